@@ -0,0 +1,121 @@
+package alternates
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/gitdir"
+)
+
+// objects/info/alternates 列出其他仓库的对象目录，每行一个（空行和 '#' 开头的行被忽略）。
+// 在本仓库中找不到的对象会继续到这些目录中查找，多个 fork 可以共用同一份对象；
+// 相对路径相对于本仓库的 objects 目录，被借用的仓库自己的 alternates 也会生效（与 git 一样最多 5 层）
+
+// maxDepth 是嵌套 alternates 的最大层数
+const maxDepth = 5
+
+// ObjectDir 返回仓库自己的 objects 目录
+func ObjectDir(gitDir string) string {
+	return filepath.Join(gitdir.CommonDir(gitDir), "objects")
+}
+
+// Path 返回 alternates 文件的路径
+func Path(gitDir string) string {
+	return filepath.Join(ObjectDir(gitDir), "info", "alternates")
+}
+
+// Read 读取 objectDir/info/alternates，返回其中列出的对象目录（转换为绝对路径）；文件不存在时返回空列表
+func Read(objectDir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(objectDir, "info", "alternates"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(objectDir, line)
+		}
+		dirs = append(dirs, filepath.Clean(line))
+	}
+	return dirs, scanner.Err()
+}
+
+// ObjectDirs 返回查找对象时依次搜索的目录：仓库自己的 objects 目录，然后是所有 alternates（包括嵌套的）
+// 不存在的目录和重复的目录被跳过
+func ObjectDirs(gitDir string) []string {
+	own := ObjectDir(gitDir)
+	dirs := []string{own}
+	seen := map[string]bool{own: true}
+	var visit func(dir string, depth int)
+	visit = func(dir string, depth int) {
+		if depth > maxDepth {
+			return
+		}
+		list, err := Read(dir)
+		if err != nil {
+			return
+		}
+		for _, alt := range list {
+			if seen[alt] {
+				continue
+			}
+			seen[alt] = true
+			if info, err := os.Stat(alt); err != nil || !info.IsDir() {
+				continue
+			}
+			dirs = append(dirs, alt)
+			visit(alt, depth+1)
+		}
+	}
+	visit(own, 1)
+	return dirs
+}
+
+// Add 把对象目录追加到仓库的 alternates 中（已经存在时不重复添加），objectDir 会被转换为绝对路径
+func Add(gitDir, objectDir string) error {
+	abs, err := filepath.Abs(objectDir)
+	if err != nil {
+		return err
+	}
+	list, err := Read(ObjectDir(gitDir))
+	if err != nil {
+		return err
+	}
+	for _, dir := range list {
+		if dir == abs {
+			return nil
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(Path(gitDir)), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(Path(gitDir), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(abs + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Remove 删除仓库的 alternates 文件，不再借用其他仓库的对象（调用方需要先把借用的对象复制过来）
+func Remove(gitDir string) error {
+	err := os.Remove(Path(gitDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
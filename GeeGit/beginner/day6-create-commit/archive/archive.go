@@ -0,0 +1,300 @@
+package archive
+
+import (
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"geegit/beginner/day6-create-commit/blob"
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/matcher"
+	"geegit/beginner/day6-create-commit/revision"
+	"geegit/beginner/day6-create-commit/tree"
+	"geegit/beginner/day6-create-commit/wildmatch"
+)
+
+// Formats 是支持的归档格式，顺序与 `git archive --list` 一致
+var Formats = []string{"tar", "tgz", "tar.gz", "zip"}
+
+// FormatFromName 根据输出文件的扩展名推断格式（git archive -o），无法推断时返回空字符串
+func FormatFromName(name string) string {
+	for _, f := range []string{"tar.gz", "tgz", "tar", "zip"} {
+		if strings.HasSuffix(name, "."+f) {
+			return f
+		}
+	}
+	return ""
+}
+
+// NoCompression 是 Options.Level 的特殊值，表示不压缩
+const NoCompression = -1
+
+// Options 控制 Write
+type Options struct {
+	Format string   // Formats 之一，为空时是 tar
+	Prefix string   // 加在每个路径前面，通常以 "/" 结尾（--prefix）
+	Subdir string   // 只归档 tree 中的这个子目录，路径相对于它（在子目录中运行 git archive）
+	Paths  []string // 只归档这些路径（相对于 tree 的根目录或 Subdir），可以是目录或通配符；为空时归档全部
+	Level  int      // tgz 和 zip 的压缩级别 1-9（-1 到 -9），0 表示默认级别，NoCompression 表示只存储（-0）
+	// Verbose 不为 nil 时，每写入一个条目就把它的路径传给 Verbose（git archive -v）
+	Verbose func(p string)
+}
+
+// ErrPathspec 表示 Paths 中有路径没有匹配任何条目
+type ErrPathspec struct {
+	Path string
+}
+
+func (e *ErrPathspec) Error() string {
+	return fmt.Sprintf("pathspec '%s' did not match any files", e.Path)
+}
+
+// entry 是归档中的一个条目
+type entry struct {
+	path string    // 加上前缀后的路径，目录以 "/" 结尾
+	mode uint32    // git 的模式：0100644、0100755、0120000、040000 或 0160000
+	hash hash.Hash // 对象的哈希，写入 pax 扩展头时用作文件名
+	data []byte    // 文件内容或符号链接的目标，目录为空
+
+	written bool // 目录是否已经写入；Paths 不为空时目录推迟到其中有条目被写入时才写入
+}
+
+func (e *entry) isDir() bool     { return e.mode&0170000 == 0040000 || e.mode == 0160000 }
+func (e *entry) isSymlink() bool { return e.mode&0170000 == 0120000 }
+
+// archiver 是一种归档格式的写入器
+type archiver interface {
+	writeEntry(e *entry) error
+	close() error
+}
+
+// Write 把 treeish（commit、标签或 tree）的内容写成归档
+//  1. commit（或指向 commit 的标签）以提交时间作为所有条目的修改时间，并把 commit 的哈希
+//     写入 tar 的 pax 全局头（comment）或 zip 的注释；tree 使用当前时间
+//  2. 从被归档的 tree 中读取 .gitattributes，跳过带有 export-ignore 属性的文件和目录
+//  3. 按 tree 中的顺序写入条目，目录在其内容之前；Paths 不为空时只写入匹配的条目和它们所在的目录
+func Write(w io.Writer, gitDir string, treeish hash.Hash, opts Options) error {
+	// 1. 确定 tree、修改时间和 commit
+	var commitID hash.Hash
+	mtime := time.Now()
+	root, err := revision.Peel(gitDir, treeish, hash.CommitObject)
+	if err == nil {
+		c, err := commit.ReadCommit(gitDir, root)
+		if err != nil {
+			return err
+		}
+		commitID, mtime, root = root, c.Committer.When, c.Tree
+	} else if root, err = revision.Peel(gitDir, treeish, hash.TreeObject); err != nil {
+		return fmt.Errorf("not a tree object: %s", treeish)
+	}
+	if opts.Subdir != "" {
+		e, err := tree.FindEntry(gitDir, root, opts.Subdir)
+		if err != nil || !e.IsDir() {
+			return errors.New("current working directory is untracked")
+		}
+		root = e.Hash
+	}
+
+	if err := checkPaths(gitDir, root, opts.Paths); err != nil {
+		return err
+	}
+
+	level := opts.Level
+	switch level {
+	case 0:
+		level = flate.DefaultCompression
+	case NoCompression:
+		level = flate.NoCompression
+	}
+
+	var a archiver
+	switch opts.Format {
+	case "", "tar":
+		if opts.Level != 0 {
+			return fmt.Errorf("Argument not supported for format 'tar': -%d", level)
+		}
+		fallthrough
+	case "tgz", "tar.gz":
+		umask, err := tarUmask(gitDir)
+		if err != nil {
+			return err
+		}
+		a, err = newTar(w, commitID, mtime.Unix(), umask, opts.Format != "" && opts.Format != "tar", level)
+		if err != nil {
+			return err
+		}
+	case "zip":
+		a = newZip(w, commitID, mtime, level)
+	default:
+		return fmt.Errorf("Unknown archive format '%s'", opts.Format)
+	}
+
+	// 2. 属性
+	attrs, err := matcher.LoadAttributesFrom(gitDir, func(name string) ([]byte, error) {
+		e, err := tree.FindEntry(gitDir, root, name)
+		if err != nil {
+			return nil, err
+		}
+		b, err := blob.ReadBlob(gitDir, e.Hash)
+		if err != nil {
+			return nil, err
+		}
+		return b.Data, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 3. 条目
+	wr := &walker{gitDir: gitDir, opts: opts, attrs: attrs, a: a}
+	if strings.HasSuffix(opts.Prefix, "/") {
+		// 前缀本身作为一个目录条目
+		if err := wr.write(&entry{path: opts.Prefix, mode: 040000, hash: root}); err != nil {
+			return err
+		}
+	}
+	if err := wr.walk(root, "", nil, len(opts.Paths) == 0); err != nil {
+		return err
+	}
+	return a.close()
+}
+
+// checkPaths 在写入任何内容之前检查 Paths 中的每一项都至少匹配 tree 中的一个文件
+func checkPaths(gitDir string, root hash.Hash, paths []string) error {
+	for _, spec := range paths {
+		found := errors.New("found")
+		err := tree.Walk(gitDir, root, func(p string, _ tree.TreeEntry) error {
+			if matchPath(spec, p) {
+				return found
+			}
+			return nil
+		})
+		if err == nil {
+			return &ErrPathspec{Path: spec}
+		} else if err != found {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchPath 判断路径是否匹配 spec：相同、在其目录下，或者符合通配符（"*" 可以匹配 "/"）
+func matchPath(spec, p string) bool {
+	spec = strings.TrimSuffix(spec, "/")
+	return spec == "" || spec == "." || p == spec || strings.HasPrefix(p, spec+"/") || wildmatch.Match(spec, p, 0)
+}
+
+// tarUmask 读取 tar.umask，缺省为 002；"user" 表示使用进程的 umask，这里同样按 002 处理
+func tarUmask(gitDir string) (uint32, error) {
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return 0, err
+	}
+	v, ok := cfg.Get("tar.umask")
+	if !ok || v == "user" {
+		return 002, nil
+	}
+	n, err := strconv.ParseUint(v, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("bad numeric config value '%s' for 'tar.umask'", v)
+	}
+	return uint32(n), nil
+}
+
+// walker 按顺序遍历 tree 并写入条目
+type walker struct {
+	gitDir string
+	opts   Options
+	attrs  *matcher.Attributes
+	a      archiver
+}
+
+// walk 写入 tree h（路径为 dir）中的条目；all 表示 dir 本身已经匹配了 Paths，其中的条目全部写入
+// pending 是还没有写入的上层目录：只有其中的条目被写入时才写入这些目录
+func (wr *walker) walk(h hash.Hash, dir string, pending []*entry, all bool) error {
+	t, err := tree.ReadTree(wr.gitDir, h)
+	if err != nil {
+		return err
+	}
+	for _, te := range t.Entries {
+		p := path.Join(dir, te.Name)
+		mode, err := strconv.ParseUint(te.Mode, 8, 32)
+		if err != nil {
+			return fmt.Errorf("invalid mode %s for %s", te.Mode, p)
+		}
+		if wr.attrs.Lookup(p).Get("export-ignore").State == matcher.Set {
+			continue
+		}
+		e := &entry{path: wr.opts.Prefix + p, mode: uint32(mode), hash: te.Hash}
+		match := all || wr.match(p)
+
+		if te.IsDir() {
+			e.path += "/"
+			if match {
+				if err := wr.flush(pending, e); err != nil {
+					return err
+				}
+				if err := wr.walk(te.Hash, p, nil, true); err != nil {
+					return err
+				}
+			} else if err := wr.walk(te.Hash, p, append(pending[:len(pending):len(pending)], e), false); err != nil {
+				return err
+			}
+			continue
+		}
+		if !match {
+			continue
+		}
+		if te.IsSubmodule() {
+			e.path += "/"
+		} else {
+			b, err := blob.ReadBlob(wr.gitDir, te.Hash)
+			if err != nil {
+				return err
+			}
+			e.data = b.Data
+		}
+		if err := wr.flush(pending, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush 写入 pending 中还没有写入的目录，然后写入条目 e
+func (wr *walker) flush(pending []*entry, e *entry) error {
+	for _, d := range pending {
+		if d.written {
+			continue
+		}
+		if err := wr.write(d); err != nil {
+			return err
+		}
+		d.written = true
+	}
+	return wr.write(e)
+}
+
+func (wr *walker) write(e *entry) error {
+	if wr.opts.Verbose != nil {
+		wr.opts.Verbose(e.path)
+	}
+	return wr.a.writeEntry(e)
+}
+
+// match 判断路径是否匹配 Paths 中的某一项
+func (wr *walker) match(p string) bool {
+	for _, spec := range wr.opts.Paths {
+		if matchPath(spec, p) {
+			return true
+		}
+	}
+	return false
+}
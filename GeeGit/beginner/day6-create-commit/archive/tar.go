@@ -0,0 +1,198 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/hash"
+)
+
+// tar 归档与 git archive 的输出逐字节相同：
+//
+//	ustar 格式，所有者为 root/root（uid、gid 为 0），所有条目的修改时间相同
+//	归档 commit 时，第一个条目是 pax 全局头 "pax_global_header"，其中 comment 记录 commit 的哈希
+//	路径超过 100 字节时优先拆分到 ustar 的 prefix 字段，拆不开时名称记为 "<哈希>.data"，
+//	真实路径放在前面的 pax 扩展头 "<哈希>.paxheader" 中；超过 100 字节的符号链接目标同样放在扩展头中
+//	文件的权限是 0666 或 0777（可执行），目录是 0777，再去掉 tar.umask；符号链接总是 0777
+//	结尾是两个全零的块，整个归档补齐到 10240 字节（20 个块）的整数倍
+
+const (
+	blockSize  = 512
+	recordSize = 20 * blockSize
+)
+
+// tarWriter 写入 tar 归档，tgz 时在外面包一层 gzip
+type tarWriter struct {
+	w     *bufio.Writer
+	gz    *gzip.Writer // 不压缩时为 nil
+	size  int64        // 已经写入的字节数
+	mtime int64
+	umask uint32
+	err   error // 第一个写入错误，之后的写入都被跳过
+}
+
+func newTar(w io.Writer, commitID hash.Hash, mtime int64, umask uint32, compress bool, level int) (*tarWriter, error) {
+	t := &tarWriter{mtime: mtime, umask: umask}
+	if compress {
+		gz, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, err
+		}
+		t.gz, w = gz, gz
+	}
+	t.w = bufio.NewWriterSize(w, recordSize)
+
+	if !commitID.IsZero() {
+		ext := paxRecord("comment", commitID.String())
+		t.writeHeader(t.header("pax_global_header", 'g', 0100666, int64(len(ext)), ""))
+		t.data([]byte(ext))
+	}
+	return t, t.err
+}
+
+func (t *tarWriter) writeEntry(e *entry) error {
+	var typ byte
+	mode := e.mode
+	var size int64
+	link := ""
+	switch {
+	case e.isDir():
+		typ, mode = '5', (mode|0777)&^t.umask
+	case e.isSymlink():
+		typ, mode, link = '2', mode|0777, string(e.data)
+	default:
+		perm := uint32(0666)
+		if mode&0100 != 0 {
+			perm = 0777
+		}
+		typ, mode, size = '0', (mode|perm)&^t.umask, int64(len(e.data))
+	}
+
+	// 1. 放不进 ustar 字段的路径和链接目标写入 pax 扩展头
+	var ext strings.Builder
+	name, prefix := e.path, ""
+	if len(name) > 100 {
+		plen := pathPrefix(name, 155)
+		if rest := len(name) - plen - 1; plen > 0 && rest <= 100 {
+			name, prefix = name[plen+1:], name[:plen]
+		} else {
+			name = e.hash.String() + ".data"
+			ext.WriteString(paxRecord("path", e.path))
+		}
+	}
+	if len(link) > 100 {
+		ext.WriteString(paxRecord("linkpath", link))
+		link = "see " + e.hash.String() + ".paxheader"
+	}
+	if ext.Len() > 0 {
+		t.writeHeader(t.header(e.hash.String()+".paxheader", 'x', 0100666, int64(ext.Len()), ""))
+		t.data([]byte(ext.String()))
+	}
+
+	// 2. 条目本身
+	h := t.header(name, typ, mode, size, link)
+	copy(h[345:500], prefix)
+	t.writeHeader(h)
+	if typ == '0' {
+		t.data(e.data)
+	}
+	return t.err
+}
+
+// pathPrefix 与 git 的 get_path_prefix 相同：返回路径中可以放进 prefix 字段（最长 maxlen）的部分的长度，
+// 拆分点是 "/"，目录末尾的 "/" 不算
+func pathPrefix(p string, maxlen int) int {
+	i := len(p)
+	if i > 1 && p[i-1] == '/' {
+		i--
+	}
+	if i > maxlen {
+		i = maxlen
+	}
+	for {
+		i--
+		if i <= 0 || p[i] == '/' {
+			break
+		}
+	}
+	return i
+}
+
+// paxRecord 生成一条 pax 记录 "<长度> <键>=<值>\n"，长度包括它自己的位数
+func paxRecord(key, value string) string {
+	n := 1 + 1 + len(key) + 1 + len(value) + 1
+	for tmp := 1; n/10 >= tmp; tmp *= 10 {
+		n++
+	}
+	return fmt.Sprintf("%d %s=%s\n", n, key, value)
+}
+
+// header 生成 ustar 头（还没有校验和），数字字段是以 NUL 结尾的八进制数
+func (t *tarWriter) header(name string, typ byte, mode uint32, size int64, link string) []byte {
+	h := make([]byte, blockSize)
+	copy(h[0:100], name)
+	copy(h[100:108], fmt.Sprintf("%07o", mode&07777))
+	copy(h[108:116], "0000000")
+	copy(h[116:124], "0000000")
+	copy(h[124:136], fmt.Sprintf("%011o", size))
+	copy(h[136:148], fmt.Sprintf("%011o", t.mtime))
+	h[156] = typ
+	copy(h[157:257], link)
+	copy(h[257:263], "ustar\x00")
+	copy(h[263:265], "00")
+	copy(h[265:297], "root")
+	copy(h[297:329], "root")
+	copy(h[329:337], "0000000")
+	copy(h[337:345], "0000000")
+	return h
+}
+
+// writeHeader 填入校验和（所有字节之和，校验和字段本身按空格计算）后写入头
+func (t *tarWriter) writeHeader(h []byte) {
+	copy(h[148:156], "        ")
+	sum := 0
+	for _, b := range h {
+		sum += int(b)
+	}
+	copy(h[148:156], fmt.Sprintf("%07o\x00", sum))
+	t.block(h)
+}
+
+// block 写入一个块
+func (t *tarWriter) block(b []byte) {
+	if t.err != nil {
+		return
+	}
+	_, t.err = t.w.Write(b)
+	t.size += int64(len(b))
+}
+
+// data 写入数据并用 0 补齐到块的整数倍
+func (t *tarWriter) data(b []byte) {
+	t.block(b)
+	if n := len(b) % blockSize; n != 0 {
+		t.block(make([]byte, blockSize-n))
+	}
+}
+
+// close 写入结尾：至少两个全零的块，并补齐到 recordSize 的整数倍
+func (t *tarWriter) close() error {
+	tail := recordSize - t.size%recordSize
+	if tail < 2*blockSize {
+		tail += recordSize
+	}
+	t.block(make([]byte, tail))
+	if t.err != nil {
+		return t.err
+	}
+	if err := t.w.Flush(); err != nil {
+		return err
+	}
+	if t.gz != nil {
+		return t.gz.Close()
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package archive
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"io"
+	"time"
+
+	"geegit/beginner/day6-create-commit/hash"
+)
+
+// zip 归档与 git archive 的约定相同：commit 的哈希写入归档注释；普通文件和目录按 MS-DOS 属性记录，
+// 可执行文件（0755）和符号链接（0777，内容为链接目标）按 Unix 属性记录；修改时间同时写入 DOS 时间
+// 和扩展时间戳（UT）字段
+
+const (
+	creatorUnix = 3    // CreatorVersion 的高字节，表示 ExternalAttrs 的高 16 位是 Unix 模式
+	msdosDir    = 0x10 // MS-DOS 的目录属性
+)
+
+// zipWriter 写入 zip 归档
+type zipWriter struct {
+	w     *zip.Writer
+	mtime time.Time
+	store bool // 压缩级别为 0 时所有文件都只存储
+}
+
+func newZip(w io.Writer, commitID hash.Hash, mtime time.Time, level int) *zipWriter {
+	zw := zip.NewWriter(w)
+	zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, level)
+	})
+	if !commitID.IsZero() {
+		zw.SetComment(commitID.String())
+	}
+	return &zipWriter{w: zw, mtime: mtime.Local(), store: level == flate.NoCompression}
+}
+
+func (z *zipWriter) writeEntry(e *entry) error {
+	fh := &zip.FileHeader{Name: e.path, Method: zip.Store, Modified: z.mtime}
+	switch {
+	case e.isDir():
+		fh.ExternalAttrs = msdosDir
+	case e.isSymlink():
+		fh.CreatorVersion = creatorUnix << 8
+		fh.ExternalAttrs = 0120777 << 16
+	default:
+		if e.mode&0100 != 0 {
+			fh.CreatorVersion = creatorUnix << 8
+			fh.ExternalAttrs = 0100755 << 16
+		}
+		if len(e.data) > 0 && !z.store {
+			fh.Method = zip.Deflate
+		}
+	}
+	w, err := z.w.CreateHeader(fh)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(e.data)
+	return err
+}
+
+func (z *zipWriter) close() error {
+	return z.w.Close()
+}
@@ -0,0 +1,237 @@
+package bisect
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/refs"
+)
+
+// 二分查找的状态与 git 相同，保存在当前工作区的 git 目录中，可以和 git bisect 混用：
+//
+//	BISECT_START          开始前 HEAD 所在的分支名，分离 HEAD 时是完整哈希；存在即表示正在二分查找
+//	BISECT_TERMS          两个状态的名称，固定为 "bad" 和 "good"
+//	BISECT_NAMES          start 时给出的路径，shell 引号格式
+//	BISECT_LOG            可以重放的操作记录（git bisect log）
+//	BISECT_EXPECTED_REV   最近一次检出的待测 commit
+//	BISECT_ANCESTORS_OK   已经确认所有好的 commit 都是坏的 commit 的祖先
+//	refs/bisect/bad       坏的 commit
+//	refs/bisect/good-<hash>, refs/bisect/skip-<hash>  好的 commit 和跳过的 commit
+
+// 标记的名称
+const (
+	Bad  = "bad"
+	Good = "good"
+	Skip = "skip"
+)
+
+const (
+	startFile       = "BISECT_START"
+	termsFile       = "BISECT_TERMS"
+	namesFile       = "BISECT_NAMES"
+	logFile         = "BISECT_LOG"
+	expectedFile    = "BISECT_EXPECTED_REV"
+	ancestorsOKFile = "BISECT_ANCESTORS_OK"
+	refPrefix       = "refs/bisect/"
+)
+
+// ErrNotBisecting 表示没有正在进行的二分查找
+var ErrNotBisecting = errors.New("We are not bisecting.")
+
+// State 是正在进行的二分查找
+type State struct {
+	Start string      // 开始前 HEAD 所在的分支名，分离 HEAD 时是完整哈希
+	Bad   hash.Hash   // 零哈希表示还没有标记
+	Good  []hash.Hash // 按哈希排序
+	Skip  []hash.Hash // 按哈希排序
+	Paths []string    // 只关心修改了这些路径的 commit
+}
+
+// Load 读取二分查找的状态，没有正在进行的二分查找时返回 ErrNotBisecting
+func Load(gitDir string) (*State, error) {
+	data, err := os.ReadFile(filepath.Join(gitDir, startFile))
+	if os.IsNotExist(err) || err == nil && len(data) == 0 {
+		return nil, ErrNotBisecting
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &State{Start: strings.TrimSpace(string(data))}
+
+	if data, err := os.ReadFile(filepath.Join(gitDir, namesFile)); err == nil {
+		if s.Paths, err = unquote(strings.TrimSpace(string(data))); err != nil {
+			return nil, fmt.Errorf("%s: %v", namesFile, err)
+		}
+	}
+	list, err := refs.List(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range list {
+		name, ok := strings.CutPrefix(r.Name, refPrefix)
+		switch {
+		case !ok:
+		case name == Bad:
+			s.Bad = r.Hash
+		case strings.HasPrefix(name, Good+"-"):
+			s.Good = append(s.Good, r.Hash)
+		case strings.HasPrefix(name, Skip+"-"):
+			s.Skip = append(s.Skip, r.Hash)
+		}
+	}
+	return s, nil
+}
+
+// Start 清除旧的状态，开始新的二分查找；start 是开始前 HEAD 所在的分支名（分离 HEAD 时是完整哈希）
+func Start(gitDir, start string, paths []string) error {
+	if err := Clean(gitDir); err != nil {
+		return err
+	}
+	files := []struct{ name, content string }{
+		{startFile, start + "\n"},
+		{termsFile, Bad + "\n" + Good + "\n"},
+		{namesFile, Quote(paths) + "\n"},
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(gitDir, f.name), []byte(f.content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Mark 把 h 标记为 term（Bad、Good 或 Skip），在日志中记录 "# <term>: [<hash>] <subject>"；
+// command 为 true 时再记录可以重放的命令 "git bisect <term> <hash>"
+// 标记的不是最近一次检出的 commit 时，之前对祖先关系的检查作废
+func Mark(gitDir, term string, h hash.Hash, command bool) error {
+	name := refPrefix + term
+	if term != Bad {
+		name += "-" + h.String()
+	}
+	if err := refs.Update(gitDir, name, h, nil, ""); err != nil {
+		return err
+	}
+	if err := logCommit(gitDir, term, h); err != nil {
+		return err
+	}
+	if command {
+		if err := AppendLog(gitDir, fmt.Sprintf("git bisect %s %s\n", term, h)); err != nil {
+			return err
+		}
+	}
+
+	if expected, ok := Expected(gitDir); ok && expected != h {
+		for _, name := range []string{ancestorsOKFile, expectedFile} {
+			if err := os.Remove(filepath.Join(gitDir, name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Expect 记录即将检出的待测 commit
+func Expect(gitDir string, h hash.Hash) error {
+	return os.WriteFile(filepath.Join(gitDir, expectedFile), []byte(h.String()+"\n"), 0644)
+}
+
+// Expected 返回最近一次检出的待测 commit
+func Expected(gitDir string) (hash.Hash, bool) {
+	data, err := os.ReadFile(filepath.Join(gitDir, expectedFile))
+	if err != nil {
+		return hash.Hash{}, false
+	}
+	h, err := hash.ParseHash(strings.TrimSpace(string(data)))
+	return h, err == nil
+}
+
+// AppendLog 在 BISECT_LOG 末尾追加内容
+func AppendLog(gitDir, text string) error {
+	f, err := os.OpenFile(filepath.Join(gitDir, logFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Log 返回 BISECT_LOG 的内容
+func Log(gitDir string) ([]byte, error) {
+	if _, err := Load(gitDir); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filepath.Join(gitDir, logFile))
+}
+
+// Clean 删除二分查找的全部状态：refs/bisect/ 下的引用和 BISECT_* 文件
+func Clean(gitDir string) error {
+	list, err := refs.List(gitDir)
+	if err != nil {
+		return err
+	}
+	for _, r := range list {
+		if strings.HasPrefix(r.Name, refPrefix) {
+			if err := refs.Delete(gitDir, r.Name, nil); err != nil {
+				return err
+			}
+		}
+	}
+	// BISECT_START 最后删除，中途失败时仍然处于二分查找中，可以再次 reset
+	for _, name := range []string{expectedFile, ancestorsOKFile, logFile, namesFile, termsFile, startFile} {
+		if err := os.Remove(filepath.Join(gitDir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Quote 按 shell 单引号的规则把参数拼接成 " 'a' 'b'" 的形式（与 git 的 sq_quote_argv 相同，
+// 单引号和感叹号放在引号外面转义），用于 BISECT_NAMES、日志和 run 执行的命令
+func Quote(args []string) string {
+	var b strings.Builder
+	for _, arg := range args {
+		b.WriteString(" '")
+		for _, c := range arg {
+			if c == '\'' || c == '!' {
+				b.WriteString(`'\` + string(c) + `'`)
+			} else {
+				b.WriteRune(c)
+			}
+		}
+		b.WriteString("'")
+	}
+	return b.String()
+}
+
+// unquote 是 Quote 的逆操作
+func unquote(s string) ([]string, error) {
+	var args []string
+	for s = strings.TrimLeft(s, " "); s != ""; s = strings.TrimLeft(s, " ") {
+		var b strings.Builder
+		for s != "" && s[0] != ' ' {
+			switch {
+			case s[0] == '\'':
+				end := strings.IndexByte(s[1:], '\'')
+				if end < 0 {
+					return nil, errors.New("unterminated quote")
+				}
+				b.WriteString(s[1 : end+1])
+				s = s[end+2:]
+			case s[0] == '\\' && len(s) > 1:
+				b.WriteByte(s[1])
+				s = s[2:]
+			default:
+				return nil, fmt.Errorf("unquoted character %q", s[0])
+			}
+		}
+		args = append(args, b.String())
+	}
+	return args, nil
+}
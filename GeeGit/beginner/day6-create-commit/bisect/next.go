@@ -0,0 +1,486 @@
+package bisect
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/commitgraph"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/revision"
+)
+
+// Outcome 是 Next 的结果类型
+type Outcome int
+
+const (
+	Testing     Outcome = iota // 检出 Step.Commit 继续测试
+	MergeBase                  // 好的 commit 不都是坏的 commit 的祖先，需要先测试它们的共同祖先 Step.Commit
+	Found                      // Step.Commit 是第一个坏的 commit
+	OnlySkipped                // 只剩下跳过的 commit，第一个坏的 commit 是 Step.Candidates 之一
+)
+
+// Step 是二分查找的下一步
+type Step struct {
+	Outcome    Outcome
+	Commit     hash.Hash
+	Left       int         // Testing：测试 Commit 之后最多还剩下的 commit 数量
+	Steps      int         // Testing：估计还需要测试的次数
+	Candidates []hash.Hash // OnlySkipped：可能是第一个坏的 commit 的 commit
+	// SkippedBase 不为零时，好的 commit 与坏的 commit 的共同祖先被跳过了，结果不一定可靠
+	SkippedBase hash.Hash
+}
+
+// ErrNoTestable 表示候选的 commit 都没有修改 start 时给出的路径
+var ErrNoTestable = errors.New("No testable commit found.\nMaybe you started with bad path arguments?")
+
+// BothError 表示坏的 commit 同时是好的 commit（它是某个好的 commit 的祖先）
+type BothError struct {
+	Commit hash.Hash
+}
+
+func (e *BothError) Error() string {
+	return fmt.Sprintf("%s was both %s and %s", e.Commit, Good, Bad)
+}
+
+// MergeBaseError 表示坏的 commit 就是它与好的 commit 的共同祖先
+type MergeBaseError struct {
+	Bad    hash.Hash
+	Good   []hash.Hash
+	Tested bool // 共同祖先是 Next 要求测试、刚刚被标记为坏的：问题在好的 commit 上已经修复了
+}
+
+func (e *MergeBaseError) Error() string {
+	if !e.Tested {
+		return "Some good revs are not ancestors of the bad rev.\n" +
+			"git bisect cannot work properly in this case.\n" +
+			"Maybe you mistook good and bad revs?"
+	}
+	return fmt.Sprintf("The merge base %s is bad.\nThis means the bug has been fixed between %s and [%s].",
+		e.Bad, e.Bad, joinHashes(e.Good))
+}
+
+// Waiting 在还缺少好的或坏的 commit 时返回提示，例如 "status: waiting for both good and bad commits"
+func (s *State) Waiting() string {
+	switch {
+	case len(s.Good) > 0 && !s.Bad.IsZero():
+		return ""
+	case len(s.Good) > 0:
+		commits := "commits"
+		if len(s.Good) == 1 {
+			commits = "commit"
+		}
+		return fmt.Sprintf("status: waiting for %s commit, %d %s %s known", Bad, len(s.Good), Good, commits)
+	case !s.Bad.IsZero():
+		return fmt.Sprintf("status: waiting for %s commit(s), %s commit known", Good, Bad)
+	default:
+		return fmt.Sprintf("status: waiting for both %s and %s commits", Good, Bad)
+	}
+}
+
+// Next 根据已有的标记决定下一步，需要已经有好的和坏的 commit
+//  1. 好的 commit 不都是坏的 commit 的祖先时，先要求测试它们的共同祖先（确认过一次之后不再检查）
+//  2. 候选是从坏的 commit 可以到达、从好的 commit 不能到达的 commit；指定了路径时只有修改了这些路径的
+//     commit 参与计数。选择能到达的候选数量最接近一半的 commit，这样无论结果好坏都能排除一半
+//  3. 有跳过的 commit 时按与 git 相同的伪随机规则在最好的几个 commit 附近另选一个
+//  4. 选中的是坏的 commit 本身时，它就是第一个坏的 commit
+//
+// 找到第一个坏的 commit 或只剩下跳过的 commit 时，结论记录到日志中
+func Next(gitDir string) (*Step, error) {
+	s, err := Load(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	if s.Waiting() != "" {
+		return nil, errors.New(s.Waiting())
+	}
+
+	// 1. 祖先关系
+	step, skippedBase, err := s.checkMergeBases(gitDir)
+	if err != nil || step != nil {
+		return step, err
+	}
+
+	// 2. 候选
+	b, err := s.candidates(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	var chosen *candidate
+	var tried []hash.Hash
+	reaches := 0
+	if len(s.Skip) == 0 {
+		if chosen = b.find(); chosen != nil {
+			reaches = chosen.weight
+		}
+	} else {
+		// 3. 跳过的 commit
+		sorted := b.sorted()
+		if len(sorted) > 0 {
+			reaches = sorted[0].weight
+		}
+		chosen, tried = b.skipAway(sorted, s.Skip, s.Bad)
+	}
+
+	step = &Step{SkippedBase: skippedBase}
+	switch {
+	case chosen == nil && len(tried) == 0:
+		return nil, &BothError{Commit: s.Bad}
+	case chosen == nil:
+		step.Outcome, step.Candidates = OnlySkipped, tried
+	case b.nr == 0:
+		return nil, ErrNoTestable
+	case chosen.hash == s.Bad && len(tried) > 0:
+		step.Outcome, step.Candidates = OnlySkipped, append(tried, s.Bad)
+	case chosen.hash == s.Bad:
+		// 4. 找到了
+		step.Outcome, step.Commit = Found, s.Bad
+		return step, logCommit(gitDir, "first bad commit", s.Bad)
+	default:
+		step.Outcome, step.Commit = Testing, chosen.hash
+		step.Left = b.nr - reaches - 1
+		step.Steps = estimateSteps(b.nr)
+		return step, nil
+	}
+	for _, h := range step.Candidates {
+		if err := logCommit(gitDir, "possible first bad commit", h); err != nil {
+			return nil, err
+		}
+	}
+	return step, nil
+}
+
+// checkMergeBases 检查好的 commit 是否都是坏的 commit 的祖先，不是时检查它们的共同祖先：
+// 共同祖先是坏的 commit 时无法二分，是跳过的 commit 时只能继续，还没有测试过时需要先测试它
+func (s *State) checkMergeBases(gitDir string) (*Step, hash.Hash, error) {
+	okFile := filepath.Join(gitDir, ancestorsOKFile)
+	if _, err := os.Stat(okFile); err == nil {
+		return nil, hash.Hash{}, nil
+	}
+	all := true
+	for _, g := range s.Good {
+		ok, err := revision.IsAncestor(gitDir, g, s.Bad)
+		if err != nil {
+			return nil, hash.Hash{}, err
+		}
+		all = all && ok
+	}
+	var skipped hash.Hash
+	if !all {
+		bases, err := revision.MergeBases(gitDir, s.Bad, s.Good...)
+		if err != nil {
+			return nil, hash.Hash{}, err
+		}
+	loop:
+		for _, mb := range bases {
+			switch {
+			case mb == s.Bad:
+				expected, _ := Expected(gitDir)
+				return nil, hash.Hash{}, &MergeBaseError{Bad: s.Bad, Good: s.Good, Tested: expected == s.Bad}
+			case contains(s.Good, mb):
+			case contains(s.Skip, mb):
+				skipped = mb
+				break loop
+			default:
+				return &Step{Outcome: MergeBase, Commit: mb}, hash.Hash{}, nil
+			}
+		}
+	}
+	return nil, skipped, os.WriteFile(okFile, nil, 0644)
+}
+
+// candidate 是一个候选 commit
+type candidate struct {
+	hash     hash.Hash
+	parents  []*candidate // 也是候选的父 commit
+	treesame bool         // 没有修改关心的路径，不参与计数
+	weight   int          // 能到达的参与计数的候选数量（包括自己）；-1 和 -2 表示还没有计算
+}
+
+// bisection 是全部候选，从旧到新排列
+type bisection struct {
+	list []*candidate
+	nr   int // 参与计数的候选数量
+}
+
+// candidates 列出候选 commit
+func (s *State) candidates(gitDir string) (*bisection, error) {
+	opts := revision.ListOptions{Include: []hash.Hash{s.Bad}, Exclude: s.Good}
+	list, err := revision.List(gitDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	var changed map[hash.Hash]bool
+	if len(s.Paths) > 0 {
+		opts.Paths = s.Paths
+		hs, err := revision.List(gitDir, opts)
+		if err != nil {
+			return nil, err
+		}
+		changed = make(map[hash.Hash]bool, len(hs))
+		for _, h := range hs {
+			changed[h] = true
+		}
+	}
+
+	b := &bisection{}
+	byHash := make(map[hash.Hash]*candidate, len(list))
+	for i := len(list) - 1; i >= 0; i-- {
+		c := &candidate{hash: list[i], treesame: changed != nil && !changed[list[i]]}
+		if !c.treesame {
+			b.nr++
+		}
+		byHash[c.hash] = c
+		b.list = append(b.list, c)
+	}
+	for _, c := range b.list {
+		gc, err := commitgraph.Read(gitDir, c.hash)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range gc.Parents {
+			if pc := byHash[p]; pc != nil {
+				c.parents = append(c.parents, pc)
+			}
+		}
+	}
+	return b, nil
+}
+
+// weigh 计算每个候选的 weight（git 的 do_find_bisection）
+// 只有一个候选父 commit 的 commit 比父 commit 多能到达自己，不需要遍历；合并 commit 的多个父 commit
+// 通常能到达相同的祖先，只能逐个遍历计数。stop 不为 nil 时，遇到 stop 返回 true 的 commit 立即返回它
+func (b *bisection) weigh(stop func(c *candidate) bool) *candidate {
+	counted := 0
+	for _, c := range b.list {
+		switch len(c.parents) {
+		case 0:
+			c.weight = 0
+			if !c.treesame {
+				c.weight = 1
+				counted++
+			}
+		case 1:
+			c.weight = -1
+		default:
+			c.weight = -2
+		}
+	}
+	for _, c := range b.list {
+		if c.weight != -2 {
+			continue
+		}
+		c.weight = c.distance()
+		if stop != nil && stop(c) {
+			return c
+		}
+		counted++
+	}
+	for progress := true; counted < b.nr && progress; {
+		progress = false
+		for _, c := range b.list {
+			if c.weight >= 0 {
+				continue
+			}
+			var q *candidate
+			for _, p := range c.parents {
+				if p.weight >= 0 {
+					q = p
+					break
+				}
+			}
+			if q == nil {
+				continue
+			}
+			c.weight = q.weight
+			if !c.treesame {
+				c.weight++
+				counted++
+			}
+			progress = true
+			if stop != nil && stop(c) {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// distance 遍历计算 c 能到达的参与计数的候选数量
+func (c *candidate) distance() int {
+	seen := map[*candidate]bool{c: true}
+	stack := []*candidate{c}
+	n := 0
+	for len(stack) > 0 {
+		x := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !x.treesame {
+			n++
+		}
+		for _, p := range x.parents {
+			if !seen[p] {
+				seen[p] = true
+				stack = append(stack, p)
+			}
+		}
+	}
+	return n
+}
+
+// halfway 判断 c 是否恰好能到达一半的候选（候选很多时允许千分之一的误差）
+func (b *bisection) halfway(c *candidate) bool {
+	if c.treesame {
+		return false
+	}
+	diff := 2*c.weight - b.nr
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= 1 || diff < b.nr/1024
+}
+
+// dist 返回测试 c 之后至少能排除的候选数量
+func (b *bisection) dist(c *candidate) int {
+	return min(c.weight, b.nr-c.weight)
+}
+
+// find 返回最好的候选：先找到的恰好一半的候选，否则是 dist 最大的候选中最旧的；没有候选时返回 nil
+func (b *bisection) find() *candidate {
+	if len(b.list) == 0 {
+		return nil
+	}
+	if c := b.weigh(b.halfway); c != nil {
+		return c
+	}
+	best, bestDist := b.list[0], -1
+	for _, c := range b.list {
+		if !c.treesame && b.dist(c) > bestDist {
+			best, bestDist = c, b.dist(c)
+		}
+	}
+	return best
+}
+
+// sorted 返回参与计数的候选，按 dist 从大到小排列，dist 相同时按哈希排列
+func (b *bisection) sorted() []*candidate {
+	b.weigh(nil)
+	var out []*candidate
+	for _, c := range b.list {
+		if !c.treesame {
+			out = append(out, c)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if di, dj := b.dist(out[i]), b.dist(out[j]); di != dj {
+			return di > dj
+		}
+		return bytes.Compare(out[i].hash[:], out[j].hash[:]) < 0
+	})
+	return out
+}
+
+// skipAway 在排好序的候选中选择没有跳过的 commit，tried 是被略过的跳过的 commit
+// 最好的候选没有跳过时直接选择它；否则与 git 相同，用以剩余数量为种子的伪随机数在剩余的候选中选一个，
+// 越靠前（越接近一半）的候选越容易被选中。没有剩余的候选时返回 nil
+func (b *bisection) skipAway(sorted []*candidate, skip []hash.Hash, bad hash.Hash) (*candidate, []hash.Hash) {
+	if len(sorted) == 0 {
+		return nil, nil
+	}
+	if !contains(skip, sorted[0].hash) {
+		return sorted[0], nil
+	}
+	var rest []*candidate
+	var tried []hash.Hash
+	for _, c := range sorted {
+		if contains(skip, c.hash) {
+			tried = append(tried, c.hash)
+		} else {
+			rest = append(rest, c)
+		}
+	}
+	if len(rest) == 0 {
+		return nil, tried
+	}
+
+	const modulo = 32768
+	count := len(rest)
+	prn := int((uint32(count)*1103515245 + 12345) / 65536 % modulo)
+	index := (count * prn / modulo) * sqrti(prn) / sqrti(modulo)
+	if index < count {
+		if rest[index].hash != bad {
+			return rest[index], tried
+		}
+		if index > 0 {
+			return rest[index-1], tried
+		}
+	}
+	return rest[0], tried
+}
+
+// sqrti 用牛顿迭代计算整数平方根，与 git 一样使用单精度浮点数，保证伪随机选择的结果相同
+func sqrti(val int) int {
+	if val == 0 {
+		return 0
+	}
+	x := float32(val)
+	for {
+		y := (x + float32(val)/x) / 2
+		d := y - x
+		if d < 0 {
+			d = -d
+		}
+		x = y
+		if d < 0.5 {
+			return int(x)
+		}
+	}
+}
+
+// estimateSteps 估计在 all 个候选中找到第一个坏的 commit 还需要测试的次数（git 的 estimate_bisect_steps）
+// 设 all = 2^n + x（0 <= x < 2^n），测试一次之后平均剩下约 all/2 个候选，
+// x 较小时最后一步往往可以省掉
+func estimateSteps(all int) int {
+	if all < 3 {
+		return 0
+	}
+	n := bits.Len(uint(all)) - 1
+	e := 1 << n
+	x := all - e
+	if e < 3*x {
+		return n
+	}
+	return n - 1
+}
+
+// logCommit 在日志中记录 "# <what>: [<hash>] <subject>"
+func logCommit(gitDir, what string, h hash.Hash) error {
+	c, err := commit.ReadCommit(gitDir, h)
+	if err != nil {
+		return err
+	}
+	subject, _, _ := strings.Cut(c.Message, "\n")
+	return AppendLog(gitDir, fmt.Sprintf("# %s: [%s] %s\n", what, h, subject))
+}
+
+func contains(list []hash.Hash, h hash.Hash) bool {
+	for _, x := range list {
+		if x == h {
+			return true
+		}
+	}
+	return false
+}
+
+func joinHashes(list []hash.Hash) string {
+	s := make([]string, len(list))
+	for i, h := range list {
+		s[i] = h.String()
+	}
+	return strings.Join(s, " ")
+}
@@ -0,0 +1,167 @@
+package bitmap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// Bitmap 是未压缩的位图，第 i 位是 words[i/64] 的第 i%64 位（与 git 的 ewah 相同，从低位开始）
+type Bitmap struct {
+	words []uint64
+}
+
+// Set 把第 i 位置为 1
+func (b *Bitmap) Set(i int) {
+	for len(b.words) <= i/64 {
+		b.words = append(b.words, 0)
+	}
+	b.words[i/64] |= 1 << (i % 64)
+}
+
+// Get 判断第 i 位是否为 1
+func (b *Bitmap) Get(i int) bool {
+	return i/64 < len(b.words) && b.words[i/64]&(1<<(i%64)) != 0
+}
+
+// Or 把 o 中为 1 的位合并进来
+func (b *Bitmap) Or(o *Bitmap) {
+	for len(b.words) < len(o.words) {
+		b.words = append(b.words, 0)
+	}
+	for i, w := range o.words {
+		b.words[i] |= w
+	}
+}
+
+// AndNot 清除 o 中为 1 的位
+func (b *Bitmap) AndNot(o *Bitmap) {
+	for i := range b.words {
+		if i < len(o.words) {
+			b.words[i] &^= o.words[i]
+		}
+	}
+}
+
+// Xor 与 o 按位异或，用于还原以 XOR 方式存储的位图
+func (b *Bitmap) Xor(o *Bitmap) {
+	for len(b.words) < len(o.words) {
+		b.words = append(b.words, 0)
+	}
+	for i, w := range o.words {
+		b.words[i] ^= w
+	}
+}
+
+// CountAnd 返回同时在 b 和 o 中为 1 的位数
+func (b *Bitmap) CountAnd(o *Bitmap) int {
+	n := 0
+	for i, w := range b.words {
+		if i < len(o.words) {
+			n += bits.OnesCount64(w & o.words[i])
+		}
+	}
+	return n
+}
+
+// Each 按从小到大的顺序对每个为 1 的位调用 fn
+func (b *Bitmap) Each(fn func(i int) error) error {
+	for i, w := range b.words {
+		for w != 0 {
+			if err := fn(i*64 + bits.TrailingZeros64(w)); err != nil {
+				return err
+			}
+			w &= w - 1
+		}
+	}
+	return nil
+}
+
+// EWAH（Enhanced Word-Aligned Hybrid）压缩格式:
+//
+//	bitSize(4) wordCount(4) words(wordCount*8) lastRLW(4)
+//
+// words 由若干组组成，每组以一个 RLW（running length word）开头：第 0 位是连续字的取值，
+// 第 1-32 位是连续的全 0 或全 1 字的个数，第 33-63 位是紧随其后的原样存储的字（literal）的个数
+const (
+	maxRunningLength = 1<<32 - 1
+	maxLiteralWords  = 1<<31 - 1
+)
+
+// decodeEWAH 从 data 开头解析一个 EWAH 位图，返回位图和占用的字节数
+func decodeEWAH(data []byte) (*Bitmap, int, error) {
+	be := binary.BigEndian
+	if len(data) < 8 {
+		return nil, 0, fmt.Errorf("ewah bitmap is truncated")
+	}
+	n := int(be.Uint32(data[4:8]))
+	size := 8 + n*8 + 4
+	if n < 0 || len(data) < size {
+		return nil, 0, fmt.Errorf("ewah bitmap is truncated")
+	}
+	b := &Bitmap{}
+	for i := 0; i < n; {
+		rlw := be.Uint64(data[8+i*8:])
+		i++
+		run := int(rlw >> 1 & maxRunningLength)
+		literals := int(rlw >> 33)
+		var fill uint64
+		if rlw&1 != 0 {
+			fill = ^uint64(0)
+		}
+		for ; run > 0; run-- {
+			b.words = append(b.words, fill)
+		}
+		if i+literals > n {
+			return nil, 0, fmt.Errorf("ewah bitmap has too many literal words")
+		}
+		for ; literals > 0; literals-- {
+			b.words = append(b.words, be.Uint64(data[8+i*8:]))
+			i++
+		}
+	}
+	return b, size, nil
+}
+
+// encodeEWAH 把位图压缩成 EWAH 格式
+func encodeEWAH(b *Bitmap) []byte {
+	words := b.words
+	for len(words) > 0 && words[len(words)-1] == 0 {
+		words = words[:len(words)-1]
+	}
+	clean := func(w uint64) bool { return w == 0 || w == ^uint64(0) }
+
+	var out []uint64
+	lastRLW := 0
+	for i := 0; i < len(words) || len(out) == 0; {
+		lastRLW = len(out)
+		out = append(out, 0)
+		var fill uint64
+		run := 0
+		if i < len(words) && clean(words[i]) {
+			w := words[i]
+			fill = w & 1
+			for i < len(words) && words[i] == w && run < maxRunningLength {
+				run++
+				i++
+			}
+		}
+		literals := 0
+		for i < len(words) && !clean(words[i]) && literals < maxLiteralWords {
+			out = append(out, words[i])
+			literals++
+			i++
+		}
+		out[lastRLW] = fill | uint64(run)<<1 | uint64(literals)<<33
+	}
+
+	be := binary.BigEndian
+	buf := make([]byte, 8+len(out)*8+4)
+	be.PutUint32(buf, uint32(len(words)*64))
+	be.PutUint32(buf[4:], uint32(len(out)))
+	for i, w := range out {
+		be.PutUint64(buf[8+i*8:], w)
+	}
+	be.PutUint32(buf[8+len(out)*8:], uint32(lastRLW))
+	return buf
+}
@@ -0,0 +1,220 @@
+package bitmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/pack"
+	"geegit/beginner/day6-create-commit/shallow"
+)
+
+// 可达性位图（.bitmap 文件）为一部分 commit 记录从它出发可以到达的所有对象，每个对象对应一位，
+// 计算可达对象时遇到这些 commit 直接合并位图，不需要继续遍历。格式:
+//
+//	"BITM" version(2) flags(2) entryCount(4) checksum(20)
+//	commits trees blobs tags: 按对象类型划分的四个 EWAH 位图
+//	entries: (objectPos(4) xorOffset(1) flags(1) EWAH) * entryCount
+//	[name-hash cache(N*4)，flags 含 0x4 时] [lookup table，flags 含 0x10 时]
+//	checksum(20)
+//
+// packfile 的位图（pack-<hash>.bitmap）按对象在 .pack 中的偏移排列各位，header 中是 packfile 的校验和；
+// multi-pack-index 的位图（multi-pack-index-<checksum>.bitmap）按伪 pack 顺序，header 中是它的校验和。
+// objectPos 是 commit 在 .idx 或 multi-pack-index 中按哈希排序的下标；xorOffset 不为 0 时，
+// 存储的是与前面第 xorOffset 个条目的位图异或的结果
+
+var signature = []byte("BITM")
+
+const (
+	flagFullDAG = 0x1 // 位图包括 commit 可以到达的所有对象，唯一支持的方式
+
+	headerSize   = 32
+	maxXorOffset = 160
+)
+
+// Index 表示仓库的可达性位图
+type Index struct {
+	Objects []hash.Hash // 每一位对应的对象
+
+	types   [4]*Bitmap                  // 按 hash.ObjectType 划分
+	entries map[hash.Hash]*Bitmap       // commit -> 从它可以到达的所有对象
+	find    func(hash.Hash) (int, bool) // 在按哈希排序的表中查找
+	bitOf   []uint32                    // 按哈希排序的下标 -> 位
+}
+
+// cached 是按文件修改时间缓存的位图
+type cached struct {
+	modTime time.Time
+	size    int64
+	index   *Index
+}
+
+var cache sync.Map
+
+// Open 读取仓库的可达性位图：优先使用 multi-pack-index 的位图，其次是某个 packfile 的位图；
+// 都没有时返回 nil。与 git 一致，浅克隆中或设置了 pack.useBitmaps=false 时不使用位图
+func Open(gitDir string) (*Index, error) {
+	if shallow.IsShallow(gitDir) {
+		return nil, nil
+	}
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Bool("pack.usebitmaps", true) {
+		return nil, nil
+	}
+
+	// 1. multi-pack-index 的位图；multi-pack-index 损坏时跳过，使用 packfile 的位图
+	if m, err := pack.OpenMultiPackIndex(gitDir); err == nil && m != nil {
+		if order := m.PseudoPackOrder(); order != nil {
+			p := filepath.Join(filepath.Dir(pack.MultiPackIndexPath(gitDir)), pack.MidxBitmapName(m.Checksum))
+			x, err := load(p, m.Checksum, m.Hashes, order, m.Find)
+			if x != nil || err != nil {
+				return x, err
+			}
+		}
+	}
+
+	// 2. packfile 的位图
+	packs, err := pack.OpenAll(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range packs {
+		idx := p.Index
+		order := make([]uint32, len(idx.Hashes))
+		for i := range order {
+			order[i] = uint32(i)
+		}
+		sort.Slice(order, func(i, j int) bool { return idx.Offsets[order[i]] < idx.Offsets[order[j]] })
+		x, err := load(strings.TrimSuffix(p.Path, ".pack")+".bitmap", idx.PackChecksum, idx.Hashes, order, idx.Find)
+		if x != nil || err != nil {
+			return x, err
+		}
+	}
+	return nil, nil
+}
+
+// load 读取位图文件，文件不存在或不属于 checksum 对应的 packfile / multi-pack-index 时返回 nil
+// sorted 是按哈希排序的所有对象，order[i] 是第 i 位对应的对象在 sorted 中的下标
+func load(path string, checksum hash.Hash, sorted []hash.Hash, order []uint32, find func(hash.Hash) (int, bool)) (*Index, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if c, ok := cache.Load(path); ok {
+		if c := c.(*cached); c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+			return c.index, nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	x := &Index{
+		Objects: make([]hash.Hash, len(order)),
+		find:    find,
+		bitOf:   make([]uint32, len(sorted)),
+	}
+	for bit, i := range order {
+		x.Objects[bit] = sorted[i]
+		x.bitOf[i] = uint32(bit)
+	}
+	if x.entries, err = parse(data, checksum, x); err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+	if x.entries == nil {
+		x = nil
+	}
+	cache.Store(path, &cached{modTime: info.ModTime(), size: info.Size(), index: x})
+	return x, nil
+}
+
+// parse 解析位图文件，填充 x 中按类型划分的位图，返回每个 commit 的位图；checksum 不一致时返回 nil
+func parse(data []byte, checksum hash.Hash, x *Index) (map[hash.Hash]*Bitmap, error) {
+	be := binary.BigEndian
+	if len(data) < headerSize+20 || !bytes.Equal(data[:4], signature) {
+		return nil, fmt.Errorf("corrupted bitmap index file (wrong header)")
+	}
+	if v := be.Uint16(data[4:6]); v != 1 {
+		return nil, fmt.Errorf("unsupported version '%d' for bitmap index file", v)
+	}
+	if flags := be.Uint16(data[6:8]); flags&flagFullDAG == 0 {
+		return nil, fmt.Errorf("unsupported options for bitmap index file")
+	}
+	if !bytes.Equal(data[12:32], checksum[:]) {
+		return nil, nil
+	}
+	count := int(be.Uint32(data[8:12]))
+	pos := headerSize
+
+	// 1. 按类型划分的位图
+	for t := range x.types {
+		b, n, err := decodeEWAH(data[pos:])
+		if err != nil {
+			return nil, err
+		}
+		x.types[t] = b
+		pos += n
+	}
+
+	// 2. 每个 commit 的位图
+	entries := make(map[hash.Hash]*Bitmap, count)
+	list := make([]*Bitmap, 0, count)
+	for i := 0; i < count; i++ {
+		if len(data) < pos+6 {
+			return nil, fmt.Errorf("corrupt ewah bitmap: truncated header for entry %d", i)
+		}
+		objPos := int(be.Uint32(data[pos:]))
+		xor := int(data[pos+4])
+		b, n, err := decodeEWAH(data[pos+6:])
+		if err != nil {
+			return nil, err
+		}
+		pos += 6 + n
+		if objPos >= len(x.bitOf) {
+			return nil, fmt.Errorf("corrupt ewah bitmap: commit index %d out of range", objPos)
+		}
+		if xor > maxXorOffset || xor > i {
+			return nil, fmt.Errorf("corrupted bitmap pack index")
+		}
+		if xor > 0 {
+			b.Xor(list[i-xor])
+		}
+		list = append(list, b)
+		entries[x.Objects[x.bitOf[objPos]]] = b
+	}
+	return entries, nil
+}
+
+// position 返回对象对应的位，对象不在位图覆盖的范围内时返回 false
+func (x *Index) position(h hash.Hash) (int, bool) {
+	i, ok := x.find(h)
+	if !ok {
+		return 0, false
+	}
+	return int(x.bitOf[i]), true
+}
+
+// typeOf 返回第 i 位对应的对象的类型
+func (x *Index) typeOf(i int) (hash.ObjectType, bool) {
+	for t, b := range x.types {
+		if b.Get(i) {
+			return hash.ObjectType(t), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,164 @@
+package bitmap
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/tag"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// Set 是一组对象：位图覆盖的对象记在对应的位上，其余对象（松散对象、写位图之后新增的 packfile 中的对象）单独记录
+type Set struct {
+	index *Index
+	bits  *Bitmap
+	extra map[hash.Hash]hash.ObjectType
+}
+
+// Reachable 返回从 tips 可以到达的所有对象
+// 从 tips 出发遍历，遇到有位图的 commit 直接合并它的位图，不再继续遍历它的祖先
+func (x *Index) Reachable(gitDir string, tips []hash.Hash) (*Set, error) {
+	return x.reach(gitDir, tips, true)
+}
+
+// ReachableCommits 与 Reachable 相同，但是不遍历 tree：结果中的 commit 是完整的，
+// tree 和 blob 只包括位图中记录的部分，只用于统计 commit
+func (x *Index) ReachableCommits(gitDir string, tips []hash.Hash) (*Set, error) {
+	return x.reach(gitDir, tips, false)
+}
+
+func (x *Index) reach(gitDir string, tips []hash.Hash, trees bool) (*Set, error) {
+	s := &Set{index: x, bits: &Bitmap{}, extra: make(map[hash.Hash]hash.ObjectType)}
+	type item struct {
+		h     hash.Hash
+		t     hash.ObjectType
+		known bool // 类型已知（来自父 commit 或 tree 条目）
+	}
+	stack := make([]item, 0, len(tips))
+	for _, h := range tips {
+		stack = append(stack, item{h: h})
+	}
+	for len(stack) > 0 {
+		it := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if s.Contains(it.h) {
+			continue
+		}
+		if b, ok := x.entries[it.h]; ok {
+			s.bits.Or(b)
+			continue
+		}
+		if it.known && it.t == hash.BlobObject {
+			s.add(it.h, it.t)
+			continue
+		}
+
+		obj, err := object.Read(gitDir, it.h)
+		if err != nil {
+			return nil, err
+		}
+		s.add(it.h, obj.Type)
+		switch obj.Type {
+		case hash.CommitObject:
+			c, err := commit.ParseCommit(obj.Content)
+			if err != nil {
+				return nil, fmt.Errorf("commit %s: %v", it.h, err)
+			}
+			for _, p := range c.Parents {
+				stack = append(stack, item{p, hash.CommitObject, true})
+			}
+			if trees {
+				stack = append(stack, item{c.Tree, hash.TreeObject, true})
+			}
+		case hash.TreeObject:
+			entries, err := tree.ParseEntries(obj.Content)
+			if err != nil {
+				return nil, fmt.Errorf("tree %s: %v", it.h, err)
+			}
+			for _, e := range entries {
+				switch {
+				case e.IsSubmodule():
+				case e.IsDir():
+					stack = append(stack, item{e.Hash, hash.TreeObject, true})
+				default:
+					stack = append(stack, item{e.Hash, hash.BlobObject, true})
+				}
+			}
+		case hash.TagObject:
+			t, err := tag.ParseTag(obj.Content)
+			if err != nil {
+				return nil, fmt.Errorf("tag %s: %v", it.h, err)
+			}
+			if trees || t.Type == hash.CommitObject || t.Type == hash.TagObject {
+				stack = append(stack, item{t.Object, t.Type, true})
+			}
+		}
+	}
+	return s, nil
+}
+
+// add 把对象加入集合
+func (s *Set) add(h hash.Hash, t hash.ObjectType) {
+	if i, ok := s.index.position(h); ok {
+		s.bits.Set(i)
+	} else {
+		s.extra[h] = t
+	}
+}
+
+// Contains 判断对象是否在集合中
+func (s *Set) Contains(h hash.Hash) bool {
+	if i, ok := s.index.position(h); ok {
+		return s.bits.Get(i)
+	}
+	_, ok := s.extra[h]
+	return ok
+}
+
+// AndNot 从集合中去掉 o 中的对象，o 必须来自同一个 Index
+func (s *Set) AndNot(o *Set) {
+	s.bits.AndNot(o.bits)
+	for h := range o.extra {
+		delete(s.extra, h)
+	}
+}
+
+// Count 返回集合中某种类型的对象数量
+func (s *Set) Count(t hash.ObjectType) int {
+	n := s.bits.CountAnd(s.index.types[t])
+	for _, et := range s.extra {
+		if et == t {
+			n++
+		}
+	}
+	return n
+}
+
+// Each 对集合中的每个对象调用 fn：先按位的顺序，然后是其余对象按哈希排序
+func (s *Set) Each(fn func(h hash.Hash, t hash.ObjectType) error) error {
+	err := s.bits.Each(func(i int) error {
+		t, ok := s.index.typeOf(i)
+		if !ok {
+			return fmt.Errorf("object %s has no type in the bitmap index", s.index.Objects[i])
+		}
+		return fn(s.index.Objects[i], t)
+	})
+	if err != nil {
+		return err
+	}
+	extra := make([]hash.Hash, 0, len(s.extra))
+	for h := range s.extra {
+		extra = append(extra, h)
+	}
+	sort.Slice(extra, func(i, j int) bool { return bytes.Compare(extra[i][:], extra[j][:]) < 0 })
+	for _, h := range extra {
+		if err := fn(h, s.extra[h]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package bitmap
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+
+	"geegit/beginner/day6-create-commit/commitgraph"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/lockfile"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/pack"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/tag"
+)
+
+// selectInterval 是选择 commit 的间隔：两个有位图的 commit 之间最多遍历这么多 commit
+const selectInterval = 100
+
+// Write 为 multi-pack-index 生成可达性位图（git multi-pack-index write --bitmap），m 必须带有 RIDX
+//  1. 按对象类型划分 multi-pack-index 中的所有对象
+//  2. 从所有引用出发，按父 commit 在前的顺序列出 commit，选出引用指向的 commit，以及每隔 selectInterval 个选一个
+//  3. 按同样的顺序计算每个选中 commit 的位图，遇到已经算好的祖先直接合并；
+//     可以到达的对象必须都在 multi-pack-index 中
+//  4. 写出 multi-pack-index-<checksum>.bitmap
+func Write(gitDir string, m *pack.MultiPackIndex) error {
+	order := m.PseudoPackOrder()
+	if order == nil {
+		return fmt.Errorf("multi-pack-index has no reverse index")
+	}
+	x := &Index{
+		Objects: make([]hash.Hash, len(order)),
+		entries: make(map[hash.Hash]*Bitmap),
+		find:    m.Find,
+		bitOf:   make([]uint32, len(order)),
+	}
+	for i := range x.types {
+		x.types[i] = &Bitmap{}
+	}
+
+	// 1. 按类型划分对象
+	for bit, i := range order {
+		x.Objects[bit] = m.Hashes[i]
+		x.bitOf[i] = uint32(bit)
+		id, off := m.Entry(int(i))
+		p, err := m.Pack(id)
+		if err != nil {
+			return err
+		}
+		t, err := p.TypeAt(off)
+		if err != nil {
+			return err
+		}
+		x.types[t].Set(bit)
+	}
+
+	// 2. 选择 commit
+	commits, selected, err := selectCommits(gitDir, m)
+	if err != nil {
+		return err
+	}
+
+	// 3. 计算位图
+	var list []hash.Hash
+	for _, h := range commits {
+		if !selected[h] {
+			continue
+		}
+		s, err := x.Reachable(gitDir, []hash.Hash{h})
+		if err != nil {
+			return err
+		}
+		for missing := range s.extra {
+			return fmt.Errorf("Failed to write bitmap index. Packfile doesn't have full closure (object %s is missing)", missing)
+		}
+		x.entries[h] = s.bits
+		list = append(list, h)
+	}
+
+	// 4. 写出文件
+	be := binary.BigEndian
+	var buf bytes.Buffer
+	buf.Write(signature)
+	binary.Write(&buf, be, uint16(1))
+	binary.Write(&buf, be, uint16(flagFullDAG))
+	binary.Write(&buf, be, uint32(len(list)))
+	buf.Write(m.Checksum[:])
+	for _, b := range x.types {
+		buf.Write(encodeEWAH(b))
+	}
+	for _, h := range list {
+		i, _ := m.Find(h)
+		binary.Write(&buf, be, uint32(i))
+		buf.Write([]byte{0, 0}) // xorOffset, flags
+		buf.Write(encodeEWAH(x.entries[h]))
+	}
+	sum := sha1.Sum(buf.Bytes())
+	buf.Write(sum[:])
+
+	lock, err := lockfile.Acquire(filepath.Join(filepath.Dir(pack.MultiPackIndexPath(gitDir)), pack.MidxBitmapName(m.Checksum)))
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+	if _, err := lock.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return lock.Commit()
+}
+
+// selectCommits 从所有引用出发，按父 commit 在前的顺序列出 multi-pack-index 中可以到达的 commit，并选出要记录位图的 commit
+// 与 git 一致，指向的 commit 不在 multi-pack-index 中的引用被忽略
+func selectCommits(gitDir string, m *pack.MultiPackIndex) ([]hash.Hash, map[hash.Hash]bool, error) {
+	list, err := refs.List(gitDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var tips []hash.Hash
+	if h, err := refs.Resolve(gitDir, "HEAD"); err == nil {
+		list = append(list, refs.Ref{Name: "HEAD", Hash: h})
+	}
+	for _, r := range list {
+		h := r.Hash
+		for {
+			obj, err := object.Read(gitDir, h)
+			if err != nil {
+				return nil, nil, err
+			}
+			if obj.Type != hash.TagObject {
+				if obj.Type == hash.CommitObject {
+					tips = append(tips, h)
+				}
+				break
+			}
+			t, err := tag.ParseTag(obj.Content)
+			if err != nil {
+				return nil, nil, err
+			}
+			h = t.Object
+		}
+	}
+
+	// 深度优先遍历，所有父 commit 都已输出后再输出 commit 本身
+	selected := make(map[hash.Hash]bool)
+	seen := make(map[hash.Hash]bool)
+	var commits []hash.Hash
+	for _, tip := range tips {
+		if _, ok := m.Find(tip); !ok || seen[tip] {
+			continue
+		}
+		selected[tip] = true
+		type frame struct {
+			c    *commitgraph.Commit
+			next int // 下一个要访问的父 commit
+		}
+		c, err := commitgraph.Read(gitDir, tip)
+		if err != nil {
+			return nil, nil, err
+		}
+		seen[tip] = true
+		stack := []frame{{c, 0}}
+		for len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			if top.next < len(top.c.Parents) {
+				p := top.c.Parents[top.next]
+				top.next++
+				if seen[p] {
+					continue
+				}
+				seen[p] = true
+				pc, err := commitgraph.Read(gitDir, p)
+				if err != nil {
+					return nil, nil, err
+				}
+				stack = append(stack, frame{pc, 0})
+				continue
+			}
+			commits = append(commits, top.c.Hash)
+			if len(commits)%selectInterval == 0 {
+				selected[top.c.Hash] = true
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	return commits, selected, nil
+}
@@ -0,0 +1,371 @@
+package blame
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"time"
+
+	"geegit/beginner/day6-create-commit/blob"
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/diff"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// Line 表示 blame 结果中的一行
+type Line struct {
+	Commit    *commit.Commit // 引入这一行的 commit
+	Path      string         // 这一行在该 commit 中所属文件的路径（可能因重命名而不同）
+	OrigLine  int            // 在该 commit 的文件中的行号（从 1 开始）
+	FinalLine int            // 在起始 commit 的文件中的行号（从 1 开始）
+	Text      string         // 行内容（包含末尾的 "\n"）
+	Boundary  bool           // 该 commit 没有父 commit（历史的起点）
+
+	// 该文件在父 commit 中的版本（porcelain 格式的 "previous" 行），不存在时 PrevPath 为空
+	PrevCommit hash.Hash
+	PrevPath   string
+}
+
+// Result 表示对一个文件的 blame 结果
+type Result struct {
+	Path  string
+	Lines []Line
+}
+
+// pending 表示一行尚未确定归属，正在沿历史向上追溯
+type pending struct {
+	final int // 在最终文件中的下标（从 0 开始）
+	orig  int // 在当前嫌疑 commit 的文件中的下标（从 0 开始）
+}
+
+// suspect 表示"某个 commit 中的某个文件"，可能是若干行的来源
+type suspect struct {
+	commit *commit.Commit
+	path   string
+	blob   hash.Hash
+	lines  []string
+	rows   []pending
+}
+
+// Blame 从 start 指向的 commit 开始沿历史回溯，找出 path 文件每一行最早被引入的 commit
+// 文件在某个父 commit 中不存在时，会尝试通过内容匹配跟踪重命名
+func Blame(gitDir string, start hash.Hash, path string) (*Result, error) {
+	b := newBlamer(gitDir)
+
+	// 读取起始 commit 中的文件
+	c, err := b.readCommit(start)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := tree.FindEntry(gitDir, c.Tree, path)
+	if err != nil {
+		return nil, fmt.Errorf("no such path '%s' in %s", path, start.String()[:8])
+	}
+	if entry.IsDir() {
+		return nil, fmt.Errorf("'%s' is a directory", path)
+	}
+	lines, err := b.readLines(entry.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return b.run(&suspect{commit: c, path: path, blob: entry.Hash, lines: lines})
+}
+
+// BlameContents 对尚未提交的内容 data 做 blame（git blame 不指定版本时使用工作区中的文件）
+// 与 git 一样，data 被当作 head 之上一个哈希为零的虚拟 commit 中的 path：
+// 与 head 相同的行继续沿历史回溯，其余的行归属于作者为 "Not Committed Yet" 的虚拟 commit
+func BlameContents(gitDir string, head hash.Hash, path string, data []byte) (*Result, error) {
+	b := newBlamer(gitDir)
+	parent, err := b.readCommit(head)
+	if err != nil {
+		return nil, err
+	}
+	sig := commit.Signature{Name: "Not Committed Yet", Email: "not.committed.yet", When: time.Now()}
+	fake := &commit.Commit{
+		Tree:      parent.Tree,
+		Parents:   []hash.Hash{head},
+		Author:    sig,
+		Committer: sig,
+		Message:   fmt.Sprintf("Version of %s from %s\n", path, path),
+	}
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	b.staged = make(map[string]bool)
+	for _, e := range idx.Entries {
+		b.staged[e.Path] = true
+	}
+	blobHash := hash.ComputeHash(hash.BlobObject, data)
+	return b.run(&suspect{commit: fake, path: path, blob: blobHash, lines: diff.SplitLines(data)})
+}
+
+func newBlamer(gitDir string) *blamer {
+	return &blamer{
+		gitDir:  gitDir,
+		commits: make(map[hash.Hash]*commit.Commit),
+		blobs:   make(map[hash.Hash][]string),
+	}
+}
+
+// run 从 first 开始追溯，first 中的每一行最终归属于某个 commit
+func (b *blamer) run(first *suspect) (*Result, error) {
+	result := &Result{Path: first.path, Lines: make([]Line, len(first.lines))}
+	for i := range first.lines {
+		first.rows = append(first.rows, pending{final: i, orig: i})
+	}
+
+	// 1. 按 committer 时间从新到旧处理嫌疑对象
+	queue := &suspectQueue{index: make(map[suspectKey]*suspect)}
+	queue.add(first)
+
+	for queue.Len() > 0 {
+		s := heap.Pop(queue).(*suspect)
+		delete(queue.index, suspectKey{s.commit.Hash, s.path})
+
+		remaining, prev, err := b.passToParents(s, queue)
+		if err != nil {
+			return nil, err
+		}
+
+		// 2. 所有父 commit 都无法解释的行，归属于当前 commit
+		for _, row := range remaining {
+			result.Lines[row.final] = Line{
+				Commit:    s.commit,
+				Path:      s.path,
+				OrigLine:  row.orig + 1,
+				FinalLine: row.final + 1,
+				Text:      s.lines[row.orig],
+				Boundary:  len(s.commit.Parents) == 0,
+			}
+			if prev != nil {
+				result.Lines[row.final].PrevCommit = prev.commit
+				result.Lines[row.final].PrevPath = prev.path
+			}
+		}
+	}
+
+	return result, nil
+}
+
+type blamer struct {
+	gitDir  string
+	commits map[hash.Hash]*commit.Commit
+	blobs   map[hash.Hash][]string
+
+	// staged 是 BlameContents 时索引中的路径：与 git 一样，虚拟 commit 相对于 head 的重命名按索引判断
+	staged map[string]bool
+}
+
+func (b *blamer) readCommit(h hash.Hash) (*commit.Commit, error) {
+	if c, ok := b.commits[h]; ok {
+		return c, nil
+	}
+	c, err := commit.ReadCommit(b.gitDir, h)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %v", h.String(), err)
+	}
+	b.commits[h] = c
+	return c, nil
+}
+
+func (b *blamer) readLines(h hash.Hash) ([]string, error) {
+	if lines, ok := b.blobs[h]; ok {
+		return lines, nil
+	}
+	bl, err := blob.ReadBlob(b.gitDir, h)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %v", h.String(), err)
+	}
+	lines := diff.SplitLines(bl.Data)
+	b.blobs[h] = lines
+	return lines, nil
+}
+
+// passToParents 把能在父 commit 中找到的行交给父 commit 继续追溯
+// 返回无法交出的行（即由 s.commit 引入的行），以及第一个包含该文件的父版本
+func (b *blamer) passToParents(s *suspect, queue *suspectQueue) ([]pending, *suspectKey, error) {
+	remaining := s.rows
+	var prev *suspectKey
+
+	for _, parentHash := range s.commit.Parents {
+		if len(remaining) == 0 {
+			break
+		}
+		parent, err := b.readCommit(parentHash)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		parentPath, parentBlob, err := b.findInParent(s, parent)
+		if err != nil {
+			return nil, nil, err
+		}
+		if parentPath == "" {
+			continue
+		}
+		if prev == nil {
+			prev = &suspectKey{commit: parentHash, path: parentPath}
+		}
+
+		// 内容完全相同：所有行原样交给父 commit
+		if parentBlob == s.blob {
+			queue.add(&suspect{
+				commit: parent,
+				path:   parentPath,
+				blob:   parentBlob,
+				lines:  s.lines,
+				rows:   remaining,
+			})
+			remaining = nil
+			break
+		}
+
+		parentLines, err := b.readLines(parentBlob)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// 通过 diff 建立 "当前行号 -> 父版本行号" 的对应关系
+		mapping := make(map[int]int)
+		for _, e := range diff.Lines(parentLines, s.lines) {
+			if e.Op == diff.Equal {
+				mapping[e.NewLine] = e.OldLine
+			}
+		}
+
+		var passed, kept []pending
+		for _, row := range remaining {
+			if old, ok := mapping[row.orig]; ok {
+				passed = append(passed, pending{final: row.final, orig: old})
+			} else {
+				kept = append(kept, row)
+			}
+		}
+		if len(passed) > 0 {
+			queue.add(&suspect{
+				commit: parent,
+				path:   parentPath,
+				blob:   parentBlob,
+				lines:  parentLines,
+				rows:   passed,
+			})
+		}
+		remaining = kept
+	}
+
+	return remaining, prev, nil
+}
+
+// findInParent 在父 commit 中定位 s 对应的文件
+// 优先按相同路径查找；找不到时视为重命名，来源只考虑父 commit 中有、而 s.commit 中已经删除的文件
+// （与 git blame 一样不做复制检测），依次尝试内容完全相同的和内容最相似的文件。
+// 候选按路径排序，相似度相同时取路径最小的，结果是确定的。返回空路径表示父 commit 中没有对应文件
+func (b *blamer) findInParent(s *suspect, parent *commit.Commit) (string, hash.Hash, error) {
+	entry, err := tree.FindEntry(b.gitDir, parent.Tree, s.path)
+	if err == nil && !entry.IsDir() {
+		return s.path, entry.Hash, nil
+	}
+	if err != nil && err != tree.ErrNotFound {
+		return "", hash.Hash{}, err
+	}
+
+	// 收集父 commit 中有、s.commit 中已经删除的文件
+	var paths []string
+	deleted := make(map[string]hash.Hash)
+	err = tree.Walk(b.gitDir, parent.Tree, func(p string, e tree.TreeEntry) error {
+		if s.commit.Hash.IsZero() && b.staged != nil {
+			if b.staged[p] {
+				return nil
+			}
+		} else if _, err := tree.FindEntry(b.gitDir, s.commit.Tree, p); err == nil {
+			return nil
+		} else if err != tree.ErrNotFound {
+			return err
+		}
+		paths = append(paths, p)
+		deleted[p] = e.Hash
+		return nil
+	})
+	if err != nil {
+		return "", hash.Hash{}, err
+	}
+	sort.Strings(paths)
+
+	// 1. 精确重命名：内容完全相同
+	for _, p := range paths {
+		if deleted[p] == s.blob {
+			return p, deleted[p], nil
+		}
+	}
+
+	// 2. 相似重命名：相似度至少为 0.5，相同时保留先出现（路径较小）的
+	bestPath, bestHash, bestScore := "", hash.Hash{}, 0.0
+	for _, p := range paths {
+		candidate, err := b.readLines(deleted[p])
+		if err != nil {
+			return "", hash.Hash{}, err
+		}
+		if score := similarity(candidate, s.lines); score >= 0.5 && score > bestScore {
+			bestPath, bestHash, bestScore = p, deleted[p], score
+		}
+	}
+	return bestPath, bestHash, nil
+}
+
+// similarity 计算两个文件的行相似度（0 ~ 1）
+func similarity(a, b []string) float64 {
+	if len(a)+len(b) == 0 {
+		return 1
+	}
+	same := 0
+	for _, e := range diff.Lines(a, b) {
+		if e.Op == diff.Equal {
+			same++
+		}
+	}
+	return float64(2*same) / float64(len(a)+len(b))
+}
+
+// suspectKey 用于合并同一个 commit 中同一个文件的嫌疑对象
+type suspectKey struct {
+	commit hash.Hash
+	path   string
+}
+
+// suspectQueue 是按 committer 时间排序的优先队列（最新的先出队）
+type suspectQueue struct {
+	items []*suspect
+	index map[suspectKey]*suspect
+}
+
+// add 加入一个嫌疑对象；如果已经存在同一 commit 同一路径的对象，则合并行
+func (q *suspectQueue) add(s *suspect) {
+	key := suspectKey{s.commit.Hash, s.path}
+	if existing, ok := q.index[key]; ok {
+		existing.rows = append(existing.rows, s.rows...)
+		return
+	}
+	q.index[key] = s
+	heap.Push(q, s)
+}
+
+func (q *suspectQueue) Len() int { return len(q.items) }
+
+func (q *suspectQueue) Less(i, j int) bool {
+	return q.items[i].commit.Committer.When.After(q.items[j].commit.Committer.When)
+}
+
+func (q *suspectQueue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+
+func (q *suspectQueue) Push(x any) { q.items = append(q.items, x.(*suspect)) }
+
+func (q *suspectQueue) Pop() any {
+	old := q.items
+	n := len(old)
+	item := old[n-1]
+	q.items = old[:n-1]
+	return item
+}
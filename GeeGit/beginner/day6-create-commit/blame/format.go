@@ -0,0 +1,139 @@
+package blame
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+)
+
+// WriteHuman 以 `git blame` 的默认格式输出结果
+// 格式: <hash> [<path>] (<author> <date> <lineno>) <line>
+func WriteHuman(w io.Writer, r *Result) error {
+	bw := bufio.NewWriter(w)
+
+	// 1. 计算各列宽度，使输出对齐
+	authorWidth, pathWidth := 0, 0
+	showPath := false
+	for _, l := range r.Lines {
+		if n := len([]rune(l.Commit.Author.Name)); n > authorWidth {
+			authorWidth = n
+		}
+		if len(l.Path) > pathWidth {
+			pathWidth = len(l.Path)
+		}
+		if l.Path != r.Path {
+			showPath = true
+		}
+	}
+	lineWidth := len(strconv.Itoa(len(r.Lines)))
+
+	// 2. 逐行输出
+	for _, l := range r.Lines {
+		// 边界 commit 以 "^" 开头，总宽度保持一致
+		id := l.Commit.Hash.String()[:8]
+		if l.Boundary {
+			id = "^" + id[:7]
+		}
+		bw.WriteString(id)
+
+		if showPath {
+			fmt.Fprintf(bw, " %-*s", pathWidth, l.Path)
+		}
+
+		author := l.Commit.Author
+		padding := strings.Repeat(" ", authorWidth-len([]rune(author.Name)))
+		fmt.Fprintf(bw, " (%s%s %s %*d) %s",
+			author.Name, padding,
+			author.When.Format("2006-01-02 15:04:05 -0700"),
+			lineWidth, l.FinalLine,
+			l.Text)
+		if !strings.HasSuffix(l.Text, "\n") {
+			bw.WriteString("\n")
+		}
+	}
+
+	return bw.Flush()
+}
+
+// WritePorcelain 以 `git blame --porcelain` 的格式输出结果
+// 连续来自同一 commit 的行组成一组，每个 commit 的详细信息只在第一次出现时输出
+func WritePorcelain(w io.Writer, r *Result) error {
+	bw := bufio.NewWriter(w)
+
+	// 同一个 commit 被归属到多个路径时，每组都要输出 filename
+	paths := make(map[hash.Hash]map[string]bool)
+	for _, l := range r.Lines {
+		if paths[l.Commit.Hash] == nil {
+			paths[l.Commit.Hash] = make(map[string]bool)
+		}
+		paths[l.Commit.Hash][l.Path] = true
+	}
+
+	shown := make(map[hash.Hash]bool)
+	for i := 0; i < len(r.Lines); {
+		// 1. 找出当前组的范围：同一 commit、同一路径、原始行号连续
+		j := i + 1
+		for j < len(r.Lines) &&
+			r.Lines[j].Commit.Hash == r.Lines[i].Commit.Hash &&
+			r.Lines[j].Path == r.Lines[i].Path &&
+			r.Lines[j].OrigLine == r.Lines[j-1].OrigLine+1 {
+			j++
+		}
+
+		// 2. 输出组头和（首次出现时的）commit 详情
+		l := r.Lines[i]
+		fmt.Fprintf(bw, "%s %d %d %d\n", l.Commit.Hash.String(), l.OrigLine, l.FinalLine, j-i)
+		if !shown[l.Commit.Hash] {
+			shown[l.Commit.Hash] = true
+			writeDetails(bw, l)
+			fmt.Fprintf(bw, "filename %s\n", l.Path)
+		} else if len(paths[l.Commit.Hash]) > 1 {
+			fmt.Fprintf(bw, "filename %s\n", l.Path)
+		}
+		writeContent(bw, l.Text)
+
+		// 3. 组内其余行只输出行号
+		for k := i + 1; k < j; k++ {
+			l := r.Lines[k]
+			fmt.Fprintf(bw, "%s %d %d\n", l.Commit.Hash.String(), l.OrigLine, l.FinalLine)
+			writeContent(bw, l.Text)
+		}
+		i = j
+	}
+
+	return bw.Flush()
+}
+
+func writeDetails(w *bufio.Writer, l Line) {
+	writeSignature(w, "author", l.Commit.Author)
+	writeSignature(w, "committer", l.Commit.Committer)
+
+	summary, _, _ := strings.Cut(l.Commit.Message, "\n")
+	fmt.Fprintf(w, "summary %s\n", summary)
+	if l.Boundary {
+		w.WriteString("boundary\n")
+	}
+	if l.PrevPath != "" {
+		fmt.Fprintf(w, "previous %s %s\n", l.PrevCommit.String(), l.PrevPath)
+	}
+}
+
+func writeSignature(w *bufio.Writer, role string, sig commit.Signature) {
+	fmt.Fprintf(w, "%s %s\n", role, sig.Name)
+	fmt.Fprintf(w, "%s-mail <%s>\n", role, sig.Email)
+	fmt.Fprintf(w, "%s-time %d\n", role, sig.When.Unix())
+	fmt.Fprintf(w, "%s-tz %s\n", role, sig.When.Format("-0700"))
+}
+
+func writeContent(w *bufio.Writer, text string) {
+	w.WriteString("\t")
+	w.WriteString(text)
+	if !strings.HasSuffix(text, "\n") {
+		w.WriteString("\n")
+	}
+}
@@ -0,0 +1,237 @@
+package branch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/remote"
+	"geegit/beginner/day6-create-commit/revision"
+)
+
+// Upstream 返回分支的上游引用，例如 refs/remotes/origin/main
+// 上游由 branch.<name>.remote 和 branch.<name>.merge 决定；remote 为 "." 时上游是本地分支，
+// 否则按该远程的 fetch refspec 把 merge 映射为远程跟踪引用（没有匹配的 refspec 时使用默认的布局）
+// 没有配置上游时返回空字符串
+func Upstream(cfg *config.Config, name string) string {
+	remoteName, _ := cfg.Get("branch." + name + ".remote")
+	merge, _ := cfg.Get("branch." + name + ".merge")
+	if remoteName == "" || merge == "" {
+		return ""
+	}
+	if remoteName == "." {
+		return merge
+	}
+	if r, err := remote.Get(cfg, remoteName); err == nil {
+		if tracking := r.TrackingRef(merge); tracking != "" {
+			return tracking
+		}
+	}
+	return "refs/remotes/" + remoteName + "/" + strings.TrimPrefix(merge, "refs/heads/")
+}
+
+// SetUpstream 把分支 name 的上游设置为 upstream（完整引用名）
+// refs/remotes/<remote>/<branch> 记录为 remote=<remote>、merge=refs/heads/<branch>，本地分支记录为 remote=.
+func SetUpstream(gitDir, name, upstream string) error {
+	remote, merge := ".", upstream
+	if rest, ok := strings.CutPrefix(upstream, "refs/remotes/"); ok {
+		r, b, found := strings.Cut(rest, "/")
+		if !found {
+			return fmt.Errorf("cannot set up tracking information; '%s' is not a branch", upstream)
+		}
+		remote, merge = r, "refs/heads/"+b
+	} else if !strings.HasPrefix(upstream, "refs/heads/") {
+		return fmt.Errorf("cannot set up tracking information; starting point '%s' is not a branch",
+			refs.ShortName(upstream))
+	}
+
+	file := gitdir.Path(gitDir, "config")
+	if err := config.SetValue(file, "branch."+name+".remote", remote); err != nil {
+		return err
+	}
+	return config.SetValue(file, "branch."+name+".merge", merge)
+}
+
+// UnsetUpstream 删除分支的上游配置
+func UnsetUpstream(gitDir, name string) error {
+	file := gitdir.Path(gitDir, "config")
+	if err := config.Unset(file, "branch."+name+".remote"); err != nil {
+		return err
+	}
+	return config.Unset(file, "branch."+name+".merge")
+}
+
+// Tracking 是分支与其上游的比较结果
+type Tracking struct {
+	Upstream string // 上游的完整引用名
+	Gone     bool   // 配置了上游，但上游引用不存在
+	Ahead    int    // 本地独有的 commit 数
+	Behind   int    // 上游独有的 commit 数
+}
+
+// ShortUpstream 返回上游的简短名称，例如 origin/main
+func (t *Tracking) ShortUpstream() string {
+	return refs.ShortName(t.Upstream)
+}
+
+// CompareWithUpstream 计算分支与上游的领先/落后情况，没有配置上游时返回 nil
+func CompareWithUpstream(gitDir string, cfg *config.Config, name string) (*Tracking, error) {
+	upstream := Upstream(cfg, name)
+	if upstream == "" {
+		return nil, nil
+	}
+	t := &Tracking{Upstream: upstream}
+
+	up, err := refs.Read(gitDir, upstream)
+	if errors.Is(err, refs.ErrNotFound) {
+		t.Gone = true
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	local, err := refs.Read(gitDir, "refs/heads/"+name)
+	if err != nil {
+		return nil, err
+	}
+	if local.Hash != up.Hash {
+		t.Ahead, t.Behind, err = revision.AheadBehind(gitDir, local.Hash, up.Hash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Message 返回 `git status` 和 `git switch` 中显示的跟踪信息（多行，不含末尾换行）
+func (t *Tracking) Message() string {
+	up := t.ShortUpstream()
+	switch {
+	case t.Gone:
+		return fmt.Sprintf("Your branch is based on '%s', but the upstream is gone.\n"+
+			"  (use \"git branch --unset-upstream\" to fixup)", up)
+	case t.Ahead == 0 && t.Behind == 0:
+		return fmt.Sprintf("Your branch is up to date with '%s'.", up)
+	case t.Behind == 0:
+		return fmt.Sprintf("Your branch is ahead of '%s' by %s.\n"+
+			"  (use \"git push\" to publish your local commits)", up, commits(t.Ahead))
+	case t.Ahead == 0:
+		return fmt.Sprintf("Your branch is behind '%s' by %s, and can be fast-forwarded.\n"+
+			"  (use \"git pull\" to update your local branch)", up, commits(t.Behind))
+	default:
+		return fmt.Sprintf("Your branch and '%s' have diverged,\n"+
+			"and have %d and %d different commits each, respectively.\n"+
+			"  (use \"git pull\" to merge the remote branch into yours)", up, t.Ahead, t.Behind)
+	}
+}
+
+// Summary 返回 `git branch -vv` 和 `git status -sb` 中使用的简短说明，例如 "ahead 1, behind 2"
+// 与上游相同时返回空字符串
+func (t *Tracking) Summary() string {
+	switch {
+	case t.Gone:
+		return "gone"
+	case t.Ahead > 0 && t.Behind > 0:
+		return fmt.Sprintf("ahead %d, behind %d", t.Ahead, t.Behind)
+	case t.Ahead > 0:
+		return fmt.Sprintf("ahead %d", t.Ahead)
+	case t.Behind > 0:
+		return fmt.Sprintf("behind %d", t.Behind)
+	}
+	return ""
+}
+
+func commits(n int) string {
+	if n == 1 {
+		return "1 commit"
+	}
+	return fmt.Sprintf("%d commits", n)
+}
+
+// Current 返回 HEAD 指向的分支短名称；分离 HEAD 时返回空字符串
+func Current(gitDir string) (string, error) {
+	target, err := refs.ResolveName(gitDir, "HEAD")
+	if err != nil {
+		return "", err
+	}
+	name, ok := strings.CutPrefix(target, "refs/heads/")
+	if !ok {
+		return "", nil
+	}
+	return name, nil
+}
+
+// Exists 判断本地分支是否存在
+func Exists(gitDir, name string) bool {
+	_, err := refs.Read(gitDir, "refs/heads/"+name)
+	return err == nil
+}
+
+// Head 返回分支指向的 commit
+func Head(gitDir, name string) (hash.Hash, error) {
+	ref, err := refs.Read(gitDir, "refs/heads/"+name)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	return ref.Hash, nil
+}
+
+// Detached 描述分离的 HEAD，用于 `git status` 和 `git branch` 的 "HEAD detached at/from <name>"
+// 从 HEAD 的 reflog 中找到最后一次 checkout 的目标：HEAD 仍指向该目标时 at 为 true，
+// 之后又有新的 commit 时 at 为 false；找不到时返回 HEAD 的短哈希
+func Detached(gitDir string, head hash.Hash) (name string, at bool) {
+	entries, _ := refs.ReadLog(gitDir, "HEAD")
+	for i := len(entries) - 1; i >= 0; i-- {
+		msg, ok := strings.CutPrefix(entries[i].Message, "checkout: moving from ")
+		if !ok {
+			continue
+		}
+		_, target, ok := strings.Cut(msg, " to ")
+		if !ok {
+			break
+		}
+		if h, err := hash.ParseHash(target); err == nil {
+			target = h.String()[:7]
+		}
+		return target, entries[i].New == head
+	}
+	return head.String()[:7], true
+}
+
+// Previous 返回 `@{-n}`（即 `git switch -`）表示的分支：HEAD reflog 中倒数第 n 次 checkout 之前所在的分支或 commit
+func Previous(gitDir string, n int) (string, error) {
+	entries, err := refs.ReadLog(gitDir, "HEAD")
+	if err != nil {
+		return "", err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		msg, ok := strings.CutPrefix(entries[i].Message, "checkout: moving from ")
+		if !ok {
+			continue
+		}
+		if n--; n == 0 {
+			from, _, _ := strings.Cut(msg, " to ")
+			return from, nil
+		}
+	}
+	return "", fmt.Errorf("no previous branch")
+}
+
+// stateFiles 是进行中的合并、cherry-pick 和 revert 在 gitDir 中留下的文件
+var stateFiles = []string{"MERGE_HEAD", "MERGE_MSG", "MERGE_MODE", "MERGE_RR", "SQUASH_MSG", "CHERRY_PICK_HEAD", "REVERT_HEAD"}
+
+// RemoveState 删除进行中的合并、cherry-pick 和 revert 的状态（git reset 之后这些操作不再继续）
+func RemoveState(gitDir string) error {
+	for _, name := range stateFiles {
+		if err := os.Remove(filepath.Join(gitDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package bundle
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/pack"
+	"geegit/beginner/day6-create-commit/refs"
+)
+
+// bundle 文件把引用和 packfile 打包成一个文件，用于离线传输（git bundle）。格式与 git 一致：
+//
+//	# v2 git bundle              签名；v3 之后可以有 "@<key>=<value>" 形式的能力行
+//	-<hash> <说明>               前提（prerequisite）：接收方必须已经有的 commit，packfile 中的对象可能引用它们
+//	<hash> <引用名>              bundle 中的引用
+//	                             空行，然后是 packfile
+//
+// 有前提的 bundle 中是瘦 packfile（thin pack），delta 的基础对象可能在接收方的仓库中
+
+const (
+	signatureV2 = "# v2 git bundle\n"
+	signatureV3 = "# v3 git bundle\n"
+)
+
+// Prerequisite 是 bundle 的一个前提 commit
+type Prerequisite struct {
+	Hash    hash.Hash
+	Comment string // 通常是 commit 的标题
+}
+
+// Bundle 是读入内存的 bundle 文件
+type Bundle struct {
+	Version       int    // 2 或 3
+	Filter        string // v3 的 @filter 能力：packfile 按这个过滤器省略了对象
+	Prerequisites []Prerequisite
+	Refs          []refs.Ref // 按文件中的顺序，可能包括 HEAD
+	Pack          []byte     // packfile 数据
+}
+
+// IsBundle 判断 path 是否是 bundle 文件（以 v2 或 v3 的签名开头）
+func IsBundle(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	line, _ := bufio.NewReader(f).ReadString('\n')
+	return line == signatureV2 || line == signatureV3
+}
+
+// Read 读取并解析 bundle 文件，错误信息与 git 一致
+func Read(path string) (*Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open '%s'", path)
+	}
+	b := &Bundle{}
+	switch {
+	case bytes.HasPrefix(data, []byte(signatureV2)):
+		b.Version = 2
+	case bytes.HasPrefix(data, []byte(signatureV3)):
+		b.Version = 3
+	default:
+		return nil, fmt.Errorf("'%s' does not look like a v2 or v3 bundle file", path)
+	}
+	pos := len(signatureV2)
+
+	for {
+		end := bytes.IndexByte(data[pos:], '\n')
+		if end < 0 {
+			return nil, fmt.Errorf("'%s' does not look like a v2 or v3 bundle file", path)
+		}
+		line := string(data[pos : pos+end])
+		pos += end + 1
+		if line == "" {
+			break
+		}
+
+		// 1. v3 的能力
+		if b.Version == 3 && strings.HasPrefix(line, "@") {
+			key, value, _ := strings.Cut(line[1:], "=")
+			switch key {
+			case "object-format":
+				if value != "sha1" {
+					return nil, fmt.Errorf("unrecognized bundle hash algorithm: %s", value)
+				}
+			case "filter":
+				b.Filter = value
+			default:
+				return nil, fmt.Errorf("unknown capability '%s'", line[1:])
+			}
+			continue
+		}
+
+		// 2. 前提和引用
+		prereq := strings.HasPrefix(line, "-")
+		hex, rest, _ := strings.Cut(strings.TrimPrefix(line, "-"), " ")
+		h, err := hash.ParseHash(hex)
+		if err != nil || !prereq && rest == "" {
+			return nil, fmt.Errorf("unrecognized header: %s", line)
+		}
+		if prereq {
+			b.Prerequisites = append(b.Prerequisites, Prerequisite{Hash: h, Comment: rest})
+		} else {
+			b.Refs = append(b.Refs, refs.Ref{Name: rest, Hash: h})
+		}
+	}
+	b.Pack = data[pos:]
+	return b, nil
+}
+
+// MissingError 表示仓库中缺少 bundle 的前提 commit
+type MissingError struct {
+	Missing []Prerequisite
+}
+
+func (e *MissingError) Error() string {
+	var b strings.Builder
+	b.WriteString("Repository lacks these prerequisite commits:")
+	for _, p := range e.Missing {
+		fmt.Fprintf(&b, "\nerror: %s ", p.Hash)
+	}
+	return b.String()
+}
+
+// Verify 检查仓库 gitDir 中是否有 bundle 的全部前提 commit，缺少时返回 *MissingError
+func (b *Bundle) Verify(gitDir string) error {
+	var missing []Prerequisite
+	for _, p := range b.Prerequisites {
+		if !object.Exists(gitDir, p.Hash) {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) > 0 {
+		return &MissingError{Missing: missing}
+	}
+	return nil
+}
+
+// Unbundle 检查前提之后，把 bundle 中的对象写入仓库 gitDir 的一个新 packfile（git bundle unbundle）
+// 瘦 packfile 中 delta 的基础对象从仓库中读取，写入的 packfile 不再依赖它们；不更新任何引用
+func (b *Bundle) Unbundle(gitDir string) error {
+	if err := b.Verify(gitDir); err != nil {
+		return err
+	}
+	entries, err := pack.Parse(b.Pack, func(h hash.Hash) (hash.ObjectType, []byte, error) {
+		obj, err := object.Read(gitDir, h)
+		if err != nil {
+			return 0, nil, err
+		}
+		return obj.Type, obj.Content, nil
+	})
+	if err != nil {
+		return fmt.Errorf("bundle pack: %v", err)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	_, err = pack.Write(gitDir, entries, false)
+	return err
+}
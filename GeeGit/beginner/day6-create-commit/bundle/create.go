@@ -0,0 +1,257 @@
+package bundle
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/pack"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/revision"
+	"geegit/beginner/day6-create-commit/tag"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// ErrEmpty 表示没有任何引用可以写入 bundle
+var ErrEmpty = errors.New("Refusing to create empty bundle.")
+
+// CreateOptions 控制 Create，起点和排除与 git bundle create 的 rev-list 参数对应
+type CreateOptions struct {
+	Version int         // 2 或 3，0 表示 2
+	Refs    []refs.Ref  // 写入 bundle 的引用（命令行上的引用名和 --all），同时也是起点
+	Include []hash.Hash // 不是引用名的其他起点，它们的历史会被打包，但不写入引用
+	Exclude []hash.Hash // 排除从这些 commit 可以到达的历史（^<rev>，或 <a>..<b> 中的 a）
+}
+
+// Create 把从起点可以到达、排除之外的对象写成 bundle
+//  1. 列出要打包的 commit，指向的 commit 被排除的引用不写入；一个引用都没有时返回 ErrEmpty
+//  2. 这些 commit 的父 commit 中被排除的是前提，接收方必须已经有它们
+//  3. packfile 包括这些 commit、引用途经的标签，以及 commit 的 tree 中前提的 tree 里没有的对象
+func Create(w io.Writer, gitDir string, opts CreateOptions) error {
+	if opts.Version == 0 {
+		opts.Version = 2
+	}
+	if opts.Version != 2 && opts.Version != 3 {
+		return fmt.Errorf("unsupported bundle version %d", opts.Version)
+	}
+	c := &creator{gitDir: gitDir, seen: make(map[hash.Hash]bool)}
+
+	// 1. 起点和 commit
+	type tip struct {
+		ref     *refs.Ref
+		target  hash.Hash
+		objType hash.ObjectType
+		tags    []hash.Hash
+	}
+	var tips []tip
+	var starts []hash.Hash
+	names := make(map[string]bool)
+	for i := range opts.Refs {
+		if names[opts.Refs[i].Name] {
+			continue
+		}
+		names[opts.Refs[i].Name] = true
+		tips = append(tips, tip{ref: &opts.Refs[i]})
+	}
+	for _, h := range opts.Include {
+		tips = append(tips, tip{target: h})
+	}
+	for i := range tips {
+		t := &tips[i]
+		h := t.target
+		if t.ref != nil {
+			h = t.ref.Hash
+		}
+		target, objType, tags, err := c.peel(h)
+		if err != nil {
+			return err
+		}
+		t.target, t.objType, t.tags = target, objType, tags
+		if objType == hash.CommitObject {
+			starts = append(starts, target)
+		}
+	}
+	commits, err := revision.List(gitDir, revision.ListOptions{Include: starts, Exclude: opts.Exclude})
+	if err != nil {
+		return err
+	}
+	listed := make(map[hash.Hash]bool, len(commits))
+	for _, h := range commits {
+		listed[h] = true
+	}
+	var kept []refs.Ref
+	for _, t := range tips {
+		if t.objType == hash.CommitObject && !listed[t.target] {
+			continue
+		}
+		if t.ref != nil {
+			kept = append(kept, *t.ref)
+		}
+	}
+	if len(kept) == 0 {
+		return ErrEmpty
+	}
+
+	// 2. 前提
+	var prereqs []Prerequisite
+	var roots []hash.Hash
+	isPrereq := make(map[hash.Hash]bool)
+	for _, h := range commits {
+		obj, err := c.add(h)
+		if err != nil {
+			return err
+		}
+		cm, err := commit.ParseCommit(obj.Content)
+		if err != nil {
+			return fmt.Errorf("commit %s: %v", h, err)
+		}
+		roots = append(roots, cm.Tree)
+		for _, p := range cm.Parents {
+			if listed[p] || isPrereq[p] {
+				continue
+			}
+			pc, err := commit.ReadCommit(gitDir, p)
+			if err != nil {
+				return err
+			}
+			isPrereq[p] = true
+			subject, _, _ := strings.Cut(pc.Message, "\n")
+			prereqs = append(prereqs, Prerequisite{Hash: p, Comment: subject})
+			if err := c.exclude(pc.Tree); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 3. 标签、tree 和 blob
+	for _, t := range tips {
+		if t.objType == hash.CommitObject && !listed[t.target] {
+			continue
+		}
+		for _, h := range t.tags {
+			if _, err := c.add(h); err != nil {
+				return err
+			}
+		}
+		if t.objType != hash.CommitObject {
+			roots = append(roots, t.target)
+		}
+	}
+	for _, h := range roots {
+		if err := c.addTree(h); err != nil {
+			return err
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	if opts.Version == 3 {
+		bw.WriteString(signatureV3)
+		bw.WriteString("@object-format=sha1\n")
+	} else {
+		bw.WriteString(signatureV2)
+	}
+	for _, p := range prereqs {
+		fmt.Fprintf(bw, "-%s %s\n", p.Hash, p.Comment)
+	}
+	for _, r := range kept {
+		fmt.Fprintf(bw, "%s %s\n", r.Hash, r.Name)
+	}
+	bw.WriteString("\n")
+	if _, err := pack.Encode(bw, c.entries); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// creator 收集要写入 packfile 的对象
+type creator struct {
+	gitDir  string
+	seen    map[hash.Hash]bool // 已经选中或者接收方已经有的对象
+	entries []pack.Entry
+}
+
+// add 选中对象 h，返回它的内容
+func (c *creator) add(h hash.Hash) (*object.Object, error) {
+	obj, err := object.Read(c.gitDir, h)
+	if err != nil {
+		return nil, err
+	}
+	if !c.seen[h] {
+		c.seen[h] = true
+		c.entries = append(c.entries, pack.Entry{Hash: h, Type: obj.Type, Content: obj.Content})
+	}
+	return obj, nil
+}
+
+// peel 剥离标签，返回最终指向的对象、它的类型和途经的标签对象
+func (c *creator) peel(h hash.Hash) (hash.Hash, hash.ObjectType, []hash.Hash, error) {
+	var tags []hash.Hash
+	for {
+		obj, err := object.Read(c.gitDir, h)
+		if err != nil {
+			return hash.Hash{}, 0, nil, err
+		}
+		if obj.Type != hash.TagObject {
+			return h, obj.Type, tags, nil
+		}
+		t, err := tag.ParseTag(obj.Content)
+		if err != nil {
+			return hash.Hash{}, 0, nil, fmt.Errorf("tag %s: %v", h, err)
+		}
+		tags = append(tags, h)
+		h = t.Object
+	}
+}
+
+// addTree 选中 tree（或 blob）h 及其中还没有选中的对象，子模块的 commit 不在这个仓库中
+func (c *creator) addTree(h hash.Hash) error {
+	if c.seen[h] {
+		return nil
+	}
+	obj, err := c.add(h)
+	if err != nil || obj.Type != hash.TreeObject {
+		return err
+	}
+	entries, err := tree.ParseEntries(obj.Content)
+	if err != nil {
+		return fmt.Errorf("tree %s: %v", h, err)
+	}
+	for _, e := range entries {
+		if e.IsSubmodule() {
+			continue
+		}
+		if err := c.addTree(e.Hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exclude 把前提的 tree h 中的对象标记为接收方已经有的对象
+func (c *creator) exclude(h hash.Hash) error {
+	if c.seen[h] {
+		return nil
+	}
+	c.seen[h] = true
+	t, err := tree.ReadTree(c.gitDir, h)
+	if err != nil {
+		return err
+	}
+	for _, e := range t.Entries {
+		switch {
+		case e.IsSubmodule():
+		case e.IsDir():
+			if err := c.exclude(e.Hash); err != nil {
+				return err
+			}
+		default:
+			c.seen[e.Hash] = true
+		}
+	}
+	return nil
+}
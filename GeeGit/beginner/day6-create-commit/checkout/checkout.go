@@ -0,0 +1,346 @@
+package checkout
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/blob"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/sparse"
+	"geegit/beginner/day6-create-commit/status"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// ConflictError 表示切换或合并会覆盖本地修改或未跟踪的文件
+type ConflictError struct {
+	Modified  []string // 已修改（暂存或未暂存）的被跟踪文件
+	Untracked []string // 会被覆盖的未跟踪文件
+	Merge     bool     // 由合并（Merge）产生，影响错误信息的措辞
+}
+
+func (e *ConflictError) Error() string {
+	op, action := "checkout", "switch branches"
+	if e.Merge {
+		op, action = "merge", "merge"
+	}
+	var b strings.Builder
+	if len(e.Modified) > 0 {
+		fmt.Fprintf(&b, "Your local changes to the following files would be overwritten by %s:\n", op)
+		for _, p := range e.Modified {
+			fmt.Fprintf(&b, "\t%s\n", p)
+		}
+		fmt.Fprintf(&b, "Please commit your changes or stash them before you %s.", action)
+	}
+	if len(e.Untracked) > 0 {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "The following untracked working tree files would be overwritten by %s:\n", op)
+		for _, p := range e.Untracked {
+			fmt.Fprintf(&b, "\t%s\n", p)
+		}
+		fmt.Fprintf(&b, "Please move or remove them before you %s.", action)
+	}
+	return b.String()
+}
+
+// change 表示一个路径从旧 tree 到新 tree 的变化；entry 为 nil 表示不存在
+type change struct {
+	path     string
+	old, new *tree.TreeEntry
+}
+
+// Options 控制切换的方式
+type Options struct {
+	// Force 为 true 时丢弃本地修改（git switch --discard-changes / checkout -f）
+	Force bool
+}
+
+// Trees 把工作区和索引从 from tree 切换到 to tree（类似 `git read-tree -m -u from to`）
+//  1. 两个 tree 中相同的路径保持不动，因此未提交的修改可以带到新分支
+//  2. 发生变化的路径如果有本地修改或会覆盖未跟踪文件，整体放弃并返回 *ConflictError
+//  3. 否则删除旧文件、写出新文件，并更新索引
+//
+// from 为零哈希表示从空 tree 切换（例如尚无提交的分支）；还没有索引时（例如 clone --no-checkout 之后）
+// 工作区中什么都没有检出，同样从空 tree 切换
+//
+// 稀疏检出时，范围之外的文件不写到工作区，索引中标记为 skip-worktree
+func Trees(gitDir, workDir string, from, to hash.Hash, opts Options) error {
+	if _, err := os.Stat(index.Path(gitDir)); os.IsNotExist(err) {
+		from = hash.Hash{}
+	}
+	patterns, err := sparse.Load(gitDir)
+	if err != nil {
+		return err
+	}
+	outside := func(p string) bool { return patterns != nil && !patterns.Match(p) }
+	oldFiles, err := flatten(gitDir, from)
+	if err != nil {
+		return err
+	}
+	newFiles, err := flatten(gitDir, to)
+	if err != nil {
+		return err
+	}
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		return err
+	}
+
+	// 1. 找出发生变化的路径
+	var changes []change
+	for p, oe := range oldFiles {
+		ne, ok := newFiles[p]
+		if !ok {
+			changes = append(changes, change{path: p, old: oe})
+		} else if oe.Mode != ne.Mode || oe.Hash != ne.Hash {
+			changes = append(changes, change{path: p, old: oe, new: ne})
+		}
+	}
+	for p, ne := range newFiles {
+		if _, ok := oldFiles[p]; !ok {
+			changes = append(changes, change{path: p, new: ne})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].path < changes[j].path })
+
+	// 2. 检查本地修改
+	if !opts.Force {
+		if err := checkConflicts(workDir, idx, changes); err != nil {
+			return err
+		}
+	}
+
+	// 3. 部分克隆中缺失的 blob 先一次性从 promisor 远程获取
+	var blobs []hash.Hash
+	for _, c := range changes {
+		if c.new != nil && !c.new.IsSubmodule() && !outside(c.path) {
+			blobs = append(blobs, c.new.Hash)
+		}
+	}
+	if err := object.Prefetch(gitDir, blobs); err != nil {
+		return err
+	}
+
+	// 4. 先删除，再写入，以处理文件和目录互换的情况
+	for _, c := range changes {
+		if c.new != nil && !outside(c.path) {
+			continue
+		}
+		if e, ok := idx.Find(c.path); !ok || !e.SkipWorktree {
+			if err := RemoveFile(workDir, c.path); err != nil {
+				return err
+			}
+		}
+		idx.Remove(c.path)
+	}
+	for _, c := range changes {
+		if c.new == nil {
+			continue
+		}
+		idx.RemoveDir(c.path)
+		if outside(c.path) {
+			idx.Add(skipEntry(c.path, c.new))
+			continue
+		}
+		e, err := WriteFile(gitDir, workDir, c.path, c.new)
+		if err != nil {
+			return err
+		}
+		idx.Add(e)
+	}
+	if opts.Force {
+		// 丢弃其余路径上的本地修改和冲突
+		for p, ne := range newFiles {
+			if e, ok := idx.Find(p); ok && e.Stage == 0 && e.Mode == index.ParseMode(ne.Mode) && e.Hash == ne.Hash &&
+				fileMatches(workDir, e) {
+				continue
+			}
+			idx.Remove(p)
+			if outside(p) {
+				idx.Add(skipEntry(p, ne))
+				continue
+			}
+			e, err := WriteFile(gitDir, workDir, p, ne)
+			if err != nil {
+				return err
+			}
+			idx.Add(e)
+		}
+	}
+
+	return idx.Write(gitDir)
+}
+
+// checkConflicts 检查发生变化的路径上是否有会丢失的本地修改
+func checkConflicts(workDir string, idx *index.Index, changes []change) error {
+	conflict := &ConflictError{}
+	for _, c := range changes {
+		e, tracked := idx.Find(c.path)
+		full := filepath.Join(workDir, filepath.FromSlash(c.path))
+		info, statErr := os.Lstat(full)
+
+		// 1. 旧 tree 中没有、索引中也没有：工作区中的同名文件是未跟踪文件
+		if !tracked {
+			if c.old == nil && statErr == nil && !info.IsDir() {
+				if !matchesEntry(full, info, c.new) {
+					conflict.Untracked = append(conflict.Untracked, c.path)
+				}
+			} else if c.old != nil && statErr == nil {
+				// 已经暂存删除的文件又出现在工作区中
+				if c.new == nil || !matchesEntry(full, info, c.new) {
+					conflict.Modified = append(conflict.Modified, c.path)
+				}
+			}
+			continue
+		}
+
+		// 2. 索引已经等于新版本：不需要改动（工作区的修改会被保留）
+		if c.new != nil && e.Stage == 0 && e.Mode == index.ParseMode(c.new.Mode) && e.Hash == c.new.Hash {
+			continue
+		}
+
+		// 3. 索引与旧版本不同，或工作区与索引不同，都是本地修改
+		staged := e.Stage != 0 || c.old == nil || e.Mode != index.ParseMode(c.old.Mode) || e.Hash != c.old.Hash
+		if staged || !fileMatches(workDir, e) {
+			conflict.Modified = append(conflict.Modified, c.path)
+		}
+	}
+
+	if len(conflict.Modified) > 0 || len(conflict.Untracked) > 0 {
+		return conflict
+	}
+	return nil
+}
+
+// fileMatches 判断工作区文件是否与索引条目一致
+func fileMatches(workDir string, e *index.Entry) bool {
+	if e.SkipWorktree {
+		return true
+	}
+	full := filepath.Join(workDir, filepath.FromSlash(e.Path))
+	info, err := os.Lstat(full)
+	if err != nil {
+		return false
+	}
+	if e.StatMatches(info) {
+		return true
+	}
+	// 内容相同但可执行位不同也算修改
+	h, err := status.HashWorktreeFile(full, info)
+	return err == nil && h == e.Hash && index.NewEntry(e.Path, info, h).Mode == e.Mode
+}
+
+// matchesEntry 判断工作区文件的内容是否已经等于 tree 条目
+func matchesEntry(full string, info os.FileInfo, entry *tree.TreeEntry) bool {
+	if entry == nil || info.IsDir() {
+		return false
+	}
+	h, err := status.HashWorktreeFile(full, info)
+	return err == nil && h == entry.Hash
+}
+
+// WriteFile 把 tree 条目写到工作区，返回对应的索引条目
+// 普通文件按模式设置可执行位，符号链接创建为链接，gitlink（子模块）只创建空目录
+func WriteFile(gitDir, workDir, p string, entry *tree.TreeEntry) (index.Entry, error) {
+	full := filepath.Join(workDir, filepath.FromSlash(p))
+	mode := index.ParseMode(entry.Mode)
+
+	// 1. 目标位置可能是旧的文件、链接或空目录
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return index.Entry{}, err
+	}
+	if info, err := os.Lstat(full); err == nil && !(info.IsDir() && mode == 0160000) {
+		if err := os.Remove(full); err != nil {
+			return index.Entry{}, fmt.Errorf("unable to remove '%s': %v", p, err)
+		}
+	}
+
+	// 2. 写出内容
+	switch mode {
+	case 0160000:
+		if err := os.MkdirAll(full, 0755); err != nil {
+			return index.Entry{}, err
+		}
+		info, err := os.Lstat(full)
+		if err != nil {
+			return index.Entry{}, err
+		}
+		e := index.NewEntry(p, info, entry.Hash)
+		e.Mode = mode
+		return e, nil
+	case 0120000:
+		b, err := blob.ReadBlob(gitDir, entry.Hash)
+		if err != nil {
+			return index.Entry{}, err
+		}
+		if err := os.Symlink(string(b.Data), full); err != nil {
+			return index.Entry{}, err
+		}
+	default:
+		b, err := blob.ReadBlob(gitDir, entry.Hash)
+		if err != nil {
+			return index.Entry{}, err
+		}
+		perm := os.FileMode(0644)
+		if mode == 0100755 {
+			perm = 0755
+		}
+		if err := os.WriteFile(full, b.Data, perm); err != nil {
+			return index.Entry{}, err
+		}
+		// 文件已存在时 WriteFile 不会修改权限
+		if err := os.Chmod(full, perm); err != nil {
+			return index.Entry{}, err
+		}
+	}
+
+	// 3. 记录 stat 信息，之后的 status 不必重新计算哈希
+	info, err := os.Lstat(full)
+	if err != nil {
+		return index.Entry{}, err
+	}
+	return index.NewEntry(p, info, entry.Hash), nil
+}
+
+// skipEntry 返回稀疏检出范围之外的文件的索引条目，工作区中没有这个文件
+func skipEntry(p string, entry *tree.TreeEntry) index.Entry {
+	return index.Entry{Mode: index.ParseMode(entry.Mode), Hash: entry.Hash, Path: p, SkipWorktree: true}
+}
+
+// RemoveFile 删除工作区文件（相对于工作区根目录的路径），并清理变空的父目录；文件不存在时不报错
+func RemoveFile(workDir, p string) error {
+	full := filepath.Join(workDir, filepath.FromSlash(p))
+	if err := os.Remove(full); err != nil && !errors.Is(err, os.ErrNotExist) {
+		// gitlink 对应的目录可能不为空，保留即可
+		if info, statErr := os.Lstat(full); statErr != nil || !info.IsDir() {
+			return err
+		}
+	}
+	for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+		if os.Remove(filepath.Join(workDir, filepath.FromSlash(dir))) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// flatten 把 tree 展开为 路径 -> 条目；零哈希表示空 tree
+func flatten(gitDir string, root hash.Hash) (map[string]*tree.TreeEntry, error) {
+	files := make(map[string]*tree.TreeEntry)
+	if root.IsZero() {
+		return files, nil
+	}
+	err := tree.Walk(gitDir, root, func(p string, e tree.TreeEntry) error {
+		files[p] = &e
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,87 @@
+package checkout
+
+import (
+	"errors"
+
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/merge"
+	"geegit/beginner/day6-create-commit/sparse"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// Merge 把 tree 三方合并的结果写入索引和工作区，res 的 ours 应是当前索引对应的 tree
+//  1. 结果中有变化的路径如果有本地修改或会覆盖未跟踪文件，整体放弃并返回 *ConflictError
+//  2. 干净合并的路径更新工作区文件和 stage 0 条目
+//  3. 冲突的路径在工作区写出带冲突标记的内容，索引中记录 stage 1-3
+//
+// 稀疏检出时，范围之外干净合并的路径只更新索引（标记为 skip-worktree），冲突的路径总是写到工作区
+func Merge(gitDir, workDir string, res *merge.Result) error {
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		return err
+	}
+	patterns, err := sparse.Load(gitDir)
+	if err != nil {
+		return err
+	}
+
+	// 1. 检查本地修改
+	changes := make([]change, 0, len(res.Entries))
+	for _, e := range res.Entries {
+		old := e.Ours
+		if e.OursPath != "" {
+			// ours 中的文件在原路径上（由另一个条目删除），新路径上原本没有文件
+			old = nil
+		}
+		changes = append(changes, change{path: e.Path, old: old, new: e.Result})
+	}
+	if err := checkConflicts(workDir, idx, changes); err != nil {
+		var conflict *ConflictError
+		if errors.As(err, &conflict) {
+			conflict.Merge = true
+		}
+		return err
+	}
+
+	// 2. 更新工作区和索引
+	for _, e := range res.Entries {
+		if e.Result == nil {
+			if err := RemoveFile(workDir, e.Path); err != nil {
+				return err
+			}
+			idx.Remove(e.Path)
+		} else if !e.Conflict && patterns != nil && !patterns.Match(e.Path) {
+			if old, ok := idx.Find(e.Path); !ok || !old.SkipWorktree {
+				if err := RemoveFile(workDir, e.Path); err != nil {
+					return err
+				}
+			}
+			idx.RemoveDir(e.Path)
+			idx.Add(skipEntry(e.Path, e.Result))
+		} else {
+			idx.RemoveDir(e.Path)
+			ie, err := WriteFile(gitDir, workDir, e.Path, e.Result)
+			if err != nil {
+				return err
+			}
+			idx.Add(ie)
+		}
+		if !e.Conflict {
+			continue
+		}
+		idx.Remove(e.Path)
+		for i, te := range []*tree.TreeEntry{e.Base, e.Ours, e.Theirs} {
+			if te == nil {
+				continue
+			}
+			idx.Entries = append(idx.Entries, index.Entry{
+				Mode:  index.ParseMode(te.Mode),
+				Hash:  te.Hash,
+				Stage: i + 1,
+				Path:  e.Path,
+			})
+		}
+		idx.Sort()
+	}
+	return idx.Write(gitDir)
+}
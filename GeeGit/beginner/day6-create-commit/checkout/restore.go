@@ -0,0 +1,233 @@
+package checkout
+
+import (
+	"fmt"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/sparse"
+	"geegit/beginner/day6-create-commit/tree"
+	"geegit/beginner/day6-create-commit/wildmatch"
+)
+
+// RestoreOptions 控制 Restore 恢复什么、从哪里恢复
+type RestoreOptions struct {
+	// Source 是恢复的来源 tree；零哈希表示从索引恢复，此时只能恢复工作区
+	Source hash.Hash
+	// Staged 为 true 时恢复索引（--staged）
+	Staged bool
+	// Worktree 为 true 时恢复工作区（--worktree）
+	Worktree bool
+}
+
+// PathspecError 表示命令行上的路径没有匹配任何被跟踪的文件
+type PathspecError struct {
+	Path string
+}
+
+func (e *PathspecError) Error() string {
+	return fmt.Sprintf("pathspec '%s' did not match any file(s) known to git", e.Path)
+}
+
+// UnmergedError 表示要从索引恢复的路径还有未解决的冲突
+type UnmergedError struct {
+	Path string
+}
+
+func (e *UnmergedError) Error() string {
+	return fmt.Sprintf("path '%s' is unmerged", e.Path)
+}
+
+// Reset 把索引设为 root tree 的内容（git reset --mixed），worktree 为 true 时同时重置工作区（git reset --hard）
+// 内容没有变化的索引条目保留 stat 信息；root 中没有的被跟踪文件从索引（--hard 时还有工作区）中删除，
+// 未跟踪的文件不受影响。零哈希表示空 tree
+//
+// paths 不为空时只重置匹配的路径（git reset <tree-ish> -- <paths>），没有匹配任何文件的路径不算错误
+func Reset(gitDir, workDir string, root hash.Hash, paths []string, worktree bool) error {
+	match := func(string) bool { return true }
+	if len(paths) > 0 {
+		match = matchAny(paths)
+	}
+	return restore(gitDir, workDir, match, RestoreOptions{Source: root, Staged: true, Worktree: worktree})
+}
+
+// Restore 把匹配 paths 的文件恢复为来源中的版本（git restore）
+//  1. 每个路径必须匹配索引或来源 tree 中的文件，否则返回 *PathspecError
+//  2. 从索引恢复时，有冲突的路径返回 *UnmergedError，此时不修改任何文件
+//  3. 来源中没有、但在索引中匹配的文件被删除（no-overlay 模式）
+//
+// 路径相对于工作区根目录，可以是文件、目录或通配符，空字符串表示全部
+func Restore(gitDir, workDir string, paths []string, opts RestoreOptions) error {
+	if !opts.Staged && !opts.Worktree {
+		opts.Worktree = true
+	}
+	if opts.Staged && opts.Source.IsZero() {
+		return fmt.Errorf("cannot restore the index from itself")
+	}
+	match := matchAny(paths)
+
+	// 1. 检查每个路径都有匹配
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		return err
+	}
+	files, err := flatten(gitDir, opts.Source)
+	if err != nil {
+		return err
+	}
+	for _, spec := range paths {
+		found := false
+		for _, e := range idx.Entries {
+			if MatchPath(spec, e.Path) {
+				found = true
+				break
+			}
+		}
+		for p := range files {
+			if found {
+				break
+			}
+			found = MatchPath(spec, p)
+		}
+		if !found {
+			return &PathspecError{Path: spec}
+		}
+	}
+
+	// 2. 从索引恢复时不能有冲突
+	if opts.Source.IsZero() {
+		for _, e := range idx.Entries {
+			if e.Stage != 0 && match(e.Path) {
+				return &UnmergedError{Path: e.Path}
+			}
+		}
+	}
+	return restore(gitDir, workDir, match, opts)
+}
+
+// MatchPath 判断路径是否匹配命令行上的 spec：相同、在其目录下，或者符合通配符（"*" 可以匹配 "/"）
+func MatchPath(spec, p string) bool {
+	spec = strings.TrimSuffix(spec, "/")
+	return spec == "" || spec == "." || p == spec || strings.HasPrefix(p, spec+"/") || wildmatch.Match(spec, p, 0)
+}
+
+// matchAny 返回判断路径是否匹配 paths 中任意一项的函数
+func matchAny(paths []string) func(string) bool {
+	return func(p string) bool {
+		for _, spec := range paths {
+			if MatchPath(spec, p) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// restore 恢复 match 选中的路径
+//  1. 来源中没有的被跟踪文件：恢复索引时删除条目，恢复工作区时删除文件
+//  2. 来源中的文件：恢复索引时更新条目，恢复工作区时写出内容与来源不同的文件
+//
+// 稀疏检出时，范围之外的文件不写到工作区，索引中标记为 skip-worktree
+func restore(gitDir, workDir string, match func(string) bool, opts RestoreOptions) error {
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		return err
+	}
+	patterns, err := sparse.Load(gitDir)
+	if err != nil {
+		return err
+	}
+	outside := func(p string) bool { return patterns != nil && !patterns.Match(p) }
+
+	// 来源：tree 中的文件，或索引中的 stage 0 条目
+	files := make(map[string]*tree.TreeEntry)
+	if opts.Source.IsZero() {
+		for _, e := range idx.Entries {
+			if e.Stage == 0 && match(e.Path) {
+				files[e.Path] = &tree.TreeEntry{Mode: fmt.Sprintf("%o", e.Mode), Name: e.Path, Hash: e.Hash}
+			}
+		}
+	} else {
+		all, err := flatten(gitDir, opts.Source)
+		if err != nil {
+			return err
+		}
+		for p, te := range all {
+			if match(p) {
+				files[p] = te
+			}
+		}
+	}
+
+	// 1. 先删除，再写入，以处理文件和目录互换的情况
+	var removed []string
+	for _, e := range idx.Entries {
+		if _, ok := files[e.Path]; ok || !match(e.Path) {
+			continue
+		}
+		if opts.Worktree && !e.SkipWorktree {
+			if err := RemoveFile(workDir, e.Path); err != nil {
+				return err
+			}
+		}
+		removed = append(removed, e.Path)
+	}
+	if opts.Staged {
+		for _, p := range removed {
+			idx.Remove(p)
+		}
+	}
+
+	// 2. 找出需要更新的文件，部分克隆中缺失的 blob 先一次性获取
+	var paths []string
+	var blobs []hash.Hash
+	for p, te := range files {
+		e, ok := idx.Find(p)
+		same := ok && e.Mode == index.ParseMode(te.Mode) && e.Hash == te.Hash
+		if same && (!opts.Worktree || fileMatches(workDir, e)) {
+			continue
+		}
+		paths = append(paths, p)
+		if opts.Worktree && !te.IsSubmodule() && !outside(p) {
+			blobs = append(blobs, te.Hash)
+		}
+	}
+	if err := object.Prefetch(gitDir, blobs); err != nil {
+		return err
+	}
+
+	// 3. 更新索引和工作区
+	for _, p := range paths {
+		te := files[p]
+		var old index.Entry
+		if e, ok := idx.Find(p); ok {
+			old = *e
+		}
+		same := old.Path == p && old.Mode == index.ParseMode(te.Mode) && old.Hash == te.Hash
+		if opts.Staged {
+			idx.RemoveDir(p)
+		}
+		var e index.Entry
+		switch {
+		case opts.Worktree && !outside(p):
+			e, err = WriteFile(gitDir, workDir, p, te)
+			if err != nil {
+				return err
+			}
+		case opts.Worktree:
+			e = skipEntry(p, te)
+		default:
+			e = index.Entry{Mode: index.ParseMode(te.Mode), Hash: te.Hash, Path: p, SkipWorktree: outside(p)}
+		}
+		// 只恢复工作区时，索引只在内容相同时刷新 stat 信息
+		if opts.Staged || same {
+			if same {
+				e.SkipWorktree, e.IntentToAdd, e.AssumeValid = old.SkipWorktree, old.IntentToAdd, old.AssumeValid
+			}
+			idx.Add(e)
+		}
+	}
+	return idx.Write(gitDir)
+}
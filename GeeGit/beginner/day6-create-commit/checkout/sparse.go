@@ -0,0 +1,71 @@
+package checkout
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/sparse"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// Sparse 按稀疏检出的范围 patterns 更新工作区和索引中的 skip-worktree 标记（git sparse-checkout reapply）
+// patterns 为 nil 表示关闭稀疏检出，检出全部文件
+//  1. 进入范围的文件写到工作区，工作区中已经有同名文件时保留该文件
+//  2. 离开范围的文件从工作区删除；有本地修改的文件保留，不标记 skip-worktree，在返回值中列出
+//  3. 未合并的文件和 intent-to-add 的文件总是留在工作区
+func Sparse(gitDir, workDir string, patterns *sparse.Patterns) (notUpToDate []string, err error) {
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	want := func(e *index.Entry) bool {
+		return patterns == nil || e.Stage != 0 || e.IntentToAdd || patterns.Match(e.Path)
+	}
+
+	// 1. 部分克隆中缺失的 blob 先一次性获取
+	var blobs []hash.Hash
+	for i := range idx.Entries {
+		if e := &idx.Entries[i]; e.SkipWorktree && want(e) && e.Mode != 0160000 {
+			blobs = append(blobs, e.Hash)
+		}
+	}
+	if err := object.Prefetch(gitDir, blobs); err != nil {
+		return nil, err
+	}
+
+	// 2. 逐个条目检出或删除
+	for i := range idx.Entries {
+		e := &idx.Entries[i]
+		switch {
+		case e.SkipWorktree && want(e):
+			full := filepath.Join(workDir, filepath.FromSlash(e.Path))
+			if _, err := os.Lstat(full); err == nil {
+				e.SkipWorktree = false
+				continue
+			}
+			ne, err := WriteFile(gitDir, workDir, e.Path, &tree.TreeEntry{Mode: fmt.Sprintf("%o", e.Mode), Hash: e.Hash})
+			if err != nil {
+				return nil, err
+			}
+			ne.Mode, ne.AssumeValid = e.Mode, e.AssumeValid
+			*e = ne
+		case !e.SkipWorktree && !want(e):
+			full := filepath.Join(workDir, filepath.FromSlash(e.Path))
+			if _, err := os.Lstat(full); err == nil {
+				if !fileMatches(workDir, e) {
+					notUpToDate = append(notUpToDate, e.Path)
+					continue
+				}
+				if err := RemoveFile(workDir, e.Path); err != nil {
+					return nil, err
+				}
+			}
+			e.SkipWorktree = true
+		}
+	}
+	return notUpToDate, idx.Write(gitDir)
+}
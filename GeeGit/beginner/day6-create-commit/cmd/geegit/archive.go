@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	"geegit/beginner/day6-create-commit/archive"
+	"geegit/beginner/day6-create-commit/revision"
+)
+
+// cmdArchive 实现 `geegit archive`：把 tree 的内容导出为 tar、tar.gz 或 zip 归档
+// 格式缺省时根据 -o 的扩展名推断，都推断不出时为 tar；在子目录中运行时只归档该子目录
+//
+//	geegit archive [--format=<fmt>] [--prefix=<prefix>] [-o <file>] [-v] [-<n>] <tree-ish> [<path>...]
+//	geegit archive --list
+func cmdArchive(args []string) error {
+	fs := newFlags("archive", "[--format=<fmt>] [--prefix=<prefix>] [-o <file>] [-v] [-<n>] <tree-ish> [<path>...]")
+	format := fs.String("format", "", "archive format")
+	prefix := fs.String("prefix", "", "prepend prefix to each pathname in the archive")
+	output := fs.String("o", "", "write the archive to this file")
+	fs.StringVar(output, "output", "", "write the archive to this file")
+	verbose := fs.Bool("v", false, "report archived files on stderr")
+	fs.BoolVar(verbose, "verbose", false, "report archived files on stderr")
+	list := fs.Bool("l", false, "list supported archive formats")
+	fs.BoolVar(list, "list", false, "list supported archive formats")
+	level := 0
+	for n := 0; n <= 9; n++ {
+		n := n
+		fs.BoolFunc(strconv.Itoa(n), "compression level (tgz and zip)", func(string) error {
+			level = n
+			if n == 0 {
+				level = archive.NoCompression
+			}
+			return nil
+		})
+	}
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *list {
+		for _, f := range archive.Formats {
+			fmt.Println(f)
+		}
+		return nil
+	}
+	if len(positional) == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	// 1. 要归档的对象，以及在子目录中运行时对应的子目录
+	gitDir, workDir, err := openRepo()
+	if err != nil {
+		return err
+	}
+	h, err := revision.Resolve(gitDir, positional[0])
+	if err != nil {
+		return fmt.Errorf("not a valid object name: %s", positional[0])
+	}
+	opts := archive.Options{Format: *format, Prefix: *prefix, Level: level}
+	if workDir != "" {
+		if opts.Subdir, err = repoPath(workDir, "."); err != nil {
+			return err
+		}
+	}
+	for _, p := range positional[1:] {
+		opts.Paths = append(opts.Paths, path.Clean(filepath.ToSlash(p)))
+	}
+	if opts.Format == "" {
+		opts.Format = archive.FormatFromName(*output)
+	}
+	if *verbose {
+		opts.Verbose = func(p string) { fmt.Fprintln(os.Stderr, p) }
+	}
+
+	// 2. 写入标准输出或 -o 指定的文件，失败时删除不完整的文件
+	if *output == "" {
+		return archive.Write(os.Stdout, gitDir, h, opts)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("could not create archive file '%s': %v", *output, err)
+	}
+	if err := archive.Write(f, gitDir, h, opts); err != nil {
+		f.Close()
+		os.Remove(*output)
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,606 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/bisect"
+	"geegit/beginner/day6-create-commit/blob"
+	"geegit/beginner/day6-create-commit/branch"
+	"geegit/beginner/day6-create-commit/checkout"
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/diff"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/merge"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/revision"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// cmdBisect 实现 `geegit bisect`：在好的和坏的 commit 之间二分查找第一个坏的 commit
+// 每一步检出（分离 HEAD）候选中位于中间的 commit，由用户或 run 的命令判断它的好坏；
+// 状态保存在 .git/BISECT_* 和 refs/bisect/ 中，与 git bisect 兼容
+//
+//	geegit bisect start [<bad> [<good>...]] [--] [<path>...]
+//	geegit bisect (bad | good | skip) [<rev>...]
+//	geegit bisect reset [<commit>]
+//	geegit bisect log
+//	geegit bisect run <cmd> [<arg>...]
+func cmdBisect(args []string) error {
+	usage := "usage: geegit bisect start [<bad> [<good>...]] [--] [<pathspec>...]\n" +
+		"   or: geegit bisect (bad|good|skip) [<rev>...]\n" +
+		"   or: geegit bisect reset [<commit>]\n" +
+		"   or: geegit bisect log\n" +
+		"   or: geegit bisect run <cmd>..."
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return errUsage
+	}
+	sub, args := args[0], args[1:]
+	gitDir, workDir, err := openRepo()
+	if err != nil {
+		return err
+	}
+
+	switch sub {
+	case "start":
+		return bisectStart(gitDir, workDir, args)
+	case bisect.Bad, bisect.Good, bisect.Skip:
+		_, err := bisectState(gitDir, workDir, sub, args)
+		return err
+	case "reset":
+		if len(args) > 1 {
+			fmt.Fprintln(os.Stderr, "error: 'geegit bisect reset' requires either no argument or a commit")
+			return exitCode(1)
+		}
+		return bisectReset(gitDir, args)
+	case "log":
+		data, err := bisect.Log(gitDir)
+		if errors.Is(err, bisect.ErrNotBisecting) {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return exitCode(1)
+		}
+		if err != nil {
+			return err
+		}
+		os.Stdout.Write(data)
+		return nil
+	case "run":
+		return bisectRun(gitDir, workDir, args)
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown subcommand: `%s'\n", sub)
+		fmt.Fprintln(os.Stderr, usage)
+		return errUsage
+	}
+}
+
+// bisectStart 开始二分查找：第一个修订是坏的 commit，其余是好的 commit；没有 "--" 时，
+// 第一个不是修订的参数开始是路径。已经在二分查找时先回到最初的分支
+func bisectStart(gitDir, workDir string, args []string) error {
+	// 1. 修订和路径
+	dashdash := false
+	for _, arg := range args {
+		dashdash = dashdash || arg == "--"
+	}
+	var revs []hash.Hash
+	var paths []string
+	for i, arg := range args {
+		if arg == "--" {
+			paths = args[i+1:]
+			break
+		}
+		if strings.HasPrefix(arg, "--") {
+			fmt.Fprintf(os.Stderr, "error: unrecognized option: '%s'\n", arg)
+			return exitCode(1)
+		}
+		h, err := revision.ResolveType(gitDir, arg, hash.CommitObject)
+		if err == nil {
+			revs = append(revs, h)
+			continue
+		}
+		if dashdash {
+			return fmt.Errorf("'%s' does not appear to be a valid revision", arg)
+		}
+		paths = args[i:]
+		break
+	}
+	for i, p := range paths {
+		rel, err := repoPath(workDir, p)
+		if err != nil {
+			return err
+		}
+		paths[i] = rel
+	}
+
+	// 2. 开始的位置：已经在二分查找时是原来记录的位置
+	start := ""
+	if s, err := bisect.Load(gitDir); err == nil {
+		start = s.Start
+		if err := bisectCheckoutStart(gitDir, start); err != nil {
+			fmt.Fprintf(os.Stderr, "error: checking out '%s' failed. Try 'git bisect start <valid-branch>'.\n", start)
+			return exitCode(1)
+		}
+	} else if !errors.Is(err, bisect.ErrNotBisecting) {
+		return err
+	} else if start, err = branch.Current(gitDir); err != nil {
+		return err
+	} else if start == "" {
+		head, err := refs.Resolve(gitDir, "HEAD")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error: bad HEAD - I need a HEAD")
+			return exitCode(1)
+		}
+		start = head.String()
+	}
+
+	// 3. 记录状态
+	if err := bisect.Start(gitDir, start, paths); err != nil {
+		return err
+	}
+	for i, h := range revs {
+		term := bisect.Good
+		if i == 0 {
+			term = bisect.Bad
+		}
+		if err := bisect.Mark(gitDir, term, h, false); err != nil {
+			return err
+		}
+	}
+	if err := bisect.AppendLog(gitDir, "git bisect start"+bisect.Quote(args)+"\n"); err != nil {
+		return err
+	}
+
+	// 4. 第一步；失败时放弃这次二分查找
+	if _, err := bisectAutoNext(gitDir, workDir); err != nil {
+		if cleanErr := bisect.Clean(gitDir); cleanErr != nil {
+			return cleanErr
+		}
+		return err
+	}
+	return nil
+}
+
+// bisectState 把 revs（为空时是 HEAD）标记为 term，然后进行下一步；返回的 Step 为 nil 表示还缺少好的或坏的 commit
+// skip 的参数可以是 <a>..<b> 形式的范围
+func bisectState(gitDir, workDir, term string, revs []string) (*bisect.Step, error) {
+	if _, err := bisect.Load(gitDir); errors.Is(err, bisect.ErrNotBisecting) {
+		fmt.Fprintln(os.Stderr, "You need to start by \"git bisect start\"")
+		fmt.Fprintln(os.Stderr)
+		return nil, exitCode(1)
+	} else if err != nil {
+		return nil, err
+	}
+	if term == bisect.Bad && len(revs) > 1 {
+		fmt.Fprintf(os.Stderr, "error: 'git bisect %s' can take only one argument.\n", term)
+		return nil, exitCode(1)
+	}
+	if len(revs) == 0 {
+		revs = []string{"HEAD"}
+	}
+
+	// 1. 先检查全部参数，避免只标记了一部分
+	var hashes []hash.Hash
+	for _, rev := range revs {
+		if from, to, ok := strings.Cut(rev, ".."); ok && term == bisect.Skip {
+			a, errA := revision.ResolveType(gitDir, from, hash.CommitObject)
+			b, errB := revision.ResolveType(gitDir, to, hash.CommitObject)
+			if errA != nil || errB != nil {
+				fmt.Fprintf(os.Stderr, "error: Bad rev input: %s\n", rev)
+				return nil, exitCode(1)
+			}
+			list, err := revision.List(gitDir, revision.ListOptions{Include: []hash.Hash{b}, Exclude: []hash.Hash{a}})
+			if err != nil {
+				return nil, err
+			}
+			hashes = append(hashes, list...)
+			continue
+		}
+		if _, err := revision.Resolve(gitDir, rev); err != nil {
+			fmt.Fprintf(os.Stderr, "error: Bad rev input: %s\n", rev)
+			return nil, exitCode(1)
+		}
+		h, err := revision.ResolveType(gitDir, rev, hash.CommitObject)
+		if err != nil {
+			return nil, fmt.Errorf("Bad rev input (not a commit): %s", rev)
+		}
+		hashes = append(hashes, h)
+	}
+
+	// 2. 标记
+	for _, h := range hashes {
+		if err := bisect.Mark(gitDir, term, h, true); err != nil {
+			return nil, err
+		}
+	}
+	return bisectAutoNext(gitDir, workDir)
+}
+
+// bisectAutoNext 已经有好的和坏的 commit 时进行下一步，否则输出（并记录）还在等待什么
+func bisectAutoNext(gitDir, workDir string) (*bisect.Step, error) {
+	s, err := bisect.Load(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	if status := s.Waiting(); status != "" {
+		fmt.Println(status)
+		return nil, bisect.AppendLog(gitDir, "# "+status+"\n")
+	}
+	return bisectNext(gitDir, workDir)
+}
+
+// bisectNext 计算并执行下一步：检出待测的 commit，或者报告结论
+// 退出码与 git 相同：找不到可以二分的范围为 1，只剩下跳过的 commit 为 2，共同祖先是坏的为 3，没有可测试的 commit 为 4
+func bisectNext(gitDir, workDir string) (*bisect.Step, error) {
+	step, err := bisect.Next(gitDir)
+	var both *bisect.BothError
+	var badBase *bisect.MergeBaseError
+	switch {
+	case errors.As(err, &both):
+		fmt.Println(err)
+		return nil, exitCode(1)
+	case errors.As(err, &badBase):
+		fmt.Fprintln(os.Stderr, err)
+		if badBase.Tested {
+			return nil, exitCode(3)
+		}
+		return nil, exitCode(1)
+	case errors.Is(err, bisect.ErrNoTestable):
+		fmt.Fprintln(os.Stderr, err)
+		return nil, exitCode(4)
+	case err != nil:
+		return nil, err
+	}
+
+	if !step.SkippedBase.IsZero() {
+		s, err := bisect.Load(gitDir)
+		if err != nil {
+			return nil, err
+		}
+		good := make([]string, len(s.Good))
+		for i, h := range s.Good {
+			good[i] = h.String()
+		}
+		fmt.Fprintf(os.Stderr, "warning: the merge base between %s and [%s] must be skipped.\n"+
+			"So we cannot be sure the first %s commit is between %s and %s.\nWe continue anyway.\n",
+			s.Bad, strings.Join(good, " "), bisect.Bad, step.SkippedBase, s.Bad)
+	}
+
+	switch step.Outcome {
+	case bisect.Testing:
+		revisions, steps := "revisions", "steps"
+		if step.Left == 1 {
+			revisions = "revision"
+		}
+		if step.Steps == 1 {
+			steps = "step"
+		}
+		fmt.Printf("Bisecting: %d %s left to test after this (roughly %d %s)\n", step.Left, revisions, step.Steps, steps)
+		return step, bisectCheckout(gitDir, workDir, step.Commit)
+	case bisect.MergeBase:
+		fmt.Println("Bisecting: a merge base must be tested")
+		return step, bisectCheckout(gitDir, workDir, step.Commit)
+	case bisect.Found:
+		fmt.Printf("%s is the first %s commit\n", step.Commit, bisect.Bad)
+		return step, showCommit(gitDir, step.Commit)
+	default:
+		fmt.Println("There are only 'skip'ped commits left to test.")
+		fmt.Printf("The first %s commit could be any of:\n", bisect.Bad)
+		for _, h := range step.Candidates {
+			fmt.Println(h)
+		}
+		fmt.Println("We cannot bisect more!")
+		return step, exitCode(2)
+	}
+}
+
+// bisectCheckout 分离 HEAD 到待测的 commit（git checkout -q <commit>），然后输出 "[<hash>] <subject>"
+func bisectCheckout(gitDir, workDir string, h hash.Hash) error {
+	if err := bisect.Expect(gitDir, h); err != nil {
+		return err
+	}
+	current, err := branch.Current(gitDir)
+	if err != nil {
+		return err
+	}
+	oldHead, _ := refs.Resolve(gitDir, "HEAD")
+	from := current
+	if from == "" {
+		from = oldHead.String()
+	}
+	if err := switchTrees(gitDir, workDir, oldHead, h, false); err != nil {
+		var conflict *checkout.ConflictError
+		if errors.As(err, &conflict) {
+			fmt.Fprintf(os.Stderr, "error: %v\nAborting\n", err)
+			return exitCode(1)
+		}
+		return err
+	}
+	if err := refs.Detach(gitDir, h, fmt.Sprintf("checkout: moving from %s to %s", from, h)); err != nil {
+		return err
+	}
+	if err := postCheckout(gitDir, workDir, oldHead, h); err != nil {
+		return err
+	}
+	c, err := commit.ReadCommit(gitDir, h)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[%s] %s\n", h, subjectOf(c))
+	return nil
+}
+
+// bisectCheckoutStart 回到开始二分查找时的位置：分支名切换到分支，完整哈希则分离 HEAD
+// 输出与 git checkout 相同
+func bisectCheckoutStart(gitDir, target string) error {
+	if branch.Exists(gitDir, target) {
+		return cmdSwitch([]string{target})
+	}
+	return cmdSwitch([]string{"--detach", target})
+}
+
+// bisectReset 结束二分查找，回到开始时的位置（或者指定的 commit），清除全部状态
+func bisectReset(gitDir string, args []string) error {
+	if len(args) > 0 {
+		if _, err := revision.ResolveType(gitDir, args[0], hash.CommitObject); err != nil {
+			fmt.Fprintf(os.Stderr, "error: '%s' is not a valid commit\n", args[0])
+			return exitCode(1)
+		}
+	}
+	s, err := bisect.Load(gitDir)
+	if errors.Is(err, bisect.ErrNotBisecting) {
+		fmt.Println(err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	target := s.Start
+	if len(args) > 0 {
+		target = args[0]
+	}
+	if err := bisectCheckoutStart(gitDir, target); err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not check out original HEAD '%s'. Try 'git bisect reset <commit>'.\n", target)
+		return exitCode(1)
+	}
+	return bisect.Clean(gitDir)
+}
+
+// bisectRun 自动二分查找：在每个待测的 commit 上（工作区根目录中）用 sh 执行命令，
+// 退出码 0 表示好，125 表示无法测试（跳过），1 到 127 的其他值表示坏，其余的值中止查找
+func bisectRun(gitDir, workDir string, args []string) error {
+	s, err := bisect.Load(gitDir)
+	if err != nil || s.Waiting() != "" {
+		return exitCode(1)
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "error: bisect run failed: no command provided.")
+		return exitCode(1)
+	}
+	command := bisect.Quote(args)
+	for {
+		// 1. 执行命令
+		fmt.Printf("running %s\n", command)
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Dir = workDir
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		code := 0
+		if err := cmd.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				return err
+			}
+			code = exitErr.ExitCode()
+		}
+		if code < 0 || code >= 128 {
+			// 与 git 相同，退出码是取反后的低 8 位
+			fmt.Fprintf(os.Stderr, "error: bisect run failed: exit code %d from '%s' is < 0 or >= 128\n", code, command)
+			return exitCode(-code & 0xff)
+		}
+
+		// 2. 按退出码标记当前的 commit
+		term := bisect.Bad
+		switch code {
+		case 0:
+			term = bisect.Good
+		case 125:
+			term = bisect.Skip
+		}
+		step, err := bisectState(gitDir, workDir, term, nil)
+		var code2 exitCode
+		switch {
+		case errors.As(err, &code2) && code2 == 2:
+			fmt.Fprintln(os.Stderr, "error: bisect run cannot continue any more")
+			return err
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "error: bisect run failed: 'geegit bisect %s' exited with error code %v\n", term, err)
+			return err
+		case step != nil && step.Outcome == bisect.MergeBase:
+			fmt.Print("bisect run success")
+			return nil
+		case step != nil && step.Outcome == bisect.Found:
+			fmt.Print("bisect found first bad commit")
+			return nil
+		}
+	}
+}
+
+// showCommit 输出 commit 的信息和相对第一个父 commit 的修改统计（git show --stat --summary）
+func showCommit(gitDir string, h hash.Hash) error {
+	c, err := commit.ReadCommit(gitDir, h)
+	if err != nil {
+		return err
+	}
+
+	// 1. 头部和提交说明
+	var b strings.Builder
+	fmt.Fprintf(&b, "commit %s\n", h)
+	if len(c.Parents) > 1 {
+		short := make([]string, len(c.Parents))
+		for i, p := range c.Parents {
+			short[i] = p.String()[:7]
+		}
+		fmt.Fprintf(&b, "Merge: %s\n", strings.Join(short, " "))
+	}
+	fmt.Fprintf(&b, "Author: %s <%s>\n", c.Author.Name, c.Author.Email)
+	fmt.Fprintf(&b, "Date:   %s\n\n", c.Author.When.Format("Mon Jan 2 15:04:05 2006 -0700"))
+	for _, line := range strings.Split(strings.TrimRight(c.Message, "\n"), "\n") {
+		fmt.Fprintf(&b, "    %s\n", line)
+	}
+	os.Stdout.WriteString(b.String())
+
+	// 2. 修改的文件
+	var parentTree hash.Hash
+	if len(c.Parents) > 0 {
+		pc, err := commit.ReadCommit(gitDir, c.Parents[0])
+		if err != nil {
+			return err
+		}
+		parentTree = pc.Tree
+	}
+	stats, summary, err := treeStats(gitDir, parentTree, c.Tree)
+	if err != nil || len(stats) == 0 {
+		return err
+	}
+
+	// 3. 统计和摘要
+	fmt.Println()
+	if err := diff.WriteStat(os.Stdout, stats, 80); err != nil {
+		return err
+	}
+	for _, line := range summary {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// treeStats 比较两个 tree，返回每个修改的文件的统计（按路径排序）和新增、删除、重命名、模式变化的摘要行（--summary）
+// 与 git 默认的 diff.renames 一样，删除和新增的文件之间检测重命名，重命名的统计以新路径排序
+func treeStats(gitDir string, from, to hash.Hash) ([]diff.FileStat, []string, error) {
+	oldFiles, err := flattenTree(gitDir, from)
+	if err != nil {
+		return nil, nil, err
+	}
+	newFiles, err := flattenTree(gitDir, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	deleted := make(map[string]hash.Hash)
+	added := make(map[string]hash.Hash)
+	var paths []string
+	for p, e := range oldFiles {
+		ne, ok := newFiles[p]
+		if !ok || ne.Mode != e.Mode || ne.Hash != e.Hash {
+			paths = append(paths, p)
+		}
+		if !ok && isRegularMode(e.Mode) {
+			deleted[p] = e.Hash
+		}
+	}
+	for p, e := range newFiles {
+		if _, ok := oldFiles[p]; !ok {
+			paths = append(paths, p)
+			if isRegularMode(e.Mode) {
+				added[p] = e.Hash
+			}
+		}
+	}
+	sort.Strings(paths)
+	renames, err := diff.DetectRenames(deleted, added, func(h hash.Hash) ([]byte, error) {
+		b, err := blob.ReadBlob(gitDir, h)
+		if err != nil {
+			return nil, err
+		}
+		return b.Data, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	renamedFrom := make(map[string]bool)
+	renamedTo := make(map[string]diff.Rename)
+	for _, r := range renames {
+		renamedFrom[r.From] = true
+		renamedTo[r.To] = r
+	}
+
+	var stats []diff.FileStat
+	var summary []string
+	for _, p := range paths {
+		if renamedFrom[p] {
+			continue
+		}
+		oldPath, label := p, p
+		r, renamed := renamedTo[p]
+		if renamed {
+			oldPath, label = r.From, diff.RenamePath(r.From, r.To)
+		}
+		oe, ne := oldFiles[oldPath], newFiles[p]
+		oldData, err := entryContent(gitDir, oe)
+		if err != nil {
+			return nil, nil, err
+		}
+		newData, err := entryContent(gitDir, ne)
+		if err != nil {
+			return nil, nil, err
+		}
+		st := diff.FileStat{Path: label}
+		if merge.IsBinary(oldData) || merge.IsBinary(newData) {
+			st.Binary, st.Added, st.Deleted = true, len(newData), len(oldData)
+		} else {
+			st.Added, st.Deleted = diff.CountLines(oldData, newData)
+		}
+		stats = append(stats, st)
+		switch {
+		case renamed:
+			summary = append(summary, fmt.Sprintf(" rename %s (%d%%)", label, r.Score))
+		case oe == nil:
+			summary = append(summary, fmt.Sprintf(" create mode %s %s", modeString(ne.Mode), p))
+		case ne == nil:
+			summary = append(summary, fmt.Sprintf(" delete mode %s %s", modeString(oe.Mode), p))
+		case oe.Mode != ne.Mode:
+			summary = append(summary, fmt.Sprintf(" mode change %s => %s %s", modeString(oe.Mode), modeString(ne.Mode), p))
+		}
+	}
+	return stats, summary, nil
+}
+
+// isRegularMode 判断 tree 条目是否是普通文件；只有普通文件参与重命名检测
+func isRegularMode(mode string) bool {
+	return mode == "100644" || mode == "100755"
+}
+
+// flattenTree 返回 tree 中的全部文件（包括符号链接和子模块），root 为零哈希时返回空
+func flattenTree(gitDir string, root hash.Hash) (map[string]*tree.TreeEntry, error) {
+	files := make(map[string]*tree.TreeEntry)
+	if root.IsZero() {
+		return files, nil
+	}
+	err := tree.Walk(gitDir, root, func(p string, e tree.TreeEntry) error {
+		files[p] = &e
+		return nil
+	})
+	return files, err
+}
+
+// entryContent 返回文件的内容，子模块与 git 一样显示为 "Subproject commit <hash>"，不存在时返回 nil
+func entryContent(gitDir string, e *tree.TreeEntry) ([]byte, error) {
+	switch {
+	case e == nil:
+		return nil, nil
+	case e.IsSubmodule():
+		return []byte("Subproject commit " + e.Hash.String() + "\n"), nil
+	}
+	b, err := blob.ReadBlob(gitDir, e.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return b.Data, nil
+}
+
+// modeString 把 tree 中的模式补齐为 6 位（tree 对象中目录的模式是 "40000"）
+func modeString(mode string) string {
+	return fmt.Sprintf("%06s", mode)
+}
@@ -0,0 +1,737 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/branch"
+	"geegit/beginner/day6-create-commit/checkout"
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/hooks"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/revision"
+	"geegit/beginner/day6-create-commit/wildmatch"
+	"geegit/beginner/day6-create-commit/worktree"
+)
+
+// countFlag 是可以重复出现、统计出现次数的选项，例如 -v 和 -vv
+type countFlag int
+
+func (c *countFlag) String() string   { return fmt.Sprint(int(*c)) }
+func (c *countFlag) IsBoolFlag() bool { return true }
+
+func (c *countFlag) Set(v string) error {
+	if v == "true" {
+		*c++
+	} else if v == "false" {
+		*c = 0
+	}
+	return nil
+}
+
+// cmdBranch 实现 `geegit branch`：列出、创建、删除、重命名分支以及设置上游
+func cmdBranch(args []string) error {
+	fs := newFlags("branch", "[-v[v]] [-a|-r] [--list [<pattern>...]] | <name> [<start>] | -d|-D <name>... | -m|-M [<old>] <new>")
+	del := fs.Bool("d", false, "delete fully merged branch")
+	fs.BoolVar(del, "delete", false, "delete fully merged branch")
+	forceDel := fs.Bool("D", false, "delete branch (even if not merged)")
+	move := fs.Bool("m", false, "move/rename a branch and its reflog")
+	fs.BoolVar(move, "move", false, "move/rename a branch and its reflog")
+	forceMove := fs.Bool("M", false, "move/rename a branch, even if target exists")
+	force := fs.Bool("f", false, "force creation, move/rename, deletion")
+	fs.BoolVar(force, "force", false, "force creation, move/rename, deletion")
+	var verbose countFlag
+	fs.Var(&verbose, "v", "show hash and subject, give twice for upstream branch")
+	fs.Var(&verbose, "verbose", "show hash and subject, give twice for upstream branch")
+	all := fs.Bool("a", false, "list both remote-tracking and local branches")
+	fs.BoolVar(all, "all", false, "list both remote-tracking and local branches")
+	remotes := fs.Bool("r", false, "act on remote-tracking branches")
+	fs.BoolVar(remotes, "remotes", false, "act on remote-tracking branches")
+	list := fs.Bool("l", false, "list branch names")
+	fs.BoolVar(list, "list", false, "list branch names")
+	showCurrent := fs.Bool("show-current", false, "show current branch name")
+	track := fs.Bool("t", false, "set up tracking mode")
+	fs.BoolVar(track, "track", false, "set up tracking mode")
+	noTrack := fs.Bool("no-track", false, "do not set up upstream configuration")
+	upstream := fs.String("u", "", "change the upstream info")
+	fs.StringVar(upstream, "set-upstream-to", "", "change the upstream info")
+	unsetUpstream := fs.Bool("unset-upstream", false, "unset the upstream info")
+	// -vv 等价于 -v -v
+	for i, arg := range args {
+		if arg == "-vv" {
+			args = append(args[:i:i], append([]string{"-v", "-v"}, args[i+1:]...)...)
+			break
+		}
+	}
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	gitDir, workDir, err := openRepo()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *showCurrent:
+		name, err := branch.Current(gitDir)
+		if err != nil {
+			return err
+		}
+		if name != "" {
+			fmt.Println(name)
+		}
+		return nil
+	case *del || *forceDel:
+		if len(positional) == 0 {
+			return fmt.Errorf("branch name required")
+		}
+		return deleteBranches(gitDir, workDir, positional, *forceDel || *force, *remotes)
+	case *move || *forceMove:
+		return renameBranch(gitDir, positional, *forceMove || *force)
+	case *upstream != "":
+		return setUpstream(gitDir, *upstream, positional)
+	case *unsetUpstream:
+		name, err := branchArg(gitDir, positional)
+		if err != nil {
+			return err
+		}
+		cfg, err := config.Load(gitDir)
+		if err != nil {
+			return err
+		}
+		if branch.Upstream(cfg, name) == "" {
+			return fmt.Errorf("branch '%s' has no upstream information", name)
+		}
+		return branch.UnsetUpstream(gitDir, name)
+	case *list || *all || *remotes || verbose > 0 || len(positional) == 0:
+		return listBranches(gitDir, positional, int(verbose), *all, *remotes)
+	}
+
+	if len(positional) > 2 {
+		fs.Usage()
+		return errUsage
+	}
+	start := "HEAD"
+	if len(positional) == 2 {
+		start = positional[1]
+	}
+	trackMode := ""
+	switch {
+	case *noTrack:
+		trackMode = "never"
+	case *track:
+		trackMode = "always"
+	}
+	if current, _ := branch.Current(gitDir); *force && current == positional[0] {
+		return fmt.Errorf("cannot force update the current branch")
+	}
+	if w := worktree.CheckedOut(gitDir, "refs/heads/"+positional[0]); *force && w != nil {
+		return fmt.Errorf("cannot force update the branch '%s' checked out at '%s'", positional[0], w.Path)
+	}
+	return createBranch(gitDir, positional[0], start, *force, trackMode)
+}
+
+// branchArg 返回命令行上指定的分支，没有指定时返回当前分支
+func branchArg(gitDir string, positional []string) (string, error) {
+	if len(positional) > 0 {
+		return positional[0], nil
+	}
+	name, err := branch.Current(gitDir)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", fmt.Errorf("could not set upstream of HEAD when it does not point to any branch")
+	}
+	return name, nil
+}
+
+// listBranches 列出分支，当前分支（或分离的 HEAD）以 "*" 标记
+// verbose 为 1 时显示 commit 和与上游的差异，为 2 时同时显示上游名称
+func listBranches(gitDir string, patterns []string, verbose int, all, remotes bool) error {
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return err
+	}
+	current, err := branch.Current(gitDir)
+	if err != nil {
+		return err
+	}
+
+	type item struct {
+		display string    // 显示的名称
+		ref     string    // 完整引用名，分离 HEAD 时为空
+		hash    hash.Hash // 指向的 commit
+		target  string    // 符号引用的目标（例如 origin/HEAD -> origin/main）
+		current bool
+		other   string // 在其他工作区中检出时为该工作区的路径
+	}
+	var items []item
+
+	// 1. 分离的 HEAD 排在最前面
+	if current == "" {
+		if head, err := refs.Read(gitDir, "HEAD"); err == nil && len(patterns) == 0 {
+			name, at := branch.Detached(gitDir, head.Hash)
+			label := "(HEAD detached at " + name + ")"
+			if !at {
+				label = "(HEAD detached from " + name + ")"
+			}
+			items = append(items, item{display: label, hash: head.Hash, current: true})
+		}
+	}
+
+	// 2. 本地分支和远程跟踪分支
+	refList, err := refs.List(gitDir)
+	if err != nil {
+		return err
+	}
+	for _, r := range refList {
+		var short, display string
+		switch {
+		case strings.HasPrefix(r.Name, "refs/heads/") && !remotes:
+			short = strings.TrimPrefix(r.Name, "refs/heads/")
+			display = short
+		case strings.HasPrefix(r.Name, "refs/remotes/") && (remotes || all):
+			short = strings.TrimPrefix(r.Name, "refs/remotes/")
+			display = short
+			if all {
+				display = "remotes/" + short
+			}
+		default:
+			continue
+		}
+		if len(patterns) > 0 && !matchAny(patterns, short) {
+			continue
+		}
+		it := item{display: display, ref: r.Name, hash: r.Hash, current: r.Name == "refs/heads/"+current}
+		if w := worktree.CheckedOut(gitDir, r.Name); w != nil && !it.current {
+			it.other = w.Path
+		}
+		if ref, err := refs.Read(gitDir, r.Name); err == nil && ref.Target != "" {
+			it.target = refs.ShortName(ref.Target)
+		}
+		items = append(items, it)
+	}
+
+	width := 0
+	for _, it := range items {
+		width = max(width, len(it.display))
+	}
+
+	for _, it := range items {
+		mark := "  "
+		switch {
+		case it.current:
+			mark = "* "
+		case it.other != "":
+			mark = "+ "
+		}
+		if it.target != "" {
+			fmt.Printf("%s%s -> %s\n", mark, it.display, it.target)
+			continue
+		}
+		if verbose == 0 {
+			fmt.Printf("%s%s\n", mark, it.display)
+			continue
+		}
+
+		subject := ""
+		if c, err := commit.ReadCommit(gitDir, it.hash); err == nil {
+			subject = subjectOf(c)
+		}
+		tracking := ""
+		if name, ok := strings.CutPrefix(it.ref, "refs/heads/"); ok {
+			t, err := branch.CompareWithUpstream(gitDir, cfg, name)
+			if err != nil {
+				return err
+			}
+			if t != nil {
+				summary := t.Summary()
+				switch {
+				case verbose > 1 && summary != "":
+					tracking = "[" + t.ShortUpstream() + ": " + summary + "] "
+				case verbose > 1:
+					tracking = "[" + t.ShortUpstream() + "] "
+				case summary != "":
+					tracking = "[" + summary + "] "
+				}
+			}
+		}
+		fmt.Printf("%s%-*s %s %s%s\n", mark, width, it.display, it.hash.String()[:7], tracking, subject)
+	}
+	return nil
+}
+
+// matchAny 判断名称是否符合任意一个 --list 模式
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if wildmatch.Match(p, name, 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// createBranch 从 start 创建分支 name；force 为 true 时重置已有的分支
+// trackMode 为 "always" 时总是设置上游，"never" 时从不设置，为空时只在 start 是远程跟踪分支时设置（branch.autoSetupMerge）
+func createBranch(gitDir, name, start string, force bool, trackMode string) error {
+	refName := "refs/heads/" + name
+	if refs.CheckName(refName) != nil || strings.HasPrefix(name, "-") || name == "HEAD" {
+		return fmt.Errorf("'%s' is not a valid branch name", name)
+	}
+
+	// 1. 分支已存在时只有 -f 才能重置，且不能重置当前分支
+	msg := "branch: Created from " + start
+	if branch.Exists(gitDir, name) {
+		if !force {
+			return fmt.Errorf("a branch named '%s' already exists", name)
+		}
+		msg = "branch: Reset to " + start
+	}
+
+	target, err := revision.ResolveType(gitDir, start, hash.CommitObject)
+	if err != nil {
+		return fmt.Errorf("not a valid object name: '%s'", start)
+	}
+	if err := refs.Update(gitDir, refName, target, nil, msg); err != nil {
+		return err
+	}
+
+	// 2. 设置上游
+	upstream := trackingRef(gitDir, start)
+	if trackMode == "never" || upstream == "" || (trackMode == "" && !strings.HasPrefix(upstream, "refs/remotes/")) {
+		return nil
+	}
+	if err := branch.SetUpstream(gitDir, name, upstream); err != nil {
+		return err
+	}
+	fmt.Printf("branch '%s' set up to track '%s'.\n", name, refs.ShortName(upstream))
+	return nil
+}
+
+// trackingRef 返回 start 对应的可作为上游的分支（远程跟踪分支或本地分支），不是分支时返回空字符串
+func trackingRef(gitDir, start string) string {
+	for _, candidate := range []string{start, "refs/remotes/" + start, "refs/heads/" + start} {
+		if !strings.HasPrefix(candidate, "refs/remotes/") && !strings.HasPrefix(candidate, "refs/heads/") {
+			continue
+		}
+		if ref, err := refs.Read(gitDir, candidate); err == nil && ref.Target == "" {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// deleteBranches 删除分支；未合并的分支需要 force
+// 找不到或不能删除的分支输出错误后继续处理其余分支，最后以退出码 1 结束
+func deleteBranches(gitDir, workDir string, names []string, force, remote bool) error {
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return err
+	}
+	current, err := branch.Current(gitDir)
+	if err != nil {
+		return err
+	}
+	head, _ := refs.Resolve(gitDir, "HEAD")
+
+	failed := false
+	for _, name := range names {
+		refName := "refs/heads/" + name
+		kind := "branch"
+		if remote {
+			refName = "refs/remotes/" + name
+			kind = "remote-tracking branch"
+		}
+		ref, err := refs.Read(gitDir, refName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s '%s' not found.\n", kind, name)
+			failed = true
+			continue
+		}
+		if !remote && name == current {
+			fmt.Fprintf(os.Stderr, "error: Cannot delete branch '%s' checked out at '%s'\n", name, workDir)
+			failed = true
+			continue
+		}
+		if w := worktree.CheckedOut(gitDir, refName); !remote && w != nil {
+			fmt.Fprintf(os.Stderr, "error: Cannot delete branch '%s' checked out at '%s'\n", name, w.Path)
+			failed = true
+			continue
+		}
+
+		// 1. 分支必须已经合并到上游（没有上游时合并到 HEAD）
+		if !remote && !force {
+			mergedTo, into := head, "HEAD"
+			if up := branch.Upstream(cfg, name); up != "" {
+				if h, err := refs.Resolve(gitDir, up); err == nil {
+					mergedTo, into = h, up
+				}
+			}
+			merged, err := revision.IsAncestor(gitDir, ref.Hash, mergedTo)
+			if err != nil {
+				return err
+			}
+			if !merged {
+				fmt.Fprintf(os.Stderr, "error: The branch '%s' is not fully merged.\n"+
+					"If you are sure you want to delete it, run 'git branch -D %s'.\n", name, name)
+				failed = true
+				continue
+			}
+			if into != "HEAD" && !head.IsZero() {
+				if ok, _ := revision.IsAncestor(gitDir, ref.Hash, head); !ok {
+					fmt.Fprintf(os.Stderr, "warning: deleting branch '%s' that has been merged to\n"+
+						"         '%s', but not yet merged to HEAD.\n", name, into)
+				}
+			}
+		}
+
+		// 2. 删除引用和分支配置
+		if err := refs.Delete(gitDir, refName, &ref.Hash); err != nil {
+			return err
+		}
+		if !remote {
+			err := config.RemoveSection(gitdir.Path(gitDir, "config"), "branch."+name)
+			if err != nil {
+				return err
+			}
+		}
+		if remote {
+			fmt.Printf("Deleted remote-tracking branch %s (was %s).\n", name, ref.Hash.String()[:7])
+		} else {
+			fmt.Printf("Deleted branch %s (was %s).\n", name, ref.Hash.String()[:7])
+		}
+	}
+	if failed {
+		return exitCode(1)
+	}
+	return nil
+}
+
+// renameBranch 实现 `branch -m [<old>] <new>`，分支配置和 reflog 随之移动
+func renameBranch(gitDir string, positional []string, force bool) error {
+	var oldName, newName string
+	switch len(positional) {
+	case 1:
+		current, err := branch.Current(gitDir)
+		if err != nil {
+			return err
+		}
+		if current == "" {
+			return fmt.Errorf("cannot rename the current branch while not on any")
+		}
+		oldName, newName = current, positional[0]
+	case 2:
+		oldName, newName = positional[0], positional[1]
+	default:
+		return fmt.Errorf("too many arguments for a rename operation")
+	}
+
+	if !branch.Exists(gitDir, oldName) {
+		return fmt.Errorf("No branch named '%s'.", oldName)
+	}
+	if refs.CheckName("refs/heads/"+newName) != nil || strings.HasPrefix(newName, "-") || newName == "HEAD" {
+		return fmt.Errorf("'%s' is not a valid branch name", newName)
+	}
+	if oldName != newName && branch.Exists(gitDir, newName) {
+		if !force {
+			return fmt.Errorf("a branch named '%s' already exists", newName)
+		}
+		if err := refs.Delete(gitDir, "refs/heads/"+newName, nil); err != nil {
+			return err
+		}
+	}
+	if oldName == newName {
+		return nil
+	}
+
+	msg := fmt.Sprintf("Branch: renamed refs/heads/%s to refs/heads/%s", oldName, newName)
+	if err := refs.Rename(gitDir, "refs/heads/"+oldName, "refs/heads/"+newName, msg); err != nil {
+		return err
+	}
+	return config.RenameSection(gitdir.Path(gitDir, "config"), "branch."+oldName, "branch."+newName)
+}
+
+// setUpstream 实现 `branch -u <upstream> [<branch>]`
+func setUpstream(gitDir, upstream string, positional []string) error {
+	name, err := branchArg(gitDir, positional)
+	if err != nil {
+		return err
+	}
+	if !branch.Exists(gitDir, name) {
+		return fmt.Errorf("branch '%s' does not exist", name)
+	}
+	ref := trackingRef(gitDir, upstream)
+	if ref == "" {
+		return fmt.Errorf("the requested upstream branch '%s' does not exist", upstream)
+	}
+	if err := branch.SetUpstream(gitDir, name, ref); err != nil {
+		return err
+	}
+	fmt.Printf("branch '%s' set up to track '%s'.\n", name, refs.ShortName(ref))
+	return nil
+}
+
+// cmdSwitch 实现 `geegit switch`：切换到已有分支、创建并切换到新分支，或分离 HEAD
+func cmdSwitch(args []string) error {
+	fs := newFlags("switch", "[-c|-C <new-branch>] [-d] [-f] [--no-track] [<branch>|<start-point>]")
+	create := fs.String("c", "", "create and switch to a new branch")
+	fs.StringVar(create, "create", "", "create and switch to a new branch")
+	forceCreate := fs.String("C", "", "create/reset and switch to a branch")
+	fs.StringVar(forceCreate, "force-create", "", "create/reset and switch to a branch")
+	detach := fs.Bool("d", false, "detach HEAD at named commit")
+	fs.BoolVar(detach, "detach", false, "detach HEAD at named commit")
+	force := fs.Bool("f", false, "throw away local modifications")
+	fs.BoolVar(force, "discard-changes", false, "throw away local modifications")
+	track := fs.Bool("t", false, "set upstream info for new branch")
+	fs.BoolVar(track, "track", false, "set upstream info for new branch")
+	noTrack := fs.Bool("no-track", false, "do not set upstream info for new branch")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 && *create == "" && *forceCreate == "" || len(positional) > 2 {
+		fs.Usage()
+		return errUsage
+	}
+
+	gitDir, workDir, err := openRepo()
+	if err != nil {
+		return err
+	}
+
+	// 1. 当前位置：分支名，或分离 HEAD 时的完整哈希（与 git 的 reflog 一致）
+	current, err := branch.Current(gitDir)
+	if err != nil {
+		return err
+	}
+	oldHead, _ := refs.Resolve(gitDir, "HEAD")
+	from := current
+	if from == "" {
+		from = oldHead.String()
+	}
+
+	target := "HEAD"
+	if len(positional) > 0 {
+		target = positional[0]
+	}
+	// label 是错误信息中显示的名称，"-" 显示为 @{-1}
+	label := target
+	if target == "-" {
+		label = "@{-1}"
+		if target, err = branch.Previous(gitDir, 1); err != nil {
+			return fmt.Errorf("invalid reference: %s", label)
+		}
+	}
+
+	// 2. 确定要切换到的分支和 commit
+	newBranch := ""
+	trackMode := ""
+	switch {
+	case *noTrack:
+		trackMode = "never"
+	case *track:
+		trackMode = "always"
+	}
+	switch {
+	case *create != "" || *forceCreate != "":
+		newBranch = *create
+		if *forceCreate != "" {
+			newBranch = *forceCreate
+		}
+		if len(positional) == 0 {
+			target = "HEAD"
+		}
+		if refs.CheckName("refs/heads/"+newBranch) != nil || strings.HasPrefix(newBranch, "-") {
+			return fmt.Errorf("'%s' is not a valid branch name", newBranch)
+		}
+		if branch.Exists(gitDir, newBranch) && *forceCreate == "" {
+			return fmt.Errorf("a branch named '%s' already exists", newBranch)
+		}
+	case *detach:
+	case branch.Exists(gitDir, target):
+		if w := worktree.CheckedOut(gitDir, "refs/heads/"+target); w != nil && current != target {
+			return fmt.Errorf("'%s' is already checked out at '%s'", target, w.Path)
+		}
+		if current == target {
+			fmt.Fprintf(os.Stderr, "Already on '%s'\n", target)
+			if err := refs.SetSymbolic(gitDir, "HEAD", "refs/heads/"+target,
+				fmt.Sprintf("checkout: moving from %s to %s", from, target)); err != nil {
+				return err
+			}
+			if err := printTracking(gitDir, target); err != nil {
+				return err
+			}
+			return postCheckout(gitDir, workDir, oldHead, oldHead)
+		}
+	case len(positional) > 0 && guessRemote(gitDir, target) != "":
+		// 本地没有、但恰好一个远程有同名分支：创建跟踪该远程分支的本地分支
+		newBranch = target
+		target = refs.ShortName(guessRemote(gitDir, target))
+	default:
+		if _, err := revision.ResolveType(gitDir, target, hash.CommitObject); err != nil {
+			return fmt.Errorf("invalid reference: %s", label)
+		}
+		kind := "commit"
+		if _, err := refs.Read(gitDir, "refs/tags/"+target); err == nil {
+			kind = "tag"
+		}
+		fmt.Fprintf(os.Stderr, "fatal: a branch is expected, got %s '%s'\n", kind, target)
+		fmt.Fprintln(os.Stderr, "hint: If you want to detach HEAD at the commit, try again with the --detach option.")
+		return exitCode(128)
+	}
+
+	to, err := revision.ResolveType(gitDir, target, hash.CommitObject)
+	if err != nil {
+		return fmt.Errorf("invalid reference: %s", target)
+	}
+
+	// 3. 切换工作区和索引
+	if err := switchTrees(gitDir, workDir, oldHead, to, *force); err != nil {
+		var conflict *checkout.ConflictError
+		if errors.As(err, &conflict) {
+			fmt.Fprintf(os.Stderr, "error: %v\nAborting\n", err)
+			return exitCode(1)
+		}
+		return err
+	}
+
+	// 4. 更新 HEAD
+	existed := newBranch != "" && branch.Exists(gitDir, newBranch)
+	if newBranch != "" {
+		if err := createBranch(gitDir, newBranch, target, true, trackMode); err != nil {
+			return err
+		}
+	}
+	switch {
+	case newBranch != "":
+		msg := fmt.Sprintf("checkout: moving from %s to %s", from, newBranch)
+		if err := refs.SetSymbolic(gitDir, "HEAD", "refs/heads/"+newBranch, msg); err != nil {
+			return err
+		}
+		switch {
+		case existed && current == newBranch:
+			fmt.Fprintf(os.Stderr, "Reset branch '%s'\n", newBranch)
+		case existed:
+			fmt.Fprintf(os.Stderr, "Switched to and reset branch '%s'\n", newBranch)
+		default:
+			fmt.Fprintf(os.Stderr, "Switched to a new branch '%s'\n", newBranch)
+		}
+		if err := printTracking(gitDir, newBranch); err != nil {
+			return err
+		}
+
+	case *detach:
+		if current == "" && oldHead != to {
+			if c, err := commit.ReadCommit(gitDir, oldHead); err == nil {
+				fmt.Fprintf(os.Stderr, "Previous HEAD position was %s %s\n", oldHead.String()[:7], subjectOf(c))
+			}
+		}
+		if err := refs.Detach(gitDir, to, fmt.Sprintf("checkout: moving from %s to %s", from, target)); err != nil {
+			return err
+		}
+		c, err := commit.ReadCommit(gitDir, to)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "HEAD is now at %s %s\n", to.String()[:7], subjectOf(c))
+
+	default:
+		if current == "" && oldHead != to {
+			if c, err := commit.ReadCommit(gitDir, oldHead); err == nil {
+				fmt.Fprintf(os.Stderr, "Previous HEAD position was %s %s\n", oldHead.String()[:7], subjectOf(c))
+			}
+		}
+		msg := fmt.Sprintf("checkout: moving from %s to %s", from, target)
+		if err := refs.SetSymbolic(gitDir, "HEAD", "refs/heads/"+target, msg); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Switched to branch '%s'\n", target)
+		if err := printTracking(gitDir, target); err != nil {
+			return err
+		}
+	}
+
+	// 5. post-checkout 钩子
+	return postCheckout(gitDir, workDir, oldHead, to)
+}
+
+// postCheckout 运行 post-checkout 钩子，钩子失败时命令以 1 退出（切换已经完成，不会撤销）
+func postCheckout(gitDir, workDir string, old, new hash.Hash) error {
+	var exitErr *hooks.ExitError
+	err := hooks.PostCheckout(gitDir, workDir, old, new, true, os.Stderr)
+	if errors.As(err, &exitErr) {
+		return exitCode(1)
+	}
+	return err
+}
+
+// switchTrees 把工作区从 from commit 切换到 to commit，from 为零哈希表示尚无提交
+func switchTrees(gitDir, workDir string, from, to hash.Hash, force bool) error {
+	var fromTree hash.Hash
+	if !from.IsZero() {
+		c, err := commit.ReadCommit(gitDir, from)
+		if err != nil {
+			return err
+		}
+		fromTree = c.Tree
+	}
+	c, err := commit.ReadCommit(gitDir, to)
+	if err != nil {
+		return err
+	}
+	return checkout.Trees(gitDir, workDir, fromTree, c.Tree, checkout.Options{Force: force})
+}
+
+// guessRemote 在所有远程中查找名为 name 的远程跟踪分支，恰好有一个时返回其完整引用名
+func guessRemote(gitDir, name string) string {
+	all, err := refs.List(gitDir)
+	if err != nil {
+		return ""
+	}
+	found := ""
+	for _, r := range all {
+		rest, ok := strings.CutPrefix(r.Name, "refs/remotes/")
+		if !ok {
+			continue
+		}
+		if _, b, ok := strings.Cut(rest, "/"); ok && b == name {
+			if found != "" {
+				return ""
+			}
+			found = r.Name
+		}
+	}
+	return found
+}
+
+// printTracking 输出分支与上游的比较信息（切换分支之后）
+func printTracking(gitDir, name string) error {
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return err
+	}
+	t, err := branch.CompareWithUpstream(gitDir, cfg, name)
+	if err != nil || t == nil {
+		return err
+	}
+	fmt.Println(t.Message())
+	return nil
+}
+
+// subjectOf 返回 commit message 的标题：与 git 的 %s 相同，第一段的多行用空格连接
+func subjectOf(c *commit.Commit) string {
+	paragraph, _, _ := strings.Cut(strings.TrimLeft(c.Message, "\n"), "\n\n")
+	lines := strings.Split(strings.TrimRight(paragraph, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.Join(lines, " ")
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/bundle"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/lockfile"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/revision"
+)
+
+// cmdBundle 实现 `geegit bundle`：把引用和对象打包成单个文件离线传输，或者检查、解开这样的文件
+// bundle 文件也可以直接作为 clone 和 fetch 的远程仓库地址
+//
+//	geegit bundle create [-q] [--version=<version>] <file> [--all] <rev>... [^<rev>...] [<a>..<b>]
+//	geegit bundle verify [-q] <file>
+//	geegit bundle list-heads <file> [<refname>...]
+//	geegit bundle unbundle <file> [<refname>...]
+func cmdBundle(args []string) error {
+	usage := "usage: geegit bundle create [-q | --quiet] [--version=<version>] <file> <git-rev-list-args>\n" +
+		"   or: geegit bundle verify [-q | --quiet] <file>\n" +
+		"   or: geegit bundle list-heads <file> [<refname>...]\n" +
+		"   or: geegit bundle unbundle <file> [<refname>...]"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "error: need a subcommand")
+		fmt.Fprintln(os.Stderr, usage)
+		return errUsage
+	}
+	sub, args := args[0], args[1:]
+
+	fs := newFlags("bundle "+sub, "<file> [<args>...]")
+	quiet := fs.Bool("q", false, "do not show progress or bundle details")
+	fs.BoolVar(quiet, "quiet", false, "do not show progress or bundle details")
+	version := fs.Int("version", 0, "specify bundle format version")
+	all := fs.Bool("all", false, "include all refs (create)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	switch sub {
+	case "create", "verify", "list-heads", "unbundle":
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown subcommand: `%s'\n", sub)
+		fmt.Fprintln(os.Stderr, usage)
+		return errUsage
+	}
+	if len(positional) == 0 {
+		fmt.Fprintln(os.Stderr, "fatal: need a <file> argument")
+		fmt.Fprintln(os.Stderr)
+		fs.Usage()
+		return errUsage
+	}
+	file, rest := positional[0], positional[1:]
+
+	if sub == "create" {
+		return bundleCreate(file, rest, *all, *version)
+	}
+	b, err := bundle.Read(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitCode(1)
+	}
+	if sub == "list-heads" {
+		listBundleRefs(b, rest)
+		return nil
+	}
+
+	gitDir, _, err := openRepo()
+	if err != nil {
+		return err
+	}
+	switch sub {
+	case "verify":
+		var missing *bundle.MissingError
+		if err := b.Verify(gitDir); errors.As(err, &missing) {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return exitCode(1)
+		} else if err != nil {
+			return err
+		}
+		if !*quiet {
+			printBundleDetails(b)
+		}
+		fmt.Fprintf(os.Stderr, "%s is okay\n", file)
+	case "unbundle":
+		var missing *bundle.MissingError
+		if err := b.Unbundle(gitDir); errors.As(err, &missing) {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return exitCode(1)
+		} else if err != nil {
+			return err
+		}
+		listBundleRefs(b, rest)
+	}
+	return nil
+}
+
+// bundleCreate 按 rev-list 风格的参数创建 bundle，file 为 "-" 时写到标准输出
+// 命令行上能解析为引用名的正向参数（以及 --all 的全部引用和 HEAD）写入 bundle 的引用列表
+func bundleCreate(file string, revs []string, all bool, version int) error {
+	gitDir, _, err := openRepo()
+	if err != nil {
+		return err
+	}
+	opts := bundle.CreateOptions{Version: version}
+
+	// 1. 起点和排除
+	include := func(spec string) error {
+		h, err := revision.Resolve(gitDir, spec)
+		if err != nil {
+			return err
+		}
+		if name, err := refs.Expand(gitDir, spec); err == nil {
+			if h, err = refs.Resolve(gitDir, name); err != nil {
+				return err
+			}
+			opts.Refs = append(opts.Refs, refs.Ref{Name: name, Hash: h})
+		} else {
+			opts.Include = append(opts.Include, h)
+		}
+		return nil
+	}
+	exclude := func(spec string) error {
+		h, err := revision.ResolveType(gitDir, spec, hash.CommitObject)
+		if err != nil {
+			return err
+		}
+		opts.Exclude = append(opts.Exclude, h)
+		return nil
+	}
+	for _, arg := range revs {
+		var err error
+		if from, to, ok := strings.Cut(arg, ".."); ok {
+			if err = exclude(from); err == nil {
+				err = include(to)
+			}
+		} else if strings.HasPrefix(arg, "^") {
+			err = exclude(arg[1:])
+		} else {
+			err = include(arg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if all {
+		list, err := refs.List(gitDir)
+		if err != nil {
+			return err
+		}
+		opts.Refs = append(opts.Refs, list...)
+		if h, err := refs.Resolve(gitDir, "HEAD"); err == nil {
+			opts.Refs = append(opts.Refs, refs.Ref{Name: "HEAD", Hash: h})
+		}
+	}
+
+	// 2. 先写入锁文件，成功后才替换目标文件
+	if file == "-" {
+		return bundle.Create(os.Stdout, gitDir, opts)
+	}
+	lock, err := lockfile.Acquire(file)
+	if err != nil {
+		return err
+	}
+	if err := bundle.Create(lock, gitDir, opts); err != nil {
+		lock.Rollback()
+		return err
+	}
+	return lock.Commit()
+}
+
+// printBundleDetails 输出 bundle 的引用和前提，格式与 git bundle verify 一致
+func printBundleDetails(b *bundle.Bundle) {
+	if len(b.Refs) == 1 {
+		fmt.Println("The bundle contains this ref:")
+	} else {
+		fmt.Printf("The bundle contains these %d refs:\n", len(b.Refs))
+	}
+	listBundleRefs(b, nil)
+	if b.Filter != "" {
+		fmt.Printf("The bundle uses this filter: %s\n", b.Filter)
+	}
+	switch len(b.Prerequisites) {
+	case 0:
+		fmt.Println("The bundle records a complete history.")
+	case 1:
+		fmt.Println("The bundle requires this ref:")
+	default:
+		fmt.Printf("The bundle requires these %d refs:\n", len(b.Prerequisites))
+	}
+	for _, p := range b.Prerequisites {
+		fmt.Printf("%s \n", p.Hash)
+	}
+	fmt.Println("The bundle uses this hash algorithm: sha1")
+}
+
+// listBundleRefs 输出 bundle 中的引用，names 不为空时只输出名字与其中之一完全相同的引用
+func listBundleRefs(b *bundle.Bundle, names []string) {
+	for _, r := range b.Refs {
+		match := len(names) == 0
+		for _, n := range names {
+			match = match || n == r.Name
+		}
+		if match {
+			fmt.Printf("%s %s\n", r.Hash, r.Name)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/matcher"
+)
+
+// cmdCheckIgnore 实现 `geegit check-ignore <pathname>...`
+// 输出被忽略的路径；没有任何路径被忽略时退出码为 1
+func cmdCheckIgnore(args []string) error {
+	fs := newFlags("check-ignore", "<pathname>...")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("no path specified")
+	}
+
+	gitDir, workDir, err := openRepo()
+	if err != nil {
+		return err
+	}
+	ignore, err := matcher.LoadIgnore(gitDir, workDir)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, arg := range positional {
+		p, err := repoPath(workDir, arg)
+		if err != nil {
+			return err
+		}
+		isDir := strings.HasSuffix(arg, "/")
+		if info, err := os.Stat(filepath.Join(workDir, filepath.FromSlash(p))); err == nil {
+			isDir = info.IsDir()
+		}
+		if ignore.Match(p, isDir) {
+			fmt.Println(arg)
+			found = true
+		}
+	}
+	if !found {
+		return exitCode(1)
+	}
+	return nil
+}
+
+// cmdCheckAttr 实现 `geegit check-attr (-a | <attr>...) [--] <pathname>...`
+// 没有 "--" 时第一个参数是属性名，其余是路径
+func cmdCheckAttr(args []string) error {
+	fs := newFlags("check-attr", "(-a | <attr>...) [--] <pathname>...")
+	all := fs.Bool("a", false, "report all attributes set on file")
+	fs.BoolVar(all, "all", false, "report all attributes set on file")
+
+	// "--" 用于分隔属性名和路径，需要在 parseArgs 之前找出来
+	var names, paths []string
+	sep := -1
+	for i, arg := range args {
+		if arg == "--" {
+			sep = i
+			break
+		}
+	}
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	switch {
+	case *all:
+		paths = positional
+	case sep >= 0:
+		before, err := parseArgs(fs, args[:sep])
+		if err != nil {
+			return err
+		}
+		names, paths = before, args[sep+1:]
+	case len(positional) > 0:
+		names, paths = positional[:1], positional[1:]
+	}
+	if (!*all && len(names) == 0) || len(paths) == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	gitDir, workDir, err := openRepo()
+	if err != nil {
+		return err
+	}
+	attributes, err := matcher.LoadAttributes(gitDir, workDir)
+	if err != nil {
+		return err
+	}
+
+	for _, arg := range paths {
+		p, err := repoPath(workDir, arg)
+		if err != nil {
+			return err
+		}
+		if *all {
+			for _, attr := range attributes.All(p) {
+				fmt.Printf("%s: %s: %s\n", arg, attr.Name, attr.String())
+			}
+			continue
+		}
+		attrs := attributes.Lookup(p)
+		for _, name := range names {
+			fmt.Printf("%s: %s: %s\n", arg, name, attrs.Get(name).String())
+		}
+	}
+	return nil
+}
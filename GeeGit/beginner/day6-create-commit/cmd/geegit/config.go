@@ -0,0 +1,311 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/repository"
+	"geegit/beginner/day6-create-commit/worktree"
+)
+
+// cmdConfig 实现 `geegit config`：读取和修改配置
+//
+//	geegit config [<file-option>] [--show-origin] [--show-scope] [--type=<type>] -l | --get <name> | --get-all <name> | <name>
+//	geegit config [<file-option>] <name> <value> | --add <name> <value> | --unset[-all] <name>
+//	geegit config [<file-option>] --rename-section <old> <new> | --remove-section <name>
+//
+// 读取时没有指定文件则合并所有层级的配置；写入时默认写 .git/config
+func cmdConfig(args []string) error {
+	fs := newFlags("config", "[--global|--system|--local|--worktree|-f <file>] [--show-origin] [--show-scope] [--type=<type>] (-l | --get <name> | <name> [<value>] | ...)")
+	global := fs.Bool("global", false, "use global config file")
+	system := fs.Bool("system", false, "use system config file")
+	local := fs.Bool("local", false, "use repository config file")
+	worktreeScope := fs.Bool("worktree", false, "use per-worktree config file")
+	file := fs.String("f", "", "use given config file")
+	fs.StringVar(file, "file", "", "use given config file")
+	list := fs.Bool("l", false, "list all")
+	fs.BoolVar(list, "list", false, "list all")
+	get := fs.Bool("get", false, "get value: name")
+	getAll := fs.Bool("get-all", false, "get all values: name")
+	add := fs.Bool("add", false, "add a new variable: name value")
+	unset := fs.Bool("unset", false, "remove a variable: name")
+	unsetAll := fs.Bool("unset-all", false, "remove all matches: name")
+	renameSection := fs.Bool("rename-section", false, "rename section: old-name new-name")
+	removeSection := fs.Bool("remove-section", false, "remove a section: name")
+	showOrigin := fs.Bool("show-origin", false, "show origin of config (file, command line)")
+	showScope := fs.Bool("show-scope", false, "show scope of config (system, global, local, worktree, command)")
+	typ := fs.String("type", "", "value is given this type (bool, int, path)")
+	for _, t := range []string{"bool", "int", "path"} {
+		t := t
+		fs.BoolFunc(t, "value is "+t, func(string) error { *typ = t; return nil })
+	}
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	gitDir, workDir, err := openRepo()
+	if err != nil && !errors.Is(err, repository.ErrNotRepository) {
+		return err
+	}
+
+	// 1. 确定要读写的文件，target 为空表示读取时合并所有层级
+	target := ""
+	switch {
+	case *file != "":
+		target = *file
+	case *global:
+		target = config.GlobalFile()
+	case *system:
+		target = config.SystemFile()
+	case *local:
+		if gitDir == "" {
+			return fmt.Errorf("--local can only be used inside a git repository")
+		}
+		target = gitdir.Path(gitDir, "config")
+	case *worktreeScope:
+		if gitDir == "" {
+			return fmt.Errorf("--worktree can only be used inside a git repository")
+		}
+		// 没有打开 extensions.worktreeConfig 时只有一个工作区才能使用，等同于 --local
+		if target = config.WorktreeFile(gitDir); target == "" {
+			trees, err := worktree.List(gitDir)
+			if err != nil {
+				return err
+			}
+			if len(trees) > 1 {
+				return fmt.Errorf("--worktree cannot be used with multiple working trees unless the config\n" +
+					"extension worktreeConfig is enabled. Please read \"CONFIGURATION FILE\"\n" +
+					"section in \"git help worktree\" for details")
+			}
+			target = gitdir.Path(gitDir, "config")
+		}
+	}
+	writeTarget := func() (string, error) {
+		if target != "" {
+			return target, nil
+		}
+		if gitDir == "" {
+			return "", fmt.Errorf("not in a git directory")
+		}
+		return gitdir.Path(gitDir, "config"), nil
+	}
+	load := func() (*config.Config, error) {
+		if target != "" {
+			if _, err := os.Stat(target); errors.Is(err, os.ErrNotExist) {
+				return &config.Config{}, nil
+			}
+			return config.LoadFile(target)
+		}
+		return config.Load(gitDir)
+	}
+
+	// printEntry 输出一条配置，按需加上层级和来源
+	printEntry := func(e config.Entry, text string) {
+		if *showScope {
+			scope := string(e.Scope)
+			if scope == "" {
+				scope = "command"
+			}
+			fmt.Print(scope + "\t")
+		}
+		if *showOrigin {
+			fmt.Print(formatOrigin(e, workDir) + "\t")
+		}
+		fmt.Println(text)
+	}
+
+	nargs := func(n int) error {
+		if len(positional) != n {
+			fmt.Fprintf(os.Stderr, "error: wrong number of arguments, should be %d\n", n)
+			return exitCode(129)
+		}
+		return nil
+	}
+
+	switch {
+	case *list:
+		cfg, err := load()
+		if err != nil {
+			return err
+		}
+		for _, e := range cfg.Entries {
+			if e.Implicit {
+				printEntry(e, e.Name())
+			} else {
+				printEntry(e, e.Name()+"="+e.Value)
+			}
+		}
+		return nil
+
+	case *getAll || *get || (len(positional) == 1 && !*unset && !*unsetAll && !*removeSection):
+		if err := nargs(1); err != nil {
+			return err
+		}
+		name := positional[0]
+		if err := checkKey(name); err != nil {
+			return err
+		}
+		cfg, err := load()
+		if err != nil {
+			return err
+		}
+		matches := cfg.Find(name)
+		if len(matches) == 0 {
+			return exitCode(1)
+		}
+		if !*getAll {
+			matches = matches[len(matches)-1:]
+		}
+		for _, e := range matches {
+			value, err := formatValue(e, *typ)
+			if err != nil {
+				return err
+			}
+			printEntry(e, value)
+		}
+		return nil
+
+	case *unset || *unsetAll:
+		if err := nargs(1); err != nil {
+			return err
+		}
+		f, err := writeTarget()
+		if err != nil {
+			return err
+		}
+		cfg, err := config.LoadFile(f)
+		if err != nil {
+			return exitCode(5)
+		}
+		n := len(cfg.Find(positional[0]))
+		switch {
+		case n == 0:
+			return exitCode(5)
+		case n > 1 && *unset:
+			fmt.Fprintf(os.Stderr, "warning: %s has multiple values\n", positional[0])
+			return exitCode(5)
+		}
+		return config.Unset(f, positional[0])
+
+	case *renameSection || *removeSection:
+		want := 1
+		if *renameSection {
+			want = 2
+		}
+		if err := nargs(want); err != nil {
+			return err
+		}
+		f, err := writeTarget()
+		if err != nil {
+			return err
+		}
+		if !hasSection(f, positional[0]) {
+			return fmt.Errorf("no such section: %s", positional[0])
+		}
+		if *renameSection {
+			return config.RenameSection(f, positional[0], positional[1])
+		}
+		return config.RemoveSection(f, positional[0])
+	}
+
+	// 2. 设置值
+	if err := nargs(2); err != nil {
+		return err
+	}
+	name, value := positional[0], positional[1]
+	if err := checkKey(name); err != nil {
+		return err
+	}
+	f, err := writeTarget()
+	if err != nil {
+		return err
+	}
+	if *add {
+		return config.AddValue(f, name, value)
+	}
+	if cfg, err := config.LoadFile(f); err == nil && len(cfg.Find(name)) > 1 {
+		fmt.Fprintf(os.Stderr, "warning: %s has multiple values\n", name)
+		fmt.Fprintf(os.Stderr, "error: cannot overwrite multiple values with a single value\n"+
+			"       Use a regexp, --add or --replace-all to change %s.\n", name)
+		return exitCode(5)
+	}
+	return config.SetValue(f, name, value)
+}
+
+// checkKey 检查键名至少包含节和键两部分
+func checkKey(name string) error {
+	if !strings.Contains(name, ".") || strings.HasPrefix(name, ".") {
+		fmt.Fprintf(os.Stderr, "error: key does not contain a section: %s\n", name)
+		return exitCode(1)
+	}
+	if strings.HasSuffix(name, ".") {
+		fmt.Fprintf(os.Stderr, "error: key does not contain variable name: %s\n", name)
+		return exitCode(1)
+	}
+	return nil
+}
+
+// hasSection 判断配置文件中是否有该节
+func hasSection(file, name string) bool {
+	cfg, err := config.LoadFile(file)
+	if err != nil {
+		return false
+	}
+	section, subsection, _ := strings.Cut(name, ".")
+	for _, e := range cfg.Entries {
+		if e.Section == strings.ToLower(section) && e.Subsection == subsection {
+			return true
+		}
+	}
+	return false
+}
+
+// formatValue 按 --type 转换值的输出形式
+func formatValue(e config.Entry, typ string) (string, error) {
+	switch typ {
+	case "bool":
+		if e.Implicit {
+			return "true", nil
+		}
+		b, ok := config.ParseBool(e.Value)
+		if !ok {
+			return "", fmt.Errorf("bad boolean config value '%s' for '%s'", e.Value, e.Name())
+		}
+		return strconv.FormatBool(b), nil
+	case "int":
+		n, ok := config.ParseInt(e.Value)
+		if !ok {
+			return "", fmt.Errorf("bad numeric config value '%s' for '%s': invalid unit", e.Value, e.Name())
+		}
+		return strconv.FormatInt(n, 10), nil
+	case "path":
+		return config.ExpandPath(e.Value), nil
+	case "":
+		if e.Implicit {
+			return "", nil
+		}
+		return e.Value, nil
+	}
+	return "", fmt.Errorf("unrecognized --type argument, %s", typ)
+}
+
+// formatOrigin 返回 --show-origin 的来源，仓库内的文件显示为相对于工作区根目录的路径
+func formatOrigin(e config.Entry, workDir string) string {
+	if e.Origin == "" {
+		return "command line:"
+	}
+	origin := e.Origin
+	if workDir != "" {
+		if rel, err := filepath.Rel(workDir, origin); err == nil && !strings.HasPrefix(rel, "..") {
+			origin = rel
+		}
+	}
+	return "file:" + filepath.ToSlash(origin)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"geegit/beginner/day6-create-commit/config"
+)
+
+// 需要编辑提交说明的命令（revert）通过 messageEditor 启动用户的编辑器，
+// 编辑器按 git 的顺序确定：GIT_EDITOR、core.editor、VISUAL、EDITOR，最后是 vi
+
+// editHint 是附加在待编辑说明末尾的提示，与 git commit 的相同；注释行在提交时去掉
+const editHint = "\n# Please enter the commit message for your changes. Lines starting\n" +
+	"# with '#' will be ignored, and an empty message aborts the commit.\n"
+
+// isTerminal 判断 f 是否是终端；与 git 一样，没有指定 --edit/--no-edit 时只在终端上编辑
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// editor 返回用户配置的编辑器命令
+func editor(gitDir string) string {
+	if e := os.Getenv("GIT_EDITOR"); e != "" {
+		return e
+	}
+	if cfg, err := config.Load(gitDir); err == nil {
+		if e, ok := cfg.Get("core.editor"); ok && e != "" {
+			return e
+		}
+	}
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if e := os.Getenv(name); e != "" {
+			return e
+		}
+	}
+	return "vi"
+}
+
+// messageEditor 返回编辑说明文件的函数：在文件末尾加上 editHint，然后在终端上运行编辑器
+// 编辑器命令由 shell 解释（可以带参数），":" 表示不编辑
+func messageEditor(gitDir, workDir string) func(msgFile string) error {
+	return func(msgFile string) error {
+		f, err := os.OpenFile(msgFile, os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = f.WriteString(editHint)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		e := editor(gitDir)
+		if e == ":" {
+			return nil
+		}
+		cmd := exec.Command("sh", "-c", e+` "$@"`, e, msgFile)
+		cmd.Dir = workDir
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("there was a problem with the editor '%s'", e)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/fastimport"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/revision"
+	"geegit/beginner/day6-create-commit/storage"
+)
+
+// cmdFastExport 实现 `geegit fast-export`：把选定的历史写成 fast-import 流，输出到标准输出
+// 参数与 rev-list 相同；能解析为引用名的参数（以及 --all 的全部引用）在流中以引用名出现
+//
+//	geegit fast-export [--all] [--signed-tags=<mode>] [--reference-excluded-parents] [--full-tree] [--no-data]
+//	                   [--import-marks=<file>] [--export-marks=<file>] <rev>... [^<rev>...] [<a>..<b>]
+func cmdFastExport(args []string) error {
+	fs := newFlags("fast-export", "[<options>] [<rev-list-opts>]")
+	all := fs.Bool("all", false, "export all refs")
+	signedTags := fs.String("signed-tags", "abort", "what to do with signed tags (abort, verbatim, strip)")
+	referenceExcluded := fs.Bool("reference-excluded-parents", false, "reference parents which are not in fast-export stream by object id")
+	fullTree := fs.Bool("full-tree", false, "output full tree for each commit")
+	noData := fs.Bool("no-data", false, "skip output of blob data")
+	importMarks := fs.String("import-marks", "", "import marks from this file")
+	exportMarks := fs.String("export-marks", "", "dump marks to this file")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	switch *signedTags {
+	case "abort", "verbatim", "strip":
+	default:
+		fmt.Fprintf(os.Stderr, "error: Unknown signed-tags mode: %s\n", *signedTags)
+		return errUsage
+	}
+	gitDir, _, err := openRepo()
+	if err != nil {
+		return err
+	}
+
+	// 1. 起点和排除，引用使用完整的名字；HEAD 指向分支时使用分支名
+	var tips []fastimport.Tip
+	include := func(spec string) error {
+		name, err := refs.Expand(gitDir, spec)
+		if err != nil {
+			h, err := revision.Resolve(gitDir, spec)
+			if err != nil {
+				return err
+			}
+			tips = append(tips, fastimport.Tip{Name: spec, Hash: h})
+			return nil
+		}
+		ref, err := refs.Read(gitDir, name)
+		if err != nil {
+			return err
+		}
+		if name == "HEAD" && ref.Target != "" {
+			name = ref.Target
+		}
+		tips = append(tips, fastimport.Tip{Name: name, Hash: ref.Hash, Ref: true})
+		return nil
+	}
+	exclude := func(spec string) error {
+		h, err := revision.ResolveType(gitDir, spec, hash.CommitObject)
+		if err != nil {
+			return err
+		}
+		tips = append(tips, fastimport.Tip{Name: spec, Hash: h, Exclude: true})
+		return nil
+	}
+	if *all {
+		list, err := refs.List(gitDir)
+		if err != nil {
+			return err
+		}
+		for _, r := range list {
+			tips = append(tips, fastimport.Tip{Name: r.Name, Hash: r.Hash, Ref: true})
+		}
+		if _, err := refs.Resolve(gitDir, "HEAD"); err == nil {
+			if err := include("HEAD"); err != nil {
+				return err
+			}
+		}
+	}
+	for _, arg := range positional {
+		var err error
+		if from, to, ok := strings.Cut(arg, ".."); ok {
+			if err = exclude(from); err == nil {
+				err = include(to)
+			}
+		} else if strings.HasPrefix(arg, "^") {
+			err = exclude(arg[1:])
+		} else {
+			err = include(arg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// 2. 导出
+	return fastimport.Export(os.Stdout, storage.NewFilesystem(gitDir), tips, fastimport.ExportOptions{
+		ReferenceExcludedParents: *referenceExcluded,
+		FullTree:                 *fullTree,
+		NoData:                   *noData,
+		SignedTags:               *signedTags,
+		ImportMarks:              *importMarks,
+		ExportMarks:              *exportMarks,
+		Warnings:                 os.Stderr,
+	})
+}
+
+// cmdFastImport 实现 `geegit fast-import`：从标准输入读取 fast-import 流，写入对象并更新其中的分支和标签
+// 有分支因为不是快进而没有更新时退出码为 1
+//
+//	geegit fast-import [--force] [--quiet | --stats] [--date-format=<fmt>] [--done]
+//	                   [--import-marks=<file> | --import-marks-if-exists=<file>] [--export-marks=<file>]
+func cmdFastImport(args []string) error {
+	fs := newFlags("fast-import", "[<options>]")
+	force := fs.Bool("force", false, "force updating modified existing branches, even if doing so would cause commits to be lost")
+	quiet := fs.Bool("quiet", false, "disable the output shown by --stats")
+	stats := fs.Bool("stats", false, "display some basic statistics about the objects fast-import has created")
+	dateFormat := fs.String("date-format", "", "specify the type of dates the frontend will supply (raw, raw-permissive, rfc2822, now)")
+	done := fs.Bool("done", false, "terminate with error if there is no done command at the end of the stream")
+	importMarks := fs.String("import-marks", "", "read marks from this file before processing any input")
+	importMarksIfExists := fs.String("import-marks-if-exists", "", "like --import-marks but does not complain if the file does not exist")
+	exportMarks := fs.String("export-marks", "", "dump the internal marks table to this file when complete")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		fs.Usage()
+		return errUsage
+	}
+	gitDir, _, err := openRepo()
+	if err != nil {
+		return err
+	}
+
+	opts := fastimport.ImportOptions{
+		Force:       *force,
+		DateFormat:  *dateFormat,
+		ImportMarks: *importMarks,
+		ExportMarks: *exportMarks,
+		Done:        *done,
+		Output:      os.Stdout,
+	}
+	if *importMarksIfExists != "" {
+		opts.ImportMarks, opts.IgnoreMissingMarks = *importMarksIfExists, true
+	}
+	st, err := fastimport.Import(os.Stdin, storage.NewFilesystem(gitDir), opts)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range st.Refused {
+		fmt.Fprintf(os.Stderr, "warning: Not updating %s (new tip %s does not contain %s)\n", r.Name, r.New, r.Old)
+	}
+	if !*quiet || *stats {
+		printImportStats(st)
+	}
+	if len(st.Refused) > 0 {
+		return exitCode(1)
+	}
+	return nil
+}
+
+// printImportStats 输出导入的统计，格式参照 git fast-import
+func printImportStats(st *fastimport.Stats) {
+	total, duplicates := 0, 0
+	for _, t := range []hash.ObjectType{hash.BlobObject, hash.TreeObject, hash.CommitObject, hash.TagObject} {
+		total += st.Objects[t]
+		duplicates += st.Duplicates[t]
+	}
+	line := strings.Repeat("-", 69)
+	fmt.Fprintln(os.Stderr, "fast-import statistics:")
+	fmt.Fprintln(os.Stderr, line)
+	fmt.Fprintf(os.Stderr, "Total objects:   %10d (%10d duplicates)\n", total, duplicates)
+	for _, row := range []struct {
+		name string
+		t    hash.ObjectType
+	}{{"blobs  ", hash.BlobObject}, {"trees  ", hash.TreeObject}, {"commits", hash.CommitObject}, {"tags   ", hash.TagObject}} {
+		fmt.Fprintf(os.Stderr, "      %s: %10d (%10d duplicates)\n", row.name, st.Objects[row.t], st.Duplicates[row.t])
+	}
+	fmt.Fprintf(os.Stderr, "Total branches:  %10d\n", st.Branches)
+	fmt.Fprintf(os.Stderr, "      marks:     %10d unique\n", st.Marks)
+	fmt.Fprintln(os.Stderr, line)
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/bundle"
+	"geegit/beginner/day6-create-commit/checkout"
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/fetch"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/repository"
+	"geegit/beginner/day6-create-commit/shallow"
+)
+
+// cmdClone 实现 `geegit clone`：把仓库克隆到新目录并检出远程 HEAD 所在的分支
+// 目前只支持本地仓库和 bundle 文件：直接给出路径时与 git 的本地克隆一样忽略 --depth 等选项，
+// 使用 file:// 地址时才会按选项截断历史或过滤对象。--reference 和 --shared（只对本地路径有效）
+// 把对方的对象目录写入 objects/info/alternates，直接借用其中的对象，--dissociate 在克隆后复制这些对象
+//
+//	geegit clone [--depth <depth>] [--shallow-since <date>] [--filter <filter-spec>] [-n] [-q]
+//	             [--reference <repo>] [-s | --shared] [--dissociate] <repo> [<dir>]
+func cmdClone(args []string) error {
+	fs := newFlags("clone", "[<options>] [--] <repo> [<dir>]")
+	depth := fs.String("depth", "", "create a shallow clone of that depth")
+	since := fs.String("shallow-since", "", "create a shallow clone since a specific time")
+	filter := fs.String("filter", "", "object filtering")
+	noCheckout := fs.Bool("n", false, "don't create a checkout")
+	fs.BoolVar(noCheckout, "no-checkout", false, "don't create a checkout")
+	quiet := fs.Bool("q", false, "be more quiet")
+	fs.BoolVar(quiet, "quiet", false, "be more quiet")
+	var reference multiFlag
+	fs.Var(&reference, "reference", "reference repository")
+	shared := fs.Bool("s", false, "setup as shared repository")
+	fs.BoolVar(shared, "shared", false, "setup as shared repository")
+	dissociate := fs.Bool("dissociate", false, "use --reference only while cloning")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(positional) == 0:
+		fmt.Fprintln(os.Stderr, "fatal: You must specify a repository to clone.")
+		fmt.Fprintln(os.Stderr)
+		fs.Usage()
+		return errUsage
+	case len(positional) > 2:
+		fmt.Fprintln(os.Stderr, "fatal: Too many arguments.")
+		fmt.Fprintln(os.Stderr)
+		fs.Usage()
+		return errUsage
+	}
+	opts, err := fetchOptions(*depth, "", *since, *filter)
+	if err != nil {
+		return err
+	}
+
+	// 1. 本地路径转换为绝对路径，截断历史和过滤对象的选项只对 file:// 地址有效；
+	//    bundle 文件中的历史是固定的，这些选项同样被忽略（与 git 一样不提示）
+	url := positional[0]
+	isBundle := bundle.IsBundle(url)
+	local := !strings.Contains(url, "://") && !isBundle
+	if isBundle {
+		if url, err = filepath.Abs(url); err != nil {
+			return err
+		}
+		opts = fetch.Options{}
+	}
+	if local {
+		if _, err := repository.OpenLocal(url); err != nil {
+			return fmt.Errorf("repository '%s' does not exist", url)
+		}
+		if url, err = filepath.Abs(url); err != nil {
+			return err
+		}
+		for _, ignored := range []struct {
+			name string
+			set  bool
+		}{{"--depth", *depth != ""}, {"--shallow-since", *since != ""}, {"--filter", *filter != ""}} {
+			if ignored.set {
+				fmt.Fprintf(os.Stderr, "warning: %s is ignored in local clones; use file:// instead.\n", ignored.name)
+			}
+		}
+		opts = fetch.Options{}
+		if *shared {
+			opts.Reference = append(opts.Reference, url)
+		}
+	}
+	opts.Reference = append(opts.Reference, reference...)
+	opts.Dissociate = *dissociate
+	opts.Warnings = os.Stderr
+
+	// 2. 目标目录不能是非空的已有目录
+	dir := cloneDirName(positional[0])
+	if len(positional) == 2 {
+		dir = positional[1]
+	}
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("destination path '%s' already exists and is not an empty directory.", dir)
+	}
+	workDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	_, statErr := os.Stat(workDir)
+	if !*quiet {
+		fmt.Fprintf(os.Stderr, "Cloning into '%s'...\n", dir)
+	}
+
+	// 3. 克隆，失败时删除创建的目录（目录原本存在时只删除其中的 .git），检出失败时也一样
+	gitDir := filepath.Join(workDir, ".git")
+	cleanup := func() {
+		if os.IsNotExist(statErr) {
+			os.RemoveAll(workDir)
+		} else {
+			os.RemoveAll(gitDir)
+		}
+	}
+	res, err := fetch.Clone(url, gitDir, opts)
+	if err != nil {
+		cleanup()
+		return err
+	}
+	if local && !*quiet {
+		fmt.Fprintln(os.Stderr, "done.")
+	}
+
+	// 4. 检出
+	head, err := refs.Resolve(gitDir, "HEAD")
+	if err != nil && len(res.Updates) > 0 {
+		fmt.Fprintln(os.Stderr, "warning: remote HEAD refers to nonexistent ref, unable to checkout")
+		return nil
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: You appear to have cloned an empty repository.")
+		return nil
+	}
+	if *noCheckout {
+		return nil
+	}
+	c, err := commit.ReadCommit(gitDir, head)
+	if err != nil {
+		cleanup()
+		return err
+	}
+	if err := checkout.Trees(gitDir, workDir, hash.Hash{}, c.Tree, checkout.Options{Force: true}); err != nil {
+		cleanup()
+		return err
+	}
+	return postCheckout(gitDir, workDir, hash.Hash{}, head)
+}
+
+// cloneDirName 由仓库地址得到默认的目标目录：最后一个路径分量，去掉 "/.git"、".git" 和 ".bundle" 后缀
+func cloneDirName(url string) string {
+	name := strings.TrimRight(filepath.ToSlash(url), "/")
+	name = strings.TrimSuffix(name, "/.git")
+	name = name[strings.LastIndexAny(name, "/:")+1:]
+	return strings.TrimSuffix(strings.TrimSuffix(name, ".git"), ".bundle")
+}
+
+// cmdFetch 实现 `geegit fetch`：从远程仓库获取对象，更新远程跟踪分支和 FETCH_HEAD
+//
+//	geegit fetch [--depth <depth> | --deepen <depth> | --shallow-since <date> | --unshallow] [-q] [<repository>]
+func cmdFetch(args []string) error {
+	fs := newFlags("fetch", "[<options>] [<repository>]")
+	depth := fs.String("depth", "", "deepen history of shallow clone")
+	deepen := fs.String("deepen", "", "deepen history of shallow clone by the given number of commits")
+	since := fs.String("shallow-since", "", "deepen history of shallow repository based on time")
+	unshallow := fs.Bool("unshallow", false, "convert to a complete repository")
+	quiet := fs.Bool("q", false, "be more quiet")
+	fs.BoolVar(quiet, "quiet", false, "be more quiet")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		fs.Usage()
+		return errUsage
+	}
+	if *depth != "" && *deepen != "" {
+		return fmt.Errorf("options '--deepen' and '--depth' cannot be used together")
+	}
+	if *deepen != "" {
+		if _, err := strconv.Atoi(*deepen); err != nil {
+			fmt.Fprintln(os.Stderr, "error: option `deepen' expects a numerical value")
+			return errUsage
+		}
+	}
+	opts, err := fetchOptions(*depth, *deepen, *since, "")
+	if err != nil {
+		return err
+	}
+
+	gitDir, _, err := openRepo()
+	if err != nil {
+		return err
+	}
+	if *unshallow {
+		if !shallow.IsShallow(gitDir) {
+			return fmt.Errorf("--unshallow on a complete repository does not make sense")
+		}
+		opts.Depth = math.MaxInt32
+	}
+
+	// 没有指定远程时使用当前分支的 branch.<name>.remote，默认为 origin
+	name := "origin"
+	if len(positional) == 1 {
+		name = positional[0]
+	} else if branch, err := refs.ResolveName(gitDir, "HEAD"); err == nil {
+		cfg, err := config.Load(gitDir)
+		if err != nil {
+			return err
+		}
+		if v, ok := cfg.Get("branch." + strings.TrimPrefix(branch, "refs/heads/") + ".remote"); ok {
+			name = v
+		}
+	}
+
+	opts.Warnings = os.Stderr
+	res, err := fetch.Fetch(gitDir, name, opts, strings.Join(append([]string{"fetch"}, args...), " "))
+	if err != nil {
+		return err
+	}
+	if !*quiet {
+		writeFetchSummary(res)
+	}
+	for _, u := range res.Updates {
+		if u.Rejected {
+			return exitCode(1)
+		}
+	}
+	return nil
+}
+
+// fetchOptions 解析 clone 和 fetch 共同的选项
+func fetchOptions(depth, deepen, since, filter string) (fetch.Options, error) {
+	var opts fetch.Options
+	if depth != "" {
+		n, err := strconv.Atoi(depth)
+		if err != nil || n <= 0 {
+			return opts, fmt.Errorf("depth %s is not a positive number", depth)
+		}
+		opts.Depth = n
+	}
+	if deepen != "" {
+		opts.Deepen, _ = strconv.Atoi(deepen)
+	}
+	if since != "" {
+		t, err := commit.ParseDate(since)
+		if err != nil {
+			return opts, err
+		}
+		opts.ShallowSince = t
+	}
+	if filter != "" {
+		f, err := fetch.ParseFilter(filter)
+		if err != nil {
+			return opts, err
+		}
+		opts.Filter = f
+	}
+	return opts, nil
+}
+
+// writeFetchSummary 在标准错误上输出 "From <url>" 和每个发生变化的引用，格式与 git fetch 一致
+// 每行依次是标记（"*" 新建、"+" 强制更新、"!" 被拒绝）、摘要（例如 "[new branch]" 或 "1a2b3c4..5d6e7f8"）、
+// 远程引用和本地引用
+func writeFetchSummary(res *fetch.Result) {
+	var lines [][4]string // 标记、摘要、远程引用、本地引用 + 说明
+	width := 10
+	for _, u := range res.Updates {
+		if u.Dst != "" && u.Old == u.New {
+			continue
+		}
+		from, to := refs.ShortName(u.Src), refs.ShortName(u.Dst)
+		var line [4]string
+		switch {
+		case u.Dst == "":
+			kind := "branch"
+			if strings.HasPrefix(u.Src, "refs/tags/") {
+				kind = "tag"
+			}
+			line = [4]string{"*", kind, from, "FETCH_HEAD"}
+		case u.Rejected:
+			line = [4]string{"!", "[rejected]", from, to + "  (non-fast-forward)"}
+		case u.Old.IsZero():
+			kind := "[new ref]"
+			if strings.HasPrefix(u.Src, "refs/heads/") {
+				kind = "[new branch]"
+			} else if strings.HasPrefix(u.Src, "refs/tags/") {
+				kind = "[new tag]"
+			}
+			line = [4]string{"*", kind, from, to}
+		case u.Forced:
+			line = [4]string{"+", u.Old.String()[:7] + "..." + u.New.String()[:7], from, to + "  (forced update)"}
+		default:
+			line = [4]string{" ", u.Old.String()[:7] + ".." + u.New.String()[:7], from, to}
+		}
+		width = max(width, len(from))
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "From %s\n", res.URL)
+	for _, l := range lines {
+		fmt.Fprintf(os.Stderr, " %s %-17s %-*s -> %s\n", l[0], l[1], width, l[2], l[3])
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"geegit/beginner/day6-create-commit/grep"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/matcher"
+	"geegit/beginner/day6-create-commit/revision"
+)
+
+// cmdGrep 实现 `geegit grep`：在工作区、索引或指定的 tree 中搜索文件内容
+// 模式之后的参数在 "--" 之前先按修订解析，解析不了的必须是工作区中存在的路径；
+// 在子目录中运行时只搜索该子目录，路径相对于当前目录输出。没有任何匹配时退出码为 1
+//
+//	geegit grep [-n] [-l | -c] [-i] [-w] [-v] [-E | -F] [-I] [--cached] [--threads <n>]
+//	            (<pattern> | -e <pattern>...) [<tree-ish>...] [[--] <path>...]
+func cmdGrep(args []string) error {
+	fs := newFlags("grep", "[<options>] [-e] <pattern> [<rev>...] [[--] <path>...]")
+	lineNumber := fs.Bool("n", false, "show line numbers")
+	fs.BoolVar(lineNumber, "line-number", false, "show line numbers")
+	names := fs.Bool("l", false, "show only filenames instead of matching lines")
+	fs.BoolVar(names, "files-with-matches", false, "show only filenames instead of matching lines")
+	fs.BoolVar(names, "name-only", false, "synonym for --files-with-matches")
+	count := fs.Bool("c", false, "show the number of matches instead of matching lines")
+	fs.BoolVar(count, "count", false, "show the number of matches instead of matching lines")
+	var opts grep.Options
+	fs.BoolVar(&opts.IgnoreCase, "i", false, "case insensitive matching")
+	fs.BoolVar(&opts.IgnoreCase, "ignore-case", false, "case insensitive matching")
+	fs.BoolVar(&opts.WordRegexp, "w", false, "match patterns only at word boundaries")
+	fs.BoolVar(&opts.WordRegexp, "word-regexp", false, "match patterns only at word boundaries")
+	fs.BoolVar(&opts.Invert, "v", false, "show non-matching lines")
+	fs.BoolVar(&opts.Invert, "invert-match", false, "show non-matching lines")
+	fs.BoolVar(&opts.Extended, "E", false, "use extended POSIX regular expressions")
+	fs.BoolVar(&opts.Extended, "extended-regexp", false, "use extended POSIX regular expressions")
+	fs.BoolVar(&opts.Fixed, "F", false, "interpret patterns as fixed strings")
+	fs.BoolVar(&opts.Fixed, "fixed-strings", false, "interpret patterns as fixed strings")
+	fs.BoolVar(&opts.SkipBinary, "I", false, "don't match patterns in binary files")
+	fs.IntVar(&opts.Threads, "threads", 0, "use <n> worker threads")
+	cached := fs.Bool("cached", false, "search in index instead of in the work tree")
+	var patterns multiFlag
+	fs.Var(&patterns, "e", "match <pattern>")
+
+	// "--" 之后是路径，需要在 parseArgs 之前分开
+	var paths []string
+	dashdash := false
+	for i, arg := range args {
+		if arg == "--" {
+			args, paths, dashdash = args[:i], args[i+1:], true
+			break
+		}
+	}
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	opts.Patterns = patterns
+	if len(opts.Patterns) == 0 && len(positional) > 0 {
+		opts.Patterns, positional = positional[:1], positional[1:]
+	}
+	if len(opts.Patterns) == 0 {
+		return errors.New("no pattern given")
+	}
+
+	// 1. 区分修订和路径
+	gitDir, workDir, err := openRepo()
+	if err != nil {
+		return err
+	}
+	var revs []string
+	for i, arg := range positional {
+		if _, err := revision.Resolve(gitDir, arg); err == nil {
+			revs = append(revs, arg)
+			continue
+		}
+		if !dashdash {
+			for _, p := range positional[i:] {
+				if _, err := os.Lstat(p); err != nil {
+					return fmt.Errorf("ambiguous argument '%s': unknown revision or path not in the working tree.\n"+
+						"Use '--' to separate paths from revisions, like this:\n"+
+						"'git <command> [<revision>...] -- [<file>...]'", p)
+				}
+			}
+			paths = append(positional[i:], paths...)
+			break
+		}
+		return fmt.Errorf("bad revision '%s'", arg)
+	}
+	if *cached && len(revs) > 0 {
+		return errors.New("both --cached and trees are given")
+	}
+	prefix, err := repoPath(workDir, ".")
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		rel, err := repoPath(workDir, p)
+		if err != nil {
+			return err
+		}
+		opts.Paths = append(opts.Paths, rel)
+	}
+	if len(paths) == 0 && prefix != "" {
+		opts.Paths = []string{prefix}
+	}
+	g, err := grep.New(opts)
+	if err != nil {
+		return err
+	}
+	attrs, err := matcher.LoadAttributes(gitDir, workDir)
+	if err != nil {
+		return err
+	}
+
+	// 2. 搜索并输出
+	found := false
+	output := func(name string) func(*grep.Result) error {
+		return func(r *grep.Result) error {
+			found = true
+			p := name + displayPath(prefix, r.Path)
+			switch {
+			case *names:
+				fmt.Println(p)
+			case *count:
+				fmt.Printf("%s:%d\n", p, len(r.Lines))
+			case r.Binary:
+				fmt.Printf("Binary file %s matches\n", p)
+			default:
+				for _, l := range r.Lines {
+					if *lineNumber {
+						fmt.Printf("%s:%d:%s\n", p, l.Number, l.Text)
+					} else {
+						fmt.Printf("%s:%s\n", p, l.Text)
+					}
+				}
+			}
+			return nil
+		}
+	}
+	switch {
+	case len(revs) > 0:
+		for _, rev := range revs {
+			h, _ := revision.Resolve(gitDir, rev)
+			root, err := revision.Peel(gitDir, h, hash.TreeObject)
+			if err != nil {
+				return fmt.Errorf("unable to read tree (%s)", h)
+			}
+			if err := g.Tree(gitDir, root, attrs, output(rev+":")); err != nil {
+				return err
+			}
+		}
+	case *cached:
+		err = g.Index(gitDir, attrs, output(""))
+	default:
+		err = g.Worktree(gitDir, workDir, attrs, output(""))
+	}
+	if err != nil {
+		return err
+	}
+	if !found {
+		return exitCode(1)
+	}
+	return nil
+}
+
+// displayPath 把相对于仓库根目录的路径转换为相对于当前目录（仓库中的 prefix）的路径
+func displayPath(prefix, p string) string {
+	if prefix == "" {
+		return p
+	}
+	rel, err := filepath.Rel(filepath.FromSlash(prefix), filepath.FromSlash(p))
+	if err != nil {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
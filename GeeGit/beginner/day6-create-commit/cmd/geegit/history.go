@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/commitgraph"
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/revision"
+)
+
+// cmdRevList 实现 `geegit rev-list`：按提交时间从新到旧列出 commit
+//
+//	geegit rev-list [--count [--use-bitmap-index]] [-n <n>] [--all] <commit>... [^<commit>...] [<a>..<b>] [-- <path>...]
+func cmdRevList(args []string) error {
+	fs := newFlags("rev-list", "[<options>] <commit>... [--] [<path>...]")
+	count := fs.Bool("count", false, "print the number of commits instead of listing them")
+	maxCount := fs.Int("n", 0, "limit the number of commits to output")
+	fs.IntVar(maxCount, "max-count", 0, "limit the number of commits to output")
+	all := fs.Bool("all", false, "start from all refs")
+	fs.Bool("use-bitmap-index", false, "use bitmap indexes when counting (always on when available)")
+
+	// "--" 之后是路径，需要在 parseArgs 之前分开
+	var paths []string
+	for i, arg := range args {
+		if arg == "--" {
+			args, paths = args[:i], args[i+1:]
+			break
+		}
+	}
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 && !*all {
+		fs.Usage()
+		return errUsage
+	}
+
+	gitDir, workDir, err := openRepo()
+	if err != nil {
+		return err
+	}
+	opts := revision.ListOptions{MaxCount: *maxCount}
+	for _, p := range paths {
+		rel, err := repoPath(workDir, p)
+		if err != nil {
+			return err
+		}
+		opts.Paths = append(opts.Paths, rel)
+	}
+
+	// 1. 解析起点：<rev>、^<rev> 和 <a>..<b>
+	resolve := func(spec string) (hash.Hash, error) {
+		return revision.ResolveType(gitDir, spec, hash.CommitObject)
+	}
+	for _, arg := range positional {
+		if from, to, ok := strings.Cut(arg, ".."); ok {
+			a, err := resolve(from)
+			if err != nil {
+				return err
+			}
+			b, err := resolve(to)
+			if err != nil {
+				return err
+			}
+			opts.Exclude = append(opts.Exclude, a)
+			opts.Include = append(opts.Include, b)
+			continue
+		}
+		exclude := strings.HasPrefix(arg, "^")
+		h, err := resolve(strings.TrimPrefix(arg, "^"))
+		if err != nil {
+			return err
+		}
+		if exclude {
+			opts.Exclude = append(opts.Exclude, h)
+		} else {
+			opts.Include = append(opts.Include, h)
+		}
+	}
+	if *all {
+		list, err := refs.List(gitDir)
+		if err != nil {
+			return err
+		}
+		for _, r := range list {
+			if h, err := revision.Peel(gitDir, r.Hash, hash.CommitObject); err == nil {
+				opts.Include = append(opts.Include, h)
+			}
+		}
+		if h, err := refs.Resolve(gitDir, "HEAD"); err == nil {
+			opts.Include = append(opts.Include, h)
+		}
+	}
+
+	// 2. 遍历并输出；只需要数量时可以使用可达性位图
+	if *count {
+		opts.Warnings = os.Stderr
+		n, err := revision.Count(gitDir, opts)
+		if err != nil {
+			return err
+		}
+		fmt.Println(n)
+		return nil
+	}
+	list, err := revision.List(gitDir, opts)
+	if err != nil {
+		return err
+	}
+	for _, h := range list {
+		fmt.Println(h)
+	}
+	return nil
+}
+
+// cmdMergeBase 实现 `geegit merge-base`：输出两个 commit 的最近共同祖先
+// 没有共同祖先时（或 --is-ancestor 不成立时）退出码为 1
+//
+//	geegit merge-base [-a | --all] <commit> <commit>...
+//	geegit merge-base --is-ancestor <commit> <commit>
+func cmdMergeBase(args []string) error {
+	fs := newFlags("merge-base", "[-a | --all] <commit> <commit>...\n   or: geegit merge-base --is-ancestor <commit> <commit>")
+	all := fs.Bool("a", false, "output all common ancestors")
+	fs.BoolVar(all, "all", false, "output all common ancestors")
+	isAncestor := fs.Bool("is-ancestor", false, "is the first one ancestor of the other?")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 2 || (*isAncestor && (len(positional) != 2 || *all)) {
+		fs.Usage()
+		return errUsage
+	}
+
+	gitDir, _, err := openRepo()
+	if err != nil {
+		return err
+	}
+	commits := make([]hash.Hash, len(positional))
+	for i, arg := range positional {
+		if commits[i], err = revision.ResolveType(gitDir, arg, hash.CommitObject); err != nil {
+			return fmt.Errorf("Not a valid object name %s", arg)
+		}
+	}
+
+	if *isAncestor {
+		ok, err := revision.IsAncestor(gitDir, commits[0], commits[1])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return exitCode(1)
+		}
+		return nil
+	}
+	bases, err := revision.MergeBases(gitDir, commits[0], commits[1:]...)
+	if err != nil {
+		return err
+	}
+	if len(bases) == 0 {
+		return exitCode(1)
+	}
+	if !*all {
+		bases = bases[:1]
+	}
+	for _, h := range bases {
+		fmt.Println(h)
+	}
+	return nil
+}
+
+// cmdCommitGraph 实现 `geegit commit-graph`：生成或检查 objects/info/commit-graph
+//
+//	geegit commit-graph write [--reachable] [--append] [--changed-paths]
+//	geegit commit-graph verify
+func cmdCommitGraph(args []string) error {
+	usage := "usage: geegit commit-graph verify\n" +
+		"   or: geegit commit-graph write [--append] [--reachable] [--changed-paths]"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "error: need a subcommand")
+		fmt.Fprintln(os.Stderr, usage)
+		return errUsage
+	}
+	sub, args := args[0], args[1:]
+
+	fs := newFlags("commit-graph "+sub, "verify | write [--append] [--reachable] [--changed-paths]")
+	var opts commitgraph.Options
+	fs.BoolVar(&opts.Reachable, "reachable", false, "start walk at all refs")
+	fs.BoolVar(&opts.Append, "append", false, "include all commits already in the commit-graph file")
+	fs.BoolVar(&opts.ChangedPaths, "changed-paths", false, "enable computation for changed paths")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	gitDir, _, err := openRepo()
+	if err != nil {
+		return err
+	}
+	switch sub {
+	case "write":
+		cfg, err := config.Load(gitDir)
+		if err != nil {
+			return err
+		}
+		if !cfg.Bool("core.commitgraph", true) {
+			fmt.Fprintln(os.Stderr, "warning: attempting to write a commit-graph, but 'core.commitGraph' is disabled")
+			return nil
+		}
+		return commitgraph.Write(gitDir, opts)
+	case "verify":
+		problems, err := commitgraph.Verify(gitDir)
+		if err != nil {
+			return err
+		}
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		if len(problems) > 0 {
+			return exitCode(1)
+		}
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "error: unknown subcommand: `%s'\n", sub)
+	fmt.Fprintln(os.Stderr, usage)
+	return errUsage
+}
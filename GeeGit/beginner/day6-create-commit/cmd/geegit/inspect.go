@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"geegit/beginner/day6-create-commit/blame"
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/fsck"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/revision"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// cmdBlame 实现 `geegit blame [--porcelain] [<rev>] [--] <file>`
+// 不指定版本时对工作区中的文件做 blame，尚未提交的修改显示为 "Not Committed Yet"
+func cmdBlame(args []string) error {
+	fs := newFlags("blame", "[--porcelain] [<rev>] [--] <file>")
+	porcelain := fs.Bool("porcelain", false, "show in a format designed for machine consumption")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	rev := ""
+	switch len(positional) {
+	case 1:
+	case 2:
+		rev, positional = positional[0], positional[1:]
+	default:
+		fs.Usage()
+		return errUsage
+	}
+
+	gitDir, workDir, err := openRepo()
+	if err != nil {
+		return err
+	}
+	p, err := repoPath(workDir, positional[0])
+	if err != nil {
+		return err
+	}
+	var result *blame.Result
+	if rev != "" {
+		start, err := revision.ResolveType(gitDir, rev, hash.CommitObject)
+		if err != nil {
+			return err
+		}
+		if result, err = blame.Blame(gitDir, start, p); err != nil {
+			return err
+		}
+	} else if result, err = blameWorktree(gitDir, workDir, p); err != nil {
+		return err
+	}
+	if *porcelain {
+		return blame.WritePorcelain(os.Stdout, result)
+	}
+	return blame.WriteHuman(os.Stdout, result)
+}
+
+// blameWorktree 对工作区中的文件做 blame，尚未提交的行显示为 "Not Committed Yet"
+// 与 git 一样，文件必须已经在 HEAD 或索引中
+func blameWorktree(gitDir, workDir, p string) (*blame.Result, error) {
+	head, err := revision.ResolveType(gitDir, "HEAD", hash.CommitObject)
+	if err != nil {
+		return nil, err
+	}
+	c, err := commit.ReadCommit(gitDir, head)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tree.FindEntry(gitDir, c.Tree, p); err != nil {
+		idx, err := index.Read(gitDir)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := idx.Find(p); !ok {
+			return nil, fmt.Errorf("no such path '%s' in HEAD", p)
+		}
+	}
+
+	full := filepath.Join(workDir, filepath.FromSlash(p))
+	info, err := os.Lstat(full)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("Cannot lstat '%s': No such file or directory", p)
+	} else if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(full)
+		if err != nil {
+			return nil, err
+		}
+		data = []byte(filepath.ToSlash(target))
+	} else if data, err = os.ReadFile(full); err != nil {
+		return nil, err
+	}
+	return blame.BlameContents(gitDir, head, p, data)
+}
+
+// cmdFsck 实现 `geegit fsck [--strict] [--json]`，发现错误或缺失对象时退出码为 1
+func cmdFsck(args []string) error {
+	fs := newFlags("fsck", "[--strict] [--json]")
+	strict := fs.Bool("strict", false, "enable more strict checking")
+	asJSON := fs.Bool("json", false, "print one JSON object per problem")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	gitDir, _, err := openRepo()
+	if err != nil {
+		return err
+	}
+	report, err := fsck.Check(gitDir, fsck.Options{Strict: *strict})
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		err = fsck.WriteJSON(os.Stdout, report)
+	} else {
+		err = fsck.WriteHuman(os.Stdout, report)
+	}
+	if err != nil {
+		return err
+	}
+	if report.HasErrors() {
+		return exitCode(1)
+	}
+	return nil
+}
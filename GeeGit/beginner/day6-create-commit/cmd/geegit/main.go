@@ -0,0 +1,188 @@
+// geegit 是 GeeGit 的命令行入口
+// 子命令的参数和输出格式与 git 的同名命令保持一致，可以在任何已有的 Git 仓库中使用
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/repository"
+)
+
+// command 表示一个子命令
+type command struct {
+	run     func(args []string) error
+	summary string
+}
+
+var commands = map[string]command{
+	// 底层命令（plumbing）
+	"init":        {cmdInit, "Create an empty Git repository"},
+	"hash-object": {cmdHashObject, "Compute object ID and optionally create an object from a file"},
+	"cat-file":    {cmdCatFile, "Provide contents or details of repository objects"},
+	"ls-tree":     {cmdLsTree, "List the contents of a tree object"},
+	"write-tree":  {cmdWriteTree, "Create a tree object from the current index"},
+	"commit-tree": {cmdCommitTree, "Create a new commit object"},
+	"update-ref":  {cmdUpdateRef, "Update the object name stored in a ref safely"},
+
+	// 工作区命令
+	"status":          {cmdStatus, "Show the working tree status"},
+	"branch":          {cmdBranch, "List, create, or delete branches"},
+	"switch":          {cmdSwitch, "Switch branches"},
+	"tag":             {cmdTag, "Create, list, delete or verify a tag object"},
+	"stash":           {cmdStash, "Stash the changes in a dirty working directory away"},
+	"worktree":        {cmdWorktree, "Manage multiple working trees"},
+	"submodule":       {cmdSubmodule, "Initialize, update or inspect submodules"},
+	"sparse-checkout": {cmdSparseCheckout, "Reduce your working tree to a subset of tracked files"},
+	"reset":           {cmdReset, "Reset current HEAD to the specified state"},
+	"restore":         {cmdRestore, "Restore working tree files"},
+
+	// 历史命令
+	"rev-list":     {cmdRevList, "Lists commit objects in reverse chronological order"},
+	"merge-base":   {cmdMergeBase, "Find as good common ancestors as possible for a merge"},
+	"commit-graph": {cmdCommitGraph, "Write and verify Git commit-graph files"},
+	"bisect":       {cmdBisect, "Use binary search to find the commit that introduced a bug"},
+	"revert":       {cmdRevert, "Revert some existing commits"},
+
+	// 对象存储命令
+	"multi-pack-index": {cmdMultiPackIndex, "Write and verify multi-pack-indexes"},
+	"repack":           {cmdRepack, "Pack unpacked objects in a repository"},
+
+	// 导出命令
+	"archive":     {cmdArchive, "Create an archive of files from a named tree"},
+	"fast-export": {cmdFastExport, "Git data exporter"},
+	"fast-import": {cmdFastImport, "Backend for fast Git data importers"},
+
+	// 远程命令
+	"clone":  {cmdClone, "Clone a repository into a new directory"},
+	"fetch":  {cmdFetch, "Download objects and refs from another repository"},
+	"push":   {cmdPush, "Update remote refs along with associated objects"},
+	"bundle": {cmdBundle, "Move objects and refs by archive"},
+
+	// 配置命令
+	"config": {cmdConfig, "Get and set repository or global options"},
+	"remote": {cmdRemote, "Manage set of tracked repositories"},
+
+	// 检查命令
+	"grep":          {cmdGrep, "Print lines matching a pattern"},
+	"blame":         {cmdBlame, "Show what revision and author last modified each line of a file"},
+	"fsck":          {cmdFsck, "Verify the connectivity and validity of the objects in the database"},
+	"check-ignore":  {cmdCheckIgnore, "Debug gitignore / exclude files"},
+	"check-attr":    {cmdCheckAttr, "Display gitattributes information"},
+	"verify-commit": {cmdVerifyCommit, "Check the GPG signature of commits"},
+	"verify-tag":    {cmdVerifyTag, "Check the GPG signature of tags"},
+}
+
+// errUsage 表示参数错误（用法说明已经输出过）
+var errUsage = errors.New("invalid usage")
+
+// exitCode 是不需要输出错误信息、只改变退出码的"错误"
+type exitCode int
+
+func (e exitCode) Error() string { return fmt.Sprintf("exit status %d", int(e)) }
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" {
+		usage()
+		os.Exit(1)
+	}
+
+	name := os.Args[1]
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "geegit: '%s' is not a geegit command. See 'geegit --help'.\n", name)
+		os.Exit(1)
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		var code exitCode
+		if errors.As(err, &code) {
+			os.Exit(int(code))
+		}
+		if errors.Is(err, flag.ErrHelp) || errors.Is(err, errUsage) {
+			os.Exit(129)
+		}
+		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+		os.Exit(128)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: geegit <command> [<args>]")
+	fmt.Fprintln(os.Stderr)
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "   %-14s %s\n", name, commands[name].summary)
+	}
+}
+
+// newFlags 创建子命令的参数解析器
+func newFlags(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: geegit %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs 解析参数，允许选项和位置参数交替出现（git 的习惯），"--" 之后全部视为位置参数
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var rest []string
+	for i, arg := range args {
+		if arg == "--" {
+			args, rest = args[:i], args[i+1:]
+			break
+		}
+	}
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// multiFlag 是可以重复出现的字符串选项，例如 commit-tree 的 -p 和 -m
+type multiFlag []string
+
+func (m *multiFlag) String() string     { return strings.Join(*m, ",") }
+func (m *multiFlag) Set(v string) error { *m = append(*m, v); return nil }
+
+// openRepo 从当前目录向上查找仓库，返回 .git 目录和工作区根目录
+func openRepo() (gitDir, workDir string, err error) {
+	return repository.Discover(".")
+}
+
+// repoPath 将命令行上的路径（相对于当前目录）转换为相对于工作区根目录的路径
+func repoPath(workDir, p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(workDir, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: '%s' is outside repository at '%s'", p, p, workDir)
+	}
+	if rel == "." {
+		return "", nil
+	}
+	return filepath.ToSlash(rel), nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"geegit/beginner/day6-create-commit/bitmap"
+	"geegit/beginner/day6-create-commit/pack"
+	"geegit/beginner/day6-create-commit/repack"
+)
+
+// cmdMultiPackIndex 实现 `geegit multi-pack-index`：生成或检查 objects/pack/multi-pack-index
+// --bitmap 同时生成可达性位图，供 rev-list --count 和 fetch / clone 计算对象时使用
+//
+//	geegit multi-pack-index write [--preferred-pack=<pack>] [--bitmap]
+//	geegit multi-pack-index verify
+func cmdMultiPackIndex(args []string) error {
+	usage := "usage: geegit multi-pack-index write [--preferred-pack=<pack>] [--bitmap]\n" +
+		"   or: geegit multi-pack-index verify"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "error: need a subcommand")
+		fmt.Fprintln(os.Stderr, usage)
+		return errUsage
+	}
+	sub, args := args[0], args[1:]
+
+	fs := newFlags("multi-pack-index "+sub, "write [--preferred-pack=<pack>] [--bitmap] | verify")
+	var opts pack.MidxOptions
+	fs.StringVar(&opts.PreferredPack, "preferred-pack", "", "pack for reuse when computing a multi-pack bitmap")
+	writeBitmap := fs.Bool("bitmap", false, "write multi-pack bitmap")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	gitDir, _, err := openRepo()
+	if err != nil {
+		return err
+	}
+	switch sub {
+	case "write":
+		opts.RevIndex = *writeBitmap
+		m, written, err := pack.WriteMultiPackIndex(gitDir, opts)
+		if errors.Is(err, pack.ErrNoPacks) {
+			fmt.Fprintf(os.Stderr, "error: %v.\n", err)
+			return exitCode(1)
+		} else if err != nil || !written {
+			return err
+		}
+		if _, ok := m.FindPack(opts.PreferredPack); opts.PreferredPack != "" && !ok {
+			fmt.Fprintf(os.Stderr, "warning: unknown preferred pack: '%s'\n", opts.PreferredPack)
+		}
+		if *writeBitmap {
+			return bitmap.Write(gitDir, m)
+		}
+		return nil
+	case "verify":
+		problems, err := pack.VerifyMultiPackIndex(gitDir)
+		if err != nil {
+			return err
+		}
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		if len(problems) > 0 {
+			return exitCode(1)
+		}
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "error: unknown subcommand: `%s'\n", sub)
+	fmt.Fprintln(os.Stderr, usage)
+	return errUsage
+}
+
+// cmdRepack 实现 `geegit repack`：把松散对象（-a 时是所有可达对象）打包成一个新的 packfile
+// -a -d 同时删除原有的 packfile，之后仓库不再需要 alternates 中的对象（-l 时除外）
+//
+//	geegit repack [-a] [-d] [-l] [-q]
+func cmdRepack(args []string) error {
+	fs := newFlags("repack", "[<options>]")
+	var opts repack.Options
+	fs.BoolVar(&opts.All, "a", false, "pack everything in a single pack")
+	fs.BoolVar(&opts.Delete, "d", false, "remove redundant packs, and run git-prune-packed")
+	fs.BoolVar(&opts.Local, "l", false, "pass --local to git-pack-objects")
+	fs.BoolVar(&opts.Local, "local", false, "pass --local to git-pack-objects")
+	quiet := fs.Bool("q", false, "be quiet")
+	fs.BoolVar(quiet, "quiet", false, "be quiet")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	gitDir, _, err := openRepo()
+	if err != nil {
+		return err
+	}
+	n, err := repack.Repack(gitDir, opts)
+	if err != nil {
+		return err
+	}
+	if n == 0 && !*quiet {
+		fmt.Println("Nothing new to pack.")
+	}
+	return nil
+}
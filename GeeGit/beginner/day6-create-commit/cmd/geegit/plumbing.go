@@ -0,0 +1,511 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/gpg"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/repository"
+	"geegit/beginner/day6-create-commit/revision"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// cmdInit 实现 `geegit init [-q] [<directory>]`
+func cmdInit(args []string) error {
+	fs := newFlags("init", "[-q] [<directory>]")
+	quiet := fs.Bool("q", false, "only print error and warning messages")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	_, statErr := os.Stat(filepath.Join(abs, ".git", "HEAD"))
+	if err := repository.InitRepository(abs); err != nil {
+		return err
+	}
+
+	if !*quiet {
+		if statErr == nil {
+			fmt.Printf("Reinitialized existing Git repository in %s%c\n", filepath.Join(abs, ".git"), filepath.Separator)
+		} else {
+			fmt.Printf("Initialized empty Git repository in %s%c\n", filepath.Join(abs, ".git"), filepath.Separator)
+		}
+	}
+	return nil
+}
+
+// cmdHashObject 实现 `geegit hash-object [-t <type>] [-w] [--stdin] [<file>...]`
+func cmdHashObject(args []string) error {
+	fs := newFlags("hash-object", "[-t <type>] [-w] [--stdin] [<file>...]")
+	typeName := fs.String("t", "blob", "object type")
+	write := fs.Bool("w", false, "write the object into the object database")
+	stdin := fs.Bool("stdin", false, "read the object from standard input")
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	objType, err := hash.ParseObjectType(*typeName)
+	if err != nil {
+		return err
+	}
+
+	gitDir := ""
+	if *write {
+		if gitDir, _, err = openRepo(); err != nil {
+			return err
+		}
+	}
+
+	hashOne := func(content []byte) error {
+		var h hash.Hash
+		if *write {
+			if h, err = object.Write(gitDir, objType, content); err != nil {
+				return err
+			}
+		} else {
+			h = hash.ComputeHash(objType, content)
+		}
+		fmt.Println(h.String())
+		return nil
+	}
+
+	if *stdin {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		if err := hashOne(content); err != nil {
+			return err
+		}
+	}
+	for _, f := range files {
+		content, err := os.ReadFile(f)
+		if err != nil {
+			return fmt.Errorf("could not open '%s' for reading: %v", f, err)
+		}
+		if err := hashOne(content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cmdCatFile 实现 `geegit cat-file (-t | -s | -e | -p | <type>) <object>` 和 `--batch`/`--batch-check`
+func cmdCatFile(args []string) error {
+	fs := newFlags("cat-file", "(-t | -s | -e | -p | <type>) <object> | --batch | --batch-check")
+	showType := fs.Bool("t", false, "show object type")
+	showSize := fs.Bool("s", false, "show object size")
+	exists := fs.Bool("e", false, "exit with zero when there's no error")
+	pretty := fs.Bool("p", false, "pretty-print object's content")
+	batch := fs.Bool("batch", false, "show info and content of objects fed from the standard input")
+	batchCheck := fs.Bool("batch-check", false, "show info about objects fed from the standard input")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	gitDir, _, err := openRepo()
+	if err != nil {
+		return err
+	}
+
+	if *batch || *batchCheck {
+		return catFileBatch(gitDir, *batch)
+	}
+
+	// <type> <object> 形式：期望对象是指定类型（会自动剥离）
+	var wantType string
+	switch len(positional) {
+	case 1:
+	case 2:
+		wantType, positional = positional[0], positional[1:]
+	default:
+		fs.Usage()
+		return errUsage
+	}
+
+	h, err := revision.Resolve(gitDir, positional[0])
+	if err != nil {
+		if *exists {
+			return exitCode(1)
+		}
+		return fmt.Errorf("Not a valid object name %s", positional[0])
+	}
+	if wantType != "" {
+		t, err := hash.ParseObjectType(wantType)
+		if err != nil {
+			return err
+		}
+		if h, err = revision.Peel(gitDir, h, t); err != nil {
+			return err
+		}
+	}
+
+	obj, err := object.Read(gitDir, h)
+	if err != nil {
+		if *exists {
+			return exitCode(1)
+		}
+		return err
+	}
+
+	switch {
+	case *exists:
+		return nil
+	case *showType:
+		fmt.Println(obj.Type)
+	case *showSize:
+		fmt.Println(len(obj.Content))
+	case *pretty && obj.Type == hash.TreeObject:
+		entries, err := tree.ParseEntries(obj.Content)
+		if err != nil {
+			return err
+		}
+		w := bufio.NewWriter(os.Stdout)
+		for _, e := range entries {
+			writeTreeLine(w, e, e.Name, false)
+		}
+		return w.Flush()
+	default:
+		os.Stdout.Write(obj.Content)
+	}
+	return nil
+}
+
+// catFileBatch 逐行读取标准输入中的对象名，输出 "<hash> <type> <size>"（以及内容）
+func catFileBatch(gitDir string, withContent bool) error {
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		spec := scanner.Text()
+		h, err := revision.Resolve(gitDir, spec)
+		if err != nil {
+			fmt.Fprintf(w, "%s missing\n", spec)
+			continue
+		}
+		obj, err := object.Read(gitDir, h)
+		if err != nil {
+			fmt.Fprintf(w, "%s missing\n", spec)
+			continue
+		}
+
+		fmt.Fprintf(w, "%s %s %d\n", h.String(), obj.Type, len(obj.Content))
+		if withContent {
+			w.Write(obj.Content)
+			w.WriteString("\n")
+		}
+	}
+	return scanner.Err()
+}
+
+// cmdLsTree 实现 `geegit ls-tree [-r] [-t] [-d] [--name-only] <tree-ish> [<path>...]`
+func cmdLsTree(args []string) error {
+	fs := newFlags("ls-tree", "[-r] [-t] [-d] [--name-only] <tree-ish> [<path>...]")
+	recursive := fs.Bool("r", false, "recurse into subtrees")
+	showTrees := fs.Bool("t", false, "show trees when recursing")
+	onlyTrees := fs.Bool("d", false, "only show trees")
+	nameOnly := fs.Bool("name-only", false, "list only filenames")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	gitDir, _, err := openRepo()
+	if err != nil {
+		return err
+	}
+	root, err := revision.ResolveType(gitDir, positional[0], hash.TreeObject)
+	if err != nil {
+		return fmt.Errorf("not a tree object: %v", err)
+	}
+	filters := positional[1:]
+
+	// matches 判断路径是否被过滤条件选中；descend 判断是否需要进入目录继续查找
+	matches := func(p string) bool {
+		if len(filters) == 0 {
+			return true
+		}
+		for _, f := range filters {
+			if f == p || (strings.HasSuffix(f, "/") && strings.HasPrefix(p, f)) || strings.HasPrefix(p, f+"/") {
+				return true
+			}
+		}
+		return false
+	}
+	descend := func(p string) bool {
+		for _, f := range filters {
+			if strings.HasPrefix(f, p+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	var list func(h hash.Hash, prefix string) error
+	list = func(h hash.Hash, prefix string) error {
+		t, err := tree.ReadTree(gitDir, h)
+		if err != nil {
+			return err
+		}
+		for _, e := range t.Entries {
+			p := prefix + e.Name
+			if !matches(p) {
+				if e.IsDir() && descend(p) {
+					if err := list(e.Hash, p+"/"); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			if e.IsDir() && (*recursive || descend(p)) {
+				if *showTrees || *onlyTrees {
+					writeTreeLine(w, e, p, *nameOnly)
+				}
+				if err := list(e.Hash, p+"/"); err != nil {
+					return err
+				}
+				continue
+			}
+			if *onlyTrees && !e.IsDir() {
+				continue
+			}
+			writeTreeLine(w, e, p, *nameOnly)
+		}
+		return nil
+	}
+
+	if err := list(root, ""); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// writeTreeLine 输出一个 tree 条目: <mode> SP <type> SP <hash> TAB <path>
+func writeTreeLine(w io.Writer, e tree.TreeEntry, p string, nameOnly bool) {
+	if nameOnly {
+		fmt.Fprintln(w, p)
+		return
+	}
+	objType := "blob"
+	switch {
+	case e.IsDir():
+		objType = "tree"
+	case e.IsSubmodule():
+		objType = "commit"
+	}
+	mode := strings.Repeat("0", max(0, 6-len(e.Mode))) + e.Mode
+	fmt.Fprintf(w, "%s %s %s\t%s\n", mode, objType, e.Hash.String(), p)
+}
+
+// cmdWriteTree 实现 `geegit write-tree`：把索引的当前内容写成 tree 对象
+// 索引中有冲突时失败；git add -N 添加的条目不会写入
+func cmdWriteTree(args []string) error {
+	fs := newFlags("write-tree", "")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	gitDir, _, err := openRepo()
+	if err != nil {
+		return err
+	}
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		return err
+	}
+	h, err := tree.WriteIndex(gitDir, idx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return errors.New("git-write-tree: error building trees")
+	}
+	fmt.Println(h.String())
+	return nil
+}
+
+// cmdCommitTree 实现 `geegit commit-tree <tree> [-p <parent>]... [-m <message>]... [-F <file>] [-S[<key>]]`
+// 没有 -m 和 -F 时从标准输入读取提交信息
+func cmdCommitTree(args []string) error {
+	fs := newFlags("commit-tree", "<tree> [(-p <parent>)...] [(-m <message>)...] [(-F <file>)...] [-S[<key>]]")
+	var parents, messages, files multiFlag
+	fs.Var(&parents, "p", "id of a parent commit object")
+	fs.Var(&messages, "m", "commit message")
+	fs.Var(&files, "F", "read commit log message from file")
+	sign := &signFlag{}
+	fs.Var(sign, "S", "GPG sign commit")
+	fs.Var(sign, "gpg-sign", "GPG sign commit")
+	fs.BoolFunc("no-gpg-sign", "do not GPG sign commit", func(string) error { return sign.Set("false") })
+	expandShortValue(args, "S")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	gitDir, _, err := openRepo()
+	if err != nil {
+		return err
+	}
+
+	c := &commit.Commit{}
+	if c.Tree, err = revision.ResolveType(gitDir, positional[0], hash.TreeObject); err != nil {
+		return err
+	}
+	for _, p := range parents {
+		h, err := revision.ResolveType(gitDir, p, hash.CommitObject)
+		if err != nil {
+			return fmt.Errorf("not a valid object name %s", p)
+		}
+		c.Parents = append(c.Parents, h)
+	}
+
+	// 多个 -m 各自成为一段，段落之间用空行分隔
+	var paragraphs []string
+	for _, m := range messages {
+		paragraphs = append(paragraphs, strings.TrimRight(m, "\n")+"\n")
+	}
+	for _, f := range files {
+		data, err := readFileOrStdin(f)
+		if err != nil {
+			return err
+		}
+		paragraphs = append(paragraphs, string(data))
+	}
+	if len(messages) == 0 && len(files) == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		paragraphs = append(paragraphs, string(data))
+	}
+	c.Message = strings.Join(paragraphs, "\n")
+
+	if c.Author, err = commit.DefaultSignature(gitDir, "author"); err != nil {
+		return err
+	}
+	if c.Committer, err = commit.DefaultSignature(gitDir, "committer"); err != nil {
+		return err
+	}
+
+	// 签名的是不含 gpgsig 头部的 commit 内容
+	if sign.enabled {
+		cfg, err := config.Load(gitDir)
+		if err != nil {
+			return err
+		}
+		if _, err := gpg.ConfiguredFormat(cfg); err != nil {
+			return err
+		}
+		if c.GPGSig, err = gpg.Sign(cfg, commit.Encode(c), sign.key); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return exitCode(1)
+		}
+	}
+
+	h, err := commit.WriteCommit(gitDir, c)
+	if err != nil {
+		return err
+	}
+	fmt.Println(h.String())
+	return nil
+}
+
+// cmdUpdateRef 实现 `geegit update-ref [-m <reason>] <ref> <new> [<old>]` 和 `geegit update-ref -d <ref> [<old>]`
+func cmdUpdateRef(args []string) error {
+	fs := newFlags("update-ref", "[-m <reason>] (-d <ref> [<old>] | <ref> <new> [<old>])")
+	del := fs.Bool("d", false, "delete the reference")
+	msg := fs.String("m", "", "reason of the update, recorded in the reflog")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	gitDir, _, err := openRepo()
+	if err != nil {
+		return err
+	}
+
+	// resolveOld 解析 <old>：空字符串或全零哈希表示引用必须不存在
+	resolveOld := func(spec string) (*hash.Hash, error) {
+		if spec == "" || strings.Trim(spec, "0") == "" {
+			return &hash.Hash{}, nil
+		}
+		h, err := revision.Resolve(gitDir, spec)
+		if err != nil {
+			return nil, err
+		}
+		return &h, nil
+	}
+
+	if *del {
+		if len(positional) < 1 || len(positional) > 2 {
+			fs.Usage()
+			return errUsage
+		}
+		var old *hash.Hash
+		if len(positional) == 2 {
+			if old, err = resolveOld(positional[1]); err != nil {
+				return err
+			}
+		}
+		return refs.Delete(gitDir, positional[0], old)
+	}
+
+	if len(positional) < 2 || len(positional) > 3 {
+		fs.Usage()
+		return errUsage
+	}
+	newHash, err := revision.Resolve(gitDir, positional[1])
+	if err != nil {
+		return fmt.Errorf("%s: not a valid SHA1", positional[1])
+	}
+	if !object.Exists(gitDir, newHash) {
+		return fmt.Errorf("trying to write ref '%s' with nonexistent object %s", positional[0], newHash.String())
+	}
+	var old *hash.Hash
+	if len(positional) == 3 {
+		if old, err = resolveOld(positional[2]); err != nil {
+			return err
+		}
+	}
+	return refs.Update(gitDir, positional[0], newHash, old, *msg)
+}
+
+// readFileOrStdin 读取文件内容，文件名为 "-" 时读取标准输入
+func readFileOrStdin(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
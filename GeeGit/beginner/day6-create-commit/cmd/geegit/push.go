@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/hooks"
+	"geegit/beginner/day6-create-commit/push"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/remote"
+)
+
+// cmdPush 实现 `geegit push`：把本地分支推送到远程仓库，目前只支持本地仓库
+// 没有指定远程时依次使用 branch.<name>.pushRemote、remote.pushDefault、branch.<name>.remote，默认为 origin；
+// 没有指定 refspec 时按 push.default（缺省为 simple）推送当前分支
+//
+//	geegit push [-f | --force] [--no-verify] [-o <option>]... [<repository> [<refspec>...]]
+func cmdPush(args []string) error {
+	fs := newFlags("push", "[<options>] [<repository> [<refspec>...]]")
+	force := fs.Bool("f", false, "force updates")
+	fs.BoolVar(force, "force", false, "force updates")
+	noVerify := fs.Bool("no-verify", false, "bypass pre-push hook")
+	var pushOptions multiFlag
+	fs.Var(&pushOptions, "o", "option to transmit")
+	fs.Var(&pushOptions, "push-option", "option to transmit")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	gitDir, workDir, err := openRepo()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return err
+	}
+	branch := ""
+	if name, err := refs.ResolveName(gitDir, "HEAD"); err == nil && strings.HasPrefix(name, "refs/heads/") {
+		branch = strings.TrimPrefix(name, "refs/heads/")
+	}
+
+	// 1. 远程：没有配置时直接把参数当作仓库地址
+	name := pushRemote(cfg, branch)
+	if len(positional) > 0 {
+		name = positional[0]
+	}
+	r, err := remote.Get(cfg, name)
+	if errors.Is(err, remote.ErrNotFound) {
+		r = &remote.Remote{Name: name, URLs: []string{name}}
+	} else if err != nil {
+		return err
+	}
+
+	// 2. refspec
+	var specs []remote.RefSpec
+	if len(positional) > 1 {
+		for _, s := range positional[1:] {
+			spec, err := remote.ParseRefSpec(s)
+			if err != nil {
+				return err
+			}
+			specs = append(specs, spec)
+		}
+	} else if specs, err = defaultPushSpecs(cfg, r.Name, branch); err != nil {
+		return err
+	}
+
+	// 3. 推送，接收端的输出加上 "remote: " 前缀
+	out := &remoteWriter{w: os.Stderr}
+	opts := push.Options{Force: *force, NoVerify: *noVerify, PushOptions: pushOptions, Remote: out, Hints: os.Stderr}
+	res, err := push.Push(gitDir, workDir, r, specs, opts)
+	out.Flush()
+	var specErr *push.SpecError
+	var exitErr *hooks.ExitError
+	switch {
+	case errors.As(err, &specErr):
+		fmt.Fprintf(os.Stderr, "error: %v\n%serror: failed to push some refs to '%s'\n", err, specErr.Hint, push.URL(r))
+		return exitCode(1)
+	case errors.As(err, &exitErr):
+		fmt.Fprintf(os.Stderr, "error: failed to push some refs to '%s'\n", push.URL(r))
+		return exitCode(1)
+	case err != nil:
+		return err
+	}
+	if !writePushSummary(res, branch) {
+		return exitCode(1)
+	}
+	return nil
+}
+
+// pushRemote 返回没有指定远程时推送使用的远程名称
+func pushRemote(cfg *config.Config, branch string) string {
+	if branch != "" {
+		if v, ok := cfg.Get("branch." + branch + ".pushRemote"); ok {
+			return v
+		}
+	}
+	if v, ok := cfg.Get("remote.pushDefault"); ok {
+		return v
+	}
+	if branch != "" {
+		if v, ok := cfg.Get("branch." + branch + ".remote"); ok {
+			return v
+		}
+	}
+	return "origin"
+}
+
+// defaultPushSpecs 按 push.default 返回没有指定 refspec 时推送的内容，错误信息与 git 一致
+//
+//	simple    推送当前分支；推送到它的上游所在的远程时，上游必须是同名分支（缺省）
+//	current   推送当前分支到同名分支
+//	upstream  推送当前分支到它的上游
+//	matching  推送远程也有的所有同名分支
+//	nothing   不推送，报错
+func defaultPushSpecs(cfg *config.Config, remoteName, branch string) ([]remote.RefSpec, error) {
+	mode, _ := cfg.Get("push.default")
+	switch mode {
+	case "matching":
+		return []remote.RefSpec{{}}, nil
+	case "nothing":
+		return nil, errors.New(`You didn't specify any refspecs to push, and push.default is "nothing".`)
+	}
+	if branch == "" {
+		return nil, fmt.Errorf("You are not currently on a branch.\n"+
+			"To push the history leading to the current (detached HEAD)\n"+
+			"state now, use\n\n    git push %s HEAD:<name-of-remote-branch>\n", remoteName)
+	}
+	ref := "refs/heads/" + branch
+	upstreamRemote, ok := cfg.Get("branch." + branch + ".remote")
+	if !ok {
+		upstreamRemote = "origin"
+	}
+	if mode == "current" || mode != "upstream" && upstreamRemote != remoteName {
+		return []remote.RefSpec{{Src: ref, Dst: ref}}, nil
+	}
+
+	merge, ok := cfg.Get("branch." + branch + ".merge")
+	if !ok || upstreamRemote != remoteName {
+		return nil, fmt.Errorf("The current branch %s has no upstream branch.\n"+
+			"To push the current branch and set the remote as upstream, use\n\n"+
+			"    git push --set-upstream %s %s\n\n"+
+			"To have this happen automatically for branches without a tracking\n"+
+			"upstream, see 'push.autoSetupRemote' in 'git help config'.\n", branch, remoteName, branch)
+	}
+	if mode != "upstream" && merge != ref {
+		return nil, fmt.Errorf("The upstream branch of your current branch does not match\n"+
+			"the name of your current branch.  To push to the upstream branch\n"+
+			"on the remote, use\n\n"+
+			"    git push %s HEAD:%s\n\n"+
+			"To push to the branch of the same name on the remote, use\n\n"+
+			"    git push %s HEAD\n\n"+
+			"To choose either option permanently, see push.default in 'git help config'.\n\n"+
+			"To avoid automatically configuring an upstream branch when its name\n"+
+			"won't match the local branch, see option 'simple' of branch.autoSetupMerge\n"+
+			"in 'git help config'.\n", remoteName, refs.ShortName(merge), remoteName)
+	}
+	return []remote.RefSpec{{Src: ref, Dst: merge}}, nil
+}
+
+// 推送被拒绝时的提示，与 git 相同；只给出最重要的一种
+const (
+	hintNonFFHead = "hint: Updates were rejected because the tip of your current branch is behind\n" +
+		"hint: its remote counterpart. Integrate the remote changes (e.g.\n" +
+		"hint: 'git pull ...') before pushing again.\n" +
+		"hint: See the 'Note about fast-forwards' in 'git push --help' for details.\n"
+	hintNonFF = "hint: Updates were rejected because a pushed branch tip is behind its remote\n" +
+		"hint: counterpart. Check out this branch and integrate the remote changes\n" +
+		"hint: (e.g. 'git pull ...') before pushing again.\n" +
+		"hint: See the 'Note about fast-forwards' in 'git push --help' for details.\n"
+	hintFetchFirst = "hint: Updates were rejected because the remote contains work that you do\n" +
+		"hint: not have locally. This is usually caused by another repository pushing\n" +
+		"hint: to the same ref. You may want to first integrate the remote changes\n" +
+		"hint: (e.g., 'git pull ...') before pushing again.\n" +
+		"hint: See the 'Note about fast-forwards' in 'git push --help' for details.\n"
+	hintAlreadyExists = "hint: Updates were rejected because the tag already exists in the remote.\n"
+)
+
+// writePushSummary 在标准错误上输出 "To <url>" 和每个引用的结果，格式与 git push 一致：
+// 先输出成功的更新，再输出被拒绝的；全部已是最新时输出 "Everything up-to-date"。
+// 有被拒绝的更新时输出错误和提示，返回 false
+func writePushSummary(res *push.Result, branch string) bool {
+	var ok, failed []string
+	var nonFFHead, nonFF, fetchFirst, alreadyExists bool
+	for _, u := range res.Updates {
+		to := refs.ShortName(u.Dst)
+		from := refs.ShortName(u.Src) + " -> " + to
+		var line string
+		switch {
+		case u.Rejected != "":
+			line = fmt.Sprintf(" ! %-17s %s (%s)", "[rejected]", from, u.Rejected)
+			switch u.Rejected {
+			case "non-fast-forward":
+				if u.Dst == "refs/heads/"+branch {
+					nonFFHead = true
+				} else {
+					nonFF = true
+				}
+			case "fetch first":
+				fetchFirst = true
+			case "already exists":
+				alreadyExists = true
+			}
+		case u.RemoteRejected != "":
+			if u.New.IsZero() {
+				from = to
+			}
+			line = fmt.Sprintf(" ! %-17s %s (%s)", "[remote rejected]", from, u.RemoteRejected)
+		case u.Old == u.New:
+			continue
+		case u.New.IsZero():
+			line = fmt.Sprintf(" - %-17s %s", "[deleted]", to)
+		case u.Old.IsZero():
+			kind := "[new reference]"
+			if strings.HasPrefix(u.Dst, "refs/heads/") {
+				kind = "[new branch]"
+			} else if strings.HasPrefix(u.Dst, "refs/tags/") {
+				kind = "[new tag]"
+			}
+			line = fmt.Sprintf(" * %-17s %s", kind, from)
+		case u.Forced:
+			line = fmt.Sprintf(" + %-17s %s (forced update)", u.Old.String()[:7]+"..."+u.New.String()[:7], from)
+		default:
+			line = fmt.Sprintf("   %-17s %s", u.Old.String()[:7]+".."+u.New.String()[:7], from)
+		}
+		if u.OK() {
+			ok = append(ok, line)
+		} else {
+			failed = append(failed, line)
+		}
+	}
+	if len(ok) == 0 && len(failed) == 0 {
+		fmt.Fprintln(os.Stderr, "Everything up-to-date")
+		return true
+	}
+	fmt.Fprintf(os.Stderr, "To %s\n", res.URL)
+	for _, line := range append(ok, failed...) {
+		fmt.Fprintln(os.Stderr, line)
+	}
+	if len(failed) == 0 {
+		return true
+	}
+	fmt.Fprintf(os.Stderr, "error: failed to push some refs to '%s'\n", res.URL)
+	switch {
+	case nonFFHead:
+		io.WriteString(os.Stderr, hintNonFFHead)
+	case nonFF:
+		io.WriteString(os.Stderr, hintNonFF)
+	case fetchFirst:
+		io.WriteString(os.Stderr, hintFetchFirst)
+	case alreadyExists:
+		io.WriteString(os.Stderr, hintAlreadyExists)
+	}
+	return false
+}
+
+// remoteWriter 按 git 转发接收端输出的格式输出：每行加上 "remote: " 前缀，
+// 非空行末尾补上 8 个空格（git 在非终端上用它们覆盖上一行剩余的内容）
+type remoteWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (r *remoteWriter) Write(p []byte) (int, error) {
+	r.buf = append(r.buf, p...)
+	for {
+		i := bytes.IndexByte(r.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		r.writeLine(r.buf[:i])
+		r.buf = r.buf[i+1:]
+	}
+}
+
+// Flush 输出最后一行不以换行结尾的内容
+func (r *remoteWriter) Flush() {
+	if len(r.buf) > 0 {
+		r.writeLine(r.buf)
+		r.buf = nil
+	}
+}
+
+func (r *remoteWriter) writeLine(line []byte) {
+	if len(line) == 0 {
+		fmt.Fprintln(r.w, "remote: ")
+		return
+	}
+	fmt.Fprintf(r.w, "remote: %s        \n", line)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/remote"
+)
+
+// cmdRemote 实现 `geegit remote`：管理 .git/config 中的远程定义
+//
+//	geegit remote [-v]
+//	geegit remote add <name> <url>
+//	geegit remote remove|rm <name>
+//	geegit remote get-url [--push] [--all] <name>
+//	geegit remote set-url [--push] [--add] <name> <url>
+func cmdRemote(args []string) error {
+	sub := ""
+	if len(args) > 0 && args[0] != "-v" && args[0] != "--verbose" {
+		sub, args = args[0], args[1:]
+	}
+
+	fs := newFlags("remote "+sub, "[-v] | add <name> <url> | remove <name> | get-url <name> | set-url <name> <url>")
+	verbose := fs.Bool("v", false, "be verbose")
+	fs.BoolVar(verbose, "verbose", false, "be verbose")
+	push := fs.Bool("push", false, "query or set push URLs")
+	all := fs.Bool("all", false, "return all URLs")
+	add := fs.Bool("add", false, "add URL")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	usage := func(n int) error {
+		if len(positional) != n {
+			fs.Usage()
+			return errUsage
+		}
+		return nil
+	}
+
+	gitDir, _, err := openRepo()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return err
+	}
+
+	switch sub {
+	case "":
+		for _, r := range remote.List(cfg) {
+			if !*verbose {
+				fmt.Println(r.Name)
+				continue
+			}
+			fmt.Printf("%s\t%s (fetch)\n", r.Name, r.FetchURL())
+			for _, u := range r.PushURLs {
+				fmt.Printf("%s\t%s (push)\n", r.Name, u)
+			}
+		}
+		return nil
+
+	case "add":
+		if err := usage(2); err != nil {
+			return err
+		}
+		if err := remote.CheckName(positional[0]); err != nil {
+			return err
+		}
+		if _, err := remote.Get(cfg, positional[0]); err == nil {
+			fmt.Fprintf(os.Stderr, "error: remote %s already exists.\n", positional[0])
+			return exitCode(3)
+		}
+		return remote.Add(gitDir, positional[0], positional[1])
+
+	case "remove", "rm":
+		if err := usage(1); err != nil {
+			return err
+		}
+		err := remote.Remove(gitDir, positional[0])
+		if errors.Is(err, remote.ErrNotFound) {
+			fmt.Fprintf(os.Stderr, "error: No such remote: '%s'\n", positional[0])
+			return exitCode(2)
+		}
+		return err
+
+	case "get-url":
+		if err := usage(1); err != nil {
+			return err
+		}
+		r, err := remote.Get(cfg, positional[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: No such remote '%s'\n", positional[0])
+			return exitCode(2)
+		}
+		urls := r.URLs
+		if *push {
+			urls = r.PushURLs
+		}
+		if !*all && len(urls) > 0 {
+			urls = urls[:1]
+		}
+		for _, u := range urls {
+			fmt.Println(u)
+		}
+		return nil
+
+	case "set-url":
+		if err := usage(2); err != nil {
+			return err
+		}
+		err := remote.SetURL(gitDir, positional[0], positional[1], *push, *add)
+		if errors.Is(err, remote.ErrNotFound) {
+			fmt.Fprintf(os.Stderr, "error: No such remote '%s'\n", positional[0])
+			return exitCode(2)
+		}
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "error: unknown subcommand: `%s'\n", sub)
+	return errUsage
+}
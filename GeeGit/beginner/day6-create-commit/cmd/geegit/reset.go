@@ -0,0 +1,263 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"geegit/beginner/day6-create-commit/branch"
+	"geegit/beginner/day6-create-commit/checkout"
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/revision"
+	"geegit/beginner/day6-create-commit/status"
+)
+
+// cmdReset 实现 `geegit reset`：把当前分支（或分离的 HEAD）移动到指定的 commit
+// --soft 只移动 HEAD，--mixed（缺省）同时重置索引，--hard 还重置工作区；
+// 给出路径时只把这些路径的索引条目重置为 <tree-ish> 中的版本，HEAD 不动
+//
+//	geegit reset [--soft | --mixed | --hard] [-q] [<commit>]
+//	geegit reset [-q] [<tree-ish>] [--] <pathspec>...
+func cmdReset(args []string) error {
+	fs := newFlags("reset", "[--soft | --mixed | --hard] [-q] [<commit>] | [<tree-ish>] [--] <pathspec>...")
+	soft := fs.Bool("soft", false, "reset only HEAD")
+	fs.Bool("mixed", false, "reset HEAD and index (default)")
+	hard := fs.Bool("hard", false, "reset HEAD, index and working tree")
+	quiet := fs.Bool("q", false, "be quiet, only report errors")
+	fs.BoolVar(quiet, "quiet", false, "be quiet, only report errors")
+
+	// "--" 之后是路径，需要在 parseArgs 之前分开
+	var paths []string
+	dashdash := false
+	for i, arg := range args {
+		if arg == "--" {
+			args, paths, dashdash = args[:i], args[i+1:], true
+			break
+		}
+	}
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	mode := "mixed"
+	switch {
+	case *hard:
+		mode = "hard"
+	case *soft:
+		mode = "soft"
+	}
+
+	gitDir, workDir, err := openRepo()
+	if err != nil {
+		return err
+	}
+
+	// 1. 区分修订和路径：没有 "--" 时，第一个参数不是修订就全部当作路径
+	rev := "HEAD"
+	switch {
+	case dashdash && len(positional) > 1:
+		fs.Usage()
+		return errUsage
+	case dashdash && len(positional) == 1:
+		rev = positional[0]
+		if _, err := revision.ResolveType(gitDir, rev, hash.TreeObject); err != nil {
+			return fmt.Errorf("Failed to resolve '%s' as a valid revision.", rev)
+		}
+	case len(positional) > 0:
+		if _, err := revision.ResolveType(gitDir, positional[0], hash.TreeObject); err == nil {
+			rev, positional = positional[0], positional[1:]
+		}
+		for _, p := range positional {
+			if _, err := os.Lstat(p); err != nil {
+				return fmt.Errorf("ambiguous argument '%s': unknown revision or path not in the working tree.\n"+
+					"Use '--' to separate paths from revisions, like this:\n"+
+					"'git <command> [<revision>...] -- [<file>...]'", p)
+			}
+		}
+		paths = append(positional, paths...)
+	}
+
+	// 2. 只重置部分路径的索引条目
+	if len(paths) > 0 {
+		if mode != "mixed" {
+			return fmt.Errorf("Cannot do %s reset with paths.", mode)
+		}
+		var root hash.Hash
+		if _, err := refs.Resolve(gitDir, "HEAD"); err == nil || rev != "HEAD" {
+			if root, err = revision.ResolveType(gitDir, rev, hash.TreeObject); err != nil {
+				return err
+			}
+		}
+		var specs []string
+		for _, p := range paths {
+			rel, err := repoPath(workDir, p)
+			if err != nil {
+				return err
+			}
+			specs = append(specs, rel)
+		}
+		if err := checkout.Reset(gitDir, workDir, root, specs, false); err != nil {
+			return err
+		}
+		if !*quiet {
+			return printUnstaged(gitDir, workDir)
+		}
+		return nil
+	}
+
+	// 3. 目标 commit；尚无提交时 `reset` 清空索引
+	oldHead, headErr := refs.Resolve(gitDir, "HEAD")
+	var target hash.Hash
+	var targetTree hash.Hash
+	if headErr == nil || rev != "HEAD" {
+		if target, err = revision.ResolveType(gitDir, rev, hash.CommitObject); err != nil {
+			return fmt.Errorf("ambiguous argument '%s': unknown revision or path not in the working tree.\n"+
+				"Use '--' to separate paths from revisions, like this:\n"+
+				"'git <command> [<revision>...] -- [<file>...]'", rev)
+		}
+		c, err := commit.ReadCommit(gitDir, target)
+		if err != nil {
+			return err
+		}
+		targetTree = c.Tree
+	}
+
+	// 4. 重置索引和工作区
+	switch mode {
+	case "soft":
+		idx, err := index.Read(gitDir)
+		if err != nil {
+			return err
+		}
+		for _, e := range idx.Entries {
+			if e.Stage != 0 {
+				return errors.New("Cannot do a soft reset in the middle of a merge.")
+			}
+		}
+	case "mixed", "hard":
+		if err := checkout.Reset(gitDir, workDir, targetTree, nil, mode == "hard"); err != nil {
+			return err
+		}
+	}
+
+	// 5. 移动 HEAD，原来的位置记录在 ORIG_HEAD 中；进行中的合并或 revert 随之结束
+	if !target.IsZero() {
+		if headErr == nil {
+			if err := os.WriteFile(filepath.Join(gitDir, "ORIG_HEAD"), []byte(oldHead.String()+"\n"), 0644); err != nil {
+				return err
+			}
+		}
+		if err := refs.Update(gitDir, "HEAD", target, nil, "reset: moving to "+rev); err != nil {
+			return err
+		}
+	}
+	if err := branch.RemoveState(gitDir); err != nil {
+		return err
+	}
+
+	// 6. 输出
+	switch {
+	case *quiet:
+	case mode == "hard" && !target.IsZero():
+		c, err := commit.ReadCommit(gitDir, target)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("HEAD is now at %s %s\n", target.String()[:7], subjectOf(c))
+	case mode == "mixed":
+		return printUnstaged(gitDir, workDir)
+	}
+	return nil
+}
+
+// printUnstaged 列出重置之后工作区中与索引不同的被跟踪文件（git reset 的 "Unstaged changes after reset:"）
+func printUnstaged(gitDir, workDir string) error {
+	st, err := status.Compute(gitDir, workDir, status.Options{HideUntracked: true})
+	if err != nil {
+		return err
+	}
+	header := false
+	for _, f := range st.Files {
+		code := f.Worktree
+		if f.IsConflicted() {
+			code = status.Unmerged
+		} else if !f.IsModified() {
+			continue
+		}
+		if !header {
+			fmt.Println("Unstaged changes after reset:")
+			header = true
+		}
+		fmt.Printf("%c\t%s\n", code, f.Path)
+	}
+	return nil
+}
+
+// cmdRestore 实现 `geegit restore`：把指定的文件恢复为索引（缺省）或 --source 中的版本
+// 缺省只恢复工作区；--staged 恢复索引，此时来源缺省为 HEAD
+//
+//	geegit restore [--source=<tree>] [--staged] [--worktree] [-q] [--] <pathspec>...
+func cmdRestore(args []string) error {
+	fs := newFlags("restore", "[--source=<tree>] [--staged] [--worktree] [--] <pathspec>...")
+	source := fs.String("s", "", "which tree-ish to checkout from")
+	fs.StringVar(source, "source", "", "which tree-ish to checkout from")
+	staged := fs.Bool("S", false, "restore the index")
+	fs.BoolVar(staged, "staged", false, "restore the index")
+	worktree := fs.Bool("W", false, "restore the working tree (default)")
+	fs.BoolVar(worktree, "worktree", false, "restore the working tree (default)")
+	quiet := fs.Bool("q", false, "suppress progress reporting")
+	fs.BoolVar(quiet, "quiet", false, "suppress progress reporting")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return errors.New("you must specify path(s) to restore")
+	}
+	gitDir, workDir, err := openRepo()
+	if err != nil {
+		return err
+	}
+
+	// 1. 来源：--source，恢复索引时缺省为 HEAD，否则为索引
+	opts := checkout.RestoreOptions{Staged: *staged, Worktree: *worktree}
+	if *source == "" && *staged {
+		*source = "HEAD"
+	}
+	if *source != "" {
+		if opts.Source, err = revision.ResolveType(gitDir, *source, hash.TreeObject); err != nil {
+			return fmt.Errorf("could not resolve %s", *source)
+		}
+	}
+
+	// 2. 路径相对于当前目录
+	var specs []string
+	original := make(map[string]string)
+	for _, p := range positional {
+		rel, err := repoPath(workDir, p)
+		if err != nil {
+			return err
+		}
+		specs = append(specs, rel)
+		original[rel] = p
+	}
+
+	// 3. 恢复
+	err = checkout.Restore(gitDir, workDir, specs, opts)
+	var pathspecErr *checkout.PathspecError
+	var unmergedErr *checkout.UnmergedError
+	switch {
+	case errors.As(err, &pathspecErr):
+		pathspecErr.Path = original[pathspecErr.Path]
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitCode(1)
+	case errors.As(err, &unmergedErr):
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitCode(1)
+	}
+	return err
+}
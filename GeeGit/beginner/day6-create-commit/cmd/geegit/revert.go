@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"geegit/beginner/day6-create-commit/branch"
+	"geegit/beginner/day6-create-commit/checkout"
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/diff"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/hooks"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/revert"
+	"geegit/beginner/day6-create-commit/revision"
+	"geegit/beginner/day6-create-commit/status"
+)
+
+// cmdRevert 实现 `geegit revert`：创建一个撤销指定 commit 的新 commit
+// 有冲突时停下来，解决之后用 --continue 提交，或用 --abort（--skip）放弃
+//
+// 没有指定 --edit/--no-edit 时，与 git 一样只在标准输入是终端时打开编辑器修改说明
+//
+//	geegit revert [--[no-]edit] [-n] [-m <parent-number>] <commit>
+//	geegit revert (--continue | --skip | --abort)
+func cmdRevert(args []string) error {
+	fs := newFlags("revert", "[--[no-]edit] [-n] [-m <parent-number>] <commit> | --continue | --skip | --abort")
+	edit := fs.Bool("e", false, "edit the commit message")
+	fs.BoolVar(edit, "edit", false, "edit the commit message")
+	noEdit := fs.Bool("no-edit", false, "don't edit the commit message")
+	noCommit := fs.Bool("n", false, "don't automatically commit")
+	fs.BoolVar(noCommit, "no-commit", false, "don't automatically commit")
+	mainline := fs.Int("m", 0, "select mainline parent")
+	fs.IntVar(mainline, "mainline", 0, "select mainline parent")
+	cont := fs.Bool("continue", false, "resume revert after resolving conflicts")
+	skip := fs.Bool("skip", false, "skip current commit and continue")
+	abort := fs.Bool("abort", false, "cancel revert")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	gitDir, workDir, err := openRepo()
+	if err != nil {
+		return err
+	}
+	opts := revert.Options{Mainline: *mainline, NoCommit: *noCommit, Hints: os.Stderr}
+	if *edit || !*noEdit && isTerminal(os.Stdin) {
+		opts.Edit = messageEditor(gitDir, workDir)
+	}
+
+	// 1. 继续或放弃进行中的 revert
+	if *cont || *skip || *abort {
+		if len(positional) > 0 {
+			fs.Usage()
+			return errUsage
+		}
+		if *cont {
+			res, err := revert.Continue(gitDir, workDir, opts)
+			switch {
+			case errors.Is(err, revert.ErrUnresolved):
+				fmt.Fprintln(os.Stderr, "error: Committing is not possible because you have unmerged files.")
+				fmt.Fprintln(os.Stderr, "hint: Fix them up in the work tree, and then use 'git add/rm <file>'")
+				fmt.Fprintln(os.Stderr, "hint: as appropriate to mark resolution and make a commit.")
+				if err := printUnmerged(gitDir); err != nil {
+					return err
+				}
+				return errors.New("Exiting because of an unresolved conflict.")
+			case errors.Is(err, revert.ErrNotInProgress):
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				return errors.New("revert failed")
+			case err != nil:
+				return err
+			}
+			return printCommitSummary(gitDir, res)
+		}
+		err := revert.Abort(gitDir, workDir)
+		if errors.Is(err, revert.ErrNotInProgress) {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return errors.New("revert failed")
+		}
+		return err
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	// 2. 撤销
+	h, err := revision.ResolveType(gitDir, positional[0], hash.CommitObject)
+	if err != nil {
+		return fmt.Errorf("bad revision '%s'", positional[0])
+	}
+	res, err := revert.Revert(gitDir, workDir, h, opts)
+	var conflict *checkout.ConflictError
+	switch {
+	case errors.Is(err, hooks.ErrEmptyCommit):
+		// 与 git 一样输出合并过程和 git status 的长格式
+		for _, m := range res.Merge.Messages {
+			fmt.Println(m)
+		}
+		st, err := status.Compute(gitDir, workDir, status.Options{})
+		if err != nil {
+			return err
+		}
+		if err := status.WriteLong(os.Stdout, st); err != nil {
+			return err
+		}
+		return exitCode(1)
+	case errors.Is(err, revert.ErrUnmerged):
+		fmt.Fprintln(os.Stderr, "error: Reverting is not possible because you have unmerged files.")
+		fmt.Fprintln(os.Stderr, "hint: Fix them up in the work tree, and then use 'git add/rm <file>'")
+		fmt.Fprintln(os.Stderr, "hint: as appropriate to mark resolution and make a commit.")
+		return errors.New("revert failed")
+	case errors.Is(err, revert.ErrDirtyIndex):
+		fmt.Fprintf(os.Stderr, "error: %v.\n", err)
+		fmt.Fprintln(os.Stderr, "hint: commit your changes or stash them to proceed.")
+		return errors.New("revert failed")
+	case errors.As(err, &conflict):
+		for _, m := range res.Merge.Messages {
+			fmt.Println(m)
+		}
+		fmt.Fprintf(os.Stderr, "error: %v\nAborting\n", err)
+		return errors.New("revert failed")
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return errors.New("revert failed")
+	}
+
+	// 3. 输出合并过程和结果
+	for _, m := range res.Merge.Messages {
+		fmt.Println(m)
+	}
+	if !res.Merge.Clean() {
+		c, err := commit.ReadCommit(gitDir, h)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "error: could not revert %s... %s\n", h.String()[:7], subjectOf(c))
+		if *noCommit {
+			fmt.Fprintln(os.Stderr, "hint: after resolving the conflicts, mark the corrected paths")
+			fmt.Fprintln(os.Stderr, "hint: with 'git add <paths>' or 'git rm <paths>'")
+		} else {
+			fmt.Fprintln(os.Stderr, "hint: After resolving the conflicts, mark them with")
+			fmt.Fprintln(os.Stderr, "hint: \"git add/rm <pathspec>\", then run")
+			fmt.Fprintln(os.Stderr, "hint: \"git revert --continue\".")
+			fmt.Fprintln(os.Stderr, "hint: You can instead skip this commit with \"git revert --skip\".")
+			fmt.Fprintln(os.Stderr, "hint: To abort and get back to the state before \"git revert\",")
+			fmt.Fprintln(os.Stderr, "hint: run \"git revert --abort\".")
+		}
+		return exitCode(1)
+	}
+	if *noCommit {
+		return nil
+	}
+	return printCommitSummary(gitDir, res)
+}
+
+// printUnmerged 列出索引中有冲突的路径，每个路径一行 "U\t<路径>"
+func printUnmerged(gitDir string) error {
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		return err
+	}
+	last := ""
+	for _, e := range idx.Entries {
+		if e.Stage != 0 && e.Path != last {
+			fmt.Printf("U\t%s\n", e.Path)
+			last = e.Path
+		}
+	}
+	return nil
+}
+
+// printCommitSummary 输出新 commit 的摘要，格式与 git commit 之后的输出相同：
+// "[<分支> <短哈希>] <标题>"，然后是相对父 commit 的修改统计
+func printCommitSummary(gitDir string, res *revert.Result) error {
+	name, err := branch.Current(gitDir)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		name = "detached HEAD"
+	}
+	c, err := commit.ReadCommit(gitDir, res.Commit)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[%s %s] %s\n", name, res.Commit.String()[:7], subjectOf(c))
+
+	parent, err := commit.ReadCommit(gitDir, res.Parent)
+	if err != nil {
+		return err
+	}
+	stats, summary, err := treeStats(gitDir, parent.Tree, c.Tree)
+	if err != nil {
+		return err
+	}
+	if err := diff.WriteShortStat(os.Stdout, stats); err != nil {
+		return err
+	}
+	for _, line := range summary {
+		fmt.Println(line)
+	}
+	return nil
+}
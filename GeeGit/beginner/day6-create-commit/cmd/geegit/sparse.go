@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/checkout"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/sparse"
+)
+
+// cmdSparseCheckout 实现 `geegit sparse-checkout`：只在工作区中检出一部分目录
+// 缺省使用锥形模式，参数是相对于当前目录的目录；--no-cone 时参数是 gitignore 格式的规则
+//
+//	geegit sparse-checkout list
+//	geegit sparse-checkout set [--cone | --no-cone] [--skip-checks] <directory>...
+//	geegit sparse-checkout add [--skip-checks] <directory>...
+//	geegit sparse-checkout reapply
+//	geegit sparse-checkout disable
+func cmdSparseCheckout(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: geegit sparse-checkout (list | set | add | reapply | disable) [<options>]")
+		return errUsage
+	}
+	sub, args := args[0], args[1:]
+
+	fs := newFlags("sparse-checkout "+sub, "list | set [--[no-]cone] [--skip-checks] <directory>... | add [--skip-checks] <directory>... | reapply | disable")
+	cone := fs.Bool("cone", false, "initialize the sparse-checkout in cone mode")
+	noCone := fs.Bool("no-cone", false, "initialize the sparse-checkout with gitignore-style patterns")
+	skipChecks := fs.Bool("skip-checks", false, "skip some sanity checks on the given paths that might give false positives")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	gitDir, workDir, err := openRepo()
+	if err != nil {
+		return err
+	}
+	if workDir == "" {
+		return errors.New("this operation must be run in a work tree")
+	}
+	current, err := sparse.Load(gitDir)
+	if err != nil {
+		return err
+	}
+
+	switch sub {
+	case "list":
+		if current == nil {
+			return errors.New("this worktree is not sparse")
+		}
+		if !current.Cone {
+			os.Stdout.Write(current.Bytes())
+			return nil
+		}
+		for _, d := range current.Dirs() {
+			fmt.Println(d)
+		}
+		return nil
+
+	case "set", "add":
+		// 1. 模式：set 第一次打开稀疏检出时缺省是锥形模式，之后沿用当前的模式
+		coneMode := current == nil || current.Cone
+		if sub == "set" && (*cone || *noCone) {
+			coneMode = *cone && !*noCone
+		}
+		if sub == "add" {
+			if current == nil {
+				return errors.New("no sparse-checkout to add to")
+			}
+			if len(positional) == 0 {
+				return nil
+			}
+		}
+
+		// 2. 新的范围
+		var patterns *sparse.Patterns
+		if coneMode {
+			dirs, err := sparseDirs(gitDir, workDir, positional, *skipChecks)
+			if err != nil {
+				return err
+			}
+			if sub == "add" && current.Cone {
+				dirs = append(current.Dirs(), dirs...)
+			}
+			patterns = sparse.NewCone(dirs)
+		} else {
+			lines := positional
+			if sub == "add" {
+				old := strings.Split(strings.TrimSuffix(string(current.Bytes()), "\n"), "\n")
+				lines = append(old, lines...)
+			}
+			patterns = sparse.NewRules(lines)
+		}
+		if err := sparse.Save(gitDir, patterns); err != nil {
+			return err
+		}
+		return applySparse(gitDir, workDir, patterns)
+
+	case "reapply":
+		if current == nil {
+			return errors.New("must be in a sparse-checkout to reapply sparsity patterns")
+		}
+		return applySparse(gitDir, workDir, current)
+
+	case "disable":
+		if err := sparse.Disable(gitDir); err != nil {
+			return err
+		}
+		return applySparse(gitDir, workDir, nil)
+
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown subcommand: `%s'\n", sub)
+		fs.Usage()
+		return errUsage
+	}
+}
+
+// sparseDirs 检查并转换锥形模式的参数：参数是相对于当前目录的目录，不能以 "/" 开头，
+// 不能含有通配符，也不能是被跟踪的文件（--skip-checks 时跳过后两项检查）
+func sparseDirs(gitDir, workDir string, args []string, skipChecks bool) ([]string, error) {
+	dirs := make([]string, 0, len(args))
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "/") {
+			return nil, errors.New("specify directories rather than patterns (no leading slash)")
+		}
+		rel, err := repoPath(workDir, arg)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, rel)
+	}
+	if skipChecks {
+		return dirs, nil
+	}
+	for _, d := range dirs {
+		if strings.ContainsAny(d, `*?[]\`) {
+			return nil, errors.New(`specify directories rather than patterns.  If your directory really has any of '*?[]\' in it, pass --skip-checks`)
+		}
+	}
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dirs {
+		if _, ok := idx.Find(d); ok {
+			return nil, fmt.Errorf("'%s' is not a directory; to treat it as a directory anyway, rerun with --skip-checks", d)
+		}
+	}
+	return dirs, nil
+}
+
+// applySparse 按新的范围更新工作区，并像 git 一样提示留在工作区中的文件：
+// 有本地修改而没有删除的文件，以及范围之外仍然存在（其中有未跟踪文件）的目录；只剩下空目录的直接删除
+func applySparse(gitDir, workDir string, patterns *sparse.Patterns) error {
+	kept, err := checkout.Sparse(gitDir, workDir, patterns)
+	if err != nil {
+		return err
+	}
+	if len(kept) > 0 {
+		fmt.Fprintln(os.Stderr, "warning: The following paths are not up to date and were left despite sparse patterns:")
+		for _, p := range kept {
+			fmt.Fprintf(os.Stderr, "\t%s\n", p)
+		}
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "After fixing the above paths, you may want to run `git sparse-checkout reapply`.")
+	}
+	if patterns == nil || !patterns.Cone {
+		return nil
+	}
+
+	// 范围之外最上层的目录中的文件都不在工作区时，目录本应不存在
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		return err
+	}
+	outside := make(map[string]bool)
+	for _, e := range idx.Entries {
+		dir := ""
+		for _, name := range strings.Split(path.Dir(e.Path), "/") {
+			dir = path.Join(dir, name)
+			if !patterns.InCone(dir) {
+				break
+			}
+		}
+		if dir == "." || patterns.InCone(dir) {
+			continue
+		}
+		if _, ok := outside[dir]; !ok {
+			outside[dir] = true
+		}
+		outside[dir] = outside[dir] && e.SkipWorktree
+	}
+	var dirs []string
+	for dir, skipped := range outside {
+		if _, err := os.Lstat(filepath.Join(workDir, filepath.FromSlash(dir))); skipped && err == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		full := filepath.Join(workDir, filepath.FromSlash(dir))
+		if !hasFiles(full) {
+			if err := os.RemoveAll(full); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "warning: directory '%s/' contains untracked files, but is not in the sparse-checkout cone\n", dir)
+	}
+	return nil
+}
+
+// hasFiles 判断目录 dir 中（包括子目录）是否有文件
+func hasFiles(dir string) bool {
+	found := false
+	filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+
+	"geegit/beginner/day6-create-commit/checkout"
+	"geegit/beginner/day6-create-commit/revision"
+	"geegit/beginner/day6-create-commit/stash"
+	"geegit/beginner/day6-create-commit/status"
+)
+
+// stashRevision 匹配 stash@{<n>} 和 refs/stash@{<n>}
+var stashRevision = regexp.MustCompile(`^(?:refs/)?stash@\{(\d+)\}$`)
+
+// cmdStash 实现 `geegit stash`：保存和恢复本地修改
+//
+//	geegit stash [push] [-m <message>] [-u | --include-untracked] [-q]
+//	geegit stash list
+//	geegit stash apply [--index] [-q] [<stash>]
+//	geegit stash pop [--index] [-q] [<stash>]
+//	geegit stash drop [-q] [<stash>]
+func cmdStash(args []string) error {
+	sub := "push"
+	if len(args) > 0 && (len(args[0]) == 0 || args[0][0] != '-') {
+		sub, args = args[0], args[1:]
+	}
+
+	fs := newFlags("stash "+sub, "[push [-m <message>] [-u]] | list | apply [--index] [<stash>] | pop [--index] [<stash>] | drop [<stash>]")
+	var message string
+	fs.StringVar(&message, "m", "", "stash message")
+	fs.StringVar(&message, "message", "", "stash message")
+	untracked := fs.Bool("u", false, "include untracked files in stash")
+	fs.BoolVar(untracked, "include-untracked", false, "include untracked files in stash")
+	quiet := fs.Bool("q", false, "be quiet")
+	fs.BoolVar(quiet, "quiet", false, "be quiet")
+	restoreIndex := fs.Bool("index", false, "attempt to recreate the index")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	gitDir, workDir, err := openRepo()
+	if err != nil {
+		return err
+	}
+
+	switch sub {
+	case "push":
+		if len(positional) > 0 {
+			fs.Usage()
+			return errUsage
+		}
+		msg, err := stash.Push(gitDir, workDir, stash.PushOptions{Message: message, IncludeUntracked: *untracked})
+		switch {
+		case errors.Is(err, stash.ErrNoChanges):
+			if !*quiet {
+				fmt.Println("No local changes to save")
+			}
+			return nil
+		case errors.Is(err, stash.ErrNoInitialCommit):
+			fmt.Fprintln(os.Stderr, "You do not have the initial commit yet")
+			return exitCode(1)
+		case err != nil:
+			return err
+		}
+		if !*quiet {
+			fmt.Printf("Saved working directory and index state %s\n", msg)
+		}
+		return nil
+
+	case "list":
+		entries, err := stash.List(gitDir)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			fmt.Printf("stash@{%d}: %s\n", e.Index, e.Message)
+		}
+		return nil
+
+	case "drop":
+		n, name, err := stashArg(gitDir, positional, true)
+		if err != nil {
+			return err
+		}
+		if _, err := revision.Resolve(gitDir, name); err != nil {
+			return err
+		}
+		return dropStash(gitDir, n, name, *quiet)
+
+	case "apply", "pop":
+		n, name, err := stashArg(gitDir, positional, sub == "pop")
+		if err != nil {
+			return err
+		}
+		h, err := revision.Resolve(gitDir, name)
+		if err != nil {
+			return err
+		}
+		s, err := stash.Read(gitDir, h)
+		if err != nil {
+			return err
+		}
+
+		// 1. 应用 stash，输出合并过程
+		res, err := stash.Apply(gitDir, workDir, s, stash.ApplyOptions{Index: *restoreIndex})
+		switch {
+		case errors.Is(err, stash.ErrUnmerged):
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return exitCode(1)
+		case errors.Is(err, stash.ErrIndexConflicts):
+			fmt.Fprintln(os.Stderr, "error: Conflicts in index. Try without --index.")
+			return exitCode(1)
+		}
+		if res == nil {
+			return err
+		}
+		if !*quiet {
+			for _, m := range res.Messages {
+				fmt.Println(m)
+			}
+		}
+		failed := err != nil || !res.Clean()
+		var conflict *checkout.ConflictError
+		if errors.As(err, &conflict) {
+			fmt.Fprintf(os.Stderr, "error: %v\nAborting\n", err)
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\nerror: could not restore untracked files from stash\n", err)
+		}
+		if *restoreIndex && (conflict != nil || !res.Clean()) {
+			fmt.Fprintln(os.Stderr, "Index was not unstashed.")
+		}
+
+		// 2. 显示应用后的状态
+		if !*quiet {
+			st, err := status.Compute(gitDir, workDir, status.Options{})
+			if err != nil {
+				return err
+			}
+			if err := status.WriteLong(os.Stdout, st); err != nil {
+				return err
+			}
+		}
+
+		// 3. pop 成功时删除这一项 stash
+		if failed {
+			if sub == "pop" {
+				fmt.Println("The stash entry is kept in case you need it again.")
+			}
+			return exitCode(1)
+		}
+		if sub == "pop" {
+			return dropStash(gitDir, n, name, *quiet)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("subcommand wasn't specified; 'push' can't be assumed due to unexpected token '%s'", sub)
+}
+
+// stashArg 解析 <stash> 参数，返回它在 stash 列表中的位置和用于解析、显示的名称
+// 省略时为 refs/stash@{0}，也可以写作 <n> 或 stash@{<n>}；
+// requireRef 为 false 时（apply）还可以是任意 stash 形式的 commit，此时位置为 -1
+func stashArg(gitDir string, positional []string, requireRef bool) (int, string, error) {
+	if len(positional) > 1 {
+		return 0, "", fmt.Errorf("Too many revisions specified: %s", positional[0])
+	}
+	entries, err := stash.List(gitDir)
+	if err != nil {
+		return 0, "", err
+	}
+	if len(positional) == 0 {
+		if len(entries) == 0 {
+			fmt.Fprintln(os.Stderr, "No stash entries found.")
+			return 0, "", exitCode(1)
+		}
+		return 0, stash.Ref + "@{0}", nil
+	}
+
+	name := positional[0]
+	if n, err := strconv.Atoi(name); err == nil && n >= 0 {
+		return n, fmt.Sprintf("%s@{%d}", stash.Ref, n), nil
+	}
+	if m := stashRevision.FindStringSubmatch(name); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n, name, nil
+	}
+	if requireRef {
+		return 0, "", fmt.Errorf("'%s' is not a stash reference", name)
+	}
+	return -1, name, nil
+}
+
+// dropStash 删除 stash@{n} 并输出 "Dropped <name> (<hash>)"
+func dropStash(gitDir string, n int, name string, quiet bool) error {
+	h, err := stash.Drop(gitDir, n)
+	if errors.Is(err, stash.ErrNoStash) {
+		fmt.Fprintln(os.Stderr, "No stash entries found.")
+		return exitCode(1)
+	} else if err != nil {
+		return err
+	}
+	if !quiet {
+		fmt.Printf("Dropped %s (%s)\n", name, h)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"geegit/beginner/day6-create-commit/status"
+)
+
+// optionalValue 是既可以单独出现、也可以带值的选项，例如 --porcelain 和 --porcelain=v2
+type optionalValue struct {
+	value    string
+	implicit string // 单独出现时的取值
+}
+
+func (o *optionalValue) String() string   { return o.value }
+func (o *optionalValue) IsBoolFlag() bool { return true }
+
+func (o *optionalValue) Set(v string) error {
+	if v == "true" {
+		v = o.implicit
+	}
+	o.value = v
+	return nil
+}
+
+// cmdStatus 实现 `geegit status [-s] [-b] [--porcelain[=v1|v2]] [-z] [--ignored] [-u[<mode>]]`
+func cmdStatus(args []string) error {
+	fs := newFlags("status", "[-s] [-b] [--porcelain[=<version>]] [-z] [--ignored] [-u[<mode>]]")
+	short := fs.Bool("short", false, "show status concisely")
+	fs.BoolVar(short, "s", false, "show status concisely")
+	branch := fs.Bool("branch", false, "show branch information")
+	fs.BoolVar(branch, "b", false, "show branch information")
+	porcelain := &optionalValue{implicit: "v1"}
+	fs.Var(porcelain, "porcelain", "machine-readable output (v1 or v2)")
+	nul := fs.Bool("z", false, "terminate entries with NUL")
+	ignored := fs.Bool("ignored", false, "show ignored files")
+	untracked := &optionalValue{value: "normal", implicit: "all"}
+	fs.Var(untracked, "u", "show untracked files (no, normal, all)")
+	fs.Var(untracked, "untracked-files", "show untracked files (no, normal, all)")
+	// git 允许 -uno 这种值紧跟在短选项后的写法，flag 包需要 -u=no
+	expandShortValue(args, "u")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	gitDir, workDir, err := openRepo()
+	if err != nil {
+		return err
+	}
+
+	opts := status.Options{ShowIgnored: *ignored}
+	switch untracked.value {
+	case "no":
+		opts.HideUntracked = true
+	case "all":
+		opts.AllUntracked = true
+	case "normal":
+	default:
+		return fmt.Errorf("Invalid untracked files mode '%s'", untracked.value)
+	}
+
+	st, err := status.Compute(gitDir, workDir, opts)
+	if err != nil {
+		return err
+	}
+
+	formatOpts := status.FormatOptions{Branch: *branch, NulSep: *nul, Porcelain: porcelain.value != "" || *nul}
+	switch {
+	case porcelain.value == "v2" || porcelain.value == "2":
+		return status.WritePorcelainV2(os.Stdout, st, formatOpts)
+	case porcelain.value == "v1" || porcelain.value == "1" || *short || *nul:
+		return status.WriteShort(os.Stdout, st, formatOpts)
+	case porcelain.value != "":
+		return fmt.Errorf("unsupported porcelain version '%s'", porcelain.value)
+	default:
+		return status.WriteLong(os.Stdout, st)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/status"
+	"geegit/beginner/day6-create-commit/submodule"
+)
+
+// cmdSubmodule 实现 `geegit submodule`：初始化、更新和查看子模块
+//
+//	geegit submodule [status] [<path>...]
+//	geegit submodule init [<path>...]
+//	geegit submodule update [--init] [<path>...]
+func cmdSubmodule(args []string) error {
+	sub := "status"
+	if len(args) > 0 && (len(args[0]) == 0 || args[0][0] != '-') {
+		sub, args = args[0], args[1:]
+	}
+
+	fs := newFlags("submodule "+sub, "[status [<path>...]] | init [<path>...] | update [--init] [<path>...]")
+	initFirst := fs.Bool("init", false, "initialize uninitialized submodules before update")
+	quiet := fs.Bool("q", false, "be quiet")
+	fs.BoolVar(quiet, "quiet", false, "be quiet")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	gitDir, workDir, err := openRepo()
+	if err != nil {
+		return err
+	}
+	list, err := submodule.List(gitDir, workDir)
+	if err != nil {
+		return err
+	}
+	var paths []string
+	for _, p := range positional {
+		rel, err := repoPath(workDir, p)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, rel)
+	}
+	if list, err = submodule.Match(list, paths); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitCode(1)
+	}
+
+	switch sub {
+	case "status":
+		for _, s := range list {
+			fmt.Println(submoduleStatusLine(workDir, s))
+		}
+		return nil
+
+	case "init":
+		return initSubmodules(gitDir, workDir, list, *quiet)
+
+	case "update":
+		if *initFirst {
+			if err := initSubmodules(gitDir, workDir, list, *quiet); err != nil {
+				return err
+			}
+		}
+		var progress io.Writer = os.Stderr
+		if *quiet {
+			progress = nil
+		}
+		for _, s := range list {
+			checkedOut, err := submodule.Update(gitDir, workDir, s, progress)
+			if err != nil {
+				return err
+			}
+			if checkedOut && !*quiet {
+				fmt.Printf("Submodule path '%s': checked out '%s'\n", s.Path, s.Commit)
+			}
+		}
+		return nil
+	}
+
+	fs.Usage()
+	return errUsage
+}
+
+// initSubmodules 初始化子模块，输出 "Submodule '<name>' (<url>) registered for path '<path>'"
+func initSubmodules(gitDir, workDir string, list []*submodule.Submodule, quiet bool) error {
+	for _, s := range list {
+		url, err := submodule.Init(gitDir, workDir, s)
+		if err != nil {
+			return err
+		}
+		if url != "" && !quiet {
+			fmt.Fprintf(os.Stderr, "Submodule '%s' (%s) registered for path '%s'\n", s.Name, url, s.Path)
+		}
+	}
+	return nil
+}
+
+// submoduleStatusLine 返回 `submodule status` 的一行：<标记><commit> <路径> (<描述>)
+// 标记为 "-" 表示尚未检出，"+" 表示子模块的 HEAD 与记录的 commit 不同，"U" 表示有合并冲突
+func submoduleStatusLine(workDir string, s *submodule.Submodule) string {
+	if s.Stage > 0 {
+		return fmt.Sprintf("U%s %s", hash.Hash{}, s.Path)
+	}
+	if !submodule.IsPopulated(workDir, s) {
+		return fmt.Sprintf("-%s %s", s.Commit, s.Path)
+	}
+	subGitDir := status.SubmoduleGitDir(filepath.Join(workDir, filepath.FromSlash(s.Path)))
+	head, err := refs.Resolve(subGitDir, "HEAD")
+	if err != nil {
+		return fmt.Sprintf("-%s %s", s.Commit, s.Path)
+	}
+	mark := " "
+	if head != s.Commit {
+		mark = "+"
+	}
+	return fmt.Sprintf("%s%s %s (%s)", mark, head, s.Path, submodule.Describe(subGitDir, head))
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/gpg"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/revision"
+	"geegit/beginner/day6-create-commit/tag"
+)
+
+// cmdTag 实现 `geegit tag`：列出、创建（轻量、附注或签名）、删除和验证标签
+func cmdTag(args []string) error {
+	fs := newFlags("tag", "[-l [<pattern>...]] [-n[<num>]] | [-a | -s | -u <key>] [-f] [-m <msg>|-F <file>] <name> [<commit>] | -d <name>... | -v <name>...")
+	list := fs.Bool("l", false, "list tag names")
+	fs.BoolVar(list, "list", false, "list tag names")
+	lines := &optionalValue{value: "0", implicit: "1"}
+	fs.Var(lines, "n", "print <n> lines of each tag message")
+	del := fs.Bool("d", false, "delete tags")
+	fs.BoolVar(del, "delete", false, "delete tags")
+	annotate := fs.Bool("a", false, "annotated tag, needs a message")
+	fs.BoolVar(annotate, "annotate", false, "annotated tag, needs a message")
+	var messages multiFlag
+	fs.Var(&messages, "m", "tag message")
+	fs.Var(&messages, "message", "tag message")
+	file := fs.String("F", "", "read message from file")
+	fs.StringVar(file, "file", "", "read message from file")
+	force := fs.Bool("f", false, "replace the tag if exists")
+	fs.BoolVar(force, "force", false, "replace the tag if exists")
+	sign := &signFlag{}
+	fs.Var(sign, "s", "annotated and GPG-signed tag")
+	fs.Var(sign, "sign", "annotated and GPG-signed tag")
+	fs.BoolFunc("no-sign", "do not sign the tag", func(string) error { return sign.Set("false") })
+	fs.Func("u", "use another key to sign the tag", sign.Set)
+	fs.Func("local-user", "use another key to sign the tag", sign.Set)
+	verify := fs.Bool("v", false, "verify tags")
+	fs.BoolVar(verify, "verify", false, "verify tags")
+	// git 允许 -n3 这种值紧跟在短选项后的写法
+	expandShortValue(args, "n")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(lines.value)
+	if err != nil {
+		return fmt.Errorf("option `n' expects a numerical value")
+	}
+
+	gitDir, _, err := openRepo()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *del:
+		return deleteTags(gitDir, positional)
+	case *verify:
+		return verifyTags(gitDir, positional)
+	case *list || len(positional) == 0:
+		return listTags(gitDir, positional, n)
+	case len(positional) > 2:
+		fs.Usage()
+		return errUsage
+	}
+
+	// 创建标签：有消息或需要签名时创建附注标签，tag.gpgSign 让所有新标签都默认签名
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return err
+	}
+	explicit := false
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "s", "sign", "no-sign", "u", "local-user":
+			explicit = true
+		}
+	})
+	if !explicit {
+		sign.enabled = cfg.Bool("tag.gpgsign", false)
+	}
+	target := "HEAD"
+	if len(positional) == 2 {
+		target = positional[1]
+	}
+	var message string
+	hasMessage := len(messages) > 0 || *file != ""
+	switch {
+	case len(messages) > 0 && *file != "":
+		return fmt.Errorf("options '-F' and '-m' cannot be used together")
+	case len(messages) > 0:
+		message = strings.Join(messages, "\n\n")
+	case *file != "":
+		data, err := readFileOrStdin(*file)
+		if err != nil {
+			return fmt.Errorf("could not open or read '%s': %v", *file, err)
+		}
+		message = string(data)
+	case *annotate || sign.enabled:
+		return fmt.Errorf("no tag message given, use -m or -F")
+	}
+	return createTag(gitDir, positional[0], target, message, hasMessage || sign.enabled, *force, sign)
+}
+
+// createTag 创建标签 name 指向 target；annotated 为 true 时先写出 tag 对象，sign.enabled 时对 tag 对象签名
+func createTag(gitDir, name, target, message string, annotated, force bool, sign *signFlag) error {
+	refName := "refs/tags/" + name
+	if refs.CheckName(refName) != nil || strings.HasPrefix(name, "-") {
+		return fmt.Errorf("'%s' is not a valid tag name.", name)
+	}
+	existing, err := refs.Read(gitDir, refName)
+	if err == nil && !force {
+		return fmt.Errorf("tag '%s' already exists", name)
+	}
+
+	obj, err := revision.Resolve(gitDir, target)
+	if err != nil {
+		return fmt.Errorf("Failed to resolve '%s' as a valid ref.", target)
+	}
+
+	h := obj
+	if annotated {
+		o, err := object.Read(gitDir, obj)
+		if err != nil {
+			return err
+		}
+		tagger, err := commit.DefaultSignature(gitDir, "committer")
+		if err != nil {
+			return err
+		}
+		t := &tag.Tag{Object: obj, Type: o.Type, Name: name, Tagger: tagger, Message: cleanupMessage(message)}
+		// 签名附在标签消息之后，被签名的是此前的全部内容
+		if sign.enabled {
+			cfg, err := config.Load(gitDir)
+			if err != nil {
+				return err
+			}
+			sig, err := gpg.Sign(cfg, tag.Encode(t), sign.key)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				return fmt.Errorf("unable to sign the tag")
+			}
+			t.Message += sig
+		}
+		if h, err = tag.WriteTag(gitDir, t); err != nil {
+			return err
+		}
+	}
+
+	if err := refs.Update(gitDir, refName, h, nil, ""); err != nil {
+		return err
+	}
+	if existing != nil && existing.Hash != h {
+		fmt.Printf("Updated tag '%s' (was %s)\n", name, existing.Hash.String()[:7])
+	}
+	return nil
+}
+
+// cleanupMessage 去掉每行末尾和消息首尾的空白，并确保以换行结尾（git 的 --cleanup=strip，不去掉注释）
+func cleanupMessage(msg string) string {
+	lines := strings.Split(msg, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	msg = strings.Trim(strings.Join(lines, "\n"), "\n")
+	if msg == "" {
+		return ""
+	}
+	return msg + "\n"
+}
+
+// listTags 按名称顺序列出标签；n > 0 时同时显示消息的前 n 行
+// 附注标签显示标签消息，轻量标签显示所指 commit 的消息
+func listTags(gitDir string, patterns []string, n int) error {
+	all, err := refs.List(gitDir)
+	if err != nil {
+		return err
+	}
+	for _, r := range all {
+		name, ok := strings.CutPrefix(r.Name, "refs/tags/")
+		if !ok || (len(patterns) > 0 && !matchAny(patterns, name)) {
+			continue
+		}
+		if n == 0 {
+			fmt.Println(name)
+			continue
+		}
+
+		var message string
+		if o, err := object.Read(gitDir, r.Hash); err == nil {
+			switch o.Type {
+			case hash.TagObject:
+				if t, err := tag.ParseTag(o.Content); err == nil {
+					message = t.Message
+				}
+			case hash.CommitObject:
+				if c, err := commit.ParseCommit(o.Content); err == nil {
+					message = c.Message
+				}
+			}
+		}
+		text := strings.Split(strings.TrimRight(message, "\n"), "\n")
+		if len(text) > n {
+			text = text[:n]
+		}
+		fmt.Printf("%-15s %s\n", name, strings.Join(text, "\n    "))
+	}
+	return nil
+}
+
+// verifyTags 验证标签的签名（`git tag -v`），先输出不含签名的标签内容
+func verifyTags(gitDir string, names []string) error {
+	failed := false
+	for _, name := range names {
+		ref, err := refs.Read(gitDir, "refs/tags/"+name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: tag '%s' not found.\n", name)
+			failed = true
+			continue
+		}
+		ok, err := verifyTag(gitDir, ref.Hash, true)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			failed = true
+		}
+	}
+	if failed {
+		return exitCode(1)
+	}
+	return nil
+}
+
+// deleteTags 删除标签；找不到的标签输出错误后继续，最后以退出码 1 结束
+func deleteTags(gitDir string, names []string) error {
+	failed := false
+	for _, name := range names {
+		ref, err := refs.Read(gitDir, "refs/tags/"+name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: tag '%s' not found.\n", name)
+			failed = true
+			continue
+		}
+		if err := refs.Delete(gitDir, "refs/tags/"+name, &ref.Hash); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted tag '%s' (was %s)\n", name, ref.Hash.String()[:7])
+	}
+	if failed {
+		return exitCode(1)
+	}
+	return nil
+}
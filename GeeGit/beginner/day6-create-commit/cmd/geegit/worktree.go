@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"geegit/beginner/day6-create-commit/branch"
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/repository"
+	"geegit/beginner/day6-create-commit/revision"
+	"geegit/beginner/day6-create-commit/worktree"
+)
+
+// cmdWorktree 实现 `geegit worktree`：管理同一个仓库的多个工作区
+//
+//	geegit worktree add [-f] [-b <new-branch>] [--detach] <path> [<commit-ish>]
+//	geegit worktree list [--porcelain]
+//	geegit worktree remove [-f] <worktree>
+//	geegit worktree prune [-n] [-v]
+func cmdWorktree(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: geegit worktree add [-f] [-b <new-branch>] [--detach] <path> [<commit-ish>]\n"+
+			"   or: geegit worktree list [--porcelain]\n"+
+			"   or: geegit worktree remove [-f] <worktree>\n"+
+			"   or: geegit worktree prune [-n] [-v]")
+		return errUsage
+	}
+	sub, args := args[0], args[1:]
+
+	fs := newFlags("worktree "+sub, "add [-f] [-b <new-branch>] [--detach] <path> [<commit-ish>] | list [--porcelain] | remove [-f] <worktree> | prune [-n] [-v]")
+	var force countFlag
+	fs.Var(&force, "f", "force")
+	fs.Var(&force, "force", "force")
+	newBranch := fs.String("b", "", "create a new branch")
+	resetBranch := fs.String("B", "", "create or reset a branch")
+	detach := fs.Bool("detach", false, "detach HEAD at named commit")
+	porcelain := fs.Bool("porcelain", false, "machine-readable output")
+	dryRun := fs.Bool("n", false, "do not remove, show only")
+	fs.BoolVar(dryRun, "dry-run", false, "do not remove, show only")
+	verbose := fs.Bool("v", false, "report pruned working trees")
+	fs.BoolVar(verbose, "verbose", false, "report pruned working trees")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	gitDir, _, err := openRepo()
+	if err != nil {
+		return err
+	}
+
+	switch sub {
+	case "add":
+		if len(positional) == 0 || len(positional) > 2 {
+			fs.Usage()
+			return errUsage
+		}
+		name, reset := *newBranch, false
+		if *resetBranch != "" {
+			name, reset = *resetBranch, true
+		}
+		return addWorktree(gitDir, positional, name, reset, *detach, force > 0)
+
+	case "list":
+		if len(positional) > 0 {
+			fs.Usage()
+			return errUsage
+		}
+		list, err := worktree.List(gitDir)
+		if err != nil {
+			return err
+		}
+		if *porcelain {
+			writeWorktreesPorcelain(list)
+		} else {
+			writeWorktrees(list)
+		}
+		return nil
+
+	case "remove":
+		if len(positional) != 1 {
+			fs.Usage()
+			return errUsage
+		}
+		w, err := worktree.Find(gitDir, positional[0])
+		if err != nil {
+			return err
+		}
+		// 加锁的工作区需要 -f -f 才能删除
+		err = worktree.Remove(w, force > 1 || (force == 1 && !w.IsLocked))
+		switch {
+		case errors.Is(err, worktree.ErrMainWorktree), errors.Is(err, worktree.ErrDirty):
+			return fmt.Errorf("'%s' %v", positional[0], err)
+		case err != nil && w.IsLocked:
+			return fmt.Errorf("%v\nuse 'remove -f -f' to override or unlock first", err)
+		}
+		return err
+
+	case "prune":
+		if len(positional) > 0 {
+			fs.Usage()
+			return errUsage
+		}
+		return worktree.Prune(gitDir, *dryRun, func(w *worktree.Worktree) {
+			if *dryRun || *verbose {
+				fmt.Fprintf(os.Stderr, "Removing worktrees/%s: %s\n", w.ID, w.Prunable)
+			}
+		})
+	}
+
+	fmt.Fprintf(os.Stderr, "error: unknown subcommand: `%s'\n", sub)
+	fs.Usage()
+	return errUsage
+}
+
+// addWorktree 实现 `geegit worktree add`
+// 没有指定 commit-ish 和 -b 时，以路径的最后一段作为分支名：分支已存在就检出它，否则从 HEAD 创建
+func addWorktree(gitDir string, positional []string, newBranch string, reset, detach, force bool) error {
+	path := positional[0]
+	start := "HEAD"
+	if len(positional) > 1 {
+		start = positional[1]
+	}
+
+	// 1. 确定要检出的分支
+	checkoutBranch := ""
+	switch {
+	case newBranch != "":
+	case len(positional) == 1 && !detach:
+		if name := filepath.Base(path); branch.Exists(gitDir, name) {
+			checkoutBranch = name
+		} else {
+			newBranch = name
+		}
+	case len(positional) > 1 && !detach && branch.Exists(gitDir, start):
+		checkoutBranch = start
+	}
+	if checkoutBranch != "" {
+		start = checkoutBranch
+	}
+	target, err := revision.ResolveType(gitDir, start, hash.CommitObject)
+	if err != nil {
+		return fmt.Errorf("invalid reference: %s", start)
+	}
+
+	// 2. 输出将要做的事情，创建新分支
+	switch {
+	case newBranch != "" && reset && branch.Exists(gitDir, newBranch):
+		old, _ := branch.Head(gitDir, newBranch)
+		fmt.Fprintf(os.Stderr, "Preparing worktree (resetting branch '%s'; was at %s)\n", newBranch, old.String()[:7])
+	case newBranch != "":
+		fmt.Fprintf(os.Stderr, "Preparing worktree (new branch '%s')\n", newBranch)
+	case checkoutBranch != "":
+		fmt.Fprintf(os.Stderr, "Preparing worktree (checking out '%s')\n", checkoutBranch)
+	default:
+		fmt.Fprintf(os.Stderr, "Preparing worktree (detached HEAD %s)\n", target.String()[:7])
+	}
+	if newBranch != "" {
+		if w := worktree.CheckedOut(gitDir, "refs/heads/"+newBranch); reset && w != nil {
+			return fmt.Errorf("cannot force update the branch '%s' checked out at '%s'", newBranch, w.Path)
+		}
+		if err := createBranch(gitDir, newBranch, start, reset, ""); err != nil {
+			return err
+		}
+		checkoutBranch = newBranch
+	}
+
+	// 3. 创建工作区并检出
+	opts := worktree.AddOptions{Commit: target, Force: force}
+	if checkoutBranch != "" {
+		opts.Branch = "refs/heads/" + checkoutBranch
+	}
+	if err := worktree.Add(gitDir, path, opts); err != nil {
+		return err
+	}
+	c, err := commit.ReadCommit(gitDir, target)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("HEAD is now at %s %s\n", target.String()[:7], subjectOf(c))
+
+	// 4. 在新工作区中运行 post-checkout 钩子
+	wtGitDir, wtWorkDir, err := repository.Discover(path)
+	if err != nil {
+		return err
+	}
+	return postCheckout(wtGitDir, wtWorkDir, hash.Hash{}, target)
+}
+
+// writeWorktrees 输出 `worktree list` 的默认格式："<路径> <短哈希> [<分支>]"，路径按最长的对齐
+func writeWorktrees(list []*worktree.Worktree) {
+	width := 0
+	for _, w := range list {
+		width = max(width, len(w.Path))
+	}
+	for _, w := range list {
+		line := fmt.Sprintf("%-*s %s ", width+1, w.Path, w.Head.String()[:7])
+		if w.Branch != "" {
+			line += "[" + refs.ShortName(w.Branch) + "]"
+		} else {
+			line += "(detached HEAD)"
+		}
+		if w.IsLocked {
+			line += " locked"
+		}
+		if w.Prunable != "" {
+			line += " prunable"
+		}
+		fmt.Println(line)
+	}
+}
+
+// writeWorktreesPorcelain 输出 `worktree list --porcelain` 的格式：每个工作区若干行 "<属性> <值>"，以空行分隔
+func writeWorktreesPorcelain(list []*worktree.Worktree) {
+	for _, w := range list {
+		fmt.Printf("worktree %s\nHEAD %s\n", w.Path, w.Head)
+		if w.Branch != "" {
+			fmt.Printf("branch %s\n", w.Branch)
+		} else {
+			fmt.Println("detached")
+		}
+		if w.IsLocked {
+			if w.Locked != "" {
+				fmt.Printf("locked %s\n", w.Locked)
+			} else {
+				fmt.Println("locked")
+			}
+		}
+		if w.Prunable != "" {
+			fmt.Printf("prunable %s\n", w.Prunable)
+		}
+		fmt.Println()
+	}
+}
@@ -20,5 +20,6 @@ type Commit struct {
 	Parents   []hash.Hash
 	Author    Signature
 	Committer Signature
+	GPGSig    string // gpgsig 头部中的签名（ASCII armor 格式），未签名时为空
 	Message   string
 }
@@ -0,0 +1,146 @@
+package commit
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"geegit/beginner/day6-create-commit/config"
+)
+
+// DefaultSignature 返回 author 或 committer 的签名，gitDir 为空时只读取全局配置
+// 姓名和邮箱按以下顺序查找，先找到的优先：
+//  1. 环境变量 GIT_AUTHOR_NAME / GIT_AUTHOR_EMAIL（committer 同理）
+//  2. 配置 author.name / author.email（committer 同理）
+//  3. 配置 user.name / user.email
+//  4. 邮箱还会读取环境变量 EMAIL
+//  5. 系统用户名和 <用户名>@<主机名>；设置了 user.useConfigOnly 时不做自动推断，直接报错
+//
+// 时间取自 GIT_AUTHOR_DATE（committer 同理），缺省为当前时间
+func DefaultSignature(gitDir, role string) (Signature, error) {
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return Signature{}, err
+	}
+	prefix := "GIT_" + strings.ToUpper(role) + "_"
+	lookup := func(env, key string) string {
+		if v := os.Getenv(prefix + env); v != "" {
+			return v
+		}
+		if v, ok := cfg.Get(role + "." + key); ok && v != "" {
+			return v
+		}
+		v, _ := cfg.Get("user." + key)
+		return v
+	}
+
+	sig := Signature{
+		Name:  lookup("NAME", "name"),
+		Email: lookup("EMAIL", "email"),
+		When:  time.Now(),
+	}
+	if sig.Email == "" {
+		sig.Email = os.Getenv("EMAIL")
+	}
+
+	if sig.Name == "" || sig.Email == "" {
+		if cfg.Bool("user.useconfigonly", false) {
+			missing := "email"
+			if sig.Name == "" {
+				missing = "name"
+			}
+			return Signature{}, fmt.Errorf("no %s was given and auto-detection is disabled", missing)
+		}
+		u, err := user.Current()
+		if err != nil {
+			return Signature{}, fmt.Errorf("unable to auto-detect %s identity: %v", role, err)
+		}
+		if sig.Name == "" {
+			sig.Name = u.Username
+		}
+		if sig.Email == "" {
+			host, _ := os.Hostname()
+			sig.Email = u.Username + "@" + host
+		}
+	}
+
+	if date := os.Getenv(prefix + "DATE"); date != "" {
+		when, err := ParseDate(date)
+		if err != nil {
+			return Signature{}, err
+		}
+		sig.When = when
+	}
+
+	return sig, nil
+}
+
+// ParseDate 解析 Git 接受的常见日期格式
+//   - 内部格式: "1234567890 +0800"（也可以带 "@" 前缀）
+//   - RFC 3339: "2005-04-07T22:13:13+08:00"
+//   - RFC 2822: "Thu, 07 Apr 2005 22:13:13 +0800"
+//   - ISO 8601: "2005-04-07 22:13:13 +0800"（时区可以省略，表示本地时间）
+//   - 只有日期: "2005-04-07"，与 git 一样时间取当前时刻
+//   - 相对时间: "2 weeks ago"、"3.days.ago"、"now"、"yesterday"
+func ParseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+
+	// 内部格式复用签名的解析逻辑
+	if sig, err := ParseSignature("x <x> " + strings.TrimPrefix(s, "@")); err == nil {
+		return sig.When, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, time.RFC1123Z, "Mon, 2 Jan 2006 15:04:05 -0700", "2006-01-02 15:04:05 -0700"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local); err == nil {
+		return t, nil
+	}
+	now := time.Now()
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return time.Date(t.Year(), t.Month(), t.Day(), now.Hour(), now.Minute(), now.Second(), 0, time.Local), nil
+	}
+	if t, ok := relativeDate(s, now); ok {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date format: %s", s)
+}
+
+// relativeDate 解析相对于 now 的时间："<N> <单位> ago"，单位可以是复数，空格也可以写成 "."
+func relativeDate(s string, now time.Time) (time.Time, bool) {
+	fields := strings.Fields(strings.ReplaceAll(strings.ToLower(s), ".", " "))
+	switch {
+	case len(fields) == 1 && fields[0] == "now":
+		return now, true
+	case len(fields) == 1 && fields[0] == "yesterday":
+		return now.AddDate(0, 0, -1), true
+	case len(fields) != 3 || fields[2] != "ago":
+		return time.Time{}, false
+	}
+	n, err := strconv.Atoi(fields[0])
+	if err != nil || n < 0 {
+		return time.Time{}, false
+	}
+	switch strings.TrimSuffix(fields[1], "s") {
+	case "second":
+		return now.Add(-time.Duration(n) * time.Second), true
+	case "minute":
+		return now.Add(-time.Duration(n) * time.Minute), true
+	case "hour":
+		return now.Add(-time.Duration(n) * time.Hour), true
+	case "day":
+		return now.AddDate(0, 0, -n), true
+	case "week":
+		return now.AddDate(0, 0, -7*n), true
+	case "month":
+		return now.AddDate(0, -n, 0), true
+	case "year":
+		return now.AddDate(-n, 0, 0), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,165 @@
+package commit
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/shallow"
+)
+
+// ReadCommit 读取一个 commit 对象（松散对象或 packfile 中的对象）
+// 浅克隆边界上的 commit（记录在 .git/shallow 中）的父 commit 不在对象库中，返回时去掉它的 Parents
+func ReadCommit(gitDir string, h hash.Hash) (*Commit, error) {
+	obj, err := object.Read(gitDir, h)
+	if err != nil {
+		return nil, err
+	}
+
+	if obj.Type != hash.CommitObject {
+		return nil, fmt.Errorf("expected commit, got %s", obj.Type)
+	}
+
+	c, err := ParseCommit(obj.Content)
+	if err != nil {
+		return nil, err
+	}
+	c.Hash = h
+	if shallow.Contains(gitDir, h) {
+		c.Parents = nil
+	}
+	return c, nil
+}
+
+// ParseCommit 解析 commit 对象的文本内容（不含 "commit <size>\0" 头部）
+// 格式: 若干 "<key> <value>" 头部行，一个空行，然后是 commit message
+func ParseCommit(content []byte) (*Commit, error) {
+	c := &Commit{}
+
+	// 1. 头部和消息之间用第一个空行分隔
+	headers := content
+	if idx := bytes.Index(content, []byte("\n\n")); idx >= 0 {
+		headers = content[:idx]
+		c.Message = string(content[idx+2:])
+	}
+
+	// 2. 逐行解析头部
+	hasTree := false
+	lastKey := ""
+	for _, line := range strings.Split(string(headers), "\n") {
+		// 以空格开头的是上一个头部的续行，只有 gpgsig 需要保留
+		if line == "" || line[0] == ' ' {
+			if lastKey == "gpgsig" && line != "" {
+				c.GPGSig += line[1:] + "\n"
+			}
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		lastKey = key
+		switch key {
+		case "tree":
+			h, err := hash.ParseHash(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tree line: %v", err)
+			}
+			c.Tree = h
+			hasTree = true
+		case "parent":
+			h, err := hash.ParseHash(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid parent line: %v", err)
+			}
+			c.Parents = append(c.Parents, h)
+		case "author":
+			sig, err := ParseSignature(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid author line: %v", err)
+			}
+			c.Author = sig
+		case "committer":
+			sig, err := ParseSignature(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid committer line: %v", err)
+			}
+			c.Committer = sig
+		case "gpgsig":
+			c.GPGSig = value + "\n"
+		}
+	}
+
+	if !hasTree {
+		return nil, fmt.Errorf("invalid commit: missing tree")
+	}
+	return c, nil
+}
+
+// ParseSignature 解析签名
+// 格式: Name <email> timestamp timezone
+func ParseSignature(s string) (Signature, error) {
+	openIdx := strings.IndexByte(s, '<')
+	closeIdx := strings.LastIndexByte(s, '>')
+	if openIdx < 0 || closeIdx < openIdx {
+		return Signature{}, fmt.Errorf("malformed signature: %q", s)
+	}
+
+	sig := Signature{
+		Name:  strings.TrimSpace(s[:openIdx]),
+		Email: s[openIdx+1 : closeIdx],
+	}
+
+	// 时间部分: "<timestamp> <timezone>"
+	fields := strings.Fields(s[closeIdx+1:])
+	if len(fields) != 2 {
+		return Signature{}, fmt.Errorf("malformed signature time: %q", s)
+	}
+	timestamp, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return Signature{}, fmt.Errorf("malformed timestamp: %q", fields[0])
+	}
+	tz := fields[1]
+	if len(tz) != 5 || (tz[0] != '+' && tz[0] != '-') {
+		return Signature{}, fmt.Errorf("malformed timezone: %q", tz)
+	}
+	hours, err1 := strconv.Atoi(tz[1:3])
+	minutes, err2 := strconv.Atoi(tz[3:5])
+	if err1 != nil || err2 != nil {
+		return Signature{}, fmt.Errorf("malformed timezone: %q", tz)
+	}
+	offset := hours*3600 + minutes*60
+	if tz[0] == '-' {
+		offset = -offset
+	}
+
+	sig.When = time.Unix(timestamp, 0).In(time.FixedZone("", offset))
+	return sig, nil
+}
+
+// ExtractSignature 把 commit 对象的内容拆成被签名的数据和签名
+// 被签名的数据是去掉 gpgsig 头部（包括续行）后的内容，没有签名时 sig 为空
+func ExtractSignature(content []byte) (payload []byte, sig string) {
+	headers, body := content, []byte(nil)
+	if idx := bytes.Index(content, []byte("\n\n")); idx >= 0 {
+		headers, body = content[:idx+1], content[idx+1:]
+	}
+
+	var buf bytes.Buffer
+	inSig := false
+	for _, line := range bytes.SplitAfter(headers, []byte("\n")) {
+		switch {
+		case bytes.HasPrefix(line, []byte("gpgsig ")):
+			inSig = true
+			sig += string(line[len("gpgsig "):])
+		case inSig && bytes.HasPrefix(line, []byte(" ")):
+			sig += string(line[1:])
+		default:
+			inSig = false
+			buf.Write(line)
+		}
+	}
+	buf.Write(body)
+	return buf.Bytes(), sig
+}
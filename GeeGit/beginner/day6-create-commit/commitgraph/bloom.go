@@ -0,0 +1,237 @@
+package commitgraph
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"path"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// changed-path Bloom 过滤器记录一个 commit 相对第一个父 commit（根 commit 相对空 tree）修改过的文件路径，
+// 以及这些路径的所有上级目录。按路径过滤历史时，过滤器中没有某个路径就说明这个 commit 一定没有修改它，
+// 不需要读取和比较两个 tree
+
+// BloomSettings 是 BDAT 头部中的过滤器参数
+type BloomSettings struct {
+	HashVersion  uint32 // murmur3 的实现版本：1 与 git 2.45 之前一致（字节按有符号数扩展），2 是标准实现
+	NumHashes    uint32 // 每个路径设置的位数
+	BitsPerEntry uint32 // 每个路径占用的位数
+}
+
+// defaultBloom 是 git 默认的过滤器参数
+var defaultBloom = BloomSettings{HashVersion: 1, NumHashes: 7, BitsPerEntry: 10}
+
+const (
+	bloomSeed0 = 0x293ae76f
+	bloomSeed1 = 0x7e646e2c
+
+	// maxChangedPaths 是过滤器能记录的修改数量上限，超过时写入所有位都为 1 的单字节过滤器（任何路径都可能修改过）
+	maxChangedPaths = 512
+)
+
+// Bloom 是一个 commit 的 changed-path Bloom 过滤器
+type Bloom struct {
+	Data     []byte
+	settings BloomSettings
+}
+
+// Bloom 返回 commit 的过滤器；commit 不在 commit-graph 中或文件中没有过滤器时返回 false
+func (g *Graph) Bloom(h hash.Hash) (*Bloom, bool) {
+	if g.bloomIndex == nil {
+		return nil, false
+	}
+	i, ok := g.Find(h)
+	if !ok {
+		return nil, false
+	}
+	var start uint32
+	if i > 0 {
+		start = binary.BigEndian.Uint32(g.bloomIndex[(i-1)*4:])
+	}
+	end := binary.BigEndian.Uint32(g.bloomIndex[i*4:])
+	if end <= start || int(end) > len(g.bloomData) {
+		return nil, false
+	}
+	return &Bloom{Data: g.bloomData[start:end], settings: g.bloom}, true
+}
+
+// MayContain 判断 p 是否可能被修改过；返回 false 时 p 一定没有被修改
+func (b *Bloom) MayContain(p string) bool {
+	total := uint32(len(b.Data)) * 8
+	for _, h := range b.settings.keys(p) {
+		pos := h % total
+		if b.Data[pos/8]&(1<<(pos%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// newBloom 由修改过的文件路径生成过滤器；路径太多时返回全为 1 的单字节过滤器
+func newBloom(settings BloomSettings, changed []string) []byte {
+	if len(changed) > maxChangedPaths {
+		return []byte{0xff}
+	}
+
+	// 1. 每个文件路径和它的所有上级目录都是一个键
+	keys := make(map[string]bool)
+	for _, p := range changed {
+		for ; p != "." && p != "" && !keys[p]; p = path.Dir(p) {
+			keys[p] = true
+		}
+	}
+
+	// 2. 每个键设置 NumHashes 位；没有任何修改时仍然写入一个全为 0 的字节
+	n := (len(keys)*int(settings.BitsPerEntry) + 7) / 8
+	if n == 0 {
+		return []byte{0}
+	}
+	data := make([]byte, n)
+	total := uint32(n) * 8
+	for p := range keys {
+		for _, h := range settings.keys(p) {
+			pos := h % total
+			data[pos/8] |= 1 << (pos % 8)
+		}
+	}
+	return data
+}
+
+// keys 返回路径的 NumHashes 个哈希值：h0 + i*h1，h0 和 h1 是两个种子的 murmur3 哈希
+func (s BloomSettings) keys(p string) []uint32 {
+	h0 := murmur3(s.HashVersion, bloomSeed0, []byte(p))
+	h1 := murmur3(s.HashVersion, bloomSeed1, []byte(p))
+	keys := make([]uint32, s.NumHashes)
+	for i := range keys {
+		keys[i] = h0 + uint32(i)*h1
+	}
+	return keys
+}
+
+// murmur3 计算 32 位 murmur3 哈希
+// version 1 与 git 2.45 之前的实现一致：字节先按有符号 char 扩展为 32 位再组合，
+// 只有路径中含有大于 0x7f 的字节时结果才与标准实现不同
+func murmur3(version, seed uint32, data []byte) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+	word := func(b byte) uint32 {
+		if version == 1 {
+			return uint32(int32(int8(b)))
+		}
+		return uint32(b)
+	}
+	mix := func(k uint32) uint32 {
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		return k * c2
+	}
+
+	h := seed
+	n := len(data) / 4
+	for i := 0; i < n; i++ {
+		b := data[i*4:]
+		k := word(b[0]) | word(b[1])<<8 | word(b[2])<<16 | word(b[3])<<24
+		h ^= mix(k)
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	tail := data[n*4:]
+	var k uint32
+	switch len(tail) {
+	case 3:
+		k ^= word(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= word(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= word(tail[0])
+		h ^= mix(k)
+	}
+
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+// changedPaths 比较两个 tree（from 为零哈希表示空 tree），返回新增、删除或修改过的文件路径
+// 目录本身不计入，子模块（gitlink）按文件处理
+func changedPaths(gitDir string, from, to hash.Hash, prefix string, out *[]string) error {
+	entries := func(h hash.Hash) (map[string]tree.TreeEntry, error) {
+		m := make(map[string]tree.TreeEntry)
+		if h.IsZero() {
+			return m, nil
+		}
+		t, err := tree.ReadTree(gitDir, h)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range t.Entries {
+			m[e.Name] = e
+		}
+		return m, nil
+	}
+	a, err := entries(from)
+	if err != nil {
+		return err
+	}
+	b, err := entries(to)
+	if err != nil {
+		return err
+	}
+
+	// 一侧是目录时递归比较目录中的文件（另一侧视为空 tree），一侧是文件时文件本身是一次修改
+	side := func(e tree.TreeEntry, ok bool) (dir, file hash.Hash) {
+		switch {
+		case !ok:
+		case e.IsDir():
+			dir = e.Hash
+		default:
+			file = e.Hash
+		}
+		return dir, file
+	}
+	for name := range union(a, b) {
+		ea, okA := a[name]
+		eb, okB := b[name]
+		if okA && okB && ea.Hash == eb.Hash && ea.IsDir() == eb.IsDir() && strings.TrimLeft(ea.Mode, "0") == strings.TrimLeft(eb.Mode, "0") {
+			continue
+		}
+		p := name
+		if prefix != "" {
+			p = prefix + "/" + name
+		}
+		dirA, fileA := side(ea, okA)
+		dirB, fileB := side(eb, okB)
+		if !dirA.IsZero() || !dirB.IsZero() {
+			if err := changedPaths(gitDir, dirA, dirB, p, out); err != nil {
+				return err
+			}
+		}
+		if !fileA.IsZero() || !fileB.IsZero() {
+			*out = append(*out, p)
+		}
+	}
+	return nil
+}
+
+func union(a, b map[string]tree.TreeEntry) map[string]bool {
+	names := make(map[string]bool, len(a)+len(b))
+	for name := range a {
+		names[name] = true
+	}
+	for name := range b {
+		names[name] = true
+	}
+	return names
+}
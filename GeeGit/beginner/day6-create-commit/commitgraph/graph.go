@@ -0,0 +1,305 @@
+package commitgraph
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/shallow"
+)
+
+// commit-graph 文件（objects/info/commit-graph）把每个 commit 的 tree、父 commit、提交时间和代数
+// （generation number）存成定长记录，遍历历史时不需要解压和解析 commit 对象。格式:
+//
+//	"CGPH" version(1) hashVersion(1) chunkCount(1) baseGraphs(1)
+//	chunk 表: (id(4) offset(8)) * (chunkCount+1)，最后一项的 id 为 0，offset 为最后一个 chunk 的结尾
+//	OIDF: fanout(256*4)
+//	OIDL: hashes(N*20)，按哈希升序排列
+//	CDAT: (tree(20) parent1(4) parent2(4) generation<<2|time>>32 (4) time(4)) * N
+//	EDGE: 章鱼合并的第 2 个及以后的父 commit，最后一个的最高位为 1
+//	BIDX: 每个 commit 的 Bloom 过滤器在 BDAT 中的结束位置(4) * N
+//	BDAT: hashVersion(4) numHashes(4) bitsPerEntry(4)，然后是所有过滤器
+//	checksum(20)
+
+var signature = []byte("CGPH")
+
+const (
+	chunkFanout     = 0x4f494446 // "OIDF"
+	chunkOIDs       = 0x4f49444c // "OIDL"
+	chunkData       = 0x43444154 // "CDAT"
+	chunkExtraEdges = 0x45444745 // "EDGE"
+	chunkBloomIndex = 0x42494458 // "BIDX"
+	chunkBloomData  = 0x42444154 // "BDAT"
+
+	hashSize      = 20
+	dataWidth     = hashSize + 16
+	parentNone    = 0x70000000 // 没有这个父 commit
+	parentOctopus = 0x80000000 // parent2 的最高位为 1 时，其余位是 EDGE 中的下标
+	lastEdge      = 0x80000000 // EDGE 中最后一个父 commit 的标记
+
+	// maxGeneration 是 CDAT 中 30 位代数字段能表示的最大值，更深的历史都记为它
+	maxGeneration = 0x3fffffff
+)
+
+// InfiniteGeneration 是不在 commit-graph 中的 commit 的代数
+// 这样的 commit 可能是任何已记录 commit 的后代，比较代数时不能据此剪枝
+const InfiniteGeneration = math.MaxUint32
+
+// Commit 是遍历历史时需要的 commit 信息
+type Commit struct {
+	Hash       hash.Hash
+	Tree       hash.Hash
+	Parents    []hash.Hash
+	Time       int64  // committer 时间（Unix 秒）
+	Generation uint32 // 根 commit 为 1，其余为父 commit 的最大代数加 1；不在 commit-graph 中时为 InfiniteGeneration
+}
+
+// Graph 表示一个 commit-graph 文件
+type Graph struct {
+	Fanout   [256]uint32
+	Hashes   []hash.Hash // 按哈希升序排列，commit 在其中的下标即它在文件中的位置
+	Checksum hash.Hash
+
+	raw        []byte // 整个文件，用于校验
+	data       []byte // CDAT
+	edges      []byte // EDGE
+	bloomIndex []byte // BIDX
+	bloomData  []byte // BDAT 中头部之后的部分
+	bloom      BloomSettings
+}
+
+// Path 返回 commit-graph 文件的路径（在公共目录中，所有 worktree 共用）
+func Path(gitDir string) string {
+	return filepath.Join(gitdir.CommonDir(gitDir), "objects", "info", "commit-graph")
+}
+
+// cached 是按文件修改时间缓存的 commit-graph
+type cached struct {
+	modTime time.Time
+	size    int64
+	graph   *Graph
+}
+
+// cache 缓存文件路径 -> *cached，遍历历史时每读一个 commit 都要查询一次
+var cache sync.Map
+
+// Open 读取仓库的 commit-graph，没有该文件时返回 nil
+// 与 git 一致，浅克隆（父 commit 被截断）或设置了 core.commitGraph=false 时也不使用 commit-graph
+func Open(gitDir string) (*Graph, error) {
+	p := Path(gitDir)
+	info, err := os.Stat(p)
+	if errors.Is(err, os.ErrNotExist) || shallow.IsShallow(gitDir) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if c, ok := cache.Load(p); ok {
+		if c := c.(*cached); c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+			return c.graph, nil
+		}
+	}
+
+	var g *Graph
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Bool("core.commitgraph", true) {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if g, err = Parse(data); err != nil {
+			return nil, err
+		}
+	}
+	cache.Store(p, &cached{modTime: info.ModTime(), size: info.Size(), graph: g})
+	return g, nil
+}
+
+// Parse 解析 commit-graph 文件内容；为了让打开大文件足够快，这里不校验末尾的校验和（由 Verify 负责）
+func Parse(data []byte) (*Graph, error) {
+	if len(data) < 8+hashSize || !bytes.Equal(data[:4], signature) {
+		return nil, fmt.Errorf("commit-graph signature does not match")
+	}
+	if data[4] != 1 {
+		return nil, fmt.Errorf("commit-graph version %d does not match version 1", data[4])
+	}
+	if data[5] != 1 {
+		return nil, fmt.Errorf("commit-graph hash version %d does not match version 1", data[5])
+	}
+	if data[7] != 0 {
+		return nil, fmt.Errorf("commit-graph chains are not supported")
+	}
+
+	// 1. 读取 chunk 表，得到每个 chunk 的内容
+	g := &Graph{raw: data}
+	copy(g.Checksum[:], data[len(data)-hashSize:])
+	count := int(data[6])
+	table := data[8:]
+	if len(table) < (count+1)*12 {
+		return nil, fmt.Errorf("commit-graph chunk lookup table is truncated")
+	}
+	chunks := make(map[uint32][]byte)
+	for i := 0; i < count; i++ {
+		id := binary.BigEndian.Uint32(table[i*12:])
+		start := binary.BigEndian.Uint64(table[i*12+4:])
+		end := binary.BigEndian.Uint64(table[i*12+16:])
+		if start > end || end > uint64(len(data)-hashSize) {
+			return nil, fmt.Errorf("improper chunk offset(s) %x and %x", start, end)
+		}
+		chunks[id] = data[start:end]
+	}
+
+	// 2. 必需的 OIDF、OIDL、CDAT
+	fanout, ok := chunks[chunkFanout]
+	if !ok || len(fanout) != 256*4 {
+		return nil, fmt.Errorf("commit-graph required OID fanout chunk missing or corrupted")
+	}
+	for i := range g.Fanout {
+		g.Fanout[i] = binary.BigEndian.Uint32(fanout[i*4:])
+	}
+	n := int(g.Fanout[255])
+	oids, ok := chunks[chunkOIDs]
+	if !ok || len(oids) != n*hashSize {
+		return nil, fmt.Errorf("commit-graph required OID lookup chunk missing or corrupted")
+	}
+	g.Hashes = make([]hash.Hash, n)
+	for i := range g.Hashes {
+		copy(g.Hashes[i][:], oids[i*hashSize:])
+	}
+	if g.data, ok = chunks[chunkData]; !ok || len(g.data) != n*dataWidth {
+		return nil, fmt.Errorf("commit-graph required commit data chunk missing or corrupted")
+	}
+	g.edges = chunks[chunkExtraEdges]
+
+	// 3. 可选的 Bloom 过滤器，格式不认识时忽略
+	bidx, bdat := chunks[chunkBloomIndex], chunks[chunkBloomData]
+	if len(bidx) == n*4 && len(bdat) >= 12 {
+		settings := BloomSettings{
+			HashVersion:  binary.BigEndian.Uint32(bdat),
+			NumHashes:    binary.BigEndian.Uint32(bdat[4:]),
+			BitsPerEntry: binary.BigEndian.Uint32(bdat[8:]),
+		}
+		if settings.HashVersion == 1 || settings.HashVersion == 2 {
+			g.bloomIndex, g.bloomData, g.bloom = bidx, bdat[12:], settings
+		}
+	}
+	return g, nil
+}
+
+// Find 查找 commit 在 commit-graph 中的位置
+func (g *Graph) Find(h hash.Hash) (int, bool) {
+	lo := 0
+	if h[0] > 0 {
+		lo = int(g.Fanout[h[0]-1])
+	}
+	hi := int(g.Fanout[h[0]])
+	for lo < hi {
+		mid := (lo + hi) / 2
+		switch c := bytes.Compare(g.Hashes[mid][:], h[:]); {
+		case c == 0:
+			return mid, true
+		case c < 0:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return 0, false
+}
+
+// Lookup 返回 commit-graph 中记录的 commit 信息，不在其中时返回 false
+func (g *Graph) Lookup(h hash.Hash) (*Commit, bool) {
+	i, ok := g.Find(h)
+	if !ok {
+		return nil, false
+	}
+	c, err := g.commit(i)
+	if err != nil {
+		return nil, false
+	}
+	return c, true
+}
+
+// commit 解码位置 i 处的 CDAT 记录
+func (g *Graph) commit(i int) (*Commit, error) {
+	rec := g.data[i*dataWidth : (i+1)*dataWidth]
+	c := &Commit{Hash: g.Hashes[i]}
+	copy(c.Tree[:], rec)
+	p1 := binary.BigEndian.Uint32(rec[hashSize:])
+	p2 := binary.BigEndian.Uint32(rec[hashSize+4:])
+	genTime := binary.BigEndian.Uint32(rec[hashSize+8:])
+	c.Time = int64(genTime&3)<<32 | int64(binary.BigEndian.Uint32(rec[hashSize+12:]))
+	c.Generation = genTime >> 2
+
+	parent := func(pos uint32) error {
+		if int(pos) >= len(g.Hashes) {
+			return fmt.Errorf("invalid parent position %d", pos)
+		}
+		c.Parents = append(c.Parents, g.Hashes[pos])
+		return nil
+	}
+	if p1 == parentNone {
+		return c, nil
+	}
+	if err := parent(p1); err != nil {
+		return nil, err
+	}
+	switch {
+	case p2 == parentNone:
+	case p2&parentOctopus == 0:
+		if err := parent(p2); err != nil {
+			return nil, err
+		}
+	default:
+		for e := int(p2 &^ parentOctopus); ; e++ {
+			if (e+1)*4 > len(g.edges) {
+				return nil, fmt.Errorf("commit-graph extra-edges pointer out of bounds")
+			}
+			v := binary.BigEndian.Uint32(g.edges[e*4:])
+			if err := parent(v &^ lastEdge); err != nil {
+				return nil, err
+			}
+			if v&lastEdge != 0 {
+				break
+			}
+		}
+	}
+	return c, nil
+}
+
+// Read 读取遍历历史时需要的 commit 信息：commit 在 commit-graph 中时直接查表，
+// 否则解析 commit 对象，此时代数为 InfiniteGeneration
+func Read(gitDir string, h hash.Hash) (*Commit, error) {
+	g, err := Open(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	if g != nil {
+		if c, ok := g.Lookup(h); ok {
+			return c, nil
+		}
+	}
+	c, err := commit.ReadCommit(gitDir, h)
+	if err != nil {
+		return nil, err
+	}
+	return &Commit{
+		Hash:       h,
+		Tree:       c.Tree,
+		Parents:    c.Parents,
+		Time:       c.Committer.When.Unix(),
+		Generation: InfiniteGeneration,
+	}, nil
+}
@@ -0,0 +1,86 @@
+package commitgraph
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+
+	"geegit/beginner/day6-create-commit/commit"
+)
+
+// Verify 检查 commit-graph 文件（git commit-graph verify），返回发现的问题，消息与 git 一致
+//  1. 校验和、哈希的顺序和 fanout 表；哈希的顺序或 fanout 表有误时无法按哈希查找，不再继续
+//  2. 每个 commit 记录的 tree、父 commit、代数和提交时间与 commit 对象一致
+func Verify(gitDir string) ([]string, error) {
+	g, err := Open(gitDir)
+	if err != nil || g == nil {
+		return nil, err
+	}
+	var problems []string
+	report := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// 1. 文件本身
+	if sha1.Sum(g.raw[:len(g.raw)-hashSize]) != g.Checksum {
+		report("the commit-graph file has incorrect checksum and is likely corrupt")
+	}
+	checksumErrors := len(problems)
+	var counts [256]uint32
+	for i, h := range g.Hashes {
+		if i > 0 && bytes.Compare(g.Hashes[i-1][:], h[:]) >= 0 {
+			report("commit-graph has incorrect OID order: %s then %s", g.Hashes[i-1], h)
+		}
+		counts[h[0]]++
+	}
+	var total uint32
+	for i, n := range counts {
+		total += n
+		if g.Fanout[i] != total {
+			report("commit-graph has incorrect fanout value: fanout[%d] = %d != %d", i, g.Fanout[i], total)
+		}
+	}
+	if len(problems) > checksumErrors {
+		return problems, nil
+	}
+
+	// 2. 与 commit 对象逐个比较
+	for i, h := range g.Hashes {
+		c, err := g.commit(i)
+		if err != nil {
+			report("failed to parse commit %s from commit-graph", h)
+			continue
+		}
+		obj, err := commit.ReadCommit(gitDir, h)
+		if err != nil {
+			report("failed to parse commit %s from object database for commit-graph", h)
+			continue
+		}
+		if c.Tree != obj.Tree {
+			report("root tree OID for commit %s in commit-graph is %s != %s", h, c.Tree, obj.Tree)
+		}
+		var highest uint32
+		for j, p := range obj.Parents {
+			if j >= len(c.Parents) {
+				report("commit-graph parent list for commit %s terminates early", h)
+				break
+			}
+			if c.Parents[j] != p {
+				report("commit-graph parent for %s is %s != %s", h, c.Parents[j], p)
+			}
+			if pc, ok := g.Lookup(p); ok && pc.Generation > highest {
+				highest = pc.Generation
+			}
+		}
+		if len(c.Parents) > len(obj.Parents) {
+			report("commit-graph parent list for commit %s is too long", h)
+		}
+		if want := min(highest+1, maxGeneration); c.Generation != want {
+			report("commit-graph generation for commit %s is %d != %d", h, c.Generation, want)
+		}
+		if when := obj.Committer.When.Unix(); c.Time != when {
+			report("commit date for commit %s in commit-graph is %d != %d", h, c.Time, when)
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,280 @@
+package commitgraph
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"sort"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/lockfile"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/pack"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/shallow"
+	"geegit/beginner/day6-create-commit/tag"
+)
+
+// Options 控制 Write 收录哪些 commit
+type Options struct {
+	Reachable    bool // 从所有引用出发遍历（--reachable）；否则收录 packfile 中的所有 commit
+	Append       bool // 同时保留原有 commit-graph 中的 commit（--append）
+	ChangedPaths bool // 计算 changed-path Bloom 过滤器；原有文件中已经有过滤器时总是计算
+}
+
+// Write 生成 objects/info/commit-graph
+//  1. 按选项收集起始 commit，再补全它们的所有祖先（commit-graph 中的 commit 的父 commit 必须也在其中）
+//  2. 按哈希排序，计算每个 commit 的代数
+//  3. 需要时计算 Bloom 过滤器（原有文件中已经有的直接复用）
+//  4. 写出所有 chunk 和校验和
+//
+// 与 git 一致，浅克隆中不生成 commit-graph；没有任何 commit 时也不写文件
+func Write(gitDir string, opts Options) error {
+	if shallow.IsShallow(gitDir) {
+		return nil
+	}
+	old, err := Open(gitDir)
+	if err != nil {
+		return err
+	}
+
+	// 1. 收集 commit
+	starts, err := startCommits(gitDir, opts, old)
+	if err != nil {
+		return err
+	}
+	commits := make(map[hash.Hash]*Commit)
+	stack := starts
+	for len(stack) > 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if _, ok := commits[h]; ok {
+			continue
+		}
+		c, err := commit.ReadCommit(gitDir, h)
+		if err != nil {
+			return err
+		}
+		commits[h] = &Commit{Hash: h, Tree: c.Tree, Parents: c.Parents, Time: c.Committer.When.Unix()}
+		stack = append(stack, c.Parents...)
+	}
+	if len(commits) == 0 {
+		return nil
+	}
+
+	// 2. 排序并计算代数
+	list := make([]*Commit, 0, len(commits))
+	for _, c := range commits {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return bytes.Compare(list[i].Hash[:], list[j].Hash[:]) < 0 })
+	computeGenerations(commits)
+
+	// 3. Bloom 过滤器
+	var filters [][]byte
+	if opts.ChangedPaths || (old != nil && old.bloomIndex != nil) {
+		filters = make([][]byte, len(list))
+		for i, c := range list {
+			if old != nil {
+				if b, ok := old.Bloom(c.Hash); ok && b.settings == defaultBloom {
+					filters[i] = b.Data
+					continue
+				}
+			}
+			var from hash.Hash
+			if len(c.Parents) > 0 {
+				from = commits[c.Parents[0]].Tree
+			}
+			var changed []string
+			if err := changedPaths(gitDir, from, c.Tree, "", &changed); err != nil {
+				return err
+			}
+			filters[i] = newBloom(defaultBloom, changed)
+		}
+	}
+
+	lock, err := lockfile.Acquire(Path(gitDir))
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+	if _, err := lock.Write(encode(list, filters)); err != nil {
+		return err
+	}
+	return lock.Commit()
+}
+
+// startCommits 返回收集 commit 的起点：所有引用剥离后指向的 commit，或 packfile 中的所有 commit
+func startCommits(gitDir string, opts Options, old *Graph) ([]hash.Hash, error) {
+	var starts []hash.Hash
+	if opts.Append && old != nil {
+		starts = append(starts, old.Hashes...)
+	}
+	if opts.Reachable {
+		list, err := refs.List(gitDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range list {
+			h, objType, err := peel(gitDir, r.Hash)
+			if err != nil {
+				return nil, err
+			}
+			if objType == hash.CommitObject {
+				starts = append(starts, h)
+			}
+		}
+		return starts, nil
+	}
+
+	packs, err := pack.OpenAll(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range packs {
+		for _, h := range p.Index.Hashes {
+			objType, _, err := p.Read(h)
+			if err != nil {
+				return nil, err
+			}
+			if objType == hash.CommitObject {
+				starts = append(starts, h)
+			}
+		}
+	}
+	return starts, nil
+}
+
+// peel 剥离标签，返回最终指向的对象及其类型
+func peel(gitDir string, h hash.Hash) (hash.Hash, hash.ObjectType, error) {
+	for {
+		obj, err := object.Read(gitDir, h)
+		if err != nil {
+			return hash.Hash{}, 0, err
+		}
+		if obj.Type != hash.TagObject {
+			return h, obj.Type, nil
+		}
+		t, err := tag.ParseTag(obj.Content)
+		if err != nil {
+			return hash.Hash{}, 0, err
+		}
+		h = t.Object
+	}
+}
+
+// computeGenerations 按父 commit 优先的顺序计算代数（拓扑层级），历史很深时不使用递归
+func computeGenerations(commits map[hash.Hash]*Commit) {
+	for _, c := range commits {
+		stack := []*Commit{c}
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if top.Generation != 0 {
+				stack = stack[:len(stack)-1]
+				continue
+			}
+			var highest uint32
+			ready := true
+			for _, p := range top.Parents {
+				pc := commits[p]
+				if pc.Generation == 0 {
+					stack = append(stack, pc)
+					ready = false
+				} else if pc.Generation > highest {
+					highest = pc.Generation
+				}
+			}
+			if ready {
+				top.Generation = min(highest+1, maxGeneration)
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+}
+
+// encode 按文件格式序列化，list 按哈希排序，filters 为 nil 时不写 Bloom 过滤器
+func encode(list []*Commit, filters [][]byte) []byte {
+	pos := make(map[hash.Hash]uint32, len(list))
+	for i, c := range list {
+		pos[c.Hash] = uint32(i)
+	}
+	be := binary.BigEndian
+
+	// 1. 生成每个 chunk 的内容
+	type chunk struct {
+		id   uint32
+		data []byte
+	}
+	var fanout, oids, data, edges bytes.Buffer
+	var counts [256]uint32
+	for _, c := range list {
+		counts[c.Hash[0]]++
+		oids.Write(c.Hash[:])
+	}
+	var total uint32
+	for _, n := range counts {
+		total += n
+		binary.Write(&fanout, be, total)
+	}
+	for _, c := range list {
+		data.Write(c.Tree[:])
+		p1, p2 := uint32(parentNone), uint32(parentNone)
+		switch {
+		case len(c.Parents) == 0:
+		case len(c.Parents) <= 2:
+			p1 = pos[c.Parents[0]]
+			if len(c.Parents) == 2 {
+				p2 = pos[c.Parents[1]]
+			}
+		default:
+			p1 = pos[c.Parents[0]]
+			p2 = parentOctopus | uint32(edges.Len()/4)
+			for i, p := range c.Parents[1:] {
+				v := pos[p]
+				if i == len(c.Parents)-2 {
+					v |= lastEdge
+				}
+				binary.Write(&edges, be, v)
+			}
+		}
+		binary.Write(&data, be, p1)
+		binary.Write(&data, be, p2)
+		binary.Write(&data, be, c.Generation<<2|uint32(c.Time>>32)&3)
+		binary.Write(&data, be, uint32(c.Time))
+	}
+	chunks := []chunk{{chunkFanout, fanout.Bytes()}, {chunkOIDs, oids.Bytes()}, {chunkData, data.Bytes()}}
+	if edges.Len() > 0 {
+		chunks = append(chunks, chunk{chunkExtraEdges, edges.Bytes()})
+	}
+	if filters != nil {
+		var index, bloom bytes.Buffer
+		binary.Write(&bloom, be, defaultBloom)
+		var end uint32
+		for _, f := range filters {
+			end += uint32(len(f))
+			binary.Write(&index, be, end)
+			bloom.Write(f)
+		}
+		chunks = append(chunks, chunk{chunkBloomIndex, index.Bytes()}, chunk{chunkBloomData, bloom.Bytes()})
+	}
+
+	// 2. 头部、chunk 表、各 chunk 和校验和
+	var buf bytes.Buffer
+	buf.Write(signature)
+	buf.Write([]byte{1, 1, byte(len(chunks)), 0})
+	offset := uint64(8 + (len(chunks)+1)*12)
+	for _, c := range chunks {
+		binary.Write(&buf, be, c.id)
+		binary.Write(&buf, be, offset)
+		offset += uint64(len(c.data))
+	}
+	binary.Write(&buf, be, uint32(0))
+	binary.Write(&buf, be, offset)
+	for _, c := range chunks {
+		buf.Write(c.data)
+	}
+	sum := sha1.Sum(buf.Bytes())
+	buf.Write(sum[:])
+	return buf.Bytes()
+}
@@ -0,0 +1,322 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/gitdir"
+)
+
+// Scope 表示配置的来源层级，优先级从低到高依次为 system、global、local、command
+type Scope string
+
+const (
+	ScopeSystem   Scope = "system"   // /etc/gitconfig
+	ScopeGlobal   Scope = "global"   // ~/.gitconfig 和 $XDG_CONFIG_HOME/git/config
+	ScopeLocal    Scope = "local"    // .git/config
+	ScopeWorktree Scope = "worktree" // .git/config.worktree，只在 extensions.worktreeConfig 为 true 时读取
+	ScopeCommand  Scope = "command"  // GIT_CONFIG_COUNT 和 `git -c` 传入的配置
+)
+
+// Entry 表示配置文件中的一个键值对
+// Section 和 Key 不区分大小写（统一存为小写），Subsection 区分大小写
+type Entry struct {
+	Section    string
+	Subsection string
+	Key        string
+	Value      string
+	Implicit   bool   // 只写了键名没有 "="，布尔值视为 true
+	Scope      Scope  // 所属层级，Parse 的结果中为空
+	Origin     string // 来源文件，命令行配置为空字符串
+}
+
+// Name 返回完整的键名，例如 "core.excludesfile" 或 "remote.origin.url"
+func (e Entry) Name() string {
+	if e.Subsection == "" {
+		return e.Section + "." + e.Key
+	}
+	return e.Section + "." + e.Subsection + "." + e.Key
+}
+
+// Config 是按优先级从低到高合并后的配置，同名键以最后出现的为准
+type Config struct {
+	Entries []Entry
+}
+
+// Load 按优先级从低到高读取各层配置，gitDir 为空时不读取仓库配置
+//  1. system: $GIT_CONFIG_SYSTEM 或 /etc/gitconfig，设置 GIT_CONFIG_NOSYSTEM 时跳过
+//  2. global: $GIT_CONFIG_GLOBAL，或 $XDG_CONFIG_HOME/git/config 和 ~/.gitconfig
+//  3. local: .git/config
+//  4. worktree: 当前工作区的 config.worktree（需要 extensions.worktreeConfig）
+//  5. command: GIT_CONFIG_COUNT/GIT_CONFIG_KEY_<n>/GIT_CONFIG_VALUE_<n> 和 GIT_CONFIG_PARAMETERS
+//
+// 每个文件中的 include.path 和满足条件的 includeIf.<condition>.path 会在原位置展开，不存在的文件会被忽略
+func Load(gitDir string) (*Config, error) {
+	l := &loader{gitDir: gitDir}
+	for _, f := range Files(gitDir) {
+		if err := l.readFile(f.Path, f.Scope, 0); err != nil {
+			return nil, err
+		}
+	}
+	if err := l.readEnv(); err != nil {
+		return nil, err
+	}
+	return &Config{Entries: l.entries}, nil
+}
+
+// LoadFile 只读取一个配置文件（`git config -f`），与 git 一致，不展开其中的 include
+func LoadFile(file string) (*Config, error) {
+	l := &loader{noIncludes: true}
+	if _, err := os.Stat(file); err != nil {
+		return nil, fmt.Errorf("unable to read config file '%s': %v", file, err)
+	}
+	if err := l.readFile(file, "", 0); err != nil {
+		return nil, err
+	}
+	return &Config{Entries: l.entries}, nil
+}
+
+// File 是一个配置文件及其层级
+type File struct {
+	Path  string
+	Scope Scope
+}
+
+// Files 按优先级从低到高返回各层的配置文件（不检查文件是否存在）
+func Files(gitDir string) []File {
+	var files []File
+	if p := SystemFile(); p != "" {
+		files = append(files, File{p, ScopeSystem})
+	}
+	if p := os.Getenv("GIT_CONFIG_GLOBAL"); p != "" {
+		files = append(files, File{p, ScopeGlobal})
+	} else {
+		if p := XDGPath("config"); p != "" {
+			files = append(files, File{p, ScopeGlobal})
+		}
+		if home, err := os.UserHomeDir(); err == nil {
+			files = append(files, File{filepath.Join(home, ".gitconfig"), ScopeGlobal})
+		}
+	}
+	if gitDir != "" {
+		files = append(files, File{gitdir.Path(gitDir, "config"), ScopeLocal})
+		if p := WorktreeFile(gitDir); p != "" {
+			files = append(files, File{p, ScopeWorktree})
+		}
+	}
+	return files
+}
+
+// WorktreeFile 返回当前工作区专用的配置文件 config.worktree，
+// 仓库配置中没有打开 extensions.worktreeConfig 时返回空字符串
+func WorktreeFile(gitDir string) string {
+	data, err := os.ReadFile(gitdir.Path(gitDir, "config"))
+	if err != nil {
+		return ""
+	}
+	entries, err := Parse(data)
+	if err != nil {
+		return ""
+	}
+	enabled := false
+	for _, e := range entries {
+		if e.Section == "extensions" && e.Subsection == "" && e.Key == "worktreeconfig" {
+			enabled, _ = ParseBool(e.Value)
+			enabled = enabled || e.Implicit
+		}
+	}
+	if !enabled {
+		return ""
+	}
+	return gitdir.Path(gitDir, "config.worktree")
+}
+
+// SystemFile 返回系统配置文件的路径，设置了 GIT_CONFIG_NOSYSTEM 时返回空字符串
+func SystemFile() string {
+	if b, _ := ParseBool(os.Getenv("GIT_CONFIG_NOSYSTEM")); b {
+		return ""
+	}
+	if p := os.Getenv("GIT_CONFIG_SYSTEM"); p != "" {
+		return p
+	}
+	return "/etc/gitconfig"
+}
+
+// GlobalFile 返回写入全局配置时使用的文件（`git config --global`）
+// 与 git 一致：~/.gitconfig 不存在而 XDG 配置文件存在时写入后者
+func GlobalFile() string {
+	if p := os.Getenv("GIT_CONFIG_GLOBAL"); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	p := filepath.Join(home, ".gitconfig")
+	if _, err := os.Stat(p); err != nil {
+		if xdg := XDGPath("config"); xdg != "" {
+			if _, err := os.Stat(xdg); err == nil {
+				return xdg
+			}
+		}
+	}
+	return p
+}
+
+// Get 返回键的最后一个值，键名格式为 "section.key" 或 "section.subsection.key"
+func (c *Config) Get(name string) (string, bool) {
+	values := c.GetAll(name)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[len(values)-1], true
+}
+
+// GetAll 按出现顺序返回键的所有值（用于 remote.<name>.fetch 这类多值键）
+func (c *Config) GetAll(name string) []string {
+	var values []string
+	for _, e := range c.Find(name) {
+		values = append(values, e.Value)
+	}
+	return values
+}
+
+// Find 按出现顺序返回键名为 name 的所有条目（包括来源信息）
+func (c *Config) Find(name string) []Entry {
+	section, subsection, key := splitName(name)
+	var entries []Entry
+	for _, e := range c.Entries {
+		if e.Section == section && e.Subsection == subsection && e.Key == key {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Bool 按 git 的规则解析布尔值，键不存在或无法解析时返回 def
+func (c *Config) Bool(name string, def bool) bool {
+	section, subsection, key := splitName(name)
+	for i := len(c.Entries) - 1; i >= 0; i-- {
+		e := c.Entries[i]
+		if e.Section != section || e.Subsection != subsection || e.Key != key {
+			continue
+		}
+		if e.Implicit {
+			return true
+		}
+		if b, ok := ParseBool(e.Value); ok {
+			return b
+		}
+		return def
+	}
+	return def
+}
+
+// Int 解析整数值，支持 k、m、g 后缀（1024 的倍数），键不存在时返回 def
+func (c *Config) Int(name string, def int64) (int64, error) {
+	v, ok := c.Get(name)
+	if !ok {
+		return def, nil
+	}
+	n, ok := ParseInt(v)
+	if !ok {
+		return 0, fmt.Errorf("bad numeric config value '%s' for '%s': invalid unit", v, name)
+	}
+	return n, nil
+}
+
+// ParseInt 解析带 k/m/g 单位后缀的整数
+func ParseInt(s string) (int64, bool) {
+	s = strings.TrimSpace(s)
+	unit := int64(1)
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'k', 'K':
+			unit = 1 << 10
+		case 'm', 'M':
+			unit = 1 << 20
+		case 'g', 'G':
+			unit = 1 << 30
+		}
+		if unit > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n * unit, true
+}
+
+// Path 返回路径类型的值，开头的 "~/" 会被展开为用户主目录
+func (c *Config) Path(name string) (string, bool) {
+	v, ok := c.Get(name)
+	if !ok || v == "" {
+		return "", false
+	}
+	return ExpandPath(v), true
+}
+
+// ParseBool 解析 git 的布尔值: true/yes/on 和 false/no/off/空字符串，整数（可以带单位）按是否为 0 判断
+func ParseBool(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "true", "yes", "on":
+		return true, true
+	case "false", "no", "off", "":
+		return false, true
+	}
+	if n, ok := ParseInt(s); ok {
+		return n != 0, true
+	}
+	return false, false
+}
+
+// ExpandPath 展开路径开头的 "~/"
+func ExpandPath(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
+
+// XDGPath 返回 $XDG_CONFIG_HOME/git/<name>（缺省为 ~/.config/git/<name>），无法确定时返回空字符串
+// 用于 core.excludesFile 和 core.attributesFile 的默认值
+func XDGPath(name string) string {
+	xdg := xdgConfigHome()
+	if xdg == "" {
+		return ""
+	}
+	return filepath.Join(xdg, "git", name)
+}
+
+func xdgConfigHome() string {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return xdg
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".config")
+	}
+	return ""
+}
+
+// splitName 把 "section.subsection.key" 拆成三部分
+// section 和 key 转为小写；subsection 可以包含 "."，保持原样
+func splitName(name string) (section, subsection, key string) {
+	first := strings.IndexByte(name, '.')
+	last := strings.LastIndexByte(name, '.')
+	if first < 0 {
+		return strings.ToLower(name), "", ""
+	}
+	section = strings.ToLower(name[:first])
+	key = strings.ToLower(name[last+1:])
+	if first != last {
+		subsection = name[first+1 : last]
+	}
+	return section, subsection, key
+}
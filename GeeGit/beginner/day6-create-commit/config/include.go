@@ -0,0 +1,238 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/wildmatch"
+)
+
+// maxIncludeDepth 是 include 嵌套的最大深度，防止循环引用
+const maxIncludeDepth = 10
+
+// loader 按顺序读取配置文件，并展开其中的 include
+type loader struct {
+	gitDir     string // 用于 includeIf 的 gitdir: 和 onbranch: 条件，可以为空
+	noIncludes bool   // 不展开 include（读取单个文件时的默认行为）
+	entries    []Entry
+}
+
+// readFile 读取一个配置文件，不存在时忽略
+// include.path 和满足条件的 includeIf.<condition>.path 指向的文件紧跟在 include 条目之后展开
+func (l *loader) readFile(file string, scope Scope, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("exceeded maximum include depth (%d) while including %s", maxIncludeDepth, file)
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config %s: %v", file, err)
+	}
+	entries, err := Parse(data)
+	if err != nil {
+		return fmt.Errorf("bad config file %s: %v", file, err)
+	}
+
+	for _, e := range entries {
+		e.Scope, e.Origin = scope, file
+		l.entries = append(l.entries, e)
+		if l.noIncludes || e.Key != "path" || e.Implicit {
+			continue
+		}
+
+		include := false
+		switch {
+		case e.Section == "include" && e.Subsection == "":
+			include = true
+		case e.Section == "includeif":
+			include = l.matchCondition(e.Subsection, file)
+		}
+		if !include {
+			continue
+		}
+
+		// 相对路径相对于当前配置文件所在的目录
+		p := ExpandPath(e.Value)
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(file), p)
+		}
+		if err := l.readFile(p, scope, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchCondition 判断 includeIf 的条件是否成立，不认识的条件视为不成立
+//   - gitdir:<pattern> 和 gitdir/i:<pattern>（不区分大小写）匹配 .git 目录的路径
+//   - onbranch:<pattern> 匹配当前分支名
+func (l *loader) matchCondition(cond, file string) bool {
+	switch {
+	case strings.HasPrefix(cond, "gitdir:"):
+		return l.matchGitDir(strings.TrimPrefix(cond, "gitdir:"), file, 0)
+	case strings.HasPrefix(cond, "gitdir/i:"):
+		return l.matchGitDir(strings.TrimPrefix(cond, "gitdir/i:"), file, wildmatch.CaseFold)
+	case strings.HasPrefix(cond, "onbranch:"):
+		name := l.currentBranch()
+		if name == "" {
+			return false
+		}
+		pattern := strings.TrimPrefix(cond, "onbranch:")
+		if strings.HasSuffix(pattern, "/") {
+			pattern += "**"
+		}
+		return wildmatch.Match(pattern, name, wildmatch.PathName)
+	}
+	return false
+}
+
+// matchGitDir 实现 gitdir: 条件
+//  1. "~/" 展开为主目录，"./" 相对于当前配置文件所在的目录
+//  2. 不是绝对路径的模式前面加上 "**/"，以 "/" 结尾的模式后面加上 "**"
+//  3. 分别与 .git 目录的路径和解析符号链接之后的真实路径匹配
+func (l *loader) matchGitDir(pattern, file string, flags wildmatch.Flags) bool {
+	if l.gitDir == "" {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(pattern, "~/"):
+		pattern = ExpandPath(pattern)
+	case strings.HasPrefix(pattern, "./"):
+		trailing := strings.HasSuffix(pattern, "/")
+		pattern = filepath.Join(filepath.Dir(file), pattern[2:])
+		if trailing {
+			pattern += "/"
+		}
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = "**/" + pattern
+	}
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	pattern = filepath.ToSlash(pattern)
+	flags |= wildmatch.PathName
+
+	gitDir, err := filepath.Abs(l.gitDir)
+	if err != nil {
+		return false
+	}
+	if wildmatch.Match(pattern, filepath.ToSlash(gitDir), flags) {
+		return true
+	}
+	real, err := filepath.EvalSymlinks(gitDir)
+	return err == nil && wildmatch.Match(pattern, filepath.ToSlash(real), flags)
+}
+
+// currentBranch 读取 HEAD 指向的分支名（尚无提交的分支也算），分离 HEAD 时返回空字符串
+// 这里不能依赖 refs 包，否则会形成循环依赖
+func (l *loader) currentBranch() string {
+	if l.gitDir == "" {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(l.gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	name, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "ref: refs/heads/")
+	if !ok {
+		return ""
+	}
+	return name
+}
+
+// readEnv 读取环境变量中的配置
+//  1. GIT_CONFIG_COUNT=<n> 以及 GIT_CONFIG_KEY_<i> / GIT_CONFIG_VALUE_<i>
+//  2. GIT_CONFIG_PARAMETERS（`git -c key=value` 传给子进程的格式："'key'='value' 'key2'='value2'"）
+func (l *loader) readEnv() error {
+	if s := os.Getenv("GIT_CONFIG_COUNT"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return fmt.Errorf("bogus count in GIT_CONFIG_COUNT")
+		}
+		for i := 0; i < n; i++ {
+			key, ok := os.LookupEnv(fmt.Sprintf("GIT_CONFIG_KEY_%d", i))
+			if !ok {
+				return fmt.Errorf("missing config key GIT_CONFIG_KEY_%d", i)
+			}
+			value, ok := os.LookupEnv(fmt.Sprintf("GIT_CONFIG_VALUE_%d", i))
+			if !ok {
+				return fmt.Errorf("missing config value GIT_CONFIG_VALUE_%d", i)
+			}
+			if err := l.addParameter(key, value, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	params, err := parseParameters(os.Getenv("GIT_CONFIG_PARAMETERS"))
+	if err != nil {
+		return err
+	}
+	for _, p := range params {
+		key, value, found := strings.Cut(p, "=")
+		if err := l.addParameter(key, value, !found); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addParameter 添加一条命令行配置
+func (l *loader) addParameter(name, value string, implicit bool) error {
+	section, subsection, key := splitName(name)
+	if section == "" || key == "" || name[len(name)-1] == '.' {
+		return fmt.Errorf("bogus config parameter: %s", name)
+	}
+	if implicit {
+		value = "true"
+	}
+	l.entries = append(l.entries, Entry{Section: section, Subsection: subsection, Key: key,
+		Value: value, Implicit: implicit, Scope: ScopeCommand})
+	return nil
+}
+
+// parseParameters 解析 GIT_CONFIG_PARAMETERS：以空格分隔、用单引号包围的若干项
+// 引号外的 "\" 转义下一个字符（项内的单引号写作先结束引号、再写 \'、再重新开始引号）；
+// "'key'='value'" 形式的键和值分别加引号
+func parseParameters(s string) ([]string, error) {
+	var params []string
+	var cur strings.Builder
+	inItem := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\'':
+			// 读取到下一个单引号为止
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("bogus format in GIT_CONFIG_PARAMETERS")
+			}
+			cur.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inItem = true
+		case c == '\\' && i+1 < len(s):
+			i++
+			cur.WriteByte(s[i])
+		case c == ' ' || c == '\t' || c == '\n':
+			if inItem {
+				params = append(params, cur.String())
+				cur.Reset()
+				inItem = false
+			}
+		default:
+			cur.WriteByte(c)
+			inItem = true
+		}
+	}
+	if inItem {
+		params = append(params, cur.String())
+	}
+	return params, nil
+}
@@ -0,0 +1,226 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parse 解析 git-config 格式的内容
+// 支持的语法：
+//   - "#" 或 ";" 开始的注释
+//   - [section]、[section "subsection"] 和旧式的 [section.subsection]
+//   - key = value，值可以用双引号包围，支持 \\ \" \n \t \b 转义和行尾 "\" 续行
+//   - 只有键名没有 "=" 的布尔值
+func Parse(data []byte) ([]Entry, error) {
+	p := &parser{data: string(data), line: 1}
+	var entries []Entry
+	section, subsection := "", ""
+
+	for {
+		p.skipSpace(true)
+		if p.eof() {
+			return entries, nil
+		}
+
+		switch c := p.peek(); {
+		case c == '#' || c == ';':
+			p.skipLine()
+		case c == '[':
+			var err error
+			section, subsection, err = p.parseSection()
+			if err != nil {
+				return nil, err
+			}
+		case isKeyChar(c):
+			if section == "" {
+				return nil, p.errorf("key outside of a section")
+			}
+			e, err := p.parseEntry()
+			if err != nil {
+				return nil, err
+			}
+			e.Section, e.Subsection = section, subsection
+			entries = append(entries, e)
+		default:
+			return nil, p.errorf("unexpected character %q", c)
+		}
+	}
+}
+
+type parser struct {
+	data string
+	pos  int
+	line int
+}
+
+func (p *parser) eof() bool  { return p.pos >= len(p.data) }
+func (p *parser) peek() byte { return p.data[p.pos] }
+
+func (p *parser) next() byte {
+	c := p.data[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+// skipSpace 跳过空白，newlines 为 true 时同时跳过换行
+func (p *parser) skipSpace(newlines bool) {
+	for !p.eof() {
+		c := p.peek()
+		if c == ' ' || c == '\t' || c == '\r' || (newlines && c == '\n') {
+			p.next()
+			continue
+		}
+		return
+	}
+}
+
+func (p *parser) skipLine() {
+	for !p.eof() && p.next() != '\n' {
+	}
+}
+
+// parseSection 解析 "[section]"、"[section "subsection"]" 或 "[section.subsection]"
+func (p *parser) parseSection() (string, string, error) {
+	p.next() // '['
+	start := p.pos
+	for !p.eof() && (isKeyChar(p.peek()) || p.peek() == '.') {
+		p.next()
+	}
+	name := p.data[start:p.pos]
+	if name == "" {
+		return "", "", p.errorf("empty section name")
+	}
+
+	if p.eof() {
+		return "", "", p.errorf("unterminated section header")
+	}
+
+	// 1. [section]，旧式的 [section.subsection] 中 subsection 不区分大小写
+	if p.peek() == ']' {
+		p.next()
+		section, subsection, _ := strings.Cut(name, ".")
+		return strings.ToLower(section), strings.ToLower(subsection), nil
+	}
+
+	// 2. [section "subsection"]
+	p.skipSpace(false)
+	if p.eof() || p.next() != '"' {
+		return "", "", p.errorf("bad section header")
+	}
+	var sub strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", "", p.errorf("unterminated subsection name")
+		}
+		c := p.next()
+		if c == '"' {
+			break
+		}
+		if c == '\\' && !p.eof() && p.peek() != '\n' {
+			c = p.next()
+		}
+		sub.WriteByte(c)
+	}
+	if p.eof() || p.next() != ']' {
+		return "", "", p.errorf("bad section header")
+	}
+	return strings.ToLower(name), sub.String(), nil
+}
+
+// parseEntry 解析一行 "key = value" 或单独的 "key"
+func (p *parser) parseEntry() (Entry, error) {
+	start := p.pos
+	for !p.eof() && isKeyChar(p.peek()) {
+		p.next()
+	}
+	e := Entry{Key: strings.ToLower(p.data[start:p.pos])}
+
+	p.skipSpace(false)
+	if p.eof() || p.peek() == '\n' || p.peek() == '#' || p.peek() == ';' {
+		e.Implicit = true
+		e.Value = "true"
+		p.skipLine()
+		return e, nil
+	}
+	if p.next() != '=' {
+		return Entry{}, p.errorf("bad config line for key '%s'", e.Key)
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return Entry{}, err
+	}
+	e.Value = value
+	return e, nil
+}
+
+// parseValue 解析到行尾为止的值
+// 引号外的首尾空白被去掉，引号内的内容原样保留
+func (p *parser) parseValue() (string, error) {
+	p.skipSpace(false)
+
+	var b strings.Builder
+	quoted := false
+	trailing := 0 // 引号外、尚未确定是否属于值的末尾空白的长度
+	for !p.eof() {
+		c := p.next()
+		switch {
+		case c == '\n':
+			if quoted {
+				return "", p.errorf("unterminated quoted value")
+			}
+			return b.String()[:b.Len()-trailing], nil
+		case !quoted && (c == '#' || c == ';'):
+			p.skipLine()
+			return b.String()[:b.Len()-trailing], nil
+		case c == '"':
+			quoted = !quoted
+			trailing = 0
+		case c == '\\':
+			if p.eof() {
+				return "", p.errorf("bad escape at end of value")
+			}
+			esc := p.next()
+			switch esc {
+			case '\n':
+				// 续行
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'b':
+				b.WriteByte('\b')
+			case '\\', '"':
+				b.WriteByte(esc)
+			case '\r':
+				if !p.eof() && p.peek() == '\n' {
+					p.next()
+				}
+			default:
+				return "", p.errorf("bad escape sequence '\\%c'", esc)
+			}
+			trailing = 0
+		case !quoted && (c == ' ' || c == '\t' || c == '\r'):
+			b.WriteByte(c)
+			trailing++
+		default:
+			b.WriteByte(c)
+			trailing = 0
+		}
+	}
+	if quoted {
+		return "", p.errorf("unterminated quoted value")
+	}
+	return b.String()[:b.Len()-trailing], nil
+}
+
+func isKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-'
+}
@@ -0,0 +1,286 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/lockfile"
+)
+
+// 配置文件的修改都按行进行，未涉及的行（包括注释和格式）保持原样
+
+// line 是配置文件中的一行（续行与首行合并在一起）
+type line struct {
+	text       string
+	section    string // 该行所在的节，节头行本身也记录为该节
+	subsection string
+	key        string // 键名（小写），节头、注释和空行为空
+	header     bool
+}
+
+// comment 判断一行是否是注释（或其他不是键、节头和空行的内容）
+func (l line) comment() bool {
+	return !l.header && l.key == "" && strings.TrimSpace(l.text) != ""
+}
+
+// SetValue 在配置文件 file 中设置一个键的值，文件不存在时会被创建
+// 键已存在时替换最后一次出现的值；否则追加到同名节的末尾，没有该节时在文件末尾新建
+func SetValue(file, name, value string) error {
+	return setValue(file, name, value, true)
+}
+
+// AddValue 为多值键追加一个值（`git config --add`），已有的值保持不变
+func AddValue(file, name, value string) error {
+	return setValue(file, name, value, false)
+}
+
+func setValue(file, name, value string, replace bool) error {
+	section, subsection, key := splitName(name)
+	if key == "" || section == "" {
+		return fmt.Errorf("key does not contain a section: %s", name)
+	}
+	rawKey := name[strings.LastIndexByte(name, '.')+1:]
+	entry := "\t" + rawKey + " = " + quoteValue(value)
+
+	return edit(file, func(lines []line) []line {
+		// 1. 替换最后一次出现的同名键
+		lastKey, lastInSection := -1, -1
+		for i, l := range lines {
+			if l.section != section || l.subsection != subsection {
+				continue
+			}
+			lastInSection = i
+			if l.key == key {
+				lastKey = i
+			}
+		}
+		if replace && lastKey >= 0 {
+			lines[lastKey].text = entry
+			return lines
+		}
+
+		// 2. 追加到已有节的末尾
+		newLine := line{text: entry, section: section, subsection: subsection, key: key}
+		if lastInSection >= 0 {
+			lines = append(lines[:lastInSection+1], append([]line{newLine}, lines[lastInSection+1:]...)...)
+			return lines
+		}
+
+		// 3. 新建节
+		return append(lines, line{text: sectionHeader(section, subsection), header: true,
+			section: section, subsection: subsection}, newLine)
+	})
+}
+
+// Unset 删除键的所有值，键不存在时不做任何修改
+// 与 git 一样，节中的键全部被删除、且节的前后没有注释时，节头也一起删除
+func Unset(file, name string) error {
+	section, subsection, key := splitName(name)
+	match := func(l line) bool {
+		return l.key == key && l.section == section && l.subsection == subsection
+	}
+	return edit(file, func(lines []line) []line {
+		drop := make([]bool, len(lines))
+		for i, l := range lines {
+			if drop[i] || !match(l) {
+				continue
+			}
+			begin, end, ok := emptySection(lines, i, match)
+			if !ok {
+				begin, end = i, i+1
+			}
+			for j := begin; j < end; j++ {
+				drop[j] = true
+			}
+		}
+		kept := lines[:0]
+		for i, l := range lines {
+			if !drop[i] {
+				kept = append(kept, l)
+			}
+		}
+		return kept
+	})
+}
+
+// emptySection 判断删除第 i 行的键（以及同一节中后面同样被删除的键）之后节是否变空，
+// 是时返回包括节头在内要删除的行 [begin, end)，规则与 git 相同：
+//  1. 向前：第 i 行是节中的第一个键，节头之前到上一个键或上一个节之间没有注释
+//  2. 向后：到下一个节为止没有其他键和注释；紧接着的同名节头也一起删除
+//
+// 节头之前和节末尾的空行一起删除
+func emptySection(lines []line, i int, match func(line) bool) (begin, end int, ok bool) {
+	section, subsection := lines[i].section, lines[i].subsection
+	same := func(l line) bool {
+		return l.header && l.section == section && l.subsection == subsection
+	}
+
+	// 1. 向前
+	seen := false
+back:
+	for begin = i; begin > 0; begin-- {
+		l := lines[begin-1]
+		switch {
+		case l.comment():
+			return 0, 0, false
+		case l.key != "":
+			if !seen {
+				return 0, 0, false
+			}
+			break back
+		case same(l):
+			seen = true
+		case l.header:
+			break back
+		}
+	}
+
+	// 2. 向后
+	for end = i + 1; end < len(lines); end++ {
+		l := lines[end]
+		switch {
+		case l.comment():
+			return 0, 0, false
+		case l.key != "":
+			if !match(l) {
+				return 0, 0, false
+			}
+		case l.header && !same(l):
+			return begin, end, true
+		}
+	}
+	return begin, end, true
+}
+
+// RenameSection 重命名节，例如 "branch.old" -> "branch.new"
+func RenameSection(file, oldName, newName string) error {
+	oldSection, oldSub := splitSection(oldName)
+	newSection, newSub := splitSection(newName)
+	return edit(file, func(lines []line) []line {
+		for i, l := range lines {
+			if l.header && l.section == oldSection && l.subsection == oldSub {
+				lines[i].text = sectionHeader(newSection, newSub)
+			}
+		}
+		return lines
+	})
+}
+
+// RemoveSection 删除整个节（包括其中的所有键）
+func RemoveSection(file, name string) error {
+	section, subsection := splitSection(name)
+	return edit(file, func(lines []line) []line {
+		kept := lines[:0]
+		for _, l := range lines {
+			if l.section == section && l.subsection == subsection {
+				continue
+			}
+			kept = append(kept, l)
+		}
+		return kept
+	})
+}
+
+// edit 在锁的保护下读取、修改并写回配置文件
+func edit(file string, fn func([]line) []line) error {
+	lock, err := lockfile.Acquire(file)
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	lines := splitLines(string(data))
+	lines = fn(lines)
+
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(l.text)
+		b.WriteString("\n")
+	}
+	if _, err := lock.Write([]byte(b.String())); err != nil {
+		return err
+	}
+	return lock.Commit()
+}
+
+// splitLines 把配置文件拆分成行，并标注每行所在的节和键
+func splitLines(data string) []line {
+	var lines []line
+	section, subsection := "", ""
+	raw := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+	if data == "" {
+		raw = nil
+	}
+
+	for i := 0; i < len(raw); i++ {
+		text := raw[i]
+		// 以 "\" 结尾的行和下一行属于同一个值
+		for strings.HasSuffix(text, "\\") && i+1 < len(raw) {
+			i++
+			text += "\n" + raw[i]
+		}
+
+		l := line{text: text}
+		trimmed := strings.TrimSpace(text)
+		switch {
+		case strings.HasPrefix(trimmed, "["):
+			p := &parser{data: trimmed, line: 1}
+			if s, sub, err := p.parseSection(); err == nil {
+				section, subsection = s, sub
+			}
+			l.header = true
+		case trimmed != "" && isKeyChar(trimmed[0]):
+			end := 0
+			for end < len(trimmed) && isKeyChar(trimmed[end]) {
+				end++
+			}
+			l.key = strings.ToLower(trimmed[:end])
+		}
+		l.section, l.subsection = section, subsection
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+func sectionHeader(section, subsection string) string {
+	if subsection == "" {
+		return "[" + section + "]"
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(subsection)
+	return fmt.Sprintf("[%s \"%s\"]", section, escaped)
+}
+
+// splitSection 把 "section.subsection" 拆成两部分
+func splitSection(name string) (string, string) {
+	section, subsection, _ := strings.Cut(name, ".")
+	return strings.ToLower(section), subsection
+}
+
+// quoteValue 按需要给值加引号和转义
+func quoteValue(v string) string {
+	var b strings.Builder
+	needQuote := v != strings.TrimSpace(v) || strings.ContainsAny(v, "#;")
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; c {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	if needQuote {
+		return `"` + b.String() + `"`
+	}
+	return b.String()
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnsetRemovesEmptySection(t *testing.T) {
+	// 期望的结果与 git config -f <file> --unset-all 依次删除这些键之后相同
+	tests := []struct {
+		name  string
+		input string
+		keys  []string
+		want  string
+	}{
+		{
+			name:  "only key",
+			input: "[a]\n\tx = 1\n[b]\n\ty = 2\n",
+			keys:  []string{"a.x"},
+			want:  "[b]\n\ty = 2\n",
+		},
+		{
+			name:  "subsection and trailing blank line",
+			input: "[c]\n\tw = 4\n[d \"s\"]\n\tv = 1\n\n[e]\n\tq = 1\n",
+			keys:  []string{"d.s.v"},
+			want:  "[c]\n\tw = 4\n[e]\n\tq = 1\n",
+		},
+		{
+			name:  "all values of a multi-valued key",
+			input: "[core]\n\tbare = false\n[m]\n\tv = 1\n\tv = 2\n",
+			keys:  []string{"m.v"},
+			want:  "[core]\n\tbare = false\n",
+		},
+		{
+			name:  "other keys remain",
+			input: "[a]\n\tx = 1\n\ty = 2\n",
+			keys:  []string{"a.x"},
+			want:  "[a]\n\ty = 2\n",
+		},
+		{
+			name:  "same section continues under another header",
+			input: "[c]\n\tz = 3\n[c]\n\tw = 4\n",
+			keys:  []string{"c.z"},
+			want:  "[c]\n[c]\n\tw = 4\n",
+		},
+		{
+			name:  "comment inside the section",
+			input: "[b]\n\t# note\n\ty = 2\n",
+			keys:  []string{"b.y"},
+			want:  "[b]\n\t# note\n",
+		},
+		{
+			name:  "comments around the sections",
+			input: "[x]\n\tk = 1\n# c\n[y]\n\tj = 1\n",
+			keys:  []string{"y.j", "x.k"},
+			want:  "[x]\n# c\n[y]\n",
+		},
+		{
+			name:  "repeated header after a comment",
+			input: "[m]\n; trailing\n[n]\n\tk = 1\n[n]\n\tk = 2\n",
+			keys:  []string{"n.k"},
+			want:  "[m]\n; trailing\n[n]\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "config")
+			if err := os.WriteFile(file, []byte(tt.input), 0644); err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range tt.keys {
+				if err := Unset(file, key); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("after Unset %v:\n%q\nwant:\n%q", tt.keys, got, tt.want)
+			}
+		})
+	}
+}
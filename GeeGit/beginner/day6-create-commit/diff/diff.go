@@ -0,0 +1,177 @@
+package diff
+
+import "strings"
+
+// Op 表示一行在差异中的操作类型
+type Op int
+
+const (
+	Equal  Op = iota // 两边都有
+	Delete           // 只在旧版本中
+	Insert           // 只在新版本中
+)
+
+// Edit 表示差异中的一行
+// OldLine/NewLine 是从 0 开始的行号，不存在的一侧为 -1
+type Edit struct {
+	Op      Op
+	OldLine int
+	NewLine int
+}
+
+// SplitLines 将内容按行切分，每行保留末尾的 "\n"
+// 最后一行如果没有换行符也会作为单独一行返回
+func SplitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Lines 使用 Myers 算法计算从 a 到 b 的最短编辑序列
+// 返回的 Edit 按行顺序排列，可直接用于生成 unified diff 或逐行对应；连续的修改中删除排在插入之前
+// 使用线性空间的版本（分治寻找中间的 snake），内存与 len(a)+len(b) 成正比
+func Lines(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	if n+m == 0 {
+		return nil
+	}
+	s := &myers{a: a, b: b, offset: n + m + 1}
+	s.vf = make([]int, 2*(n+m)+3)
+	s.vb = make([]int, 2*(n+m)+3)
+	s.edits = make([]Edit, 0, n+m)
+	s.compare(0, n, 0, m)
+	deletesFirst(s.edits)
+	return s.edits
+}
+
+// myers 保存分治过程中复用的 V 数组和已经得到的编辑序列
+// vf[offset+k] 是前向搜索在对角线 k 上能到达的最远 x，vb 是从终点反向搜索时的距离
+type myers struct {
+	a, b   []string
+	vf, vb []int
+	offset int
+	edits  []Edit
+}
+
+// compare 按顺序追加 a[a0:a1] 到 b[b0:b1] 的编辑序列
+//  1. 去掉相同的前缀和后缀
+//  2. 一边为空时只剩删除或插入
+//  3. 否则找到中间的 snake，对两侧递归
+func (s *myers) compare(a0, a1, b0, b1 int) {
+	// 1. 相同的前缀和后缀
+	for a0 < a1 && b0 < b1 && s.a[a0] == s.b[b0] {
+		s.edits = append(s.edits, Edit{Op: Equal, OldLine: a0, NewLine: b0})
+		a0++
+		b0++
+	}
+	suffix := 0
+	for a0 < a1-suffix && b0 < b1-suffix && s.a[a1-suffix-1] == s.b[b1-suffix-1] {
+		suffix++
+	}
+	a1 -= suffix
+	b1 -= suffix
+
+	// 2. 只有删除或插入
+	switch {
+	case a0 == a1:
+		for y := b0; y < b1; y++ {
+			s.edits = append(s.edits, Edit{Op: Insert, OldLine: -1, NewLine: y})
+		}
+	case b0 == b1:
+		for x := a0; x < a1; x++ {
+			s.edits = append(s.edits, Edit{Op: Delete, OldLine: x, NewLine: -1})
+		}
+	default:
+		// 3. 中间的 snake 两侧各自递归
+		x, y, u, v := s.middleSnake(a0, a1, b0, b1)
+		s.compare(a0, x, b0, y)
+		for ; x < u; x, y = x+1, y+1 {
+			s.edits = append(s.edits, Edit{Op: Equal, OldLine: x, NewLine: y})
+		}
+		s.compare(u, a1, v, b1)
+	}
+
+	for i := 0; i < suffix; i++ {
+		s.edits = append(s.edits, Edit{Op: Equal, OldLine: a1 + i, NewLine: b1 + i})
+	}
+}
+
+// middleSnake 同时从起点和终点搜索，返回最短编辑路径中间的一段对角线 (x, y) -> (u, v)
+// 调用时两边都不为空，且首尾的行都不相同
+func (s *myers) middleSnake(a0, a1, b0, b1 int) (x, y, u, v int) {
+	n, m := a1-a0, b1-b0
+	delta := n - m
+	odd := delta%2 != 0
+	vf, vb, off := s.vf, s.vb, s.offset
+	vf[off+1] = 0
+	vb[off+1] = 0
+	for d := 0; d <= (n+m+1)/2; d++ {
+		// 前向：delta 为奇数时检查是否与反向搜索的路径重叠
+		for k := -d; k <= d; k += 2 {
+			if k == -d || k != d && vf[off+k-1] < vf[off+k+1] {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y = x - k
+			u, v = x, y
+			for u < n && v < m && s.a[a0+u] == s.b[b0+v] {
+				u++
+				v++
+			}
+			vf[off+k] = u
+			if kb := delta - k; odd && kb >= -(d-1) && kb <= d-1 && u+vb[off+kb] >= n {
+				return a0 + x, b0 + y, a0 + u, b0 + v
+			}
+		}
+		// 反向：坐标是到终点的距离，delta 为偶数时检查重叠
+		for k := -d; k <= d; k += 2 {
+			if k == -d || k != d && vb[off+k-1] < vb[off+k+1] {
+				x = vb[off+k+1]
+			} else {
+				x = vb[off+k-1] + 1
+			}
+			y = x - k
+			u, v = x, y
+			for u < n && v < m && s.a[a1-u-1] == s.b[b1-v-1] {
+				u++
+				v++
+			}
+			vb[off+k] = u
+			if kf := delta - k; !odd && kf >= -d && kf <= d && u+vf[off+kf] >= n {
+				return a1 - u, b1 - v, a1 - x, b1 - y
+			}
+		}
+	}
+	panic("diff: middle snake not found")
+}
+
+// deletesFirst 把每一段连续的删除和插入重新排列为先删除、后插入，各自保持原来的顺序
+func deletesFirst(edits []Edit) {
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			i++
+			continue
+		}
+		j := i
+		for j < len(edits) && edits[j].Op != Equal {
+			j++
+		}
+		var del, ins []Edit
+		for _, e := range edits[i:j] {
+			if e.Op == Delete {
+				del = append(del, e)
+			} else {
+				ins = append(ins, e)
+			}
+		}
+		copy(edits[i:], del)
+		copy(edits[i+len(del):], ins)
+		i = j
+	}
+}
@@ -0,0 +1,167 @@
+package diff
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/hash"
+)
+
+// 重命名检测：在一侧删除、另一侧新增的文件之间按内容配对（与 git diff -M 的默认行为相同）。
+// 内容完全相同的文件直接配对；其余的按相似度从高到低配对，相似度不到 MinScore 的不算重命名
+
+// MinScore 是认为两个文件是重命名所需的最低相似度（百分比），与 git 的默认值相同
+const MinScore = 50
+
+// Rename 是一对被识别为重命名的路径
+type Rename struct {
+	From, To string
+	Score    int // 相似度（百分比），内容相同时为 100
+}
+
+// DetectRenames 在删除的文件 deleted 和新增的文件 added（路径 -> blob 哈希）之间找出重命名，
+// 每个文件最多出现在一个重命名中；read 读取 blob 的内容。结果按 To 排序
+//  1. 哈希相同的文件直接配对，有多个来源时优先选择文件名相同的
+//  2. 剩下的文件两两计算相似度，从高到低配对；空文件不参与
+func DetectRenames(deleted, added map[string]hash.Hash, read func(hash.Hash) ([]byte, error)) ([]Rename, error) {
+	srcs := sortedKeys(deleted)
+	dsts := sortedKeys(added)
+	usedSrc := make(map[string]bool)
+	usedDst := make(map[string]bool)
+	var renames []Rename
+
+	// 1. 内容相同
+	for _, dst := range dsts {
+		from := ""
+		for _, src := range srcs {
+			if usedSrc[src] || deleted[src] != added[dst] {
+				continue
+			}
+			if from == "" || path.Base(src) == path.Base(dst) && path.Base(from) != path.Base(dst) {
+				from = src
+			}
+		}
+		if from != "" {
+			usedSrc[from], usedDst[dst] = true, true
+			renames = append(renames, Rename{From: from, To: dst, Score: 100})
+		}
+	}
+
+	// 2. 内容相似
+	type candidate struct {
+		src, dst string
+		score    int
+	}
+	var candidates []candidate
+	srcChunks := make(map[string]map[string]int)
+	srcSizes := make(map[string]int)
+	for _, dst := range dsts {
+		if usedDst[dst] {
+			continue
+		}
+		dstData, err := read(added[dst])
+		if err != nil {
+			return nil, err
+		}
+		if len(dstData) == 0 {
+			continue
+		}
+		dstChunks := chunks(dstData)
+		for _, src := range srcs {
+			if usedSrc[src] {
+				continue
+			}
+			if _, ok := srcChunks[src]; !ok {
+				data, err := read(deleted[src])
+				if err != nil {
+					return nil, err
+				}
+				srcChunks[src], srcSizes[src] = chunks(data), len(data)
+			}
+			if srcSizes[src] == 0 {
+				continue
+			}
+			if score := similarity(srcChunks[src], dstChunks, srcSizes[src], len(dstData)); score >= MinScore {
+				candidates = append(candidates, candidate{src, dst, score})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		return path.Base(a.src) == path.Base(a.dst) && path.Base(b.src) != path.Base(b.dst)
+	})
+	for _, c := range candidates {
+		if usedSrc[c.src] || usedDst[c.dst] {
+			continue
+		}
+		usedSrc[c.src], usedDst[c.dst] = true, true
+		renames = append(renames, Rename{From: c.src, To: c.dst, Score: c.score})
+	}
+	sort.Slice(renames, func(i, j int) bool { return renames[i].To < renames[j].To })
+	return renames, nil
+}
+
+// RenamePath 按 git 的格式显示重命名，两边相同的目录前缀和后缀提到大括号之外，
+// 例如 "a => b" 和 "src/{old => new}/main.go"
+func RenamePath(from, to string) string {
+	// 公共前缀到最后一个相同的 "/" 为止，公共后缀从第一个相同的 "/" 开始
+	prefix := 0
+	for i := 0; i < len(from) && i < len(to) && from[i] == to[i]; i++ {
+		if from[i] == '/' {
+			prefix = i + 1
+		}
+	}
+	suffix := 0
+	for i := 1; i <= len(from)-prefix && i <= len(to)-prefix && from[len(from)-i] == to[len(to)-i]; i++ {
+		if from[len(from)-i] == '/' {
+			suffix = i
+		}
+	}
+	if prefix == 0 && suffix == 0 {
+		return from + " => " + to
+	}
+	return from[:prefix] + "{" + from[prefix:len(from)-suffix] + " => " + to[prefix:len(to)-suffix] + "}" + from[len(from)-suffix:]
+}
+
+// chunks 把内容切成以换行结尾、最长 64 字节的片段，统计每种片段的总字节数（与 git 估算相似度的方式相同）
+// "\r\n" 中的 "\r" 不计入，换行风格不同的文本仍然相同
+func chunks(data []byte) map[string]int {
+	counts := make(map[string]int)
+	var b strings.Builder
+	for i, c := range data {
+		if c == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+			continue
+		}
+		b.WriteByte(c)
+		if c == '\n' || b.Len() >= 64 {
+			counts[b.String()] += b.Len()
+			b.Reset()
+		}
+	}
+	if b.Len() > 0 {
+		counts[b.String()] += b.Len()
+	}
+	return counts
+}
+
+// similarity 返回相似度：两边共有片段的字节数占较大文件的百分比
+func similarity(src, dst map[string]int, srcSize, dstSize int) int {
+	copied := 0
+	for k, n := range src {
+		copied += min(n, dst[k])
+	}
+	return copied * 100 / max(srcSize, dstSize)
+}
+
+func sortedKeys(m map[string]hash.Hash) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,169 @@
+package diff
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// FileStat 是一个文件的修改统计（git diff --stat 中的一行）
+type FileStat struct {
+	Path    string
+	Added   int  // 增加的行数；二进制文件是新版本的字节数
+	Deleted int  // 删除的行数；二进制文件是旧版本的字节数
+	Binary  bool // 二进制文件只显示大小的变化
+}
+
+// CountLines 返回从 a 到 b 增加和删除的行数
+func CountLines(a, b []byte) (added, deleted int) {
+	for _, e := range Lines(SplitLines(a), SplitLines(b)) {
+		switch e.Op {
+		case Insert:
+			added++
+		case Delete:
+			deleted++
+		}
+	}
+	return added, deleted
+}
+
+// WriteStat 按 git diff --stat 的格式输出每个文件的统计和汇总行，width 是每行的最大宽度（git 缺省为 80）
+//  1. 文件名、修改行数和 "+"/"-" 图形三栏对齐；放不下时图形最多占 3/8，文件名截掉开头，以 ".../" 开始
+//  2. 图形按修改最多的文件缩放，但有修改的文件至少显示一个符号
+//  3. 汇总行中二进制文件只计入文件数
+func WriteStat(w io.Writer, stats []FileStat, width int) error {
+	bw := bufio.NewWriter(w)
+
+	// 1. 各栏的宽度
+	maxLen, maxChange, binWidth, numberWidth := 0, 0, 0, 0
+	for _, s := range stats {
+		maxLen = max(maxLen, utf8.RuneCountInString(s.Path))
+		if s.Binary {
+			// "Bin XXX -> YYY bytes"
+			binWidth = max(binWidth, 14+decimalWidth(s.Added)+decimalWidth(s.Deleted))
+			numberWidth = 3
+			continue
+		}
+		maxChange = max(maxChange, s.Added+s.Deleted)
+	}
+	numberWidth = max(numberWidth, decimalWidth(maxChange))
+	width = max(width, 16+6+numberWidth)
+	graphWidth := maxChange
+	if maxChange+4 <= binWidth {
+		graphWidth = binWidth - 4
+	}
+	nameWidth := maxLen
+	if nameWidth+numberWidth+6+graphWidth > width {
+		if graphWidth > width*3/8-numberWidth-6 {
+			graphWidth = max(width*3/8-numberWidth-6, 6)
+		}
+		if nameWidth > width-numberWidth-6-graphWidth {
+			nameWidth = width - numberWidth - 6 - graphWidth
+		} else {
+			graphWidth = width - numberWidth - 6 - nameWidth
+		}
+	}
+
+	// 2. 每个文件一行
+	files, insertions, deletions := 0, 0, 0
+	for _, s := range stats {
+		files++
+		name, prefix := s.Path, ""
+		if n := utf8.RuneCountInString(name); n > nameWidth {
+			prefix = "..."
+			keep := max(nameWidth-3, 0)
+			for ; n > keep; n-- {
+				_, size := utf8.DecodeRuneInString(name)
+				name = name[size:]
+			}
+			if i := strings.IndexByte(name, '/'); i >= 0 {
+				name = name[i:]
+			}
+		}
+		padding := max(nameWidth-len(prefix)-utf8.RuneCountInString(name), 0)
+		fmt.Fprintf(bw, " %s%s%s | ", prefix, name, strings.Repeat(" ", padding))
+		if s.Binary {
+			fmt.Fprintf(bw, "%*s", numberWidth, "Bin")
+			if s.Added != 0 || s.Deleted != 0 {
+				fmt.Fprintf(bw, " %d -> %d bytes", s.Deleted, s.Added)
+			}
+			bw.WriteString("\n")
+			continue
+		}
+		insertions += s.Added
+		deletions += s.Deleted
+		add, del := s.Added, s.Deleted
+		if graphWidth <= maxChange {
+			total := scaleLinear(add+del, graphWidth, maxChange)
+			if total < 2 && add > 0 && del > 0 {
+				total = 2
+			}
+			if add < del {
+				add = scaleLinear(add, graphWidth, maxChange)
+				del = total - add
+			} else {
+				del = scaleLinear(del, graphWidth, maxChange)
+				add = total - del
+			}
+		}
+		fmt.Fprintf(bw, "%*d", numberWidth, s.Added+s.Deleted)
+		if s.Added+s.Deleted > 0 {
+			bw.WriteString(" ")
+		}
+		bw.WriteString(strings.Repeat("+", add) + strings.Repeat("-", del) + "\n")
+	}
+
+	// 3. 汇总
+	bw.WriteString(statSummary(files, insertions, deletions) + "\n")
+	return bw.Flush()
+}
+
+// WriteShortStat 只输出 WriteStat 的汇总行（git diff --shortstat，也是 git commit 之后显示的统计）
+func WriteShortStat(w io.Writer, stats []FileStat) error {
+	insertions, deletions := 0, 0
+	for _, s := range stats {
+		if !s.Binary {
+			insertions += s.Added
+			deletions += s.Deleted
+		}
+	}
+	_, err := io.WriteString(w, statSummary(len(stats), insertions, deletions)+"\n")
+	return err
+}
+
+// statSummary 返回汇总行，例如 " 2 files changed, 3 insertions(+), 1 deletion(-)"
+// 只有增加或只有删除时省略另一项，都没有时两项都显示
+func statSummary(files, insertions, deletions int) string {
+	if files == 0 {
+		return " 0 files changed"
+	}
+	s := fmt.Sprintf(" %d %s changed", files, plural(files, "file", "files"))
+	if insertions > 0 || deletions == 0 {
+		s += fmt.Sprintf(", %d %s(+)", insertions, plural(insertions, "insertion", "insertions"))
+	}
+	if deletions > 0 || insertions == 0 {
+		s += fmt.Sprintf(", %d %s(-)", deletions, plural(deletions, "deletion", "deletions"))
+	}
+	return s
+}
+
+// scaleLinear 把 it 从 [0, maxChange] 缩放到 [0, width]，不为零的值至少是 1
+func scaleLinear(it, width, maxChange int) int {
+	if it == 0 {
+		return 0
+	}
+	return 1 + it*(width-1)/maxChange
+}
+
+func decimalWidth(n int) int {
+	return len(fmt.Sprint(n))
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
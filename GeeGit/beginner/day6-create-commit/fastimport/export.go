@@ -0,0 +1,626 @@
+package fastimport
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/gpg"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/storage"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// Tip 是要导出的一个起点或排除点，对应 rev-list 风格的一个参数
+type Tip struct {
+	Name    string    // 完整的引用名；不是引用时是命令行上的写法，例如 "main~2"
+	Hash    hash.Hash // 指向的对象，可能是标签
+	Ref     bool      // Name 是引用：导出结束时这个引用一定会被设置
+	Exclude bool      // ^<rev>：不导出从这里可以到达的提交
+}
+
+// ExportOptions 控制导出的内容
+type ExportOptions struct {
+	// ReferenceExcludedParents 为 true 时，被排除的父提交用哈希引用（接收方必须已经有这些提交）；
+	// 否则去掉这些父提交，提交的第一个父提交被排除时按根提交导出完整的文件
+	ReferenceExcludedParents bool
+	FullTree                 bool   // 每个提交都用 deleteall 加上全部文件表示
+	NoData                   bool   // 不输出 blob，文件直接用哈希引用
+	SignedTags               string // 带签名的标签：abort（缺省，报错）、verbatim（保留签名）或 strip（去掉签名）
+	ImportMarks              string // 开始前读取的 marks 文件，其中的提交视为已经导出
+	ExportMarks              string // 结束时写入的 marks 文件
+	Warnings                 io.Writer
+}
+
+// exporter 保存一次导出的状态
+type exporter struct {
+	w    *bufio.Writer
+	s    storage.Storer
+	opts ExportOptions
+
+	marks    map[hash.Hash]int
+	last     int
+	commits  map[hash.Hash]*commit.Commit
+	source   map[hash.Hash]string // 提交以哪个引用名导出
+	shown    map[hash.Hash]bool
+	excluded map[hash.Hash]bool
+	extras   []string             // 结束时需要设置的引用，导出提交用过的名字会被去掉
+	extraTip map[string]hash.Hash // extras 中引用指向的提交
+	tagRefs  []string             // 指向附注标签的引用，按命令行的顺序
+	tagObjs  map[string]hash.Hash // tagRefs 中引用指向的标签对象
+}
+
+// Export 把 tips 选定的历史按 git fast-export 的格式写到 w
+//  1. 起点上的附注标签和引用分别记下，引用名依次作为提交导出时使用的名字
+//  2. 按提交时间遍历，计算要导出的提交，父提交沿用子提交的名字；再按拓扑顺序排列，父提交在前
+//  3. 每个提交在所有父提交导出之后导出：先是新出现的 blob，然后是提交本身和相对于第一个父提交的文件变化
+//  4. 最后设置没有通过提交设置的引用，并导出附注标签
+func Export(w io.Writer, s storage.Storer, tips []Tip, opts ExportOptions) error {
+	e := &exporter{
+		w: bufio.NewWriter(w), s: s, opts: opts,
+		marks:    make(map[hash.Hash]int),
+		commits:  make(map[hash.Hash]*commit.Commit),
+		source:   make(map[hash.Hash]string),
+		shown:    make(map[hash.Hash]bool),
+		excluded: make(map[hash.Hash]bool),
+		extraTip: make(map[string]hash.Hash),
+		tagObjs:  make(map[string]hash.Hash),
+	}
+	if e.opts.Warnings == nil {
+		e.opts.Warnings = io.Discard
+	}
+	if opts.ImportMarks != "" {
+		marks, err := ReadMarks(opts.ImportMarks)
+		if err != nil {
+			return err
+		}
+		for n, h := range marks {
+			e.marks[h] = n
+			e.last = max(e.last, n)
+			if obj, err := s.ReadObject(h); err == nil && obj.Type == hash.CommitObject {
+				e.excluded[h] = true
+			}
+		}
+	}
+
+	// 1. 起点：引用名先于其他写法成为提交的名字
+	var starts, excludes []hash.Hash
+	for _, t := range tips {
+		if t.Exclude {
+			excludes = append(excludes, t.Hash)
+			continue
+		}
+		h, err := e.peel(t)
+		if err != nil {
+			return err
+		}
+		starts = append(starts, h)
+	}
+	i := 0
+	for _, t := range tips {
+		if t.Exclude {
+			continue
+		}
+		if h := starts[i]; !t.Ref && !h.IsZero() && e.source[h] == "" {
+			e.source[h] = t.Name
+		}
+		i++
+	}
+	sort.Strings(e.extras)
+	for i := len(e.extras) - 1; i > 0; i-- {
+		if e.extras[i] == e.extras[i-1] {
+			e.extras = append(e.extras[:i], e.extras[i+1:]...)
+		}
+	}
+	if err := e.exclude(excludes); err != nil {
+		return err
+	}
+
+	// 2. 遍历，按拓扑顺序排列后反过来，父提交在前
+	order, err := e.walk(starts)
+	if err != nil {
+		return err
+	}
+	order = e.topoSort(order)
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+
+	// 3. 父提交都导出之后才导出；暂时不能导出的提交放到栈中
+	var pending []hash.Hash
+	for _, h := range order {
+		if e.hasUnshownParent(h) {
+			pending = append(pending, h)
+			continue
+		}
+		if err := e.exportCommit(h); err != nil {
+			return err
+		}
+		for len(pending) > 0 {
+			last := pending[len(pending)-1]
+			if e.hasUnshownParent(last) {
+				break
+			}
+			if err := e.exportCommit(last); err != nil {
+				return err
+			}
+			pending = pending[:len(pending)-1]
+		}
+	}
+
+	// 4. 其余的引用和附注标签，都按相反的顺序
+	for i := len(e.extras) - 1; i >= 0; i-- {
+		name := e.extras[i]
+		h := e.extraTip[name]
+		switch mark, ok := e.marks[h]; {
+		case ok:
+			fmt.Fprintf(e.w, "reset %s\nfrom :%d\n\n", name, mark)
+		case opts.ReferenceExcludedParents:
+			fmt.Fprintf(e.w, "reset %s\nfrom %s\n\n", name, h)
+		default:
+			fmt.Fprintf(e.w, "reset %s\nfrom %s\n\n", name, hash.Hash{})
+		}
+	}
+	for i := len(e.tagRefs) - 1; i >= 0; i-- {
+		if err := e.exportTag(e.tagRefs[i], e.tagObjs[e.tagRefs[i]]); err != nil {
+			// 与 git 相同，出错之前的内容仍然输出
+			e.w.Flush()
+			return err
+		}
+	}
+	if err := e.w.Flush(); err != nil {
+		return err
+	}
+
+	// 与 git 相同，marks 文件中只有提交
+	if opts.ExportMarks != "" {
+		marks := make(map[int]hash.Hash)
+		for h, n := range e.marks {
+			if _, ok := e.commits[h]; ok {
+				marks[n] = h
+			}
+		}
+		return WriteMarks(opts.ExportMarks, marks)
+	}
+	return nil
+}
+
+// peel 剥开起点上的标签，返回最终的提交；起点是引用时记下这个引用
+// 标签指向的 blob 直接导出，不是提交的起点返回零哈希
+func (e *exporter) peel(t Tip) (hash.Hash, error) {
+	h, isTag := t.Hash, false
+	for {
+		obj, err := e.s.ReadObject(h)
+		if err != nil {
+			return hash.Hash{}, err
+		}
+		switch obj.Type {
+		case hash.TagObject:
+			tg, err := storage.ReadTag(e.s, h)
+			if err != nil {
+				return hash.Hash{}, err
+			}
+			if t.Ref && !isTag {
+				e.tagRefs = append(e.tagRefs, t.Name)
+				e.tagObjs[t.Name] = h
+			}
+			h, isTag = tg.Object, true
+			continue
+		case hash.CommitObject:
+		case hash.BlobObject:
+			if isTag {
+				return hash.Hash{}, e.exportBlob(h)
+			}
+			fallthrough
+		default:
+			if t.Ref {
+				fmt.Fprintf(e.opts.Warnings, "warning: %s: Unexpected object of type %s, skipping.\n", t.Name, obj.Type)
+			}
+			return hash.Hash{}, nil
+		}
+		if t.Ref {
+			if !isTag {
+				e.extras = append(e.extras, t.Name)
+				e.extraTip[t.Name] = h
+			}
+			if e.source[h] == "" {
+				e.source[h] = t.Name
+			}
+		}
+		return h, nil
+	}
+}
+
+// exclude 标记从 excludes 可以到达的所有提交
+func (e *exporter) exclude(excludes []hash.Hash) error {
+	queue := excludes
+	for len(queue) > 0 {
+		h := queue[0]
+		queue = queue[1:]
+		if e.excluded[h] {
+			continue
+		}
+		c, err := e.readCommit(h)
+		if err != nil {
+			return err
+		}
+		e.excluded[h] = true
+		queue = append(queue, c.Parents...)
+	}
+	return nil
+}
+
+// walk 从 starts 出发按提交时间（从新到旧）遍历没有被排除的提交，返回遍历的顺序
+// 父提交还没有名字时沿用子提交的名字
+func (e *exporter) walk(starts []hash.Hash) ([]hash.Hash, error) {
+	seen := make(map[hash.Hash]bool)
+	var list []hash.Hash
+	for _, h := range starts {
+		if h.IsZero() || seen[h] {
+			continue
+		}
+		if _, err := e.readCommit(h); err != nil {
+			return nil, err
+		}
+		seen[h] = true
+		list = append(list, h)
+	}
+	date := func(h hash.Hash) int64 { return e.commits[h].Committer.When.Unix() }
+	sort.SliceStable(list, func(i, j int) bool { return date(list[i]) > date(list[j]) })
+
+	var order []hash.Hash
+	for len(list) > 0 {
+		h := list[0]
+		list = list[1:]
+		if e.excluded[h] {
+			continue
+		}
+		c, err := e.readCommit(h)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range c.Parents {
+			if e.source[p] == "" {
+				e.source[p] = e.source[h]
+			}
+			if seen[p] || e.excluded[p] {
+				continue
+			}
+			seen[p] = true
+			if _, err := e.readCommit(p); err != nil {
+				return nil, err
+			}
+			// 插到时间相同的提交之后
+			i := 0
+			for i < len(list) && date(list[i]) >= date(p) {
+				i++
+			}
+			list = append(list[:i], append([]hash.Hash{p}, list[i:]...)...)
+		}
+		order = append(order, h)
+	}
+	return order, nil
+}
+
+// topoSort 把提交排成子提交在前的拓扑顺序（与 git rev-list --topo-order 相同）：
+// 从没有子提交的提交开始深度优先，提交的所有子提交都输出之后才输出它
+func (e *exporter) topoSort(order []hash.Hash) []hash.Hash {
+	indegree := make(map[hash.Hash]int, len(order))
+	for _, h := range order {
+		indegree[h] = 1
+	}
+	for _, h := range order {
+		for _, p := range e.commits[h].Parents {
+			if indegree[p] > 0 {
+				indegree[p]++
+			}
+		}
+	}
+	var stack []hash.Hash
+	for _, h := range order {
+		if indegree[h] == 1 {
+			stack = append(stack, h)
+		}
+	}
+	for i, j := 0, len(stack)-1; i < j; i, j = i+1, j-1 {
+		stack[i], stack[j] = stack[j], stack[i]
+	}
+
+	sorted := make([]hash.Hash, 0, len(order))
+	for len(stack) > 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, p := range e.commits[h].Parents {
+			if indegree[p] == 0 {
+				continue
+			}
+			if indegree[p]--; indegree[p] == 1 {
+				stack = append(stack, p)
+			}
+		}
+		indegree[h] = 0
+		sorted = append(sorted, h)
+	}
+	return sorted
+}
+
+func (e *exporter) hasUnshownParent(h hash.Hash) bool {
+	for _, p := range e.commits[h].Parents {
+		if !e.shown[p] && !e.excluded[p] {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *exporter) readCommit(h hash.Hash) (*commit.Commit, error) {
+	if c, ok := e.commits[h]; ok {
+		return c, nil
+	}
+	c, err := storage.ReadCommit(e.s, h)
+	if err != nil {
+		return nil, err
+	}
+	e.commits[h] = c
+	return c, nil
+}
+
+// exportBlob 导出还没有 mark 的 blob
+func (e *exporter) exportBlob(h hash.Hash) error {
+	if _, ok := e.marks[h]; ok || e.opts.NoData {
+		return nil
+	}
+	b, err := storage.ReadBlob(e.s, h)
+	if err != nil {
+		return err
+	}
+	e.last++
+	e.marks[h] = e.last
+	fmt.Fprintf(e.w, "blob\nmark :%d\ndata %d\n", e.last, len(b.Data))
+	e.w.Write(b.Data)
+	e.w.WriteString("\n")
+	return nil
+}
+
+// exportCommit 导出一个提交：新的 blob、提交的头部和说明、父提交、相对于第一个父提交的文件变化
+func (e *exporter) exportCommit(h hash.Hash) error {
+	c := e.commits[h]
+
+	// 1. 文件变化：第一个父提交被排除且不引用被排除的提交时，相对于空 tree
+	var base hash.Hash
+	if len(c.Parents) > 0 && !e.opts.FullTree {
+		if _, ok := e.marks[c.Parents[0]]; ok || e.opts.ReferenceExcludedParents {
+			pc, err := e.readCommit(c.Parents[0])
+			if err != nil {
+				return err
+			}
+			base = pc.Tree
+		}
+	}
+	changes, err := diffTrees(e.s, base, c.Tree, "")
+	if err != nil {
+		return err
+	}
+	for _, ch := range changes {
+		if ch.new != nil && !ch.new.IsSubmodule() {
+			if err := e.exportBlob(ch.new.Hash); err != nil {
+				return err
+			}
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return depthFirst(changes[i].path, changes[j].path) })
+
+	// 2. 提交本身
+	name := e.source[h]
+	if len(c.Parents) == 0 {
+		fmt.Fprintf(e.w, "reset %s\n", name)
+	}
+	e.last++
+	e.marks[h] = e.last
+	fmt.Fprintf(e.w, "commit %s\nmark :%d\n", name, e.last)
+	fmt.Fprintf(e.w, "author %s\ncommitter %s\n", commit.FormatSignature(c.Author), commit.FormatSignature(c.Committer))
+	fmt.Fprintf(e.w, "data %d\n%s", len(c.Message), c.Message)
+	first := true
+	for _, p := range c.Parents {
+		mark, ok := e.marks[p]
+		if !ok && !e.opts.ReferenceExcludedParents {
+			continue
+		}
+		if first {
+			e.w.WriteString("from ")
+		} else {
+			e.w.WriteString("merge ")
+		}
+		first = false
+		if ok {
+			fmt.Fprintf(e.w, ":%d\n", mark)
+		} else {
+			fmt.Fprintf(e.w, "%s\n", p)
+		}
+	}
+	if e.opts.FullTree {
+		e.w.WriteString("deleteall\n")
+	}
+
+	// 3. 文件变化
+	for _, ch := range changes {
+		p := quotePath(ch.path)
+		if ch.new == nil {
+			fmt.Fprintf(e.w, "D %s\n", p)
+			continue
+		}
+		if mark, ok := e.marks[ch.new.Hash]; ok && !ch.new.IsSubmodule() {
+			fmt.Fprintf(e.w, "M %s :%d %s\n", ch.new.Mode, mark, p)
+		} else {
+			fmt.Fprintf(e.w, "M %s %s %s\n", ch.new.Mode, ch.new.Hash, p)
+		}
+	}
+	e.w.WriteString("\n")
+
+	e.shown[h] = true
+	for i, name := range e.extras {
+		if name == e.source[h] {
+			e.extras = append(e.extras[:i], e.extras[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// exportTag 导出附注标签 name
+func (e *exporter) exportTag(name string, h hash.Hash) error {
+	t, err := storage.ReadTag(e.s, h)
+	if err != nil {
+		return err
+	}
+
+	// 1. 签名
+	msg := t.Message
+	if payload, sig := gpg.ParseSigned([]byte(msg)); sig != "" {
+		switch e.opts.SignedTags {
+		case "verbatim":
+		case "strip":
+			msg = string(payload)
+		default:
+			return fmt.Errorf("encountered signed tag %s; use --signed-tags=<mode> to handle it", h)
+		}
+	}
+
+	// 2. 标签指向的对象：嵌套的标签已经按各自的引用导出
+	var from string
+	switch t.Type {
+	case hash.CommitObject, hash.BlobObject:
+		if t.Type == hash.BlobObject {
+			if err := e.exportBlob(t.Object); err != nil {
+				return err
+			}
+		}
+		if mark, ok := e.marks[t.Object]; ok {
+			from = fmt.Sprintf(":%d", mark)
+		} else if e.opts.ReferenceExcludedParents || e.opts.NoData {
+			from = t.Object.String()
+		} else {
+			return fmt.Errorf("tag %s tags unexported object; use --tag-of-filtered-object=<mode> to handle it", h)
+		}
+	case hash.TagObject:
+		from = t.Object.String()
+	default:
+		fmt.Fprintf(e.opts.Warnings, "warning: Omitting tag %s,\nsince tags of trees (or tags of tags of trees, etc.) are not supported.\n", h)
+		return nil
+	}
+
+	fmt.Fprintf(e.w, "tag %s\nfrom %s\n", strings.TrimPrefix(name, "refs/tags/"), from)
+	if !t.Tagger.When.IsZero() || t.Tagger.Name != "" {
+		fmt.Fprintf(e.w, "tagger %s\n", commit.FormatSignature(t.Tagger))
+	}
+	fmt.Fprintf(e.w, "data %d\n%s\n", len(msg), msg)
+	return nil
+}
+
+// change 是两个 tree 之间一个路径的变化，old 或 new 为 nil 表示不存在
+type change struct {
+	path     string
+	old, new *tree.TreeEntry
+}
+
+// diffTrees 递归比较两个 tree（零哈希表示空 tree），按 tree 中的顺序返回文件的变化
+func diffTrees(s storage.ObjectStorer, a, b hash.Hash, prefix string) ([]change, error) {
+	read := func(h hash.Hash) ([]tree.TreeEntry, error) {
+		if h.IsZero() {
+			return nil, nil
+		}
+		t, err := storage.ReadTree(s, h)
+		if err != nil {
+			return nil, err
+		}
+		return t.Entries, nil
+	}
+	oldEntries, err := read(a)
+	if err != nil {
+		return nil, err
+	}
+	newEntries, err := read(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []change
+	i, j := 0, 0
+	for i < len(oldEntries) || j < len(newEntries) {
+		var o, n *tree.TreeEntry
+		switch {
+		case j >= len(newEntries):
+			o = &oldEntries[i]
+		case i >= len(oldEntries):
+			n = &newEntries[j]
+		default:
+			ko, kn := entryKey(oldEntries[i]), entryKey(newEntries[j])
+			switch {
+			case ko < kn:
+				o = &oldEntries[i]
+			case ko > kn:
+				n = &newEntries[j]
+			default:
+				o, n = &oldEntries[i], &newEntries[j]
+			}
+		}
+		if o != nil {
+			i++
+		}
+		if n != nil {
+			j++
+		}
+		if o != nil && n != nil && o.Mode == n.Mode && o.Hash == n.Hash {
+			continue
+		}
+
+		name := path.Join(prefix, entryName(o, n))
+		var oldTree, newTree hash.Hash
+		if o != nil && o.IsDir() {
+			oldTree, o = o.Hash, nil
+		}
+		if n != nil && n.IsDir() {
+			newTree, n = n.Hash, nil
+		}
+		if o != nil || n != nil {
+			changes = append(changes, change{path: name, old: o, new: n})
+		}
+		if !oldTree.IsZero() || !newTree.IsZero() {
+			sub, err := diffTrees(s, oldTree, newTree, name)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, sub...)
+		}
+	}
+	return changes, nil
+}
+
+// entryKey 是 tree 中排序用的名字：目录名视为以 "/" 结尾
+func entryKey(e tree.TreeEntry) string {
+	if e.IsDir() {
+		return e.Name + "/"
+	}
+	return e.Name
+}
+
+func entryName(o, n *tree.TreeEntry) string {
+	if o != nil {
+		return o.Name
+	}
+	return n.Name
+}
+
+// depthFirst 是文件变化的输出顺序：按路径排序，但一个路径是另一个的前缀时较长的在前，
+// 这样 "d/e" 的变化在 "d" 之前（先删除目录中的文件，再把目录换成文件）
+func depthFirst(a, b string) bool {
+	n := min(len(a), len(b))
+	if a[:n] != b[:n] {
+		return a[:n] < b[:n]
+	}
+	return len(a) > len(b)
+}
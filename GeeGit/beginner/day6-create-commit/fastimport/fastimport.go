@@ -0,0 +1,187 @@
+package fastimport
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/lockfile"
+)
+
+// fast-import 流是转换其他版本控制系统历史的通用格式（git fast-import / fast-export），由一系列命令组成：
+//
+//	blob                       文件内容：mark :<n>，然后是 data
+//	commit <ref>               提交：mark、author、committer、data（提交说明）、from、merge，
+//	                           然后是文件变化 M <mode> <dataref> <path>、D <path>、C/R <src> <dst>、deleteall
+//	tag <name>                 附注标签：from、tagger、data
+//	reset <ref>                重置分支，可以带 from
+//	progress / checkpoint / done / feature / option
+//
+// data 有两种形式："data <长度>" 后面紧跟这么多字节；"data <<<分隔符>" 后面的行直到分隔符所在的行。
+// mark（":<n>"）是流中给对象起的编号，之后可以代替哈希引用这个对象；marks 文件每行是 ":<n> <哈希>"。
+// 路径中有特殊字符时使用 C 风格的引号，与 git 的输出一致
+
+// ReadMarks 读取 marks 文件
+func ReadMarks(path string) (map[int]hash.Hash, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	marks := make(map[int]hash.Hash)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		mark, hex, ok := strings.Cut(line, " ")
+		n, err := parseMark(mark)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("corrupt mark line: %s", line)
+		}
+		h, err := hash.ParseHash(hex)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt mark line: %s", line)
+		}
+		marks[n] = h
+	}
+	return marks, scanner.Err()
+}
+
+// WriteMarks 按编号顺序写入 marks 文件
+func WriteMarks(path string, marks map[int]hash.Hash) error {
+	nums := make([]int, 0, len(marks))
+	for n := range marks {
+		nums = append(nums, n)
+	}
+	sort.Ints(nums)
+
+	lock, err := lockfile.Acquire(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(lock)
+	for _, n := range nums {
+		fmt.Fprintf(w, ":%d %s\n", n, marks[n])
+	}
+	if err := w.Flush(); err != nil {
+		lock.Rollback()
+		return err
+	}
+	return lock.Commit()
+}
+
+// parseMark 解析 ":<n>" 形式的 mark
+func parseMark(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(s, ":"))
+	if !strings.HasPrefix(s, ":") || err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid mark: %s", s)
+	}
+	return n, nil
+}
+
+// quotePath 按 git fast-export 的规则输出路径：有控制字符、引号、反斜杠或非 ASCII 字符时使用 C 风格的转义，
+// 否则只在包含空格时加上引号
+func quotePath(p string) string {
+	needQuote := false
+	for i := 0; i < len(p); i++ {
+		if c := p[i]; c < 0x20 || c == '"' || c == '\\' || c >= 0x7f {
+			needQuote = true
+			break
+		}
+	}
+	if !needQuote {
+		if strings.Contains(p, " ") {
+			return `"` + p + `"`
+		}
+		return p
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\a':
+			b.WriteString(`\a`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\v':
+			b.WriteString(`\v`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if c < 0x20 || c >= 0x7f {
+				fmt.Fprintf(&b, "\\%03o", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// unquotePath 解析以引号开头的路径，返回路径和引号之后剩下的内容
+func unquotePath(s string) (string, string, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			return b.String(), s[i+1:], nil
+		case c != '\\':
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i >= len(s) {
+			break
+		}
+		switch c = s[i]; c {
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'v':
+			b.WriteByte('\v')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case '"', '\\':
+			b.WriteByte(c)
+		default:
+			if i+3 > len(s) {
+				return "", "", errors.New("invalid escape")
+			}
+			n, err := strconv.ParseUint(s[i:i+3], 8, 8)
+			if err != nil {
+				return "", "", fmt.Errorf("invalid escape: \\%s", s[i:i+3])
+			}
+			b.WriteByte(byte(n))
+			i += 2
+		}
+	}
+	return "", "", fmt.Errorf("invalid path: %s", s)
+}
@@ -0,0 +1,826 @@
+package fastimport
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/storage"
+	"geegit/beginner/day6-create-commit/tag"
+)
+
+// ImportOptions 控制导入的方式
+type ImportOptions struct {
+	Force       bool   // 允许分支非快进地更新
+	DateFormat  string // 身份中日期的格式：raw（缺省，"<秒数> <时区>"）、raw-permissive、rfc2822 或 now
+	ImportMarks string // 开始前读取的 marks 文件
+	// IgnoreMissingMarks 为 true 时 ImportMarks 不存在也不报错（--import-marks-if-exists）
+	IgnoreMissingMarks bool
+	ExportMarks        string    // 结束时写入的 marks 文件
+	Done               bool      // 流必须以 done 命令结束（--done）
+	Output             io.Writer // progress 命令的输出，nil 时丢弃
+}
+
+// Refused 是因为不是快进而没有更新的分支
+type Refused struct {
+	Name     string
+	New, Old hash.Hash
+}
+
+// Stats 是导入的统计
+type Stats struct {
+	Objects    map[hash.ObjectType]int // 按类型统计新写入的对象
+	Duplicates map[hash.ObjectType]int // 对象库里已经存在、不需要写入的对象
+	Branches   int                     // 流中出现的分支
+	Marks      int                     // 定义过的 mark
+	Refused    []Refused
+}
+
+// branch 是导入过程中的一个分支
+type branch struct {
+	tip    hash.Hash // 零哈希表示还没有提交（reset 之后）
+	delete bool      // reset 到零哈希：结束时删除这个引用
+}
+
+// importer 保存一次导入的状态
+type importer struct {
+	r     *bufio.Reader
+	s     storage.Storer
+	opts  ImportOptions
+	stats *Stats
+
+	line    string // 当前还没有处理的命令行
+	eof     bool
+	sawData bool // 已经处理过 blob、commit 或 tag，不能再出现 feature
+	needEnd bool // feature done：流必须以 done 结束
+
+	marks    map[int]hash.Hash
+	branches map[string]*branch
+	tagNames []string // 按出现的顺序
+	tags     map[string]hash.Hash
+}
+
+// Import 读取 fast-import 流，把对象通过 s 写入对象库，最后更新流中出现的分支和标签
+// 分支只在快进时更新（Force 时总是更新），没有更新的分支记录在 Stats.Refused 中；标签总是更新
+func Import(r io.Reader, s storage.Storer, opts ImportOptions) (*Stats, error) {
+	im := &importer{
+		r: bufio.NewReader(r), s: s, opts: opts,
+		stats:    &Stats{Objects: make(map[hash.ObjectType]int), Duplicates: make(map[hash.ObjectType]int)},
+		needEnd:  opts.Done,
+		marks:    make(map[int]hash.Hash),
+		branches: make(map[string]*branch),
+		tags:     make(map[string]hash.Hash),
+	}
+	if im.opts.Output == nil {
+		im.opts.Output = io.Discard
+	}
+	if err := im.checkDateFormat(); err != nil {
+		return nil, err
+	}
+	if opts.ImportMarks != "" {
+		if err := im.importMarks(opts.ImportMarks, opts.IgnoreMissingMarks); err != nil {
+			return nil, err
+		}
+	}
+
+	// 1. 逐个处理命令
+	if err := im.next(); err != nil {
+		return nil, err
+	}
+	for !im.eof {
+		cmd := im.line
+		var err error
+		switch {
+		case cmd == "":
+			err = im.next()
+		case cmd == "blob":
+			im.sawData = true
+			err = im.parseBlob()
+		case strings.HasPrefix(cmd, "commit "):
+			im.sawData = true
+			err = im.parseCommit(strings.TrimPrefix(cmd, "commit "))
+		case strings.HasPrefix(cmd, "tag "):
+			im.sawData = true
+			err = im.parseTag(strings.TrimPrefix(cmd, "tag "))
+		case strings.HasPrefix(cmd, "reset "):
+			err = im.parseReset(strings.TrimPrefix(cmd, "reset "))
+		case strings.HasPrefix(cmd, "progress "):
+			fmt.Fprintln(im.opts.Output, cmd)
+			err = im.next()
+		case cmd == "checkpoint":
+			if err = im.updateRefs(); err == nil {
+				err = im.next()
+			}
+		case cmd == "done":
+			im.needEnd = false
+			im.eof = true
+		case strings.HasPrefix(cmd, "feature "):
+			if err = im.parseFeature(strings.TrimPrefix(cmd, "feature ")); err == nil {
+				err = im.next()
+			}
+		case strings.HasPrefix(cmd, "option "):
+			// 针对其他工具或者命令行参数的选项，忽略
+			err = im.next()
+		default:
+			err = fmt.Errorf("unsupported command: %s", cmd)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if im.needEnd {
+		return nil, errors.New("stream ends early")
+	}
+
+	// 2. 更新引用，写出 marks
+	if err := im.updateRefs(); err != nil {
+		return nil, err
+	}
+	im.stats.Branches = len(im.branches)
+	im.stats.Marks = len(im.marks)
+	if im.opts.ExportMarks != "" {
+		if err := WriteMarks(im.opts.ExportMarks, im.marks); err != nil {
+			return nil, err
+		}
+	}
+	return im.stats, nil
+}
+
+// next 读取下一个命令行，跳过注释
+func (im *importer) next() error {
+	for {
+		line, err := im.r.ReadString('\n')
+		if err == io.EOF && line == "" {
+			im.line, im.eof = "", true
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		im.line = strings.TrimSuffix(line, "\n")
+		return nil
+	}
+}
+
+// parseData 读取当前行开始的 data 命令，返回数据并移到下一个命令
+func (im *importer) parseData() ([]byte, error) {
+	arg, ok := strings.CutPrefix(im.line, "data ")
+	if !ok {
+		return nil, fmt.Errorf("expected 'data n' command, found: %s", im.line)
+	}
+
+	var data []byte
+	if delim, ok := strings.CutPrefix(arg, "<<"); ok {
+		// data <<分隔符：之后的行直到分隔符所在的行
+		var buf bytes.Buffer
+		for {
+			line, err := im.r.ReadString('\n')
+			if err != nil {
+				return nil, fmt.Errorf("EOF in data (terminator '%s' not found)", delim)
+			}
+			if strings.TrimSuffix(line, "\n") == delim {
+				break
+			}
+			buf.WriteString(line)
+		}
+		data = buf.Bytes()
+	} else {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid data length: %s", arg)
+		}
+		data = make([]byte, n)
+		if _, err := io.ReadFull(im.r, data); err != nil {
+			return nil, fmt.Errorf("EOF in data (%d bytes remaining)", n)
+		}
+	}
+
+	// 数据之后可以有一个可选的换行
+	if b, err := im.r.Peek(1); err == nil && b[0] == '\n' {
+		im.r.ReadByte()
+	}
+	return data, im.next()
+}
+
+// parseMark 解析可选的 mark 行
+func (im *importer) parseMark() (int, error) {
+	arg, ok := strings.CutPrefix(im.line, "mark ")
+	if !ok {
+		return 0, nil
+	}
+	n, err := parseMark(arg)
+	if err != nil {
+		return 0, err
+	}
+	return n, im.next()
+}
+
+// skipOriginalOID 跳过可选的 original-oid 行，它只对其他工具有意义
+func (im *importer) skipOriginalOID() error {
+	if strings.HasPrefix(im.line, "original-oid ") {
+		return im.next()
+	}
+	return nil
+}
+
+func (im *importer) setMark(n int, h hash.Hash) {
+	if n > 0 {
+		im.marks[n] = h
+	}
+}
+
+// write 写入对象，对象库中已经有的对象计为重复
+func (im *importer) write(t hash.ObjectType, content []byte) (hash.Hash, error) {
+	if h := hash.ComputeHash(t, content); im.s.HasObject(h) {
+		im.stats.Duplicates[t]++
+		return h, nil
+	}
+	im.stats.Objects[t]++
+	return im.s.WriteObject(t, content)
+}
+
+// parseBlob 处理 blob 命令
+func (im *importer) parseBlob() error {
+	if err := im.next(); err != nil {
+		return err
+	}
+	mark, err := im.parseMark()
+	if err != nil {
+		return err
+	}
+	if err := im.skipOriginalOID(); err != nil {
+		return err
+	}
+	data, err := im.parseData()
+	if err != nil {
+		return err
+	}
+	h, err := im.write(hash.BlobObject, data)
+	if err != nil {
+		return err
+	}
+	im.setMark(mark, h)
+	return nil
+}
+
+// parseCommit 处理 commit 命令：在 from 指定的提交（缺省是分支当前的提交）的 tree 上应用文件变化，写入新的提交
+func (im *importer) parseCommit(ref string) error {
+	// 1. 头部和提交说明
+	if err := im.next(); err != nil {
+		return err
+	}
+	mark, err := im.parseMark()
+	if err != nil {
+		return err
+	}
+	if err := im.skipOriginalOID(); err != nil {
+		return err
+	}
+	var author, committer commit.Signature
+	hasAuthor := false
+	if arg, ok := strings.CutPrefix(im.line, "author "); ok {
+		if author, err = im.parseIdent(arg); err != nil {
+			return err
+		}
+		hasAuthor = true
+		if err := im.next(); err != nil {
+			return err
+		}
+	}
+	arg, ok := strings.CutPrefix(im.line, "committer ")
+	if !ok {
+		return errors.New("expected committer but didn't get one")
+	}
+	if committer, err = im.parseIdent(arg); err != nil {
+		return err
+	}
+	if !hasAuthor {
+		author = committer
+	}
+	if err := im.next(); err != nil {
+		return err
+	}
+	encoding := ""
+	if arg, ok := strings.CutPrefix(im.line, "encoding "); ok {
+		encoding = arg
+		if err := im.next(); err != nil {
+			return err
+		}
+	}
+	msg, err := im.parseData()
+	if err != nil {
+		return err
+	}
+
+	// 2. 父提交
+	b := im.branch(ref)
+	var parents []hash.Hash
+	if arg, ok := strings.CutPrefix(im.line, "from "); ok {
+		h, err := im.resolveCommit(arg)
+		if err != nil {
+			return err
+		}
+		if !h.IsZero() {
+			parents = append(parents, h)
+		}
+		if err := im.next(); err != nil {
+			return err
+		}
+	} else if !b.tip.IsZero() {
+		parents = append(parents, b.tip)
+	}
+	for {
+		arg, ok := strings.CutPrefix(im.line, "merge ")
+		if !ok {
+			break
+		}
+		h, err := im.resolveCommit(arg)
+		if err != nil {
+			return err
+		}
+		parents = append(parents, h)
+		if err := im.next(); err != nil {
+			return err
+		}
+	}
+
+	// 3. 文件变化
+	root := newDir(hash.Hash{})
+	if len(parents) > 0 {
+		c, err := storage.ReadCommit(im.s, parents[0])
+		if err != nil {
+			return err
+		}
+		root = newDir(c.Tree)
+	}
+	for more := true; more && !im.eof; {
+		var err error
+		switch cmd := im.line; {
+		case strings.HasPrefix(cmd, "M "):
+			err = im.fileModify(root, cmd[2:])
+		case strings.HasPrefix(cmd, "D "):
+			err = im.fileDelete(root, cmd[2:])
+		case strings.HasPrefix(cmd, "C "), strings.HasPrefix(cmd, "R "):
+			err = im.fileCopy(root, cmd[2:], cmd[0] == 'R')
+		case cmd == "deleteall":
+			root = newDir(hash.Hash{})
+			err = im.next()
+		case strings.HasPrefix(cmd, "N "):
+			err = errors.New("notes are not supported")
+		case cmd == "":
+			err, more = im.next(), false
+		default:
+			more = false
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// 4. 写入 tree 和提交
+	treeHash, err := root.write(im)
+	if err != nil {
+		return err
+	}
+	content := commit.Encode(&commit.Commit{
+		Tree: treeHash, Parents: parents, Author: author, Committer: committer, Message: string(msg),
+	})
+	if encoding != "" {
+		i := bytes.Index(content, []byte("\n\n"))
+		content = append(content[:i+1:i+1], append([]byte("encoding "+encoding+"\n"), content[i+1:]...)...)
+	}
+	h, err := im.write(hash.CommitObject, content)
+	if err != nil {
+		return err
+	}
+	b.tip, b.delete = h, false
+	im.setMark(mark, h)
+	return nil
+}
+
+// fileModify 处理 M <mode> <dataref> <path>，dataref 是 mark、哈希或者 inline（数据紧跟在后面）
+func (im *importer) fileModify(root *node, arg string) error {
+	mode, rest, _ := strings.Cut(arg, " ")
+	ref, rest, _ := strings.Cut(rest, " ")
+	p, err := parsePath(rest)
+	if err != nil {
+		return err
+	}
+	switch mode {
+	case "644", "100644", "0100644":
+		mode = "100644"
+	case "755", "100755", "0100755":
+		mode = "100755"
+	case "120000", "160000":
+	case "040000", "40000":
+		mode = "40000"
+	default:
+		return fmt.Errorf("corrupt mode: M %s", arg)
+	}
+
+	var h hash.Hash
+	if ref == "inline" {
+		if mode == "160000" || mode == "40000" {
+			return fmt.Errorf("directories and submodules cannot be specified 'inline': %s", p)
+		}
+		if err := im.next(); err != nil {
+			return err
+		}
+		data, err := im.parseData()
+		if err != nil {
+			return err
+		}
+		if h, err = im.write(hash.BlobObject, data); err != nil {
+			return err
+		}
+	} else {
+		if h, err = im.resolveObject(ref); err != nil {
+			return err
+		}
+		if mode != "160000" && !im.s.HasObject(h) {
+			return fmt.Errorf("path %s: object %s not found", p, ref)
+		}
+		if err := im.next(); err != nil {
+			return err
+		}
+	}
+	if p == "" && mode == "40000" {
+		*root = *newDir(h)
+		return nil
+	}
+	return root.set(im.s, p, &node{mode: mode, hash: h})
+}
+
+// fileDelete 处理 D <path>
+func (im *importer) fileDelete(root *node, arg string) error {
+	p, err := parsePath(arg)
+	if err != nil {
+		return err
+	}
+	if err := root.set(im.s, p, nil); err != nil {
+		return err
+	}
+	return im.next()
+}
+
+// fileCopy 处理 C <src> <dst> 和 R <src> <dst>；没有引号的源路径到第一个空格为止
+func (im *importer) fileCopy(root *node, arg string, rename bool) error {
+	var src, rest string
+	var err error
+	if strings.HasPrefix(arg, `"`) {
+		if src, rest, err = unquotePath(arg); err != nil {
+			return err
+		}
+		rest = strings.TrimPrefix(rest, " ")
+	} else {
+		src, rest, _ = strings.Cut(arg, " ")
+	}
+	dst, err := parsePath(rest)
+	if err != nil {
+		return err
+	}
+	n, err := root.lookup(im.s, src)
+	if err != nil {
+		return err
+	}
+	if n == nil {
+		return fmt.Errorf("path %s not in branch", src)
+	}
+	n = n.clone()
+	if rename {
+		if err := root.set(im.s, src, nil); err != nil {
+			return err
+		}
+	}
+	if err := root.set(im.s, dst, n); err != nil {
+		return err
+	}
+	return im.next()
+}
+
+// parsePath 解析行末的路径，可以带引号
+func parsePath(s string) (string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return s, nil
+	}
+	p, rest, err := unquotePath(s)
+	if err != nil {
+		return "", err
+	}
+	if rest != "" {
+		return "", fmt.Errorf("garbage after path: %s", s)
+	}
+	return p, nil
+}
+
+// parseTag 处理 tag 命令，写入附注标签对象，结束时更新 refs/tags/<name>
+func (im *importer) parseTag(name string) error {
+	if err := im.next(); err != nil {
+		return err
+	}
+	mark, err := im.parseMark()
+	if err != nil {
+		return err
+	}
+	arg, ok := strings.CutPrefix(im.line, "from ")
+	if !ok {
+		return errors.New("expected from command")
+	}
+	target, err := im.resolveObject(arg)
+	if err != nil {
+		return err
+	}
+	obj, err := im.s.ReadObject(target)
+	if err != nil {
+		return err
+	}
+	if err := im.next(); err != nil {
+		return err
+	}
+	if err := im.skipOriginalOID(); err != nil {
+		return err
+	}
+	t := &tag.Tag{Object: target, Type: obj.Type, Name: name}
+	hasTagger := false
+	if arg, ok := strings.CutPrefix(im.line, "tagger "); ok {
+		if t.Tagger, err = im.parseIdent(arg); err != nil {
+			return err
+		}
+		hasTagger = true
+		if err := im.next(); err != nil {
+			return err
+		}
+	}
+	msg, err := im.parseData()
+	if err != nil {
+		return err
+	}
+	t.Message = string(msg)
+
+	content := tag.Encode(t)
+	if !hasTagger {
+		content = []byte(fmt.Sprintf("object %s\ntype %s\ntag %s\n\n%s", t.Object, t.Type, t.Name, t.Message))
+	}
+	h, err := im.write(hash.TagObject, content)
+	if err != nil {
+		return err
+	}
+	if _, ok := im.tags[name]; !ok {
+		im.tagNames = append(im.tagNames, name)
+	}
+	im.tags[name] = h
+	im.setMark(mark, h)
+	return nil
+}
+
+// parseReset 处理 reset 命令：分支回到 from 指定的提交，没有 from 时之后的提交从空的历史开始
+func (im *importer) parseReset(ref string) error {
+	b := im.branch(ref)
+	b.tip, b.delete = hash.Hash{}, false
+	if err := im.next(); err != nil {
+		return err
+	}
+	arg, ok := strings.CutPrefix(im.line, "from ")
+	if !ok {
+		return nil
+	}
+	h, err := im.resolveCommit(arg)
+	if err != nil {
+		return err
+	}
+	b.tip, b.delete = h, h.IsZero()
+	return im.next()
+}
+
+// parseFeature 处理 feature 命令；命令行上给出的选项优先
+func (im *importer) parseFeature(feature string) error {
+	if im.sawData {
+		return fmt.Errorf("got feature command '%s' after data command", feature)
+	}
+	name, value, _ := strings.Cut(feature, "=")
+	switch name {
+	case "date-format":
+		if im.opts.DateFormat == "" {
+			im.opts.DateFormat = value
+		}
+		return im.checkDateFormat()
+	case "import-marks", "import-marks-if-exists":
+		if im.opts.ImportMarks != "" {
+			return nil
+		}
+		return im.importMarks(value, name == "import-marks-if-exists")
+	case "export-marks":
+		if im.opts.ExportMarks == "" {
+			im.opts.ExportMarks = value
+		}
+	case "force":
+		im.opts.Force = true
+	case "done":
+		im.needEnd = true
+	default:
+		return fmt.Errorf("this version of fast-import does not support feature %s", feature)
+	}
+	return nil
+}
+
+func (im *importer) checkDateFormat() error {
+	switch im.opts.DateFormat {
+	case "", "raw", "raw-permissive", "rfc2822", "now":
+		return nil
+	}
+	return fmt.Errorf("unknown --date-format argument %s", im.opts.DateFormat)
+}
+
+// importMarks 读取 marks 文件
+func (im *importer) importMarks(path string, ignoreMissing bool) error {
+	marks, err := ReadMarks(path)
+	if ignoreMissing && errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for n, h := range marks {
+		im.marks[n] = h
+	}
+	return nil
+}
+
+// parseIdent 解析 "<name> <<email>> <date>"，日期按 DateFormat 解析
+func (im *importer) parseIdent(s string) (commit.Signature, error) {
+	open, end := strings.IndexByte(s, '<'), strings.IndexByte(s, '>')
+	if open < 0 || end < open {
+		return commit.Signature{}, fmt.Errorf("missing < in ident string: %s", s)
+	}
+	sig := commit.Signature{Name: strings.TrimSpace(s[:open]), Email: s[open+1 : end]}
+	date := strings.TrimSpace(s[end+1:])
+
+	switch im.opts.DateFormat {
+	case "now":
+		if date != "now" {
+			return commit.Signature{}, fmt.Errorf("date in ident must be 'now': %s", s)
+		}
+		sig.When = time.Now()
+	case "rfc2822":
+		t, err := time.Parse("Mon, 2 Jan 2006 15:04:05 -0700", date)
+		if err != nil {
+			return commit.Signature{}, fmt.Errorf("invalid date: %s", s)
+		}
+		sig.When = t
+	default:
+		parsed, err := commit.ParseSignature("x <x> " + date)
+		if err != nil {
+			return commit.Signature{}, fmt.Errorf("invalid raw date \"%s\" in ident: %s", date, s)
+		}
+		sig.When = parsed.When
+	}
+	return sig, nil
+}
+
+// branch 返回分支的状态，第一次出现时从空的历史开始
+func (im *importer) branch(ref string) *branch {
+	b, ok := im.branches[ref]
+	if !ok {
+		b = &branch{}
+		im.branches[ref] = b
+	}
+	return b
+}
+
+// resolveObject 解析 mark（":<n>"）、完整的哈希、流中的分支或者仓库中已有的引用
+func (im *importer) resolveObject(ref string) (hash.Hash, error) {
+	if strings.HasPrefix(ref, ":") {
+		n, err := parseMark(ref)
+		if err != nil {
+			return hash.Hash{}, err
+		}
+		h, ok := im.marks[n]
+		if !ok {
+			return hash.Hash{}, fmt.Errorf("mark :%d not declared", n)
+		}
+		return h, nil
+	}
+	if h, err := hash.ParseHash(ref); err == nil {
+		return h, nil
+	}
+	name := strings.TrimSuffix(ref, "^0")
+	if b, ok := im.branches[name]; ok && !b.tip.IsZero() {
+		return b.tip, nil
+	}
+	for _, candidate := range []string{name, "refs/" + name, "refs/tags/" + name, "refs/heads/" + name} {
+		if r, err := im.s.Reference(candidate); err == nil {
+			return r.Hash, nil
+		} else if !errors.Is(err, refs.ErrNotFound) {
+			return hash.Hash{}, err
+		}
+	}
+	return hash.Hash{}, fmt.Errorf("invalid ref name or SHA1 expression: %s", ref)
+}
+
+// resolveCommit 与 resolveObject 相同，但剥开标签得到提交；零哈希原样返回
+func (im *importer) resolveCommit(ref string) (hash.Hash, error) {
+	h, err := im.resolveObject(ref)
+	if err != nil || h.IsZero() {
+		return h, err
+	}
+	for {
+		obj, err := im.s.ReadObject(h)
+		if err != nil {
+			return hash.Hash{}, err
+		}
+		switch obj.Type {
+		case hash.CommitObject:
+			return h, nil
+		case hash.TagObject:
+			t, err := storage.ReadTag(im.s, h)
+			if err != nil {
+				return hash.Hash{}, err
+			}
+			h = t.Object
+		default:
+			return hash.Hash{}, fmt.Errorf("mark or object %s is not a commit", ref)
+		}
+	}
+}
+
+// updateRefs 按名称顺序更新分支，然后更新标签
+func (im *importer) updateRefs() error {
+	im.stats.Refused = nil
+	names := make([]string, 0, len(im.branches))
+	for name := range im.branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		b := im.branches[name]
+		if b.tip.IsZero() {
+			if b.delete {
+				if err := im.s.RemoveReference(name, nil); err != nil && !errors.Is(err, refs.ErrNotFound) {
+					return err
+				}
+			}
+			continue
+		}
+		old, err := im.s.Reference(name)
+		if err != nil && !errors.Is(err, refs.ErrNotFound) {
+			return err
+		}
+		if err == nil && old.Hash != b.tip && !im.opts.Force {
+			ok, err := im.contains(b.tip, old.Hash)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				im.stats.Refused = append(im.stats.Refused, Refused{Name: name, New: b.tip, Old: old.Hash})
+				continue
+			}
+		}
+		if err := im.s.UpdateReference(name, b.tip, nil, "fast-import"); err != nil {
+			return err
+		}
+	}
+	for _, name := range im.tagNames {
+		if err := im.s.UpdateReference("refs/tags/"+name, im.tags[name], nil, "fast-import"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// contains 判断 old 是否是 tip 或者它的祖先
+func (im *importer) contains(tip, old hash.Hash) (bool, error) {
+	seen := map[hash.Hash]bool{tip: true}
+	queue := []hash.Hash{tip}
+	for len(queue) > 0 {
+		h := queue[0]
+		queue = queue[1:]
+		if h == old {
+			return true, nil
+		}
+		c, err := storage.ReadCommit(im.s, h)
+		if err != nil {
+			return false, err
+		}
+		for _, p := range c.Parents {
+			if !seen[p] {
+				seen[p] = true
+				queue = append(queue, p)
+			}
+		}
+	}
+	return false, nil
+}
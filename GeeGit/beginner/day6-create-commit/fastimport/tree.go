@@ -0,0 +1,144 @@
+package fastimport
+
+import (
+	"strings"
+
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/storage"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// node 是导入时在内存中修改的目录树中的一个条目
+type node struct {
+	mode     string
+	hash     hash.Hash        // 文件指向的对象；目录没有修改过时是原来的 tree，修改后清零
+	children map[string]*node // 目录中的条目，第一次用到时才从对象库读取
+}
+
+// newDir 返回内容为 tree h 的目录，零哈希表示空目录
+func newDir(h hash.Hash) *node {
+	return &node{mode: "40000", hash: h}
+}
+
+func (n *node) isDir() bool {
+	return n.mode == "40000"
+}
+
+// load 读取目录原来的条目
+func (n *node) load(s storage.ObjectStorer) error {
+	if n.children != nil {
+		return nil
+	}
+	n.children = make(map[string]*node)
+	if n.hash.IsZero() {
+		return nil
+	}
+	t, err := storage.ReadTree(s, n.hash)
+	if err != nil {
+		return err
+	}
+	for _, e := range t.Entries {
+		mode := e.Mode
+		if e.IsDir() {
+			mode = "40000"
+		}
+		n.children[e.Name] = &node{mode: mode, hash: e.Hash}
+	}
+	return nil
+}
+
+// lookup 返回路径 p 上的条目，不存在时返回 nil
+func (n *node) lookup(s storage.ObjectStorer, p string) (*node, error) {
+	cur := n
+	for _, name := range strings.Split(p, "/") {
+		if !cur.isDir() {
+			return nil, nil
+		}
+		if err := cur.load(s); err != nil {
+			return nil, err
+		}
+		if cur = cur.children[name]; cur == nil {
+			return nil, nil
+		}
+	}
+	return cur, nil
+}
+
+// set 把路径 p 设置为 entry，entry 为 nil 表示删除
+// 沿途缺少的目录自动创建，挡路的文件被替换为目录；删除后变空的目录也一起删除
+func (n *node) set(s storage.ObjectStorer, p string, entry *node) error {
+	if err := n.load(s); err != nil {
+		return err
+	}
+	name, rest, nested := strings.Cut(p, "/")
+	if !nested {
+		if entry == nil {
+			if _, ok := n.children[name]; ok {
+				delete(n.children, name)
+				n.hash = hash.Hash{}
+			}
+			return nil
+		}
+		n.children[name] = entry
+		n.hash = hash.Hash{}
+		return nil
+	}
+
+	child := n.children[name]
+	if child == nil || !child.isDir() {
+		if entry == nil {
+			return nil
+		}
+		child = newDir(hash.Hash{})
+		n.children[name] = child
+	}
+	if err := child.set(s, rest, entry); err != nil {
+		return err
+	}
+	if child.hash.IsZero() {
+		n.hash = hash.Hash{}
+		if len(child.children) == 0 {
+			delete(n.children, name)
+		}
+	}
+	return nil
+}
+
+// clone 返回条目的副本，复制或重命名目录之后修改其中一份不会影响另一份
+func (n *node) clone() *node {
+	c := &node{mode: n.mode, hash: n.hash}
+	if n.children != nil {
+		c.children = make(map[string]*node, len(n.children))
+		for name, child := range n.children {
+			c.children[name] = child.clone()
+		}
+	}
+	return c
+}
+
+// write 写入修改过的目录，返回 tree 的哈希；空的子目录不写入
+func (n *node) write(im *importer) (hash.Hash, error) {
+	if !n.hash.IsZero() {
+		return n.hash, nil
+	}
+	entries := make([]tree.TreeEntry, 0, len(n.children))
+	for name, child := range n.children {
+		h := child.hash
+		if child.isDir() {
+			if child.children != nil && len(child.children) == 0 {
+				continue
+			}
+			var err error
+			if h, err = child.write(im); err != nil {
+				return hash.Hash{}, err
+			}
+		}
+		entries = append(entries, tree.TreeEntry{Mode: child.mode, Name: name, Hash: h})
+	}
+	h, err := im.write(hash.TreeObject, tree.BuildTreeContent(entries))
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	n.hash = h
+	return h, nil
+}
@@ -0,0 +1,141 @@
+package fetch
+
+import (
+	"fmt"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/alternates"
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/remote"
+	"geegit/beginner/day6-create-commit/repack"
+	"geegit/beginner/day6-create-commit/repository"
+	"geegit/beginner/day6-create-commit/shallow"
+)
+
+// Clone 把仓库 url 克隆到新的 git 目录 gitDir（相当于 git clone --no-checkout）
+//  1. 初始化仓库，添加远程 origin；截断历史时只获取远程 HEAD 所在的分支（--single-branch）
+//  2. 部分克隆时把 origin 记为 promisor 远程，并记录过滤器，core.repositoryformatversion 升级为 1
+//  3. 获取对象，远程分支和标签直接写入 packed-refs；借用的仓库中已有的对象不再获取
+//  4. 创建与远程 HEAD 同名的本地分支并切换过去，refs/remotes/origin/HEAD 指向对应的远程跟踪分支
+//  5. --dissociate 时把借用的对象打包到本仓库，然后删除 alternates
+func Clone(url, gitDir string, opts Options) (*Result, error) {
+	src, err := open(url)
+	if err != nil {
+		return nil, err
+	}
+	var borrowed []string
+	for _, ref := range opts.Reference {
+		dir, err := repository.OpenLocal(ref)
+		if err != nil {
+			return nil, fmt.Errorf("reference repository '%s' is not a local repository.", ref)
+		}
+		if shallow.IsShallow(dir) {
+			return nil, fmt.Errorf("reference repository '%s' is shallow", ref)
+		}
+		borrowed = append(borrowed, alternates.ObjectDir(dir))
+	}
+
+	// 1. 初始化仓库和远程
+	if err := repository.InitGitDir(gitDir); err != nil {
+		return nil, err
+	}
+	for _, dir := range borrowed {
+		if err := alternates.Add(gitDir, dir); err != nil {
+			return nil, err
+		}
+	}
+	if err := remote.Add(gitDir, "origin", url); err != nil {
+		return nil, err
+	}
+	file := gitdir.Path(gitDir, "config")
+	head := src.head
+	branch := ""
+	if head != nil {
+		branch = strings.TrimPrefix(head.Target, "refs/heads/")
+	}
+	if opts.isShallow() && branch != "" {
+		spec := remote.RefSpec{Force: true, Src: head.Target, Dst: "refs/remotes/origin/" + branch}
+		if err := config.SetValue(file, "remote.origin.fetch", spec.String()); err != nil {
+			return nil, err
+		}
+	}
+
+	// 2. 部分克隆
+	if opts.Filter != nil {
+		for _, kv := range [][2]string{
+			{"core.repositoryformatversion", "1"},
+			{"remote.origin.promisor", "true"},
+			{"remote.origin.partialclonefilter", opts.Filter.Spec},
+		} {
+			if err := config.SetValue(file, kv[0], kv[1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 3. 获取
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	r, err := remote.Get(cfg, "origin")
+	if err != nil {
+		return nil, err
+	}
+	res, err := fetch(gitDir, r, opts, opts.Filter != nil)
+	if err != nil {
+		return nil, err
+	}
+	var packed []refs.Ref
+	for _, u := range res.Updates {
+		packed = append(packed, refs.Ref{Name: u.Dst, Hash: u.New})
+	}
+	if len(packed) > 0 {
+		if err := refs.WritePacked(gitDir, packed); err != nil {
+			return nil, err
+		}
+	}
+
+	// 4. 本地分支
+	if err := checkoutHead(gitDir, url, head, branch); err != nil {
+		return nil, err
+	}
+
+	// 5. 不再借用其他仓库的对象
+	if opts.Dissociate && len(borrowed) > 0 {
+		if _, err := repack.Repack(gitDir, repack.Options{All: true, Delete: true}); err != nil {
+			return nil, err
+		}
+		if err := alternates.Remove(gitDir); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// checkoutHead 创建与远程 HEAD 同名的本地分支并设置上游；远程还没有任何 commit 时 HEAD 指向远程默认分支的名字
+func checkoutHead(gitDir, url string, head *refs.Ref, branch string) error {
+	if head == nil {
+		return nil
+	}
+	file := gitdir.Path(gitDir, "config")
+	msg := "clone: from " + url
+	if head.Target == "" {
+		return refs.Detach(gitDir, head.Hash, msg)
+	}
+	if err := refs.SetSymbolic(gitDir, "HEAD", head.Target, ""); err != nil {
+		return err
+	}
+	if err := refs.SetSymbolic(gitDir, "refs/remotes/origin/HEAD", "refs/remotes/origin/"+branch, msg); err != nil {
+		return err
+	}
+	if err := refs.Update(gitDir, head.Target, head.Hash, nil, msg); err != nil {
+		return err
+	}
+	if err := config.SetValue(file, "branch."+branch+".remote", "origin"); err != nil {
+		return err
+	}
+	return config.SetValue(file, "branch."+branch+".merge", head.Target)
+}
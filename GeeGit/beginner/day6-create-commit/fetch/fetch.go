@@ -0,0 +1,309 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"geegit/beginner/day6-create-commit/bundle"
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/remote"
+	"geegit/beginner/day6-create-commit/repository"
+	"geegit/beginner/day6-create-commit/revision"
+)
+
+// Options 控制一次获取（fetch 或 clone）的范围
+type Options struct {
+	Depth        int       // --depth：从每个引用开始只获取 Depth 个 commit，0 表示不限制
+	Deepen       int       // --deepen：把浅克隆的边界再向前推进 Deepen 个 commit
+	ShallowSince time.Time // --shallow-since：只获取提交时间不早于它的 commit
+	Filter       *Filter   // --filter：部分克隆时省略的对象
+
+	Reference  []string // clone --reference / --shared：借用这些本地仓库的对象（写入 objects/info/alternates），不再获取
+	Dissociate bool     // clone --dissociate：克隆完成后复制借用的对象，不再依赖被借用的仓库
+
+	Warnings io.Writer // 输出警告（例如远程的位图损坏），nil 表示不输出
+}
+
+// isShallow 判断是否要求截断历史
+func (o *Options) isShallow() bool {
+	return o.Depth > 0 || o.Deepen > 0 || !o.ShallowSince.IsZero()
+}
+
+// Update 是一次获取中一个引用的变化
+type Update struct {
+	Src      string    // 远程引用，例如 refs/heads/main
+	Dst      string    // 本地引用，例如 refs/remotes/origin/main；只写入 FETCH_HEAD 时为空
+	Old, New hash.Hash // Old 为零哈希表示新建，与 New 相同表示没有变化
+	Forced   bool      // 不是快进，只有 refspec 带 "+" 时才会更新
+	Rejected bool      // 不是快进且 refspec 不允许强制更新，引用保持不变
+	Followed bool      // 自动跟随获取的标签（指向的 commit 在获取的历史中）
+
+	force bool // refspec 带 "+"
+}
+
+// Result 是一次获取的结果
+type Result struct {
+	URL     string
+	Updates []Update // 获取到的引用，按远程引用的名称排序，自动跟随的标签在最后
+	Head    string   // 远程 HEAD 指向的分支，例如 refs/heads/main；远程 HEAD 分离时为空
+}
+
+// Fetch 从远程 name 获取对象并按 fetch refspec 更新远程跟踪引用（git fetch <name>）
+// 获取到的引用同时写入 FETCH_HEAD；reflog 的原因形如 "<action>: fast-forward"。
+// 远程配置了 remote.<name>.promisor 时是部分克隆，opts.Filter 为空则使用 remote.<name>.partialclonefilter
+func Fetch(gitDir, name string, opts Options, action string) (*Result, error) {
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	r, err := remote.Get(cfg, name)
+	if errors.Is(err, remote.ErrNotFound) {
+		r = &remote.Remote{Name: name, URLs: []string{name}} // 直接使用仓库地址，只获取远程的 HEAD
+	} else if err != nil {
+		return nil, err
+	}
+	promisor := cfg.Bool("remote."+name+".promisor", false)
+	if promisor && opts.Filter == nil {
+		if spec, ok := cfg.Get("remote." + name + ".partialclonefilter"); ok {
+			if opts.Filter, err = ParseFilter(spec); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	res, err := fetch(gitDir, r, opts, promisor)
+	if err != nil {
+		return nil, err
+	}
+	for i := range res.Updates {
+		u := &res.Updates[i]
+		if u.Rejected || u.Dst == "" || u.Old == u.New {
+			continue
+		}
+		if err := refs.Update(gitDir, u.Dst, u.New, nil, action+": "+u.reason()); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeFetchHead(gitDir, cfg, r, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// reason 返回 reflog 中记录的原因，与 git fetch 一致
+func (u *Update) reason() string {
+	switch {
+	case u.Old.IsZero() && strings.HasPrefix(u.Src, "refs/tags/"):
+		return "storing tag"
+	case u.Old.IsZero() && strings.HasPrefix(u.Src, "refs/heads/"):
+		return "storing head"
+	case u.Old.IsZero():
+		return "storing ref"
+	case u.Forced:
+		return "forced-update"
+	}
+	return "fast-forward"
+}
+
+// fetch 获取远程 r 中按 refspec 映射到本地的引用（没有 refspec 时为远程的 HEAD）及其历史，
+// 以及指向这些历史的标签；只写入对象和 .git/shallow，不更新引用
+func fetch(gitDir string, r *remote.Remote, opts Options, promisor bool) (*Result, error) {
+	src, err := open(r.FetchURL())
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{URL: r.FetchURL()}
+	if src.head != nil {
+		res.Head = src.head.Target
+	}
+
+	// 1. 按 refspec 确定要获取的引用
+	var all []Update
+	if len(r.Fetch) == 0 {
+		if src.head == nil {
+			return nil, errors.New("couldn't find remote ref HEAD")
+		}
+		all = append(all, Update{Src: "HEAD", New: src.head.Hash})
+	}
+	mapped := make(map[string]bool)
+	for _, ref := range src.refs {
+		for _, spec := range r.Fetch {
+			dst, ok := spec.Map(ref.Name)
+			if !ok || mapped[dst] {
+				continue
+			}
+			mapped[dst] = true
+			u := Update{Src: ref.Name, Dst: dst, New: ref.Hash, force: spec.Force}
+			if old, err := refs.Resolve(gitDir, dst); err == nil {
+				u.Old = old
+			}
+			all = append(all, u)
+		}
+	}
+
+	// 2. 选择对象；remote 中指向获取到的历史、本地还没有的标签自动跟随获取。
+	//    bundle 中的对象先全部写入本地仓库，与 git 一样不按引用挑选
+	if err := src.unpack(gitDir); err != nil {
+		return nil, err
+	}
+	n, err := newNegotiation(gitDir, src.dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	var wants []hash.Hash
+	for _, u := range all {
+		if u.Old != u.New || opts.isShallow() {
+			wants = append(wants, u.New)
+		}
+	}
+	if err := n.want(wants); err != nil {
+		return nil, err
+	}
+	for _, ref := range src.refs {
+		if !strings.HasPrefix(ref.Name, "refs/tags/") || mapped[ref.Name] {
+			continue
+		}
+		if _, err := refs.Resolve(gitDir, ref.Name); err == nil {
+			continue
+		}
+		ok, err := n.follows(ref.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			if _, _, err := n.peel(ref.Hash, true); err != nil {
+				return nil, err
+			}
+			all = append(all, Update{Src: ref.Name, Dst: ref.Name, New: ref.Hash, Followed: true})
+		}
+	}
+	if err := n.finish(promisor); err != nil {
+		return nil, err
+	}
+
+	// 3. 判断已有的引用是否是快进
+	for _, u := range all {
+		if u.Dst != "" && !u.Old.IsZero() && u.Old != u.New {
+			ff, err := revision.IsAncestor(gitDir, u.Old, u.New)
+			if err != nil {
+				return nil, err
+			}
+			u.Forced, u.Rejected = !ff, !ff && !u.force
+		}
+		res.Updates = append(res.Updates, u)
+	}
+	return res, nil
+}
+
+// source 是获取的来源：本地仓库，或者 bundle 文件
+type source struct {
+	dir    string     // 读取对象的 git 目录；bundle 解开之后就是本地仓库
+	refs   []refs.Ref // refs/ 下的引用，按名称排序
+	head   *refs.Ref  // HEAD，没有时为 nil
+	bundle *bundle.Bundle
+}
+
+// open 打开远程仓库地址，错误信息与 git 无法连接远程仓库时一致；地址是 bundle 文件时读取其中的引用
+func open(url string) (*source, error) {
+	if p := strings.TrimPrefix(url, "file://"); bundle.IsBundle(p) {
+		return openBundle(p)
+	}
+	dir, err := repository.OpenLocal(url)
+	if err != nil {
+		return nil, fmt.Errorf("%v\nfatal: Could not read from remote repository.\n\n"+
+			"Please make sure you have the correct access rights\nand the repository exists.", err)
+	}
+	list, err := refs.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &source{dir: dir, refs: list}
+	if head, err := refs.Read(dir, "HEAD"); err == nil {
+		s.head = head
+	}
+	return s, nil
+}
+
+// openBundle 读取 bundle 中的引用。bundle 中的 HEAD 不是符号引用，与 git 一样按它指向的 commit 推测分支：
+// 优先选择 main，其次是第一个指向同一个 commit 的分支，都没有时是分离的 HEAD
+func openBundle(path string) (*source, error) {
+	b, err := bundle.Read(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &source{bundle: b}
+	for _, r := range b.Refs {
+		if r.Name == "HEAD" {
+			s.head = &refs.Ref{Name: r.Name, Hash: r.Hash}
+		} else {
+			s.refs = append(s.refs, r)
+		}
+	}
+	sort.Slice(s.refs, func(i, j int) bool { return s.refs[i].Name < s.refs[j].Name })
+	if s.head == nil {
+		return s, nil
+	}
+	for _, r := range s.refs {
+		if r.Hash != s.head.Hash || !strings.HasPrefix(r.Name, "refs/heads/") {
+			continue
+		}
+		if s.head.Target == "" || r.Name == "refs/heads/main" {
+			s.head.Target = r.Name
+		}
+	}
+	return s, nil
+}
+
+// unpack 把 bundle 中的对象写入本地仓库 gitDir，之后从本地仓库读取对象；来源是仓库时什么也不做
+func (s *source) unpack(gitDir string) error {
+	if s.bundle == nil || s.dir != "" {
+		return nil
+	}
+	if err := s.bundle.Unbundle(gitDir); err != nil {
+		return fmt.Errorf("%v\nfatal: remote transport reported error", err)
+	}
+	s.dir = gitDir
+	return nil
+}
+
+// writeFetchHead 写入 .git/FETCH_HEAD，每个获取到的引用一行: "<hash>\t[not-for-merge]\t<描述>"
+// 当前分支的上游（branch.<name>.remote 和 branch.<name>.merge）是可以合并的，其余标记为 not-for-merge
+func writeFetchHead(gitDir string, cfg *config.Config, r *remote.Remote, res *Result) error {
+	merge := ""
+	if name, err := refs.ResolveName(gitDir, "HEAD"); err == nil && strings.HasPrefix(name, "refs/heads/") {
+		branch := strings.TrimPrefix(name, "refs/heads/")
+		if v, _ := cfg.Get("branch." + branch + ".remote"); v == r.Name {
+			merge, _ = cfg.Get("branch." + branch + ".merge")
+		}
+	}
+
+	var b strings.Builder
+	for _, u := range res.Updates {
+		if u.Followed {
+			continue
+		}
+		mark := "not-for-merge"
+		if u.Src == merge || u.Src == "HEAD" {
+			mark = ""
+		}
+		desc := res.URL
+		switch {
+		case strings.HasPrefix(u.Src, "refs/heads/"):
+			desc = fmt.Sprintf("branch '%s' of %s", strings.TrimPrefix(u.Src, "refs/heads/"), res.URL)
+		case strings.HasPrefix(u.Src, "refs/tags/"):
+			desc = fmt.Sprintf("tag '%s' of %s", strings.TrimPrefix(u.Src, "refs/tags/"), res.URL)
+		case u.Src != "HEAD":
+			desc = fmt.Sprintf("'%s' of %s", u.Src, res.URL)
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\n", u.New, mark, desc)
+	}
+	return os.WriteFile(gitdir.Path(gitDir, "FETCH_HEAD"), []byte(b.String()), 0644)
+}
@@ -0,0 +1,58 @@
+package fetch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/config"
+)
+
+// Filter 是部分克隆的对象过滤器（--filter=<spec>），决定哪些对象不随 commit 一起获取
+//   - blob:none        不获取任何 blob
+//   - blob:limit=<n>   不获取大小不小于 n 字节的 blob，n 可以带 k、m、g 单位
+//   - tree:<depth>     不获取深度不小于 depth 的 tree 和 blob（commit 的根 tree 深度为 0），
+//     tree:0 只获取 commit 和标签
+type Filter struct {
+	Spec      string // 原始的过滤器，写入 remote.<name>.partialclonefilter
+	BlobLimit int64  // blob 大小的上限，-1 表示不限制
+	TreeDepth int    // tree 深度的上限，-1 表示不限制
+}
+
+// ParseFilter 解析过滤器
+func ParseFilter(spec string) (*Filter, error) {
+	f := &Filter{Spec: spec, BlobLimit: -1, TreeDepth: -1}
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch {
+	case kind == "blob" && arg == "none":
+		f.BlobLimit = 0
+	case kind == "blob" && strings.HasPrefix(arg, "limit="):
+		n, ok := config.ParseInt(strings.TrimPrefix(arg, "limit="))
+		if !ok || n < 0 {
+			return nil, fmt.Errorf("invalid filter-spec '%s'", spec)
+		}
+		f.BlobLimit = n
+	case kind == "tree":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("expected 'tree:<depth>'")
+		}
+		f.TreeDepth = n
+	default:
+		return nil, fmt.Errorf("invalid filter-spec '%s'", spec)
+	}
+	return f, nil
+}
+
+// omitTree 判断深度为 depth 的 tree 是否被过滤掉
+func (f *Filter) omitTree(depth int) bool {
+	return f != nil && f.TreeDepth >= 0 && depth >= f.TreeDepth
+}
+
+// omitBlob 判断深度为 depth、大小为 size 的 blob 是否被过滤掉
+func (f *Filter) omitBlob(depth int, size int) bool {
+	if f == nil {
+		return false
+	}
+	return (f.BlobLimit >= 0 && int64(size) >= f.BlobLimit) || (f.TreeDepth >= 0 && depth >= f.TreeDepth)
+}
@@ -0,0 +1,340 @@
+package fetch
+
+import (
+	"fmt"
+
+	"geegit/beginner/day6-create-commit/bitmap"
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/pack"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/shallow"
+	"geegit/beginner/day6-create-commit/tag"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// negotiation 选择要从远程仓库 src 发送到本地仓库 gitDir 的对象
+// 相当于 upload-pack 在服务端做的事：从想要的对象出发遍历，遇到本地已有的 commit 停止，
+// 按 --depth / --deepen / --shallow-since 截断历史，按过滤器省略 tree 和 blob
+type negotiation struct {
+	gitDir, src string
+	opts        Options
+
+	shallow  map[hash.Hash]bool // 本地原有的浅克隆边界
+	boundary map[hash.Hash]bool // 这次获取产生的浅克隆边界
+	depth    map[hash.Hash]int  // 已经遍历过的 commit 及其深度
+	sent     map[hash.Hash]bool
+	entries  []pack.Entry
+}
+
+func newNegotiation(gitDir, src string, opts Options) (*negotiation, error) {
+	set, err := shallow.Read(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	return &negotiation{
+		gitDir:   gitDir,
+		src:      src,
+		opts:     opts,
+		shallow:  set,
+		boundary: make(map[hash.Hash]bool),
+		depth:    make(map[hash.Hash]int),
+		sent:     make(map[hash.Hash]bool),
+	}, nil
+}
+
+// Objects 把从 tips 可以到达、仓库 gitDir 还没有的对象从仓库 src 复制到 gitDir，写成一个 packfile；
+// 推送时由推送端调用，gitDir 是接收端
+func Objects(gitDir, src string, tips []hash.Hash) error {
+	n, err := newNegotiation(gitDir, src, Options{})
+	if err != nil {
+		return err
+	}
+	if err := n.want(tips); err != nil {
+		return err
+	}
+	return n.finish(false)
+}
+
+// send 从远程读取对象并加入待发送的列表，对象已经选中过时返回 nil
+func (n *negotiation) send(h hash.Hash) (*object.Object, error) {
+	if n.sent[h] {
+		return nil, nil
+	}
+	obj, err := object.Read(n.src, h)
+	if err != nil {
+		return nil, err
+	}
+	n.sent[h] = true
+	n.entries = append(n.entries, pack.Entry{Hash: h, Type: obj.Type, Content: obj.Content})
+	return obj, nil
+}
+
+// want 选择从 tips 可以到达、本地还没有的对象
+func (n *negotiation) want(tips []hash.Hash) error {
+	if ok, err := n.wantBitmap(tips); ok || err != nil {
+		return err
+	}
+	var commits []hash.Hash
+	for _, h := range tips {
+		target, objType, err := n.peel(h, true)
+		if err != nil {
+			return err
+		}
+		switch objType {
+		case hash.CommitObject:
+			commits = append(commits, target)
+		case hash.TreeObject:
+			err = n.addTree(target, 0)
+		case hash.BlobObject:
+			err = n.addBlob(target, 0)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return n.walkCommits(commits)
+}
+
+// wantBitmap 在不截断历史、不过滤对象且远程有可达性位图时，用位图计算要发送的对象：
+// 从 tips 可以到达的对象，去掉从本地引用（远程也有的 commit）可以到达的对象。不能使用位图时返回 false；
+// 位图只是加速，读取失败时给出警告，改为逐个遍历对象
+func (n *negotiation) wantBitmap(tips []hash.Hash) (bool, error) {
+	if n.opts.isShallow() || n.opts.Filter != nil || len(n.shallow) > 0 {
+		return false, nil
+	}
+	idx, err := bitmap.Open(n.src)
+	if err != nil {
+		if n.opts.Warnings != nil {
+			fmt.Fprintf(n.opts.Warnings, "warning: ignoring bitmap index: %v\n", err)
+		}
+		return false, nil
+	}
+	if idx == nil {
+		return false, nil
+	}
+
+	// 1. 本地引用中远程也有的 commit 是双方共有的历史
+	local, err := refs.List(n.gitDir)
+	if err != nil {
+		return false, err
+	}
+	var haves []hash.Hash
+	for _, r := range local {
+		if object.Exists(n.src, r.Hash) {
+			haves = append(haves, r.Hash)
+		}
+	}
+
+	// 2. 计算差集，跳过本地已有的对象
+	want, err := idx.Reachable(n.src, tips)
+	if err != nil {
+		return false, err
+	}
+	have, err := idx.Reachable(n.src, haves)
+	if err != nil {
+		return false, err
+	}
+	want.AndNot(have)
+	err = want.Each(func(h hash.Hash, _ hash.ObjectType) error {
+		if object.Exists(n.gitDir, h) {
+			return nil
+		}
+		_, err := n.send(h)
+		return err
+	})
+	return true, err
+}
+
+// peel 一直剥离远程的标签，返回最终指向的对象及其类型；send 为 true 时同时选中途经的标签对象
+func (n *negotiation) peel(h hash.Hash, send bool) (hash.Hash, hash.ObjectType, error) {
+	for {
+		obj, err := object.Read(n.src, h)
+		if err != nil {
+			return hash.Hash{}, 0, err
+		}
+		if obj.Type != hash.TagObject {
+			return h, obj.Type, nil
+		}
+		if send && !object.Exists(n.gitDir, h) {
+			if _, err := n.send(h); err != nil {
+				return hash.Hash{}, 0, err
+			}
+		}
+		t, err := tag.ParseTag(obj.Content)
+		if err != nil {
+			return hash.Hash{}, 0, err
+		}
+		h = t.Object
+	}
+}
+
+// walkCommits 从 tips 出发按广度优先遍历历史，选择需要发送的 commit 及其 tree
+// commit 的深度从 1 开始计数（tips 本身），超过 --depth 的父 commit 不再遍历，
+// 它的子 commit 成为新的浅克隆边界。--deepen 从本地原有的边界开始计数，边界之上的新 commit 不计深度
+func (n *negotiation) walkCommits(tips []hash.Hash) error {
+	type item struct {
+		h     hash.Hash
+		depth int
+	}
+	start := 1
+	limit := n.opts.Depth
+	if n.opts.Deepen > 0 {
+		start, limit = 0, n.opts.Deepen
+	}
+	queue := make([]item, 0, len(tips))
+	for _, h := range tips {
+		queue = append(queue, item{h, start})
+	}
+
+	var commits []*commit.Commit
+	for len(queue) > 0 {
+		it := queue[0]
+		queue = queue[1:]
+		if d, ok := n.depth[it.h]; ok && d <= it.depth {
+			continue
+		}
+		n.depth[it.h] = it.depth
+		delete(n.boundary, it.h)
+
+		// 1. 本地已有的历史不需要再遍历；加深或重新指定 --depth 时仍要从头计算边界
+		local := object.Exists(n.gitDir, it.h)
+		if local && limit == 0 && n.opts.ShallowSince.IsZero() {
+			continue
+		}
+		c, err := commit.ReadCommit(n.src, it.h)
+		if err != nil {
+			return err
+		}
+		if !local {
+			if _, err := n.send(it.h); err != nil {
+				return err
+			}
+			commits = append(commits, c)
+		}
+		if shallow.Contains(n.src, it.h) {
+			n.boundary[it.h] = true // 远程本身也是浅克隆
+		}
+
+		// 2. 按深度和时间决定是否继续遍历父 commit
+		next := it.depth + 1
+		if n.opts.Deepen > 0 && it.depth == 0 && !n.shallow[it.h] {
+			next = 0
+		}
+		if limit > 0 && next > limit {
+			n.boundary[it.h] = true
+			continue
+		}
+		for _, p := range c.Parents {
+			if !n.opts.ShallowSince.IsZero() {
+				pc, err := commit.ReadCommit(n.src, p)
+				if err != nil {
+					return err
+				}
+				if pc.Committer.When.Before(n.opts.ShallowSince) {
+					n.boundary[it.h] = true
+					continue
+				}
+			}
+			queue = append(queue, item{p, next})
+		}
+	}
+
+	for _, c := range commits {
+		if err := n.addTree(c.Tree, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addTree 选择 tree 及其中本地没有的子 tree 和 blob，depth 是 tree 相对 commit 根 tree 的深度
+func (n *negotiation) addTree(h hash.Hash, depth int) error {
+	if n.sent[h] || n.opts.Filter.omitTree(depth) || object.Exists(n.gitDir, h) {
+		return nil
+	}
+	obj, err := n.send(h)
+	if err != nil {
+		return err
+	}
+	entries, err := tree.ParseEntries(obj.Content)
+	if err != nil {
+		return fmt.Errorf("tree %s: %v", h, err)
+	}
+	for _, e := range entries {
+		switch {
+		case e.IsSubmodule():
+			// gitlink 指向子模块中的 commit，不在这个仓库中
+		case e.IsDir():
+			err = n.addTree(e.Hash, depth+1)
+		default:
+			err = n.addBlob(e.Hash, depth+1)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addBlob 选择本地没有、也没有被过滤掉的 blob
+func (n *negotiation) addBlob(h hash.Hash, depth int) error {
+	if n.sent[h] || n.opts.Filter.omitBlob(depth, 0) || object.Exists(n.gitDir, h) {
+		return nil
+	}
+	obj, err := object.Read(n.src, h)
+	if err != nil {
+		return err
+	}
+	if n.opts.Filter.omitBlob(depth, len(obj.Content)) {
+		return nil
+	}
+	n.sent[h] = true
+	n.entries = append(n.entries, pack.Entry{Hash: h, Type: obj.Type, Content: obj.Content})
+	return nil
+}
+
+// follows 判断远程的标签 h 指向的对象（剥离后）是否已经在本地或这次会发送，即是否需要自动跟随获取
+func (n *negotiation) follows(h hash.Hash) (bool, error) {
+	target, _, err := n.peel(h, false)
+	if err != nil {
+		return false, err
+	}
+	return n.sent[target] || object.Exists(n.gitDir, target), nil
+}
+
+// finish 把选中的对象写入 packfile，并更新 .git/shallow
+// 新产生的边界总是记录下来（与 git 一致，即使是根 commit）；原有的边界中父 commit 已经全部取回的不再是边界
+func (n *negotiation) finish(promisor bool) error {
+	if len(n.entries) > 0 {
+		if _, err := pack.Write(n.gitDir, n.entries, promisor); err != nil {
+			return err
+		}
+	}
+
+	set := make(map[hash.Hash]bool)
+	for h := range n.boundary {
+		set[h] = true
+	}
+	for h := range n.shallow {
+		obj, err := object.Read(n.gitDir, h)
+		if err != nil {
+			return err
+		}
+		c, err := commit.ParseCommit(obj.Content)
+		if err != nil {
+			return err
+		}
+		for _, p := range c.Parents {
+			if !object.Exists(n.gitDir, p) {
+				set[h] = true
+				break
+			}
+		}
+	}
+	if len(set) == 0 && len(n.shallow) == 0 {
+		return nil
+	}
+	return shallow.Write(n.gitDir, set)
+}
@@ -0,0 +1,373 @@
+package fsck
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/alternates"
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/pack"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/shallow"
+	"geegit/beginner/day6-create-commit/worktree"
+)
+
+// Kind 表示问题的类别
+type Kind string
+
+const (
+	KindError    Kind = "error"    // 对象损坏或格式错误
+	KindWarning  Kind = "warning"  // 格式不规范，但 git 仍能读取
+	KindMissing  Kind = "missing"  // 被引用但不存在的对象
+	KindDangling Kind = "dangling" // 存在但没有被任何引用或对象指向
+)
+
+// Problem 表示 fsck 发现的一个问题
+type Problem struct {
+	Kind    Kind   `json:"kind"`
+	ID      string `json:"id,omitempty"` // 与 git fsck 一致的消息 ID，例如 "treeNotSorted"
+	Type    string `json:"type"`         // 对象类型，packfile 级别的问题为 "pack"
+	Object  string `json:"object"`       // 对象哈希或 packfile 名称
+	Message string `json:"message,omitempty"`
+}
+
+// Report 是一次检查的结果
+type Report struct {
+	Checked  int // 检查过的对象数量
+	Problems []Problem
+}
+
+// HasErrors 判断是否存在会导致 fsck 失败的问题（错误或缺失对象）
+func (r *Report) HasErrors() bool {
+	for _, p := range r.Problems {
+		if p.Kind == KindError || p.Kind == KindMissing {
+			return true
+		}
+	}
+	return false
+}
+
+// link 表示一个对象对另一个对象的引用
+type link struct {
+	to      hash.Hash
+	objType hash.ObjectType
+}
+
+// Options 控制 Check 的检查方式
+type Options struct {
+	// Strict 为 true 时同时报告 100664 这样 git 仍然接受的旧文件模式（--strict）
+	Strict bool
+}
+
+type checker struct {
+	gitDir  string
+	opts    Options
+	report  *Report
+	objects map[hash.Hash]hash.ObjectType // 所有能成功读取的对象
+	links   map[hash.Hash][]link          // 每个对象引用的其他对象
+
+	promisor map[hash.Hash]bool // promisor packfile 中的对象，它们引用的对象允许缺失（部分克隆）
+}
+
+// Check 检查仓库中所有对象的完整性和连通性
+//  1. 重新计算每个松散对象和 packfile 对象的哈希
+//  2. 校验 tree/commit/tag 的格式
+//  3. 从引用、HEAD、索引和 reflog 出发遍历，找出缺失的对象和悬空的对象
+//
+// 浅克隆边界上的 commit 的父 commit、部分克隆中 promisor 对象引用的对象本来就不在仓库中，不报告为缺失
+func Check(gitDir string, opts Options) (*Report, error) {
+	c := &checker{
+		gitDir:   gitDir,
+		opts:     opts,
+		report:   &Report{},
+		objects:  make(map[hash.Hash]hash.ObjectType),
+		links:    make(map[hash.Hash][]link),
+		promisor: make(map[hash.Hash]bool),
+	}
+
+	if err := c.checkLoose(); err != nil {
+		return nil, err
+	}
+	if err := c.checkPacks(); err != nil {
+		return nil, err
+	}
+	if err := c.checkConnectivity(); err != nil {
+		return nil, err
+	}
+	return c.report, nil
+}
+
+func (c *checker) add(p Problem) {
+	c.report.Problems = append(c.report.Problems, p)
+}
+
+// checkLoose 检查所有松散对象（包括 alternates 中的）
+func (c *checker) checkLoose() error {
+	for _, dir := range alternates.ObjectDirs(c.gitDir) {
+		hashes, err := object.ListLooseIn(dir)
+		if err != nil {
+			return err
+		}
+
+		for _, h := range hashes {
+			c.report.Checked++
+			s := h.String()
+			raw, err := os.ReadFile(filepath.Join(dir, s[:2], s[2:]))
+			if err != nil {
+				return err
+			}
+			objType, content, err := object.ParseLoose(raw)
+			if err != nil {
+				c.add(Problem{Kind: KindError, ID: "badObject", Type: "unknown", Object: s, Message: err.Error()})
+				continue
+			}
+			c.checkObject(h, objType, content)
+		}
+	}
+	return nil
+}
+
+// checkPacks 检查所有 packfile（包括 alternates 中的）：文件校验和、每个对象的 CRC32 和哈希
+// 每个 packfile 单独打开，无法打开的只报告它自己，其余的照常检查
+func (c *checker) checkPacks() error {
+	var packs []*pack.Pack
+	for _, dir := range alternates.ObjectDirs(c.gitDir) {
+		idxFiles, err := filepath.Glob(filepath.Join(dir, "pack", "*.idx"))
+		if err != nil {
+			return err
+		}
+		for _, idxPath := range idxFiles {
+			p, err := pack.Open(idxPath)
+			if err != nil {
+				name := strings.TrimSuffix(filepath.Base(idxPath), ".idx") + ".pack"
+				c.add(Problem{Kind: KindError, ID: "badPack", Type: "pack", Object: name, Message: err.Error()})
+				continue
+			}
+			packs = append(packs, p)
+		}
+	}
+
+	for _, p := range packs {
+		name := filepath.Base(p.Path)
+		if err := p.Verify(); err != nil {
+			c.add(Problem{Kind: KindError, ID: "badPackChecksum", Type: "pack", Object: name, Message: err.Error()})
+		}
+
+		promisor := p.IsPromisor()
+		for i, h := range p.Index.Hashes {
+			c.report.Checked++
+			c.promisor[h] = promisor
+			if !p.CheckCRC(i) {
+				c.add(Problem{Kind: KindError, ID: "badCRC", Type: "unknown", Object: h.String(),
+					Message: fmt.Sprintf("CRC mismatch in %s", name)})
+				continue
+			}
+			objType, content, err := p.ReadAt(p.Index.Offsets[i])
+			if err != nil {
+				c.add(Problem{Kind: KindError, ID: "badObject", Type: "unknown", Object: h.String(), Message: err.Error()})
+				continue
+			}
+			c.checkObject(h, objType, content)
+		}
+	}
+	return nil
+}
+
+// checkObject 重新计算哈希，并按类型校验对象内容
+func (c *checker) checkObject(h hash.Hash, objType hash.ObjectType, content []byte) {
+	if actual := hash.ComputeHash(objType, content); actual != h {
+		c.add(Problem{Kind: KindError, ID: "hashMismatch", Type: objType.String(), Object: h.String(),
+			Message: fmt.Sprintf("hash mismatch, content hashes to %s", actual.String())})
+		return
+	}
+	c.objects[h] = objType
+
+	var problems []Problem
+	var links []link
+	switch objType {
+	case hash.TreeObject:
+		links, problems = checkTree(content, c.opts.Strict)
+	case hash.CommitObject:
+		links, problems = checkCommit(content)
+	case hash.TagObject:
+		links, problems = checkTag(content)
+	}
+
+	for _, p := range problems {
+		p.Type = objType.String()
+		p.Object = h.String()
+		c.add(p)
+	}
+	c.links[h] = links
+}
+
+// root 是遍历的起点，source 说明它从哪里来，用于报告缺失的对象
+type root struct {
+	link
+	source string
+}
+
+// roots 收集与 git fsck 相同的遍历起点
+//  1. 所有引用（包括 refs/stash）
+//  2. 每个工作区的 HEAD、索引中的条目和 cache-tree
+//  3. 所有 reflog 中每条记录的旧值和新值
+func (c *checker) roots() ([]root, error) {
+	var roots []root
+	add := func(h hash.Hash, source string) {
+		if h.IsZero() {
+			return
+		}
+		// 引用可以指向任意类型的对象（例如轻量标签指向 blob），以实际类型为准
+		objType := hash.CommitObject
+		if t, ok := c.objects[h]; ok {
+			objType = t
+		}
+		roots = append(roots, root{link: link{to: h, objType: objType}, source: source})
+	}
+
+	// 1. 引用
+	all, err := refs.List(c.gitDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range all {
+		add(ref.Hash, "a ref")
+	}
+
+	// 2. 各个工作区的 HEAD 和索引；子模块的 commit 不在本仓库中，intent-to-add 条目没有内容
+	worktrees, err := worktree.List(c.gitDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range worktrees {
+		if head, err := refs.Read(w.GitDir, "HEAD"); err == nil {
+			add(head.Hash, "HEAD")
+		}
+		idx, err := index.Read(w.GitDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range idx.Entries {
+			if e.Mode != 0160000 && !e.IntentToAdd {
+				roots = append(roots, root{link: link{to: e.Hash, objType: hash.BlobObject}, source: "the index"})
+			}
+		}
+		for _, t := range idx.CacheTree {
+			roots = append(roots, root{link: link{to: t, objType: hash.TreeObject}, source: "the cache-tree"})
+		}
+		if err := c.reflogRoots(w.GitDir, "HEAD", add); err != nil {
+			return nil, err
+		}
+	}
+
+	// 3. 共享的 reflog（logs/refs/ 下，包括已经删除的引用留下的）
+	logs := filepath.Join(gitdir.CommonDir(c.gitDir), "logs")
+	err = filepath.WalkDir(filepath.Join(logs, "refs"), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		name, err := filepath.Rel(logs, p)
+		if err != nil {
+			return err
+		}
+		return c.reflogRoots(c.gitDir, filepath.ToSlash(name), add)
+	})
+	return roots, err
+}
+
+// reflogRoots 把引用 name 的 reflog 中每条记录的旧值和新值加入起点
+func (c *checker) reflogRoots(gitDir, name string, add func(hash.Hash, string)) error {
+	entries, err := refs.ReadLog(gitDir, name)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		add(e.Old, "the reflog of "+name)
+		add(e.New, "the reflog of "+name)
+	}
+	return nil
+}
+
+// checkConnectivity 从引用、HEAD、索引和 reflog 出发遍历对象图
+func (c *checker) checkConnectivity() error {
+	roots, err := c.roots()
+	if err != nil {
+		return err
+	}
+
+	// 1. 从根出发遍历，记录可达对象和缺失对象
+	boundary, err := shallow.Read(c.gitDir)
+	if err != nil {
+		return err
+	}
+	reachable := make(map[hash.Hash]bool)
+	missing := make(map[hash.Hash]bool)
+	type item struct {
+		link
+		from   hash.Hash
+		source string
+	}
+	var stack []item
+	for _, r := range roots {
+		stack = append(stack, item{link: r.link, source: r.source})
+	}
+	for len(stack) > 0 {
+		it := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if reachable[it.to] || missing[it.to] {
+			continue
+		}
+
+		if boundary[it.from] && it.objType == hash.CommitObject {
+			continue // 被截断的父 commit
+		}
+		if _, ok := c.objects[it.to]; !ok {
+			if c.promisor[it.from] {
+				continue
+			}
+			missing[it.to] = true
+			msg := "referenced by " + it.source
+			if !it.from.IsZero() {
+				msg = fmt.Sprintf("broken link from %s %s", c.objects[it.from].String(), it.from.String())
+			}
+			c.add(Problem{Kind: KindMissing, Type: it.objType.String(), Object: it.to.String(), Message: msg})
+			continue
+		}
+
+		reachable[it.to] = true
+		for _, l := range c.links[it.to] {
+			stack = append(stack, item{link: l, from: it.to})
+		}
+	}
+
+	// 2. 悬空对象：不可达，并且没有被任何其他对象引用
+	referenced := make(map[hash.Hash]bool)
+	for _, links := range c.links {
+		for _, l := range links {
+			referenced[l.to] = true
+		}
+	}
+	var dangling []Problem
+	for h, objType := range c.objects {
+		if !reachable[h] && !referenced[h] {
+			dangling = append(dangling, Problem{Kind: KindDangling, Type: objType.String(), Object: h.String()})
+		}
+	}
+	sort.Slice(dangling, func(i, j int) bool { return dangling[i].Object < dangling[j].Object })
+	c.report.Problems = append(c.report.Problems, dangling...)
+	return nil
+}
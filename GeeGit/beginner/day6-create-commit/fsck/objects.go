@@ -0,0 +1,243 @@
+package fsck
+
+import (
+	"bytes"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+func errorf(id, msg string) Problem   { return Problem{Kind: KindError, ID: id, Message: msg} }
+func warningf(id, msg string) Problem { return Problem{Kind: KindWarning, ID: id, Message: msg} }
+
+// checkTree 校验 tree 对象：条目格式、文件模式、名称和排序
+// 与 git 一致，名称不规范只是警告；100664（早期 git 写出的模式）只在 strict 时报告
+func checkTree(content []byte, strict bool) ([]link, []Problem) {
+	entries, err := tree.ParseEntries(content)
+	if err != nil {
+		return nil, []Problem{errorf("badTree", err.Error())}
+	}
+
+	var links []link
+	var problems []Problem
+	seen := make(map[string]bool) // 同一个问题只报告一次
+	report := func(p Problem) {
+		if !seen[p.ID] {
+			seen[p.ID] = true
+			problems = append(problems, p)
+		}
+	}
+
+	for i, e := range entries {
+		// 1. 名称
+		switch {
+		case e.Name == "":
+			report(warningf("emptyName", "contains empty pathname"))
+		case strings.Contains(e.Name, "/"):
+			report(warningf("fullPathname", "contains full pathnames"))
+		case e.Name == ".":
+			report(warningf("hasDot", "contains '.'"))
+		case e.Name == "..":
+			report(warningf("hasDotdot", "contains '..'"))
+		case strings.EqualFold(e.Name, ".git"):
+			report(warningf("hasDotgit", "contains '.git'"))
+		}
+
+		// 2. 文件模式，同时确定条目指向的对象类型
+		switch e.Mode {
+		case "100644", "100755", "120000":
+			links = append(links, link{to: e.Hash, objType: hash.BlobObject})
+		case "40000":
+			links = append(links, link{to: e.Hash, objType: hash.TreeObject})
+		case "160000":
+			// gitlink 指向子模块中的 commit，不在本仓库中
+		case "040000":
+			report(warningf("zeroPaddedFilemode", "contains zero-padded file modes"))
+			links = append(links, link{to: e.Hash, objType: hash.TreeObject})
+		case "100664":
+			if strict {
+				report(warningf("badFilemode", "contains bad file modes"))
+			}
+			links = append(links, link{to: e.Hash, objType: hash.BlobObject})
+		default:
+			report(warningf("badFilemode", "contains bad file modes"))
+		}
+
+		// 3. 排序和重复
+		if i > 0 {
+			switch cmp := compareEntries(entries[i-1], e); {
+			case cmp == 0 || entries[i-1].Name == e.Name:
+				report(errorf("duplicateEntries", "contains duplicate file entries"))
+			case cmp > 0:
+				report(errorf("treeNotSorted", "not properly sorted"))
+			}
+		}
+	}
+
+	return links, problems
+}
+
+// compareEntries 按 git 的规则比较两个 tree 条目
+// 目录名在比较时视为以 "/" 结尾，因此 "foo.c" 排在目录 "foo" 之前
+func compareEntries(a, b tree.TreeEntry) int {
+	return bytes.Compare(sortKey(a), sortKey(b))
+}
+
+func sortKey(e tree.TreeEntry) []byte {
+	if e.IsDir() {
+		return []byte(e.Name + "/")
+	}
+	return []byte(e.Name)
+}
+
+// checkCommit 校验 commit 对象的头部
+// 头部必须依次是: tree、若干 parent、author、committer
+func checkCommit(content []byte) ([]link, []Problem) {
+	var links []link
+	lines := headerLines(content)
+	i := 0
+
+	// 1. tree
+	if i >= len(lines) || !strings.HasPrefix(lines[i], "tree ") {
+		return nil, []Problem{errorf("missingTree", "invalid format - expected 'tree' line")}
+	}
+	h, err := hash.ParseHash(strings.TrimPrefix(lines[i], "tree "))
+	if err != nil {
+		return nil, []Problem{errorf("badTreeSha1", "invalid 'tree' line format - bad sha1")}
+	}
+	links = append(links, link{to: h, objType: hash.TreeObject})
+	i++
+
+	// 2. parent（零个或多个）
+	for i < len(lines) && strings.HasPrefix(lines[i], "parent ") {
+		h, err := hash.ParseHash(strings.TrimPrefix(lines[i], "parent "))
+		if err != nil {
+			return links, []Problem{errorf("badParentSha1", "invalid 'parent' line format - bad sha1")}
+		}
+		links = append(links, link{to: h, objType: hash.CommitObject})
+		i++
+	}
+
+	// 3. author
+	if i >= len(lines) || !strings.HasPrefix(lines[i], "author ") {
+		return links, []Problem{errorf("missingAuthor", "invalid format - expected 'author' line")}
+	}
+	if p, ok := checkIdent(strings.TrimPrefix(lines[i], "author ")); !ok {
+		return links, []Problem{p}
+	}
+	i++
+
+	// 4. committer
+	if i >= len(lines) || !strings.HasPrefix(lines[i], "committer ") {
+		return links, []Problem{errorf("missingCommitter", "invalid format - expected 'committer' line")}
+	}
+	if p, ok := checkIdent(strings.TrimPrefix(lines[i], "committer ")); !ok {
+		return links, []Problem{p}
+	}
+
+	return links, nil
+}
+
+// checkTag 校验 tag 对象的头部
+// 头部必须依次是: object、type、tag，以及可选的 tagger
+func checkTag(content []byte) ([]link, []Problem) {
+	lines := headerLines(content)
+
+	if len(lines) < 1 || !strings.HasPrefix(lines[0], "object ") {
+		return nil, []Problem{errorf("missingObject", "invalid format - expected 'object' line")}
+	}
+	target, err := hash.ParseHash(strings.TrimPrefix(lines[0], "object "))
+	if err != nil {
+		return nil, []Problem{errorf("badObjectSha1", "invalid 'object' line format - bad sha1")}
+	}
+
+	if len(lines) < 2 || !strings.HasPrefix(lines[1], "type ") {
+		return nil, []Problem{errorf("missingTypeEntry", "invalid format - expected 'type' line")}
+	}
+	targetType, err := hash.ParseObjectType(strings.TrimPrefix(lines[1], "type "))
+	if err != nil {
+		return nil, []Problem{errorf("badType", "invalid 'type' value")}
+	}
+	links := []link{{to: target, objType: targetType}}
+
+	if len(lines) < 3 || !strings.HasPrefix(lines[2], "tag ") {
+		return links, []Problem{errorf("missingTagEntry", "invalid format - expected 'tag' line")}
+	}
+	if name := strings.TrimPrefix(lines[2], "tag "); name == "" || strings.ContainsAny(name, " ~^:?*[\\") {
+		return links, []Problem{warningf("badTagName", "invalid 'tag' name: "+name)}
+	}
+
+	if len(lines) < 4 || !strings.HasPrefix(lines[3], "tagger ") {
+		return links, []Problem{warningf("missingTaggerEntry", "invalid format - expected 'tagger' line")}
+	}
+	if p, ok := checkIdent(strings.TrimPrefix(lines[3], "tagger ")); !ok {
+		return links, []Problem{p}
+	}
+
+	return links, nil
+}
+
+// headerLines 返回对象头部（第一个空行之前）的各行，不包括续行
+func headerLines(content []byte) []string {
+	header := string(content)
+	if idx := strings.Index(header, "\n\n"); idx >= 0 {
+		header = header[:idx]
+	}
+	var lines []string
+	for _, line := range strings.Split(header, "\n") {
+		if strings.HasPrefix(line, " ") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// checkIdent 校验签名行的格式: Name <email> timestamp timezone
+func checkIdent(s string) (Problem, bool) {
+	// 1. 名称和邮箱
+	lt := strings.IndexByte(s, '<')
+	if lt < 0 {
+		return errorf("missingEmail", "invalid author/committer line - missing email"), false
+	}
+	if lt == 0 || s[lt-1] != ' ' {
+		return errorf("missingSpaceBeforeEmail", "invalid author/committer line - missing space before email"), false
+	}
+	if strings.ContainsAny(s[:lt], "<>\n") {
+		return errorf("badName", "invalid author/committer line - bad name"), false
+	}
+	gt := strings.IndexByte(s[lt:], '>')
+	if gt < 0 || strings.ContainsAny(s[lt+1:lt+gt], "<\n") {
+		return errorf("badEmail", "invalid author/committer line - bad email"), false
+	}
+	rest := s[lt+gt+1:]
+
+	// 2. 时间戳
+	if !strings.HasPrefix(rest, " ") {
+		return errorf("missingSpaceBeforeDate", "invalid author/committer line - missing space before date"), false
+	}
+	rest = rest[1:]
+	n := 0
+	for n < len(rest) && rest[n] >= '0' && rest[n] <= '9' {
+		n++
+	}
+	if n == 0 {
+		return errorf("badDate", "invalid author/committer line - bad date"), false
+	}
+	if n > 1 && rest[0] == '0' {
+		return errorf("zeroPaddedDate", "invalid author/committer line - zero-padded date"), false
+	}
+	if n > 19 {
+		return errorf("badDateOverflow", "invalid author/committer line - date causes integer overflow"), false
+	}
+	rest = rest[n:]
+
+	// 3. 时区: " +hhmm" 或 " -hhmm"
+	if len(rest) != 6 || rest[0] != ' ' || (rest[1] != '+' && rest[1] != '-') ||
+		strings.IndexFunc(rest[2:], func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+		return errorf("badTimezone", "invalid author/committer line - bad time zone"), false
+	}
+
+	return Problem{}, true
+}
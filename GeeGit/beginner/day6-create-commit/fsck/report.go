@@ -0,0 +1,42 @@
+package fsck
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// WriteHuman 以接近 `git fsck` 的格式输出问题
+//
+//	error in tree <hash>: treeNotSorted: not properly sorted
+//	missing blob <hash>
+//	dangling commit <hash>
+func WriteHuman(w io.Writer, r *Report) error {
+	bw := bufio.NewWriter(w)
+	for _, p := range r.Problems {
+		switch p.Kind {
+		case KindError, KindWarning:
+			fmt.Fprintf(bw, "%s in %s %s: %s: %s\n", p.Kind, p.Type, p.Object, p.ID, p.Message)
+		case KindMissing:
+			fmt.Fprintf(bw, "missing %s %s\n", p.Type, p.Object)
+			if p.Message != "" {
+				fmt.Fprintf(bw, "  (%s)\n", p.Message)
+			}
+		case KindDangling:
+			fmt.Fprintf(bw, "dangling %s %s\n", p.Type, p.Object)
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteJSON 以 JSON Lines 格式输出问题，每行一个 Problem，便于脚本处理
+func WriteJSON(w io.Writer, r *Report) error {
+	enc := json.NewEncoder(w)
+	for _, p := range r.Problems {
+		if err := enc.Encode(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
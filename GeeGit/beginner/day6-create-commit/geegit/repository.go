@@ -0,0 +1,286 @@
+package geegit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/checkout"
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/fetch"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/hooks"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/repository"
+	"geegit/beginner/day6-create-commit/revision"
+	"geegit/beginner/day6-create-commit/storage"
+)
+
+// 作为库使用时的入口：Repository 把 git 目录、工作区和存储后端放在一起，
+// 调用方不需要自己传递 gitDir，也不需要知道各个包中的函数分别负责哪一步
+//
+//	r, err := geegit.PlainInit("project", false)
+//	w, err := r.Worktree()
+//	err = w.Add("README.md")
+//	h, err := w.Commit("first commit", geegit.CommitOptions{})
+//	log, err := r.Log(geegit.LogOptions{})
+
+var (
+	// ErrRepositoryNotExists 表示路径上没有仓库
+	ErrRepositoryNotExists = errors.New("repository does not exist")
+	// ErrNoWorktree 表示仓库没有工作区（裸仓库，或者用 Init 创建的仓库）
+	ErrNoWorktree = errors.New("repository has no worktree")
+)
+
+// Repository 是一个 Git 仓库
+type Repository struct {
+	Storer storage.Storer
+
+	gitDir  string // 保存在文件系统上时的 git 目录，否则为空
+	workDir string // 工作区根目录，裸仓库为空
+}
+
+// Open 打开 path 处的仓库：path 是工作区根目录（其中有 .git 目录或 .git 文件）或者裸仓库
+func Open(path string) (*Repository, error) {
+	if _, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+		gitDir, workDir, err := repository.Discover(path)
+		if err != nil {
+			return nil, err
+		}
+		return newFilesystem(gitDir, workDir), nil
+	}
+	gitDir, err := repository.OpenLocal(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrRepositoryNotExists, path)
+	}
+	return newFilesystem(gitDir, ""), nil
+}
+
+// PlainInit 在 path 创建新仓库（git init），bare 为 true 时 path 本身就是 git 目录（git init --bare）
+func PlainInit(path string, bare bool) (*Repository, error) {
+	if bare {
+		if err := repository.InitGitDir(path); err != nil {
+			return nil, err
+		}
+		if err := config.SetValue(filepath.Join(path, "config"), "core.bare", "true"); err != nil {
+			return nil, err
+		}
+		gitDir, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		return newFilesystem(gitDir, ""), nil
+	}
+	if err := repository.InitRepository(path); err != nil {
+		return nil, err
+	}
+	workDir, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	return newFilesystem(filepath.Join(workDir, ".git"), workDir), nil
+}
+
+// Init 在任意存储后端中创建没有工作区的仓库，例如 storage.NewMemory()；HEAD 还不存在时指向 refs/heads/main
+func Init(s storage.Storer) (*Repository, error) {
+	if _, err := s.Reference("HEAD"); errors.Is(err, refs.ErrNotFound) {
+		list, err := s.References()
+		if err != nil {
+			return nil, err
+		}
+		if len(list) == 0 {
+			if err := s.SetSymbolicReference("HEAD", "refs/heads/main", ""); err != nil {
+				return nil, err
+			}
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return &Repository{Storer: s}, nil
+}
+
+// InitWithWorktree 与 Init 相同，但仓库有工作区 path（必须已经存在）：对象、引用和索引保存在 s 中，
+// 只有工作区中的文件在磁盘上，例如 storage.NewMemory() 配合临时目录；这样的仓库没有钩子
+func InitWithWorktree(s storage.Storer, path string) (*Repository, error) {
+	workDir, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(workDir); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", path)
+	}
+	r, err := Init(s)
+	if err != nil {
+		return nil, err
+	}
+	r.workDir = workDir
+	return r, nil
+}
+
+// CloneOptions 控制 Clone
+type CloneOptions struct {
+	fetch.Options
+	NoCheckout bool // 不检出工作区（git clone --no-checkout）
+}
+
+// Clone 把仓库 url 克隆到新目录 path，并检出远程 HEAD 所在的分支（之后运行 post-checkout 钩子）；
+// 获取或检出失败时删除创建的目录（目录原本存在时只删除其中的 .git）
+func Clone(url, path string, opts CloneOptions) (*Repository, error) {
+	if !strings.Contains(url, "://") {
+		abs, err := filepath.Abs(url)
+		if err != nil {
+			return nil, err
+		}
+		url = abs
+	}
+	if entries, err := os.ReadDir(path); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("destination path '%s' already exists and is not an empty directory", path)
+	}
+	workDir, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	_, statErr := os.Stat(workDir)
+	cleanup := func() {
+		if os.IsNotExist(statErr) {
+			os.RemoveAll(workDir)
+		} else {
+			os.RemoveAll(filepath.Join(workDir, ".git"))
+		}
+	}
+
+	r := newFilesystem(filepath.Join(workDir, ".git"), workDir)
+	if _, err := fetch.Clone(url, r.gitDir, opts.Options); err != nil {
+		cleanup()
+		return nil, err
+	}
+	if opts.NoCheckout {
+		return r, nil
+	}
+	head, err := r.Head()
+	if errors.Is(err, refs.ErrNotFound) {
+		return r, nil // 空仓库
+	} else if err != nil {
+		cleanup()
+		return nil, err
+	}
+	c, err := r.CommitObject(head.Hash)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	if err := checkout.Trees(r.gitDir, workDir, hash.Hash{}, c.Tree, checkout.Options{Force: true}); err != nil {
+		cleanup()
+		return nil, err
+	}
+	return r, hooks.PostCheckout(r.gitDir, workDir, hash.Hash{}, head.Hash, true, nil)
+}
+
+func newFilesystem(gitDir, workDir string) *Repository {
+	return &Repository{Storer: storage.NewFilesystem(gitDir), gitDir: gitDir, workDir: workDir}
+}
+
+// GitDir 返回仓库的 git 目录，仓库不保存在文件系统上时为空
+func (r *Repository) GitDir() string {
+	return r.gitDir
+}
+
+// Head 返回 HEAD：Target 是它指向的分支（分离 HEAD 时为空），Hash 是最终指向的 commit
+// 还没有任何 commit 时返回 refs.ErrNotFound
+func (r *Repository) Head() (*refs.Ref, error) {
+	return r.Storer.Reference("HEAD")
+}
+
+// Reference 读取一个引用（完整名称），跟随符号引用
+func (r *Repository) Reference(name string) (*refs.Ref, error) {
+	return r.Storer.Reference(name)
+}
+
+// References 列出 refs/ 下的所有引用，按名称排序
+func (r *Repository) References() ([]refs.Ref, error) {
+	return r.Storer.References()
+}
+
+// CommitObject 读取 commit；文件系统上的浅克隆仓库中，边界 commit 的 Parents 为空（与 commit.ReadCommit 一致）
+func (r *Repository) CommitObject(h hash.Hash) (*commit.Commit, error) {
+	if r.gitDir != "" {
+		return commit.ReadCommit(r.gitDir, h)
+	}
+	return storage.ReadCommit(r.Storer, h)
+}
+
+// LogOptions 控制 Log
+type LogOptions struct {
+	From     hash.Hash // 从这个 commit 开始，零哈希表示 HEAD
+	MaxCount int       // 最多返回的 commit 数量，0 表示不限制
+}
+
+// Log 按提交时间从新到旧返回 From 的历史（git log）
+// 文件系统上的仓库使用 revision.List（可以利用 commit-graph），其他后端直接遍历对象
+func (r *Repository) Log(opts LogOptions) ([]*commit.Commit, error) {
+	from := opts.From
+	if from.IsZero() {
+		head, err := r.Head()
+		if err != nil {
+			return nil, err
+		}
+		from = head.Hash
+	}
+
+	if r.gitDir != "" {
+		hashes, err := revision.List(r.gitDir, revision.ListOptions{Include: []hash.Hash{from}, MaxCount: opts.MaxCount})
+		if err != nil {
+			return nil, err
+		}
+		list := make([]*commit.Commit, 0, len(hashes))
+		for _, h := range hashes {
+			c, err := r.CommitObject(h)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, c)
+		}
+		return list, nil
+	}
+
+	// 按提交时间从新到旧遍历：队列按提交时间排序，每次取出最新的 commit，把它的父 commit 插入队列
+	c, err := r.CommitObject(from)
+	if err != nil {
+		return nil, err
+	}
+	var list []*commit.Commit
+	queue := []*commit.Commit{c}
+	seen := map[hash.Hash]bool{from: true}
+	for len(queue) > 0 && (opts.MaxCount == 0 || len(list) < opts.MaxCount) {
+		c := queue[0]
+		queue = queue[1:]
+		list = append(list, c)
+		for _, p := range c.Parents {
+			if seen[p] {
+				continue
+			}
+			seen[p] = true
+			pc, err := r.CommitObject(p)
+			if err != nil {
+				return nil, err
+			}
+			i := sort.Search(len(queue), func(i int) bool { return queue[i].Committer.When.Before(pc.Committer.When) })
+			queue = append(queue[:i], append([]*commit.Commit{pc}, queue[i:]...)...)
+		}
+	}
+	return list, nil
+}
+
+// Worktree 返回仓库的工作区，裸仓库和用 Init 创建的仓库返回 ErrNoWorktree
+func (r *Repository) Worktree() (*Worktree, error) {
+	if r.workDir == "" {
+		return nil, ErrNoWorktree
+	}
+	return &Worktree{r: r, Path: r.workDir}, nil
+}
@@ -0,0 +1,152 @@
+package geegit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/storage"
+)
+
+// newMemoryRepo 返回对象、引用和索引都保存在内存中、只有工作区在临时目录中的仓库
+func newMemoryRepo(t *testing.T) (*Repository, *Worktree, *storage.KVStorage) {
+	t.Helper()
+	s := storage.NewMemory()
+	r, err := InitWithWorktree(s, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, w, s
+}
+
+func writeFile(t *testing.T, w *Worktree, name, content string) {
+	t.Helper()
+	p := filepath.Join(w.Path, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryCommit(t *testing.T) {
+	r, w, s := newMemoryRepo(t)
+	if r.GitDir() != "" {
+		t.Fatalf("GitDir = %q, want empty", r.GitDir())
+	}
+	sig := &commit.Signature{Name: "A", Email: "a@example.com", When: time.Unix(1700000000, 0).UTC()}
+	opts := CommitOptions{Author: sig, Committer: sig}
+
+	if _, err := r.Head(); !errors.Is(err, refs.ErrNotFound) {
+		t.Fatalf("Head before the first commit = %v, want refs.ErrNotFound", err)
+	}
+	if _, err := w.Commit("empty", opts); !errors.Is(err, ErrEmptyCommit) {
+		t.Fatalf("Commit with an empty index = %v, want ErrEmptyCommit", err)
+	}
+
+	// 1. 第一个 commit：Add 写入的索引和对象都在 Storer 中
+	writeFile(t, w, "README.md", "hello\n")
+	writeFile(t, w, "src/main.go", "package main\n")
+	if err := w.Add("README.md", "src/main.go"); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := s.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Entries) != 2 {
+		t.Fatalf("index has %d entries, want 2", len(idx.Entries))
+	}
+	first, err := w.Commit("first", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := r.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Target != "refs/heads/main" || head.Hash != first {
+		t.Fatalf("HEAD = %+v, want refs/heads/main at %s", head, first)
+	}
+	if _, err := w.Commit("again", opts); !errors.Is(err, ErrEmptyCommit) {
+		t.Fatalf("Commit without changes = %v, want ErrEmptyCommit", err)
+	}
+
+	// 2. All 暂存被跟踪文件的修改和删除，未跟踪的文件不受影响
+	writeFile(t, w, "README.md", "hello again\n")
+	writeFile(t, w, "untracked.txt", "x\n")
+	if err := os.Remove(filepath.Join(w.Path, "src", "main.go")); err != nil {
+		t.Fatal(err)
+	}
+	opts.All = true
+	second, err := w.Commit("second\n\nbody", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := r.CommitObject(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Parents) != 1 || c.Parents[0] != first || c.Message != "second\n\nbody\n" {
+		t.Fatalf("commit = %+v", c)
+	}
+	root, err := storage.ReadTree(s, c.Tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(root.Entries) != 1 || root.Entries[0].Name != "README.md" {
+		t.Fatalf("tree = %+v", root.Entries)
+	}
+
+	// 3. Log 从 HEAD 开始按时间从新到旧
+	log, err := r.Log(LogOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 2 || log[0].Hash != second || log[1].Hash != first {
+		t.Fatalf("Log = %v", log)
+	}
+}
+
+func TestMemoryUnmerged(t *testing.T) {
+	_, w, s := newMemoryRepo(t)
+	writeFile(t, w, "a.txt", "a\n")
+	if err := w.Add("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := s.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := idx.Entries[0]
+	e.Stage = 2
+	idx.Add(e)
+	if err := s.SetIndex(idx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Commit("conflict", CommitOptions{}); err == nil {
+		t.Fatal("Commit with unmerged entries succeeded")
+	}
+}
+
+func TestInitWithoutWorktree(t *testing.T) {
+	r, err := Init(storage.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Worktree(); !errors.Is(err, ErrNoWorktree) {
+		t.Fatalf("Worktree = %v, want ErrNoWorktree", err)
+	}
+	if _, err := InitWithWorktree(storage.NewMemory(), filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("InitWithWorktree on a missing directory succeeded")
+	}
+}
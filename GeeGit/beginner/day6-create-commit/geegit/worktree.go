@@ -0,0 +1,129 @@
+package geegit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/hooks"
+	"geegit/beginner/day6-create-commit/index"
+)
+
+// ErrEmptyCommit 表示要提交的 tree 与 HEAD 的相同，没有需要提交的修改
+var ErrEmptyCommit = hooks.ErrEmptyCommit
+
+// Worktree 是仓库的工作区
+type Worktree struct {
+	Path string // 工作区根目录
+
+	r *Repository
+}
+
+// Add 把工作区中的文件（相对于工作区根目录的路径）加入索引（git add）；文件已经被删除时从索引中移除
+// 稀疏检出范围之外（skip-worktree）的文件本来就不在工作区中，不会被移除
+func (w *Worktree) Add(paths ...string) error {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		p = filepath.ToSlash(filepath.Clean(p))
+		e, err := w.stage(p)
+		if err != nil {
+			return err
+		}
+		if old, ok := idx.Find(p); e == nil && ok && old.SkipWorktree {
+			continue
+		}
+		if e == nil {
+			idx.Remove(p)
+		} else {
+			idx.Add(*e)
+		}
+	}
+	return w.r.Storer.SetIndex(idx)
+}
+
+// stage 把工作区文件写入对象库，返回对应的索引条目；文件不存在时返回 nil
+func (w *Worktree) stage(p string) (*index.Entry, error) {
+	full := filepath.Join(w.Path, filepath.FromSlash(p))
+	info, err := os.Lstat(full)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var content []byte
+	switch {
+	case info.IsDir():
+		return nil, errors.New("'" + p + "' is a directory")
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(full)
+		if err != nil {
+			return nil, err
+		}
+		content = []byte(filepath.ToSlash(target))
+	default:
+		if content, err = os.ReadFile(full); err != nil {
+			return nil, err
+		}
+	}
+	h, err := w.r.Storer.WriteObject(hash.BlobObject, content)
+	if err != nil {
+		return nil, err
+	}
+	e := index.NewEntry(p, info, h)
+	return &e, nil
+}
+
+// CommitOptions 控制 Commit
+type CommitOptions struct {
+	All        bool              // 先暂存所有被跟踪文件的修改和删除（git commit -a）
+	AllowEmpty bool              // 没有修改时也创建 commit（git commit --allow-empty）
+	NoVerify   bool              // 不运行 pre-commit 和 commit-msg 钩子（git commit --no-verify）
+	Author     *commit.Signature // 为 nil 时按 commit.DefaultSignature 的规则确定
+	Committer  *commit.Signature // 为 nil 时按 commit.DefaultSignature 的规则确定
+}
+
+// Commit 在当前分支上创建 commit（git commit），返回新 commit 的哈希
+// All 时先暂存被跟踪文件的修改，之后交给 hooks.Commit：运行钩子、写入 tree 和 commit、移动分支。
+// 索引、对象和引用都通过 Storer 读写；钩子和 COMMIT_EDITMSG 只存在于文件系统上的仓库中。
+// 钩子非零退出时中止提交并返回 *hooks.ExitError
+func (w *Worktree) Commit(msg string, opts CommitOptions) (hash.Hash, error) {
+	s := w.r.Storer
+	// 重新读取每个被跟踪的文件，内容没有变化时只刷新 stat 信息
+	if opts.All {
+		idx, err := s.Index()
+		if err != nil {
+			return hash.Hash{}, err
+		}
+		var paths []string
+		for _, e := range idx.Entries {
+			if e.Stage == 0 && !e.SkipWorktree && e.Mode != 0160000 {
+				paths = append(paths, e.Path)
+			}
+		}
+		for _, p := range paths {
+			e, err := w.stage(p)
+			if err != nil {
+				return hash.Hash{}, err
+			}
+			if e == nil {
+				idx.Remove(p)
+			} else {
+				idx.Add(*e)
+			}
+		}
+		if err := s.SetIndex(idx); err != nil {
+			return hash.Hash{}, err
+		}
+	}
+	return hooks.Commit(s, w.r.gitDir, w.Path, msg, hooks.CommitOptions{
+		AllowEmpty: opts.AllowEmpty,
+		NoVerify:   opts.NoVerify,
+		Author:     opts.Author,
+		Committer:  opts.Committer,
+	})
+}
@@ -0,0 +1,80 @@
+package gitdir
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// commonDirs 缓存 gitDir -> 公共目录，避免每次读取对象都重新读取 commondir 文件
+var commonDirs sync.Map
+
+// CommonDir 返回 gitDir 对应的公共目录
+// 链接工作区（git worktree add）的 gitDir 是主仓库的 .git/worktrees/<name>，其中的 commondir 文件
+// 记录主仓库 .git 的位置（通常是相对路径 "../.."）；对象库、引用和配置都保存在公共目录中。
+// 普通仓库的公共目录就是 gitDir 本身
+func CommonDir(gitDir string) string {
+	if dir, ok := commonDirs.Load(gitDir); ok {
+		return dir.(string)
+	}
+	dir := gitDir
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		dir = strings.TrimSpace(string(data))
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(gitDir, dir)
+		}
+		dir = filepath.Clean(dir)
+	}
+	commonDirs.Store(gitDir, dir)
+	return dir
+}
+
+// Path 返回 gitDir 中 name（使用 "/" 分隔，例如 "refs/heads/main"、"logs/HEAD"）的实际路径
+// 与 git 的规则一致：HEAD、index、logs/HEAD、refs/bisect/ 等属于各个工作区，
+// objects/、refs/、logs/refs/、config、packed-refs 等在所有工作区之间共享
+func Path(gitDir, name string) string {
+	return filepath.Join(Root(gitDir, name), filepath.FromSlash(name))
+}
+
+// Root 返回保存 name 的目录：共享的文件在公共目录中，其余的在 gitDir 中
+func Root(gitDir, name string) string {
+	if IsShared(name) {
+		return CommonDir(gitDir)
+	}
+	return gitDir
+}
+
+// perWorktree 是共享目录中属于各个工作区的例外
+var perWorktree = []string{
+	"logs/HEAD",
+	"refs/bisect", "refs/worktree", "refs/rewritten",
+	"logs/refs/bisect", "logs/refs/worktree", "logs/refs/rewritten",
+	"info/sparse-checkout",
+}
+
+// shared 是共享的文件和目录
+var shared = []string{
+	"objects", "refs", "logs", "config", "packed-refs", "shallow",
+	"hooks", "info", "remotes", "branches", "worktrees", "rr-cache", "modules",
+}
+
+// IsShared 判断 gitDir 中的 name 是否在所有工作区之间共享
+func IsShared(name string) bool {
+	for _, p := range perWorktree {
+		if hasPathPrefix(name, p) {
+			return false
+		}
+	}
+	for _, p := range shared {
+		if hasPathPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPathPrefix 判断 name 是否等于 prefix 或位于目录 prefix 之下
+func hasPathPrefix(name, prefix string) bool {
+	return name == prefix || strings.HasPrefix(name, prefix+"/")
+}
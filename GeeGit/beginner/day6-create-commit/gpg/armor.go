@@ -0,0 +1,111 @@
+package gpg
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// armorBlock 是一段 ASCII armor：-----BEGIN <Type>----- 和 -----END <Type>----- 之间的 base64 数据
+type armorBlock struct {
+	Type string // 例如 "PGP SIGNATURE"、"PGP PUBLIC KEY BLOCK"、"SSH SIGNATURE"
+	Data []byte
+}
+
+// encodeArmor 把数据编码为 ASCII armor，每行 width 个字符
+// OpenPGP 的 armor（RFC 4880 第 6 节）在头部后有一个空行，结尾带 "=" 开头的 CRC24 校验和；SSH 签名没有
+func encodeArmor(typ string, data []byte, width int) string {
+	var b strings.Builder
+	b.WriteString("-----BEGIN " + typ + "-----\n")
+	openpgp := strings.HasPrefix(typ, "PGP ")
+	if openpgp {
+		b.WriteString("\n")
+	}
+	text := base64.StdEncoding.EncodeToString(data)
+	for len(text) > width {
+		b.WriteString(text[:width] + "\n")
+		text = text[width:]
+	}
+	b.WriteString(text + "\n")
+	if openpgp {
+		crc := crc24(data)
+		b.WriteString("=" + base64.StdEncoding.EncodeToString([]byte{byte(crc >> 16), byte(crc >> 8), byte(crc)}) + "\n")
+	}
+	b.WriteString("-----END " + typ + "-----\n")
+	return b.String()
+}
+
+// decodeArmor 解码文本中的所有 armor 块，忽略块之外的内容
+func decodeArmor(text string) ([]armorBlock, error) {
+	var blocks []armorBlock
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		typ, ok := strings.CutPrefix(strings.TrimSpace(lines[i]), "-----BEGIN ")
+		if !ok || !strings.HasSuffix(typ, "-----") {
+			continue
+		}
+		typ = strings.TrimSuffix(typ, "-----")
+
+		// 1. OpenPGP armor 的头部（如 "Version: ..."）以空行结束
+		i++
+		if strings.HasPrefix(typ, "PGP ") {
+			for j := i; j < len(lines); j++ {
+				if strings.TrimSpace(lines[j]) == "" {
+					i = j + 1
+					break
+				}
+				if !strings.Contains(lines[j], ": ") {
+					break
+				}
+			}
+		}
+
+		// 2. 读取 base64 数据直到 END 行，"=" 开头的是校验和
+		var body strings.Builder
+		checksum := ""
+		end := false
+		for ; i < len(lines); i++ {
+			line := strings.TrimSpace(lines[i])
+			if line == "-----END "+typ+"-----" {
+				end = true
+				break
+			}
+			if strings.HasPrefix(line, "=") && len(line) == 5 {
+				checksum = line[1:]
+				continue
+			}
+			body.WriteString(line)
+		}
+		if !end {
+			return nil, fmt.Errorf("armor block %s is not terminated", typ)
+		}
+		data, err := base64.StdEncoding.DecodeString(body.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid armor block %s: %v", typ, err)
+		}
+		if checksum != "" {
+			sum, err := base64.StdEncoding.DecodeString(checksum)
+			crc := crc24(data)
+			if err != nil || len(sum) != 3 || sum[0] != byte(crc>>16) || sum[1] != byte(crc>>8) || sum[2] != byte(crc) {
+				return nil, fmt.Errorf("armor block %s has a bad checksum", typ)
+			}
+		}
+		blocks = append(blocks, armorBlock{Type: typ, Data: data})
+	}
+	return blocks, nil
+}
+
+// crc24 计算 OpenPGP armor 使用的 CRC-24 校验和
+func crc24(data []byte) uint32 {
+	crc := uint32(0xB704CE)
+	for _, b := range data {
+		crc ^= uint32(b) << 16
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= 0x1864CFB
+			}
+		}
+	}
+	return crc & 0xFFFFFF
+}
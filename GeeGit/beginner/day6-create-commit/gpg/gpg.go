@@ -0,0 +1,116 @@
+package gpg
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/config"
+)
+
+// Format 是签名格式，对应配置 gpg.format
+type Format string
+
+const (
+	FormatOpenPGP Format = "openpgp" // OpenPGP 签名（RFC 4880），gpg 的格式
+	FormatSSH     Format = "ssh"     // SSH 签名（PROTOCOL.sshsig），`ssh-keygen -Y sign` 的格式
+)
+
+// sigPrefixes 是各格式签名的第一行，用于从签名内容判断格式
+var sigPrefixes = map[string]Format{
+	"-----BEGIN PGP SIGNATURE-----": FormatOpenPGP,
+	"-----BEGIN PGP MESSAGE-----":   FormatOpenPGP,
+	"-----BEGIN SSH SIGNATURE-----": FormatSSH,
+}
+
+// Result 是签名验证的结果
+type Result struct {
+	Format Format
+	Good   bool   // 签名正确，且签名的公钥是可信的
+	Signer string // 签名者：OpenPGP 为用户 ID，SSH 为 allowed signers 中的 principal
+	Key    string // 签名公钥的指纹
+	Output string // 验证过程的说明，与 gpg 或 ssh-keygen 的输出相近
+}
+
+// ConfiguredFormat 返回 gpg.format 配置的签名格式，默认为 openpgp
+func ConfiguredFormat(cfg *config.Config) (Format, error) {
+	v, ok := cfg.Get("gpg.format")
+	if !ok {
+		return FormatOpenPGP, nil
+	}
+	switch f := Format(strings.ToLower(v)); f {
+	case FormatOpenPGP, FormatSSH:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid value for 'gpg.format': '%s'", v)
+}
+
+// Sign 对 payload 签名，返回 ASCII armor 格式的签名（以换行结尾）
+// key 是私钥文件的路径，为空时使用 user.signingKey：
+//   - openpgp: armor 或二进制格式的私钥文件（`gpg --export-secret-keys`），使用其中的主密钥
+//   - ssh: OpenSSH 格式的私钥文件，也可以是同名的公钥文件（*.pub）
+//
+// 不支持有密码保护的私钥，也不会调用 gpg 或 ssh-agent
+func Sign(cfg *config.Config, payload []byte, key string) (string, error) {
+	format, err := ConfiguredFormat(cfg)
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		key, _ = cfg.Path("user.signingkey")
+	}
+	if key == "" {
+		return "", fmt.Errorf("user.signingKey needs to be set to a key file for %s signing", format)
+	}
+	key = config.ExpandPath(key)
+
+	if format == FormatSSH {
+		return signSSH(key, payload)
+	}
+	return signOpenPGP(key, payload)
+}
+
+// Verify 验证 sig 是否是 payload 的正确签名，签名格式由签名内容决定
+//   - openpgp: 在 gpg.openpgp.keyring 指定的公钥文件（`gpg --export`）中查找签名公钥
+//   - ssh: 签名公钥需要出现在 gpg.ssh.allowedSignersFile 中
+//
+// 签名不正确或公钥不可信时 Result.Good 为 false，配置缺失等无法验证的情况返回错误
+func Verify(cfg *config.Config, payload []byte, sig string) (*Result, error) {
+	format, ok := SignatureFormat(sig)
+	if !ok {
+		return nil, fmt.Errorf("unknown signature format")
+	}
+	if format == FormatSSH {
+		file, _ := cfg.Path("gpg.ssh.allowedsignersfile")
+		return verifySSH(file, payload, sig)
+	}
+	file, _ := cfg.Path("gpg.openpgp.keyring")
+	return verifyOpenPGP(file, payload, sig)
+}
+
+// SignatureFormat 根据签名的第一行判断签名格式
+func SignatureFormat(sig string) (Format, bool) {
+	first, _, _ := strings.Cut(sig, "\n")
+	format, ok := sigPrefixes[strings.TrimSpace(first)]
+	return format, ok
+}
+
+// ParseSigned 把签名附在末尾的内容（例如带签名的 tag 对象）拆成被签名的数据和签名
+// 签名从最后一个以签名开始行开头的行算起，没有签名时 sig 为空
+func ParseSigned(buf []byte) (payload []byte, sig string) {
+	start := -1
+	for pos := 0; pos < len(buf); {
+		line := buf[pos:]
+		if end := bytes.IndexByte(line, '\n'); end >= 0 {
+			line = line[:end+1]
+		}
+		if _, ok := SignatureFormat(string(line)); ok {
+			start = pos
+		}
+		pos += len(line)
+	}
+	if start < 0 {
+		return buf, ""
+	}
+	return buf[:start], string(buf[start:])
+}
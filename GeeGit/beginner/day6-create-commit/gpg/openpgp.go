@@ -0,0 +1,612 @@
+package gpg
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+// OpenPGP 数据包类型（RFC 4880 第 4.3 节）
+const (
+	tagSignature       = 2
+	tagSecretKey       = 5
+	tagPublicKey       = 6
+	tagSecretSubkey    = 7
+	tagUserID          = 13
+	tagPublicSubkey    = 14
+	algoRSA            = 1
+	algoEdDSA          = 22
+	sigTypeBinary      = 0x00
+	subpacketCreated   = 2
+	subpacketIssuer    = 16
+	subpacketSignerUID = 28
+	subpacketIssuerFPR = 33
+)
+
+// ed25519OID 是 EdDSA 公钥中 Ed25519 曲线的 OID（1.3.6.1.4.1.11591.15.1）
+var ed25519OID = []byte{0x2B, 0x06, 0x01, 0x04, 0x01, 0xDA, 0x47, 0x0F, 0x01}
+
+// pgpHashes 是 OpenPGP 哈希算法编号到 Go 哈希的映射（RFC 4880 第 9.4 节）
+var pgpHashes = map[byte]crypto.Hash{
+	2:  crypto.SHA1,
+	8:  crypto.SHA256,
+	9:  crypto.SHA384,
+	10: crypto.SHA512,
+	11: crypto.SHA224,
+}
+
+// pgpHashSHA512 是签名时使用的哈希算法，与 gpg 的默认值一致
+const pgpHashSHA512 = 10
+
+// pgpKey 是 OpenPGP 的一个（子）密钥，只支持 v4 的 RSA 和 Ed25519 密钥
+type pgpKey struct {
+	Algo        byte
+	Created     time.Time
+	Public      crypto.PublicKey
+	Private     crypto.Signer // 只有从私钥文件读取时才有
+	Fingerprint []byte
+	UserIDs     []string // 主密钥的用户 ID，子密钥继承主密钥的用户 ID
+	primary     *pgpKey  // 子密钥所属的主密钥
+}
+
+// KeyID 返回 64 位的密钥 ID，即指纹的最后 8 字节
+func (k *pgpKey) KeyID() []byte { return k.Fingerprint[len(k.Fingerprint)-8:] }
+
+// algoName 返回 gpg 输出中使用的算法名称
+func algoName(algo byte) string {
+	if algo == algoEdDSA {
+		return "EDDSA"
+	}
+	return "RSA"
+}
+
+// packet 是一个 OpenPGP 数据包
+type packet struct {
+	Tag  byte
+	Body []byte
+}
+
+// readPackets 拆分 OpenPGP 数据包，支持新旧两种包头格式（不支持分段长度）
+func readPackets(data []byte) ([]packet, error) {
+	var packets []packet
+	for len(data) > 0 {
+		ctb := data[0]
+		if ctb&0x80 == 0 {
+			return nil, errors.New("invalid openpgp packet header")
+		}
+		var tag byte
+		var length, hlen int
+		if ctb&0x40 != 0 {
+			// 新格式: 1、2 或 5 字节的长度
+			tag = ctb & 0x3F
+			if len(data) < 2 {
+				return nil, errors.New("openpgp packet is truncated")
+			}
+			switch l := data[1]; {
+			case l < 192:
+				length, hlen = int(l), 2
+			case l < 224:
+				if len(data) < 3 {
+					return nil, errors.New("openpgp packet is truncated")
+				}
+				length, hlen = (int(l)-192)<<8+int(data[2])+192, 3
+			case l == 255:
+				if len(data) < 6 {
+					return nil, errors.New("openpgp packet is truncated")
+				}
+				length, hlen = int(binary.BigEndian.Uint32(data[2:])), 6
+			default:
+				return nil, errors.New("partial openpgp packet lengths are not supported")
+			}
+		} else {
+			// 旧格式: 长度字段为 1、2 或 4 字节
+			tag = (ctb >> 2) & 0x0F
+			n := []int{1, 2, 4, 0}[ctb&3]
+			if n == 0 {
+				n, length = 0, len(data)-1
+			}
+			if len(data) < 1+n {
+				return nil, errors.New("openpgp packet is truncated")
+			}
+			for i := 0; i < n; i++ {
+				length = length<<8 | int(data[1+i])
+			}
+			hlen = 1 + n
+		}
+		if len(data) < hlen+length {
+			return nil, errors.New("openpgp packet is truncated")
+		}
+		packets = append(packets, packet{Tag: tag, Body: data[hlen : hlen+length]})
+		data = data[hlen+length:]
+	}
+	return packets, nil
+}
+
+// readMPI 读取一个 MPI：2 字节的位数，后跟大端整数
+func readMPI(data []byte) ([]byte, []byte, error) {
+	if len(data) < 2 {
+		return nil, nil, errors.New("mpi is truncated")
+	}
+	n := (int(binary.BigEndian.Uint16(data)) + 7) / 8
+	if len(data) < 2+n {
+		return nil, nil, errors.New("mpi is truncated")
+	}
+	return data[2 : 2+n], data[2+n:], nil
+}
+
+// writeMPI 写入一个 MPI，去掉前导的 0 字节
+func writeMPI(buf *bytes.Buffer, b []byte) {
+	b = bytes.TrimLeft(b, "\x00")
+	bits := 0
+	if len(b) > 0 {
+		bits = (len(b)-1)*8 + bitLen(b[0])
+	}
+	buf.Write([]byte{byte(bits >> 8), byte(bits)})
+	buf.Write(b)
+}
+
+func bitLen(b byte) int {
+	n := 0
+	for ; b != 0; b >>= 1 {
+		n++
+	}
+	return n
+}
+
+// parseKey 解析公钥或私钥数据包（v4），返回密钥和公钥部分之后的剩余数据
+func parseKey(body []byte) (*pgpKey, []byte, error) {
+	if len(body) < 6 || body[0] != 4 {
+		return nil, nil, errors.New("only version 4 openpgp keys are supported")
+	}
+	k := &pgpKey{
+		Algo:    body[5],
+		Created: time.Unix(int64(binary.BigEndian.Uint32(body[1:5])), 0),
+	}
+	rest := body[6:]
+	var err error
+	switch k.Algo {
+	case algoRSA, 2, 3:
+		var n, e []byte
+		if n, rest, err = readMPI(rest); err != nil {
+			return nil, nil, err
+		}
+		if e, rest, err = readMPI(rest); err != nil {
+			return nil, nil, err
+		}
+		k.Algo = algoRSA
+		k.Public = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	case algoEdDSA:
+		if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+			return nil, nil, errors.New("eddsa key is truncated")
+		}
+		oid := rest[1 : 1+rest[0]]
+		var point []byte
+		if point, rest, err = readMPI(rest[1+rest[0]:]); err != nil {
+			return nil, nil, err
+		}
+		if !bytes.Equal(oid, ed25519OID) || len(point) != 1+ed25519.PublicKeySize || point[0] != 0x40 {
+			return nil, nil, errors.New("only ed25519 eddsa keys are supported")
+		}
+		k.Public = ed25519.PublicKey(point[1:])
+	default:
+		return nil, nil, fmt.Errorf("unsupported openpgp public key algorithm %d", k.Algo)
+	}
+
+	// 指纹: SHA1(0x99 || 2 字节长度 || 公钥数据包正文)
+	public := body[:len(body)-len(rest)]
+	h := sha1.New()
+	h.Write([]byte{0x99, byte(len(public) >> 8), byte(len(public))})
+	h.Write(public)
+	k.Fingerprint = h.Sum(nil)
+	return k, rest, nil
+}
+
+// parseSecret 解析私钥数据包中公钥之后的未加密私钥字段
+func (k *pgpKey) parseSecret(data []byte) error {
+	if len(data) < 1 {
+		return errors.New("secret key is truncated")
+	}
+	if data[0] != 0 {
+		return errors.New("secret key is protected by a passphrase, which is not supported")
+	}
+	data = data[1:]
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		var fields [4][]byte
+		var err error
+		for i := range fields {
+			if fields[i], data, err = readMPI(data); err != nil {
+				return err
+			}
+		}
+		key := &rsa.PrivateKey{
+			PublicKey: *pub,
+			D:         new(big.Int).SetBytes(fields[0]),
+			Primes:    []*big.Int{new(big.Int).SetBytes(fields[1]), new(big.Int).SetBytes(fields[2])},
+		}
+		key.Precompute()
+		k.Private = key
+	case ed25519.PublicKey:
+		seed, _, err := readMPI(data)
+		if err != nil {
+			return err
+		}
+		if len(seed) > ed25519.SeedSize {
+			return errors.New("invalid ed25519 secret key")
+		}
+		padded := make([]byte, ed25519.SeedSize)
+		copy(padded[ed25519.SeedSize-len(seed):], seed)
+		k.Private = ed25519.NewKeyFromSeed(padded)
+	}
+	return nil
+}
+
+// readKeyring 读取 armor 或二进制格式的密钥文件，返回其中所有的主密钥和子密钥
+func readKeyring(file string) ([]*pgpKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var chunks [][]byte
+	if bytes.Contains(data, []byte("-----BEGIN PGP")) {
+		blocks, err := decodeArmor(string(data))
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range blocks {
+			chunks = append(chunks, b.Data)
+		}
+	} else {
+		chunks = [][]byte{data}
+	}
+
+	var keys []*pgpKey
+	var primary *pgpKey
+	for _, chunk := range chunks {
+		packets, err := readPackets(chunk)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range packets {
+			switch p.Tag {
+			case tagPublicKey, tagSecretKey, tagPublicSubkey, tagSecretSubkey:
+				k, rest, err := parseKey(p.Body)
+				if err != nil {
+					// 跳过不支持的密钥
+					if p.Tag == tagPublicKey || p.Tag == tagSecretKey {
+						primary = nil
+					}
+					continue
+				}
+				if p.Tag == tagSecretKey || p.Tag == tagSecretSubkey {
+					if err := k.parseSecret(rest); err != nil {
+						return nil, fmt.Errorf("%s: %v", file, err)
+					}
+				}
+				if p.Tag == tagPublicKey || p.Tag == tagSecretKey {
+					primary = k
+				} else if primary == nil {
+					continue
+				} else {
+					k.primary = primary
+				}
+				keys = append(keys, k)
+			case tagUserID:
+				if primary != nil {
+					primary.UserIDs = append(primary.UserIDs, string(p.Body))
+				}
+			}
+		}
+	}
+
+	// 子密钥继承主密钥的用户 ID
+	for _, k := range keys {
+		if k.primary != nil {
+			k.UserIDs = k.primary.UserIDs
+		}
+	}
+	return keys, nil
+}
+
+// signOpenPGP 用 OpenPGP 私钥文件中的主密钥对 payload 做二进制签名，返回 armor 格式的签名
+func signOpenPGP(keyFile string, payload []byte) (string, error) {
+	keys, err := readKeyring(keyFile)
+	if err != nil {
+		return "", fmt.Errorf("unable to read signing key '%s': %v", keyFile, err)
+	}
+	var key *pgpKey
+	for _, k := range keys {
+		if k.Private != nil {
+			key = k
+			break
+		}
+	}
+	if key == nil {
+		return "", fmt.Errorf("no secret key found in '%s'", keyFile)
+	}
+
+	// 1. 带哈希的子包: 签发者指纹、签名时间、签名者用户 ID（邮箱）
+	var hashed bytes.Buffer
+	writeSubpacket(&hashed, subpacketIssuerFPR, append([]byte{4}, key.Fingerprint...))
+	created := make([]byte, 4)
+	binary.BigEndian.PutUint32(created, uint32(time.Now().Unix()))
+	writeSubpacket(&hashed, subpacketCreated, created)
+	if len(key.UserIDs) > 0 {
+		writeSubpacket(&hashed, subpacketSignerUID, []byte(emailOf(key.UserIDs[0])))
+	}
+
+	// 2. 计算签名的哈希
+	header := []byte{4, sigTypeBinary, key.Algo, pgpHashSHA512, byte(hashed.Len() >> 8), byte(hashed.Len())}
+	header = append(header, hashed.Bytes()...)
+	digest := signatureDigest(crypto.SHA512, payload, header)
+
+	// 3. 签名并组装签名数据包: 不带哈希的子包只有签发者密钥 ID
+	var unhashed bytes.Buffer
+	writeSubpacket(&unhashed, subpacketIssuer, key.KeyID())
+	var body bytes.Buffer
+	body.Write(header)
+	body.Write([]byte{byte(unhashed.Len() >> 8), byte(unhashed.Len())})
+	body.Write(unhashed.Bytes())
+	body.Write(digest[:2])
+	switch priv := key.Private.(type) {
+	case *rsa.PrivateKey:
+		sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA512, digest)
+		if err != nil {
+			return "", err
+		}
+		writeMPI(&body, sig)
+	case ed25519.PrivateKey:
+		sig := ed25519.Sign(priv, digest)
+		writeMPI(&body, sig[:32])
+		writeMPI(&body, sig[32:])
+	}
+
+	// 旧格式包头，2 字节长度，与 gpg 的输出一致
+	packetData := []byte{0x80 | tagSignature<<2 | 1, byte(body.Len() >> 8), byte(body.Len())}
+	packetData = append(packetData, body.Bytes()...)
+	return encodeArmor("PGP SIGNATURE", packetData, 64), nil
+}
+
+// writeSubpacket 写入一个签名子包（长度小于 192 字节）
+func writeSubpacket(buf *bytes.Buffer, typ byte, data []byte) {
+	buf.WriteByte(byte(len(data) + 1))
+	buf.WriteByte(typ)
+	buf.Write(data)
+}
+
+// signatureDigest 计算 v4 签名的哈希: 数据、签名头部和带哈希的子包、以及 6 字节的尾部
+func signatureDigest(h crypto.Hash, payload, header []byte) []byte {
+	hh := h.New()
+	hh.Write(payload)
+	hh.Write(header)
+	trailer := []byte{4, 0xFF, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(trailer[2:], uint32(len(header)))
+	hh.Write(trailer)
+	return hh.Sum(nil)
+}
+
+// emailOf 返回用户 ID "Name <email>" 中的邮箱，没有尖括号时返回整个用户 ID
+func emailOf(uid string) string {
+	if i := strings.IndexByte(uid, '<'); i >= 0 {
+		if j := strings.IndexByte(uid[i:], '>'); j > 0 {
+			return uid[i+1 : i+j]
+		}
+	}
+	return uid
+}
+
+// pgpSignature 是解析后的 v4 签名数据包
+type pgpSignature struct {
+	Type      byte
+	Algo      byte
+	Hash      byte
+	Created   time.Time
+	IssuerFPR []byte
+	IssuerID  []byte
+	SignerUID string
+	header    []byte // 参与哈希的部分：版本到带哈希的子包结尾
+	prefix    []byte // 哈希值的前 2 字节
+	mpis      [][]byte
+}
+
+// parseSignature 解析 armor 格式的 OpenPGP 签名
+func parseSignature(armored string) (*pgpSignature, error) {
+	blocks, err := decodeArmor(armored)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) == 0 || blocks[0].Type != "PGP SIGNATURE" {
+		return nil, errors.New("no openpgp signature found")
+	}
+	packets, err := readPackets(blocks[0].Data)
+	if err != nil {
+		return nil, err
+	}
+	if len(packets) == 0 || packets[0].Tag != tagSignature {
+		return nil, errors.New("no openpgp signature found")
+	}
+
+	body := packets[0].Body
+	if len(body) < 6 || body[0] != 4 {
+		return nil, errors.New("only version 4 openpgp signatures are supported")
+	}
+	s := &pgpSignature{Type: body[1], Algo: body[2], Hash: body[3]}
+	hashedLen := int(binary.BigEndian.Uint16(body[4:]))
+	if len(body) < 6+hashedLen+2 {
+		return nil, errors.New("openpgp signature is truncated")
+	}
+	s.header = body[:6+hashedLen]
+	rest := body[6+hashedLen:]
+	unhashedLen := int(binary.BigEndian.Uint16(rest))
+	if len(rest) < 2+unhashedLen+2 {
+		return nil, errors.New("openpgp signature is truncated")
+	}
+	if err := s.parseSubpackets(body[6 : 6+hashedLen]); err != nil {
+		return nil, err
+	}
+	if err := s.parseSubpackets(rest[2 : 2+unhashedLen]); err != nil {
+		return nil, err
+	}
+	rest = rest[2+unhashedLen:]
+	s.prefix, rest = rest[:2], rest[2:]
+	for len(rest) > 0 {
+		var mpi []byte
+		if mpi, rest, err = readMPI(rest); err != nil {
+			return nil, err
+		}
+		s.mpis = append(s.mpis, mpi)
+	}
+	return s, nil
+}
+
+// parseSubpackets 解析签名子包，只关心时间、签发者和签名者用户 ID
+func (s *pgpSignature) parseSubpackets(data []byte) error {
+	for len(data) > 0 {
+		var length, hlen int
+		switch l := data[0]; {
+		case l < 192:
+			length, hlen = int(l), 1
+		case l < 255:
+			if len(data) < 2 {
+				return errors.New("signature subpacket is truncated")
+			}
+			length, hlen = (int(l)-192)<<8+int(data[1])+192, 2
+		default:
+			if len(data) < 5 {
+				return errors.New("signature subpacket is truncated")
+			}
+			length, hlen = int(binary.BigEndian.Uint32(data[1:])), 5
+		}
+		if length < 1 || len(data) < hlen+length {
+			return errors.New("signature subpacket is truncated")
+		}
+		typ, value := data[hlen]&0x7F, data[hlen+1:hlen+length]
+		switch typ {
+		case subpacketCreated:
+			if len(value) == 4 {
+				s.Created = time.Unix(int64(binary.BigEndian.Uint32(value)), 0)
+			}
+		case subpacketIssuer:
+			s.IssuerID = value
+		case subpacketIssuerFPR:
+			if len(value) > 1 {
+				s.IssuerFPR = value[1:]
+			}
+		case subpacketSignerUID:
+			s.SignerUID = string(value)
+		}
+		data = data[hlen+length:]
+	}
+	return nil
+}
+
+// verifyOpenPGP 用 keyring 中的公钥验证 OpenPGP 签名，输出格式与 gpg 相近
+func verifyOpenPGP(keyring string, payload []byte, armored string) (*Result, error) {
+	res := &Result{Format: FormatOpenPGP}
+	sig, err := parseSignature(armored)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. 输出签名时间和签发者
+	var out strings.Builder
+	issuer := strings.ToUpper(hex.EncodeToString(sig.IssuerFPR))
+	if issuer == "" {
+		issuer = strings.ToUpper(hex.EncodeToString(sig.IssuerID))
+	}
+	fmt.Fprintf(&out, "Signature made %s\n", sig.Created.Format("Mon Jan _2 15:04:05 2006 MST"))
+	fmt.Fprintf(&out, "               using %s key %s\n", algoName(sig.Algo), issuer)
+	if sig.SignerUID != "" {
+		fmt.Fprintf(&out, "               issuer \"%s\"\n", sig.SignerUID)
+	}
+	res.Key = issuer
+
+	// 2. 在 keyring 中按指纹或密钥 ID 查找公钥
+	if keyring == "" {
+		return nil, fmt.Errorf("gpg.openpgp.keyring needs to be configured and exist for openpgp signature verification")
+	}
+	keys, err := readKeyring(keyring)
+	if err != nil {
+		return nil, fmt.Errorf("gpg.openpgp.keyring needs to be configured and exist for openpgp signature verification")
+	}
+	var key *pgpKey
+	for _, k := range keys {
+		if (sig.IssuerFPR != nil && bytes.Equal(k.Fingerprint, sig.IssuerFPR)) ||
+			(sig.IssuerFPR == nil && bytes.Equal(k.KeyID(), sig.IssuerID)) {
+			key = k
+			break
+		}
+	}
+	if key == nil {
+		out.WriteString("Can't check signature: No public key\n")
+		res.Output = out.String()
+		return res, nil
+	}
+	uid := ""
+	if len(key.UserIDs) > 0 {
+		uid = key.UserIDs[0]
+	}
+
+	// 3. 验证签名
+	if sig.Type != sigTypeBinary || !key.verify(sig, payload) {
+		fmt.Fprintf(&out, "BAD signature from \"%s\"\n", uid)
+		res.Output = out.String()
+		return res, nil
+	}
+	fmt.Fprintf(&out, "Good signature from \"%s\"\n", uid)
+	if len(key.UserIDs) > 1 {
+		for _, other := range key.UserIDs[1:] {
+			fmt.Fprintf(&out, "                aka \"%s\"\n", other)
+		}
+	}
+	res.Good = true
+	res.Signer = uid
+	res.Key = strings.ToUpper(hex.EncodeToString(key.Fingerprint))
+	res.Output = out.String()
+	return res, nil
+}
+
+// verify 检查签名是否由密钥 k 对 payload 签出
+func (k *pgpKey) verify(sig *pgpSignature, payload []byte) bool {
+	h, ok := pgpHashes[sig.Hash]
+	if !ok || !h.Available() || sig.Algo != k.Algo {
+		return false
+	}
+	digest := signatureDigest(h, payload, sig.header)
+	if !bytes.Equal(digest[:2], sig.prefix) {
+		return false
+	}
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		// MPI 去掉了前导 0，需要补齐到模数的长度
+		size := pub.Size()
+		if len(sig.mpis) != 1 || len(sig.mpis[0]) > size {
+			return false
+		}
+		s := make([]byte, size)
+		copy(s[size-len(sig.mpis[0]):], sig.mpis[0])
+		return rsa.VerifyPKCS1v15(pub, h, digest, s) == nil
+	case ed25519.PublicKey:
+		if len(sig.mpis) != 2 || len(sig.mpis[0]) > 32 || len(sig.mpis[1]) > 32 {
+			return false
+		}
+		s := make([]byte, ed25519.SignatureSize)
+		copy(s[32-len(sig.mpis[0]):32], sig.mpis[0])
+		copy(s[64-len(sig.mpis[1]):], sig.mpis[1])
+		return ed25519.Verify(pub, digest, s)
+	}
+	return false
+}
@@ -0,0 +1,494 @@
+package gpg
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/wildmatch"
+)
+
+// SSH 签名（PROTOCOL.sshsig）的固定取值，与 `ssh-keygen -Y sign -n git` 一致
+const (
+	sshMagic     = "SSHSIG"
+	sshNamespace = "git"
+	sshHashAlg   = "sha512"
+)
+
+// sshPublicKey 是一个 SSH 公钥，Blob 是它的 wire 格式编码
+type sshPublicKey struct {
+	Type string // "ssh-ed25519"、"ssh-rsa" 或 "ecdsa-sha2-nistp256" 等
+	Blob []byte
+	Key  crypto.PublicKey
+}
+
+// Fingerprint 返回 `ssh-keygen -l` 格式的 SHA256 指纹
+func (k *sshPublicKey) Fingerprint() string {
+	sum := sha256.Sum256(k.Blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// TypeName 返回 ssh-keygen 输出中使用的密钥类型名称
+func (k *sshPublicKey) TypeName() string {
+	switch {
+	case k.Type == "ssh-ed25519":
+		return "ED25519"
+	case k.Type == "ssh-rsa":
+		return "RSA"
+	case strings.HasPrefix(k.Type, "ecdsa-"):
+		return "ECDSA"
+	}
+	return strings.ToUpper(k.Type)
+}
+
+// sshReader 读取 SSH wire 格式中的 uint32、string 和 mpint
+type sshReader struct {
+	data []byte
+	err  error
+}
+
+func (r *sshReader) uint32() uint32 {
+	if r.err != nil || len(r.data) < 4 {
+		r.err = errors.New("ssh data is truncated")
+		return 0
+	}
+	n := binary.BigEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return n
+}
+
+func (r *sshReader) bytes() []byte {
+	n := r.uint32()
+	if r.err != nil || uint32(len(r.data)) < n {
+		r.err = errors.New("ssh data is truncated")
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *sshReader) string() string { return string(r.bytes()) }
+
+func (r *sshReader) mpint() *big.Int { return new(big.Int).SetBytes(r.bytes()) }
+
+// sshWriter 按 SSH wire 格式写入数据
+type sshWriter struct {
+	bytes.Buffer
+}
+
+func (w *sshWriter) uint32(n uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], n)
+	w.Write(b[:])
+}
+
+func (w *sshWriter) string(b []byte) {
+	w.uint32(uint32(len(b)))
+	w.Write(b)
+}
+
+// mpint 写入正整数：最高位为 1 时需要补一个 0 字节
+func (w *sshWriter) mpint(n *big.Int) {
+	b := n.Bytes()
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	w.string(b)
+}
+
+// parseSSHPublicKey 解析 wire 格式的公钥
+func parseSSHPublicKey(blob []byte) (*sshPublicKey, error) {
+	r := &sshReader{data: blob}
+	k := &sshPublicKey{Type: r.string(), Blob: blob}
+	switch k.Type {
+	case "ssh-ed25519":
+		pub := r.bytes()
+		if r.err == nil && len(pub) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 public key")
+		}
+		k.Key = ed25519.PublicKey(pub)
+	case "ssh-rsa":
+		e, n := r.mpint(), r.mpint()
+		k.Key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521":
+		r.string()
+		curve := sshCurve(k.Type)
+		x, y := elliptic.Unmarshal(curve, r.bytes())
+		if r.err == nil && x == nil {
+			return nil, fmt.Errorf("invalid ecdsa public key")
+		}
+		k.Key = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	default:
+		return nil, fmt.Errorf("unsupported ssh key type %s", k.Type)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return k, nil
+}
+
+// sshCurve 返回 ECDSA 密钥类型对应的曲线
+func sshCurve(typ string) elliptic.Curve {
+	switch typ {
+	case "ecdsa-sha2-nistp384":
+		return elliptic.P384()
+	case "ecdsa-sha2-nistp521":
+		return elliptic.P521()
+	}
+	return elliptic.P256()
+}
+
+// sshCurveHash 返回 ECDSA 签名使用的哈希算法（RFC 5656 第 6.2.1 节）
+func sshCurveHash(curve elliptic.Curve) crypto.Hash {
+	switch curve.Params().BitSize {
+	case 384:
+		return crypto.SHA384
+	case 521:
+		return crypto.SHA512
+	}
+	return crypto.SHA256
+}
+
+// readSSHPrivateKey 读取 OpenSSH 格式（openssh-key-v1）的私钥文件
+// file 也可以是公钥文件（*.pub），这时读取同名的私钥文件，与 `ssh-keygen -Y sign -f` 相同
+// 不支持有密码保护的私钥
+func readSSHPrivateKey(file string) (crypto.Signer, *sshPublicKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read signing key '%s': %v", file, err)
+	}
+	if bytes.HasPrefix(data, []byte("ssh-")) || bytes.HasPrefix(data, []byte("ecdsa-")) {
+		return readSSHPrivateKey(strings.TrimSuffix(file, ".pub"))
+	}
+
+	blocks, err := decodeArmor(string(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(blocks) == 0 || blocks[0].Type != "OPENSSH PRIVATE KEY" {
+		return nil, nil, fmt.Errorf("'%s' is not an OpenSSH private key", file)
+	}
+	raw, ok := bytes.CutPrefix(blocks[0].Data, []byte("openssh-key-v1\x00"))
+	if !ok {
+		return nil, nil, fmt.Errorf("'%s' is not an OpenSSH private key", file)
+	}
+
+	// 1. 头部: ciphername、kdfname、kdfoptions、密钥个数、公钥
+	r := &sshReader{data: raw}
+	cipher, kdf := r.string(), r.string()
+	r.bytes()
+	if n := r.uint32(); r.err == nil && n != 1 {
+		return nil, nil, fmt.Errorf("'%s' contains %d keys", file, n)
+	}
+	pub, err := parseSSHPublicKey(r.bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	if cipher != "none" || kdf != "none" {
+		return nil, nil, fmt.Errorf("signing key '%s' is protected by a passphrase, which is not supported", file)
+	}
+
+	// 2. 私钥部分: 两个相同的校验数、密钥类型和各类型的私钥字段
+	r = &sshReader{data: r.bytes()}
+	if r.uint32() != r.uint32() {
+		return nil, nil, fmt.Errorf("'%s' is corrupt", file)
+	}
+	var signer crypto.Signer
+	switch typ := r.string(); typ {
+	case "ssh-ed25519":
+		r.bytes()
+		priv := r.bytes()
+		if r.err == nil && len(priv) != ed25519.PrivateKeySize {
+			return nil, nil, fmt.Errorf("'%s' is corrupt", file)
+		}
+		signer = ed25519.PrivateKey(priv)
+	case "ssh-rsa":
+		n, e, d, _, p, q := r.mpint(), r.mpint(), r.mpint(), r.mpint(), r.mpint(), r.mpint()
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: n, E: int(e.Int64())},
+			D:         d,
+			Primes:    []*big.Int{p, q},
+		}
+		key.Precompute()
+		signer = key
+	case "ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521":
+		r.string()
+		r.bytes()
+		d := r.mpint()
+		signer = &ecdsa.PrivateKey{PublicKey: *pub.Key.(*ecdsa.PublicKey), D: d}
+	default:
+		return nil, nil, fmt.Errorf("unsupported ssh key type %s", typ)
+	}
+	if r.err != nil {
+		return nil, nil, fmt.Errorf("'%s' is corrupt: %v", file, r.err)
+	}
+	return signer, pub, nil
+}
+
+// sshSignedData 构建实际被签名的数据：魔数、命名空间、保留字段、哈希算法和消息的哈希
+func sshSignedData(namespace, hashAlg string, payload []byte) ([]byte, error) {
+	var digest []byte
+	switch hashAlg {
+	case "sha512":
+		sum := sha512.Sum512(payload)
+		digest = sum[:]
+	case "sha256":
+		sum := sha256.Sum256(payload)
+		digest = sum[:]
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm %s", hashAlg)
+	}
+	var w sshWriter
+	w.WriteString(sshMagic)
+	w.string([]byte(namespace))
+	w.string(nil)
+	w.string([]byte(hashAlg))
+	w.string(digest)
+	return w.Bytes(), nil
+}
+
+// signSSH 用 OpenSSH 私钥对 payload 签名，返回 armor 格式的 SSH 签名
+func signSSH(keyFile string, payload []byte) (string, error) {
+	signer, pub, err := readSSHPrivateKey(keyFile)
+	if err != nil {
+		return "", err
+	}
+	data, err := sshSignedData(sshNamespace, sshHashAlg, payload)
+	if err != nil {
+		return "", err
+	}
+
+	// 1. 按密钥类型签名，签名格式为 string(算法名) string(签名数据)
+	var sig sshWriter
+	switch key := signer.(type) {
+	case ed25519.PrivateKey:
+		sig.string([]byte("ssh-ed25519"))
+		sig.string(ed25519.Sign(key, data))
+	case *rsa.PrivateKey:
+		sum := sha512.Sum512(data)
+		s, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA512, sum[:])
+		if err != nil {
+			return "", err
+		}
+		sig.string([]byte("rsa-sha2-512"))
+		sig.string(s)
+	case *ecdsa.PrivateKey:
+		h := sshCurveHash(key.Curve).New()
+		h.Write(data)
+		rr, ss, err := ecdsa.Sign(rand.Reader, key, h.Sum(nil))
+		if err != nil {
+			return "", err
+		}
+		var rs sshWriter
+		rs.mpint(rr)
+		rs.mpint(ss)
+		sig.string([]byte(pub.Type))
+		sig.string(rs.Bytes())
+	}
+
+	// 2. 组装 SSHSIG 结构
+	var w sshWriter
+	w.WriteString(sshMagic)
+	w.uint32(1)
+	w.string(pub.Blob)
+	w.string([]byte(sshNamespace))
+	w.string(nil)
+	w.string([]byte(sshHashAlg))
+	w.string(sig.Bytes())
+	return encodeArmor("SSH SIGNATURE", w.Bytes(), 70), nil
+}
+
+// verifySSH 验证 SSH 签名，并在 allowed signers 文件中查找签名公钥对应的 principal
+func verifySSH(allowedSigners string, payload []byte, armored string) (*Result, error) {
+	res := &Result{Format: FormatSSH}
+	bad := func(reason string) (*Result, error) {
+		res.Output = "Could not verify signature.\nSignature verification failed: " + reason + "\n"
+		return res, nil
+	}
+
+	// 1. 解析 SSHSIG 结构
+	blocks, err := decodeArmor(armored)
+	if err != nil || len(blocks) == 0 || blocks[0].Type != "SSH SIGNATURE" {
+		return bad("invalid format")
+	}
+	raw, ok := bytes.CutPrefix(blocks[0].Data, []byte(sshMagic))
+	if !ok {
+		return bad("invalid format")
+	}
+	r := &sshReader{data: raw}
+	version := r.uint32()
+	pubBlob := r.bytes()
+	namespace := r.string()
+	r.bytes()
+	hashAlg := r.string()
+	sigBlob := r.bytes()
+	if r.err != nil || version != 1 {
+		return bad("invalid format")
+	}
+	pub, err := parseSSHPublicKey(pubBlob)
+	if err != nil {
+		return bad(err.Error())
+	}
+	res.Key = pub.Fingerprint()
+	if namespace != sshNamespace {
+		return bad("namespace mismatch")
+	}
+
+	// 2. 用签名中携带的公钥验证签名
+	data, err := sshSignedData(namespace, hashAlg, payload)
+	if err != nil {
+		return bad(err.Error())
+	}
+	if !verifySSHSignature(pub, data, sigBlob) {
+		return bad("incorrect signature")
+	}
+
+	// 3. 签名正确，再确认公钥是否在 allowed signers 中
+	if allowedSigners == "" {
+		return nil, fmt.Errorf("gpg.ssh.allowedSignersFile needs to be configured and exist for ssh signature verification")
+	}
+	principal, err := findPrincipal(allowedSigners, pub)
+	if err != nil {
+		return nil, err
+	}
+	if principal == "" {
+		res.Output = fmt.Sprintf("Good \"%s\" signature with %s key %s\nNo principal matched.\n", sshNamespace, pub.TypeName(), res.Key)
+		return res, nil
+	}
+	res.Good = true
+	res.Signer = principal
+	res.Output = fmt.Sprintf("Good \"%s\" signature for %s with %s key %s\n", sshNamespace, principal, pub.TypeName(), res.Key)
+	return res, nil
+}
+
+// verifySSHSignature 检查 sigBlob 是否是 pub 对 data 的签名
+func verifySSHSignature(pub *sshPublicKey, data, sigBlob []byte) bool {
+	r := &sshReader{data: sigBlob}
+	alg, sig := r.string(), r.bytes()
+	if r.err != nil {
+		return false
+	}
+	switch key := pub.Key.(type) {
+	case ed25519.PublicKey:
+		return alg == "ssh-ed25519" && ed25519.Verify(key, data, sig)
+	case *rsa.PublicKey:
+		h := crypto.SHA512
+		switch alg {
+		case "rsa-sha2-256":
+			h = crypto.SHA256
+		case "rsa-sha2-512":
+		default:
+			return false
+		}
+		hh := h.New()
+		hh.Write(data)
+		return rsa.VerifyPKCS1v15(key, h, hh.Sum(nil), sig) == nil
+	case *ecdsa.PublicKey:
+		rs := &sshReader{data: sig}
+		rr, ss := rs.mpint(), rs.mpint()
+		if alg != pub.Type || rs.err != nil {
+			return false
+		}
+		hh := sshCurveHash(key.Curve).New()
+		hh.Write(data)
+		return ecdsa.Verify(key, hh.Sum(nil), rr, ss)
+	}
+	return false
+}
+
+// findPrincipal 在 allowed signers 文件中查找公钥 pub 对应的 principal
+// 每行的格式为 "<principals> [<options>] <keytype> <base64-key> [<comment>]"，
+// 支持 namespaces="..." 选项，不支持证书（cert-authority）；匹配多行时返回第一行的 principals
+func findPrincipal(file string, pub *sshPublicKey) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("gpg.ssh.allowedSignersFile needs to be configured and exist for ssh signature verification")
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := splitUnquoted(line, func(c rune) bool { return c == ' ' || c == '\t' || c == '\r' })
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		principals, rest := fields[0], fields[1:]
+
+		// 1. 密钥类型之前的字段是选项
+		var options string
+		if !isSSHKeyType(rest[0]) {
+			options, rest = rest[0], rest[1:]
+		}
+		if len(rest) < 2 || !isSSHKeyType(rest[0]) {
+			continue
+		}
+		if !allowsNamespace(options, sshNamespace) {
+			continue
+		}
+
+		// 2. 比较公钥
+		blob, err := base64.StdEncoding.DecodeString(rest[1])
+		if err == nil && bytes.Equal(blob, pub.Blob) {
+			return principals, nil
+		}
+	}
+	return "", nil
+}
+
+// splitUnquoted 按分隔符拆分字符串，双引号中的分隔符不拆分，忽略空字段
+func splitUnquoted(s string, isSep func(rune) bool) []string {
+	var fields []string
+	var cur strings.Builder
+	quoted := false
+	for _, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+			cur.WriteRune(c)
+		case isSep(c) && !quoted:
+			if cur.Len() > 0 {
+				fields = append(fields, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(c)
+		}
+	}
+	if cur.Len() > 0 {
+		fields = append(fields, cur.String())
+	}
+	return fields
+}
+
+// isSSHKeyType 判断字段是否是支持的公钥类型
+func isSSHKeyType(s string) bool {
+	return s == "ssh-ed25519" || s == "ssh-rsa" || strings.HasPrefix(s, "ecdsa-sha2-")
+}
+
+// allowsNamespace 检查选项中的 namespaces="a,b" 是否允许 namespace，没有该选项时允许所有命名空间
+func allowsNamespace(options, namespace string) bool {
+	for _, opt := range splitUnquoted(options, func(c rune) bool { return c == ',' }) {
+		value, ok := strings.CutPrefix(opt, "namespaces=")
+		if !ok {
+			continue
+		}
+		for _, pattern := range strings.Split(strings.Trim(value, `"`), ",") {
+			if wildmatch.Match(pattern, namespace, 0) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,274 @@
+package grep
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/blob"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/matcher"
+	"geegit/beginner/day6-create-commit/merge"
+	"geegit/beginner/day6-create-commit/tree"
+	"geegit/beginner/day6-create-commit/wildmatch"
+)
+
+// 与 git grep 一致，搜索的是普通文件：符号链接和子模块不搜索。文件按路径顺序交给多个 goroutine 并行搜索，
+// 结果仍然按路径顺序返回。二进制文件（前 8000 字节中有 NUL，或者有 binary / -diff 属性）
+// 有匹配时只报告 "Binary file <path> matches"，-I 时直接跳过
+
+// Options 控制 New
+type Options struct {
+	Patterns   []string // 多个模式之间是"或"的关系（-e）
+	Fixed      bool     // 模式是固定字符串（-F）
+	Extended   bool     // 模式是扩展正则（-E），缺省是基本正则
+	IgnoreCase bool     // 忽略大小写（-i）
+	WordRegexp bool     // 只匹配完整的词（-w）
+	Invert     bool     // 选择不匹配的行（-v）
+	SkipBinary bool     // 跳过二进制文件（-I）
+	Paths      []string // 只搜索这些路径（相对于仓库根目录），可以是目录或通配符；为空时搜索全部
+	Threads    int      // 并行搜索的 goroutine 数量，0 表示 CPU 数量
+}
+
+// Line 是文件中被选中的一行
+type Line struct {
+	Number int    // 行号，从 1 开始
+	Text   string // 行的内容，不包括换行符
+}
+
+// Result 是一个有被选中行的文件
+type Result struct {
+	Path   string // 相对于被搜索的 tree 的根目录（或工作区根目录）
+	Binary bool   // 二进制文件，不应输出 Lines 的内容
+	Lines  []Line
+}
+
+// Grep 是编译好的搜索条件
+type Grep struct {
+	opts Options
+	res  []*regexp.Regexp
+}
+
+// New 编译 opts 中的模式；没有模式时返回错误
+func New(opts Options) (*Grep, error) {
+	if len(opts.Patterns) == 0 {
+		return nil, errors.New("no pattern given")
+	}
+	g := &Grep{opts: opts}
+	for _, p := range opts.Patterns {
+		re, err := compile(p, opts)
+		if err != nil {
+			return nil, err
+		}
+		g.res = append(g.res, re)
+	}
+	return g, nil
+}
+
+// Match 返回内容中被选中的行：与某个模式匹配的行，Invert 时是与所有模式都不匹配的行
+func (g *Grep) Match(data []byte) []Line {
+	var lines []Line
+	for n := 1; len(data) > 0; n++ {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			data = nil
+		}
+		if g.matchLine(string(line)) != g.opts.Invert {
+			lines = append(lines, Line{Number: n, Text: string(line)})
+		}
+	}
+	return lines
+}
+
+func (g *Grep) matchLine(line string) bool {
+	for _, re := range g.res {
+		if !g.opts.WordRegexp {
+			if re.MatchString(line) {
+				return true
+			}
+			continue
+		}
+		// -w：匹配的两端必须不是词的字符，否则从匹配开始的下一个位置重新查找
+		for start := 0; start <= len(line); {
+			loc := re.FindStringIndex(line[start:])
+			if loc == nil {
+				break
+			}
+			s, e := start+loc[0], start+loc[1]
+			if (s == 0 || !isWord(line[s-1])) && (e == len(line) || !isWord(line[e])) {
+				return true
+			}
+			start = s + 1
+		}
+	}
+	return false
+}
+
+func isWord(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// file 是一个待搜索的文件，read 返回它的内容，文件已经不存在时返回 nil
+type file struct {
+	path string
+	read func() ([]byte, error)
+}
+
+// Tree 搜索 tree root 中的文件（git grep <tree-ish>），attrs 用于判断二进制文件，可以为 nil
+func (g *Grep) Tree(gitDir string, root hash.Hash, attrs *matcher.Attributes, fn func(*Result) error) error {
+	var files []file
+	err := tree.Walk(gitDir, root, func(p string, e tree.TreeEntry) error {
+		if !strings.HasPrefix(e.Mode, "100") || !g.selected(p) {
+			return nil
+		}
+		h := e.Hash
+		files = append(files, file{path: p, read: func() ([]byte, error) {
+			b, err := blob.ReadBlob(gitDir, h)
+			if err != nil {
+				return nil, err
+			}
+			return b.Data, nil
+		}})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return g.search(files, attrs, fn)
+}
+
+// Index 搜索索引中的文件（git grep --cached）；有冲突的文件搜索每个阶段的版本
+func (g *Grep) Index(gitDir string, attrs *matcher.Attributes, fn func(*Result) error) error {
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		return err
+	}
+	var files []file
+	for _, e := range idx.Entries {
+		if e.Mode&0170000 != 0100000 || !g.selected(e.Path) {
+			continue
+		}
+		h := e.Hash
+		files = append(files, file{path: e.Path, read: func() ([]byte, error) {
+			b, err := blob.ReadBlob(gitDir, h)
+			if err != nil {
+				return nil, err
+			}
+			return b.Data, nil
+		}})
+	}
+	return g.search(files, attrs, fn)
+}
+
+// Worktree 搜索工作区中被跟踪的文件（git grep），内容从工作区读取，已经删除的文件跳过
+func (g *Grep) Worktree(gitDir, workDir string, attrs *matcher.Attributes, fn func(*Result) error) error {
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		return err
+	}
+	var files []file
+	for i, e := range idx.Entries {
+		if e.Mode&0170000 != 0100000 || !g.selected(e.Path) || i > 0 && idx.Entries[i-1].Path == e.Path {
+			continue
+		}
+		full := filepath.Join(workDir, filepath.FromSlash(e.Path))
+		files = append(files, file{path: e.Path, read: func() ([]byte, error) {
+			info, err := os.Lstat(full)
+			if err != nil || !info.Mode().IsRegular() {
+				return nil, nil
+			}
+			return os.ReadFile(full)
+		}})
+	}
+	return g.search(files, attrs, fn)
+}
+
+// selected 判断路径是否匹配 Paths：相同、在其目录下，或者符合通配符（"*" 可以匹配 "/"）
+func (g *Grep) selected(p string) bool {
+	if len(g.opts.Paths) == 0 {
+		return true
+	}
+	for _, spec := range g.opts.Paths {
+		spec = strings.TrimSuffix(spec, "/")
+		if spec == "" || p == spec || strings.HasPrefix(p, spec+"/") || wildmatch.Match(spec, p, 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// search 并行搜索 files，按顺序把有被选中行的文件交给 fn；fn 返回错误时停止
+func (g *Grep) search(files []file, attrs *matcher.Attributes, fn func(*Result) error) error {
+	n := g.opts.Threads
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	results := make([]*Result, len(files))
+	errs := make([]error, len(files))
+	done := make([]chan struct{}, len(files))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	// 1. 分发任务；调用方提前结束时通过 quit 停止分发，已经开始的 goroutine 会自然退出
+	jobs := make(chan int)
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		defer close(jobs)
+		for i := range files {
+			select {
+			case jobs <- i:
+			case <-quit:
+				return
+			}
+		}
+	}()
+	for w := 0; w < n; w++ {
+		go func() {
+			for i := range jobs {
+				results[i], errs[i] = g.searchFile(files[i], attrs)
+				close(done[i])
+			}
+		}()
+	}
+
+	// 2. 按顺序收集结果
+	for i := range files {
+		<-done[i]
+		if errs[i] != nil {
+			return errs[i]
+		}
+		if results[i] == nil {
+			continue
+		}
+		if err := fn(results[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// searchFile 搜索一个文件，没有被选中的行时返回 nil
+func (g *Grep) searchFile(f file, attrs *matcher.Attributes) (*Result, error) {
+	data, err := f.read()
+	if err != nil || data == nil {
+		return nil, err
+	}
+	binary := merge.IsBinary(data) || attrs != nil && attrs.Lookup(f.path).Binary()
+	if binary && g.opts.SkipBinary {
+		return nil, nil
+	}
+	lines := g.Match(data)
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	return &Result{Path: f.path, Binary: binary, Lines: lines}, nil
+}
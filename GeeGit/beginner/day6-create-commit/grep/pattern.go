@@ -0,0 +1,108 @@
+package grep
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// compile 按 git grep 的规则把模式编译为 Go 的正则表达式
+// 缺省是 POSIX 基本正则（BRE），-E 是扩展正则（ERE），-F 是固定字符串；Go 的 RE2 语法接近 ERE，BRE 需要先转换
+func compile(pattern string, opts Options) (*regexp.Regexp, error) {
+	expr := pattern
+	switch {
+	case opts.Fixed:
+		expr = regexp.QuoteMeta(pattern)
+	case opts.Extended:
+		expr = strings.NewReplacer(`\<`, `\b`, `\>`, `\b`).Replace(pattern)
+	default:
+		var ok bool
+		if expr, ok = fromBasic(pattern); !ok {
+			return nil, fmt.Errorf("command line, '%s': Unmatched [ or [^", pattern)
+		}
+	}
+	if opts.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("command line, '%s': %v", pattern, err)
+	}
+	return re, nil
+}
+
+// fromBasic 把 BRE 转换为 RE2 语法
+//   - \( \) \{ \} \| \+ \? 是元字符（后三个是 GNU 扩展），不带反斜杠时是普通字符
+//   - 出现在开头、"\(" 或 "\|" 之后的 "*" 是普通字符
+//   - 方括号表达式原样保留，其中的反斜杠是普通字符
+//   - \< 和 \> 是词的边界
+//
+// 方括号表达式没有结束时返回 false
+func fromBasic(pattern string) (string, bool) {
+	var b strings.Builder
+	atStart := true // 下一个字符处于可以把 "*" 当作普通字符的位置
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		start := atStart
+		atStart = false
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			switch n := pattern[i]; n {
+			case '(', '|':
+				b.WriteByte(n)
+				atStart = true
+			case ')', '{', '}', '+', '?':
+				b.WriteByte(n)
+			case '<', '>':
+				b.WriteString(`\b`)
+			case 'w', 'W', 's', 'S', 'b', 'B':
+				b.WriteByte('\\')
+				b.WriteByte(n)
+			default:
+				b.WriteString(regexp.QuoteMeta(string(n)))
+			}
+		case c == '[':
+			end := bracketEnd(pattern, i)
+			if end < 0 {
+				return "", false
+			}
+			b.WriteString(strings.ReplaceAll(pattern[i:end+1], `\`, `\\`))
+			i = end
+		case c == '*' && start:
+			b.WriteString(`\*`)
+		case c == '^' && i == 0:
+			b.WriteByte(c)
+			atStart = true
+		case strings.IndexByte("(){}|+?", c) >= 0:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), true
+}
+
+// bracketEnd 返回从 i 开始的方括号表达式的结束位置，没有结束时返回 -1
+// 紧跟在 "[" 或 "[^" 之后的 "]" 是普通字符，"[:alpha:]" 等字符类中的 "]" 不结束表达式
+func bracketEnd(pattern string, i int) int {
+	j := i + 1
+	if j < len(pattern) && pattern[j] == '^' {
+		j++
+	}
+	if j < len(pattern) && pattern[j] == ']' {
+		j++
+	}
+	for ; j < len(pattern); j++ {
+		switch {
+		case pattern[j] == ']':
+			return j
+		case pattern[j] == '[' && j+1 < len(pattern) && strings.IndexByte(":.=", pattern[j+1]) >= 0:
+			if k := strings.Index(pattern[j+2:], string(pattern[j+1])+"]"); k >= 0 {
+				j += k + 3
+			}
+		}
+	}
+	return -1
+}
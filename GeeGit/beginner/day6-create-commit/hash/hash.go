@@ -21,6 +21,7 @@ const (
 	CommitObject ObjectType = iota
 	TreeObject
 	BlobObject
+	TagObject
 )
 
 // String 返回对象类型的字符串表示
@@ -32,11 +33,29 @@ func (t ObjectType) String() string {
 		return "tree"
 	case BlobObject:
 		return "blob"
+	case TagObject:
+		return "tag"
 	default:
 		return "unknown"
 	}
 }
 
+// ParseObjectType 将 "commit"/"tree"/"blob"/"tag" 解析为 ObjectType
+func ParseObjectType(s string) (ObjectType, error) {
+	switch s {
+	case "commit":
+		return CommitObject, nil
+	case "tree":
+		return TreeObject, nil
+	case "blob":
+		return BlobObject, nil
+	case "tag":
+		return TagObject, nil
+	default:
+		return 0, fmt.Errorf("invalid object type: %q", s)
+	}
+}
+
 // ComputeHash 计算给定对象类型和内容的哈希
 // Git 对象的格式: <type> <size>\0<content>
 func ComputeHash(objType ObjectType, content []byte) Hash {
@@ -50,3 +69,22 @@ func ComputeHash(objType ObjectType, content []byte) Hash {
 	data := append(header, content...)
 	return sha1.Sum(data)
 }
+
+// ParseHash 将 40 个字符的十六进制字符串解析为 Hash
+func ParseHash(s string) (Hash, error) {
+	var h Hash
+	if len(s) != 40 {
+		return h, fmt.Errorf("invalid hash length: %q", s)
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return h, fmt.Errorf("invalid hash: %q", s)
+	}
+	copy(h[:], b)
+	return h, nil
+}
+
+// IsZero 判断是否为全零哈希
+func (h Hash) IsZero() bool {
+	return h == Hash{}
+}
@@ -0,0 +1,203 @@
+package hooks
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/storage"
+)
+
+// 提交索引时按 git commit 的顺序运行钩子：geegit.Worktree.Commit 和 revert 都通过 Commit 创建 commit，
+// 因此 prepare-commit-msg、post-commit 等钩子对所有创建 commit 的操作都一样生效
+
+// ErrEmptyCommit 表示要提交的 tree 与 HEAD 的相同，没有需要提交的修改
+var ErrEmptyCommit = errors.New("nothing to commit, working tree clean")
+
+// CommitOptions 控制 Commit
+type CommitOptions struct {
+	AllowEmpty bool              // 没有修改时也创建 commit（git commit --allow-empty）
+	NoVerify   bool              // 不运行 pre-commit 和 commit-msg 钩子（git commit --no-verify）
+	Author     *commit.Signature // 为 nil 时按 commit.DefaultSignature 的规则确定
+	Committer  *commit.Signature // 为 nil 时按 commit.DefaultSignature 的规则确定
+
+	// Reflog 是 reflog 原因中冒号之前的部分，为空时是 "commit"（第一个 commit 是 "commit (initial)"）
+	Reflog string
+	// Source 是传给 prepare-commit-msg 的消息来源，为空时是 "message"；
+	// 说明取自 MERGE_MSG 时（例如有冲突的 revert）是 "merge"
+	Source string
+	// Edit 在 prepare-commit-msg 之后编辑说明文件（例如启动编辑器），nil 表示不编辑；
+	// 编辑过的说明去掉以 "#" 开头的注释行
+	Edit func(msgFile string) error
+	// Hints 见 Options.Hints
+	Hints io.Writer
+}
+
+// Commit 把 s 中的索引提交为 HEAD 的子 commit（git commit），返回新 commit 的哈希
+//  1. 有未解决的冲突时不能提交；运行 pre-commit 钩子，钩子可能修改索引，之后重新读取
+//  2. 把索引写成 tree，与 HEAD 的 tree 相同时返回 ErrEmptyCommit（AllowEmpty 时除外）
+//  3. 消息写入 COMMIT_EDITMSG，依次交给 prepare-commit-msg、Edit 和 commit-msg 修改或检查
+//  4. 写入 commit，HEAD 指向的分支（分离时是 HEAD 本身）前进到新 commit，并记录 reflog；最后运行 post-commit
+//
+// 索引、对象和引用都通过 s 读写；钩子和 COMMIT_EDITMSG 只存在于文件系统上的仓库中，gitDir 为空时跳过。
+// 钩子非零退出时中止提交并返回 *ExitError，post-commit 的退出码被忽略
+func Commit(s storage.Storer, gitDir, workDir, msg string, opts CommitOptions) (hash.Hash, error) {
+	var parents []hash.Hash
+	var headTree hash.Hash
+	head, err := s.Reference("HEAD")
+	if err == nil {
+		c, err := storage.ReadCommit(s, head.Hash)
+		if err != nil {
+			return hash.Hash{}, err
+		}
+		parents, headTree = []hash.Hash{head.Hash}, c.Tree
+	} else if !errors.Is(err, refs.ErrNotFound) {
+		return hash.Hash{}, err
+	}
+
+	// 1. 索引和 pre-commit
+	idx, err := s.Index()
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	for _, e := range idx.Entries {
+		if e.Stage != 0 {
+			return hash.Hash{}, errors.New("committing is not possible because you have unmerged files")
+		}
+	}
+	var env []string
+	if gitDir != "" {
+		env = []string{"GIT_INDEX_FILE=" + index.Path(gitDir)}
+		if opts.Edit == nil {
+			env = append(env, "GIT_EDITOR=:")
+		}
+	}
+	run := func(name string, args ...string) error {
+		return Run(gitDir, workDir, name, Options{Args: args, Env: env, Hints: opts.Hints})
+	}
+	if gitDir != "" && !opts.NoVerify {
+		if err := run("pre-commit"); err != nil {
+			return hash.Hash{}, err
+		}
+		if idx, err = s.Index(); err != nil {
+			return hash.Hash{}, err
+		}
+	}
+
+	// 2. tree
+	root, err := storage.WriteIndexTree(s, idx)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	if !opts.AllowEmpty && (root == headTree || len(parents) == 0 && len(idx.Entries) == 0) {
+		return hash.Hash{}, ErrEmptyCommit
+	}
+
+	// 3. 消息
+	if gitDir != "" {
+		msgFile := filepath.Join(gitDir, "COMMIT_EDITMSG")
+		// 与 git 一样，钩子的参数是相对于钩子运行目录的路径
+		arg := msgFile
+		if rel, err := filepath.Rel(runDir(gitDir, workDir), msgFile); err == nil && !strings.HasPrefix(rel, "..") {
+			arg = rel
+		}
+		if err := os.WriteFile(msgFile, []byte(strings.TrimSuffix(msg, "\n")+"\n"), 0644); err != nil {
+			return hash.Hash{}, err
+		}
+		source := opts.Source
+		if source == "" {
+			source = "message"
+		}
+		if err := run("prepare-commit-msg", arg, source); err != nil {
+			return hash.Hash{}, err
+		}
+		if opts.Edit != nil {
+			if err := opts.Edit(msgFile); err != nil {
+				return hash.Hash{}, err
+			}
+		}
+		if !opts.NoVerify {
+			if err := run("commit-msg", arg); err != nil {
+				return hash.Hash{}, err
+			}
+		}
+		data, err := os.ReadFile(msgFile)
+		if err != nil {
+			return hash.Hash{}, err
+		}
+		msg = string(data)
+		if opts.Edit != nil {
+			msg = stripComments(msg)
+		}
+	}
+	if strings.TrimSpace(msg) == "" {
+		return hash.Hash{}, errors.New("Aborting commit due to empty commit message.")
+	}
+
+	// 4. commit 和分支
+	author, err := signature(gitDir, "author", opts.Author)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	committer, err := signature(gitDir, "committer", opts.Committer)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+	h, err := storage.WriteCommit(s, &commit.Commit{
+		Tree: root, Parents: parents, Author: author, Committer: committer, Message: msg,
+	})
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	action := opts.Reflog
+	if action == "" {
+		action = "commit"
+		if len(parents) == 0 {
+			action = "commit (initial)"
+		}
+	}
+	subject, _, _ := strings.Cut(msg, "\n")
+	var old hash.Hash
+	if len(parents) > 0 {
+		old = parents[0]
+	}
+	if err := s.UpdateReference("HEAD", h, &old, action+": "+subject); err != nil {
+		return hash.Hash{}, err
+	}
+	if gitDir == "" {
+		return h, nil
+	}
+	var exitErr *ExitError
+	if err := run("post-commit"); err != nil && !errors.As(err, &exitErr) {
+		return h, err
+	}
+	return h, nil
+}
+
+// signature 返回调用方给出的签名，为 nil 时读取配置（gitDir 为空时只有全局配置）和环境变量
+func signature(gitDir, role string, sig *commit.Signature) (commit.Signature, error) {
+	if sig != nil {
+		return *sig, nil
+	}
+	return commit.DefaultSignature(gitDir, role)
+}
+
+// stripComments 去掉编辑过的说明中以 "#" 开头的行，以及首尾的空行（git commit --cleanup=strip）
+func stripComments(msg string) string {
+	var lines []string
+	for _, line := range strings.Split(msg, "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, strings.TrimRight(line, " \t"))
+		}
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n") + "\n"
+}
@@ -0,0 +1,139 @@
+package hooks
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/hash"
+)
+
+// 钩子是 hooks 目录（core.hooksPath，缺省为 .git/hooks）中与钩子同名的可执行文件，与 git 一致：
+//
+//	工作目录  工作区根目录，裸仓库为 git 目录
+//	环境变量  继承当前进程的环境，加上调用方给出的变量；钩子中的 git 命令从工作目录找到仓库
+//	输出      钩子的标准输出和标准错误都写到 geegit 的标准错误
+//
+// 钩子不存在时什么也不做；存在但没有可执行权限时忽略，并向调用方给出的 Hints 提示（advice.ignoredHook=false 时不提示）
+//
+// 推送两端的钩子（pre-push、pre-receive、update、post-receive、post-update）见 receive.go，由 push 调用。
+// pre-merge-commit 和 post-merge 只属于 git merge / git pull，geegit 还没有这两个命令，暂时没有调用的地方；
+// revert 和 stash 的三方合并在 git 中同样不运行它们
+
+// ExitError 表示钩子以非零状态退出，调用方据此中止操作
+type ExitError struct {
+	Hook string // 钩子名，例如 "pre-commit"
+	Code int    // 退出码，被信号终止时为 -1
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("hook '%s' exited with status %d", e.Hook, e.Code)
+}
+
+// Options 控制 Run
+type Options struct {
+	Args  []string  // 命令行参数
+	Stdin io.Reader // 标准输入，nil 表示空输入
+	Env   []string  // 额外的环境变量，"KEY=value" 形式
+	// Output 接收钩子的标准输出和标准错误，nil 表示 os.Stderr
+	Output io.Writer
+	// Hints 接收钩子因为不可执行而被忽略时的提示，nil 表示不提示
+	Hints io.Writer
+}
+
+// Dir 返回 hooks 目录：core.hooksPath（相对路径相对于钩子的工作目录）或公共目录中的 hooks
+func Dir(gitDir, workDir string) string {
+	cfg, err := config.Load(gitDir)
+	if err == nil {
+		if p, ok := cfg.Path("core.hooksPath"); ok {
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(runDir(gitDir, workDir), p)
+			}
+			return p
+		}
+	}
+	return gitdir.Path(gitDir, "hooks")
+}
+
+// runDir 返回钩子的工作目录
+func runDir(gitDir, workDir string) string {
+	if workDir != "" {
+		return workDir
+	}
+	return gitDir
+}
+
+// Find 返回可以执行的钩子的路径，钩子不存在或不可执行时返回空字符串
+// 钩子存在但不可执行时，hint 是与 git 相同的提示（以换行结尾），由调用方决定是否输出
+func Find(gitDir, workDir, name string) (path, hint string) {
+	p := filepath.Join(Dir(gitDir, workDir), name)
+	info, err := os.Stat(p)
+	if err != nil || info.IsDir() {
+		return "", ""
+	}
+	if info.Mode()&0111 == 0 {
+		if cfg, err := config.Load(gitDir); err != nil || cfg.Bool("advice.ignoredHook", true) {
+			hint = fmt.Sprintf("hint: The '%s' hook was ignored because it's not set as executable.\n", displayPath(p)) +
+				"hint: You can disable this warning with `git config advice.ignoredHook false`.\n"
+		}
+		return "", hint
+	}
+	return p, ""
+}
+
+// displayPath 与 git 一致，当前目录之下的路径显示为相对路径
+func displayPath(p string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, p); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return p
+}
+
+// Run 运行钩子 name；钩子不存在时返回 nil，非零退出时返回 *ExitError
+func Run(gitDir, workDir, name string, opts Options) error {
+	p, hint := Find(gitDir, workDir, name)
+	if hint != "" && opts.Hints != nil {
+		io.WriteString(opts.Hints, hint)
+	}
+	if p == "" {
+		return nil
+	}
+	out := opts.Output
+	if out == nil {
+		out = os.Stderr
+	}
+
+	cmd := exec.Command(p, opts.Args...)
+	cmd.Dir = runDir(gitDir, workDir)
+	cmd.Env = append(os.Environ(), opts.Env...)
+	cmd.Stdin = opts.Stdin
+	cmd.Stdout = out
+	cmd.Stderr = out
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitError{Hook: name, Code: exitErr.ExitCode()}
+	}
+	if err != nil {
+		return fmt.Errorf("cannot run %s: %v", p, err)
+	}
+	return nil
+}
+
+// PostCheckout 在检出之后运行 post-checkout 钩子，参数为 <旧 HEAD> <新 HEAD> <1 表示切换分支，0 表示检出文件>
+// 钩子无法改变检出的结果，但它的退出码会成为命令的退出码；hints 见 Options.Hints
+func PostCheckout(gitDir, workDir string, old, new hash.Hash, branch bool, hints io.Writer) error {
+	flag := "0"
+	if branch {
+		flag = "1"
+	}
+	return Run(gitDir, workDir, "post-checkout", Options{Args: []string{old.String(), new.String(), flag}, Hints: hints})
+}
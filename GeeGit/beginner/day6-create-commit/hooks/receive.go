@@ -0,0 +1,119 @@
+package hooks
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/hash"
+)
+
+// 推送两端的钩子，由 push 按 git 的顺序调用：推送端在发送对象之前运行 pre-push；
+// 接收端在更新引用之前运行 pre-receive（所有更新）和 update（每个引用一次），之后运行 post-receive 和 post-update。
+// 接收端的钩子总是在 git 目录中运行，与工作区无关；pre-push 与其他客户端钩子一样在工作区根目录运行
+
+// RefUpdate 是一次推送中对一个引用的更新，Old 为零哈希表示创建，New 为零哈希表示删除
+type RefUpdate struct {
+	Name string // 完整的引用名，例如 "refs/heads/main"
+	Old  hash.Hash
+	New  hash.Hash
+}
+
+// ReceiveOptions 控制接收端的钩子
+type ReceiveOptions struct {
+	PushOptions []string  // git push -o 传来的选项，通过 GIT_PUSH_OPTION_COUNT 和 GIT_PUSH_OPTION_<n> 传给钩子
+	Output      io.Writer // 钩子的输出和钩子被忽略时的提示，应该转发给推送端；nil 表示输出到 os.Stderr、不提示
+}
+
+// receiveEnv 让接收端钩子中的 git 命令使用接收端的仓库（钩子在 git 目录中运行），而不是推送端环境中的 GIT_DIR
+var receiveEnv = []string{"GIT_DIR=."}
+
+// env 返回接收端钩子的环境变量，推送端使用了推送选项时加上传递推送选项的变量
+func (o ReceiveOptions) env() []string {
+	if o.PushOptions == nil {
+		return receiveEnv
+	}
+	env := append(append([]string(nil), receiveEnv...), fmt.Sprintf("GIT_PUSH_OPTION_COUNT=%d", len(o.PushOptions)))
+	for i, opt := range o.PushOptions {
+		env = append(env, fmt.Sprintf("GIT_PUSH_OPTION_%d=%s", i, opt))
+	}
+	return env
+}
+
+// updateLines 按 "<old> <new> <ref>" 每行一个更新生成钩子的标准输入
+func updateLines(updates []RefUpdate) string {
+	var b strings.Builder
+	for _, u := range updates {
+		fmt.Fprintf(&b, "%s %s %s\n", u.Old, u.New, u.Name)
+	}
+	return b.String()
+}
+
+// PreReceive 在更新任何引用之前运行 pre-receive，标准输入每行一个更新；
+// 非零退出时返回 *ExitError，接收端应该拒绝全部更新（"pre-receive hook declined"）
+func PreReceive(gitDir string, updates []RefUpdate, opts ReceiveOptions) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	return Run(gitDir, "", "pre-receive", Options{
+		Stdin: strings.NewReader(updateLines(updates)), Env: opts.env(), Output: opts.Output, Hints: opts.Output,
+	})
+}
+
+// Update 在更新引用 u.Name 之前运行 update，参数为 <ref> <old> <new>；
+// 非零退出时返回 *ExitError，接收端应该只拒绝这个引用（"hook declined"）
+func Update(gitDir string, u RefUpdate, opts ReceiveOptions) error {
+	return Run(gitDir, "", "update", Options{
+		Args: []string{u.Name, u.Old.String(), u.New.String()}, Env: receiveEnv, Output: opts.Output, Hints: opts.Output,
+	})
+}
+
+// PostReceive 在引用更新之后运行 post-receive（标准输入与 pre-receive 相同）和 post-update（参数为引用名）
+// updates 只应该包含实际完成的更新；两个钩子的退出码都被忽略
+func PostReceive(gitDir string, updates []RefUpdate, opts ReceiveOptions) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	var exitErr *ExitError
+	err := Run(gitDir, "", "post-receive", Options{
+		Stdin: strings.NewReader(updateLines(updates)), Env: opts.env(), Output: opts.Output, Hints: opts.Output,
+	})
+	if err != nil && !errors.As(err, &exitErr) {
+		return err
+	}
+	names := make([]string, len(updates))
+	for i, u := range updates {
+		names[i] = u.Name
+	}
+	err = Run(gitDir, "", "post-update", Options{Args: names, Env: receiveEnv, Output: opts.Output, Hints: opts.Output})
+	if err != nil && !errors.As(err, &exitErr) {
+		return err
+	}
+	return nil
+}
+
+// PushUpdate 是推送端准备发送的一个更新
+type PushUpdate struct {
+	LocalRef  string    // 本地引用的完整名称，源不是引用时是命令行中的写法；删除时为空
+	Local     hash.Hash // 删除时为零哈希
+	RemoteRef string
+	Remote    hash.Hash // 远程引用当前的值，远程没有时为零哈希
+}
+
+// PrePush 在发送对象之前运行 pre-push 钩子，参数为远程名和地址（直接使用地址时两者相同），
+// 标准输入每行一个更新 "<local ref> <local sha1> <remote ref> <remote sha1>"；
+// 非零退出时返回 *ExitError，推送端不应该推送任何引用
+func PrePush(gitDir, workDir, remoteName, url string, updates []PushUpdate, hints io.Writer) error {
+	var b strings.Builder
+	for _, u := range updates {
+		local := u.LocalRef
+		if local == "" {
+			local = "(delete)"
+		}
+		fmt.Fprintf(&b, "%s %s %s %s\n", local, u.Local, u.RemoteRef, u.Remote)
+	}
+	return Run(gitDir, workDir, "pre-push", Options{
+		Args: []string{remoteName, url}, Stdin: strings.NewReader(b.String()), Hints: hints,
+	})
+}
@@ -0,0 +1,390 @@
+package index
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/lockfile"
+)
+
+// 条目 flags 字段中的标志位
+const (
+	flagAssumeValid = 0x8000
+	flagExtended    = 0x4000
+	flagStageMask   = 0x3000
+	flagStageShift  = 12
+	flagNameMask    = 0x0fff
+
+	// version 3 起的扩展 flags
+	extSkipWorktree = 0x4000
+	extIntentToAdd  = 0x2000
+)
+
+// Entry 表示索引（暂存区）中的一个条目
+// stat 信息用于快速判断工作区文件是否被修改过，不需要每次都重新计算哈希
+type Entry struct {
+	CTime time.Time
+	MTime time.Time
+	Dev   uint32
+	Ino   uint32
+	Mode  uint32 // 0100644、0100755、0120000 或 0160000
+	UID   uint32
+	GID   uint32
+	Size  uint32
+	Hash  hash.Hash
+
+	Stage        int  // 0 表示正常条目，1-3 表示合并冲突中的 base/ours/theirs
+	AssumeValid  bool // 假定工作区文件没有变化
+	SkipWorktree bool // 稀疏检出：工作区中不存在该文件
+	IntentToAdd  bool // git add -N
+
+	Path string // 相对于工作区根目录的路径，使用 "/" 分隔
+}
+
+// ModeString 返回 tree 对象中使用的模式字符串，例如 "100644"
+func (e *Entry) ModeString() string {
+	return fmt.Sprintf("%o", e.Mode)
+}
+
+// ParseMode 把 tree 对象中的模式字符串转换为索引条目的模式，是 ModeString 的逆操作
+func ParseMode(s string) uint32 {
+	m, _ := strconv.ParseUint(s, 8, 32)
+	return uint32(m)
+}
+
+// Index 表示 .git/index 文件
+// 格式:
+//
+//	"DIRC" version(4) count(4)
+//	entries...（每个条目 62 字节定长部分 + 路径 + NUL，v2/v3 按 8 字节对齐）
+//	extensions...（4 字节签名 + 4 字节长度 + 数据）
+//	SHA-1 校验和(20)
+type Index struct {
+	Version uint32
+	Entries []Entry // 按路径、stage 排序
+
+	// ModTime 是读取时索引文件的修改时间，用于判断"racy"条目
+	ModTime time.Time
+
+	// CacheTree 是 TREE 扩展中记录的、仍然有效的 tree 对象（fsck 把它们当作可达对象的起点）；
+	// 只读，写回索引时与其他扩展一样被丢弃
+	CacheTree []hash.Hash
+}
+
+// Path 返回索引文件的路径
+func Path(gitDir string) string {
+	return filepath.Join(gitDir, "index")
+}
+
+// Read 读取 .git/index；文件不存在时返回空索引
+func Read(gitDir string) (*Index, error) {
+	data, err := os.ReadFile(Path(gitDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Index{Version: 2}, nil
+		}
+		return nil, err
+	}
+	idx, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(Path(gitDir)); err == nil {
+		idx.ModTime = info.ModTime()
+	}
+	return idx, nil
+}
+
+// Parse 解析索引文件内容
+func Parse(data []byte) (*Index, error) {
+	if len(data) < 12+20 || string(data[:4]) != "DIRC" {
+		return nil, fmt.Errorf("index file corrupt: bad signature")
+	}
+	if sha1.Sum(data[:len(data)-20]) != [20]byte(data[len(data)-20:]) {
+		return nil, fmt.Errorf("index file corrupt: bad checksum")
+	}
+
+	idx := &Index{Version: binary.BigEndian.Uint32(data[4:8])}
+	if idx.Version < 2 || idx.Version > 4 {
+		return nil, fmt.Errorf("index file version %d is not supported", idx.Version)
+	}
+	count := int(binary.BigEndian.Uint32(data[8:12]))
+	body := data[:len(data)-20]
+
+	offset := 12
+	prevPath := ""
+	for i := 0; i < count; i++ {
+		if offset+62 > len(body) {
+			return nil, fmt.Errorf("index file corrupt: truncated entry")
+		}
+		start := offset
+		e := Entry{}
+		u32 := func() uint32 {
+			v := binary.BigEndian.Uint32(body[offset:])
+			offset += 4
+			return v
+		}
+
+		// 1. 定长的 stat 信息和哈希
+		ctimeSec, ctimeNsec := u32(), u32()
+		mtimeSec, mtimeNsec := u32(), u32()
+		e.CTime = time.Unix(int64(ctimeSec), int64(ctimeNsec))
+		e.MTime = time.Unix(int64(mtimeSec), int64(mtimeNsec))
+		e.Dev, e.Ino, e.Mode = u32(), u32(), u32()
+		e.UID, e.GID, e.Size = u32(), u32(), u32()
+		copy(e.Hash[:], body[offset:offset+20])
+		offset += 20
+
+		// 2. flags 和扩展 flags
+		flags := binary.BigEndian.Uint16(body[offset:])
+		offset += 2
+		e.AssumeValid = flags&flagAssumeValid != 0
+		e.Stage = int(flags&flagStageMask) >> flagStageShift
+		if flags&flagExtended != 0 {
+			if idx.Version < 3 {
+				return nil, fmt.Errorf("index file corrupt: extended flags in version %d", idx.Version)
+			}
+			ext := binary.BigEndian.Uint16(body[offset:])
+			offset += 2
+			e.SkipWorktree = ext&extSkipWorktree != 0
+			e.IntentToAdd = ext&extIntentToAdd != 0
+		}
+
+		// 3. 路径
+		if idx.Version == 4 {
+			// v4 的路径相对上一个条目做前缀压缩: <要删除的字节数(varint)><剩余部分>\0
+			strip, n := readVarint(body[offset:])
+			offset += n
+			if strip > len(prevPath) {
+				return nil, fmt.Errorf("index file corrupt: bad path compression")
+			}
+			end := bytes.IndexByte(body[offset:], 0)
+			if end < 0 {
+				return nil, fmt.Errorf("index file corrupt: unterminated path")
+			}
+			e.Path = prevPath[:len(prevPath)-strip] + string(body[offset:offset+end])
+			offset += end + 1
+		} else {
+			end := bytes.IndexByte(body[offset:], 0)
+			if end < 0 {
+				return nil, fmt.Errorf("index file corrupt: unterminated path")
+			}
+			e.Path = string(body[offset : offset+end])
+			offset += end + 1
+			// 条目总长度补齐到 8 的倍数（至少一个 NUL）
+			offset = start + (offset-start+7)/8*8
+		}
+
+		prevPath = e.Path
+		idx.Entries = append(idx.Entries, e)
+	}
+
+	// 4. 扩展部分：只读取 TREE 中的 tree 哈希，REUC 等其他缓存直接忽略；写回时会被丢弃，git 会自动重建
+	for offset+8 <= len(body) {
+		sig := string(body[offset : offset+4])
+		size := int(binary.BigEndian.Uint32(body[offset+4:]))
+		offset += 8
+		if size > len(body)-offset {
+			return nil, fmt.Errorf("index file corrupt: truncated extension %q", sig)
+		}
+		if sig == "TREE" {
+			trees, err := parseCacheTree(body[offset : offset+size])
+			if err != nil {
+				return nil, err
+			}
+			idx.CacheTree = trees
+		}
+		offset += size
+	}
+	return idx, nil
+}
+
+// parseCacheTree 解析 TREE 扩展，返回其中有效节点的 tree 哈希
+// 每个节点是 "<路径>\0<条目数> <子树数>\n"，条目数不是 -1（已失效）时后面跟着 20 字节的哈希
+func parseCacheTree(data []byte) ([]hash.Hash, error) {
+	var trees []hash.Hash
+	for len(data) > 0 {
+		nul := bytes.IndexByte(data, 0)
+		if nul < 0 {
+			return nil, fmt.Errorf("index file corrupt: bad cache-tree")
+		}
+		data = data[nul+1:]
+		nl := bytes.IndexByte(data, '\n')
+		if nl < 0 {
+			return nil, fmt.Errorf("index file corrupt: bad cache-tree")
+		}
+		var count, subtrees int
+		if _, err := fmt.Sscanf(string(data[:nl]), "%d %d", &count, &subtrees); err != nil {
+			return nil, fmt.Errorf("index file corrupt: bad cache-tree")
+		}
+		data = data[nl+1:]
+		if count < 0 {
+			continue
+		}
+		if len(data) < 20 {
+			return nil, fmt.Errorf("index file corrupt: bad cache-tree")
+		}
+		var h hash.Hash
+		copy(h[:], data[:20])
+		trees = append(trees, h)
+		data = data[20:]
+	}
+	return trees, nil
+}
+
+// Write 将索引写入 .git/index（通过 index.lock 原子替换）
+func (idx *Index) Write(gitDir string) error {
+	lock, err := lockfile.Acquire(Path(gitDir))
+	if err != nil {
+		return err
+	}
+	if _, err := lock.Write(Encode(idx)); err != nil {
+		lock.Rollback()
+		return err
+	}
+	return lock.Commit()
+}
+
+// Encode 按索引文件的格式编码（排序之后），与 Parse 相对
+func Encode(idx *Index) []byte {
+	idx.Sort()
+
+	// 只要有条目使用扩展 flags，就必须使用 version 3
+	version := uint32(2)
+	for _, e := range idx.Entries {
+		if e.SkipWorktree || e.IntentToAdd {
+			version = 3
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("DIRC")
+	binary.Write(&buf, binary.BigEndian, version)
+	binary.Write(&buf, binary.BigEndian, uint32(len(idx.Entries)))
+
+	for _, e := range idx.Entries {
+		start := buf.Len()
+		for _, v := range []uint32{
+			uint32(e.CTime.Unix()), uint32(e.CTime.Nanosecond()),
+			uint32(e.MTime.Unix()), uint32(e.MTime.Nanosecond()),
+			e.Dev, e.Ino, e.Mode, e.UID, e.GID, e.Size,
+		} {
+			binary.Write(&buf, binary.BigEndian, v)
+		}
+		buf.Write(e.Hash[:])
+
+		flags := uint16(e.Stage<<flagStageShift) & flagStageMask
+		if len(e.Path) < flagNameMask {
+			flags |= uint16(len(e.Path))
+		} else {
+			flags |= flagNameMask
+		}
+		if e.AssumeValid {
+			flags |= flagAssumeValid
+		}
+		var ext uint16
+		if e.SkipWorktree {
+			ext |= extSkipWorktree
+		}
+		if e.IntentToAdd {
+			ext |= extIntentToAdd
+		}
+		if ext != 0 {
+			flags |= flagExtended
+		}
+		binary.Write(&buf, binary.BigEndian, flags)
+		if ext != 0 {
+			binary.Write(&buf, binary.BigEndian, ext)
+		}
+
+		buf.WriteString(e.Path)
+		// 至少一个 NUL，并补齐到 8 字节
+		padding := 8 - (buf.Len()-start)%8
+		buf.Write(make([]byte, padding))
+	}
+
+	sum := sha1.Sum(buf.Bytes())
+	buf.Write(sum[:])
+	return buf.Bytes()
+}
+
+// Sort 按 Git 的规则排序：先按路径（字节序），再按 stage
+func (idx *Index) Sort() {
+	sort.SliceStable(idx.Entries, func(i, j int) bool {
+		a, b := idx.Entries[i], idx.Entries[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Stage < b.Stage
+	})
+}
+
+// Find 查找指定路径的 stage 0 条目
+func (idx *Index) Find(p string) (*Entry, bool) {
+	i := sort.Search(len(idx.Entries), func(i int) bool {
+		return idx.Entries[i].Path >= p
+	})
+	for ; i < len(idx.Entries) && idx.Entries[i].Path == p; i++ {
+		if idx.Entries[i].Stage == 0 {
+			return &idx.Entries[i], true
+		}
+	}
+	return nil, false
+}
+
+// Add 添加或替换一个 stage 0 条目，同时删除该路径上的冲突条目
+func (idx *Index) Add(e Entry) {
+	idx.Remove(e.Path)
+	idx.Entries = append(idx.Entries, e)
+	idx.Sort()
+}
+
+// Remove 删除指定路径的所有条目（包括冲突的各个 stage）
+func (idx *Index) Remove(p string) {
+	kept := idx.Entries[:0]
+	for _, e := range idx.Entries {
+		if e.Path != p {
+			kept = append(kept, e)
+		}
+	}
+	idx.Entries = kept
+}
+
+// RemoveDir 删除某个目录下的所有条目
+func (idx *Index) RemoveDir(dir string) {
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	kept := idx.Entries[:0]
+	for _, e := range idx.Entries {
+		if !strings.HasPrefix(e.Path, prefix) {
+			kept = append(kept, e)
+		}
+	}
+	idx.Entries = kept
+}
+
+// readVarint 读取 v4 路径压缩使用的变长整数（与 ofs-delta 的编码相同）
+func readVarint(data []byte) (int, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	i := 0
+	c := data[i]
+	i++
+	v := int(c & 0x7f)
+	for c&0x80 != 0 && i < len(data) {
+		c = data[i]
+		i++
+		v = ((v + 1) << 7) | int(c&0x7f)
+	}
+	return v, i
+}
@@ -0,0 +1,55 @@
+package index
+
+import (
+	"os"
+
+	"geegit/beginner/day6-create-commit/hash"
+)
+
+// NewEntry 根据工作区文件的 stat 信息创建一个 stage 0 条目
+func NewEntry(p string, info os.FileInfo, h hash.Hash) Entry {
+	e := Entry{
+		Path:  p,
+		Hash:  h,
+		Mode:  modeOf(info),
+		Size:  uint32(info.Size()),
+		MTime: info.ModTime(),
+		CTime: info.ModTime(),
+	}
+	fillStat(&e, info)
+	return e
+}
+
+// StatMatches 判断工作区文件的 stat 信息是否与条目记录的一致
+// 一致时可以认为文件没有被修改，从而跳过重新计算哈希
+func (e *Entry) StatMatches(info os.FileInfo) bool {
+	if e.Mode != modeOf(info) || e.Size != uint32(info.Size()) {
+		return false
+	}
+	if !e.MTime.Equal(info.ModTime()) {
+		return false
+	}
+	other := Entry{CTime: e.CTime, Ino: e.Ino, Dev: e.Dev}
+	fillStat(&other, info)
+	return other.CTime.Equal(e.CTime) && other.Ino == e.Ino && other.Dev == e.Dev
+}
+
+// IsRacy 判断条目是否"racily clean"：文件在索引写入的同一时刻之后被修改，
+// 仅凭 stat 信息无法判断内容是否变化，必须重新计算哈希
+func (idx *Index) IsRacy(e *Entry) bool {
+	return !idx.ModTime.IsZero() && !e.MTime.Before(idx.ModTime)
+}
+
+// modeOf 返回文件在索引中的模式
+func modeOf(info os.FileInfo) uint32 {
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		return 0120000
+	case info.IsDir():
+		return 0160000
+	case info.Mode()&0111 != 0:
+		return 0100755
+	default:
+		return 0100644
+	}
+}
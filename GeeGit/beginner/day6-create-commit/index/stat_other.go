@@ -0,0 +1,8 @@
+//go:build !linux
+
+package index
+
+import "os"
+
+// fillStat 在其他平台上只使用 mtime 和 size 判断文件是否变化
+func fillStat(e *Entry, info os.FileInfo) {}
@@ -0,0 +1,51 @@
+package lockfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Lock 表示一个 <file>.lock 锁文件
+// Git 通过独占创建 .lock 文件来保证同一时刻只有一个进程修改引用、索引等文件：
+// 新内容先写入锁文件，完成后再重命名为目标文件，实现原子替换
+type Lock struct {
+	path string // 被锁定的文件
+	file *os.File
+}
+
+// Acquire 为 path 创建锁文件；锁已被占用时返回错误
+func Acquire(path string) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path+".lock", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("unable to create '%s.lock': File exists", path)
+		}
+		return nil, err
+	}
+	return &Lock{path: path, file: f}, nil
+}
+
+// Write 向锁文件写入新内容
+func (l *Lock) Write(data []byte) (int, error) {
+	return l.file.Write(data)
+}
+
+// Commit 关闭锁文件并将其重命名为目标文件，完成原子替换
+func (l *Lock) Commit() error {
+	if err := l.file.Close(); err != nil {
+		os.Remove(l.file.Name())
+		return err
+	}
+	return os.Rename(l.file.Name(), l.path)
+}
+
+// Rollback 放弃修改，删除锁文件；在 Commit 之后调用不会有任何效果
+func (l *Lock) Rollback() {
+	if l.file.Close() == nil {
+		os.Remove(l.file.Name())
+	}
+}
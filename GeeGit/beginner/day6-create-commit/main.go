@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"os"
-	"time"
 
 	"geegit/beginner/day6-create-commit/blob"
 	"geegit/beginner/day6-create-commit/commit"
@@ -31,19 +30,22 @@ func main() {
 	})
 
 	fmt.Printf("\n✓ Step 3: Create commit\n")
+	// 作者和提交者取自 user.name / user.email 配置（或 GIT_AUTHOR_* 等环境变量）
+	author, err := commit.DefaultSignature(gitDir, "author")
+	if err != nil {
+		fmt.Printf("  Error: %v\n", err)
+		return
+	}
+	committer, err := commit.DefaultSignature(gitDir, "committer")
+	if err != nil {
+		fmt.Printf("  Error: %v\n", err)
+		return
+	}
 	c := &commit.Commit{
-		Tree: treeHash,
-		Author: commit.Signature{
-			Name:  "Test User",
-			Email: "test@example.com",
-			When:  time.Now(),
-		},
-		Committer: commit.Signature{
-			Name:  "Test User",
-			Email: "test@example.com",
-			When:  time.Now(),
-		},
-		Message: "Initial commit\n",
+		Tree:      treeHash,
+		Author:    author,
+		Committer: committer,
+		Message:   "Initial commit\n",
 	}
 
 	commitHash, _ := commit.WriteCommit(gitDir, c)
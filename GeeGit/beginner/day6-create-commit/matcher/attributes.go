@@ -0,0 +1,371 @@
+package matcher
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/gitdir"
+)
+
+// State 表示一个属性对某个路径的取值状态
+type State int
+
+const (
+	Unspecified State = iota // 没有规则涉及该属性，或被 "!attr" 重置
+	Set                      // "attr"
+	Unset                    // "-attr"
+	Valued                   // "attr=value"
+)
+
+// Attribute 是一个属性的取值
+type Attribute struct {
+	Name  string
+	State State
+	Value string // 只在 State 为 Valued 时有效
+}
+
+// String 以 `git check-attr` 的格式输出取值: set、unset、unspecified 或具体的值
+func (a Attribute) String() string {
+	switch a.State {
+	case Set:
+		return "set"
+	case Unset:
+		return "unset"
+	case Valued:
+		return a.Value
+	default:
+		return "unspecified"
+	}
+}
+
+// FileAttributes 是一个路径上所有被指定过的属性
+type FileAttributes map[string]Attribute
+
+// Get 返回属性的取值，未指定时 State 为 Unspecified
+func (f FileAttributes) Get(name string) Attribute {
+	if a, ok := f[name]; ok {
+		return a
+	}
+	return Attribute{Name: name}
+}
+
+// Text 返回 text 属性: set、unset、"auto" 或 unspecified
+func (f FileAttributes) Text() Attribute { return f.Get("text") }
+
+// EOL 返回 eol 属性的值（"lf" 或 "crlf"），未指定时为空字符串
+func (f FileAttributes) EOL() string {
+	if a := f.Get("eol"); a.State == Valued {
+		return a.Value
+	}
+	return ""
+}
+
+// Binary 判断文件是否应按二进制处理：设置了 binary，或者 -diff
+func (f FileAttributes) Binary() bool {
+	return f.Get("binary").State == Set || f.Get("diff").State == Unset
+}
+
+// DiffDriver 返回 diff=<driver> 指定的驱动名称，未指定时为空字符串
+func (f FileAttributes) DiffDriver() string {
+	if a := f.Get("diff"); a.State == Valued {
+		return a.Value
+	}
+	return ""
+}
+
+// MergeDriver 返回 merge=<driver> 指定的驱动名称，未指定时为空字符串
+func (f FileAttributes) MergeDriver() string {
+	if a := f.Get("merge"); a.State == Valued {
+		return a.Value
+	}
+	return ""
+}
+
+// attrRule 是 .gitattributes 中的一行
+type attrRule struct {
+	pattern Pattern
+	attrs   []Attribute
+}
+
+// attrFile 是一个属性文件解析后的内容
+type attrFile struct {
+	rules  []attrRule
+	macros map[string][]Attribute // [attr]name 定义的宏
+	names  []string               // 按出现顺序排列的属性名（包括宏名）
+}
+
+// Attributes 按 git 的规则查询路径的属性
+// 规则来源按优先级从低到高依次为：
+//  1. core.attributesFile（缺省为 $XDG_CONFIG_HOME/git/attributes）
+//  2. 工作区根目录的 .gitattributes
+//  3. 子目录中的 .gitattributes，越深的目录优先级越高
+//  4. .git/info/attributes
+//
+// 同一文件中后出现的规则优先。宏只能在 1、2、4 中定义
+type Attributes struct {
+	workDir  string
+	source   func(name string) ([]byte, error) // 不为 nil 时 .gitattributes 从这里读取，而不是工作区
+	foldCase bool
+	global   *attrFile // 1
+	info     *attrFile // 4
+
+	mu    sync.Mutex
+	dirs  map[string]*attrFile // 目录 -> 该目录下的 .gitattributes
+	order map[string]int       // 属性名第一次出现的顺序，`check-attr -a` 按此顺序输出
+}
+
+// builtinMacros 是 git 内置的宏
+var builtinMacros = map[string][]Attribute{
+	"binary": {{Name: "diff", State: Unset}, {Name: "merge", State: Unset}, {Name: "text", State: Unset}},
+}
+
+// LoadAttributes 读取仓库的属性规则，core.attributesFile 和 core.ignoreCase 从配置中读取
+func LoadAttributes(gitDir, workDir string) (*Attributes, error) {
+	return load(gitDir, workDir, nil)
+}
+
+// LoadAttributesFrom 与 LoadAttributes 相同，但各个目录的 .gitattributes 通过 source 读取，
+// name 是文件相对于根目录的路径（例如 "src/.gitattributes"）；用于从 tree 而不是工作区读取属性（git archive）
+func LoadAttributesFrom(gitDir string, source func(name string) ([]byte, error)) (*Attributes, error) {
+	return load(gitDir, "", source)
+}
+
+func load(gitDir, workDir string, source func(name string) ([]byte, error)) (*Attributes, error) {
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Attributes{
+		workDir:  workDir,
+		source:   source,
+		foldCase: cfg.Bool("core.ignorecase", false),
+		dirs:     make(map[string]*attrFile),
+		order:    make(map[string]int),
+	}
+	a.register("binary")
+	for _, attr := range builtinMacros["binary"] {
+		a.register(attr.Name)
+	}
+
+	// 与 git 相同的读取顺序：全局文件、根目录 .gitattributes、info/attributes，子目录在用到时读取
+	attributesFile, ok := cfg.Path("core.attributesfile")
+	if !ok {
+		attributesFile = config.XDGPath("attributes")
+	}
+	a.global = a.readFile(attributesFile, "", true)
+	a.fileIn("")
+	a.info = a.readFile(gitdir.Path(gitDir, "info/attributes"), "", true)
+	return a, nil
+}
+
+// All 按属性名第一次出现的顺序返回路径 p 上所有被指定的属性
+func (a *Attributes) All(p string) []Attribute {
+	attrs := a.Lookup(p)
+	list := make([]Attribute, 0, len(attrs))
+	for _, attr := range attrs {
+		list = append(list, attr)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return a.order[list[i].Name] < a.order[list[j].Name] })
+	return list
+}
+
+func (a *Attributes) register(name string) {
+	if _, ok := a.order[name]; !ok {
+		a.order[name] = len(a.order)
+	}
+}
+
+// Lookup 返回路径 p（相对于工作区根目录，使用 "/" 分隔）的所有属性
+func (a *Attributes) Lookup(p string) FileAttributes {
+	// 1. 按优先级从低到高收集规则文件
+	files := []*attrFile{a.global}
+	var dirs []string
+	for dir := path.Dir(p); ; dir = path.Dir(dir) {
+		if dir == "." {
+			dir = ""
+		}
+		dirs = append(dirs, dir)
+		if dir == "" {
+			break
+		}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		files = append(files, a.fileIn(dirs[i]))
+	}
+	files = append(files, a.info)
+
+	// 2. 宏定义对之后所有文件生效
+	macros := make(map[string][]Attribute)
+	for name, attrs := range builtinMacros {
+		macros[name] = attrs
+	}
+
+	// 3. 依次应用匹配的规则，后面的覆盖前面的
+	result := make(FileAttributes)
+	for _, f := range files {
+		if f == nil {
+			continue
+		}
+		for name, attrs := range f.macros {
+			macros[name] = attrs
+		}
+		for _, rule := range f.rules {
+			if !rule.pattern.Match(p, false, a.foldCase) {
+				continue
+			}
+			for _, attr := range rule.attrs {
+				apply(result, attr, macros, 0)
+			}
+		}
+	}
+	return result
+}
+
+// apply 设置一个属性；属性是宏并且被设置时，同时展开宏中的属性
+func apply(result FileAttributes, attr Attribute, macros map[string][]Attribute, depth int) {
+	if attr.State == Unspecified {
+		delete(result, attr.Name)
+	} else {
+		result[attr.Name] = attr
+	}
+	if expansion, ok := macros[attr.Name]; ok && attr.State == Set && depth < 8 {
+		for _, sub := range expansion {
+			apply(result, sub, macros, depth+1)
+		}
+	}
+}
+
+// fileIn 返回目录 dir 下的 .gitattributes，读取结果会被缓存
+func (a *Attributes) fileIn(dir string) *attrFile {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if f, ok := a.dirs[dir]; ok {
+		return f
+	}
+	var f *attrFile
+	if a.source != nil {
+		if data, err := a.source(path.Join(dir, ".gitattributes")); err == nil {
+			f = a.parse(data, dir, dir == "")
+		}
+	} else {
+		f = a.readFile(filepath.Join(a.workDir, filepath.FromSlash(dir), ".gitattributes"), dir, dir == "")
+	}
+	a.dirs[dir] = f
+	return f
+}
+
+// readFile 读取并解析一个属性文件，同时登记其中出现的属性名；调用者需要持有锁或处于初始化阶段
+func (a *Attributes) readFile(file, base string, allowMacros bool) *attrFile {
+	if file == "" {
+		return nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	return a.parse(data, base, allowMacros)
+}
+
+// parse 解析属性文件的内容并登记其中出现的属性名
+func (a *Attributes) parse(data []byte, base string, allowMacros bool) *attrFile {
+	f := parseAttrFile(data, base, allowMacros)
+	for _, name := range f.names {
+		a.register(name)
+	}
+	return f
+}
+
+// parseAttrFile 解析 .gitattributes 文件
+// 每行的格式为 "<pattern> <attr>..."，attr 可以是 attr、-attr、!attr 或 attr=value
+// "[attr]<name> <attr>..." 定义宏；规则中的 "!" 取反模式不被允许，会被忽略
+func parseAttrFile(data []byte, base string, allowMacros bool) *attrFile {
+	f := &attrFile{macros: make(map[string][]Attribute)}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		// 1. 模式可以用双引号包围，以支持包含空白的路径
+		var pattern, rest string
+		if line[0] == '"' {
+			end := closingQuote(line)
+			if end < 0 {
+				continue
+			}
+			unquoted, err := strconv.Unquote(line[:end+1])
+			if err != nil {
+				continue
+			}
+			pattern, rest = unquoted, line[end+1:]
+		} else {
+			pattern, rest, _ = strings.Cut(strings.ReplaceAll(line, "\t", " "), " ")
+		}
+		attrs := parseAttrs(rest)
+
+		// 2. 宏定义
+		if name, ok := strings.CutPrefix(pattern, "[attr]"); ok {
+			if allowMacros {
+				f.macros[name] = attrs
+				f.names = append(f.names, name)
+				for _, attr := range attrs {
+					f.names = append(f.names, attr.Name)
+				}
+			}
+			continue
+		}
+
+		// 3. 普通规则；属性文件中不允许取反模式
+		p, ok := ParsePattern(pattern, base)
+		if !ok || p.Negate {
+			continue
+		}
+		f.rules = append(f.rules, attrRule{pattern: p, attrs: attrs})
+		for _, attr := range attrs {
+			f.names = append(f.names, attr.Name)
+		}
+	}
+	return f
+}
+
+func parseAttrs(s string) []Attribute {
+	var attrs []Attribute
+	for _, field := range strings.Fields(s) {
+		switch {
+		case strings.HasPrefix(field, "-"):
+			attrs = append(attrs, Attribute{Name: field[1:], State: Unset})
+		case strings.HasPrefix(field, "!"):
+			attrs = append(attrs, Attribute{Name: field[1:], State: Unspecified})
+		case strings.Contains(field, "="):
+			name, value, _ := strings.Cut(field, "=")
+			attrs = append(attrs, Attribute{Name: name, State: Valued, Value: value})
+		default:
+			attrs = append(attrs, Attribute{Name: field, State: Set})
+		}
+	}
+	return attrs
+}
+
+// closingQuote 返回与开头的双引号配对的结束引号位置
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
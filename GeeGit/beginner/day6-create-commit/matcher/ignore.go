@@ -0,0 +1,119 @@
+package matcher
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/gitdir"
+)
+
+// Ignore 按 git 的规则判断工作区中的路径是否被忽略
+// 规则来源按优先级从低到高依次为：
+//  1. core.excludesFile（缺省为 $XDG_CONFIG_HOME/git/ignore）
+//  2. .git/info/exclude
+//  3. 工作区根目录的 .gitignore
+//  4. 子目录中的 .gitignore，越深的目录优先级越高
+//
+// 同一来源中后出现的规则优先。子目录的 .gitignore 在第一次用到时才读取
+type Ignore struct {
+	workDir  string
+	foldCase bool
+	global   []Pattern // 1 和 2，Base 为空
+
+	mu   sync.Mutex
+	dirs map[string][]Pattern // 目录 -> 该目录下 .gitignore 中的规则
+}
+
+// LoadIgnore 读取仓库的忽略规则，core.excludesFile 和 core.ignoreCase 从配置中读取
+func LoadIgnore(gitDir, workDir string) (*Ignore, error) {
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return nil, err
+	}
+
+	m := NewIgnore(workDir, cfg.Bool("core.ignorecase", false))
+	excludesFile, ok := cfg.Path("core.excludesfile")
+	if !ok {
+		excludesFile = config.XDGPath("ignore")
+	}
+	for _, file := range []string{excludesFile, gitdir.Path(gitDir, "info/exclude")} {
+		if file == "" {
+			continue
+		}
+		if data, err := os.ReadFile(file); err == nil {
+			m.global = append(m.global, ParsePatterns(data, "")...)
+		}
+	}
+	return m, nil
+}
+
+// NewIgnore 创建只包含工作区 .gitignore 规则的匹配器
+func NewIgnore(workDir string, foldCase bool) *Ignore {
+	return &Ignore{workDir: workDir, foldCase: foldCase, dirs: make(map[string][]Pattern)}
+}
+
+// Match 判断路径 p（相对于工作区根目录，使用 "/" 分隔）是否被忽略
+// 父目录被忽略时，其中的文件一定被忽略，不能再被 "!" 规则重新包含
+func (m *Ignore) Match(p string, isDir bool) bool {
+	if p == "" {
+		return false
+	}
+	for i := 0; i < len(p); i++ {
+		if p[i] == '/' && m.matchOne(p[:i], true) {
+			return true
+		}
+	}
+	return m.matchOne(p, isDir)
+}
+
+// matchOne 只根据 p 本身判断，不检查父目录
+func (m *Ignore) matchOne(p string, isDir bool) bool {
+	// 1. 从最深的 .gitignore 开始，每个来源中从最后一条规则开始查找
+	dir := path.Dir(p)
+	for {
+		if dir == "." {
+			dir = ""
+		}
+		if matched, decided := m.matchList(m.patternsIn(dir), p, isDir); decided {
+			return matched
+		}
+		if dir == "" {
+			break
+		}
+		dir = path.Dir(dir)
+	}
+
+	// 2. info/exclude 和 core.excludesFile
+	matched, _ := m.matchList(m.global, p, isDir)
+	return matched
+}
+
+// matchList 返回最后一条匹配的规则的结果，第二个返回值表示是否有规则匹配
+func (m *Ignore) matchList(patterns []Pattern, p string, isDir bool) (bool, bool) {
+	for i := len(patterns) - 1; i >= 0; i-- {
+		if patterns[i].Match(p, isDir, m.foldCase) {
+			return !patterns[i].Negate, true
+		}
+	}
+	return false, false
+}
+
+// patternsIn 返回目录 dir 下 .gitignore 中的规则，读取结果会被缓存
+func (m *Ignore) patternsIn(dir string) []Pattern {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if patterns, ok := m.dirs[dir]; ok {
+		return patterns
+	}
+	data, err := os.ReadFile(filepath.Join(m.workDir, filepath.FromSlash(dir), ".gitignore"))
+	var patterns []Pattern
+	if err == nil {
+		patterns = ParsePatterns(data, dir)
+	}
+	m.dirs[dir] = patterns
+	return patterns
+}
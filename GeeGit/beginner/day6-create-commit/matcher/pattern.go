@@ -0,0 +1,101 @@
+package matcher
+
+import (
+	"path"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/wildmatch"
+)
+
+// Pattern 是 .gitignore 或 .gitattributes 中的一条路径规则
+type Pattern struct {
+	Text     string // 去掉 "!"、开头和结尾 "/" 之后的模式
+	Base     string // 规则文件所在的目录（相对于工作区根目录，根目录为空字符串）
+	Negate   bool   // 以 "!" 开头，重新包含之前被排除的路径
+	DirOnly  bool   // 以 "/" 结尾，只匹配目录
+	Basename bool   // 模式中没有 "/"，匹配任意层级的文件名
+}
+
+// ParsePattern 解析 .gitignore 中的一行，空行和注释返回 false
+//  1. 去掉行尾未转义的空格
+//  2. 开头的 "!" 表示取反，"\!" 和 "\#" 表示字面字符
+//  3. 结尾的 "/" 表示只匹配目录
+//  4. 除结尾外不含 "/" 的模式匹配任意层级的文件名，否则相对于 base 匹配
+func ParsePattern(line, base string) (Pattern, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	line = trimTrailingSpace(line)
+	if line == "" || line[0] == '#' {
+		return Pattern{}, false
+	}
+
+	p := Pattern{Base: base}
+	if line[0] == '!' {
+		p.Negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.DirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return Pattern{}, false
+	}
+	if !strings.Contains(line, "/") {
+		p.Basename = true
+	}
+	p.Text = strings.TrimPrefix(line, "/")
+	return p, true
+}
+
+// ParsePatterns 解析一个 .gitignore 文件的全部规则
+func ParsePatterns(data []byte, base string) []Pattern {
+	var patterns []Pattern
+	for _, line := range strings.Split(string(data), "\n") {
+		if p, ok := ParsePattern(line, base); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// Match 判断相对于工作区根目录的路径 p 是否符合规则（不考虑 Negate）
+func (pat Pattern) Match(p string, isDir bool, foldCase bool) bool {
+	if pat.DirOnly && !isDir {
+		return false
+	}
+
+	// 规则只对所在目录之下的路径生效
+	rel := p
+	if pat.Base != "" {
+		if !strings.HasPrefix(p, pat.Base+"/") {
+			return false
+		}
+		rel = p[len(pat.Base)+1:]
+	}
+
+	flags := wildmatch.PathName
+	if foldCase {
+		flags |= wildmatch.CaseFold
+	}
+	if pat.Basename {
+		return wildmatch.Match(pat.Text, path.Base(rel), flags)
+	}
+	return wildmatch.Match(pat.Text, rel, flags)
+}
+
+// trimTrailingSpace 去掉行尾的空格，但保留用 "\" 转义的空格
+func trimTrailingSpace(s string) string {
+	end := len(s)
+	for end > 0 && s[end-1] == ' ' {
+		// 统计空格前连续的 "\"，奇数个表示这个空格被转义
+		n := 0
+		for i := end - 2; i >= 0 && s[i] == '\\'; i-- {
+			n++
+		}
+		if n%2 == 1 {
+			break
+		}
+		end--
+	}
+	return s[:end]
+}
@@ -0,0 +1,340 @@
+package merge
+
+import (
+	"bytes"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/diff"
+)
+
+// markerSize 是冲突标记的长度，与 git 默认的 conflict-marker-size 相同
+const markerSize = 7
+
+// 合并区域的来源
+const (
+	regionConflict = iota // 两边都修改了，且修改不同
+	regionOurs            // 只有 ours 修改
+	regionTheirs          // 只有 theirs 修改
+	regionBoth            // 两边做了相同的修改
+)
+
+// region 是合并结果中的一段变化
+// 位置用 ours 和 theirs 中的行号表示：ours[o1:o2] 对应 theirs[t1:t2]
+type region struct {
+	kind   int
+	o1, o2 int
+	t1, t2 int
+}
+
+// hunk 表示一处修改：base[b1:b2] 被替换为 side[s1:s2]
+type hunk struct {
+	b1, b2 int
+	s1, s2 int
+}
+
+// Labels 是冲突标记中两边的名字，例如 "<<<<<<< HEAD" 和 ">>>>>>> feature"
+type Labels struct {
+	Ours   string
+	Theirs string
+}
+
+// Files 对文件内容做三方合并（与 `git merge-file` 的默认行为相同）
+//  1. 分别计算 base 到 ours、base 到 theirs 的修改
+//  2. 只有一边修改的地方直接采用该修改，两边重叠或相邻的修改成为冲突
+//  3. 冲突内部两边相同的行移到冲突之外，间隔不超过 3 行的冲突再合并为一个
+//
+// 返回合并结果，有冲突时结果中带有冲突标记，conflicts 为冲突的个数
+func Files(base, ours, theirs []byte, labels Labels) (result []byte, conflicts int) {
+	baseLines := diff.SplitLines(base)
+	oursLines := diff.SplitLines(ours)
+	theirsLines := diff.SplitLines(theirs)
+
+	regions := mergeRegions(hunks(baseLines, oursLines), hunks(baseLines, theirsLines))
+	regions = refineConflicts(regions, oursLines, theirsLines)
+	regions = simplifyConflicts(regions)
+
+	var b bytes.Buffer
+	pos := 0
+	for _, r := range regions {
+		writeLines(&b, oursLines[pos:r.o1])
+		switch r.kind {
+		case regionOurs, regionBoth:
+			writeLines(&b, oursLines[r.o1:r.o2])
+		case regionTheirs:
+			writeLines(&b, theirsLines[r.t1:r.t2])
+		case regionConflict:
+			conflicts++
+			b.WriteString(marker('<', labels.Ours))
+			writeSide(&b, oursLines[r.o1:r.o2])
+			b.WriteString(strings.Repeat("=", markerSize) + "\n")
+			writeSide(&b, theirsLines[r.t1:r.t2])
+			b.WriteString(marker('>', labels.Theirs))
+		}
+		pos = r.o2
+	}
+	writeLines(&b, oursLines[pos:])
+	return b.Bytes(), conflicts
+}
+
+// IsBinary 判断内容是否是二进制（前 8000 字节中有 NUL），二进制文件不做逐行合并
+func IsBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// hunks 把 base 到 side 的逐行差异合并为连续的修改块
+// 与 xdiff 相同，修改块会先在相同的行之间滑动到统一的位置（见 compact），
+// 否则同样的修改在两边可能对齐到不同的行上，产生多余的冲突
+func hunks(base, side []string) []hunk {
+	// 1. 标记两边被修改的行
+	baseChanged := make([]bool, len(base))
+	sideChanged := make([]bool, len(side))
+	for _, e := range diff.Lines(base, side) {
+		switch e.Op {
+		case diff.Delete:
+			baseChanged[e.OldLine] = true
+		case diff.Insert:
+			sideChanged[e.NewLine] = true
+		}
+	}
+	compact(base, baseChanged, sideChanged)
+	compact(side, sideChanged, baseChanged)
+
+	// 2. 两边同时向前扫描，连续的修改行组成一个修改块
+	var result []hunk
+	for bi, si := 0, 0; bi < len(base) || si < len(side); {
+		if (bi >= len(base) || !baseChanged[bi]) && (si >= len(side) || !sideChanged[si]) {
+			bi++
+			si++
+			continue
+		}
+		h := hunk{b1: bi, s1: si}
+		for bi < len(base) && baseChanged[bi] {
+			bi++
+		}
+		for si < len(side) && sideChanged[si] {
+			si++
+		}
+		h.b2, h.s2 = bi, si
+		result = append(result, h)
+	}
+	return result
+}
+
+// group 是一侧连续的修改行 [start, end)，可以为空
+// 两侧的 group 一一对应：第 i 个 group 之间的未修改行在两侧是相同的
+type group struct {
+	start, end int
+}
+
+// compact 在不改变差异含义的前提下移动 lines 中的修改块（xdiff 的 xdl_change_compact）
+//  1. 每个修改块先尽量向上、再尽量向下滑动，途中相邻的修改块会合并
+//  2. 如果滑动范围内某个位置与另一侧的修改对齐，则停在最后一个对齐的位置，否则停在最下方
+func compact(lines []string, changed, other []bool) {
+	n := len(lines)
+	isChanged := func(c []bool, i int) bool { return i >= 0 && i < len(c) && c[i] }
+	extendDown := func(c []bool, g *group) {
+		for isChanged(c, g.end) {
+			g.end++
+		}
+	}
+	next := func(c []bool, g *group) bool {
+		if g.end >= len(c) {
+			return false
+		}
+		g.start = g.end + 1
+		g.end = g.start
+		extendDown(c, g)
+		return true
+	}
+	previous := func(c []bool, g *group) {
+		g.end = g.start - 1
+		g.start = g.end
+		for isChanged(c, g.start-1) {
+			g.start--
+		}
+	}
+	slideUp := func(g *group) bool {
+		if g.start == 0 || lines[g.start-1] != lines[g.end-1] {
+			return false
+		}
+		g.start--
+		g.end--
+		changed[g.start], changed[g.end] = true, false
+		for isChanged(changed, g.start-1) {
+			g.start--
+		}
+		return true
+	}
+	slideDown := func(g *group) bool {
+		if g.end >= n || lines[g.start] != lines[g.end] {
+			return false
+		}
+		changed[g.start], changed[g.end] = false, true
+		g.start++
+		g.end++
+		extendDown(changed, g)
+		return true
+	}
+
+	g, og := group{}, group{}
+	extendDown(changed, &g)
+	extendDown(other, &og)
+	for {
+		if g.end != g.start {
+			var earliestEnd, endMatchingOther int
+			for {
+				size := g.end - g.start
+				endMatchingOther = -1
+				for slideUp(&g) {
+					previous(other, &og)
+				}
+				earliestEnd = g.end
+				if og.end > og.start {
+					endMatchingOther = g.end
+				}
+				for slideDown(&g) {
+					next(other, &og)
+					if og.end > og.start {
+						endMatchingOther = g.end
+					}
+				}
+				if size == g.end-g.start {
+					break
+				}
+			}
+			if g.end != earliestEnd && endMatchingOther != -1 {
+				for og.end == og.start {
+					slideUp(&g)
+					previous(other, &og)
+				}
+			}
+		}
+		if !next(changed, &g) {
+			return
+		}
+		next(other, &og)
+	}
+}
+
+// mergeRegions 按 base 中的位置合并两边的修改块
+// 两边的修改块重叠或首尾相接时归为同一个区域，两边都有修改块的区域是冲突
+func mergeRegions(ours, theirs []hunk) []region {
+	var regions []region
+	// delta 是此前的修改块造成的行号偏移：side 行号 = base 行号 + delta
+	oursDelta, theirsDelta := 0, 0
+	i, j := 0, 0
+	for i < len(ours) || j < len(theirs) {
+		// 1. 从 base 中位置最靠前的修改块开始，不断吸收与区域相交的修改块
+		var b1, b2 int
+		if j >= len(theirs) || (i < len(ours) && ours[i].b1 <= theirs[j].b1) {
+			b1, b2 = ours[i].b1, ours[i].b2
+		} else {
+			b1, b2 = theirs[j].b1, theirs[j].b2
+		}
+		oi, tj := i, j
+		for {
+			if i < len(ours) && ours[i].b1 <= b2 && ours[i].b2 >= b1 {
+				if ours[i].b2 > b2 {
+					b2 = ours[i].b2
+				}
+				i++
+				continue
+			}
+			if j < len(theirs) && theirs[j].b1 <= b2 && theirs[j].b2 >= b1 {
+				if theirs[j].b2 > b2 {
+					b2 = theirs[j].b2
+				}
+				j++
+				continue
+			}
+			break
+		}
+
+		// 2. 把 base 中的区域换算到两边的行号
+		o1, t1 := b1+oursDelta, b1+theirsDelta
+		for _, h := range ours[oi:i] {
+			oursDelta += (h.s2 - h.s1) - (h.b2 - h.b1)
+		}
+		for _, h := range theirs[tj:j] {
+			theirsDelta += (h.s2 - h.s1) - (h.b2 - h.b1)
+		}
+		r := region{o1: o1, o2: b2 + oursDelta, t1: t1, t2: b2 + theirsDelta}
+		switch {
+		case i == oi:
+			r.kind = regionTheirs
+		case j == tj:
+			r.kind = regionOurs
+		default:
+			r.kind = regionConflict
+		}
+		regions = append(regions, r)
+	}
+	return regions
+}
+
+// refineConflicts 对每个冲突比较两边的内容，只把真正不同的部分保留为冲突
+// 两边内容完全相同时不再是冲突；有一边为空的冲突无法细化
+func refineConflicts(regions []region, ours, theirs []string) []region {
+	var result []region
+	for _, r := range regions {
+		if r.kind != regionConflict || r.o1 == r.o2 || r.t1 == r.t2 {
+			result = append(result, r)
+			continue
+		}
+		parts := hunks(ours[r.o1:r.o2], theirs[r.t1:r.t2])
+		if len(parts) == 0 {
+			r.kind = regionBoth
+			result = append(result, r)
+			continue
+		}
+		for _, h := range parts {
+			result = append(result, region{
+				kind: regionConflict,
+				o1:   r.o1 + h.b1, o2: r.o1 + h.b2,
+				t1: r.t1 + h.s1, t2: r.t1 + h.s2,
+			})
+		}
+	}
+	return result
+}
+
+// simplifyConflicts 把相邻且间隔不超过 3 行的两个冲突合并为一个，避免过于零碎的冲突
+func simplifyConflicts(regions []region) []region {
+	var result []region
+	for _, r := range regions {
+		if n := len(result); n > 0 {
+			last := &result[n-1]
+			if last.kind == regionConflict && r.kind == regionConflict && r.o1-last.o2 <= 3 {
+				last.o2, last.t2 = r.o2, r.t2
+				continue
+			}
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+func writeLines(b *bytes.Buffer, lines []string) {
+	for _, l := range lines {
+		b.WriteString(l)
+	}
+}
+
+// writeSide 写出冲突中一边的内容，最后一行没有换行符时补上，保证冲突标记独占一行
+func writeSide(b *bytes.Buffer, lines []string) {
+	writeLines(b, lines)
+	if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+		b.WriteByte('\n')
+	}
+}
+
+func marker(c byte, label string) string {
+	m := strings.Repeat(string(c), markerSize)
+	if label != "" {
+		m += " " + label
+	}
+	return m + "\n"
+}
@@ -0,0 +1,382 @@
+package merge
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"geegit/beginner/day6-create-commit/blob"
+	"geegit/beginner/day6-create-commit/diff"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// Entry 是合并结果中的一个路径
+type Entry struct {
+	Path string
+
+	// Base/Ours/Theirs 是该路径在三个 tree 中的条目，nil 表示不存在
+	Base, Ours, Theirs *tree.TreeEntry
+
+	// Result 是合并后的条目，nil 表示删除
+	// 冲突时是应写入工作区的版本（内容冲突时带有冲突标记）
+	Result *tree.TreeEntry
+
+	// Conflict 为 true 时，索引中应记录 stage 1-3（Base/Ours/Theirs）而不是 Result
+	Conflict bool
+
+	// OursPath/TheirsPath 是 Ours/Theirs 在各自 tree 中的路径，为空表示与 Path 相同；
+	// 文件在一边被重命名时，另一边的条目取自原路径。OursPath 不为空时 ours 中 Path 本身不存在
+	OursPath, TheirsPath string
+}
+
+// Result 是 tree 三方合并的结果
+type Result struct {
+	Entries  []Entry  // 相对 ours 有变化或冲突的路径，按路径排序
+	Messages []string // 合并过程的说明，例如 "Auto-merging a" 和 "CONFLICT (...): ..."
+}
+
+// Clean 判断合并是否没有冲突
+func (r *Result) Clean() bool {
+	for _, e := range r.Entries {
+		if e.Conflict {
+			return false
+		}
+	}
+	return true
+}
+
+// Trees 以 base 为共同祖先合并 ours 和 theirs 两个 tree（与 merge-recursive 对单个祖先的处理相同）
+//  1. 只有一边修改的路径采用修改的一边，两边修改相同时直接采用
+//  2. 两边都修改的普通文件逐行合并，失败时为内容冲突（一边新增时为 add/add 冲突）
+//  3. 一边修改、一边删除时为 modify/delete 冲突，工作区保留修改的一边
+//  4. 一边重命名的文件在新路径上与另一边的修改合并（见 mergeRenamed），原路径被删除
+//
+// 零哈希表示空 tree；合并产生的 blob 会写入对象库
+func Trees(gitDir string, base, ours, theirs hash.Hash, labels Labels) (*Result, error) {
+	baseFiles, err := flatten(gitDir, base)
+	if err != nil {
+		return nil, err
+	}
+	oursFiles, err := flatten(gitDir, ours)
+	if err != nil {
+		return nil, err
+	}
+	theirsFiles, err := flatten(gitDir, theirs)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]bool)
+	for _, files := range []map[string]*tree.TreeEntry{baseFiles, oursFiles, theirsFiles} {
+		for p := range files {
+			paths[p] = true
+		}
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+	renames, err := detectRenames(gitDir, baseFiles, oursFiles, theirsFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{}
+	for _, p := range sorted {
+		if r, ok := renames[p]; ok {
+			if err := mergeRenamed(gitDir, res, r, baseFiles, oursFiles, theirsFiles, labels); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		e := Entry{Path: p, Base: baseFiles[p], Ours: oursFiles[p], Theirs: theirsFiles[p]}
+
+		// 1. 至少有一边没有修改
+		switch {
+		case sameEntry(e.Ours, e.Theirs), sameEntry(e.Base, e.Theirs):
+			continue
+		case sameEntry(e.Base, e.Ours):
+			e.Result = e.Theirs
+			res.Entries = append(res.Entries, e)
+			continue
+		}
+
+		// 2. 一边删除、一边修改
+		if e.Ours == nil || e.Theirs == nil {
+			deleted, modified := labels.Theirs, labels.Ours
+			e.Result = e.Ours
+			if e.Ours == nil {
+				deleted, modified = labels.Ours, labels.Theirs
+				e.Result = e.Theirs
+			}
+			e.Conflict = true
+			res.Messages = append(res.Messages, fmt.Sprintf(
+				"CONFLICT (modify/delete): %s deleted in %s and modified in %s.  Version %s of %s left in tree.",
+				p, deleted, modified, modified, p))
+			res.Entries = append(res.Entries, e)
+			continue
+		}
+
+		// 3. 两边都修改
+		merged, msgs, err := mergeEntry(gitDir, &e, labels)
+		if err != nil {
+			return nil, err
+		}
+		res.Messages = append(res.Messages, msgs...)
+		if !e.Conflict && sameEntry(merged, e.Ours) {
+			continue
+		}
+		e.Result = merged
+		res.Entries = append(res.Entries, e)
+	}
+	return res, nil
+}
+
+// rename 是合并中的一个重命名：base 中的 from 在 ours 和/或 theirs 中改名
+// oursTo/theirsTo 是两边的新路径，没有改名的一边为空
+type rename struct {
+	from, oursTo, theirsTo string
+	done                   bool // 已经合并过
+}
+
+// detectRenames 分别检测 base 到 ours、base 到 theirs 的重命名，返回 路径 -> 重命名，
+// 原路径和新路径都在其中，合并时由 mergeRenamed 一起处理。新路径在另一边已经被占用的重命名不处理，
+// 按普通的删除和新增合并（结果是 modify/delete 或 add/add 冲突）
+func detectRenames(gitDir string, base, ours, theirs map[string]*tree.TreeEntry) (map[string]*rename, error) {
+	read := func(h hash.Hash) ([]byte, error) {
+		b, err := blob.ReadBlob(gitDir, h)
+		if err != nil {
+			return nil, err
+		}
+		return b.Data, nil
+	}
+	sides := [2]map[string]string{}
+	for i, side := range []map[string]*tree.TreeEntry{ours, theirs} {
+		deleted := make(map[string]hash.Hash)
+		added := make(map[string]hash.Hash)
+		for p, e := range base {
+			if side[p] == nil && isRegular(e.Mode) {
+				deleted[p] = e.Hash
+			}
+		}
+		for p, e := range side {
+			if base[p] == nil && isRegular(e.Mode) {
+				added[p] = e.Hash
+			}
+		}
+		list, err := diff.DetectRenames(deleted, added, read)
+		if err != nil {
+			return nil, err
+		}
+		sides[i] = make(map[string]string)
+		for _, r := range list {
+			sides[i][r.From] = r.To
+		}
+	}
+
+	renames := make(map[string]*rename)
+	add := func(r *rename) {
+		for _, p := range []string{r.from, r.oursTo, r.theirsTo} {
+			if p != "" {
+				renames[p] = r
+			}
+		}
+	}
+	for from, to := range sides[0] {
+		switch theirsTo, ok := sides[1][from]; {
+		case ok:
+			add(&rename{from: from, oursTo: to, theirsTo: theirsTo})
+		case theirs[to] == nil:
+			add(&rename{from: from, oursTo: to})
+		}
+	}
+	for from, to := range sides[1] {
+		if _, ok := sides[0][from]; !ok && ours[to] == nil {
+			add(&rename{from: from, theirsTo: to})
+		}
+	}
+	// 两边都改名时，一边的新路径可能又是另一边其他文件的新路径，这种复杂情况按普通路径处理
+	for p, r := range renames {
+		for _, q := range []string{r.from, r.oursTo, r.theirsTo} {
+			if q != "" && renames[q] != r {
+				delete(renames, p)
+			}
+		}
+	}
+	return renames, nil
+}
+
+// mergeRenamed 合并一个重命名，在它的第一个路径（按排序）上调用一次，结果追加到 res
+//  1. 只有一边改名：在新路径上合并 base、改名一边的新文件和另一边的原文件，原路径删除；
+//     另一边删除了原文件时为 rename/delete 冲突
+//  2. 两边改成同一个名字：在新路径上正常合并
+//  3. 两边改成不同的名字：rename/rename 冲突，两个新路径各自保留一边的版本
+func mergeRenamed(gitDir string, res *Result, r *rename, baseFiles, oursFiles, theirsFiles map[string]*tree.TreeEntry, labels Labels) error {
+	if r.done {
+		return nil
+	}
+	r.done = true
+	base := baseFiles[r.from]
+
+	// 3. rename/rename
+	if r.oursTo != "" && r.theirsTo != "" && r.oursTo != r.theirsTo {
+		res.Messages = append(res.Messages, fmt.Sprintf("CONFLICT (rename/rename): %s renamed to %s in %s and to %s in %s.",
+			r.from, r.oursTo, labels.Ours, r.theirsTo, labels.Theirs))
+		res.Entries = append(res.Entries,
+			Entry{Path: r.oursTo, Base: base, Ours: oursFiles[r.oursTo], Result: oursFiles[r.oursTo], Conflict: true},
+			Entry{Path: r.theirsTo, Base: base, Theirs: theirsFiles[r.theirsTo], Result: theirsFiles[r.theirsTo], Conflict: true})
+		sortEntries(res)
+		return nil
+	}
+
+	e := Entry{Base: base}
+	switch {
+	case r.oursTo != "" && r.theirsTo != "":
+		e.Path, e.Ours, e.Theirs = r.oursTo, oursFiles[r.oursTo], theirsFiles[r.theirsTo]
+	case r.oursTo != "":
+		e.Path, e.Ours, e.Theirs, e.TheirsPath = r.oursTo, oursFiles[r.oursTo], theirsFiles[r.from], r.from
+	default:
+		e.Path, e.Ours, e.Theirs, e.OursPath = r.theirsTo, oursFiles[r.from], theirsFiles[r.theirsTo], r.from
+		// 原路径从 ours 中删除
+		if e.Ours != nil {
+			res.Entries = append(res.Entries, Entry{Path: r.from, Base: base, Ours: e.Ours})
+		}
+	}
+
+	// 1. 另一边删除了原文件
+	if e.Ours == nil || e.Theirs == nil {
+		renamedIn, deletedIn, to := labels.Theirs, labels.Ours, r.theirsTo
+		e.Result = e.Theirs
+		if e.Theirs == nil {
+			renamedIn, deletedIn, to = labels.Ours, labels.Theirs, r.oursTo
+			e.Result = e.Ours
+		}
+		e.Conflict = true
+		res.Messages = append(res.Messages, fmt.Sprintf("CONFLICT (rename/delete): %s renamed to %s in %s, but deleted in %s.",
+			r.from, to, renamedIn, deletedIn))
+		res.Entries = append(res.Entries, e)
+		sortEntries(res)
+		return nil
+	}
+
+	// 2. 合并内容，冲突标记中注明两边的路径
+	switch {
+	case sameEntry(e.Base, e.Theirs), sameEntry(e.Ours, e.Theirs):
+		e.Result = e.Ours
+	case sameEntry(e.Base, e.Ours):
+		e.Result = e.Theirs
+	default:
+		fileLabels := labels
+		if e.OursPath != "" || e.TheirsPath != "" {
+			fileLabels.Ours += ":" + firstNonEmpty(e.OursPath, e.Path)
+			fileLabels.Theirs += ":" + firstNonEmpty(e.TheirsPath, e.Path)
+		}
+		merged, msgs, err := mergeEntry(gitDir, &e, fileLabels)
+		if err != nil {
+			return err
+		}
+		res.Messages = append(res.Messages, msgs...)
+		e.Result = merged
+	}
+	e.Result = &tree.TreeEntry{Mode: e.Result.Mode, Name: path.Base(e.Path), Hash: e.Result.Hash}
+	if e.OursPath != "" || e.Conflict || !sameEntry(e.Result, e.Ours) {
+		res.Entries = append(res.Entries, e)
+	}
+	sortEntries(res)
+	return nil
+}
+
+func sortEntries(res *Result) {
+	sort.SliceStable(res.Entries, func(i, j int) bool { return res.Entries[i].Path < res.Entries[j].Path })
+}
+
+func firstNonEmpty(a, b string) string {
+	if a != "" {
+		return a
+	}
+	return b
+}
+
+// mergeEntry 合并两边都修改过的条目，冲突时设置 e.Conflict
+func mergeEntry(gitDir string, e *Entry, labels Labels) (*tree.TreeEntry, []string, error) {
+	kind := "content"
+	if e.Base == nil {
+		kind = "add/add"
+	}
+	conflict := fmt.Sprintf("CONFLICT (%s): Merge conflict in %s", kind, e.Path)
+
+	// 1. 模式：只有一边改变了模式时采用改变的一边
+	mode := e.Ours.Mode
+	if e.Base != nil && e.Ours.Mode == e.Base.Mode {
+		mode = e.Theirs.Mode
+	}
+
+	// 2. 符号链接、子模块以及类型不同的条目无法逐行合并，保留 ours
+	if !isRegular(e.Ours.Mode) || !isRegular(e.Theirs.Mode) {
+		e.Conflict = true
+		return e.Ours, []string{conflict}, nil
+	}
+	if e.Ours.Hash == e.Theirs.Hash {
+		return &tree.TreeEntry{Mode: mode, Name: e.Ours.Name, Hash: e.Ours.Hash}, nil, nil
+	}
+
+	// 3. 逐行合并内容
+	msgs := []string{"Auto-merging " + e.Path}
+	var baseData []byte
+	if e.Base != nil && isRegular(e.Base.Mode) {
+		b, err := blob.ReadBlob(gitDir, e.Base.Hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		baseData = b.Data
+	}
+	o, err := blob.ReadBlob(gitDir, e.Ours.Hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, err := blob.ReadBlob(gitDir, e.Theirs.Hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if IsBinary(baseData) || IsBinary(o.Data) || IsBinary(t.Data) {
+		e.Conflict = true
+		msgs = append(msgs, fmt.Sprintf("warning: Cannot merge binary files: %s (%s vs. %s)", e.Path, labels.Ours, labels.Theirs), conflict)
+		return e.Ours, msgs, nil
+	}
+	data, conflicts := Files(baseData, o.Data, t.Data, labels)
+	h, err := blob.WriteBlob(gitDir, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if conflicts > 0 {
+		e.Conflict = true
+		msgs = append(msgs, conflict)
+	}
+	return &tree.TreeEntry{Mode: mode, Name: e.Ours.Name, Hash: h}, msgs, nil
+}
+
+func sameEntry(a, b *tree.TreeEntry) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Mode == b.Mode && a.Hash == b.Hash
+}
+
+func isRegular(mode string) bool {
+	return mode == "100644" || mode == "100755"
+}
+
+// flatten 把 tree 展开为 路径 -> 条目；零哈希表示空 tree
+func flatten(gitDir string, root hash.Hash) (map[string]*tree.TreeEntry, error) {
+	files := make(map[string]*tree.TreeEntry)
+	if root.IsZero() {
+		return files, nil
+	}
+	err := tree.Walk(gitDir, root, func(p string, e tree.TreeEntry) error {
+		files[p] = &e
+		return nil
+	})
+	return files, err
+}
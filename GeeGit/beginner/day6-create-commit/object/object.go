@@ -0,0 +1,172 @@
+package object
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/alternates"
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/pack"
+)
+
+// ErrNotFound 表示对象既不在松散对象中，也不在任何 packfile 中
+var ErrNotFound = errors.New("object not found")
+
+// Object 表示一个任意类型的 Git 对象（已解压、去掉头部）
+type Object struct {
+	Hash    hash.Hash
+	Type    hash.ObjectType
+	Content []byte
+}
+
+// Read 读取任意类型的对象：先查找松散对象，再查找 objects/pack 下的 packfile（有 multi-pack-index 时先查询它），
+// 仓库自己没有时继续查找 objects/info/alternates 中的对象目录；都没有时如果仓库是部分克隆，再从 promisor 远程获取
+func Read(gitDir string, h hash.Hash) (*Object, error) {
+	for _, dir := range alternates.ObjectDirs(gitDir) {
+		obj, err := readLooseFile(looseFile(dir, h), h)
+		if err == nil {
+			return obj, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	p, offset, ok, err := pack.Locate(gitDir, h)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		objType, content, err := p.ReadAt(offset)
+		if err != nil {
+			return nil, err
+		}
+		return &Object{Hash: h, Type: objType, Content: content}, nil
+	}
+
+	return readPromised(gitDir, h)
+}
+
+// Exists 判断对象是否存在（松散对象或 packfile，包括 alternates 中的）
+func Exists(gitDir string, h hash.Hash) bool {
+	for _, dir := range alternates.ObjectDirs(gitDir) {
+		if _, err := os.Stat(looseFile(dir, h)); err == nil {
+			return true
+		}
+	}
+	_, _, ok, err := pack.Locate(gitDir, h)
+	return err == nil && ok
+}
+
+// LoosePath 返回松散对象的文件路径: .git/objects/xx/xxxx...
+func LoosePath(gitDir string, h hash.Hash) string {
+	return looseFile(filepath.Join(gitdir.CommonDir(gitDir), "objects"), h)
+}
+
+// looseFile 返回对象目录 objectDir 中松散对象的文件路径
+func looseFile(objectDir string, h hash.Hash) string {
+	hashStr := h.String()
+	return filepath.Join(objectDir, hashStr[:2], hashStr[2:])
+}
+
+// ReadLoose 从 .git/objects 读取一个松散对象（不查找 alternates）
+func ReadLoose(gitDir string, h hash.Hash) (*Object, error) {
+	return readLooseFile(LoosePath(gitDir, h), h)
+}
+
+// readLooseFile 读取并解析松散对象文件
+func readLooseFile(path string, h hash.Hash) (*Object, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, h.String())
+		}
+		return nil, err
+	}
+
+	objType, content, err := ParseLoose(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{Hash: h, Type: objType, Content: content}, nil
+}
+
+// ParseLoose 解压并解析松散对象文件的原始内容
+// 格式: zlib(<type> <size>\0<content>)
+func ParseLoose(raw []byte) (hash.ObjectType, []byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return 0, nil, fmt.Errorf("zlib decompress failed: %v", err)
+	}
+	defer zr.Close()
+
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return 0, nil, fmt.Errorf("read failed: %v", err)
+	}
+
+	nullIdx := bytes.IndexByte(data, 0)
+	if nullIdx < 0 {
+		return 0, nil, fmt.Errorf("invalid object format")
+	}
+
+	header := string(data[:nullIdx])
+	content := data[nullIdx+1:]
+
+	parts := strings.Split(header, " ")
+	if len(parts) != 2 {
+		return 0, nil, fmt.Errorf("invalid object header: %s", header)
+	}
+
+	objType, err := hash.ParseObjectType(parts[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	size, err := strconv.Atoi(parts[1])
+	if err != nil || size != len(content) {
+		return 0, nil, fmt.Errorf("object size mismatch: header says %s, got %d", parts[1], len(content))
+	}
+
+	return objType, content, nil
+}
+
+// ListLoose 列出 .git/objects 下所有松散对象的哈希（不包括 alternates 中的）
+func ListLoose(gitDir string) ([]hash.Hash, error) {
+	return ListLooseIn(filepath.Join(gitdir.CommonDir(gitDir), "objects"))
+}
+
+// ListLooseIn 列出对象目录 objectsDir 下所有松散对象的哈希
+func ListLooseIn(objectsDir string) ([]hash.Hash, error) {
+	dirs, err := os.ReadDir(objectsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []hash.Hash
+	for _, dir := range dirs {
+		// 只处理两位十六进制的子目录，跳过 pack/ 和 info/
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(objectsDir, dir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			h, err := hash.ParseHash(dir.Name() + f.Name())
+			if err != nil {
+				continue
+			}
+			hashes = append(hashes, h)
+		}
+	}
+	return hashes, nil
+}
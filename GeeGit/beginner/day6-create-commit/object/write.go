@@ -0,0 +1,56 @@
+package object
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"geegit/beginner/day6-create-commit/hash"
+)
+
+// Write 将任意类型的对象写入 .git/objects 目录
+// 对象已经存在时直接返回其哈希
+func Write(gitDir string, objType hash.ObjectType, content []byte) (hash.Hash, error) {
+	// 1. 计算哈希
+	h := hash.ComputeHash(objType, content)
+
+	objPath := LoosePath(gitDir, h)
+	if _, err := os.Stat(objPath); err == nil {
+		return h, nil
+	}
+
+	data, err := EncodeLoose(objType, content)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+
+	// 2. 写入文件系统
+	if err := os.MkdirAll(filepath.Dir(objPath), 0755); err != nil {
+		return hash.Hash{}, fmt.Errorf("failed to create object directory: %v", err)
+	}
+	if err := os.WriteFile(objPath, data, 0444); err != nil {
+		return hash.Hash{}, fmt.Errorf("failed to write object file: %v", err)
+	}
+
+	return h, nil
+}
+
+// EncodeLoose 生成松散对象文件的内容，是 ParseLoose 的逆过程
+// 格式: zlib(<type> <size>\0<content>)
+func EncodeLoose(objType hash.ObjectType, content []byte) ([]byte, error) {
+	header := []byte(fmt.Sprintf("%s %d", objType.String(), len(content)))
+	header = append(header, 0) // null byte
+	data := append(header, content...)
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("zlib compress failed: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("zlib close failed: %v", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,75 @@
+package pack
+
+import "fmt"
+
+// ApplyDelta 将 delta 指令应用到基础对象上，得到目标对象
+// 格式: <源大小><目标大小> 然后是一系列指令:
+//   - 最高位为 1：从源对象复制（低 4 位表示 offset 的字节，bit4-6 表示 size 的字节）
+//   - 最高位为 0：插入紧随其后的 n 个字节
+func ApplyDelta(base, delta []byte) ([]byte, error) {
+	srcSize, pos := readDeltaSize(delta, 0)
+	if srcSize != len(base) {
+		return nil, fmt.Errorf("delta base size mismatch: %d != %d", srcSize, len(base))
+	}
+	dstSize, pos := readDeltaSize(delta, pos)
+
+	out := make([]byte, 0, dstSize)
+	for pos < len(delta) {
+		op := delta[pos]
+		pos++
+
+		switch {
+		case op&0x80 != 0:
+			// 复制指令
+			var offset, size int
+			for i := 0; i < 4; i++ {
+				if op&(1<<i) != 0 {
+					offset |= int(delta[pos]) << (8 * i)
+					pos++
+				}
+			}
+			for i := 0; i < 3; i++ {
+				if op&(0x10<<i) != 0 {
+					size |= int(delta[pos]) << (8 * i)
+					pos++
+				}
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > len(base) {
+				return nil, fmt.Errorf("delta copy out of range")
+			}
+			out = append(out, base[offset:offset+size]...)
+		case op != 0:
+			// 插入指令
+			if pos+int(op) > len(delta) {
+				return nil, fmt.Errorf("delta insert out of range")
+			}
+			out = append(out, delta[pos:pos+int(op)]...)
+			pos += int(op)
+		default:
+			return nil, fmt.Errorf("invalid delta opcode 0")
+		}
+	}
+
+	if len(out) != dstSize {
+		return nil, fmt.Errorf("delta result size mismatch: %d != %d", len(out), dstSize)
+	}
+	return out, nil
+}
+
+// readDeltaSize 读取 delta 头部的变长整数（小端，每字节 7 位）
+func readDeltaSize(data []byte, pos int) (int, int) {
+	size, shift := 0, uint(0)
+	for pos < len(data) {
+		c := data[pos]
+		pos++
+		size |= int(c&0x7f) << shift
+		shift += 7
+		if c&0x80 == 0 {
+			break
+		}
+	}
+	return size, pos
+}
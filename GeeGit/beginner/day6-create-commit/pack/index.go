@@ -0,0 +1,121 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"os"
+
+	"geegit/beginner/day6-create-commit/hash"
+)
+
+// idxMagic 是 version 2 索引文件的魔数 "\377tOc"
+var idxMagic = []byte{0xff, 't', 'O', 'c'}
+
+// Index 表示一个 .idx 文件（version 2）
+// 格式:
+//
+//	magic(4) version(4) fanout(256*4)
+//	hashes(N*20) crc32(N*4) offsets(N*4) [large offsets(M*8)]
+//	pack checksum(20) idx checksum(20)
+type Index struct {
+	Fanout       [256]uint32
+	Hashes       []hash.Hash // 按哈希升序排列
+	CRC32        []uint32
+	Offsets      []int64 // 对象在 .pack 文件中的偏移
+	PackChecksum hash.Hash
+	Checksum     hash.Hash
+}
+
+// ReadIndex 读取并解析一个 .idx 文件
+func ReadIndex(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIndex(data)
+}
+
+// ParseIndex 解析 .idx 文件内容
+func ParseIndex(data []byte) (*Index, error) {
+	if len(data) < 8+256*4+40 || !bytes.Equal(data[:4], idxMagic) {
+		return nil, fmt.Errorf("invalid pack index: bad header")
+	}
+	if v := binary.BigEndian.Uint32(data[4:8]); v != 2 {
+		return nil, fmt.Errorf("unsupported pack index version %d", v)
+	}
+
+	// 1. 校验 idx 自身的 SHA-1
+	idx := &Index{}
+	copy(idx.Checksum[:], data[len(data)-20:])
+	if sha1.Sum(data[:len(data)-20]) != idx.Checksum {
+		return nil, fmt.Errorf("invalid pack index: checksum mismatch")
+	}
+	copy(idx.PackChecksum[:], data[len(data)-40:len(data)-20])
+
+	// 2. fanout 表：fanout[i] 是首字节 <= i 的对象数量
+	offset := 8
+	for i := 0; i < 256; i++ {
+		idx.Fanout[i] = binary.BigEndian.Uint32(data[offset:])
+		offset += 4
+	}
+	n := int(idx.Fanout[255])
+	if len(data) < offset+n*28+40 {
+		return nil, fmt.Errorf("invalid pack index: truncated")
+	}
+
+	// 3. 哈希、CRC32、偏移三张表
+	idx.Hashes = make([]hash.Hash, n)
+	for i := 0; i < n; i++ {
+		copy(idx.Hashes[i][:], data[offset:offset+20])
+		offset += 20
+	}
+	idx.CRC32 = make([]uint32, n)
+	for i := 0; i < n; i++ {
+		idx.CRC32[i] = binary.BigEndian.Uint32(data[offset:])
+		offset += 4
+	}
+	smallOffsets := data[offset : offset+n*4]
+	largeOffsets := data[offset+n*4 : len(data)-40]
+
+	// 4. 最高位为 1 的偏移指向 8 字节的大偏移表
+	idx.Offsets = make([]int64, n)
+	for i := 0; i < n; i++ {
+		v := binary.BigEndian.Uint32(smallOffsets[i*4:])
+		if v&0x80000000 == 0 {
+			idx.Offsets[i] = int64(v)
+			continue
+		}
+		pos := int(v&0x7fffffff) * 8
+		if pos+8 > len(largeOffsets) {
+			return nil, fmt.Errorf("invalid pack index: bad large offset")
+		}
+		idx.Offsets[i] = int64(binary.BigEndian.Uint64(largeOffsets[pos:]))
+	}
+
+	return idx, nil
+}
+
+// Find 在索引中查找哈希，返回其下标
+func (idx *Index) Find(h hash.Hash) (int, bool) {
+	// 利用 fanout 表缩小二分查找的范围
+	lo := 0
+	if h[0] > 0 {
+		lo = int(idx.Fanout[h[0]-1])
+	}
+	hi := int(idx.Fanout[h[0]])
+
+	for lo < hi {
+		mid := (lo + hi) / 2
+		switch bytes.Compare(idx.Hashes[mid][:], h[:]) {
+		case 0:
+			return mid, true
+		case -1:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,330 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"geegit/beginner/day6-create-commit/alternates"
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/hash"
+)
+
+// multi-pack-index 文件（objects/pack/multi-pack-index）把多个 packfile 的索引合并成一张按哈希排序的表，
+// 查找对象时只需要一次二分查找，不必逐个查询每个 .idx。格式:
+//
+//	"MIDX" version(1) hashVersion(1) chunkCount(1) baseFiles(1) packCount(4)
+//	chunk 表: (id(4) offset(8)) * (chunkCount+1)，最后一项的 id 为 0
+//	PNAM: 按名称排序的 .idx 文件名，各以 '\0' 结尾，整体补齐到 4 字节
+//	OIDF: fanout(256*4)
+//	OIDL: hashes(N*20)，按哈希升序排列
+//	OOFF: (packID(4) offset(4)) * N，offset 最高位为 1 时其余位是 LOFF 中的下标
+//	LOFF: 8 字节的大偏移
+//	RIDX: 按伪 pack 顺序排列的对象在 OIDL 中的下标（写位图时才有），见 PseudoPackOrder
+//	checksum(20)
+
+var midxSignature = []byte("MIDX")
+
+const (
+	midxChunkPackNames    = 0x504e414d // "PNAM"
+	midxChunkFanout       = 0x4f494446 // "OIDF"
+	midxChunkOIDs         = 0x4f49444c // "OIDL"
+	midxChunkOffsets      = 0x4f4f4646 // "OOFF"
+	midxChunkLargeOffsets = 0x4c4f4646 // "LOFF"
+	midxChunkRevIndex     = 0x52494458 // "RIDX"
+
+	midxHeaderSize  = 12
+	midxLargeOffset = 0x80000000
+)
+
+// MultiPackIndex 表示一个 multi-pack-index 文件
+type MultiPackIndex struct {
+	PackNames []string // 收录的 .idx 文件名（不含目录），按名称排序，下标即 pack ID
+	Fanout    [256]uint32
+	Hashes    []hash.Hash // 按哈希升序排列，每个对象只出现一次
+	Checksum  hash.Hash
+
+	dir          string // objects/pack 目录
+	raw          []byte // 整个文件，用于校验
+	offsets      []byte // OOFF
+	largeOffsets []byte // LOFF
+	revIndex     []byte // RIDX
+}
+
+// MultiPackIndexPath 返回 multi-pack-index 文件的路径
+func MultiPackIndexPath(gitDir string) string {
+	return filepath.Join(gitdir.CommonDir(gitDir), "objects", "pack", "multi-pack-index")
+}
+
+// cachedMidx 是按文件修改时间缓存的 multi-pack-index
+type cachedMidx struct {
+	modTime time.Time
+	size    int64
+	midx    *MultiPackIndex
+	err     error // 文件损坏时的解析错误，同样缓存起来，避免每次查找都重新解析
+}
+
+// midxCache 缓存文件路径 -> *cachedMidx，每次查找对象都要查询一次
+var midxCache sync.Map
+
+// OpenMultiPackIndex 读取仓库的 multi-pack-index，没有该文件或设置了 core.multiPackIndex=false 时返回 nil
+func OpenMultiPackIndex(gitDir string) (*MultiPackIndex, error) {
+	return openMidx(filepath.Dir(MultiPackIndexPath(gitDir)), gitDir)
+}
+
+// openMidx 读取 packDir 中的 multi-pack-index；gitDir 为空（alternates 中的目录）时不检查 core.multiPackIndex
+func openMidx(packDir, gitDir string) (*MultiPackIndex, error) {
+	p := filepath.Join(packDir, "multi-pack-index")
+	info, err := os.Stat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if c, ok := midxCache.Load(p); ok {
+		if c := c.(*cachedMidx); c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+			return c.midx, c.err
+		}
+	}
+
+	enabled := true
+	if gitDir != "" {
+		cfg, err := config.Load(gitDir)
+		if err != nil {
+			return nil, err
+		}
+		enabled = cfg.Bool("core.multipackindex", true)
+	}
+	var m *MultiPackIndex
+	var parseErr error
+	if enabled {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if m, parseErr = ParseMultiPackIndex(data); parseErr == nil {
+			m.dir = packDir
+		}
+	}
+	midxCache.Store(p, &cachedMidx{modTime: info.ModTime(), size: info.Size(), midx: m, err: parseErr})
+	return m, parseErr
+}
+
+// ParseMultiPackIndex 解析 multi-pack-index 文件内容；与 commit-graph 相同，校验和由 VerifyMultiPackIndex 检查
+func ParseMultiPackIndex(data []byte) (*MultiPackIndex, error) {
+	if len(data) < midxHeaderSize+20 || !bytes.Equal(data[:4], midxSignature) {
+		return nil, fmt.Errorf("multi-pack-index signature does not match")
+	}
+	if data[4] != 1 {
+		return nil, fmt.Errorf("multi-pack-index version %d not recognized", data[4])
+	}
+	if data[5] != 1 {
+		return nil, fmt.Errorf("multi-pack-index hash version %d does not match version 1", data[5])
+	}
+	if data[7] != 0 {
+		return nil, fmt.Errorf("multi-pack-index has %d base files, which is not supported", data[7])
+	}
+	be := binary.BigEndian
+	numChunks := int(data[6])
+	numPacks := int(be.Uint32(data[8:12]))
+	tableEnd := midxHeaderSize + (numChunks+1)*12
+	if len(data) < tableEnd+20 {
+		return nil, fmt.Errorf("multi-pack-index file is too small")
+	}
+
+	// 1. chunk 表
+	m := &MultiPackIndex{raw: data}
+	copy(m.Checksum[:], data[len(data)-20:])
+	chunks := make(map[uint32][]byte)
+	for i := 0; i < numChunks; i++ {
+		entry := data[midxHeaderSize+i*12:]
+		start, end := be.Uint64(entry[4:12]), be.Uint64(entry[16:24])
+		if start > end || end > uint64(len(data)-20) {
+			return nil, fmt.Errorf("improper chunk offset(s) %d and %d", start, end)
+		}
+		chunks[be.Uint32(entry[:4])] = data[start:end]
+	}
+	for _, id := range []uint32{midxChunkPackNames, midxChunkFanout, midxChunkOIDs, midxChunkOffsets} {
+		if _, ok := chunks[id]; !ok {
+			return nil, fmt.Errorf("multi-pack-index missing required chunk %08x", id)
+		}
+	}
+
+	// 2. pack 名称
+	names := chunks[midxChunkPackNames]
+	for len(m.PackNames) < numPacks {
+		name, rest, ok := bytes.Cut(names, []byte{0})
+		if !ok || len(name) == 0 {
+			return nil, fmt.Errorf("multi-pack-index pack-name chunk is too short")
+		}
+		m.PackNames = append(m.PackNames, string(name))
+		names = rest
+	}
+
+	// 3. fanout、哈希和偏移
+	fanout := chunks[midxChunkFanout]
+	if len(fanout) != 256*4 {
+		return nil, fmt.Errorf("multi-pack-index OID fanout is of the wrong size")
+	}
+	for i := range m.Fanout {
+		m.Fanout[i] = be.Uint32(fanout[i*4:])
+	}
+	n := int(m.Fanout[255])
+	oids := chunks[midxChunkOIDs]
+	m.offsets = chunks[midxChunkOffsets]
+	if len(oids) != n*20 || len(m.offsets) != n*8 {
+		return nil, fmt.Errorf("multi-pack-index OID lookup chunk is the wrong size")
+	}
+	m.Hashes = make([]hash.Hash, n)
+	for i := range m.Hashes {
+		copy(m.Hashes[i][:], oids[i*20:])
+	}
+	m.largeOffsets = chunks[midxChunkLargeOffsets]
+	if ridx, ok := chunks[midxChunkRevIndex]; ok {
+		if len(ridx) != n*4 {
+			return nil, fmt.Errorf("multi-pack-index reverse-index chunk is the wrong size")
+		}
+		m.revIndex = ridx
+	}
+	return m, nil
+}
+
+// Find 查找哈希，返回它在 Hashes 中的下标
+func (m *MultiPackIndex) Find(h hash.Hash) (int, bool) {
+	lo := 0
+	if h[0] > 0 {
+		lo = int(m.Fanout[h[0]-1])
+	}
+	hi := int(m.Fanout[h[0]])
+	for lo < hi {
+		mid := (lo + hi) / 2
+		switch bytes.Compare(m.Hashes[mid][:], h[:]) {
+		case 0:
+			return mid, true
+		case -1:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return 0, false
+}
+
+// Entry 返回第 i 个对象所在的 pack ID 及其在 packfile 中的偏移
+func (m *MultiPackIndex) Entry(i int) (int, int64) {
+	be := binary.BigEndian
+	packID := be.Uint32(m.offsets[i*8:])
+	off := be.Uint32(m.offsets[i*8+4:])
+	if m.largeOffsets != nil && off&midxLargeOffset != 0 {
+		j := int(off &^ midxLargeOffset)
+		if (j+1)*8 <= len(m.largeOffsets) {
+			return int(packID), int64(be.Uint64(m.largeOffsets[j*8:]))
+		}
+	}
+	return int(packID), int64(off)
+}
+
+// Pack 打开 pack ID 对应的 packfile
+func (m *MultiPackIndex) Pack(id int) (*Pack, error) {
+	if id < 0 || id >= len(m.PackNames) {
+		return nil, fmt.Errorf("bad pack-int-id: %d (%d total packs)", id, len(m.PackNames))
+	}
+	return Open(filepath.Join(m.dir, m.PackNames[id]))
+}
+
+// PseudoPackOrder 返回伪 pack 顺序，即 multi-pack-index 的位图中每一位对应的对象在 Hashes 中的下标；
+// 没有 RIDX 时返回 nil。伪 pack 顺序相当于把所有 packfile 按顺序拼接起来：首选 pack 在前，
+// 其余按 pack ID，同一个 packfile 中按对象的偏移
+func (m *MultiPackIndex) PseudoPackOrder() []uint32 {
+	if m.revIndex == nil {
+		return nil
+	}
+	order := make([]uint32, len(m.Hashes))
+	for i := range order {
+		order[i] = binary.BigEndian.Uint32(m.revIndex[i*4:])
+	}
+	return order
+}
+
+// Locate 查找对象所在的 packfile 及其偏移：依次查找仓库自己的 objects 目录和 alternates 中的目录
+// multi-pack-index 只是加速查找的缓存，损坏时当作不存在，逐个查询 .idx 文件
+func Locate(gitDir string, h hash.Hash) (*Pack, int64, bool, error) {
+	for i, dir := range alternates.ObjectDirs(gitDir) {
+		packDir := filepath.Join(dir, "pack")
+		var m *MultiPackIndex
+		var err error
+		if i == 0 {
+			m, err = OpenMultiPackIndex(gitDir)
+		} else {
+			m, err = openMidx(packDir, "")
+		}
+		if err != nil {
+			m = nil
+		}
+		p, offset, ok, err := locateIn(packDir, m, h)
+		if err != nil || ok {
+			return p, offset, ok, err
+		}
+	}
+	return nil, 0, false, nil
+}
+
+// locateIn 在一个 pack 目录中查找对象：先查询 multi-pack-index（m 可以为 nil），再查询没有被它收录的 packfile
+func locateIn(packDir string, m *MultiPackIndex, h hash.Hash) (*Pack, int64, bool, error) {
+	if m != nil {
+		if i, ok := m.Find(h); ok {
+			id, off := m.Entry(i)
+			if p, err := m.Pack(id); err == nil {
+				return p, off, true, nil
+			}
+			m = nil // packfile 已经被删除（multi-pack-index 过时了），逐个查询所有 packfile
+		}
+	}
+	covered := make(map[string]bool)
+	if m != nil {
+		for _, name := range m.PackNames {
+			covered[name] = true
+		}
+	}
+
+	idxFiles, err := filepath.Glob(filepath.Join(packDir, "*.idx"))
+	if err != nil {
+		return nil, 0, false, err
+	}
+	for _, idxPath := range idxFiles {
+		if covered[filepath.Base(idxPath)] {
+			continue
+		}
+		p, err := Open(idxPath)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		if i, ok := p.Index.Find(h); ok {
+			return p, p.Index.Offsets[i], true, nil
+		}
+	}
+	return nil, 0, false, nil
+}
+
+// FindPack 按用户给出的 packfile 名称（pack-<hash>.pack、.idx 或不带扩展名）查找 pack ID
+func (m *MultiPackIndex) FindPack(name string) (int, bool) {
+	for id, idxName := range m.PackNames {
+		if matchPackName(idxName, name) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// matchPackName 判断 .idx 文件名是否与用户给出的 packfile 名称对应
+func matchPackName(idxName, name string) bool {
+	base := strings.TrimSuffix(idxName, ".idx")
+	return name == idxName || name == base+".pack" || name == base
+}
@@ -0,0 +1,331 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/lockfile"
+)
+
+// ErrNoPacks 表示 objects/pack 下没有可以收录的 packfile
+var ErrNoPacks = errors.New("no pack files to index")
+
+// MidxOptions 控制 WriteMultiPackIndex
+type MidxOptions struct {
+	PreferredPack string // 首选 pack（--preferred-pack），名称不存在时忽略
+	RevIndex      bool   // 写入 RIDX chunk，生成位图时需要；没有指定首选 pack 时选最早的 packfile
+}
+
+// midxEntry 是合并时的一个对象
+type midxEntry struct {
+	hash      hash.Hash
+	packID    int
+	offset    int64
+	mtime     int64
+	preferred bool
+}
+
+// WriteMultiPackIndex 为 objects/pack 下的所有 packfile 生成 multi-pack-index，返回新的文件内容以及是否重新生成了文件
+//  1. 按名称排序 .idx 文件，下标即 pack ID；与 git 一致，原有文件已经收录了所有 packfile、
+//     并且不需要位图或者位图已经存在时不重新生成
+//  2. 确定首选 pack，合并所有对象并按哈希排序；同一个对象在多个 packfile 中时保留首选 pack 中的，
+//     其次是最新的 packfile 中的
+//  3. 写出各 chunk 和校验和，删除属于旧文件的位图
+func WriteMultiPackIndex(gitDir string, opts MidxOptions) (*MultiPackIndex, bool, error) {
+	dir := filepath.Join(gitdir.CommonDir(gitDir), "objects", "pack")
+	idxFiles, err := filepath.Glob(filepath.Join(dir, "*.idx"))
+	if err != nil {
+		return nil, false, err
+	}
+	if len(idxFiles) == 0 {
+		return nil, false, ErrNoPacks
+	}
+	sort.Strings(idxFiles)
+
+	// 1. 检查原有文件；已经损坏的文件直接重写
+	old, _ := OpenMultiPackIndex(gitDir)
+	if old != nil && len(old.PackNames) == len(idxFiles) {
+		same := true
+		for i, name := range old.PackNames {
+			same = same && name == filepath.Base(idxFiles[i])
+		}
+		_, err := os.Stat(filepath.Join(dir, MidxBitmapName(old.Checksum)))
+		if same && !opts.RevIndex {
+			return old, false, removeStaleMidxFiles(dir, hash.Hash{})
+		}
+		if same && err == nil {
+			return old, false, nil
+		}
+	}
+
+	// 2. 打开所有 packfile，确定首选 pack，合并对象
+	packs := make([]*Pack, len(idxFiles))
+	names := make([]string, len(idxFiles))
+	mtimes := make([]int64, len(idxFiles))
+	preferred := -1
+	for i, idxPath := range idxFiles {
+		p, err := Open(idxPath)
+		if err != nil {
+			return nil, false, err
+		}
+		info, err := os.Stat(p.Path)
+		if err != nil {
+			return nil, false, err
+		}
+		packs[i], names[i], mtimes[i] = p, filepath.Base(idxPath), info.ModTime().UnixNano()
+		if opts.PreferredPack != "" && matchPackName(names[i], opts.PreferredPack) {
+			preferred = i
+		}
+	}
+	if opts.PreferredPack == "" && opts.RevIndex {
+		preferred = 0
+		for i := range packs {
+			if len(packs[preferred].Index.Hashes) == 0 || mtimes[i] < mtimes[preferred] {
+				preferred = i
+			}
+		}
+	}
+
+	var all []midxEntry
+	for id, p := range packs {
+		for i, h := range p.Index.Hashes {
+			all = append(all, midxEntry{h, id, p.Index.Offsets[i], mtimes[id], id == preferred})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		a, b := all[i], all[j]
+		if c := bytes.Compare(a.hash[:], b.hash[:]); c != 0 {
+			return c < 0
+		}
+		if a.preferred != b.preferred {
+			return a.preferred
+		}
+		if a.mtime != b.mtime {
+			return a.mtime > b.mtime
+		}
+		return a.packID < b.packID
+	})
+	entries := all[:0]
+	for i, e := range all {
+		if i == 0 || e.hash != all[i-1].hash {
+			entries = append(entries, e)
+		}
+	}
+
+	// 3. 写入文件
+	data := encodeMidx(names, entries, opts.RevIndex)
+	lock, err := lockfile.Acquire(filepath.Join(dir, "multi-pack-index"))
+	if err != nil {
+		return nil, false, err
+	}
+	defer lock.Rollback()
+	if _, err := lock.Write(data); err != nil {
+		return nil, false, err
+	}
+	if err := lock.Commit(); err != nil {
+		return nil, false, err
+	}
+	m, err := ParseMultiPackIndex(data)
+	if err != nil {
+		return nil, false, err
+	}
+	m.dir = dir
+	return m, true, removeStaleMidxFiles(dir, m.Checksum)
+}
+
+// encodeMidx 按文件格式序列化，entries 按哈希排序
+func encodeMidx(names []string, entries []midxEntry, revIndex bool) []byte {
+	be := binary.BigEndian
+	type chunk struct {
+		id   uint32
+		data []byte
+	}
+
+	// 1. 生成每个 chunk 的内容
+	var pnam, fanout, oids, offsets, large bytes.Buffer
+	for _, name := range names {
+		pnam.WriteString(name)
+		pnam.WriteByte(0)
+	}
+	for pnam.Len()%4 != 0 {
+		pnam.WriteByte(0)
+	}
+	var counts [256]uint32
+	needLarge := false
+	for _, e := range entries {
+		counts[e.hash[0]]++
+		oids.Write(e.hash[:])
+		needLarge = needLarge || e.offset > 0xffffffff
+	}
+	var total uint32
+	for _, n := range counts {
+		total += n
+		binary.Write(&fanout, be, total)
+	}
+	for _, e := range entries {
+		binary.Write(&offsets, be, uint32(e.packID))
+		if needLarge && e.offset>>31 != 0 {
+			binary.Write(&offsets, be, midxLargeOffset|uint32(large.Len()/8))
+			binary.Write(&large, be, uint64(e.offset))
+		} else {
+			binary.Write(&offsets, be, uint32(e.offset))
+		}
+	}
+	chunks := []chunk{
+		{midxChunkPackNames, pnam.Bytes()},
+		{midxChunkFanout, fanout.Bytes()},
+		{midxChunkOIDs, oids.Bytes()},
+		{midxChunkOffsets, offsets.Bytes()},
+	}
+	if large.Len() > 0 {
+		chunks = append(chunks, chunk{midxChunkLargeOffsets, large.Bytes()})
+	}
+	if revIndex {
+		order := make([]int, len(entries))
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(i, j int) bool {
+			a, b := entries[order[i]], entries[order[j]]
+			if a.preferred != b.preferred {
+				return a.preferred
+			}
+			if a.packID != b.packID {
+				return a.packID < b.packID
+			}
+			return a.offset < b.offset
+		})
+		var ridx bytes.Buffer
+		for _, i := range order {
+			binary.Write(&ridx, be, uint32(i))
+		}
+		chunks = append(chunks, chunk{midxChunkRevIndex, ridx.Bytes()})
+	}
+
+	// 2. 头部、chunk 表、各 chunk 和校验和
+	var buf bytes.Buffer
+	buf.Write(midxSignature)
+	buf.Write([]byte{1, 1, byte(len(chunks)), 0})
+	binary.Write(&buf, be, uint32(len(names)))
+	offset := uint64(midxHeaderSize + (len(chunks)+1)*12)
+	for _, c := range chunks {
+		binary.Write(&buf, be, c.id)
+		binary.Write(&buf, be, offset)
+		offset += uint64(len(c.data))
+	}
+	binary.Write(&buf, be, uint32(0))
+	binary.Write(&buf, be, offset)
+	for _, c := range chunks {
+		buf.Write(c.data)
+	}
+	sum := sha1.Sum(buf.Bytes())
+	buf.Write(sum[:])
+	return buf.Bytes()
+}
+
+// removeStaleMidxFiles 删除属于旧 multi-pack-index 的位图（multi-pack-index-<checksum>.bitmap）
+func removeStaleMidxFiles(dir string, checksum hash.Hash) error {
+	stale, err := filepath.Glob(filepath.Join(dir, "multi-pack-index-*.bitmap"))
+	if err != nil {
+		return err
+	}
+	keep := MidxBitmapName(checksum)
+	for _, p := range stale {
+		if filepath.Base(p) == keep {
+			continue
+		}
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveMultiPackIndex 删除 multi-pack-index 及其位图，例如它收录的 packfile 被 repack 删除之后
+func RemoveMultiPackIndex(gitDir string) error {
+	p := MultiPackIndexPath(gitDir)
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return removeStaleMidxFiles(filepath.Dir(p), hash.Hash{})
+}
+
+// MidxBitmapName 返回属于某个 multi-pack-index 的位图文件名
+func MidxBitmapName(checksum hash.Hash) string {
+	return "multi-pack-index-" + checksum.String() + ".bitmap"
+}
+
+// VerifyMultiPackIndex 检查 multi-pack-index（git multi-pack-index verify），返回发现的问题，消息与 git 一致
+//  1. 校验和、收录的 packfile、fanout 表和哈希的顺序；没有任何对象时不再继续
+//  2. 按 packfile 分组，检查每个对象记录的偏移与 .idx 中的一致
+func VerifyMultiPackIndex(gitDir string) ([]string, error) {
+	m, err := OpenMultiPackIndex(gitDir)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	var problems []string
+	report := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// 1. 文件本身
+	if sha1.Sum(m.raw[:len(m.raw)-20]) != m.Checksum {
+		report("incorrect checksum")
+	}
+	packs := make([]*Pack, len(m.PackNames))
+	for id := range m.PackNames {
+		if packs[id], err = m.Pack(id); err != nil {
+			report("failed to load pack in position %d", id)
+		}
+	}
+	for i := 0; i < 255; i++ {
+		if m.Fanout[i] > m.Fanout[i+1] {
+			report("oid fanout out of order: fanout[%d] = %x > %x = fanout[%d]", i, m.Fanout[i], m.Fanout[i+1], i+1)
+		}
+	}
+	if len(m.Hashes) == 0 {
+		report("the midx contains no oid")
+		return problems, nil
+	}
+	for i := 0; i+1 < len(m.Hashes); i++ {
+		if bytes.Compare(m.Hashes[i][:], m.Hashes[i+1][:]) >= 0 {
+			report("oid lookup out of order: oid[%d] = %s >= %s = oid[%d]", i, m.Hashes[i], m.Hashes[i+1], i+1)
+		}
+	}
+
+	// 2. 逐个对象比较偏移
+	order := make([]int, len(m.Hashes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		pa, _ := m.Entry(order[a])
+		pb, _ := m.Entry(order[b])
+		return pa < pb
+	})
+	for _, i := range order {
+		h := m.Hashes[i]
+		id, off := m.Entry(i)
+		if id < 0 || id >= len(packs) || packs[id] == nil {
+			report("failed to load pack entry for oid[%d] = %s", i, h)
+			continue
+		}
+		j, ok := packs[id].Index.Find(h)
+		if !ok {
+			report("failed to load pack entry for oid[%d] = %s", i, h)
+			continue
+		}
+		if want := packs[id].Index.Offsets[j]; off != want {
+			report("incorrect object offset for oid[%d] = %s: %x != %x", i, h, off, want)
+		}
+	}
+	return problems, nil
+}
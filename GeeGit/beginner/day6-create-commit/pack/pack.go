@@ -0,0 +1,303 @@
+package pack
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/hash"
+)
+
+// packfile 中的对象类型编号（与 hash.ObjectType 不同）
+const (
+	typeCommit   = 1
+	typeTree     = 2
+	typeBlob     = 3
+	typeTag      = 4
+	typeOfsDelta = 6
+	typeRefDelta = 7
+)
+
+// Pack 表示一个 .pack 文件及其 .idx 索引
+type Pack struct {
+	Path  string // .pack 文件路径
+	Index *Index
+
+	data []byte
+	ends map[int64]int64 // 对象起始偏移 -> 下一个对象的起始偏移
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[string]*Pack)
+)
+
+// Open 打开一个 packfile，参数是 .idx 文件的路径
+// packfile 一旦写入就不会再修改，因此按路径缓存
+func Open(idxPath string) (*Pack, error) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if p, ok := cache[idxPath]; ok {
+		return p, nil
+	}
+
+	idx, err := ReadIndex(idxPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(idxPath), err)
+	}
+
+	packPath := strings.TrimSuffix(idxPath, ".idx") + ".pack"
+	data, err := os.ReadFile(packPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12+20 || string(data[:4]) != "PACK" {
+		return nil, fmt.Errorf("%s: invalid pack header", filepath.Base(packPath))
+	}
+	if v := binary.BigEndian.Uint32(data[4:8]); v != 2 && v != 3 {
+		return nil, fmt.Errorf("%s: unsupported pack version %d", filepath.Base(packPath), v)
+	}
+
+	p := &Pack{Path: packPath, Index: idx, data: data}
+
+	// 计算每个对象在文件中占据的范围，用于 CRC32 校验
+	offsets := append([]int64(nil), idx.Offsets...)
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	p.ends = make(map[int64]int64, len(offsets))
+	for i, off := range offsets {
+		end := int64(len(data) - 20)
+		if i+1 < len(offsets) {
+			end = offsets[i+1]
+		}
+		p.ends[off] = end
+	}
+
+	cache[idxPath] = p
+	return p, nil
+}
+
+// OpenAll 打开 .git/objects/pack 目录下的所有 packfile（不包括 alternates 中的）
+func OpenAll(gitDir string) ([]*Pack, error) {
+	return OpenDir(filepath.Join(gitdir.CommonDir(gitDir), "objects"))
+}
+
+// OpenDir 打开对象目录 objectDir 中 pack 目录下的所有 packfile
+func OpenDir(objectDir string) ([]*Pack, error) {
+	idxFiles, err := filepath.Glob(filepath.Join(objectDir, "pack", "*.idx"))
+	if err != nil {
+		return nil, err
+	}
+	var packs []*Pack
+	for _, idxPath := range idxFiles {
+		p, err := Open(idxPath)
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, p)
+	}
+	return packs, nil
+}
+
+// Contains 判断 packfile 中是否包含指定对象
+func (p *Pack) Contains(h hash.Hash) bool {
+	_, ok := p.Index.Find(h)
+	return ok
+}
+
+// IsPromisor 判断 packfile 是否来自部分克隆的远程（有同名的 .promisor 文件）
+// 其中的对象引用的缺失对象是远程承诺可以补上的，不算仓库损坏
+func (p *Pack) IsPromisor() bool {
+	_, err := os.Stat(strings.TrimSuffix(p.Path, ".pack") + ".promisor")
+	return err == nil
+}
+
+// Read 读取 packfile 中的对象，自动解析 delta
+func (p *Pack) Read(h hash.Hash) (hash.ObjectType, []byte, error) {
+	i, ok := p.Index.Find(h)
+	if !ok {
+		return 0, nil, fmt.Errorf("object %s not in pack", h.String())
+	}
+	return p.ReadAt(p.Index.Offsets[i])
+}
+
+// ReadAt 读取指定偏移处的对象
+// 对象头格式: 第一个字节的 bit4-6 是类型，其余各字节的低 7 位拼成大小
+func (p *Pack) ReadAt(offset int64) (hash.ObjectType, []byte, error) {
+	if offset < 12 || offset >= int64(len(p.data)-20) {
+		return 0, nil, fmt.Errorf("invalid pack offset %d", offset)
+	}
+	pos := offset
+
+	// 1. 解析类型和解压后的大小
+	c := p.data[pos]
+	pos++
+	objType := int(c>>4) & 7
+	size := int64(c & 0x0f)
+	shift := uint(4)
+	for c&0x80 != 0 {
+		if pos >= int64(len(p.data)) {
+			return 0, nil, fmt.Errorf("truncated object header at %d", offset)
+		}
+		c = p.data[pos]
+		pos++
+		size |= int64(c&0x7f) << shift
+		shift += 7
+	}
+
+	// 2. delta 对象需要先找到基础对象
+	var baseOffset int64 = -1
+	var baseHash hash.Hash
+	switch objType {
+	case typeOfsDelta:
+		// 负偏移的编码方式: 每多一个字节都要先加 1
+		c = p.data[pos]
+		pos++
+		rel := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			c = p.data[pos]
+			pos++
+			rel = ((rel + 1) << 7) | int64(c&0x7f)
+		}
+		baseOffset = offset - rel
+	case typeRefDelta:
+		copy(baseHash[:], p.data[pos:pos+20])
+		pos += 20
+	}
+
+	// 3. 解压数据
+	data, err := inflate(p.data[pos:], size)
+	if err != nil {
+		return 0, nil, fmt.Errorf("object at %d: %v", offset, err)
+	}
+
+	switch objType {
+	case typeCommit:
+		return hash.CommitObject, data, nil
+	case typeTree:
+		return hash.TreeObject, data, nil
+	case typeBlob:
+		return hash.BlobObject, data, nil
+	case typeTag:
+		return hash.TagObject, data, nil
+	case typeOfsDelta, typeRefDelta:
+		if baseOffset < 0 {
+			i, ok := p.Index.Find(baseHash)
+			if !ok {
+				return 0, nil, fmt.Errorf("delta base %s not in pack", baseHash.String())
+			}
+			baseOffset = p.Index.Offsets[i]
+		}
+		baseType, base, err := p.ReadAt(baseOffset)
+		if err != nil {
+			return 0, nil, err
+		}
+		result, err := ApplyDelta(base, data)
+		if err != nil {
+			return 0, nil, fmt.Errorf("object at %d: %v", offset, err)
+		}
+		return baseType, result, nil
+	default:
+		return 0, nil, fmt.Errorf("unknown object type %d at %d", objType, offset)
+	}
+}
+
+// TypeAt 返回指定偏移处的对象类型；delta 对象沿着基础对象查找，不需要解压数据
+func (p *Pack) TypeAt(offset int64) (hash.ObjectType, error) {
+	for depth := 0; depth < len(p.Index.Offsets); depth++ {
+		if offset < 12 || offset >= int64(len(p.data)-20) {
+			return 0, fmt.Errorf("invalid pack offset %d", offset)
+		}
+		pos := offset
+		c := p.data[pos]
+		objType := int(c>>4) & 7
+		for c&0x80 != 0 && pos+1 < int64(len(p.data)) {
+			pos++
+			c = p.data[pos]
+		}
+		pos++
+
+		switch objType {
+		case typeCommit:
+			return hash.CommitObject, nil
+		case typeTree:
+			return hash.TreeObject, nil
+		case typeBlob:
+			return hash.BlobObject, nil
+		case typeTag:
+			return hash.TagObject, nil
+		case typeOfsDelta:
+			c = p.data[pos]
+			pos++
+			rel := int64(c & 0x7f)
+			for c&0x80 != 0 {
+				c = p.data[pos]
+				pos++
+				rel = ((rel + 1) << 7) | int64(c&0x7f)
+			}
+			offset -= rel
+		case typeRefDelta:
+			var base hash.Hash
+			copy(base[:], p.data[pos:pos+20])
+			i, ok := p.Index.Find(base)
+			if !ok {
+				return 0, fmt.Errorf("delta base %s not in pack", base.String())
+			}
+			offset = p.Index.Offsets[i]
+		default:
+			return 0, fmt.Errorf("unknown object type %d at %d", objType, offset)
+		}
+	}
+	return 0, fmt.Errorf("delta chain too long at %d", offset)
+}
+
+// Verify 校验 packfile 末尾的 SHA-1，以及它是否与 .idx 中记录的一致
+func (p *Pack) Verify() error {
+	n := len(p.data) - 20
+	var trailer hash.Hash
+	copy(trailer[:], p.data[n:])
+	if sha1.Sum(p.data[:n]) != trailer {
+		return fmt.Errorf("pack checksum mismatch")
+	}
+	if trailer != p.Index.PackChecksum {
+		return fmt.Errorf("pack checksum does not match index")
+	}
+	if count := binary.BigEndian.Uint32(p.data[8:12]); int(count) != len(p.Index.Hashes) {
+		return fmt.Errorf("pack has %d objects, index has %d", count, len(p.Index.Hashes))
+	}
+	return nil
+}
+
+// CheckCRC 校验索引中第 i 个对象的原始数据是否与记录的 CRC32 一致
+func (p *Pack) CheckCRC(i int) bool {
+	start := p.Index.Offsets[i]
+	end, ok := p.ends[start]
+	if !ok {
+		return false
+	}
+	return crc32.ChecksumIEEE(p.data[start:end]) == p.Index.CRC32[i]
+}
+
+// inflate 解压 zlib 数据，并检查解压后的长度
+func inflate(data []byte, size int64) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("zlib decompress failed: %v", err)
+	}
+	defer zr.Close()
+
+	out := make([]byte, size)
+	if _, err := io.ReadFull(zr, out); err != nil {
+		return nil, fmt.Errorf("zlib decompress failed: %v", err)
+	}
+	return out, nil
+}
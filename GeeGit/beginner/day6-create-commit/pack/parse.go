@@ -0,0 +1,218 @@
+package pack
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"geegit/beginner/day6-create-commit/hash"
+)
+
+// record 是 Parse 从数据流中读出的一个对象，delta 对象在解析基础对象之后才有类型和内容
+type record struct {
+	offset   int64
+	objType  hash.ObjectType
+	data     []byte    // 完整内容；delta 对象解析之前是 delta 指令
+	base     int64     // OFS_DELTA 的基础对象偏移，-1 表示不是
+	baseRef  hash.Hash // REF_DELTA 的基础对象哈希
+	isRef    bool
+	resolved bool // 已经有完整内容（不是 delta，或者 delta 已经解析）
+	hash     hash.Hash
+}
+
+// Parse 解析没有索引的 packfile 数据（例如 bundle 中或网络上收到的 packfile），返回其中的全部对象
+//  1. 校验头部和末尾的 SHA-1，按顺序读出每个对象；zlib 数据的长度只有解压时才知道，
+//     从 bytes.Reader 读取可以保证解压器不会多读，读完后剩余的长度就是下一个对象的位置
+//  2. 反复解析基础对象已知的 delta 对象：OFS_DELTA 按偏移，REF_DELTA 按哈希在已解析的对象中查找
+//  3. 仍然缺少基础对象的 REF_DELTA 是瘦 packfile（thin pack）引用的包外对象，交给 external 读取
+//
+// 返回的对象都是完整内容，按它们在 packfile 中的顺序排列，不包括 external 提供的基础对象
+func Parse(data []byte, external func(h hash.Hash) (hash.ObjectType, []byte, error)) ([]Entry, error) {
+	if len(data) < 12+20 || string(data[:4]) != "PACK" {
+		return nil, fmt.Errorf("invalid pack header")
+	}
+	if v := binary.BigEndian.Uint32(data[4:8]); v != 2 && v != 3 {
+		return nil, fmt.Errorf("unsupported pack version %d", v)
+	}
+	end := len(data) - 20
+	var trailer hash.Hash
+	copy(trailer[:], data[end:])
+	if sha1.Sum(data[:end]) != trailer {
+		return nil, fmt.Errorf("pack checksum mismatch")
+	}
+
+	// 1. 读出每个对象
+	count := int(binary.BigEndian.Uint32(data[8:12]))
+	records := make([]*record, 0, count)
+	byOffset := make(map[int64]*record, count)
+	pos := int64(12)
+	for i := 0; i < count; i++ {
+		if pos >= int64(end) {
+			return nil, fmt.Errorf("pack truncated: %d of %d objects", i, count)
+		}
+		r, next, err := readRecord(data[:end], pos)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+		byOffset[r.offset] = r
+		pos = next
+	}
+	if pos != int64(end) {
+		return nil, fmt.Errorf("pack has %d unused bytes", int64(end)-pos)
+	}
+
+	// 2. 解析 delta
+	byHash := make(map[hash.Hash]*record, count)
+	for _, r := range records {
+		if r.resolved {
+			r.hash = hash.ComputeHash(r.objType, r.data)
+			byHash[r.hash] = r
+		}
+	}
+	resolve := func(r, base *record) error {
+		out, err := ApplyDelta(base.data, r.data)
+		if err != nil {
+			return fmt.Errorf("object at %d: %v", r.offset, err)
+		}
+		r.objType, r.data, r.resolved = base.objType, out, true
+		r.hash = hash.ComputeHash(r.objType, r.data)
+		byHash[r.hash] = r
+		return nil
+	}
+	for {
+		progress, pending := false, false
+		for _, r := range records {
+			if r.resolved {
+				continue
+			}
+			var base *record
+			if r.isRef {
+				base = byHash[r.baseRef]
+			} else if base = byOffset[r.base]; base == nil {
+				return nil, fmt.Errorf("object at %d: bad delta base offset %d", r.offset, r.base)
+			}
+			if base == nil || !base.resolved {
+				pending = true
+				continue
+			}
+			if err := resolve(r, base); err != nil {
+				return nil, err
+			}
+			progress = true
+		}
+		if !pending {
+			break
+		}
+		if progress {
+			continue
+		}
+
+		// 3. 包外的基础对象：每个只读取一次，解析出的对象可能又是其他 delta 的基础对象
+		found := false
+		for _, r := range records {
+			if r.resolved || !r.isRef || byHash[r.baseRef] != nil {
+				continue
+			}
+			if external == nil {
+				return nil, fmt.Errorf("delta base %s not in pack", r.baseRef)
+			}
+			t, content, err := external(r.baseRef)
+			if err != nil {
+				return nil, fmt.Errorf("delta base %s: %v", r.baseRef, err)
+			}
+			byHash[r.baseRef] = &record{objType: t, data: content, resolved: true, hash: r.baseRef}
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("pack has unresolved deltas")
+		}
+	}
+
+	entries := make([]Entry, len(records))
+	for i, r := range records {
+		entries[i] = Entry{Hash: r.hash, Type: r.objType, Content: r.data}
+	}
+	return entries, nil
+}
+
+// readRecord 读出 offset 处的对象，返回下一个对象的偏移
+func readRecord(data []byte, offset int64) (*record, int64, error) {
+	pos := offset
+
+	// 1. 类型和解压后的大小
+	c := data[pos]
+	pos++
+	objType := int(c>>4) & 7
+	size := int64(c & 0x0f)
+	shift := uint(4)
+	for c&0x80 != 0 {
+		if pos >= int64(len(data)) {
+			return nil, 0, fmt.Errorf("truncated object header at %d", offset)
+		}
+		c = data[pos]
+		pos++
+		size |= int64(c&0x7f) << shift
+		shift += 7
+	}
+
+	r := &record{offset: offset, base: -1, resolved: true}
+	switch objType {
+	case typeCommit:
+		r.objType = hash.CommitObject
+	case typeTree:
+		r.objType = hash.TreeObject
+	case typeBlob:
+		r.objType = hash.BlobObject
+	case typeTag:
+		r.objType = hash.TagObject
+	case typeOfsDelta:
+		rel := int64(0)
+		for i := 0; ; i++ {
+			if pos >= int64(len(data)) {
+				return nil, 0, fmt.Errorf("truncated object header at %d", offset)
+			}
+			c = data[pos]
+			pos++
+			if i > 0 {
+				rel++
+			}
+			rel = rel<<7 | int64(c&0x7f)
+			if c&0x80 == 0 {
+				break
+			}
+		}
+		if rel <= 0 || rel > offset {
+			return nil, 0, fmt.Errorf("object at %d: bad delta base offset", offset)
+		}
+		r.base, r.resolved = offset-rel, false
+	case typeRefDelta:
+		if pos+20 > int64(len(data)) {
+			return nil, 0, fmt.Errorf("truncated object header at %d", offset)
+		}
+		copy(r.baseRef[:], data[pos:pos+20])
+		r.isRef, r.resolved = true, false
+		pos += 20
+	default:
+		return nil, 0, fmt.Errorf("unknown object type %d at %d", objType, offset)
+	}
+
+	// 2. 解压数据，读到 zlib 流结束（包括 adler32 校验和）
+	br := bytes.NewReader(data[pos:])
+	zr, err := zlib.NewReader(br)
+	if err != nil {
+		return nil, 0, fmt.Errorf("object at %d: zlib decompress failed: %v", offset, err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, 0, fmt.Errorf("object at %d: zlib decompress failed: %v", offset, err)
+	}
+	if int64(len(out)) != size {
+		return nil, 0, fmt.Errorf("object at %d: size mismatch", offset)
+	}
+	r.data = out
+	return r, int64(len(data)) - int64(br.Len()), nil
+}
@@ -0,0 +1,177 @@
+package pack
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/hash"
+)
+
+// Entry 是要写入 packfile 的一个对象
+type Entry struct {
+	Hash    hash.Hash
+	Type    hash.ObjectType
+	Content []byte
+}
+
+// Write 把对象写成 objects/pack 下的一个新 packfile（不使用 delta），同时生成 .idx
+// promisor 为 true 时再写一个空的 .promisor 文件，标记其中的对象来自部分克隆的远程（promisor remote），
+// 它们引用的缺失对象可以按需从远程获取。返回 packfile 的校验和，即文件名中的 pack-<hash>
+func Write(gitDir string, entries []Entry, promisor bool) (hash.Hash, error) {
+	data, idx, err := encode(entries)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+
+	// 依次写入 .pack、.promisor 和 .idx；.idx 最后出现，读取方不会看到不完整的 packfile。
+	// 内容完全相同的 packfile 已经存在时直接返回
+	dir := filepath.Join(gitdir.CommonDir(gitDir), "objects", "pack")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return hash.Hash{}, err
+	}
+	base := filepath.Join(dir, "pack-"+idx.PackChecksum.String())
+	if _, err := os.Stat(base + ".idx"); err == nil {
+		return idx.PackChecksum, nil
+	}
+	if err := os.WriteFile(base+".pack", data, 0444); err != nil {
+		return hash.Hash{}, err
+	}
+	if promisor {
+		if err := os.WriteFile(base+".promisor", nil, 0444); err != nil {
+			return hash.Hash{}, err
+		}
+	}
+	if err := os.WriteFile(base+".idx", idx.encode(), 0444); err != nil {
+		return hash.Hash{}, err
+	}
+	return idx.PackChecksum, nil
+}
+
+// Encode 把对象编码成 packfile（不使用 delta）写入 w，不生成索引，用于 bundle 等需要 packfile 数据流的场合
+// 返回 packfile 的校验和
+func Encode(w io.Writer, entries []Entry) (hash.Hash, error) {
+	data, idx, err := encode(entries)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return hash.Hash{}, err
+	}
+	return idx.PackChecksum, nil
+}
+
+// encode 按顺序编码对象，返回 packfile 的内容（包括末尾的校验和）和对应的索引
+// 格式: "PACK" version(4) count(4)，然后依次是每个对象，最后是前面所有内容的 SHA-1
+func encode(entries []Entry) ([]byte, *Index, error) {
+	var buf bytes.Buffer
+	buf.WriteString("PACK")
+	binary.Write(&buf, binary.BigEndian, uint32(2))
+	binary.Write(&buf, binary.BigEndian, uint32(len(entries)))
+
+	idx := &Index{
+		Hashes:  make([]hash.Hash, len(entries)),
+		CRC32:   make([]uint32, len(entries)),
+		Offsets: make([]int64, len(entries)),
+	}
+	for i, e := range entries {
+		start := buf.Len()
+		if err := writeEntry(&buf, e); err != nil {
+			return nil, nil, err
+		}
+		idx.Hashes[i] = e.Hash
+		idx.Offsets[i] = int64(start)
+		idx.CRC32[i] = crc32.ChecksumIEEE(buf.Bytes()[start:])
+	}
+	idx.PackChecksum = sha1.Sum(buf.Bytes())
+	buf.Write(idx.PackChecksum[:])
+	return buf.Bytes(), idx, nil
+}
+
+// writeEntry 写入一个对象: 类型和大小组成的变长头部，然后是 zlib 压缩的内容
+func writeEntry(buf *bytes.Buffer, e Entry) error {
+	var objType byte
+	switch e.Type {
+	case hash.CommitObject:
+		objType = typeCommit
+	case hash.TreeObject:
+		objType = typeTree
+	case hash.BlobObject:
+		objType = typeBlob
+	case hash.TagObject:
+		objType = typeTag
+	default:
+		return fmt.Errorf("cannot pack object of type %s", e.Type)
+	}
+
+	size := uint64(len(e.Content))
+	c := objType<<4 | byte(size&0x0f)
+	size >>= 4
+	for size > 0 {
+		buf.WriteByte(c | 0x80)
+		c = byte(size & 0x7f)
+		size >>= 7
+	}
+	buf.WriteByte(c)
+
+	zw := zlib.NewWriter(buf)
+	if _, err := zw.Write(e.Content); err != nil {
+		return fmt.Errorf("zlib compress failed: %v", err)
+	}
+	return zw.Close()
+}
+
+// encode 按 version 2 的格式序列化索引，对象按哈希排序；超过 31 位的偏移放入大偏移表
+func (idx *Index) encode() []byte {
+	order := make([]int, len(idx.Hashes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return bytes.Compare(idx.Hashes[order[a]][:], idx.Hashes[order[b]][:]) < 0
+	})
+
+	var buf bytes.Buffer
+	buf.Write(idxMagic)
+	binary.Write(&buf, binary.BigEndian, uint32(2))
+	var fanout [256]uint32
+	for _, h := range idx.Hashes {
+		fanout[h[0]]++
+	}
+	var total uint32
+	for i := range fanout {
+		total += fanout[i]
+		binary.Write(&buf, binary.BigEndian, total)
+	}
+	for _, i := range order {
+		buf.Write(idx.Hashes[i][:])
+	}
+	for _, i := range order {
+		binary.Write(&buf, binary.BigEndian, idx.CRC32[i])
+	}
+	var large []int64
+	for _, i := range order {
+		off := idx.Offsets[i]
+		if off < 0x80000000 {
+			binary.Write(&buf, binary.BigEndian, uint32(off))
+			continue
+		}
+		binary.Write(&buf, binary.BigEndian, uint32(0x80000000|len(large)))
+		large = append(large, off)
+	}
+	for _, off := range large {
+		binary.Write(&buf, binary.BigEndian, uint64(off))
+	}
+	buf.Write(idx.PackChecksum[:])
+	sum := sha1.Sum(buf.Bytes())
+	buf.Write(sum[:])
+	return buf.Bytes()
+}
@@ -0,0 +1,337 @@
+package push
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/fetch"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/hooks"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/remote"
+	"geegit/beginner/day6-create-commit/repository"
+	"geegit/beginner/day6-create-commit/revision"
+)
+
+// 推送：把本地的引用及其历史发送到远程仓库并更新远程的引用（git push）。
+// 与 fetch 一样目前只支持本地仓库，接收端（git receive-pack）做的事也在这里完成：
+// 检查当前分支、运行接收端的钩子、更新引用。两端的钩子按 git 的顺序运行：
+//
+//	pre-push     推送端，发送对象之前；非零退出时不推送任何引用
+//	pre-receive  接收端，更新引用之前，所有更新一起；非零退出时拒绝全部更新
+//	update       接收端，每个引用更新之前；非零退出时只拒绝这个引用
+//	post-receive 接收端，所有引用更新之后，之后是 post-update；退出码被忽略
+
+// Options 控制一次推送
+type Options struct {
+	Force       bool     // 所有 refspec 都允许非快进更新（--force）
+	NoVerify    bool     // 不运行 pre-push（--no-verify）
+	PushOptions []string // 传给接收端钩子的推送选项（-o），接收端需要设置 receive.advertisePushOptions
+
+	// Remote 接收接收端的输出：钩子的输出和拒绝更新的说明，nil 表示 os.Stderr
+	Remote io.Writer
+	// Hints 接收推送端钩子因为不可执行而被忽略时的提示，nil 表示不提示
+	Hints io.Writer
+}
+
+// Update 是一次推送中一个引用的更新
+type Update struct {
+	Src      string    // 本地引用的完整名称，源不是引用时是命令行中的写法（例如 "HEAD~1"）；删除时为空
+	Dst      string    // 远程引用的完整名称
+	Old, New hash.Hash // Old 为零哈希表示新建，New 为零哈希表示删除，两者相同表示已是最新
+	Forced   bool      // 不是快进，因为 --force 或 refspec 带 "+" 仍然更新
+
+	// Rejected 是推送端拒绝更新的原因："non-fast-forward"、"fetch first"（远程的值本地没有）或 "already exists"（标签）
+	Rejected string
+	// RemoteRejected 是接收端拒绝更新的原因，例如 "pre-receive hook declined"、"hook declined"
+	RemoteRejected string
+}
+
+// OK 判断更新是否成功（包括已是最新）
+func (u *Update) OK() bool {
+	return u.Rejected == "" && u.RemoteRejected == ""
+}
+
+// Result 是一次推送的结果
+type Result struct {
+	URL     string
+	Updates []Update // 按 refspec 的顺序
+}
+
+// SpecError 表示 refspec 无法解析为要推送的引用，例如源不存在；此时不推送任何引用
+type SpecError struct {
+	Msg  string
+	Hint string // 与 git 相同的提示，以 "hint: " 开头、以换行结尾；没有时为空
+}
+
+func (e *SpecError) Error() string {
+	return e.Msg
+}
+
+// Push 按 specs 把本地的引用推送到远程 r 的第一个推送地址，返回每个引用的结果
+//  1. 打开远程仓库，把 refspec 解析为更新，检查是否是快进
+//  2. 运行 pre-push，接收端需要时检查推送选项
+//  3. 把接收端还没有的对象写入接收端（与 git 一样，之后被拒绝的更新所需的对象也已写入）
+//  4. 接收端运行钩子并更新引用，成功的更新同时更新本地的远程跟踪引用（reflog 原因 "update by push"）
+//
+// 空的 refspec（":"）推送远程也有的所有同名分支。pre-push 非零退出时返回 *hooks.ExitError
+func Push(gitDir, workDir string, r *remote.Remote, specs []remote.RefSpec, opts Options) (*Result, error) {
+	url := URL(r)
+	dir, err := open(url)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{URL: url}
+
+	// 1. 解析 refspec，检查快进
+	for _, spec := range specs {
+		updates, err := resolve(gitDir, dir, spec)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range updates {
+			if err := check(gitDir, &u, spec.Force || opts.Force); err != nil {
+				return nil, err
+			}
+			res.Updates = append(res.Updates, u)
+		}
+	}
+
+	// 2. pre-push 和推送选项
+	var pending []*Update
+	var prePush []hooks.PushUpdate
+	for i := range res.Updates {
+		u := &res.Updates[i]
+		if u.Rejected != "" || u.Old == u.New {
+			continue
+		}
+		pending = append(pending, u)
+		prePush = append(prePush, hooks.PushUpdate{LocalRef: u.Src, Local: u.New, RemoteRef: u.Dst, Remote: u.Old})
+	}
+	if !opts.NoVerify {
+		if err := hooks.PrePush(gitDir, workDir, r.Name, url, prePush, opts.Hints); err != nil {
+			return nil, err
+		}
+	}
+	if len(pending) == 0 {
+		return res, nil
+	}
+	if len(opts.PushOptions) > 0 {
+		cfg, err := config.Load(dir)
+		if err != nil {
+			return nil, err
+		}
+		if !cfg.Bool("receive.advertisePushOptions", false) {
+			return nil, errors.New("the receiving end does not support push options\nfatal: the remote end hung up unexpectedly")
+		}
+	}
+
+	// 3. 发送对象
+	var tips []hash.Hash
+	for _, u := range pending {
+		if !u.New.IsZero() {
+			tips = append(tips, u.New)
+		}
+	}
+	if err := fetch.Objects(dir, gitDir, tips); err != nil {
+		return nil, err
+	}
+
+	// 4. 接收端更新引用，本地更新远程跟踪引用
+	if err := receive(dir, pending, opts); err != nil {
+		return nil, err
+	}
+	for _, u := range pending {
+		tracking := r.TrackingRef(u.Dst)
+		if !u.OK() || tracking == "" {
+			continue
+		}
+		if u.New.IsZero() {
+			err = refs.Delete(gitDir, tracking, nil)
+		} else {
+			err = refs.Update(gitDir, tracking, u.New, nil, "update by push")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// URL 返回推送使用的地址：remote.<name>.pushurl，没有配置时是 url
+func URL(r *remote.Remote) string {
+	if len(r.PushURLs) > 0 {
+		return r.PushURLs[0]
+	}
+	return r.FetchURL()
+}
+
+// open 打开远程仓库，返回它的 git 目录；错误信息与 git 无法连接远程仓库时一致
+func open(url string) (string, error) {
+	dir, err := repository.OpenLocal(url)
+	if err != nil {
+		return "", fmt.Errorf("%v\nfatal: Could not read from remote repository.\n\n"+
+			"Please make sure you have the correct access rights\nand the repository exists.", err)
+	}
+	return dir, nil
+}
+
+// resolve 把一条 refspec 解析为更新，只填写 Src、Dst、Old 和 New
+//   - ":" 推送远程也有的所有同名分支；带 "*" 的 refspec 推送匹配的所有本地引用
+//   - ":<dst>" 删除远程引用，远程必须有这个引用
+//   - 源可以是引用或任意的 commit，没有目标时推送到同名的远程引用（HEAD 推送到当前分支的同名分支）
+func resolve(gitDir, dir string, spec remote.RefSpec) ([]Update, error) {
+	switch {
+	case spec.Src == "" && spec.Dst == "":
+		return matching(gitDir, dir)
+	case strings.Contains(spec.Src, "*"):
+		return wildcard(gitDir, dir, spec)
+	case spec.Src == "":
+		dst, err := refs.Expand(dir, spec.Dst)
+		if err != nil {
+			return nil, &SpecError{Msg: fmt.Sprintf("unable to delete '%s': remote ref does not exist", spec.Dst)}
+		}
+		old, err := refs.Resolve(dir, dst)
+		if err != nil {
+			return nil, err
+		}
+		return []Update{{Dst: dst, Old: old}}, nil
+	}
+
+	// 源：引用或 commit
+	src, newHash := spec.Src, hash.Hash{}
+	if name, err := refs.Expand(gitDir, spec.Src); err == nil {
+		if newHash, err = refs.Resolve(gitDir, name); err != nil {
+			return nil, err
+		}
+		src = name
+	} else if newHash, err = revision.Resolve(gitDir, spec.Src); err != nil {
+		return nil, &SpecError{Msg: fmt.Sprintf("src refspec %s does not match any", spec.Src)}
+	}
+	// HEAD 按它指向的分支确定目标
+	srcRef := src
+	if src == "HEAD" {
+		if srcRef, _ = refs.ResolveName(gitDir, "HEAD"); srcRef == "HEAD" {
+			srcRef = ""
+		}
+	}
+
+	// 目标：完整的引用名；简写先在远程查找，找不到时与源引用放在同一类（分支或标签）中
+	dst := spec.Dst
+	if dst == "" {
+		if !strings.HasPrefix(srcRef, "refs/") {
+			return nil, notFullRefName(gitDir, spec.Src, spec.Src, newHash)
+		}
+		dst = srcRef
+	} else if !strings.HasPrefix(dst, "refs/") {
+		if name, err := refs.Expand(dir, dst); err == nil && strings.HasPrefix(name, "refs/") {
+			dst = name
+		} else if strings.HasPrefix(srcRef, "refs/heads/") {
+			dst = "refs/heads/" + dst
+		} else if strings.HasPrefix(srcRef, "refs/tags/") {
+			dst = "refs/tags/" + dst
+		} else {
+			return nil, notFullRefName(gitDir, spec.Dst, spec.Src, newHash)
+		}
+	}
+	if err := refs.CheckName(dst); err != nil {
+		return nil, err
+	}
+	old, err := refs.Resolve(dir, dst)
+	if err != nil && !errors.Is(err, refs.ErrNotFound) {
+		return nil, err
+	}
+	return []Update{{Src: src, Dst: dst, Old: old, New: newHash}}, nil
+}
+
+// notFullRefName 返回目标不是完整引用名、又无法推测时的错误，信息与 git 相同；
+// 源是 commit 时提示推送到同名的新分支
+func notFullRefName(gitDir, dst, src string, h hash.Hash) error {
+	err := &SpecError{}
+	if obj, e := object.Read(gitDir, h); e == nil && obj.Type == hash.CommitObject {
+		err.Hint = "hint: The <src> part of the refspec is a commit object.\n" +
+			"hint: Did you mean to create a new branch by pushing to\n" +
+			fmt.Sprintf("hint: '%s:refs/heads/%s'?\n", src, dst)
+	}
+	err.Msg = fmt.Sprintf("The destination you provided is not a full refname (i.e.,\n"+
+		"starting with \"refs/\"). We tried to guess what you meant by:\n\n"+
+		"- Looking for a ref that matches '%s' on the remote side.\n"+
+		"- Checking if the <src> being pushed ('%s')\n"+
+		"  is a ref in \"refs/{heads,tags}/\". If so we add a corresponding\n"+
+		"  refs/{heads,tags}/ prefix on the remote side.\n\n"+
+		"Neither worked, so we gave up. You must fully qualify the ref.", dst, src)
+	return err
+}
+
+// matching 返回本地和远程都有的同名分支的更新（refspec ":"）
+func matching(gitDir, dir string) ([]Update, error) {
+	local, err := refs.List(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	var updates []Update
+	for _, ref := range local {
+		if !strings.HasPrefix(ref.Name, "refs/heads/") {
+			continue
+		}
+		old, err := refs.Resolve(dir, ref.Name)
+		if errors.Is(err, refs.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		updates = append(updates, Update{Src: ref.Name, Dst: ref.Name, Old: old, New: ref.Hash})
+	}
+	return updates, nil
+}
+
+// wildcard 返回匹配带 "*" 的 refspec 的所有本地引用的更新
+func wildcard(gitDir, dir string, spec remote.RefSpec) ([]Update, error) {
+	local, err := refs.List(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	var updates []Update
+	for _, ref := range local {
+		dst, ok := spec.Map(ref.Name)
+		if !ok {
+			continue
+		}
+		old, err := refs.Resolve(dir, dst)
+		if err != nil && !errors.Is(err, refs.ErrNotFound) {
+			return nil, err
+		}
+		updates = append(updates, Update{Src: ref.Name, Dst: dst, Old: old, New: ref.Hash})
+	}
+	return updates, nil
+}
+
+// check 判断更新是否允许：已有的标签不能移动，远程的值必须在本地并且是新值的祖先，force 时都允许
+func check(gitDir string, u *Update, force bool) error {
+	if u.Old.IsZero() || u.New.IsZero() || u.Old == u.New {
+		return nil
+	}
+	switch {
+	case strings.HasPrefix(u.Dst, "refs/tags/"):
+		u.Rejected = "already exists"
+	case !object.Exists(gitDir, u.Old):
+		u.Rejected = "fetch first"
+	default:
+		ff, err := revision.IsAncestor(gitDir, u.Old, u.New)
+		if err != nil {
+			return err
+		}
+		if ff {
+			return nil
+		}
+		u.Rejected = "non-fast-forward"
+	}
+	if force {
+		u.Rejected, u.Forced = "", true
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package push
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/hooks"
+	"geegit/beginner/day6-create-commit/refs"
+)
+
+// denyCurrentBranchMsg 是非裸仓库拒绝更新当前分支、且没有配置 receive.denyCurrentBranch 时 git 给出的说明
+const denyCurrentBranchMsg = `error: By default, updating the current branch in a non-bare repository
+is denied, because it will make the index and work tree inconsistent
+with what you pushed, and will require 'git reset --hard' to match
+the work tree to HEAD.
+
+You can set the 'receive.denyCurrentBranch' configuration variable
+to 'ignore' or 'warn' in the remote repository to allow pushing into
+its current branch; however, this is not recommended unless you
+arranged to update its work tree to match what you pushed in some
+other way.
+
+To squelch this message and still keep the default behaviour, set
+'receive.denyCurrentBranch' configuration variable to 'refuse'.
+`
+
+// receive 在接收端 gitDir 完成更新（git receive-pack），被拒绝的更新记录在 RemoteRejected 中
+//  1. pre-receive 拒绝时全部更新都被拒绝
+//  2. 逐个更新：非裸仓库的当前分支按 receive.denyCurrentBranch / receive.denyDeleteCurrent 检查，
+//     再运行 update 钩子，通过之后更新引用（reflog 原因 "push"）
+//  3. 对成功的更新运行 post-receive 和 post-update
+func receive(gitDir string, updates []*Update, opts Options) error {
+	out := opts.Remote
+	if out == nil {
+		out = os.Stderr
+	}
+	hookOpts := hooks.ReceiveOptions{PushOptions: opts.PushOptions, Output: out}
+	cmds := make([]hooks.RefUpdate, len(updates))
+	for i, u := range updates {
+		cmds[i] = hooks.RefUpdate{Name: u.Dst, Old: u.Old, New: u.New}
+	}
+
+	// 1. pre-receive
+	var exitErr *hooks.ExitError
+	if err := hooks.PreReceive(gitDir, cmds, hookOpts); errors.As(err, &exitErr) {
+		for _, u := range updates {
+			u.RemoteRejected = "pre-receive hook declined"
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// 2. 逐个更新
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return err
+	}
+	current := ""
+	if !cfg.Bool("core.bare", false) {
+		current, _ = refs.ResolveName(gitDir, "HEAD")
+	}
+	var done []hooks.RefUpdate
+	for i, u := range updates {
+		if u.Dst == current {
+			if u.RemoteRejected = denyCurrent(cfg, u, out); u.RemoteRejected != "" {
+				continue
+			}
+		}
+		if err := hooks.Update(gitDir, cmds[i], hookOpts); errors.As(err, &exitErr) {
+			fmt.Fprintf(out, "error: hook declined to update %s\n", u.Dst)
+			u.RemoteRejected = "hook declined"
+			continue
+		} else if err != nil {
+			return err
+		}
+		old := u.Old
+		if u.New.IsZero() {
+			err = refs.Delete(gitDir, u.Dst, &old)
+		} else {
+			err = refs.Update(gitDir, u.Dst, u.New, &old, "push")
+		}
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+			u.RemoteRejected = "failed to update ref"
+			continue
+		}
+		done = append(done, cmds[i])
+	}
+
+	// 3. post-receive 和 post-update
+	return hooks.PostReceive(gitDir, done, hookOpts)
+}
+
+// denyCurrent 检查对非裸仓库当前分支的更新，返回拒绝的原因，允许时返回空字符串
+// 更新按 receive.denyCurrentBranch（缺省拒绝并给出说明），删除按 receive.denyDeleteCurrent（缺省拒绝）
+func denyCurrent(cfg *config.Config, u *Update, out io.Writer) string {
+	if u.New.IsZero() {
+		v, ok := cfg.Get("receive.denyDeleteCurrent")
+		switch policy(v, ok) {
+		case "ignore":
+			return ""
+		case "warn":
+			fmt.Fprintf(out, "warning: deleting the current branch\n")
+			return ""
+		}
+		if !ok {
+			fmt.Fprintf(out, "error: By default, deleting the current branch is denied, because the next\n"+
+				"'git clone' won't result in any file checked out, causing confusion.\n\n"+
+				"You can set 'receive.denyDeleteCurrent' configuration variable to\n"+
+				"'warn' or 'ignore' in the remote repository to allow deleting the\n"+
+				"current branch, with or without a warning message.\n\n"+
+				"To squelch this message, you can set it to 'refuse'.\n")
+		}
+		fmt.Fprintf(out, "error: refusing to delete the current branch: %s\n", u.Dst)
+		return "deletion of the current branch prohibited"
+	}
+
+	v, ok := cfg.Get("receive.denyCurrentBranch")
+	switch policy(v, ok) {
+	case "ignore":
+		return ""
+	case "warn":
+		fmt.Fprintf(out, "warning: updating the current branch\n")
+		return ""
+	}
+	fmt.Fprintf(out, "error: refusing to update checked out branch: %s\n", u.Dst)
+	if !ok {
+		io.WriteString(out, denyCurrentBranchMsg)
+	}
+	return "branch is currently checked out"
+}
+
+// policy 把 receive.deny* 的值统一为 "refuse"、"warn" 或 "ignore"，没有配置时是 "refuse"
+// 布尔值 true 表示拒绝，false 表示允许；不认识的值按拒绝处理
+func policy(v string, ok bool) string {
+	if !ok {
+		return "refuse"
+	}
+	switch v = strings.ToLower(v); v {
+	case "warn", "ignore":
+		return v
+	}
+	if b, ok := config.ParseBool(v); ok && !b {
+		return "ignore"
+	}
+	return "refuse"
+}
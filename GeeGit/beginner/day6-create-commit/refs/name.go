@@ -0,0 +1,47 @@
+package refs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CheckName 按 `git check-ref-format` 的规则检查完整的引用名称
+//   - 至少包含一个 "/"，不能以 "/" 开头或结尾，不能有连续的 "/"
+//   - 每一段不能以 "." 开头，也不能以 ".lock" 结尾
+//   - 不能包含 ".."、"@{"、控制字符、空格以及 ~ ^ : ? * [ \ 中的任何字符
+//   - 不能以 "." 结尾，也不能是单独的 "@"
+func CheckName(name string) error {
+	bad := func() error { return fmt.Errorf("'%s' is not a valid ref name", name) }
+
+	if name == "" || name == "@" || !strings.Contains(name, "/") {
+		return bad()
+	}
+	if strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") {
+		return bad()
+	}
+	if strings.Contains(name, "//") || strings.Contains(name, "..") || strings.Contains(name, "@{") {
+		return bad()
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c < 0x20 || c == 0x7f || strings.IndexByte(" ~^:?*[\\", c) >= 0 {
+			return bad()
+		}
+	}
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || strings.HasSuffix(part, ".lock") {
+			return bad()
+		}
+	}
+	return nil
+}
+
+// ShortName 返回引用的简短名称，例如 refs/heads/main -> main、refs/remotes/origin/main -> origin/main
+func ShortName(name string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/remotes/", "refs/"} {
+		if short, ok := strings.CutPrefix(name, prefix); ok {
+			return short
+		}
+	}
+	return name
+}
@@ -0,0 +1,196 @@
+package refs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/lockfile"
+)
+
+// LogEntry 是 reflog 中的一条记录
+// 文件格式: "<old> <new> <name> <<email>> <timestamp> <tz>\t<message>\n"
+type LogEntry struct {
+	Old       hash.Hash
+	New       hash.Hash
+	Committer commit.Signature
+	Message   string
+}
+
+// LogPath 返回引用的 reflog 文件路径，例如 .git/logs/refs/heads/main
+func LogPath(gitDir, name string) string {
+	return gitdir.Path(gitDir, "logs/"+name)
+}
+
+// shouldLog 判断引用更新时是否需要写 reflog
+// 与 core.logAllRefUpdates=true（非裸仓库的默认值）一致：HEAD、分支、远程跟踪分支和 notes 总是记录，
+// 其他引用只有在 reflog 文件已经存在时才记录
+func shouldLog(gitDir, name string) bool {
+	if name == "HEAD" || strings.HasPrefix(name, "refs/heads/") ||
+		strings.HasPrefix(name, "refs/remotes/") || strings.HasPrefix(name, "refs/notes/") {
+		return true
+	}
+	_, err := os.Stat(LogPath(gitDir, name))
+	return err == nil
+}
+
+// AppendLog 在引用的 reflog 末尾追加一条记录
+func AppendLog(gitDir, name string, entry LogEntry) error {
+	p := LogPath(gitDir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return fmt.Errorf("unable to create directory for '%s': %v", p, err)
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to append to '%s': %v", p, err)
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(formatLogLine(entry))
+	return err
+}
+
+// formatLogLine 格式化一条 reflog 记录
+// 与 git 一致，消息中连续的空白（包括换行）合并为一个空格，并去掉首尾空白；没有消息时省略制表符
+func formatLogLine(entry LogEntry) string {
+	line := fmt.Sprintf("%s %s %s", entry.Old.String(), entry.New.String(), commit.FormatSignature(entry.Committer))
+	if msg := strings.Join(strings.Fields(entry.Message), " "); msg != "" {
+		line += "\t" + msg
+	}
+	return line + "\n"
+}
+
+// CreateLog 为引用创建空的 reflog（如果还不存在），之后该引用的每次更新都会记录 reflog
+// 用于 refs/stash 这类默认不记录 reflog 的引用
+func CreateLog(gitDir, name string) error {
+	p := LogPath(gitDir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// WriteLog 用 entries 替换引用的整个 reflog（例如删除 stash 中的一项之后）
+func WriteLog(gitDir, name string, entries []LogEntry) error {
+	lock, err := lockfile.Acquire(LogPath(gitDir, name))
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+	for _, entry := range entries {
+		if _, err := lock.Write([]byte(formatLogLine(entry))); err != nil {
+			return err
+		}
+	}
+	return lock.Commit()
+}
+
+// ReadLog 读取引用的 reflog，按时间从旧到新排列；没有 reflog 时返回空列表
+func ReadLog(gitDir, name string) ([]LogEntry, error) {
+	f, err := os.Open(LogPath(gitDir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []LogEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		entry, err := parseLogLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reflog line in %s: %v", name, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// hexLen 是十六进制哈希的长度
+const hexLen = 2 * len(hash.Hash{})
+
+func parseLogLine(line string) (LogEntry, error) {
+	header, msg, _ := strings.Cut(line, "\t")
+	if len(header) < 2*hexLen+2 {
+		return LogEntry{}, fmt.Errorf("line too short")
+	}
+	oldHash, err := hash.ParseHash(header[:hexLen])
+	if err != nil {
+		return LogEntry{}, err
+	}
+	newHash, err := hash.ParseHash(header[hexLen+1 : 2*hexLen+1])
+	if err != nil {
+		return LogEntry{}, err
+	}
+	sig, err := commit.ParseSignature(header[2*hexLen+2:])
+	if err != nil {
+		return LogEntry{}, err
+	}
+	return LogEntry{Old: oldHash, New: newHash, Committer: sig, Message: msg}, nil
+}
+
+// DeleteLog 删除引用的 reflog，并清理空的父目录
+func DeleteLog(gitDir, name string) error {
+	p := LogPath(gitDir, name)
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	removeEmptyParents(filepath.Join(gitdir.Root(gitDir, "logs/"+name), "logs"), name)
+	return nil
+}
+
+// logUpdate 为一次引用更新写 reflog
+// 如果 HEAD 是指向该引用的符号引用（即更新的是当前分支），HEAD 的 reflog 也会记录一条
+func logUpdate(gitDir, name string, oldHash, newHash hash.Hash, msg string) error {
+	logHead := false
+	if name != "HEAD" {
+		if target, err := ResolveName(gitDir, "HEAD"); err == nil && target == name {
+			logHead = true
+		}
+	}
+	if !shouldLog(gitDir, name) && !logHead {
+		return nil
+	}
+
+	committer, err := commit.DefaultSignature(gitDir, "committer")
+	if err != nil {
+		return err
+	}
+	entry := LogEntry{Old: oldHash, New: newHash, Committer: committer, Message: msg}
+	if shouldLog(gitDir, name) {
+		if err := AppendLog(gitDir, name, entry); err != nil {
+			return err
+		}
+	}
+	if logHead {
+		return AppendLog(gitDir, "HEAD", entry)
+	}
+	return nil
+}
+
+// removeEmptyParents 删除引用 name 在 root 下留下的空父目录
+// 与 git 一致，保留前两级目录（例如 refs/heads）
+func removeEmptyParents(root, name string) {
+	parts := strings.Split(name, "/")
+	for i := len(parts) - 1; i > 2; i-- {
+		if os.Remove(filepath.Join(root, filepath.FromSlash(strings.Join(parts[:i], "/")))) != nil {
+			return
+		}
+	}
+}
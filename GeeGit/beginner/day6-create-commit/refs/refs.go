@@ -0,0 +1,196 @@
+package refs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/hash"
+)
+
+// ErrNotFound 表示引用不存在
+var ErrNotFound = errors.New("reference not found")
+
+// Ref 表示一个引用
+type Ref struct {
+	Name   string    // 完整名称，例如 "refs/heads/main"
+	Hash   hash.Hash // 指向的对象
+	Target string    // 符号引用的目标（例如 HEAD -> refs/heads/main），普通引用为空
+}
+
+// maxSymrefDepth 是解析符号引用链的最大深度，防止循环
+const maxSymrefDepth = 5
+
+// Read 读取一个引用，自动跟随符号引用
+// 先查找 .git/<name> 文件，再查找 packed-refs
+func Read(gitDir, name string) (*Ref, error) {
+	ref := &Ref{Name: name}
+	current := name
+
+	for depth := 0; depth < maxSymrefDepth; depth++ {
+		data, err := os.ReadFile(gitdir.Path(gitDir, current))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return nil, err
+			}
+			// 松散引用不存在，查找 packed-refs
+			packed, err := readPacked(gitDir)
+			if err != nil {
+				return nil, err
+			}
+			h, ok := packed[current]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrNotFound, current)
+			}
+			ref.Hash = h
+			return ref, nil
+		}
+
+		content := strings.TrimSpace(string(data))
+		if target, ok := strings.CutPrefix(content, "ref: "); ok {
+			// 符号引用：记录第一层目标，继续解析
+			if ref.Target == "" {
+				ref.Target = target
+			}
+			current = target
+			continue
+		}
+
+		h, err := hash.ParseHash(content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference %s: %v", current, err)
+		}
+		ref.Hash = h
+		return ref, nil
+	}
+
+	return nil, fmt.Errorf("reference %s: too many levels of symbolic refs", name)
+}
+
+// Resolve 将名称解析为对象哈希
+// 支持 40 位哈希、HEAD、完整引用名，以及 git 的简写规则（main -> refs/heads/main 等）
+func Resolve(gitDir, name string) (hash.Hash, error) {
+	if h, err := hash.ParseHash(name); err == nil {
+		return h, nil
+	}
+	full, err := Expand(gitDir, name)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	ref, err := Read(gitDir, full)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	return ref.Hash, nil
+}
+
+// Expand 按 git 的简写规则返回名称对应的、已存在的完整引用名，例如 stash -> refs/stash
+func Expand(gitDir, name string) (string, error) {
+	for _, candidate := range []string{
+		name,
+		"refs/" + name,
+		"refs/tags/" + name,
+		"refs/heads/" + name,
+		"refs/remotes/" + name,
+		"refs/remotes/" + name + "/HEAD",
+	} {
+		_, err := Read(gitDir, candidate)
+		if err == nil {
+			return candidate, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// List 列出 refs/ 下的所有引用（松散引用优先于 packed-refs），按名称排序
+func List(gitDir string) ([]Ref, error) {
+	all, err := readPacked(gitDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// 共享的引用在公共目录中，refs/bisect/ 等属于当前工作区的引用在 gitDir 中
+	roots := []string{gitdir.CommonDir(gitDir)}
+	if roots[0] != gitDir {
+		roots = append(roots, gitDir)
+	}
+	for _, root := range roots {
+		err = filepath.Walk(filepath.Join(root, "refs"), func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if info.IsDir() || strings.HasSuffix(p, ".lock") {
+				return nil
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			name := filepath.ToSlash(rel)
+			if gitdir.Root(gitDir, name) != root {
+				// 其他工作区的私有引用
+				return nil
+			}
+			ref, err := Read(gitDir, name)
+			if err != nil {
+				return err
+			}
+			all[ref.Name] = ref.Hash
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]Ref, 0, len(all))
+	for name, h := range all {
+		result = append(result, Ref{Name: name, Hash: h})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// readPacked 读取 .git/packed-refs
+// 格式: 每行 "<hash> <refname>"，以 "#" 开头的是注释，以 "^" 开头的是上一个标签剥离后的对象
+func readPacked(gitDir string) (map[string]hash.Hash, error) {
+	result := make(map[string]hash.Hash)
+
+	f, err := os.Open(gitdir.Path(gitDir, "packed-refs"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+		hexStr, name, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid packed-refs line: %q", line)
+		}
+		h, err := hash.ParseHash(hexStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid packed-refs line: %q", line)
+		}
+		result[name] = h
+	}
+	return result, scanner.Err()
+}
@@ -0,0 +1,326 @@
+package refs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/lockfile"
+)
+
+// ResolveName 跟随符号引用，返回最终被写入的引用名称
+// 例如 HEAD -> refs/heads/main，即使 refs/heads/main 还不存在
+func ResolveName(gitDir, name string) (string, error) {
+	for depth := 0; depth < maxSymrefDepth; depth++ {
+		data, err := os.ReadFile(gitdir.Path(gitDir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return name, nil
+			}
+			return "", err
+		}
+		target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "ref: ")
+		if !ok {
+			return name, nil
+		}
+		name = target
+	}
+	return "", fmt.Errorf("reference %s: too many levels of symbolic refs", name)
+}
+
+// Update 原子地更新引用（会跟随符号引用），并写入 reflog
+// oldHash 不为 nil 时，只有引用的当前值等于 *oldHash 才会更新；零哈希表示引用必须不存在
+// msg 是 reflog 中记录的原因，例如 "branch: Created from HEAD"
+func Update(gitDir, name string, newHash hash.Hash, oldHash *hash.Hash, msg string) error {
+	name, err := ResolveName(gitDir, name)
+	if err != nil {
+		return err
+	}
+	if name != "HEAD" {
+		if err := CheckName(name); err != nil {
+			return err
+		}
+	}
+
+	previous, err := write(gitDir, name, newHash, oldHash)
+	if err != nil {
+		return err
+	}
+	return logUpdate(gitDir, name, previous, newHash, msg)
+}
+
+// write 在锁的保护下写入引用文件，返回引用原来的值（不存在时为零哈希）
+func write(gitDir, name string, newHash hash.Hash, oldHash *hash.Hash) (hash.Hash, error) {
+	lock, err := lockfile.Acquire(gitdir.Path(gitDir, name))
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	defer lock.Rollback()
+
+	if err := verifyOld(gitDir, name, oldHash); err != nil {
+		return hash.Hash{}, err
+	}
+	var previous hash.Hash
+	if ref, err := Read(gitDir, name); err == nil {
+		previous = ref.Hash
+	}
+	if _, err := lock.Write([]byte(newHash.String() + "\n")); err != nil {
+		return hash.Hash{}, err
+	}
+	return previous, lock.Commit()
+}
+
+// Delete 删除引用及其 reflog，同时从 packed-refs 中移除
+func Delete(gitDir, name string, oldHash *hash.Hash) error {
+	name, err := ResolveName(gitDir, name)
+	if err != nil {
+		return err
+	}
+
+	refPath := gitdir.Path(gitDir, name)
+	lock, err := lockfile.Acquire(refPath)
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+
+	if err := verifyOld(gitDir, name, oldHash); err != nil {
+		return err
+	}
+	if err := removePacked(gitDir, name); err != nil {
+		return err
+	}
+	if err := os.Remove(refPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	removeEmptyParents(gitdir.Root(gitDir, name), name)
+	return DeleteLog(gitDir, name)
+}
+
+// DeleteSymbolic 删除符号引用本身（不跟随到它指向的引用），例如 refs/remotes/origin/HEAD
+func DeleteSymbolic(gitDir, name string) error {
+	refPath := gitdir.Path(gitDir, name)
+	lock, err := lockfile.Acquire(refPath)
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+
+	if err := os.Remove(refPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	removeEmptyParents(gitdir.Root(gitDir, name), name)
+	return DeleteLog(gitDir, name)
+}
+
+// Rename 将引用 oldName 重命名为 newName，reflog 随之移动
+// HEAD 指向 oldName 时会改为指向 newName
+func Rename(gitDir, oldName, newName, msg string) error {
+	if err := CheckName(newName); err != nil {
+		return err
+	}
+	ref, err := Read(gitDir, oldName)
+	if err != nil {
+		return err
+	}
+	if _, err := Read(gitDir, newName); err == nil {
+		return fmt.Errorf("a branch named '%s' already exists", strings.TrimPrefix(newName, "refs/heads/"))
+	}
+	headTarget, _ := ResolveName(gitDir, "HEAD")
+
+	// 1. 先保留旧的 reflog，删除旧引用
+	logs, err := os.ReadFile(LogPath(gitDir, oldName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := Delete(gitDir, oldName, &ref.Hash); err != nil {
+		return err
+	}
+
+	// 2. 恢复 reflog 到新名称下，再创建新引用
+	if logs != nil {
+		p := LogPath(gitDir, newName)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, logs, 0644); err != nil {
+			return err
+		}
+	}
+	zero := hash.Hash{}
+	if _, err := write(gitDir, newName, ref.Hash, &zero); err != nil {
+		return err
+	}
+	if err := logUpdate(gitDir, newName, ref.Hash, ref.Hash, msg); err != nil {
+		return err
+	}
+
+	// 3. HEAD 跟随重命名；与 git 一致，HEAD 的 reflog 记录一次删除和一次创建
+	if headTarget == oldName {
+		if err := SetSymbolic(gitDir, "HEAD", newName, ""); err != nil {
+			return err
+		}
+		committer, err := commit.DefaultSignature(gitDir, "committer")
+		if err != nil {
+			return err
+		}
+		for _, entry := range []LogEntry{
+			{Old: ref.Hash, Committer: committer, Message: msg},
+			{New: ref.Hash, Committer: committer, Message: msg},
+		} {
+			if err := AppendLog(gitDir, "HEAD", entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SetSymbolic 将 name 设置为指向 target 的符号引用，例如 HEAD -> refs/heads/main
+// msg 不为空时在 name 的 reflog 中记录一条从原来指向的 commit 到 target 指向的 commit 的记录
+func SetSymbolic(gitDir, name, target, msg string) error {
+	var oldHash, newHash hash.Hash
+	if ref, err := Read(gitDir, name); err == nil {
+		oldHash = ref.Hash
+	}
+	if ref, err := Read(gitDir, target); err == nil {
+		newHash = ref.Hash
+	}
+
+	lock, err := lockfile.Acquire(gitdir.Path(gitDir, name))
+	if err != nil {
+		return err
+	}
+	if _, err := lock.Write([]byte("ref: " + target + "\n")); err != nil {
+		lock.Rollback()
+		return err
+	}
+	if err := lock.Commit(); err != nil {
+		return err
+	}
+	if msg == "" {
+		return nil
+	}
+	committer, err := commit.DefaultSignature(gitDir, "committer")
+	if err != nil {
+		return err
+	}
+	return AppendLog(gitDir, name, LogEntry{Old: oldHash, New: newHash, Committer: committer, Message: msg})
+}
+
+// Detach 让 HEAD 直接指向 commit（分离 HEAD），不会修改 HEAD 原来指向的分支
+func Detach(gitDir string, h hash.Hash, msg string) error {
+	previous, err := write(gitDir, "HEAD", h, nil)
+	if err != nil {
+		return err
+	}
+	return logUpdate(gitDir, "HEAD", previous, h, msg)
+}
+
+// verifyOld 检查引用的当前值是否符合预期
+func verifyOld(gitDir, name string, oldHash *hash.Hash) error {
+	if oldHash == nil {
+		return nil
+	}
+	current, err := Read(gitDir, name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	switch {
+	case err != nil && !oldHash.IsZero():
+		return fmt.Errorf("cannot lock ref '%s': unable to resolve reference", name)
+	case err == nil && current.Hash != *oldHash:
+		return fmt.Errorf("cannot lock ref '%s': is at %s but expected %s",
+			name, current.Hash.String(), oldHash.String())
+	}
+	return nil
+}
+
+// removePacked 从 packed-refs 中删除一个引用（连同其后的剥离行）
+func removePacked(gitDir, name string) error {
+	packedPath := gitdir.Path(gitDir, "packed-refs")
+	f, err := os.Open(packedPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var kept []string
+	found, skipPeeled := false, false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if skipPeeled && strings.HasPrefix(line, "^") {
+			continue
+		}
+		skipPeeled = false
+		if strings.HasSuffix(line, " "+name) && !strings.HasPrefix(line, "#") {
+			found, skipPeeled = true, true
+			continue
+		}
+		kept = append(kept, line)
+	}
+	f.Close()
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if !found {
+		return nil
+	}
+
+	lock, err := lockfile.Acquire(packedPath)
+	if err != nil {
+		return err
+	}
+	if _, err := lock.Write([]byte(strings.Join(kept, "\n") + "\n")); err != nil {
+		lock.Rollback()
+		return err
+	}
+	return lock.Commit()
+}
+
+// WritePacked 把引用直接写入 packed-refs，不写 reflog
+// 与 git clone 一致，克隆时一次性得到的远程跟踪引用和标签都保存在 packed-refs 中
+func WritePacked(gitDir string, list []Ref) error {
+	packedPath := gitdir.Path(gitDir, "packed-refs")
+	lock, err := lockfile.Acquire(packedPath)
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+
+	all, err := readPacked(gitDir)
+	if err != nil {
+		return err
+	}
+	for _, r := range list {
+		if err := CheckName(r.Name); err != nil {
+			return err
+		}
+		all[r.Name] = r.Hash
+	}
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("# pack-refs with: sorted \n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s %s\n", all[name], name)
+	}
+	if _, err := lock.Write([]byte(b.String())); err != nil {
+		return err
+	}
+	return lock.Commit()
+}
@@ -0,0 +1,63 @@
+package remote
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RefSpec 是一条引用映射规则，例如 "+refs/heads/*:refs/remotes/origin/*"
+// 源和目标中最多各有一个 "*"，匹配时 "*" 可以代表任意字符串（包括 "/"）
+type RefSpec struct {
+	Force bool   // 以 "+" 开头，允许非快进更新
+	Src   string // 源引用（fetch 时是远程的引用）
+	Dst   string // 目标引用（fetch 时是本地的远程跟踪引用），可以为空
+}
+
+// ParseRefSpec 解析一条 refspec
+func ParseRefSpec(s string) (RefSpec, error) {
+	var r RefSpec
+	if strings.HasPrefix(s, "+") {
+		r.Force = true
+		s = s[1:]
+	}
+	r.Src, r.Dst, _ = strings.Cut(s, ":")
+	if strings.Count(r.Src, "*") > 1 || strings.Count(r.Dst, "*") > 1 ||
+		(r.Dst != "" && strings.Contains(r.Src, "*") != strings.Contains(r.Dst, "*")) {
+		return RefSpec{}, fmt.Errorf("invalid refspec '%s'", s)
+	}
+	return r, nil
+}
+
+// String 返回 refspec 的文本形式
+func (r RefSpec) String() string {
+	s := r.Src
+	if r.Dst != "" {
+		s += ":" + r.Dst
+	}
+	if r.Force {
+		s = "+" + s
+	}
+	return s
+}
+
+// Map 把符合源模式的引用名映射为目标引用名
+func (r RefSpec) Map(name string) (string, bool) {
+	return mapPattern(r.Src, r.Dst, name)
+}
+
+// Reverse 把符合目标模式的引用名反向映射为源引用名（例如由远程跟踪分支得到远程分支）
+func (r RefSpec) Reverse(name string) (string, bool) {
+	return mapPattern(r.Dst, r.Src, name)
+}
+
+func mapPattern(from, to, name string) (string, bool) {
+	prefix, suffix, wildcard := strings.Cut(from, "*")
+	if !wildcard {
+		return to, name == from
+	}
+	if len(name) < len(prefix)+len(suffix) || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return "", false
+	}
+	matched := name[len(prefix) : len(name)-len(suffix)]
+	return strings.Replace(to, "*", matched, 1), true
+}
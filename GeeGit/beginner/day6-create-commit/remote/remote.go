@@ -0,0 +1,240 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/refs"
+)
+
+// ErrNotFound 表示配置中没有该远程
+var ErrNotFound = errors.New("no such remote")
+
+// Remote 是配置中 [remote "<name>"] 定义的一个远程仓库
+type Remote struct {
+	Name     string
+	URLs     []string  // remote.<name>.url，已按 url.<base>.insteadOf 改写
+	PushURLs []string  // push 使用的地址：remote.<name>.pushurl，没有配置时由 url 得到
+	Fetch    []RefSpec // remote.<name>.fetch
+}
+
+// FetchURL 返回 fetch 使用的地址（第一个 url）
+func (r *Remote) FetchURL() string {
+	if len(r.URLs) == 0 {
+		return ""
+	}
+	return r.URLs[0]
+}
+
+// TrackingRef 按 fetch refspec 把远程引用映射为本地的远程跟踪引用，例如
+// refs/heads/main -> refs/remotes/origin/main；没有匹配的 refspec 时返回空字符串
+func (r *Remote) TrackingRef(name string) string {
+	for _, spec := range r.Fetch {
+		if dst, ok := spec.Map(name); ok && dst != "" {
+			return dst
+		}
+	}
+	return ""
+}
+
+// List 按在配置中首次出现的顺序返回所有远程
+func List(cfg *config.Config) []*Remote {
+	var names []string
+	seen := make(map[string]bool)
+	for _, e := range cfg.Entries {
+		if e.Section == "remote" && e.Subsection != "" && !seen[e.Subsection] {
+			seen[e.Subsection] = true
+			names = append(names, e.Subsection)
+		}
+	}
+
+	remotes := make([]*Remote, 0, len(names))
+	for _, name := range names {
+		remotes = append(remotes, build(cfg, name))
+	}
+	return remotes
+}
+
+// Get 返回名为 name 的远程
+func Get(cfg *config.Config, name string) (*Remote, error) {
+	for _, e := range cfg.Entries {
+		if e.Section == "remote" && e.Subsection == name {
+			return build(cfg, name), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: '%s'", ErrNotFound, name)
+}
+
+// build 从配置中读取远程 name 的定义
+// push 地址先按 url.<base>.pushInsteadOf 改写原始地址，没有匹配时再按 insteadOf 改写
+func build(cfg *config.Config, name string) *Remote {
+	r := &Remote{Name: name}
+	urls := cfg.GetAll("remote." + name + ".url")
+	for _, u := range urls {
+		r.URLs = append(r.URLs, rewriteURL(cfg, u, "insteadof"))
+	}
+	for _, u := range cfg.GetAll("remote." + name + ".pushurl") {
+		r.PushURLs = append(r.PushURLs, rewriteURL(cfg, u, "insteadof"))
+	}
+	if len(r.PushURLs) == 0 {
+		for _, u := range urls {
+			rewritten := rewriteURL(cfg, u, "pushinsteadof")
+			if rewritten == u {
+				rewritten = rewriteURL(cfg, u, "insteadof")
+			}
+			r.PushURLs = append(r.PushURLs, rewritten)
+		}
+	}
+	for _, s := range cfg.GetAll("remote." + name + ".fetch") {
+		if spec, err := ParseRefSpec(s); err == nil {
+			r.Fetch = append(r.Fetch, spec)
+		}
+	}
+	return r
+}
+
+// rewriteURL 按 url.<base>.<key>（insteadOf 或 pushInsteadOf）改写地址，多个规则匹配时使用最长的前缀
+func rewriteURL(cfg *config.Config, u, key string) string {
+	best, base := "", ""
+	for _, e := range cfg.Entries {
+		if e.Section != "url" || e.Key != key || e.Subsection == "" {
+			continue
+		}
+		if strings.HasPrefix(u, e.Value) && len(e.Value) > len(best) {
+			best, base = e.Value, e.Subsection
+		}
+	}
+	if best == "" {
+		return u
+	}
+	return base + u[len(best):]
+}
+
+// CheckName 检查远程名称是否可用（需要能构成合法的 refs/remotes/<name>/... 引用）
+func CheckName(name string) error {
+	if name == "" || refs.CheckName("refs/remotes/"+name+"/test") != nil {
+		return fmt.Errorf("'%s' is not a valid remote name", name)
+	}
+	return nil
+}
+
+// Add 在 .git/config 中添加远程，fetch refspec 为默认的 +refs/heads/*:refs/remotes/<name>/*
+func Add(gitDir, name, url string) error {
+	if err := CheckName(name); err != nil {
+		return err
+	}
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return err
+	}
+	if _, err := Get(cfg, name); err == nil {
+		return fmt.Errorf("remote %s already exists.", name)
+	}
+
+	file := gitdir.Path(gitDir, "config")
+	if err := config.SetValue(file, "remote."+name+".url", url); err != nil {
+		return err
+	}
+	spec := RefSpec{Force: true, Src: "refs/heads/*", Dst: "refs/remotes/" + name + "/*"}
+	return config.AddValue(file, "remote."+name+".fetch", spec.String())
+}
+
+// Remove 删除远程：它的配置、全部远程跟踪引用，以及以它为上游的分支配置
+func Remove(gitDir, name string) error {
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return err
+	}
+	r, err := Get(cfg, name)
+	if err != nil {
+		return err
+	}
+
+	// 1. 以该远程为上游的分支不再跟踪任何分支
+	file := gitdir.Path(gitDir, "config")
+	for _, e := range cfg.Entries {
+		if e.Scope != config.ScopeLocal || e.Section != "branch" || e.Key != "remote" || e.Value != name {
+			continue
+		}
+		for _, key := range []string{"remote", "merge"} {
+			if err := config.Unset(file, "branch."+e.Subsection+"."+key); err != nil {
+				return err
+			}
+		}
+		if err := removeIfEmpty(file, "branch."+e.Subsection); err != nil {
+			return err
+		}
+	}
+
+	// 2. 删除远程跟踪引用
+	all, err := refs.List(gitDir)
+	if err != nil {
+		return err
+	}
+	for _, ref := range all {
+		if !isTrackingRef(r, ref.Name) {
+			continue
+		}
+		// refs/remotes/<name>/HEAD 是符号引用，只删除它本身
+		if sym, err := refs.Read(gitDir, ref.Name); err == nil && sym.Target != "" {
+			err = refs.DeleteSymbolic(gitDir, ref.Name)
+		} else {
+			err = refs.Delete(gitDir, ref.Name, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// 3. 删除远程的配置
+	return config.RemoveSection(file, "remote."+name)
+}
+
+// isTrackingRef 判断 ref 是否是远程 r 的 fetch refspec 映射出来的远程跟踪引用
+func isTrackingRef(r *Remote, ref string) bool {
+	for _, spec := range r.Fetch {
+		if _, ok := spec.Reverse(ref); ok && spec.Dst != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// removeIfEmpty 在节中已经没有任何键时删除节头
+func removeIfEmpty(file, section string) error {
+	cfg, err := config.LoadFile(file)
+	if err != nil {
+		return err
+	}
+	name, subsection, _ := strings.Cut(section, ".")
+	for _, e := range cfg.Entries {
+		if e.Section == name && e.Subsection == subsection {
+			return nil
+		}
+	}
+	return config.RemoveSection(file, section)
+}
+
+// SetURL 修改远程的地址
+// push 为 true 时修改 pushurl；add 为 true 时追加一个地址而不是替换已有的地址
+func SetURL(gitDir, name, url string, push, add bool) error {
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return err
+	}
+	if _, err := Get(cfg, name); err != nil {
+		return err
+	}
+	key := "remote." + name + ".url"
+	if push {
+		key = "remote." + name + ".pushurl"
+	}
+	file := gitdir.Path(gitDir, "config")
+	if add {
+		return config.AddValue(file, key, url)
+	}
+	return config.SetValue(file, key, url)
+}
@@ -0,0 +1,251 @@
+package repack
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/pack"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/tag"
+	"geegit/beginner/day6-create-commit/tree"
+	"geegit/beginner/day6-create-commit/worktree"
+)
+
+// Options 控制 repack 的方式
+type Options struct {
+	All    bool // -a：把所有可达对象写入一个新的 packfile，取代原有的 packfile
+	Delete bool // -d：删除被新 packfile 取代的 packfile 和松散对象
+	Local  bool // -l：不打包只存在于 alternates 中的对象
+}
+
+// Repack 把仓库的对象重新打包（git repack），返回写入新 packfile 的对象数，0 表示没有需要打包的对象
+//  1. 从所有引用、各工作区的 HEAD、reflog 和索引出发遍历可达对象；不存在的对象（浅克隆边界之外、
+//     部分克隆中省略的）被跳过
+//  2. 不带 All 时只打包松散对象；带 All 时打包所有可达对象，包括 alternates 中的（Local 时除外），
+//     这样再删除 alternates 文件也不会缺少对象。promisor packfile 中的对象不重新打包
+//  3. 带 Delete 时删除被取代的 packfile（只在带 All 时）和已经打包的松散对象；
+//     multi-pack-index 收录了被删除的 packfile 时一并删除
+func Repack(gitDir string, opts Options) (int, error) {
+	packs, err := pack.OpenAll(gitDir)
+	if err != nil {
+		return 0, err
+	}
+	local := func(h hash.Hash) bool {
+		if _, err := os.Stat(object.LoosePath(gitDir, h)); err == nil {
+			return true
+		}
+		for _, p := range packs {
+			if p.Contains(h) {
+				return true
+			}
+		}
+		return false
+	}
+	promised := func(h hash.Hash) bool {
+		for _, p := range packs {
+			if p.IsPromisor() && p.Contains(h) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// 1. 遍历可达对象
+	roots, err := roots(gitDir)
+	if err != nil {
+		return 0, err
+	}
+	var entries []pack.Entry
+	seen := make(map[hash.Hash]bool)
+	stack := roots
+	for len(stack) > 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[h] || !object.Exists(gitDir, h) {
+			continue
+		}
+		seen[h] = true
+		obj, err := object.Read(gitDir, h)
+		if err != nil {
+			return 0, err
+		}
+		switch obj.Type {
+		case hash.CommitObject:
+			c, err := commit.ParseCommit(obj.Content)
+			if err != nil {
+				return 0, fmt.Errorf("commit %s: %v", h, err)
+			}
+			stack = append(stack, c.Tree)
+			stack = append(stack, c.Parents...)
+		case hash.TreeObject:
+			list, err := tree.ParseEntries(obj.Content)
+			if err != nil {
+				return 0, fmt.Errorf("tree %s: %v", h, err)
+			}
+			for _, e := range list {
+				if !e.IsSubmodule() {
+					stack = append(stack, e.Hash)
+				}
+			}
+		case hash.TagObject:
+			t, err := tag.ParseTag(obj.Content)
+			if err != nil {
+				return 0, fmt.Errorf("tag %s: %v", h, err)
+			}
+			stack = append(stack, t.Object)
+		}
+
+		// 2. 选择要打包的对象
+		switch {
+		case promised(h):
+			continue
+		case !opts.All:
+			if _, err := os.Stat(object.LoosePath(gitDir, h)); err != nil {
+				continue
+			}
+		case opts.Local && !local(h):
+			continue
+		}
+		entries = append(entries, pack.Entry{Hash: h, Type: obj.Type, Content: obj.Content})
+	}
+
+	var written hash.Hash
+	if len(entries) > 0 {
+		if written, err = pack.Write(gitDir, entries, false); err != nil {
+			return 0, err
+		}
+	}
+	if !opts.Delete {
+		return len(entries), nil
+	}
+
+	// 3. 删除被取代的 packfile 和松散对象
+	if opts.All {
+		var removed []string
+		for _, p := range packs {
+			base := strings.TrimSuffix(p.Path, ".pack")
+			if p.IsPromisor() || filepath.Base(base) == "pack-"+written.String() {
+				continue
+			}
+			if _, err := os.Stat(base + ".keep"); err == nil {
+				continue
+			}
+			for _, ext := range []string{".idx", ".pack", ".bitmap", ".rev"} {
+				if err := os.Remove(base + ext); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return 0, err
+				}
+			}
+			removed = append(removed, filepath.Base(base)+".idx")
+		}
+		if err := removeMidx(gitDir, removed); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), prunePacked(gitDir)
+}
+
+// roots 返回遍历可达对象的起点：所有引用，各工作区的 HEAD、reflog 和索引中的对象
+func roots(gitDir string) ([]hash.Hash, error) {
+	var list []hash.Hash
+	addLog := func(dir, name string) error {
+		entries, err := refs.ReadLog(dir, name)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			for _, h := range []hash.Hash{e.Old, e.New} {
+				if !h.IsZero() {
+					list = append(list, h)
+				}
+			}
+		}
+		return nil
+	}
+
+	all, err := refs.List(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range all {
+		list = append(list, r.Hash)
+		if err := addLog(gitDir, r.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	worktrees, err := worktree.List(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range worktrees {
+		if w.Prunable != "" {
+			continue
+		}
+		if !w.Head.IsZero() {
+			list = append(list, w.Head)
+		}
+		if err := addLog(w.GitDir, "HEAD"); err != nil {
+			return nil, err
+		}
+		idx, err := index.Read(w.GitDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range idx.Entries {
+			if e.Mode != 0160000 {
+				list = append(list, e.Hash)
+			}
+		}
+	}
+	return list, nil
+}
+
+// removeMidx 在 multi-pack-index 收录了被删除的 packfile 或者已经损坏时删除它（以及它的位图）
+func removeMidx(gitDir string, removed []string) error {
+	m, err := pack.OpenMultiPackIndex(gitDir)
+	if err != nil {
+		return pack.RemoveMultiPackIndex(gitDir)
+	}
+	if m == nil {
+		return nil
+	}
+	for _, name := range removed {
+		if _, ok := m.FindPack(name); ok {
+			return pack.RemoveMultiPackIndex(gitDir)
+		}
+	}
+	return nil
+}
+
+// prunePacked 删除已经存在于本仓库 packfile 中的松散对象（git prune-packed）
+func prunePacked(gitDir string) error {
+	packs, err := pack.OpenAll(gitDir)
+	if err != nil {
+		return err
+	}
+	loose, err := object.ListLoose(gitDir)
+	if err != nil {
+		return err
+	}
+	for _, h := range loose {
+		for _, p := range packs {
+			if !p.Contains(h) {
+				continue
+			}
+			path := object.LoosePath(gitDir, h)
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			os.Remove(filepath.Dir(path)) // 目录为空时一并删除
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotRepository 表示从当前目录向上找不到 .git 目录
+var ErrNotRepository = errors.New("not a git repository (or any of the parent directories): .git")
+
+// Discover 从 start 目录开始向上查找仓库
+// 返回 .git 目录和工作区根目录；设置了 GIT_DIR 环境变量时直接使用它
+func Discover(start string) (gitDir, workDir string, err error) {
+	if env := os.Getenv("GIT_DIR"); env != "" {
+		gitDir, err = filepath.Abs(env)
+		if err != nil {
+			return "", "", err
+		}
+		workDir = os.Getenv("GIT_WORK_TREE")
+		if workDir == "" {
+			workDir, err = filepath.Abs(".")
+		}
+		return gitDir, workDir, err
+	}
+
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return "", "", err
+	}
+	for {
+		candidate := filepath.Join(dir, ".git")
+		if info, err := os.Stat(candidate); err == nil {
+			if info.IsDir() {
+				return candidate, dir, nil
+			}
+			// 链接工作区和子模块的 .git 是一个文件，内容为 "gitdir: <路径>"
+			gitDir, err := ReadGitFile(candidate)
+			if err != nil {
+				return "", "", err
+			}
+			return gitDir, dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", ErrNotRepository
+		}
+		dir = parent
+	}
+}
+
+// ReadGitFile 读取 .git 文件（"gitdir: <路径>"），返回它指向的 git 目录，相对路径相对于 .git 文件所在的目录
+func ReadGitFile(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+	if !ok {
+		return "", fmt.Errorf("invalid gitfile format: %s", file)
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(file), target)
+	}
+	if info, err := os.Stat(target); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("not a git repository: %s", target)
+	}
+	return filepath.Clean(target), nil
+}
+
+// OpenLocal 返回本地仓库地址（路径或 file:// 地址）对应的 git 目录：普通仓库返回其中的 .git，裸仓库返回目录本身
+// 目前只支持本地传输，其他形式的地址（https://、ssh:// 或 host:path）返回错误
+func OpenLocal(url string) (string, error) {
+	p := strings.TrimPrefix(url, "file://")
+	if strings.Contains(p, "://") || (strings.Contains(p, ":") && !filepath.IsAbs(p)) {
+		return "", fmt.Errorf("unsupported transport for '%s': only local repositories are supported", url)
+	}
+	dotGit := filepath.Join(p, ".git")
+	if info, err := os.Stat(dotGit); err == nil {
+		if info.IsDir() {
+			return filepath.Abs(dotGit)
+		}
+		return ReadGitFile(dotGit)
+	}
+	if _, err := os.Stat(filepath.Join(p, "objects")); err == nil {
+		if _, err := os.Stat(filepath.Join(p, "HEAD")); err == nil {
+			return filepath.Abs(p)
+		}
+	}
+	return "", fmt.Errorf("'%s' does not appear to be a git repository", p)
+}
@@ -6,10 +6,21 @@ import (
 	"path/filepath"
 )
 
+// defaultConfig 是新仓库的 .git/config，与 `git init` 写入的内容一致
+const defaultConfig = `[core]
+	repositoryformatversion = 0
+	filemode = true
+	bare = false
+	logallrefupdates = true
+`
+
 // InitRepository 初始化一个新的 Git 仓库
 func InitRepository(path string) error {
-	gitDir := filepath.Join(path, ".git")
+	return InitGitDir(filepath.Join(path, ".git"))
+}
 
+// InitGitDir 在 gitDir 创建仓库的目录结构，用于 git 目录不在工作区中的情况（例如子模块的 .git/modules/<name>）
+func InitGitDir(gitDir string) error {
 	if err := os.MkdirAll(gitDir, 0755); err != nil {
 		return fmt.Errorf("failed to create .git directory: %v", err)
 	}
@@ -24,7 +35,18 @@ func InitRepository(path string) error {
 		return fmt.Errorf("failed to create refs/heads directory: %v", err)
 	}
 
+	// 重新初始化已有仓库时保留原来的配置和 HEAD
+	configPath := filepath.Join(gitDir, "config")
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		if err := os.WriteFile(configPath, []byte(defaultConfig), 0644); err != nil {
+			return fmt.Errorf("failed to create config file: %v", err)
+		}
+	}
+
 	headPath := filepath.Join(gitDir, "HEAD")
+	if _, err := os.Stat(headPath); err == nil {
+		return nil
+	}
 	headContent := []byte("ref: refs/heads/main\n")
 	if err := os.WriteFile(headPath, headContent, 0644); err != nil {
 		return fmt.Errorf("failed to create HEAD file: %v", err)
@@ -0,0 +1,256 @@
+package revert
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/branch"
+	"geegit/beginner/day6-create-commit/checkout"
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/hooks"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/merge"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/status"
+	"geegit/beginner/day6-create-commit/storage"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// revert 通过三方合并撤销一个 commit 引入的修改：以该 commit 为共同祖先，
+// 把它的父 commit 合并到当前的 HEAD，结果就是去掉了这个 commit 的修改、保留了之后的所有修改。
+// 有冲突时与 git 一样留下 REVERT_HEAD 和 MERGE_MSG，解决之后用 Continue 提交，或用 Abort 放弃
+
+var (
+	// ErrUnmerged 表示索引中有未解决的冲突，无法开始 revert
+	ErrUnmerged = errors.New("reverting is not possible because you have unmerged files")
+	// ErrDirtyIndex 表示索引与 HEAD 不同，提交时会把这些修改一起带上
+	ErrDirtyIndex = errors.New("your local changes would be overwritten by revert")
+	// ErrNotInProgress 表示没有进行中的 revert（没有 REVERT_HEAD）
+	ErrNotInProgress = errors.New("no cherry-pick or revert in progress")
+	// ErrUnresolved 表示还有冲突没有解决，不能继续提交
+	ErrUnresolved = errors.New("committing is not possible because you have unmerged files")
+)
+
+// Options 控制 Revert 的方式
+type Options struct {
+	// Mainline 是撤销合并 commit 时作为主线的父 commit 的序号（从 1 开始，-m）
+	Mainline int
+	// NoCommit 为 true 时只修改索引和工作区，不创建 commit（-n）；此时索引不必与 HEAD 相同
+	NoCommit bool
+	// Edit 在提交前编辑说明文件（--edit），nil 时直接使用默认的说明
+	Edit func(msgFile string) error
+	// Hints 接收钩子没有可执行权限时的提示，见 hooks.Options
+	Hints io.Writer
+}
+
+// Result 是一次 revert 的结果
+type Result struct {
+	Merge   *merge.Result // 三方合并的结果，Continue 时为 nil
+	Message string        // 新 commit 的说明
+	Commit  hash.Hash     // 新创建的 commit；有冲突或 NoCommit 时为零哈希
+	Parent  hash.Hash     // 新 commit 的父 commit（即原来的 HEAD）
+}
+
+// Revert 创建一个撤销 commit h 的新 commit（git revert）
+//  1. 检查索引：不能有冲突，需要提交时还必须与 HEAD 相同
+//  2. 以 h 为共同祖先，把 h 的父 commit 合并到当前索引，写入索引和工作区
+//  3. 有冲突或 NoCommit 时写出 REVERT_HEAD 和 MERGE_MSG，否则通过 hooks.Commit 提交并移动 HEAD
+//
+// 合并因本地修改放弃时返回 *checkout.ConflictError；撤销之后与 HEAD 没有区别时返回 hooks.ErrEmptyCommit，
+// 这两种情况都同时返回 Result 以便输出合并过程
+func Revert(gitDir, workDir string, h hash.Hash, opts Options) (*Result, error) {
+	headHash, err := refs.Resolve(gitDir, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	head, err := commit.ReadCommit(gitDir, headHash)
+	if err != nil {
+		return nil, err
+	}
+	c, err := commit.ReadCommit(gitDir, h)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. 检查索引
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range idx.Entries {
+		if e.Stage != 0 {
+			return nil, ErrUnmerged
+		}
+	}
+	ours, err := tree.WriteIndex(gitDir, idx)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.NoCommit && ours != head.Tree {
+		return nil, ErrDirtyIndex
+	}
+
+	// 2. 要恢复到的父 commit
+	var parent hash.Hash
+	switch {
+	case len(c.Parents) > 1 && opts.Mainline == 0:
+		return nil, fmt.Errorf("commit %s is a merge but no -m option was given.", h)
+	case len(c.Parents) <= 1 && opts.Mainline > 0:
+		return nil, fmt.Errorf("mainline was specified but commit %s is not a merge.", h)
+	case opts.Mainline > len(c.Parents):
+		return nil, fmt.Errorf("commit %s does not have parent %d", h, opts.Mainline)
+	case opts.Mainline > 0:
+		parent = c.Parents[opts.Mainline-1]
+	case len(c.Parents) == 1:
+		parent = c.Parents[0]
+	}
+	var parentTree hash.Hash
+	if !parent.IsZero() {
+		pc, err := commit.ReadCommit(gitDir, parent)
+		if err != nil {
+			return nil, err
+		}
+		parentTree = pc.Tree
+	}
+
+	// 3. 三方合并
+	subject, _, _ := strings.Cut(c.Message, "\n")
+	labels := merge.Labels{Ours: "HEAD", Theirs: fmt.Sprintf("parent of %s (%s)", h.String()[:7], subject)}
+	if parent.IsZero() {
+		labels.Theirs = "(empty tree)"
+	}
+	res, err := merge.Trees(gitDir, c.Tree, ours, parentTree, labels)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkout.Merge(gitDir, workDir, res); err != nil {
+		return &Result{Merge: res, Parent: headHash}, err
+	}
+
+	msg := fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s", subject, h)
+	if opts.Mainline > 0 {
+		msg += fmt.Sprintf(", reversing\nchanges made to %s", parent)
+	}
+	msg += ".\n"
+	result := &Result{Merge: res, Message: msg, Parent: headHash}
+
+	// 4. 有冲突或不提交：留下状态，之后由用户完成
+	if !res.Clean() || opts.NoCommit {
+		mergeMsg := msg
+		if !res.Clean() {
+			mergeMsg += "\n# Conflicts:\n"
+			for _, e := range res.Entries {
+				if e.Conflict {
+					mergeMsg += "#\t" + e.Path + "\n"
+				}
+			}
+		}
+		if err := os.WriteFile(filepath.Join(gitDir, "MERGE_MSG"), []byte(mergeMsg), 0644); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(gitDir, "REVERT_HEAD"), []byte(h.String()+"\n"), 0644); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	// 5. 提交：与 git 的 sequencer 一样不运行 pre-commit 和 commit-msg，只运行 prepare-commit-msg 和 post-commit
+	source := "message"
+	if opts.Edit != nil {
+		source = "merge"
+	}
+	result.Commit, err = hooks.Commit(storage.NewFilesystem(gitDir), gitDir, workDir, msg, hooks.CommitOptions{
+		NoVerify: true, Reflog: "revert", Source: source, Edit: opts.Edit, Hints: opts.Hints,
+	})
+	if errors.Is(err, hooks.ErrEmptyCommit) {
+		return result, err
+	} else if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Continue 在解决冲突之后提交进行中的 revert（git revert --continue），说明取自 MERGE_MSG（去掉注释行）
+// 与 git 一样像 git commit 那样运行全部的提交钩子；opts 中只有 Edit 和 Hints 有效
+func Continue(gitDir, workDir string, opts Options) (*Result, error) {
+	if _, err := os.Stat(filepath.Join(gitDir, "REVERT_HEAD")); err != nil {
+		return nil, ErrNotInProgress
+	}
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range idx.Entries {
+		if e.Stage != 0 {
+			return nil, ErrUnresolved
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(gitDir, "MERGE_MSG"))
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	msg := strings.TrimRight(strings.Join(lines, "\n"), "\n") + "\n"
+
+	headHash, err := refs.Resolve(gitDir, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	h, err := hooks.Commit(storage.NewFilesystem(gitDir), gitDir, workDir, msg, hooks.CommitOptions{
+		Source: "merge", Edit: opts.Edit, Hints: opts.Hints,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := branch.RemoveState(gitDir); err != nil {
+		return nil, err
+	}
+	return &Result{Message: msg, Commit: h, Parent: headHash}, nil
+}
+
+// Abort 放弃进行中的 revert（git revert --abort / --skip）
+// 与 git reset --merge 相同，只恢复与 HEAD 不同的索引条目（即 revert 带来的修改），工作区中其他的修改保留
+func Abort(gitDir, workDir string) error {
+	if _, err := os.Stat(filepath.Join(gitDir, "REVERT_HEAD")); err != nil {
+		return ErrNotInProgress
+	}
+	headHash, err := refs.Resolve(gitDir, "HEAD")
+	if err != nil {
+		return err
+	}
+	head, err := commit.ReadCommit(gitDir, headHash)
+	if err != nil {
+		return err
+	}
+	st, err := status.Compute(gitDir, workDir, status.Options{HideUntracked: true})
+	if err != nil {
+		return err
+	}
+	var paths []string
+	for _, f := range st.Files {
+		if f.IsStaged() || f.IsConflicted() {
+			paths = append(paths, f.Path)
+		}
+	}
+	if len(paths) > 0 {
+		err := checkout.Restore(gitDir, workDir, paths, checkout.RestoreOptions{Source: head.Tree, Staged: true, Worktree: true})
+		if err != nil {
+			return err
+		}
+	}
+	// 与 git 一样，reflog 中记录一次原地的 reset
+	if err := refs.Update(gitDir, "HEAD", headHash, &headHash, "reset: moving to "+headHash.String()); err != nil {
+		return err
+	}
+	return branch.RemoveState(gitDir)
+}
@@ -0,0 +1,265 @@
+package revision
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/bitmap"
+	"geegit/beginner/day6-create-commit/commitgraph"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// ListOptions 控制 List 选择哪些 commit（git rev-list 的常用选项）
+type ListOptions struct {
+	Include  []hash.Hash // 从这些 commit 出发
+	Exclude  []hash.Hash // 排除从这些 commit 可以到达的 commit（^<rev>，或 <a>..<b> 中的 a）
+	Paths    []string    // 只保留修改了这些路径（文件或目录，相对于仓库根目录）的 commit
+	MaxCount int         // 最多返回的 commit 数量，0 表示不限制
+	Warnings io.Writer   // 输出警告（例如位图损坏），nil 表示不输出
+}
+
+// List 按提交时间从新到旧列出 commit（git rev-list）
+//  1. 从 Include 和 Exclude 同时出发，按提交时间从新到旧遍历；Exclude 一侧的 commit 把父 commit 也标记为排除
+//  2. 指定了路径时按 git 默认的历史简化处理每个 commit：与某个父 commit 在这些路径上相同（TREESAME）的 commit
+//     不输出，合并 commit 只沿这个父 commit 继续；与第一个父 commit 比较时先查询 Bloom 过滤器，
+//     过滤器表明路径没有修改时不需要读取 tree
+//  3. 队列中只剩下被排除的 commit 时停止，去掉后来才发现被排除的 commit
+func List(gitDir string, opts ListOptions) ([]hash.Hash, error) {
+	l := &lister{gitDir: gitDir, paths: opts.Paths, flags: make(map[hash.Hash]int)}
+	graph, err := commitgraph.Open(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	l.graph = graph
+	for _, p := range opts.Paths {
+		if p == "" {
+			l.graph = nil // 整个仓库，没有可以查询的键
+		}
+	}
+
+	queue := &commitQueue{}
+	push := func(h hash.Hash) error {
+		c, err := commitgraph.Read(gitDir, h)
+		if err != nil {
+			return err
+		}
+		l.flags[h] |= listSeen
+		queue.push(c)
+		return nil
+	}
+	for _, h := range opts.Exclude {
+		if err := l.markExcluded(h); err != nil {
+			return nil, err
+		}
+	}
+	for _, h := range append(append([]hash.Hash{}, opts.Exclude...), opts.Include...) {
+		if l.flags[h]&listSeen == 0 {
+			if err := push(h); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 1. 遍历
+	var out []hash.Hash
+	for queue.Len() > 0 && !l.everybodyExcluded(queue) {
+		c := queue.pop()
+		l.flags[c.Hash] |= listDone
+		parents := c.Parents
+		if l.flags[c.Hash]&listExcluded != 0 {
+			for _, p := range parents {
+				if err := l.markExcluded(p); err != nil {
+					return nil, err
+				}
+			}
+		} else {
+			show := true
+			if len(l.paths) > 0 {
+				if show, parents, err = l.simplify(c); err != nil {
+					return nil, err
+				}
+			}
+			if show {
+				out = append(out, c.Hash)
+				if len(opts.Exclude) == 0 && len(out) == opts.MaxCount {
+					break
+				}
+			}
+		}
+		for _, p := range parents {
+			if l.flags[p]&listSeen == 0 {
+				if err := push(p); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// 2. 去掉后来被排除的 commit
+	result := out[:0]
+	for _, h := range out {
+		if l.flags[h]&listExcluded == 0 {
+			result = append(result, h)
+		}
+	}
+	if opts.MaxCount > 0 && len(result) > opts.MaxCount {
+		result = result[:opts.MaxCount]
+	}
+	return result, nil
+}
+
+// Count 返回 List 会列出的 commit 数量（git rev-list --count）
+// 没有指定路径且仓库有可达性位图时，直接用位图计算从 Include 可以到达、从 Exclude 不能到达的 commit；
+// 位图读取失败时给出警告，改为遍历 commit
+func Count(gitDir string, opts ListOptions) (int, error) {
+	if len(opts.Paths) == 0 {
+		idx, err := bitmap.Open(gitDir)
+		if err != nil && opts.Warnings != nil {
+			fmt.Fprintf(opts.Warnings, "warning: ignoring bitmap index: %v\n", err)
+		}
+		if err == nil && idx != nil {
+			include, err := idx.ReachableCommits(gitDir, opts.Include)
+			if err != nil {
+				return 0, err
+			}
+			exclude, err := idx.ReachableCommits(gitDir, opts.Exclude)
+			if err != nil {
+				return 0, err
+			}
+			include.AndNot(exclude)
+			n := include.Count(hash.CommitObject)
+			if opts.MaxCount > 0 {
+				n = min(n, opts.MaxCount)
+			}
+			return n, nil
+		}
+	}
+	list, err := List(gitDir, opts)
+	return len(list), err
+}
+
+// List 的遍历标记
+const (
+	listSeen     = 1 << iota // 已经加入队列
+	listDone                 // 已经处理过
+	listExcluded             // 被排除
+)
+
+type lister struct {
+	gitDir string
+	graph  *commitgraph.Graph // 用于查询 Bloom 过滤器，没有 commit-graph 时为 nil
+	paths  []string
+	flags  map[hash.Hash]int
+}
+
+// markExcluded 把 h 标记为排除；已经处理过的 commit 不会再从队列中取出，需要立即把它的祖先也标记为排除
+func (l *lister) markExcluded(h hash.Hash) error {
+	stack := []hash.Hash{h}
+	for len(stack) > 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if l.flags[h]&listExcluded != 0 {
+			continue
+		}
+		l.flags[h] |= listExcluded
+		if l.flags[h]&listDone == 0 {
+			continue
+		}
+		c, err := commitgraph.Read(l.gitDir, h)
+		if err != nil {
+			return err
+		}
+		stack = append(stack, c.Parents...)
+	}
+	return nil
+}
+
+// everybodyExcluded 判断队列中是否只剩下被排除的 commit
+func (l *lister) everybodyExcluded(q *commitQueue) bool {
+	for _, it := range q.items {
+		if l.flags[it.commit.Hash]&listExcluded == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// simplify 判断 commit 是否修改了指定的路径，返回是否输出以及之后要遍历的父 commit
+// 与某个父 commit 相同时只沿这个父 commit 继续；根 commit 在这些路径上有内容时才输出
+func (l *lister) simplify(c *commitgraph.Commit) (bool, []hash.Hash, error) {
+	if len(c.Parents) == 0 {
+		same, err := l.sameTrees(hash.Hash{}, c.Tree)
+		return !same, nil, err
+	}
+	for i, p := range c.Parents {
+		if i == 0 && l.graph != nil {
+			if b, ok := l.graph.Bloom(c.Hash); ok && !l.mayChange(b) {
+				return false, []hash.Hash{p}, nil
+			}
+		}
+		pc, err := commitgraph.Read(l.gitDir, p)
+		if err != nil {
+			return false, nil, err
+		}
+		same, err := l.sameTrees(pc.Tree, c.Tree)
+		if err != nil {
+			return false, nil, err
+		}
+		if same {
+			return false, []hash.Hash{p}, nil
+		}
+	}
+	return true, c.Parents, nil
+}
+
+// mayChange 判断 Bloom 过滤器是否表明某个指定路径可能被修改过
+func (l *lister) mayChange(b *commitgraph.Bloom) bool {
+	for _, p := range l.paths {
+		if b.MayContain(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameTrees 判断两个 tree 在指定路径上是否完全相同，a 为零哈希表示空 tree
+func (l *lister) sameTrees(a, b hash.Hash) (bool, error) {
+	if a == b {
+		return true, nil
+	}
+	for _, p := range l.paths {
+		if p == "" {
+			return false, nil
+		}
+		ea, err := l.findEntry(a, p)
+		if err != nil {
+			return false, err
+		}
+		eb, err := l.findEntry(b, p)
+		if err != nil {
+			return false, err
+		}
+		if (ea == nil) != (eb == nil) {
+			return false, nil
+		}
+		if ea != nil && (ea.Hash != eb.Hash || strings.TrimLeft(ea.Mode, "0") != strings.TrimLeft(eb.Mode, "0")) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// findEntry 查找路径对应的条目，不存在时返回 nil
+func (l *lister) findEntry(root hash.Hash, p string) (*tree.TreeEntry, error) {
+	if root.IsZero() {
+		return nil, nil
+	}
+	e, err := tree.FindEntry(l.gitDir, root, p)
+	if errors.Is(err, tree.ErrNotFound) {
+		return nil, nil
+	}
+	return e, err
+}
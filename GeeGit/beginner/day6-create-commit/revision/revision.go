@@ -0,0 +1,305 @@
+package revision
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/alternates"
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/pack"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// Resolve 将修订表达式解析为对象哈希，支持的语法（参考 gitrevisions(7)）：
+//
+//	<sha1>、<短哈希>、HEAD、@、<引用名>
+//	<引用名>@{<n>}       引用的 reflog 中倒数第 n+1 项，例如 stash@{1}；省略引用名表示当前分支
+//	<rev>^、<rev>^<n>    第 n 个父 commit
+//	<rev>~<n>            沿第一个父 commit 回退 n 代
+//	<rev>^{<type>}       剥离到指定类型，例如 HEAD^{tree}
+//	<rev>:<path>         rev 所指 tree 中的某个路径
+func Resolve(gitDir, spec string) (hash.Hash, error) {
+	// 1. <rev>:<path>
+	if rev, p, ok := strings.Cut(spec, ":"); ok {
+		if rev == "" {
+			rev = "HEAD"
+		}
+		treeHash, err := ResolveType(gitDir, rev, hash.TreeObject)
+		if err != nil {
+			return hash.Hash{}, err
+		}
+		if p == "" {
+			return treeHash, nil
+		}
+		entry, err := tree.FindEntry(gitDir, treeHash, p)
+		if err != nil {
+			return hash.Hash{}, fmt.Errorf("path '%s' does not exist in '%s'", p, rev)
+		}
+		return entry.Hash, nil
+	}
+
+	// 2. 从末尾剥离 ^、~、^{type} 后缀
+	base, ops := splitSuffixes(spec)
+
+	h, err := resolveBase(gitDir, base)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+
+	for _, op := range ops {
+		switch {
+		case strings.HasPrefix(op, "^{"):
+			typeName := strings.TrimSuffix(strings.TrimPrefix(op, "^{"), "}")
+			if typeName == "" {
+				// ^{} 表示一直剥离标签
+				h, err = peelTags(gitDir, h)
+			} else {
+				var t hash.ObjectType
+				if t, err = hash.ParseObjectType(typeName); err == nil {
+					h, err = Peel(gitDir, h, t)
+				}
+			}
+		case op[0] == '~':
+			n := 1
+			if len(op) > 1 {
+				if n, err = strconv.Atoi(op[1:]); err != nil {
+					return hash.Hash{}, fmt.Errorf("invalid revision: %s", spec)
+				}
+			}
+			for i := 0; i < n && err == nil; i++ {
+				h, err = parent(gitDir, h, 1)
+			}
+		default: // ^ 或 ^<n>
+			n := 1
+			if len(op) > 1 {
+				if n, err = strconv.Atoi(op[1:]); err != nil {
+					return hash.Hash{}, fmt.Errorf("invalid revision: %s", spec)
+				}
+			}
+			if n > 0 {
+				h, err = parent(gitDir, h, n)
+			} else {
+				h, err = Peel(gitDir, h, hash.CommitObject)
+			}
+		}
+		if err != nil {
+			return hash.Hash{}, err
+		}
+	}
+
+	return h, nil
+}
+
+// ResolveType 解析修订表达式并剥离到指定类型（例如把 commit 或标签剥离为 tree）
+func ResolveType(gitDir, spec string, want hash.ObjectType) (hash.Hash, error) {
+	h, err := Resolve(gitDir, spec)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	return Peel(gitDir, h, want)
+}
+
+// Peel 将对象剥离到指定类型：tag -> 所指对象，commit -> tree
+func Peel(gitDir string, h hash.Hash, want hash.ObjectType) (hash.Hash, error) {
+	for {
+		obj, err := object.Read(gitDir, h)
+		if err != nil {
+			return hash.Hash{}, err
+		}
+		if obj.Type == want {
+			return h, nil
+		}
+
+		switch {
+		case obj.Type == hash.TagObject:
+			h, err = tagTarget(obj.Content)
+		case obj.Type == hash.CommitObject && want == hash.TreeObject:
+			var c *commit.Commit
+			if c, err = commit.ParseCommit(obj.Content); err == nil {
+				h = c.Tree
+			}
+		default:
+			return hash.Hash{}, fmt.Errorf("object %s is a %s, not a %s", h.String(), obj.Type, want)
+		}
+		if err != nil {
+			return hash.Hash{}, err
+		}
+	}
+}
+
+// peelTags 一直剥离标签，直到得到非标签对象
+func peelTags(gitDir string, h hash.Hash) (hash.Hash, error) {
+	for {
+		obj, err := object.Read(gitDir, h)
+		if err != nil {
+			return hash.Hash{}, err
+		}
+		if obj.Type != hash.TagObject {
+			return h, nil
+		}
+		if h, err = tagTarget(obj.Content); err != nil {
+			return hash.Hash{}, err
+		}
+	}
+}
+
+// tagTarget 读取标签对象第一行 "object <hash>"
+func tagTarget(content []byte) (hash.Hash, error) {
+	line, _, _ := bytes.Cut(content, []byte("\n"))
+	hexStr, ok := strings.CutPrefix(string(line), "object ")
+	if !ok {
+		return hash.Hash{}, fmt.Errorf("invalid tag object")
+	}
+	return hash.ParseHash(hexStr)
+}
+
+// parent 返回 commit 的第 n 个父 commit（从 1 开始）
+func parent(gitDir string, h hash.Hash, n int) (hash.Hash, error) {
+	h, err := Peel(gitDir, h, hash.CommitObject)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	c, err := commit.ReadCommit(gitDir, h)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	if n > len(c.Parents) {
+		return hash.Hash{}, fmt.Errorf("commit %s has no parent %d", h.String()[:8], n)
+	}
+	return c.Parents[n-1], nil
+}
+
+// splitSuffixes 将 "main~2^{tree}" 拆分为 "main" 和 ["~2", "^{tree}"]
+func splitSuffixes(spec string) (string, []string) {
+	var ops []string
+	for {
+		switch {
+		case strings.HasSuffix(spec, "}"):
+			idx := strings.LastIndex(spec, "^{")
+			if idx < 0 {
+				return spec, ops
+			}
+			ops = append([]string{spec[idx:]}, ops...)
+			spec = spec[:idx]
+		default:
+			// 末尾的数字可能属于 ~n 或 ^n
+			i := len(spec)
+			for i > 0 && spec[i-1] >= '0' && spec[i-1] <= '9' {
+				i--
+			}
+			if i == 0 || (spec[i-1] != '~' && spec[i-1] != '^') {
+				return spec, ops
+			}
+			ops = append([]string{spec[i-1:]}, ops...)
+			spec = spec[:i-1]
+		}
+	}
+}
+
+// resolveBase 解析不带后缀的名称：引用名、<引用名>@{<n>} 或（短）哈希
+func resolveBase(gitDir, name string) (hash.Hash, error) {
+	if name == "@" || name == "" {
+		name = "HEAD"
+	}
+	if at := strings.LastIndex(name, "@{"); at >= 0 && strings.HasSuffix(name, "}") {
+		if n, err := strconv.Atoi(name[at+2 : len(name)-1]); err == nil && n >= 0 {
+			return resolveReflog(gitDir, name[:at], n)
+		}
+	}
+	h, err := refs.Resolve(gitDir, name)
+	if err == nil {
+		return h, nil
+	}
+	if !errors.Is(err, refs.ErrNotFound) {
+		return hash.Hash{}, err
+	}
+
+	if len(name) >= 4 && len(name) < 40 && isHex(name) {
+		return expandShort(gitDir, name)
+	}
+	return hash.Hash{}, fmt.Errorf("unknown revision '%s'", name)
+}
+
+// resolveReflog 返回引用 ref 的 reflog 中倒数第 n+1 项记录的新值
+func resolveReflog(gitDir, ref string, n int) (hash.Hash, error) {
+	var full string
+	var err error
+	if ref == "" {
+		full, err = refs.ResolveName(gitDir, "HEAD")
+	} else {
+		full, err = refs.Expand(gitDir, ref)
+	}
+	if err != nil {
+		return hash.Hash{}, fmt.Errorf("unknown revision '%s@{%d}'", ref, n)
+	}
+	entries, err := refs.ReadLog(gitDir, full)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	if n == 0 && len(entries) == 0 {
+		return refs.Resolve(gitDir, full)
+	}
+	if n >= len(entries) {
+		if ref == "" {
+			ref = refs.ShortName(full)
+		}
+		return hash.Hash{}, fmt.Errorf("log for '%s' only has %d entries", ref, len(entries))
+	}
+	return entries[len(entries)-1-n].New, nil
+}
+
+// expandShort 在松散对象和 packfile（包括 alternates 中的）中查找以 prefix 开头的唯一对象
+func expandShort(gitDir, prefix string) (hash.Hash, error) {
+	prefix = strings.ToLower(prefix)
+	matches := make(map[hash.Hash]bool)
+
+	for _, dir := range alternates.ObjectDirs(gitDir) {
+		loose, err := object.ListLooseIn(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return hash.Hash{}, err
+		}
+		for _, h := range loose {
+			if strings.HasPrefix(h.String(), prefix) {
+				matches[h] = true
+			}
+		}
+
+		packs, err := pack.OpenDir(dir)
+		if err != nil {
+			return hash.Hash{}, err
+		}
+		for _, p := range packs {
+			for _, h := range p.Index.Hashes {
+				if strings.HasPrefix(h.String(), prefix) {
+					matches[h] = true
+				}
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return hash.Hash{}, fmt.Errorf("unknown revision '%s'", prefix)
+	case 1:
+		for h := range matches {
+			return h, nil
+		}
+	}
+	return hash.Hash{}, fmt.Errorf("short object ID %s is ambiguous", prefix)
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,239 @@
+package revision
+
+import (
+	"container/heap"
+	"sort"
+
+	"geegit/beginner/day6-create-commit/commitgraph"
+	"geegit/beginner/day6-create-commit/hash"
+)
+
+// 遍历历史只需要父 commit、提交时间和代数，都通过 commitgraph.Read 读取：
+// commit 在 commit-graph 中时直接查表，不需要解压和解析 commit 对象
+
+// Ancestors 返回从 start 出发可以到达的所有 commit（包括 start 本身）
+func Ancestors(gitDir string, start hash.Hash) (map[hash.Hash]bool, error) {
+	seen := make(map[hash.Hash]bool)
+	stack := []hash.Hash{start}
+	for len(stack) > 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+
+		c, err := commitgraph.Read(gitDir, h)
+		if err != nil {
+			return nil, err
+		}
+		stack = append(stack, c.Parents...)
+	}
+	return seen, nil
+}
+
+// IsAncestor 判断 a 是否是 b 的祖先（a == b 时也返回 true）
+// a 在 commit-graph 中时，代数不大于 a 的其他 commit 不可能以 a 为祖先，遍历到它们就不再继续
+func IsAncestor(gitDir string, a, b hash.Hash) (bool, error) {
+	target, err := commitgraph.Read(gitDir, a)
+	if err != nil {
+		return false, err
+	}
+	seen := make(map[hash.Hash]bool)
+	stack := []hash.Hash{b}
+	for len(stack) > 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if h == a {
+			return true, nil
+		}
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+
+		c, err := commitgraph.Read(gitDir, h)
+		if err != nil {
+			return false, err
+		}
+		if target.Generation != commitgraph.InfiniteGeneration && c.Generation <= target.Generation {
+			continue
+		}
+		stack = append(stack, c.Parents...)
+	}
+	return false, nil
+}
+
+// AheadBehind 计算 local 相对 upstream 领先和落后的 commit 数量
+// 即 `git rev-list --count upstream..local` 和 `git rev-list --count local..upstream`
+func AheadBehind(gitDir string, local, upstream hash.Hash) (ahead, behind int, err error) {
+	fromLocal, err := Ancestors(gitDir, local)
+	if err != nil {
+		return 0, 0, err
+	}
+	fromUpstream, err := Ancestors(gitDir, upstream)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for h := range fromLocal {
+		if !fromUpstream[h] {
+			ahead++
+		}
+	}
+	for h := range fromUpstream {
+		if !fromLocal[h] {
+			behind++
+		}
+	}
+	return ahead, behind, nil
+}
+
+// 合并基础的遍历标记
+const (
+	parent1   = 1 << iota // 可以从 one 到达
+	parent2               // 可以从 others 中的某一个到达
+	stale                 // 已经是某个共同祖先的祖先，不可能是最近的共同祖先
+	candidate             // 已经加入候选
+)
+
+// MergeBases 返回 one 与 others 的最近共同祖先（git merge-base --all one others...），按提交时间从新到旧排列
+// 与 git 的 paint_down_to_common 相同：从两侧同时向下标记，先处理代数大（其次是提交时间新）的 commit，
+// 两侧都能到达的 commit 是候选，它的祖先标记为 stale；最后去掉是其他候选的祖先的候选
+func MergeBases(gitDir string, one hash.Hash, others ...hash.Hash) ([]hash.Hash, error) {
+	for _, h := range others {
+		if h == one {
+			return []hash.Hash{one}, nil
+		}
+	}
+
+	// 1. 同时从两侧向下标记
+	flags := make(map[hash.Hash]int)
+	queue := &commitQueue{byGeneration: true}
+	push := func(h hash.Hash, f int) error {
+		c, err := commitgraph.Read(gitDir, h)
+		if err != nil {
+			return err
+		}
+		flags[h] |= f
+		queue.push(c)
+		return nil
+	}
+	if err := push(one, parent1); err != nil {
+		return nil, err
+	}
+	for _, h := range others {
+		if err := push(h, parent2); err != nil {
+			return nil, err
+		}
+	}
+	var candidates []*commitgraph.Commit
+	for queue.hasNonStale(flags) {
+		c := queue.pop()
+		f := flags[c.Hash] & (parent1 | parent2 | stale)
+		if f == parent1|parent2 {
+			if flags[c.Hash]&candidate == 0 {
+				flags[c.Hash] |= candidate
+				candidates = append(candidates, c)
+			}
+			f |= stale
+		}
+		for _, p := range c.Parents {
+			if flags[p]&f == f {
+				continue
+			}
+			if err := push(p, f); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 2. 去掉后来被标记为 stale 的候选，以及是其他候选的祖先的候选
+	var result []*commitgraph.Commit
+	for _, c := range candidates {
+		if flags[c.Hash]&stale == 0 {
+			result = append(result, c)
+		}
+	}
+	var bases []*commitgraph.Commit
+	for i, c := range result {
+		redundant := false
+		for j, other := range result {
+			if i == j {
+				continue
+			}
+			ok, err := IsAncestor(gitDir, c.Hash, other.Hash)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			bases = append(bases, c)
+		}
+	}
+	sort.SliceStable(bases, func(i, j int) bool { return bases[i].Time > bases[j].Time })
+	hashes := make([]hash.Hash, len(bases))
+	for i, c := range bases {
+		hashes[i] = c.Hash
+	}
+	return hashes, nil
+}
+
+// commitQueue 是遍历历史用的优先队列：默认按提交时间从新到旧，byGeneration 时先比较代数；
+// 相同时按加入的先后顺序
+type commitQueue struct {
+	byGeneration bool
+	items        []queued
+	seq          int
+}
+
+type queued struct {
+	commit *commitgraph.Commit
+	seq    int
+}
+
+func (q *commitQueue) push(c *commitgraph.Commit) {
+	heap.Push(q, queued{c, q.seq})
+	q.seq++
+}
+
+func (q *commitQueue) pop() *commitgraph.Commit {
+	return heap.Pop(q).(queued).commit
+}
+
+// hasNonStale 判断队列中是否还有没被标记为 stale 的 commit
+func (q *commitQueue) hasNonStale(flags map[hash.Hash]int) bool {
+	for _, it := range q.items {
+		if flags[it.commit.Hash]&stale == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *commitQueue) Len() int { return len(q.items) }
+
+func (q *commitQueue) Less(i, j int) bool {
+	a, b := q.items[i], q.items[j]
+	if q.byGeneration && a.commit.Generation != b.commit.Generation {
+		return a.commit.Generation > b.commit.Generation
+	}
+	if a.commit.Time != b.commit.Time {
+		return a.commit.Time > b.commit.Time
+	}
+	return a.seq < b.seq
+}
+
+func (q *commitQueue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+
+func (q *commitQueue) Push(x any) { q.items = append(q.items, x.(queued)) }
+
+func (q *commitQueue) Pop() any {
+	it := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return it
+}
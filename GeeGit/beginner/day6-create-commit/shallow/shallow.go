@@ -0,0 +1,103 @@
+package shallow
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/lockfile"
+)
+
+// 浅克隆（git clone --depth）只获取最近的一部分历史。
+// 历史被截断处的 commit 记录在 .git/shallow 中，每行一个哈希：这些 commit 的父 commit 不在对象库中，
+// 遍历历史时把它们视为没有父 commit 的根 commit
+
+// cached 是按文件修改时间缓存的 .git/shallow 内容
+type cached struct {
+	modTime time.Time
+	set     map[hash.Hash]bool
+}
+
+// cache 缓存公共目录 -> *cached，遍历历史时每读一个 commit 都要查询一次
+var cache sync.Map
+
+// Read 读取 .git/shallow，文件不存在（不是浅克隆）时返回空集合
+func Read(gitDir string) (map[hash.Hash]bool, error) {
+	p := gitdir.Path(gitDir, "shallow")
+	info, err := os.Stat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[hash.Hash]bool{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if c, ok := cache.Load(p); ok && c.(*cached).modTime.Equal(info.ModTime()) {
+		return c.(*cached).set, nil
+	}
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[hash.Hash]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		h, err := hash.ParseHash(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("bad shallow line: %s", scanner.Text())
+		}
+		set[h] = true
+	}
+	cache.Store(p, &cached{modTime: info.ModTime(), set: set})
+	return set, nil
+}
+
+// Contains 判断 commit 是否是浅克隆的边界（父 commit 被截断）
+func Contains(gitDir string, h hash.Hash) bool {
+	set, err := Read(gitDir)
+	return err == nil && set[h]
+}
+
+// Write 按哈希排序写入 .git/shallow；集合为空时删除文件，仓库不再是浅克隆
+func Write(gitDir string, set map[hash.Hash]bool) error {
+	p := gitdir.Path(gitDir, "shallow")
+	cache.Delete(p)
+	if len(set) == 0 {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	list := make([]string, 0, len(set))
+	for h := range set {
+		list = append(list, h.String())
+	}
+	sort.Strings(list)
+	var buf bytes.Buffer
+	for _, s := range list {
+		buf.WriteString(s + "\n")
+	}
+
+	lock, err := lockfile.Acquire(p)
+	if err != nil {
+		return err
+	}
+	if _, err := lock.Write(buf.Bytes()); err != nil {
+		lock.Rollback()
+		return err
+	}
+	return lock.Commit()
+}
+
+// IsShallow 判断仓库是否是浅克隆
+func IsShallow(gitDir string) bool {
+	set, err := Read(gitDir)
+	return err == nil && len(set) > 0
+}
@@ -0,0 +1,256 @@
+package sparse
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/matcher"
+)
+
+// 稀疏检出只在工作区中检出一部分被跟踪的文件，其余文件在索引中标记为 skip-worktree。
+// 检出的范围保存在当前工作区的 info/sparse-checkout 中，格式与 git 相同；
+// 锥形（cone）模式下只能按目录指定，文件内容总是下面的形式（以 "a/b" 和 "d" 为例）：
+//
+//	/*          根目录下的文件
+//	!/*/        排除根目录下的子目录
+//	/a/         a 是 a/b 的父目录：包含 a 下的文件
+//	!/a/*/      但不包含 a 的子目录
+//	/a/b/       完整包含 a/b
+//	/d/         完整包含 d
+//
+// 非锥形模式下每一行是 gitignore 格式的规则，最后一条匹配的规则决定文件是否检出
+
+// Patterns 是稀疏检出的范围
+type Patterns struct {
+	Cone bool // 锥形模式
+
+	recursive map[string]bool // 锥形模式：完整包含的目录
+	parents   map[string]bool // 锥形模式：只包含其中文件的父目录
+	rules     []matcher.Pattern
+	text      string // 非锥形模式：文件的原始内容
+}
+
+// NewCone 返回完整包含 dirs 的锥形模式范围，被其他目录包含的目录会被去掉
+func NewCone(dirs []string) *Patterns {
+	p := &Patterns{Cone: true, recursive: make(map[string]bool), parents: make(map[string]bool)}
+	for _, d := range dirs {
+		p.recursive[d] = true
+	}
+	for d := range p.recursive {
+		for parent := path.Dir(d); parent != "."; parent = path.Dir(parent) {
+			if p.recursive[parent] {
+				delete(p.recursive, d)
+				break
+			}
+		}
+	}
+	for d := range p.recursive {
+		for parent := path.Dir(d); parent != "."; parent = path.Dir(parent) {
+			p.parents[parent] = true
+		}
+	}
+	return p
+}
+
+// NewRules 返回非锥形模式的范围，每一行是一条 gitignore 格式的规则
+func NewRules(lines []string) *Patterns {
+	text := strings.Join(lines, "\n")
+	if text != "" {
+		text += "\n"
+	}
+	return &Patterns{rules: matcher.ParsePatterns([]byte(text), ""), text: text}
+}
+
+// Parse 解析 info/sparse-checkout 的内容；cone 为 true 但内容不是锥形模式的形式时按非锥形模式解析
+func Parse(data []byte, cone bool) *Patterns {
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if cone {
+		if p, ok := parseCone(lines); ok {
+			return p
+		}
+	}
+	if len(data) == 0 {
+		lines = nil
+	}
+	return NewRules(lines)
+}
+
+// parseCone 按锥形模式的形式解析，不符合时返回 false
+func parseCone(lines []string) (*Patterns, bool) {
+	if len(lines) < 2 || lines[0] != "/*" || lines[1] != "!/*/" {
+		return nil, false
+	}
+	var dirs []string
+	parents := make(map[string]bool)
+	for _, line := range lines[2:] {
+		switch {
+		case strings.HasPrefix(line, "!/") && strings.HasSuffix(line, "/*/") && len(line) > 5:
+			parents[unescape(line[2:len(line)-3])] = true
+		case strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/") && len(line) > 2:
+			dirs = append(dirs, unescape(line[1:len(line)-1]))
+		default:
+			return nil, false
+		}
+	}
+	var recursive []string
+	for _, d := range dirs {
+		if !parents[d] {
+			recursive = append(recursive, d)
+		}
+	}
+	return NewCone(recursive), true
+}
+
+// Dirs 返回锥形模式下完整包含的目录，按路径排序
+func (p *Patterns) Dirs() []string {
+	dirs := make([]string, 0, len(p.recursive))
+	for d := range p.recursive {
+		dirs = append(dirs, d)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// Bytes 返回写入 info/sparse-checkout 的内容：锥形模式下先是父目录，然后是完整包含的目录，各自按路径排序
+func (p *Patterns) Bytes() []byte {
+	if !p.Cone {
+		return []byte(p.text)
+	}
+	var b strings.Builder
+	b.WriteString("/*\n!/*/\n")
+	parents := make([]string, 0, len(p.parents))
+	for d := range p.parents {
+		parents = append(parents, d)
+	}
+	sort.Strings(parents)
+	for _, d := range parents {
+		b.WriteString("/" + escape(d) + "/\n!/" + escape(d) + "/*/\n")
+	}
+	for _, d := range p.Dirs() {
+		b.WriteString("/" + escape(d) + "/\n")
+	}
+	return []byte(b.String())
+}
+
+// Match 判断被跟踪的文件 file（相对于工作区根目录）是否在检出范围内
+func (p *Patterns) Match(file string) bool {
+	if p.Cone {
+		dir := path.Dir(file)
+		if dir == "." || p.parents[dir] {
+			return true
+		}
+		for ; dir != "."; dir = path.Dir(dir) {
+			if p.recursive[dir] {
+				return true
+			}
+		}
+		return false
+	}
+
+	// 文件本身没有匹配的规则时，依次由上层目录决定
+	for q, isDir := file, false; q != "."; q, isDir = path.Dir(q), true {
+		for i := len(p.rules) - 1; i >= 0; i-- {
+			if p.rules[i].Match(q, isDir, false) {
+				return !p.rules[i].Negate
+			}
+		}
+	}
+	return false
+}
+
+// InCone 判断锥形模式下目录 dir 是否在检出范围内（完整包含，或者是完整包含的目录的父目录）
+func (p *Patterns) InCone(dir string) bool {
+	return p.parents[dir] || p.Match(dir+"/x")
+}
+
+// Load 读取当前工作区的稀疏检出范围；没有打开 core.sparseCheckout 或者没有 info/sparse-checkout 时返回 nil
+func Load(gitDir string) (*Patterns, error) {
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Bool("core.sparsecheckout", false) {
+		return nil, nil
+	}
+	data, err := os.ReadFile(File(gitDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, cfg.Bool("core.sparsecheckoutcone", false)), nil
+}
+
+// File 返回保存检出范围的文件
+func File(gitDir string) string {
+	return gitdir.Path(gitDir, "info/sparse-checkout")
+}
+
+// Save 写入检出范围，并在当前工作区的配置中打开稀疏检出
+// 与 git 相同，core.sparseCheckout 写在 config.worktree 中（需要时打开 extensions.worktreeConfig），不影响其他工作区
+func Save(gitDir string, p *Patterns) error {
+	if err := os.MkdirAll(filepath.Dir(File(gitDir)), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(File(gitDir), p.Bytes(), 0644); err != nil {
+		return err
+	}
+	cone := "false"
+	if p.Cone {
+		cone = "true"
+	}
+	return setConfig(gitDir, "true", cone)
+}
+
+// Disable 在当前工作区的配置中关闭稀疏检出，info/sparse-checkout 保留
+func Disable(gitDir string) error {
+	if err := setConfig(gitDir, "false", "false"); err != nil {
+		return err
+	}
+	return config.SetValue(config.WorktreeFile(gitDir), "index.sparse", "false")
+}
+
+func setConfig(gitDir, sparse, cone string) error {
+	file := config.WorktreeFile(gitDir)
+	if file == "" {
+		if err := config.SetValue(gitdir.Path(gitDir, "config"), "extensions.worktreeConfig", "true"); err != nil {
+			return err
+		}
+		file = config.WorktreeFile(gitDir)
+	}
+	if err := config.SetValue(file, "core.sparseCheckout", sparse); err != nil {
+		return err
+	}
+	return config.SetValue(file, "core.sparseCheckoutCone", cone)
+}
+
+// escape 转义目录名中 gitignore 格式的特殊字符
+func escape(dir string) string {
+	var b strings.Builder
+	for _, c := range dir {
+		if strings.ContainsRune(`\*?[]`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// unescape 是 escape 的逆操作
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,160 @@
+package stash
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/checkout"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/merge"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+var (
+	// ErrUnmerged 表示索引中有未解决的冲突，无法应用 stash
+	ErrUnmerged = errors.New("cannot apply a stash in the middle of a merge")
+	// ErrIndexConflicts 表示 --index 时无法把保存的索引修改应用到当前索引
+	ErrIndexConflicts = errors.New("conflicts in index; try without --index")
+)
+
+// ApplyOptions 控制 Apply 恢复哪些内容
+type ApplyOptions struct {
+	// Index 为 true 时同时恢复保存时索引中的修改（--index），否则只恢复到工作区
+	Index bool
+}
+
+// Apply 把 stash 中的修改应用到当前的工作区（git stash apply）
+//  1. 以 B 为共同祖先，把 W 合并到当前索引对应的 tree
+//  2. 合并成功时恢复索引：--index 时恢复保存的索引修改，否则只保留新增文件的暂存状态
+//  3. 恢复保存的未跟踪文件
+//
+// 返回合并结果；合并因本地修改放弃时返回 *checkout.ConflictError，此时仍会恢复未跟踪文件
+func Apply(gitDir, workDir string, s *Stash, opts ApplyOptions) (*merge.Result, error) {
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	cTree, err := tree.WriteIndex(gitDir, idx)
+	if err != nil {
+		return nil, ErrUnmerged
+	}
+
+	// 1. --index：先把 B 到 I 的修改应用到当前索引
+	var indexTree hash.Hash
+	if opts.Index && s.BTree != s.ITree && cTree != s.ITree {
+		res, err := merge.Trees(gitDir, s.BTree, cTree, s.ITree, merge.Labels{})
+		if err != nil {
+			return nil, err
+		}
+		if !res.Clean() {
+			return nil, ErrIndexConflicts
+		}
+		for _, e := range res.Entries {
+			if e.Result == nil {
+				idx.Remove(e.Path)
+			} else {
+				idx.Add(index.Entry{Mode: index.ParseMode(e.Result.Mode), Hash: e.Result.Hash, Path: e.Path})
+			}
+		}
+		if indexTree, err = tree.WriteIndex(gitDir, idx); err != nil {
+			return nil, err
+		}
+	}
+
+	// 2. 合并工作区的修改
+	labels := merge.Labels{Ours: "Updated upstream", Theirs: "Stashed changes"}
+	if s.BTree == cTree {
+		labels.Ours = "Version stash was based on"
+	}
+	res, err := merge.Trees(gitDir, s.BTree, cTree, s.WTree, labels)
+	if err != nil {
+		return nil, err
+	}
+	if s.WTree == s.BTree {
+		res.Messages = append([]string{"Already up to date."}, res.Messages...)
+	}
+	mergeErr := checkout.Merge(gitDir, workDir, res)
+	var conflict *checkout.ConflictError
+	if mergeErr != nil && !errors.As(mergeErr, &conflict) {
+		return nil, mergeErr
+	}
+
+	// 3. 恢复索引
+	if mergeErr == nil && res.Clean() {
+		if !indexTree.IsZero() {
+			err = resetIndex(gitDir, indexTree, false)
+		} else {
+			err = resetIndex(gitDir, cTree, true)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 4. 恢复未跟踪文件
+	if !s.UTree.IsZero() {
+		if err := restoreUntracked(gitDir, workDir, s.UTree); err != nil {
+			return res, err
+		}
+	}
+	return res, mergeErr
+}
+
+// resetIndex 把索引设为 root tree 的内容，不修改工作区；内容没有变化的条目保留 stat 信息
+// keepNew 为 true 时保留 root 中没有的条目（即新增的文件仍然是暂存状态）
+func resetIndex(gitDir string, root hash.Hash, keepNew bool) error {
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		return err
+	}
+	files := make(map[string]tree.TreeEntry)
+	err = tree.Walk(gitDir, root, func(p string, e tree.TreeEntry) error {
+		files[p] = e
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var entries []index.Entry
+	for _, e := range idx.Entries {
+		te, ok := files[e.Path]
+		switch {
+		case ok && e.Stage == 0 && e.Mode == index.ParseMode(te.Mode) && e.Hash == te.Hash:
+			entries = append(entries, e)
+			delete(files, e.Path)
+		case !ok && keepNew:
+			entries = append(entries, e)
+		}
+	}
+	for p, te := range files {
+		entries = append(entries, index.Entry{Mode: index.ParseMode(te.Mode), Hash: te.Hash, Path: p})
+	}
+	idx.Entries = entries
+	idx.Sort()
+	return idx.Write(gitDir)
+}
+
+// restoreUntracked 把 U commit 中的文件写回工作区（不加入索引）
+// 已存在的文件不会被覆盖，这些文件在返回的错误中逐行列出
+func restoreUntracked(gitDir, workDir string, uTree hash.Hash) error {
+	var existing []string
+	err := tree.Walk(gitDir, uTree, func(p string, e tree.TreeEntry) error {
+		if _, err := os.Lstat(filepath.Join(workDir, filepath.FromSlash(p))); err == nil {
+			existing = append(existing, p+" already exists, no checkout")
+			return nil
+		}
+		_, err := checkout.WriteFile(gitDir, workDir, p, &e)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return errors.New(strings.Join(existing, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package stash
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"geegit/beginner/day6-create-commit/blob"
+	"geegit/beginner/day6-create-commit/checkout"
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/status"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// PushOptions 控制 Push 保存哪些内容
+type PushOptions struct {
+	Message          string // 说明，为空时使用 "WIP on <分支>: <短哈希> <标题>"
+	IncludeUntracked bool   // 同时保存并删除未跟踪的文件（-u）
+}
+
+// Push 保存本地修改，并把工作区和索引恢复到 HEAD（git stash push），返回新 stash 的说明
+//  1. 把索引保存为 I commit，未跟踪文件保存为 U commit
+//  2. 在索引的基础上用工作区中被跟踪文件的内容替换，保存为 W commit
+//  3. 重置工作区和索引，删除已保存的未跟踪文件
+//  4. refs/stash 指向 W，并追加一条 reflog；之前的步骤失败时不会留下 stash
+//
+// 没有任何修改时返回 ErrNoChanges
+func Push(gitDir, workDir string, opts PushOptions) (string, error) {
+	headHash, err := refs.Resolve(gitDir, "HEAD")
+	if errors.Is(err, refs.ErrNotFound) {
+		return "", ErrNoInitialCommit
+	} else if err != nil {
+		return "", err
+	}
+	head, err := commit.ReadCommit(gitDir, headHash)
+	if err != nil {
+		return "", err
+	}
+
+	// 1. 找出有修改的路径和未跟踪的文件
+	st, err := status.Compute(gitDir, workDir, status.Options{
+		AllUntracked:  true,
+		HideUntracked: !opts.IncludeUntracked,
+	})
+	if err != nil {
+		return "", err
+	}
+	var modified, untracked []string
+	changed := false
+	for _, f := range st.Files {
+		switch {
+		case f.Staging == status.Untracked:
+			untracked = append(untracked, f.Path)
+		case f.IsConflicted():
+			return "", errors.New("cannot save the current index state")
+		case f.IsModified():
+			modified = append(modified, f.Path)
+			changed = true
+		case f.IsStaged():
+			changed = true
+		}
+	}
+	if !changed && len(untracked) == 0 {
+		return "", ErrNoChanges
+	}
+
+	author, err := commit.DefaultSignature(gitDir, "author")
+	if err != nil {
+		return "", err
+	}
+	committer, err := commit.DefaultSignature(gitDir, "committer")
+	if err != nil {
+		return "", err
+	}
+	newCommit := func(t hash.Hash, parents []hash.Hash, msg string) (hash.Hash, error) {
+		return commit.WriteCommit(gitDir, &commit.Commit{
+			Tree: t, Parents: parents, Author: author, Committer: committer, Message: msg,
+		})
+	}
+	branch := branchName(gitDir)
+	info := headInfo(branch, head)
+
+	// 2. I commit：索引
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		return "", err
+	}
+	iTree, err := tree.WriteIndex(gitDir, idx)
+	if err != nil {
+		return "", err
+	}
+	iCommit, err := newCommit(iTree, []hash.Hash{headHash}, "index on "+info+"\n")
+	if err != nil {
+		return "", err
+	}
+	parents := []hash.Hash{headHash, iCommit}
+
+	// 3. U commit：未跟踪的文件
+	if len(untracked) > 0 {
+		uIdx := &index.Index{}
+		for _, p := range untracked {
+			e, err := addWorktreeFile(gitDir, workDir, p)
+			if err != nil {
+				return "", err
+			}
+			uIdx.Entries = append(uIdx.Entries, *e)
+		}
+		uIdx.Sort()
+		uTree, err := tree.WriteIndex(gitDir, uIdx)
+		if err != nil {
+			return "", err
+		}
+		uCommit, err := newCommit(uTree, nil, "untracked files on "+info+"\n")
+		if err != nil {
+			return "", err
+		}
+		parents = append(parents, uCommit)
+	}
+
+	// 4. W commit：索引 + 工作区中被跟踪文件的修改
+	for _, p := range modified {
+		e, err := addWorktreeFile(gitDir, workDir, p)
+		if err != nil {
+			return "", err
+		}
+		if e == nil {
+			idx.Remove(p)
+		} else {
+			idx.Add(*e)
+		}
+	}
+	wTree, err := tree.WriteIndex(gitDir, idx)
+	if err != nil {
+		return "", err
+	}
+	msg := "WIP on " + info
+	if opts.Message != "" {
+		msg = "On " + branch + ": " + opts.Message
+	}
+	wCommit, err := newCommit(wTree, parents, msg)
+	if err != nil {
+		return "", err
+	}
+
+	// 5. 确认对象都能从仓库中读到，再恢复到 HEAD（git reset --hard）并删除保存过的未跟踪文件；
+	//    这一步失败时 refs/stash 还没有更新，不会留下工作区修改仍在、却已记录的 stash
+	for _, h := range append([]hash.Hash{wCommit, wTree, iTree}, parents...) {
+		if !object.Exists(gitDir, h) {
+			return "", fmt.Errorf("cannot save the current worktree state: object %s not found", h)
+		}
+	}
+	if err := checkout.Trees(gitDir, workDir, wTree, head.Tree, checkout.Options{Force: true}); err != nil {
+		return "", err
+	}
+	for _, p := range untracked {
+		if err := checkout.RemoveFile(workDir, p); err != nil {
+			return "", err
+		}
+	}
+
+	// 6. 最后更新 refs/stash，refs/stash 总是记录 reflog
+	if err := refs.CreateLog(gitDir, Ref); err != nil {
+		return "", err
+	}
+	if err := refs.Update(gitDir, Ref, wCommit, nil, msg); err != nil {
+		// 工作区已经恢复，修改只保存在 W 中，给出它的哈希以便找回
+		return "", fmt.Errorf("cannot update %s to %s: %v", Ref, wCommit, err)
+	}
+	return msg, nil
+}
+
+// addWorktreeFile 把工作区文件写入对象库，返回对应的索引条目；文件不存在时返回 nil
+func addWorktreeFile(gitDir, workDir, p string) (*index.Entry, error) {
+	full := filepath.Join(workDir, filepath.FromSlash(p))
+	info, err := os.Lstat(full)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var content []byte
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(full)
+		if err != nil {
+			return nil, err
+		}
+		content = []byte(filepath.ToSlash(target))
+	} else if content, err = os.ReadFile(full); err != nil {
+		return nil, err
+	}
+	h, err := blob.WriteBlob(gitDir, content)
+	if err != nil {
+		return nil, err
+	}
+	e := index.NewEntry(p, info, h)
+	return &e, nil
+}
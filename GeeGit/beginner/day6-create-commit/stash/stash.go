@@ -0,0 +1,143 @@
+package stash
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/refs"
+)
+
+// Ref 是保存 stash 的引用，每一项 stash 是它的一条 reflog
+const Ref = "refs/stash"
+
+var (
+	// ErrNoStash 表示还没有任何 stash
+	ErrNoStash = errors.New("no stash entries found")
+	// ErrNoChanges 表示没有需要保存的本地修改
+	ErrNoChanges = errors.New("no local changes to save")
+	// ErrNoInitialCommit 表示当前分支还没有提交，无法保存 stash
+	ErrNoInitialCommit = errors.New("you do not have the initial commit yet")
+)
+
+// Entry 是 stash 列表中的一项
+type Entry struct {
+	Index   int       // 在列表中的位置，即 stash@{Index}
+	Hash    hash.Hash // 保存工作区的 commit
+	Message string    // reflog 中记录的说明，例如 "WIP on main: 1234567 subject"
+}
+
+// Stash 是一项 stash 对应的几个 commit，结构与 git 相同：
+//
+//	W  保存工作区的 commit，父 commit 依次为 B、I（和 U）
+//	B  保存时的 HEAD
+//	I  保存时的索引，父 commit 为 B
+//	U  保存时的未跟踪文件（stash -u），没有父 commit；不存在时为零哈希
+type Stash struct {
+	W, B, I, U hash.Hash
+
+	// 对应的 tree
+	WTree, BTree, ITree, UTree hash.Hash
+}
+
+// List 返回所有 stash，最新的在前
+func List(gitDir string) ([]Entry, error) {
+	if _, err := refs.Read(gitDir, Ref); err != nil {
+		if errors.Is(err, refs.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	log, err := refs.ReadLog(gitDir, Ref)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(log))
+	for i := len(log) - 1; i >= 0; i-- {
+		entries = append(entries, Entry{Index: len(entries), Hash: log[i].New, Message: log[i].Message})
+	}
+	return entries, nil
+}
+
+// Read 读取 commit h 对应的 stash，h 不是 stash 形式的 commit 时返回错误
+func Read(gitDir string, h hash.Hash) (*Stash, error) {
+	w, err := commit.ReadCommit(gitDir, h)
+	if err != nil {
+		return nil, err
+	}
+	if len(w.Parents) < 2 || len(w.Parents) > 3 {
+		return nil, fmt.Errorf("'%s' is not a stash-like commit", h)
+	}
+	s := &Stash{W: h, WTree: w.Tree, B: w.Parents[0], I: w.Parents[1]}
+	if len(w.Parents) == 3 {
+		s.U = w.Parents[2]
+	}
+
+	// 读取其余 commit 的 tree
+	for _, p := range []struct {
+		h    hash.Hash
+		tree *hash.Hash
+	}{{s.B, &s.BTree}, {s.I, &s.ITree}, {s.U, &s.UTree}} {
+		if p.h.IsZero() {
+			continue
+		}
+		c, err := commit.ReadCommit(gitDir, p.h)
+		if err != nil {
+			return nil, err
+		}
+		*p.tree = c.Tree
+	}
+	return s, nil
+}
+
+// Drop 删除 stash@{n}，返回被删除的 commit
+// 与 `git reflog delete --rewrite --updateref` 相同：后一条记录的旧值改为前一条的新值，
+// refs/stash 指向剩下的最新一项；没有剩下的 stash 时删除 refs/stash 和它的 reflog
+func Drop(gitDir string, n int) (hash.Hash, error) {
+	log, err := refs.ReadLog(gitDir, Ref)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	if len(log) == 0 {
+		return hash.Hash{}, ErrNoStash
+	}
+	if n < 0 || n >= len(log) {
+		return hash.Hash{}, fmt.Errorf("log for '%s' only has %d entries", Ref, len(log))
+	}
+
+	// 1. 删除 reflog 中的记录
+	pos := len(log) - 1 - n
+	dropped := log[pos].New
+	kept := append(log[:pos:pos], log[pos+1:]...)
+	var last hash.Hash
+	for i := range kept {
+		kept[i].Old = last
+		last = kept[i].New
+	}
+
+	// 2. 更新或删除引用
+	if len(kept) == 0 {
+		return dropped, refs.Delete(gitDir, Ref, nil)
+	}
+	// 更新引用时追加的 reflog 记录随后被整体替换
+	if err := refs.Update(gitDir, Ref, last, nil, ""); err != nil {
+		return hash.Hash{}, err
+	}
+	return dropped, refs.WriteLog(gitDir, Ref, kept)
+}
+
+// branchName 返回当前分支的短名称，分离 HEAD 时为 "(no branch)"
+func branchName(gitDir string) string {
+	if target, err := refs.ResolveName(gitDir, "HEAD"); err == nil && strings.HasPrefix(target, "refs/heads/") {
+		return strings.TrimPrefix(target, "refs/heads/")
+	}
+	return "(no branch)"
+}
+
+// headInfo 返回 stash 说明中描述 HEAD 的部分："<分支>: <短哈希> <标题>"
+func headInfo(branch string, head *commit.Commit) string {
+	subject, _, _ := strings.Cut(head.Message, "\n")
+	return fmt.Sprintf("%s: %s %s", branch, head.Hash.String()[:7], subject)
+}
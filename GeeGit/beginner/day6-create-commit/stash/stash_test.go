@@ -0,0 +1,106 @@
+package stash_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"geegit/beginner/day6-create-commit/geegit"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/stash"
+	"geegit/beginner/day6-create-commit/tree"
+	"geegit/beginner/day6-create-commit/worktree"
+)
+
+// newLinkedWorktree 创建有一个 commit 的仓库和它的链接工作区，返回主仓库和链接工作区的 git 目录及链接工作区的路径
+func newLinkedWorktree(t *testing.T) (common, gitDir, workDir string) {
+	t.Helper()
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(t.TempDir(), "gitconfig"))
+	for _, role := range []string{"AUTHOR", "COMMITTER"} {
+		t.Setenv("GIT_"+role+"_NAME", "A")
+		t.Setenv("GIT_"+role+"_EMAIL", "a@example.com")
+	}
+
+	dir := t.TempDir()
+	r, err := geegit.PlainInit(filepath.Join(dir, "main"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(w.Path, "a.txt"), []byte("one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Add("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	h, err := w.Commit("first", geegit.CommitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workDir = filepath.Join(dir, "linked")
+	if err := worktree.Add(r.GitDir(), workDir, worktree.AddOptions{Commit: h}); err != nil {
+		t.Fatal(err)
+	}
+	return r.GitDir(), filepath.Join(r.GitDir(), "worktrees", "linked"), workDir
+}
+
+// 链接工作区写入的对象（write-tree、stash）必须在主仓库的对象目录中，而不是工作区自己的 git 目录
+func TestLinkedWorktreeObjects(t *testing.T) {
+	common, gitDir, workDir := newLinkedWorktree(t)
+	if err := os.WriteFile(filepath.Join(workDir, "a.txt"), []byte("two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1. write-tree
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := tree.WriteIndex(gitDir, idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !object.Exists(common, root) {
+		t.Fatalf("tree %s is not in the common object directory", root)
+	}
+
+	// 2. stash push 和 apply
+	if _, err := stash.Push(gitDir, workDir, stash.PushOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(gitDir, "objects")); !os.IsNotExist(err) {
+		t.Fatalf("linked worktree has its own objects directory (err = %v)", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, "a.txt")); string(data) != "one\n" {
+		t.Fatalf("a.txt after push = %q, want %q", data, "one\n")
+	}
+	entries, err := stash.List(gitDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("stash list has %d entries, want 1", len(entries))
+	}
+	s, err := stash.Read(gitDir, entries[0].Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range []hash.Hash{s.W, s.I, s.WTree, s.ITree} {
+		if !object.Exists(common, h) {
+			t.Fatalf("object %s is not in the common object directory", h)
+		}
+	}
+	if _, err := stash.Apply(gitDir, workDir, s, stash.ApplyOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, "a.txt")); string(data) != "two\n" {
+		t.Fatalf("a.txt after apply = %q, want %q", data, "two\n")
+	}
+}
@@ -0,0 +1,421 @@
+package status
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FormatOptions 控制 short/porcelain 输出
+type FormatOptions struct {
+	Branch bool // 输出分支信息头（-b / --branch）
+	NulSep bool // 使用 NUL 结束每一项，路径不加引号（-z）
+
+	// Porcelain 为 true 时是 --porcelain 格式；否则（--short）只有内容变化的子模块显示为 "m" 或 "?"
+	Porcelain bool
+}
+
+// WriteShort 以 `git status --short` / `--porcelain=v1` 的格式输出
+// 每行: XY SP <path>，未跟踪文件为 "??"，被忽略的文件为 "!!"
+func WriteShort(w io.Writer, st *Status, opts FormatOptions) error {
+	bw := bufio.NewWriter(w)
+	term := byte('\n')
+	if opts.NulSep {
+		term = 0
+	}
+
+	if opts.Branch {
+		switch {
+		case st.Head.IsZero():
+			fmt.Fprintf(bw, "## No commits yet on %s", st.Branch)
+		case st.Branch == "":
+			bw.WriteString("## HEAD (no branch)")
+		default:
+			fmt.Fprintf(bw, "## %s", st.Branch)
+			if t := st.Tracking; t != nil {
+				fmt.Fprintf(bw, "...%s", t.ShortUpstream())
+				if summary := t.Summary(); summary != "" {
+					fmt.Fprintf(bw, " [%s]", summary)
+				}
+			}
+		}
+		bw.WriteByte(term)
+	}
+
+	for _, f := range st.Files {
+		y := f.Worktree
+		if !opts.Porcelain && f.Submodule&SubmoduleNewCommits == 0 {
+			switch {
+			case f.Submodule&SubmoduleModified != 0:
+				y = 'm'
+			case f.Submodule&SubmoduleUntracked != 0:
+				y = '?'
+			}
+		}
+		fmt.Fprintf(bw, "%c%c %s", f.Staging, y, formatPath(f.Path, opts.NulSep, true))
+		bw.WriteByte(term)
+	}
+	return bw.Flush()
+}
+
+// WritePorcelainV2 以 `git status --porcelain=v2` 的格式输出
+//
+//	1 <XY> <sub> <mH> <mI> <mW> <hH> <hI> <path>                 普通修改
+//	u <XY> <sub> <m1> <m2> <m3> <mW> <h1> <h2> <h3> <path>       冲突
+//	? <path>                                                     未跟踪
+//	! <path>                                                     被忽略
+//
+// <sub> 对普通文件是 "N..."，对子模块是 "S<c><m><u>"（new commits、modified content、untracked content）
+func WritePorcelainV2(w io.Writer, st *Status, opts FormatOptions) error {
+	bw := bufio.NewWriter(w)
+	term := byte('\n')
+	if opts.NulSep {
+		term = 0
+	}
+
+	if opts.Branch {
+		if st.Head.IsZero() {
+			bw.WriteString("# branch.oid (initial)")
+		} else {
+			fmt.Fprintf(bw, "# branch.oid %s", st.Head.String())
+		}
+		bw.WriteByte(term)
+		if st.Branch == "" {
+			bw.WriteString("# branch.head (detached)")
+		} else {
+			fmt.Fprintf(bw, "# branch.head %s", st.Branch)
+		}
+		bw.WriteByte(term)
+		if t := st.Tracking; t != nil {
+			fmt.Fprintf(bw, "# branch.upstream %s", t.ShortUpstream())
+			bw.WriteByte(term)
+			if !t.Gone {
+				fmt.Fprintf(bw, "# branch.ab +%d -%d", t.Ahead, t.Behind)
+				bw.WriteByte(term)
+			}
+		}
+	}
+
+	dot := func(c Code) byte {
+		if c == Unmodified {
+			return '.'
+		}
+		return byte(c)
+	}
+
+	for _, f := range st.Files {
+		p := formatPath(f.Path, opts.NulSep, false)
+		switch {
+		case f.Staging == Untracked:
+			fmt.Fprintf(bw, "? %s", p)
+		case f.Staging == Ignored:
+			fmt.Fprintf(bw, "! %s", p)
+		case f.IsConflicted():
+			fmt.Fprintf(bw, "u %c%c %s %06o %06o %06o %06o %s %s %s %s",
+				dot(f.Staging), dot(f.Worktree), submoduleField(f),
+				f.Stages[0].Mode, f.Stages[1].Mode, f.Stages[2].Mode, f.WorktreeMode,
+				f.Stages[0].Hash.String(), f.Stages[1].Hash.String(), f.Stages[2].Hash.String(), p)
+		default:
+			fmt.Fprintf(bw, "1 %c%c %s %06o %06o %06o %s %s %s",
+				dot(f.Staging), dot(f.Worktree), submoduleField(f),
+				f.HeadMode, f.IndexMode, f.WorktreeMode,
+				f.HeadHash.String(), f.IndexHash.String(), p)
+		}
+		bw.WriteByte(term)
+	}
+	return bw.Flush()
+}
+
+// submoduleField 返回 porcelain v2 中的 <sub> 字段
+func submoduleField(f FileStatus) string {
+	isGitlink := false
+	for _, m := range []uint32{f.HeadMode, f.IndexMode, f.WorktreeMode, f.Stages[0].Mode, f.Stages[1].Mode, f.Stages[2].Mode} {
+		isGitlink = isGitlink || m == 0160000
+	}
+	if !isGitlink {
+		return "N..."
+	}
+	b := []byte("S...")
+	for i, flag := range []SubmoduleState{SubmoduleNewCommits, SubmoduleModified, SubmoduleUntracked} {
+		if f.Submodule&flag != 0 {
+			b[i+1] = "CMU"[i]
+		}
+	}
+	return string(b)
+}
+
+// WriteLong 以 `git status` 默认的长格式输出
+func WriteLong(w io.Writer, st *Status) error {
+	bw := bufio.NewWriter(w)
+
+	// 1. 分支信息
+	if st.Branch == "" {
+		if st.DetachedAt {
+			fmt.Fprintf(bw, "HEAD detached at %s\n", st.Detached)
+		} else {
+			fmt.Fprintf(bw, "HEAD detached from %s\n", st.Detached)
+		}
+	} else {
+		fmt.Fprintf(bw, "On branch %s\n", st.Branch)
+	}
+	// 各分组之间用空行分隔；紧跟在分支信息之后的第一个分组前没有空行
+	blank := false
+	section := func(title string) {
+		if blank {
+			bw.WriteString("\n")
+		}
+		bw.WriteString(title)
+		blank = true
+	}
+	if st.Tracking != nil {
+		bw.WriteString(st.Tracking.Message() + "\n")
+		blank = true
+	}
+	if !st.Reverting.IsZero() {
+		section(fmt.Sprintf("You are currently reverting commit %s.\n", st.Reverting.String()[:7]))
+		resolved := true
+		for _, f := range st.Files {
+			if f.IsConflicted() {
+				resolved = false
+			}
+		}
+		if resolved {
+			bw.WriteString("  (all conflicts fixed: run \"git revert --continue\")\n")
+		} else {
+			bw.WriteString("  (fix conflicts and run \"git revert --continue\")\n")
+		}
+		bw.WriteString("  (use \"git revert --skip\" to skip this patch)\n")
+		bw.WriteString("  (use \"git revert --abort\" to cancel the revert operation)\n\n")
+		blank = false
+	}
+	if st.Bisecting != "" {
+		// 与 git 相同，这一组自带结尾的空行
+		section(fmt.Sprintf("You are currently bisecting, started from branch '%s'.\n", st.Bisecting))
+		bw.WriteString("  (use \"git bisect reset\" to get back to the original branch)\n\n")
+		blank = false
+	}
+	if st.Sparse >= 0 {
+		section(fmt.Sprintf("You are in a sparse checkout with %d%% of tracked files present.\n\n", st.Sparse))
+		blank = false
+	}
+	if st.Head.IsZero() {
+		bw.WriteString("\nNo commits yet\n")
+		blank = true
+	}
+
+	var staged, conflicted, changed, untracked, ignored []FileStatus
+	hasDeleted, dirtySubmodule := false, false
+	for _, f := range st.Files {
+		switch {
+		case f.Staging == Untracked:
+			untracked = append(untracked, f)
+		case f.Staging == Ignored:
+			ignored = append(ignored, f)
+		case f.IsConflicted():
+			conflicted = append(conflicted, f)
+		default:
+			if f.IsStaged() {
+				staged = append(staged, f)
+			}
+			if f.IsModified() {
+				changed = append(changed, f)
+				hasDeleted = hasDeleted || f.Worktree == Deleted
+				dirtySubmodule = dirtySubmodule || f.Submodule&(SubmoduleModified|SubmoduleUntracked) != 0
+			}
+		}
+	}
+
+	// 2. 各个分组
+	if len(staged) > 0 {
+		section("Changes to be committed:\n")
+		if st.Head.IsZero() {
+			bw.WriteString("  (use \"git rm --cached <file>...\" to unstage)\n")
+		} else {
+			bw.WriteString("  (use \"git restore --staged <file>...\" to unstage)\n")
+		}
+		for _, f := range staged {
+			writeChange(bw, f.Staging, f.Path, "")
+		}
+	}
+
+	if len(conflicted) > 0 {
+		section("Unmerged paths:\n")
+		writeUnmergedHints(bw, st, conflicted)
+		for _, f := range conflicted {
+			label := unmergedLabel(f.Staging, f.Worktree) + ":"
+			fmt.Fprintf(bw, "\t%-17s%s\n", label, formatPath(f.Path, false, false))
+		}
+	}
+
+	if len(changed) > 0 {
+		section("Changes not staged for commit:\n")
+		if hasDeleted {
+			bw.WriteString("  (use \"git add/rm <file>...\" to update what will be committed)\n")
+		} else {
+			bw.WriteString("  (use \"git add <file>...\" to update what will be committed)\n")
+		}
+		bw.WriteString("  (use \"git restore <file>...\" to discard changes in working directory)\n")
+		if dirtySubmodule {
+			bw.WriteString("  (commit or discard the untracked or modified content in submodules)\n")
+		}
+		for _, f := range changed {
+			note := ""
+			if f.Submodule != 0 {
+				note = " (" + f.Submodule.String() + ")"
+			}
+			writeChange(bw, f.Worktree, f.Path, note)
+		}
+	}
+
+	if len(untracked) > 0 {
+		section("Untracked files:\n")
+		bw.WriteString("  (use \"git add <file>...\" to include in what will be committed)\n")
+		for _, f := range untracked {
+			fmt.Fprintf(bw, "\t%s\n", formatPath(f.Path, false, false))
+		}
+	}
+
+	if len(ignored) > 0 {
+		section("Ignored files:\n")
+		bw.WriteString("  (use \"git add -f <file>...\" to include in what will be committed)\n")
+		for _, f := range ignored {
+			fmt.Fprintf(bw, "\t%s\n", formatPath(f.Path, false, false))
+		}
+	}
+
+	// 3. 总结
+	if blank {
+		bw.WriteString("\n")
+	}
+	switch {
+	case len(staged) > 0:
+	case len(changed) > 0 || len(conflicted) > 0:
+		bw.WriteString("no changes added to commit (use \"git add\" and/or \"git commit -a\")\n")
+	case len(untracked) > 0:
+		bw.WriteString("nothing added to commit but untracked files present (use \"git add\" to track)\n")
+	case st.Head.IsZero():
+		bw.WriteString("nothing to commit (create/copy files and use \"git add\" to track)\n")
+	default:
+		bw.WriteString("nothing to commit, working tree clean\n")
+	}
+
+	return bw.Flush()
+}
+
+// writeUnmergedHints 输出 "Unmerged paths:" 下的提示，与 git 一样根据冲突的种类选择措辞
+func writeUnmergedHints(w *bufio.Writer, st *Status, conflicted []FileStatus) {
+	// 1. 不在合并过程中（例如 stash apply 产生的冲突）时，还可以取消暂存
+	if !st.Merging {
+		if st.Head.IsZero() {
+			w.WriteString("  (use \"git rm --cached <file>...\" to unstage)\n")
+		} else {
+			w.WriteString("  (use \"git restore --staged <file>...\" to unstage)\n")
+		}
+	}
+
+	// 2. 按 stage 的组合区分：两边都删除、一边删除一边修改、其他
+	bothDeleted, delModified, notDeleted := false, false, false
+	for _, f := range conflicted {
+		base, ours, theirs := f.Stages[0].Mode != 0, f.Stages[1].Mode != 0, f.Stages[2].Mode != 0
+		switch {
+		case base && !ours && !theirs:
+			bothDeleted = true
+		case base && ours != theirs:
+			delModified = true
+		default:
+			notDeleted = true
+		}
+	}
+	switch {
+	case !bothDeleted && !delModified:
+		w.WriteString("  (use \"git add <file>...\" to mark resolution)\n")
+	case bothDeleted && !delModified && !notDeleted:
+		w.WriteString("  (use \"git rm <file>...\" to mark resolution)\n")
+	default:
+		w.WriteString("  (use \"git add/rm <file>...\" as appropriate to mark resolution)\n")
+	}
+}
+
+// writeChange 输出长格式中的一行修改，note 是附加在路径后的说明（例如子模块的变化）
+func writeChange(w *bufio.Writer, c Code, p, note string) {
+	label := map[Code]string{
+		Added:       "new file:",
+		Modified:    "modified:",
+		Deleted:     "deleted:",
+		TypeChanged: "typechange:",
+	}[c]
+	fmt.Fprintf(w, "\t%-12s%s%s\n", label, formatPath(p, false, false), note)
+}
+
+func unmergedLabel(x, y Code) string {
+	switch string([]byte{byte(x), byte(y)}) {
+	case "DD":
+		return "both deleted"
+	case "AU":
+		return "added by us"
+	case "UD":
+		return "deleted by them"
+	case "UA":
+		return "added by them"
+	case "DU":
+		return "deleted by us"
+	case "AA":
+		return "both added"
+	default:
+		return "both modified"
+	}
+}
+
+// formatPath 按 git 的规则给路径加引号：包含控制字符、引号、反斜杠或非 ASCII 字符时
+// 使用 C 风格的转义；short 格式中包含空格的路径也要加引号。-z 模式下原样输出
+func formatPath(p string, raw, quoteSpace bool) string {
+	if raw {
+		return p
+	}
+
+	needQuote := false
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c < 0x20 || c == '"' || c == '\\' || c >= 0x7f || (quoteSpace && c == ' ') {
+			needQuote = true
+			break
+		}
+	}
+	if !needQuote {
+		return p
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\a':
+			b.WriteString(`\a`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\v':
+			b.WriteString(`\v`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if c < 0x20 || c >= 0x7f {
+				fmt.Fprintf(&b, "\\%03o", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,540 @@
+package status
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/branch"
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/matcher"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// Code 是 `git status --short` 中使用的单字符状态码
+type Code byte
+
+const (
+	Unmodified  Code = ' '
+	Added       Code = 'A'
+	Modified    Code = 'M'
+	Deleted     Code = 'D'
+	TypeChanged Code = 'T'
+	Unmerged    Code = 'U'
+	Untracked   Code = '?'
+	Ignored     Code = '!'
+)
+
+// FileStatus 表示一个路径的状态
+// Staging 比较 HEAD 和索引（X 列），Worktree 比较索引和工作区（Y 列）
+type FileStatus struct {
+	Path     string
+	Staging  Code
+	Worktree Code
+
+	HeadMode, IndexMode, WorktreeMode uint32
+	HeadHash, IndexHash               hash.Hash
+
+	// Submodule 是子模块（gitlink）工作区的变化，有变化时 Worktree 为 Modified
+	Submodule SubmoduleState
+
+	// Stages 记录冲突文件 stage 1-3（base/ours/theirs）的模式和哈希，模式为 0 表示该 stage 不存在
+	Stages [3]struct {
+		Mode uint32
+		Hash hash.Hash
+	}
+}
+
+// IsStaged 判断文件是否有已暂存的修改
+func (f *FileStatus) IsStaged() bool {
+	return !f.IsConflicted() && f.Staging != Unmodified && f.Staging != Untracked && f.Staging != Ignored
+}
+
+// IsModified 判断文件在工作区中是否有未暂存的修改
+func (f *FileStatus) IsModified() bool {
+	return !f.IsConflicted() && f.Worktree != Unmodified && f.Worktree != Untracked && f.Worktree != Ignored
+}
+
+// IsConflicted 判断文件是否处于合并冲突状态
+func (f *FileStatus) IsConflicted() bool {
+	return f.Staging == Unmerged || f.Worktree == Unmerged ||
+		(f.Staging == Added && f.Worktree == Added) ||
+		(f.Staging == Deleted && f.Worktree == Deleted)
+}
+
+// Status 是一次 status 计算的结果
+type Status struct {
+	Branch     string           // 当前分支的短名称，分离 HEAD 时为空
+	Head       hash.Hash        // HEAD 指向的 commit，尚无提交时为零哈希
+	Detached   string           // 分离 HEAD 时最后一次 checkout 的目标（分支、标签或短哈希）
+	DetachedAt bool             // HEAD 仍然指向 Detached（"detached at"），否则为 "detached from"
+	Tracking   *branch.Tracking // 当前分支与上游的比较，没有上游时为 nil
+	Merging    bool             // 正在进行合并或 cherry-pick（存在 MERGE_HEAD 或 CHERRY_PICK_HEAD）
+	Reverting  hash.Hash        // 正在进行 revert 时被撤销的 commit（REVERT_HEAD），否则为零哈希
+	Bisecting  string           // 正在二分查找时开始前所在的分支（分离 HEAD 时是短哈希），否则为空
+	Sparse     int              // 稀疏检出时工作区中存在的被跟踪文件所占的百分比，不是稀疏检出（或索引为空）时为 -1
+	Files      []FileStatus
+}
+
+// Options 控制 status 的计算方式
+type Options struct {
+	// Ignore 判断工作区路径是否被忽略；为 nil 时使用 matcher.LoadIgnore 读取仓库的忽略规则
+	Ignore func(p string, isDir bool) bool
+	// ShowIgnored 为 true 时在结果中包含被忽略的文件
+	ShowIgnored bool
+	// AllUntracked 为 true 时列出未跟踪目录中的每个文件，否则只列出目录本身
+	AllUntracked bool
+	// HideUntracked 为 true 时不显示未跟踪的文件（-uno）
+	HideUntracked bool
+}
+
+// Compute 比较 HEAD tree、索引和工作区，得到每个路径的状态
+// 对于 stat 信息没有变化的文件不会重新计算哈希；重新计算后确认未修改的条目会刷新索引中的 stat 信息
+func Compute(gitDir, workDir string, opts Options) (*Status, error) {
+	if opts.Ignore == nil {
+		ignore, err := matcher.LoadIgnore(gitDir, workDir)
+		if err != nil {
+			return nil, err
+		}
+		opts.Ignore = ignore.Match
+	}
+
+	st := &Status{}
+
+	// 1. HEAD
+	head, err := refs.Read(gitDir, "HEAD")
+	switch {
+	case err == nil:
+		st.Head = head.Hash
+	case !errors.Is(err, refs.ErrNotFound):
+		return nil, err
+	}
+	if target, err := refs.ResolveName(gitDir, "HEAD"); err == nil && strings.HasPrefix(target, "refs/heads/") {
+		st.Branch = strings.TrimPrefix(target, "refs/heads/")
+	} else if !st.Head.IsZero() {
+		st.Detached, st.DetachedAt = branch.Detached(gitDir, st.Head)
+	}
+	if st.Branch != "" && !st.Head.IsZero() {
+		cfg, err := config.Load(gitDir)
+		if err != nil {
+			return nil, err
+		}
+		if st.Tracking, err = branch.CompareWithUpstream(gitDir, cfg, st.Branch); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range []string{"MERGE_HEAD", "CHERRY_PICK_HEAD"} {
+		if _, err := os.Stat(filepath.Join(gitDir, name)); err == nil {
+			st.Merging = true
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(gitDir, "REVERT_HEAD")); err == nil {
+		st.Reverting, _ = hash.ParseHash(strings.TrimSpace(string(data)))
+	}
+	if data, err := os.ReadFile(filepath.Join(gitDir, "BISECT_START")); err == nil {
+		st.Bisecting = strings.TrimSpace(string(data))
+		if _, err := hash.ParseHash(st.Bisecting); err == nil {
+			st.Bisecting = st.Bisecting[:7]
+		}
+	}
+
+	headFiles := make(map[string]tree.TreeEntry)
+	if !st.Head.IsZero() {
+		c, err := commit.ReadCommit(gitDir, st.Head)
+		if err != nil {
+			return nil, err
+		}
+		err = tree.Walk(gitDir, c.Tree, func(p string, e tree.TreeEntry) error {
+			headFiles[p] = e
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 2. 索引
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	st.Sparse = -1
+	if cfg, err := config.Load(gitDir); err != nil {
+		return nil, err
+	} else if cfg.Bool("core.sparsecheckout", false) && len(idx.Entries) > 0 {
+		skipped := 0
+		for _, e := range idx.Entries {
+			if e.SkipWorktree {
+				skipped++
+			}
+		}
+		st.Sparse = 100 - 100*skipped/len(idx.Entries)
+	}
+
+	files := make(map[string]*FileStatus)
+	get := func(p string) *FileStatus {
+		if f, ok := files[p]; ok {
+			return f
+		}
+		f := &FileStatus{Path: p, Staging: Unmodified, Worktree: Unmodified}
+		files[p] = f
+		return f
+	}
+
+	tracked := make(map[string]bool)
+	refreshed := false
+	for i := range idx.Entries {
+		e := &idx.Entries[i]
+		tracked[e.Path] = true
+
+		// 2a. 冲突条目
+		if e.Stage > 0 {
+			f := get(e.Path)
+			f.Stages[e.Stage-1].Mode = e.Mode
+			f.Stages[e.Stage-1].Hash = e.Hash
+			continue
+		}
+
+		f := get(e.Path)
+		f.IndexMode, f.IndexHash = e.Mode, e.Hash
+
+		// 2b. HEAD 与索引比较
+		if he, ok := headFiles[e.Path]; ok {
+			f.HeadMode, f.HeadHash = index.ParseMode(he.Mode), he.Hash
+			f.Staging = compare(f.HeadMode, f.HeadHash, e.Mode, e.Hash)
+		} else if e.IntentToAdd {
+			f.Staging = Unmodified
+		} else {
+			f.Staging = Added
+		}
+
+		// 2c. 索引与工作区比较
+		code, mode, refresh, err := compareWorktree(workDir, idx, e)
+		if err != nil {
+			return nil, err
+		}
+		f.Worktree, f.WorktreeMode = code, mode
+		if refresh {
+			refreshed = true
+		}
+		if e.Mode == 0160000 && code == Unmodified && !e.SkipWorktree && !e.AssumeValid {
+			full := filepath.Join(workDir, filepath.FromSlash(e.Path))
+			if f.Submodule, err = checkSubmodule(full, e.Hash); err != nil {
+				return nil, err
+			}
+			if f.Submodule != 0 {
+				f.Worktree = Modified
+			}
+		}
+	}
+
+	// 3. 在 HEAD 中但不在索引中的文件已被暂存删除
+	for p, he := range headFiles {
+		if !tracked[p] {
+			f := get(p)
+			f.HeadMode, f.HeadHash = index.ParseMode(he.Mode), he.Hash
+			f.Staging = Deleted
+		}
+	}
+
+	// 4. 冲突文件的 XY 由存在哪些 stage 决定
+	for _, f := range files {
+		if f.Stages[0].Mode != 0 || f.Stages[1].Mode != 0 || f.Stages[2].Mode != 0 {
+			f.Staging, f.Worktree = unmergedCodes(f)
+		}
+	}
+
+	// 5. 未跟踪和被忽略的文件
+	w := &walker{workDir: workDir, tracked: sortedKeys(tracked), opts: opts}
+	if err := w.walk(""); err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if f.Staging != Unmodified || f.Worktree != Unmodified {
+			st.Files = append(st.Files, *f)
+		}
+	}
+	// 暂存删除后仍留在工作区的文件会同时以 "D " 和 "??" 出现
+	if !opts.HideUntracked {
+		for _, p := range w.untracked {
+			st.Files = append(st.Files, FileStatus{Path: p, Staging: Untracked, Worktree: Untracked})
+		}
+	}
+	for _, p := range w.ignored {
+		st.Files = append(st.Files, FileStatus{Path: p, Staging: Ignored, Worktree: Ignored})
+	}
+	// 与 git 一致：先列出被跟踪文件的变化，再列出未跟踪文件，最后是被忽略的文件
+	group := func(f FileStatus) int {
+		switch f.Staging {
+		case Untracked:
+			return 1
+		case Ignored:
+			return 2
+		}
+		return 0
+	}
+	sort.Slice(st.Files, func(i, j int) bool {
+		a, b := st.Files[i], st.Files[j]
+		if group(a) != group(b) {
+			return group(a) < group(b)
+		}
+		return a.Path < b.Path
+	})
+
+	// 6. 刷新索引中的 stat 信息，下次就不必再计算哈希；索引被其他进程锁定时忽略
+	if refreshed {
+		idx.Write(gitDir)
+	}
+
+	return st, nil
+}
+
+// compare 比较两个版本的模式和哈希
+func compare(oldMode uint32, oldHash hash.Hash, newMode uint32, newHash hash.Hash) Code {
+	switch {
+	case oldMode&0170000 != newMode&0170000:
+		return TypeChanged
+	case oldMode != newMode || oldHash != newHash:
+		return Modified
+	default:
+		return Unmodified
+	}
+}
+
+// compareWorktree 比较索引条目和工作区文件
+// 返回状态码、工作区文件模式，以及是否更新了条目的 stat 信息
+func compareWorktree(workDir string, idx *index.Index, e *index.Entry) (Code, uint32, bool, error) {
+	if e.SkipWorktree || e.AssumeValid {
+		return Unmodified, e.Mode, false, nil
+	}
+	if e.IntentToAdd {
+		return Added, e.Mode, false, nil
+	}
+
+	full := filepath.Join(workDir, filepath.FromSlash(e.Path))
+	info, err := os.Lstat(full)
+	if err != nil {
+		if os.IsNotExist(err) || isNotDir(err) {
+			return Deleted, 0, false, nil
+		}
+		return 0, 0, false, err
+	}
+
+	current := index.NewEntry(e.Path, info, hash.Hash{})
+	// 子模块（gitlink）在这里只比较目录是否存在，子模块内部的变化由 checkSubmodule 检查
+	if e.Mode == 0160000 {
+		return Unmodified, e.Mode, false, nil
+	}
+	if current.Mode&0170000 != e.Mode&0170000 {
+		return TypeChanged, current.Mode, false, nil
+	}
+
+	// stat 信息一致且不是 racy 条目，认为文件没有变化
+	if e.StatMatches(info) && !idx.IsRacy(e) {
+		return Unmodified, current.Mode, false, nil
+	}
+
+	h, err := HashWorktreeFile(full, info)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if h != e.Hash {
+		return Modified, current.Mode, false, nil
+	}
+	if current.Mode != e.Mode {
+		return Modified, current.Mode, false, nil
+	}
+
+	// 内容没有变化，只是 stat 信息过期了：刷新条目
+	refreshedEntry := index.NewEntry(e.Path, info, e.Hash)
+	refreshedEntry.Stage = e.Stage
+	*e = refreshedEntry
+	return Unmodified, current.Mode, true, nil
+}
+
+// HashWorktreeFile 计算工作区文件作为 blob 的哈希（符号链接使用链接目标）
+func HashWorktreeFile(full string, info os.FileInfo) (hash.Hash, error) {
+	var content []byte
+	var err error
+	if info.Mode()&os.ModeSymlink != 0 {
+		var target string
+		target, err = os.Readlink(full)
+		content = []byte(filepath.ToSlash(target))
+	} else {
+		content, err = os.ReadFile(full)
+	}
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	return hash.ComputeHash(hash.BlobObject, content), nil
+}
+
+// unmergedCodes 根据存在的 stage 计算冲突文件的 XY
+func unmergedCodes(f *FileStatus) (Code, Code) {
+	base, ours, theirs := f.Stages[0].Mode != 0, f.Stages[1].Mode != 0, f.Stages[2].Mode != 0
+	switch {
+	case base && !ours && !theirs:
+		return Deleted, Deleted // both deleted
+	case !base && ours && !theirs:
+		return Added, Unmerged // added by us
+	case base && ours && !theirs:
+		return Unmerged, Deleted // deleted by them
+	case !base && !ours && theirs:
+		return Unmerged, Added // added by them
+	case base && !ours && theirs:
+		return Deleted, Unmerged // deleted by us
+	case !base && ours && theirs:
+		return Added, Added // both added
+	default:
+		return Unmerged, Unmerged // both modified
+	}
+}
+
+func isNotDir(err error) bool {
+	var pathErr *os.PathError
+	return errors.As(err, &pathErr) && strings.Contains(pathErr.Err.Error(), "not a directory")
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// walker 遍历工作区，收集未跟踪和被忽略的路径
+type walker struct {
+	workDir   string
+	tracked   []string // 已排序的索引路径
+	opts      Options
+	untracked []string
+	ignored   []string
+}
+
+// hasTracked 判断目录下是否有被跟踪的文件
+func (w *walker) hasTracked(dir string) bool {
+	prefix := dir + "/"
+	i := sort.SearchStrings(w.tracked, prefix)
+	return i < len(w.tracked) && strings.HasPrefix(w.tracked[i], prefix)
+}
+
+func (w *walker) isTracked(p string) bool {
+	i := sort.SearchStrings(w.tracked, p)
+	return i < len(w.tracked) && w.tracked[i] == p
+}
+
+func (w *walker) walk(dir string) error {
+	items, err := os.ReadDir(filepath.Join(w.workDir, filepath.FromSlash(dir)))
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		name := item.Name()
+		if name == ".git" {
+			continue
+		}
+		p := path.Join(dir, name)
+		isDir := item.IsDir()
+
+		if w.isTracked(p) {
+			continue
+		}
+		if w.opts.Ignore(p, isDir) {
+			if w.opts.ShowIgnored && (!isDir || !w.hasTracked(p)) {
+				switch {
+				case !isDir:
+					w.ignored = append(w.ignored, p)
+				case w.opts.AllUntracked:
+					// -uall 时列出被忽略目录中的每个文件
+					files, err := listFiles(w.workDir, p)
+					if err != nil {
+						return err
+					}
+					w.ignored = append(w.ignored, files...)
+				default:
+					w.ignored = append(w.ignored, p+"/")
+				}
+			}
+			if !isDir || !w.hasTracked(p) {
+				continue
+			}
+		}
+
+		if !isDir {
+			w.untracked = append(w.untracked, p)
+			continue
+		}
+
+		// 包含 .git 的子目录是嵌套仓库，作为整体显示
+		if _, err := os.Stat(filepath.Join(w.workDir, filepath.FromSlash(p), ".git")); err == nil {
+			w.untracked = append(w.untracked, p+"/")
+			continue
+		}
+
+		// 完全未跟踪的目录只显示目录本身（前提是里面有未被忽略的文件）
+		if !w.opts.AllUntracked && !w.hasTracked(p) {
+			sub := &walker{workDir: w.workDir, opts: Options{Ignore: w.opts.Ignore, AllUntracked: true}}
+			if err := sub.walk(p); err != nil {
+				return err
+			}
+			if len(sub.untracked) > 0 {
+				w.untracked = append(w.untracked, p+"/")
+			}
+			if w.opts.ShowIgnored {
+				// 目录中只有被忽略的文件时，整个目录显示为被忽略
+				if ignored := sub.ignoredWithin(p); len(ignored) > 0 && len(sub.untracked) == 0 {
+					w.ignored = append(w.ignored, p+"/")
+				} else {
+					w.ignored = append(w.ignored, ignored...)
+				}
+			}
+			continue
+		}
+
+		if err := w.walk(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ignoredWithin 在未跟踪目录中重新收集被忽略的路径（只在 ShowIgnored 时使用）
+func (w *walker) ignoredWithin(dir string) []string {
+	sub := &walker{workDir: w.workDir, opts: Options{Ignore: w.opts.Ignore, ShowIgnored: true, AllUntracked: true}}
+	if err := sub.walk(dir); err != nil {
+		return nil
+	}
+	return sub.ignored
+}
+
+// listFiles 递归列出目录中的所有文件（不包括目录本身）
+func listFiles(workDir, dir string) ([]string, error) {
+	var files []string
+	root := filepath.Join(workDir, filepath.FromSlash(dir))
+	err := filepath.WalkDir(root, func(full string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(workDir, full)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,78 @@
+package status
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/repository"
+)
+
+// SubmoduleState 记录子模块的工作区相对于父仓库中记录的 commit 的变化
+type SubmoduleState uint8
+
+const (
+	SubmoduleNewCommits SubmoduleState = 1 << iota // 子模块的 HEAD 不是记录的 commit
+	SubmoduleModified                              // 子模块中被跟踪的文件有修改
+	SubmoduleUntracked                             // 子模块中有未跟踪的文件
+)
+
+// String 返回长格式中的说明，例如 "new commits, modified content"
+func (s SubmoduleState) String() string {
+	var parts []string
+	if s&SubmoduleNewCommits != 0 {
+		parts = append(parts, "new commits")
+	}
+	if s&SubmoduleModified != 0 {
+		parts = append(parts, "modified content")
+	}
+	if s&SubmoduleUntracked != 0 {
+		parts = append(parts, "untracked content")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// SubmoduleGitDir 返回子模块工作区 dir 对应的 git 目录；子模块还没有检出（没有 .git）时返回空字符串
+// 子模块的 .git 通常是指向父仓库 .git/modules/<name> 的文件，较老的子模块也可能是目录
+func SubmoduleGitDir(dir string) string {
+	dotGit := filepath.Join(dir, ".git")
+	info, err := os.Stat(dotGit)
+	switch {
+	case err != nil:
+		return ""
+	case info.IsDir():
+		return dotGit
+	}
+	gitDir, err := repository.ReadGitFile(dotGit)
+	if err != nil {
+		return ""
+	}
+	return gitDir
+}
+
+// checkSubmodule 比较子模块的工作区和父仓库索引中记录的 commit
+func checkSubmodule(dir string, recorded hash.Hash) (SubmoduleState, error) {
+	gitDir := SubmoduleGitDir(dir)
+	if gitDir == "" {
+		return 0, nil
+	}
+	var state SubmoduleState
+	if head, err := refs.Resolve(gitDir, "HEAD"); err != nil || head != recorded {
+		state |= SubmoduleNewCommits
+	}
+	st, err := Compute(gitDir, dir, Options{})
+	if err != nil {
+		return 0, err
+	}
+	for _, f := range st.Files {
+		switch {
+		case f.Staging == Untracked:
+			state |= SubmoduleUntracked
+		case f.Staging != Ignored:
+			state |= SubmoduleModified
+		}
+	}
+	return state, nil
+}
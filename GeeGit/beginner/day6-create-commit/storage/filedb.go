@@ -0,0 +1,330 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+// FileDB 是保存在单个文件中的 KV，文件是只追加的日志:
+//
+//	"GEEKV001"
+//	records: crc32(4) op(1) uvarint(len(bucket)) uvarint(len(key)) uvarint(len(value)) bucket key value
+//
+// op 为 'P'（写入）或 'D'（删除），crc32 覆盖 op 之后的所有内容。打开时按顺序重放所有记录，
+// 在内存中记下每个键的值所在的位置；末尾不完整或校验和不符的记录（写入时进程或系统崩溃）被截掉，
+// 中间的记录损坏时打开失败。覆盖和删除留下的旧记录由 Compact 清理。
+// 打开时对文件加排他锁（flock），同一时间只能由一个进程打开，其他进程返回 ErrLocked
+type FileDB struct {
+	mu      sync.RWMutex
+	path    string
+	f       *os.File
+	size    int64
+	garbage int64 // 已经失效的记录占用的字节数
+	index   map[string]map[string]valueRef
+}
+
+// valueRef 是值在文件中的位置
+type valueRef struct {
+	off int64
+	n   int
+	rec int64 // 整条记录的长度
+}
+
+var fileDBMagic = []byte("GEEKV001")
+
+// ErrLocked 表示数据库文件已经被其他进程打开
+var ErrLocked = errors.New("database file is locked by another process")
+
+// errChecksum 表示记录的校验和不符
+var errChecksum = errors.New("checksum mismatch")
+
+const (
+	opPut    = 'P'
+	opDelete = 'D'
+)
+
+// OpenFileDB 打开数据库文件，文件不存在时创建
+func OpenFileDB(path string) (*FileDB, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	db := &FileDB{path: path, f: f, index: make(map[string]map[string]valueRef)}
+	if err := db.load(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return db, nil
+}
+
+// load 重放文件中的所有记录
+func (db *FileDB) load() error {
+	info, err := db.f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		if _, err := db.f.WriteAt(fileDBMagic, 0); err != nil {
+			return err
+		}
+		db.size = int64(len(fileDBMagic))
+		return nil
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(db.f, 0, info.Size()))
+	magic := make([]byte, len(fileDBMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, fileDBMagic) {
+		return fmt.Errorf("not a geegit database file")
+	}
+	pos := int64(len(magic))
+	for pos < info.Size() {
+		op, bucket, key, n, recLen, err := readRecord(r, info.Size()-pos)
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			// 记录超出了文件末尾：之后还有完整的记录时是中间记录的长度损坏，否则是没有写完的最后一条
+			if db.recordAfter(pos, info.Size()) {
+				return fmt.Errorf("record at %d: length exceeds the end of the file", pos)
+			}
+			break
+		} else if errors.Is(err, errChecksum) && pos+recLen == info.Size() {
+			break // 最后一条记录没有完整写入
+		} else if err != nil {
+			return fmt.Errorf("record at %d: %v", pos, err)
+		}
+		db.apply(op, bucket, key, valueRef{off: pos + recLen - int64(n), n: n, rec: recLen})
+		pos += recLen
+	}
+	if pos < info.Size() {
+		if err := db.f.Truncate(pos); err != nil {
+			return err
+		}
+	}
+	db.size = pos
+	return nil
+}
+
+// recordAfter 判断 start 之后的某个位置能否读出一条完整且校验和正确的记录
+// 只在读到超出文件末尾的记录时调用：没写完的记录只会是最后一条，它后面不会再有完整的记录
+func (db *FileDB) recordAfter(start, size int64) bool {
+	for off := start + 1; off < size; off++ {
+		r := bufio.NewReaderSize(io.NewSectionReader(db.f, off, size-off), 64)
+		if _, _, _, _, _, err := readRecord(r, size-off); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// readRecord 读取一条记录，返回值本身不保留（只需要它的位置）；校验和不符时返回 errChecksum 和记录的长度
+// remaining 是文件中剩余的字节数，记录中的长度超出它时与读到文件末尾相同，返回 io.ErrUnexpectedEOF，
+// 不会按损坏的长度分配内存
+func readRecord(r *bufio.Reader, remaining int64) (op byte, bucket, key string, n int, recLen int64, err error) {
+	var sum [4]byte
+	if _, err = io.ReadFull(r, sum[:]); err != nil {
+		return 0, "", "", 0, 0, unexpected(err)
+	}
+	crc := crc32.NewIEEE()
+	if op, err = r.ReadByte(); err != nil {
+		return 0, "", "", 0, 0, unexpected(err)
+	}
+	crc.Write([]byte{op})
+	var lens [3]uint64
+	var header []byte
+	left := uint64(max(remaining-5, 0)) // 去掉校验和与 op 之后剩余的字节数
+	for i := range lens {
+		if lens[i], err = binary.ReadUvarint(r); err != nil {
+			return 0, "", "", 0, 0, unexpected(err)
+		}
+		header = binary.AppendUvarint(header, lens[i])
+	}
+	crc.Write(header)
+	if uint64(len(header)) > left {
+		return 0, "", "", 0, 0, io.ErrUnexpectedEOF
+	}
+	size := left - uint64(len(header))
+	if lens[0] > size || lens[1] > size-lens[0] || lens[2] > size-lens[0]-lens[1] {
+		return 0, "", "", 0, 0, io.ErrUnexpectedEOF
+	}
+	body := make([]byte, lens[0]+lens[1]+lens[2])
+	if _, err = io.ReadFull(r, body); err != nil {
+		return 0, "", "", 0, 0, unexpected(err)
+	}
+	crc.Write(body)
+	recLen = int64(4 + 1 + len(header) + len(body))
+	if crc.Sum32() != binary.BigEndian.Uint32(sum[:]) {
+		return 0, "", "", 0, recLen, errChecksum
+	}
+	if op != opPut && op != opDelete {
+		return 0, "", "", 0, 0, fmt.Errorf("unknown op %q", op)
+	}
+	bucket = string(body[:lens[0]])
+	key = string(body[lens[0] : lens[0]+lens[1]])
+	return op, bucket, key, int(lens[2]), recLen, nil
+}
+
+// unexpected 把读到文件末尾统一为 io.ErrUnexpectedEOF
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// apply 在内存索引中记录一条写入或删除
+func (db *FileDB) apply(op byte, bucket, key string, ref valueRef) {
+	b := db.index[bucket]
+	if old, ok := b[key]; ok {
+		db.garbage += old.rec
+	}
+	if op == opDelete {
+		delete(b, key)
+		db.garbage += ref.rec
+		return
+	}
+	if b == nil {
+		b = make(map[string]valueRef)
+		db.index[bucket] = b
+	}
+	b[key] = ref
+}
+
+// appendRecord 把一条记录追加到文件末尾
+func (db *FileDB) appendRecord(op byte, bucket, key string, value []byte) error {
+	var header []byte
+	header = append(header, op)
+	for _, n := range []int{len(bucket), len(key), len(value)} {
+		header = binary.AppendUvarint(header, uint64(n))
+	}
+	rec := make([]byte, 4, 4+len(header)+len(bucket)+len(key)+len(value))
+	rec = append(rec, header...)
+	rec = append(rec, bucket...)
+	rec = append(rec, key...)
+	rec = append(rec, value...)
+	binary.BigEndian.PutUint32(rec, crc32.ChecksumIEEE(rec[4:]))
+
+	if _, err := db.f.WriteAt(rec, db.size); err != nil {
+		return err
+	}
+	ref := valueRef{off: db.size + int64(len(rec)-len(value)), n: len(value), rec: int64(len(rec))}
+	db.size += int64(len(rec))
+	db.apply(op, bucket, key, ref)
+	return nil
+}
+
+func (db *FileDB) Get(bucket, key string) ([]byte, bool, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	ref, ok := db.index[bucket][key]
+	if !ok {
+		return nil, false, nil
+	}
+	value := make([]byte, ref.n)
+	if _, err := db.f.ReadAt(value, ref.off); err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (db *FileDB) Put(bucket, key string, value []byte) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.appendRecord(opPut, bucket, key, value)
+}
+
+func (db *FileDB) Delete(bucket, key string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, ok := db.index[bucket][key]; !ok {
+		return nil
+	}
+	return db.appendRecord(opDelete, bucket, key, nil)
+}
+
+func (db *FileDB) Keys(bucket string) ([]string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	keys := make([]string, 0, len(db.index[bucket]))
+	for k := range db.index[bucket] {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// Garbage 返回失效的记录占用的字节数，可以据此决定何时调用 Compact
+func (db *FileDB) Garbage() int64 {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.garbage
+}
+
+// Compact 只保留有效的记录，写入临时文件后替换原文件
+func (db *FileDB) Compact() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tmp, err := os.OpenFile(db.path+".tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	next := &FileDB{path: db.path, f: tmp, index: make(map[string]map[string]valueRef)}
+	err = func() error {
+		// 替换之后其他进程打开的是新文件，它同样需要加锁
+		if err := lockFile(tmp); err != nil {
+			return err
+		}
+		if err := next.load(); err != nil {
+			return err
+		}
+		for bucket, b := range db.index {
+			for key, ref := range b {
+				value := make([]byte, ref.n)
+				if _, err := db.f.ReadAt(value, ref.off); err != nil {
+					return err
+				}
+				if err := next.appendRecord(opPut, bucket, key, value); err != nil {
+					return err
+				}
+			}
+		}
+		return tmp.Sync()
+	}()
+	if err == nil {
+		err = os.Rename(tmp.Name(), db.path)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	db.f.Close()
+	db.f, db.size, db.garbage, db.index = tmp, next.size, 0, next.index
+	return nil
+}
+
+// Sync 把写入的内容刷到磁盘
+func (db *FileDB) Sync() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.f.Sync()
+}
+
+// Close 刷新并关闭文件
+func (db *FileDB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if err := db.f.Sync(); err != nil {
+		db.f.Close()
+		return err
+	}
+	return db.f.Close()
+}
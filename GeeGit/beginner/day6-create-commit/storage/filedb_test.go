@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// openTemp 在临时目录中打开数据库文件，测试结束时关闭
+func openTemp(t *testing.T, path string) *FileDB {
+	t.Helper()
+	db, err := OpenFileDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestFileDBReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repo.db")
+	db, err := OpenFileDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kv := range [][2]string{{"a", "1"}, {"b", "2"}, {"a", "3"}} {
+		if err := db.Put("refs", kv[0], []byte(kv[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Delete("refs", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db = openTemp(t, path)
+	if v, ok, err := db.Get("refs", "a"); err != nil || !ok || string(v) != "3" {
+		t.Fatalf("Get(a) = %q, %v, %v", v, ok, err)
+	}
+	if _, ok, _ := db.Get("refs", "b"); ok {
+		t.Fatal("deleted key b is still present")
+	}
+	if db.Garbage() == 0 {
+		t.Fatal("Garbage = 0 after overwrite and delete")
+	}
+	if err := db.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := db.Get("refs", "a"); err != nil || !ok || string(v) != "3" {
+		t.Fatalf("Get(a) after Compact = %q, %v, %v", v, ok, err)
+	}
+}
+
+// appendRaw 在文件末尾追加任意字节，模拟损坏或写了一半的记录
+func appendRaw(t *testing.T, path string, data []byte) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileDBHugeLength(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repo.db")
+	db, err := OpenFileDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put("objects", "k", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	good, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 值的长度远远超过文件大小：不能按它分配内存，当作不完整的记录截掉
+	rec := []byte{0, 0, 0, 0, opPut}
+	rec = binary.AppendUvarint(rec, 7)
+	rec = binary.AppendUvarint(rec, 1)
+	rec = binary.AppendUvarint(rec, 1<<62)
+	binary.BigEndian.PutUint32(rec, crc32.ChecksumIEEE(rec[4:]))
+	appendRaw(t, path, append(rec, "objectsk"...))
+
+	db = openTemp(t, path)
+	if v, ok, err := db.Get("objects", "k"); err != nil || !ok || string(v) != "v" {
+		t.Fatalf("Get(k) = %q, %v, %v", v, ok, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != good.Size() {
+		t.Fatalf("size = %d, want %d", info.Size(), good.Size())
+	}
+}
+
+func TestFileDBCorruptMiddleLength(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repo.db")
+	db, err := OpenFileDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put("objects", "k", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	good, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 中间一条记录的值长度损坏，超出了文件末尾，后面还有完整的记录：
+	// 不能当作没写完的最后一条截掉（那样会丢掉后面的记录），打开失败且文件不变
+	bad := record("objects", "a", "1")
+	bad[7] = 0x7f
+	data := append(bad, record("objects", "b", "2")...)
+	appendRaw(t, path, data)
+	if db, err := OpenFileDB(path); err == nil {
+		db.Close()
+		t.Fatal("OpenFileDB succeeded with a corrupt length in the middle")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := good.Size() + int64(len(data)); info.Size() != want {
+		t.Fatalf("size = %d, want %d", info.Size(), want)
+	}
+}
+
+// record 按文件格式编码一条写入记录
+func record(bucket, key, value string) []byte {
+	rec := []byte{0, 0, 0, 0, opPut}
+	for _, n := range []int{len(bucket), len(key), len(value)} {
+		rec = binary.AppendUvarint(rec, uint64(n))
+	}
+	rec = append(rec, bucket+key+value...)
+	binary.BigEndian.PutUint32(rec, crc32.ChecksumIEEE(rec[4:]))
+	return rec
+}
+
+func TestFileDBTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repo.db")
+	db, err := OpenFileDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put("refs", "HEAD", []byte("ref: refs/heads/main")); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	good, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 最后一条记录长度完整，但内容没有写入（例如崩溃后留下的全零数据）：截掉
+	torn := record("refs", "refs/heads/main", "0123456789012345678901234567890123456789")
+	copy(torn[len(torn)-10:], make([]byte, 10))
+	appendRaw(t, path, torn)
+
+	db, err = OpenFileDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := db.Get("refs", "refs/heads/main"); ok {
+		t.Fatal("torn record was applied")
+	}
+	if v, ok, err := db.Get("refs", "HEAD"); err != nil || !ok || string(v) != "ref: refs/heads/main" {
+		t.Fatalf("Get(HEAD) = %q, %v, %v", v, ok, err)
+	}
+	db.Close()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != good.Size() {
+		t.Fatalf("size = %d, want %d", info.Size(), good.Size())
+	}
+
+	// 损坏的记录后面还有完整的记录：不是写了一半，打开失败
+	bad := record("refs", "a", "1")
+	bad[len(bad)-1] ^= 0xff
+	appendRaw(t, path, append(bad, record("refs", "b", "2")...))
+	if db, err := OpenFileDB(path); err == nil {
+		db.Close()
+		t.Fatal("OpenFileDB succeeded with a corrupt record in the middle")
+	}
+}
+
+func TestFileDBLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repo.db")
+	db, err := OpenFileDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFileDB(path); !errors.Is(err, ErrLocked) {
+		t.Fatalf("second OpenFileDB = %v, want ErrLocked", err)
+	}
+
+	// Compact 替换文件之后，新文件同样被锁住
+	if err := db.Put("refs", "a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFileDB(path); !errors.Is(err, ErrLocked) {
+		t.Fatalf("OpenFileDB after Compact = %v, want ErrLocked", err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db = openTemp(t, path)
+	if v, ok, err := db.Get("refs", "a"); err != nil || !ok || string(v) != "1" {
+		t.Fatalf("Get(a) = %q, %v, %v", v, ok, err)
+	}
+}
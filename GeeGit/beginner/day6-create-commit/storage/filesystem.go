@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"bytes"
+	"sort"
+
+	"geegit/beginner/day6-create-commit/alternates"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/pack"
+	"geegit/beginner/day6-create-commit/refs"
+)
+
+// Filesystem 是保存在 .git 目录中的仓库，各方法直接调用 object、refs 和 index 包中对应的函数
+type Filesystem struct {
+	GitDir string
+}
+
+// NewFilesystem 返回 git 目录 gitDir 对应的存储后端
+func NewFilesystem(gitDir string) *Filesystem {
+	return &Filesystem{GitDir: gitDir}
+}
+
+func (s *Filesystem) ReadObject(h hash.Hash) (*object.Object, error) {
+	return object.Read(s.GitDir, h)
+}
+
+func (s *Filesystem) WriteObject(objType hash.ObjectType, content []byte) (hash.Hash, error) {
+	return object.Write(s.GitDir, objType, content)
+}
+
+func (s *Filesystem) HasObject(h hash.Hash) bool {
+	return object.Exists(s.GitDir, h)
+}
+
+// ObjectHashes 返回松散对象和 packfile 中的所有对象，包括 alternates 中的
+func (s *Filesystem) ObjectHashes() ([]hash.Hash, error) {
+	seen := make(map[hash.Hash]bool)
+	for _, dir := range alternates.ObjectDirs(s.GitDir) {
+		loose, err := object.ListLooseIn(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range loose {
+			seen[h] = true
+		}
+		packs, err := pack.OpenDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range packs {
+			for _, h := range p.Index.Hashes {
+				seen[h] = true
+			}
+		}
+	}
+	return sortedHashes(seen), nil
+}
+
+func (s *Filesystem) Reference(name string) (*refs.Ref, error) {
+	return refs.Read(s.GitDir, name)
+}
+
+func (s *Filesystem) UpdateReference(name string, newHash hash.Hash, oldHash *hash.Hash, msg string) error {
+	return refs.Update(s.GitDir, name, newHash, oldHash, msg)
+}
+
+func (s *Filesystem) SetSymbolicReference(name, target, msg string) error {
+	return refs.SetSymbolic(s.GitDir, name, target, msg)
+}
+
+func (s *Filesystem) RemoveReference(name string, oldHash *hash.Hash) error {
+	return refs.Delete(s.GitDir, name, oldHash)
+}
+
+func (s *Filesystem) References() ([]refs.Ref, error) {
+	return refs.List(s.GitDir)
+}
+
+func (s *Filesystem) Index() (*index.Index, error) {
+	return index.Read(s.GitDir)
+}
+
+func (s *Filesystem) SetIndex(idx *index.Index) error {
+	return idx.Write(s.GitDir)
+}
+
+// sortedHashes 返回集合中的哈希，按哈希排序
+func sortedHashes(set map[hash.Hash]bool) []hash.Hash {
+	list := make([]hash.Hash, 0, len(set))
+	for h := range set {
+		list = append(list, h)
+	}
+	sort.Slice(list, func(i, j int) bool { return bytes.Compare(list[i][:], list[j][:]) < 0 })
+	return list
+}
@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/refs"
+)
+
+// KV 是按桶（bucket）划分的键值数据库，实现它就可以把仓库保存在 bbolt、SQLite 等数据库中
+type KV interface {
+	// Get 读取键的值，键不存在时返回 false
+	Get(bucket, key string) ([]byte, bool, error)
+	// Put 写入键的值，覆盖原来的值
+	Put(bucket, key string, value []byte) error
+	// Delete 删除键，键不存在时什么也不做
+	Delete(bucket, key string) error
+	// Keys 返回桶中的所有键
+	Keys(bucket string) ([]string, error)
+}
+
+// 桶的名字：对象以十六进制哈希为键，值与松散对象文件相同（zlib 压缩的 "<type> <size>\0<content>"）；
+// 引用以完整名称为键，值与引用文件相同（十六进制哈希，或者 "ref: <target>"）；
+// 索引只有一个键 "index"，值与 .git/index 文件相同
+const (
+	objectsBucket = "objects"
+	refsBucket    = "refs"
+	indexBucket   = "index"
+)
+
+// maxSymrefDepth 是解析符号引用链的最大深度，与 refs 包一致
+const maxSymrefDepth = 5
+
+// KVStorage 是保存在键值数据库中的仓库，没有 reflog
+type KVStorage struct {
+	db KV
+	mu sync.Mutex // 保证引用的检查和更新是原子的
+}
+
+// NewKV 返回保存在 db 中的存储后端
+func NewKV(db KV) *KVStorage {
+	return &KVStorage{db: db}
+}
+
+// NewMemory 返回只保存在内存中的存储后端，进程退出后内容丢失
+func NewMemory() *KVStorage {
+	return NewKV(&memoryKV{buckets: make(map[string]map[string][]byte)})
+}
+
+func (s *KVStorage) ReadObject(h hash.Hash) (*object.Object, error) {
+	raw, ok, err := s.db.Get(objectsBucket, h.String())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", object.ErrNotFound, h.String())
+	}
+	objType, content, err := object.ParseLoose(raw)
+	if err != nil {
+		return nil, fmt.Errorf("object %s: %v", h.String(), err)
+	}
+	return &object.Object{Hash: h, Type: objType, Content: content}, nil
+}
+
+func (s *KVStorage) WriteObject(objType hash.ObjectType, content []byte) (hash.Hash, error) {
+	h := hash.ComputeHash(objType, content)
+	if s.HasObject(h) {
+		return h, nil
+	}
+	raw, err := object.EncodeLoose(objType, content)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	return h, s.db.Put(objectsBucket, h.String(), raw)
+}
+
+func (s *KVStorage) HasObject(h hash.Hash) bool {
+	_, ok, err := s.db.Get(objectsBucket, h.String())
+	return err == nil && ok
+}
+
+func (s *KVStorage) ObjectHashes() ([]hash.Hash, error) {
+	keys, err := s.db.Keys(objectsBucket)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[hash.Hash]bool, len(keys))
+	for _, k := range keys {
+		h, err := hash.ParseHash(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid object key %q", k)
+		}
+		set[h] = true
+	}
+	return sortedHashes(set), nil
+}
+
+func (s *KVStorage) Reference(name string) (*refs.Ref, error) {
+	ref := &refs.Ref{Name: name}
+	current := name
+	for depth := 0; depth < maxSymrefDepth; depth++ {
+		data, ok, err := s.db.Get(refsBucket, current)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", refs.ErrNotFound, current)
+		}
+		if target, ok := strings.CutPrefix(string(data), "ref: "); ok {
+			if ref.Target == "" {
+				ref.Target = target
+			}
+			current = target
+			continue
+		}
+		h, err := hash.ParseHash(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference %s: %v", current, err)
+		}
+		ref.Hash = h
+		return ref, nil
+	}
+	return nil, fmt.Errorf("reference %s: too many levels of symbolic refs", name)
+}
+
+// resolveName 跟随符号引用，返回最终被写入的引用名称（即使它还不存在）
+func (s *KVStorage) resolveName(name string) (string, error) {
+	for depth := 0; depth < maxSymrefDepth; depth++ {
+		data, ok, err := s.db.Get(refsBucket, name)
+		if err != nil {
+			return "", err
+		}
+		target, isSym := strings.CutPrefix(string(data), "ref: ")
+		if !ok || !isSym {
+			return name, nil
+		}
+		name = target
+	}
+	return "", fmt.Errorf("reference %s: too many levels of symbolic refs", name)
+}
+
+// verifyOld 检查引用的当前值是否符合预期，错误信息与 refs.Update 一致
+func (s *KVStorage) verifyOld(name string, oldHash *hash.Hash) error {
+	if oldHash == nil {
+		return nil
+	}
+	current, err := s.Reference(name)
+	if err != nil && !errors.Is(err, refs.ErrNotFound) {
+		return err
+	}
+	switch {
+	case err != nil && !oldHash.IsZero():
+		return fmt.Errorf("cannot lock ref '%s': unable to resolve reference", name)
+	case err == nil && current.Hash != *oldHash:
+		return fmt.Errorf("cannot lock ref '%s': is at %s but expected %s",
+			name, current.Hash.String(), oldHash.String())
+	}
+	return nil
+}
+
+func (s *KVStorage) UpdateReference(name string, newHash hash.Hash, oldHash *hash.Hash, msg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name, err := s.resolveName(name)
+	if err != nil {
+		return err
+	}
+	if name != "HEAD" {
+		if err := refs.CheckName(name); err != nil {
+			return err
+		}
+	}
+	if err := s.verifyOld(name, oldHash); err != nil {
+		return err
+	}
+	return s.db.Put(refsBucket, name, []byte(newHash.String()))
+}
+
+func (s *KVStorage) SetSymbolicReference(name, target, msg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.db.Put(refsBucket, name, []byte("ref: "+target))
+}
+
+func (s *KVStorage) RemoveReference(name string, oldHash *hash.Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name, err := s.resolveName(name)
+	if err != nil {
+		return err
+	}
+	if err := s.verifyOld(name, oldHash); err != nil {
+		return err
+	}
+	return s.db.Delete(refsBucket, name)
+}
+
+// References 列出 refs/ 下的所有引用；指向不存在的引用的符号引用被跳过
+func (s *KVStorage) References() ([]refs.Ref, error) {
+	keys, err := s.db.Keys(refsBucket)
+	if err != nil {
+		return nil, err
+	}
+	var list []refs.Ref
+	for _, name := range keys {
+		if !strings.HasPrefix(name, "refs/") {
+			continue
+		}
+		ref, err := s.Reference(name)
+		if errors.Is(err, refs.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		list = append(list, refs.Ref{Name: name, Hash: ref.Hash})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (s *KVStorage) Index() (*index.Index, error) {
+	data, ok, err := s.db.Get(indexBucket, "index")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return &index.Index{Version: 2}, nil
+	}
+	return index.Parse(data)
+}
+
+func (s *KVStorage) SetIndex(idx *index.Index) error {
+	return s.db.Put(indexBucket, "index", index.Encode(idx))
+}
+
+// memoryKV 是保存在内存中的 KV
+type memoryKV struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+func (m *memoryKV) Get(bucket, key string) ([]byte, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v, ok := m.buckets[bucket][key]
+	return v, ok, nil
+}
+
+func (m *memoryKV) Put(bucket, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.buckets[bucket] == nil {
+		m.buckets[bucket] = make(map[string][]byte)
+	}
+	m.buckets[bucket][key] = append([]byte(nil), value...)
+	return nil
+}
+
+func (m *memoryKV) Delete(bucket, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.buckets[bucket], key)
+	return nil
+}
+
+func (m *memoryKV) Keys(bucket string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]string, 0, len(m.buckets[bucket]))
+	for k := range m.buckets[bucket] {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package storage
+
+import "os"
+
+// lockFile 在没有 flock 的平台上不加锁，需要调用方保证只有一个进程打开数据库文件
+func lockFile(f *os.File) error { return nil }
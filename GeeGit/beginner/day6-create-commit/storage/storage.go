@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"geegit/beginner/day6-create-commit/blob"
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/tag"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+// 仓库的对象库和引用可以保存在不同的后端中：
+//
+//	Filesystem  普通的 .git 目录，与其他包中按 gitDir 操作的函数完全一致
+//	NewMemory   只保存在内存中，适合测试，不接触磁盘
+//	KVStorage   保存在任意键值数据库（KV 接口）中，例如单个数据库文件（OpenFileDB），也可以接入 bbolt、SQLite 等
+//
+// 后端只负责保存；对象的格式、引用的语义（符号引用、更新前检查旧值）和索引的格式与 git 一致，
+// 错误同样使用 object.ErrNotFound 和 refs.ErrNotFound
+
+// Storer 是仓库的存储后端
+type Storer interface {
+	ObjectStorer
+	ReferenceStorer
+	IndexStorer
+}
+
+// ObjectStorer 保存对象
+type ObjectStorer interface {
+	// ReadObject 读取对象，不存在时返回 object.ErrNotFound
+	ReadObject(h hash.Hash) (*object.Object, error)
+	// WriteObject 写入对象并返回它的哈希，对象已经存在时不重复写入
+	WriteObject(objType hash.ObjectType, content []byte) (hash.Hash, error)
+	// HasObject 判断对象是否存在
+	HasObject(h hash.Hash) bool
+	// ObjectHashes 返回所有对象的哈希，按哈希排序
+	ObjectHashes() ([]hash.Hash, error)
+}
+
+// ReferenceStorer 保存引用，语义与 refs 包中的同名函数一致
+type ReferenceStorer interface {
+	// Reference 读取引用并跟随符号引用（refs.Read），不存在时返回 refs.ErrNotFound
+	Reference(name string) (*refs.Ref, error)
+	// UpdateReference 更新引用，name 是符号引用时更新它指向的引用；oldHash 不为 nil 时先检查当前值（refs.Update）
+	// msg 是 reflog 中记录的原因，没有 reflog 的后端忽略它
+	UpdateReference(name string, newHash hash.Hash, oldHash *hash.Hash, msg string) error
+	// SetSymbolicReference 把 name 设置为指向 target 的符号引用（refs.SetSymbolic）
+	SetSymbolicReference(name, target, msg string) error
+	// RemoveReference 删除引用，name 是符号引用时删除它指向的引用（refs.Delete）
+	RemoveReference(name string, oldHash *hash.Hash) error
+	// References 列出 refs/ 下的所有引用，按名称排序（refs.List）
+	References() ([]refs.Ref, error)
+}
+
+// IndexStorer 保存索引（暂存区）
+type IndexStorer interface {
+	// Index 读取索引，还没有索引时返回空索引（index.Read）
+	Index() (*index.Index, error)
+	// SetIndex 写入索引，替换原来的内容（Index.Write）
+	SetIndex(idx *index.Index) error
+}
+
+// readTyped 读取对象并检查类型
+func readTyped(s ObjectStorer, h hash.Hash, want hash.ObjectType) (*object.Object, error) {
+	obj, err := s.ReadObject(h)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Type != want {
+		return nil, fmt.Errorf("expected %s, got %s", want, obj.Type)
+	}
+	return obj, nil
+}
+
+// ReadBlob 从存储后端读取 blob 对象
+func ReadBlob(s ObjectStorer, h hash.Hash) (*blob.Blob, error) {
+	obj, err := readTyped(s, h, hash.BlobObject)
+	if err != nil {
+		return nil, err
+	}
+	return &blob.Blob{Hash: h, Data: obj.Content}, nil
+}
+
+// ReadTree 从存储后端读取 tree 对象
+func ReadTree(s ObjectStorer, h hash.Hash) (*tree.Tree, error) {
+	obj, err := readTyped(s, h, hash.TreeObject)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := tree.ParseEntries(obj.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tree entries: %v", err)
+	}
+	return &tree.Tree{Hash: h, Entries: entries}, nil
+}
+
+// ReadCommit 从存储后端读取 commit 对象
+func ReadCommit(s ObjectStorer, h hash.Hash) (*commit.Commit, error) {
+	obj, err := readTyped(s, h, hash.CommitObject)
+	if err != nil {
+		return nil, err
+	}
+	c, err := commit.ParseCommit(obj.Content)
+	if err != nil {
+		return nil, err
+	}
+	c.Hash = h
+	return c, nil
+}
+
+// ReadTag 从存储后端读取 tag 对象
+func ReadTag(s ObjectStorer, h hash.Hash) (*tag.Tag, error) {
+	obj, err := readTyped(s, h, hash.TagObject)
+	if err != nil {
+		return nil, err
+	}
+	t, err := tag.ParseTag(obj.Content)
+	if err != nil {
+		return nil, err
+	}
+	t.Hash = h
+	return t, nil
+}
+
+// WriteTree 把条目按 git 的顺序排列后写入 tree 对象
+func WriteTree(s ObjectStorer, entries []tree.TreeEntry) (hash.Hash, error) {
+	return s.WriteObject(hash.TreeObject, tree.BuildTreeContent(entries))
+}
+
+// WriteIndexTree 把索引写成 tree 对象（tree.WriteIndex），返回根 tree 的哈希
+func WriteIndexTree(s ObjectStorer, idx *index.Index) (hash.Hash, error) {
+	return tree.FromIndex(idx, func(entries []tree.TreeEntry) (hash.Hash, error) {
+		return WriteTree(s, entries)
+	})
+}
+
+// WriteCommit 写入 commit 对象
+func WriteCommit(s ObjectStorer, c *commit.Commit) (hash.Hash, error) {
+	return s.WriteObject(hash.CommitObject, commit.Encode(c))
+}
+
+// WriteTag 写入 tag 对象
+func WriteTag(s ObjectStorer, t *tag.Tag) (hash.Hash, error) {
+	return s.WriteObject(hash.TagObject, tag.Encode(t))
+}
+
+// Copy 把 src 中的所有对象和引用复制到 dst（应该是新建的空仓库），例如把磁盘上的仓库导入数据库文件
+// HEAD 保持为符号引用（分离时直接指向 commit）；refs/ 下的引用按 References 的结果复制为普通引用
+func Copy(dst, src Storer) error {
+	hashes, err := src.ObjectHashes()
+	if err != nil {
+		return err
+	}
+	for _, h := range hashes {
+		if dst.HasObject(h) {
+			continue
+		}
+		obj, err := src.ReadObject(h)
+		if err != nil {
+			return err
+		}
+		if _, err := dst.WriteObject(obj.Type, obj.Content); err != nil {
+			return err
+		}
+	}
+
+	list, err := src.References()
+	if err != nil {
+		return err
+	}
+	for _, r := range list {
+		if err := dst.UpdateReference(r.Name, r.Hash, nil, ""); err != nil {
+			return err
+		}
+	}
+	head, err := src.Reference("HEAD")
+	switch {
+	case errors.Is(err, refs.ErrNotFound):
+		return nil // 还没有任何 commit
+	case err != nil:
+		return err
+	case head.Target != "":
+		return dst.SetSymbolicReference("HEAD", head.Target, "")
+	default:
+		return dst.UpdateReference("HEAD", head.Hash, nil, "")
+	}
+}
@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/tree"
+)
+
+func TestMemoryObjects(t *testing.T) {
+	s := NewMemory()
+	h, err := s.WriteObject(hash.BlobObject, []byte("hello\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 与 git hash-object 的结果相同
+	if want := "ce013625030ba8dba906f756967f9e9ca394464a"; h.String() != want {
+		t.Fatalf("hash = %s, want %s", h, want)
+	}
+	if !s.HasObject(h) {
+		t.Fatal("HasObject = false after WriteObject")
+	}
+	b, err := ReadBlob(s, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b.Data) != "hello\n" {
+		t.Fatalf("content = %q", b.Data)
+	}
+	if _, err := ReadTree(s, h); err == nil {
+		t.Fatal("ReadTree on a blob succeeded")
+	}
+
+	var missing hash.Hash
+	missing[0] = 1
+	if _, err := s.ReadObject(missing); !errors.Is(err, object.ErrNotFound) {
+		t.Fatalf("ReadObject(missing) = %v, want object.ErrNotFound", err)
+	}
+	list, err := s.ObjectHashes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0] != h {
+		t.Fatalf("ObjectHashes = %v", list)
+	}
+}
+
+func TestMemoryReferences(t *testing.T) {
+	s := NewMemory()
+	a, _ := s.WriteObject(hash.BlobObject, []byte("a"))
+	b, _ := s.WriteObject(hash.BlobObject, []byte("b"))
+
+	if err := s.SetSymbolicReference("HEAD", "refs/heads/main", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Reference("HEAD"); !errors.Is(err, refs.ErrNotFound) {
+		t.Fatalf("Reference(HEAD) on unborn branch = %v, want refs.ErrNotFound", err)
+	}
+
+	// 更新 HEAD 实际更新它指向的分支；零哈希的旧值表示引用必须还不存在
+	zero := hash.Hash{}
+	if err := s.UpdateReference("HEAD", a, &zero, ""); err != nil {
+		t.Fatal(err)
+	}
+	head, err := s.Reference("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Target != "refs/heads/main" || head.Hash != a {
+		t.Fatalf("HEAD = %+v", head)
+	}
+	if err := s.UpdateReference("refs/heads/main", b, &zero, ""); err == nil {
+		t.Fatal("update with a stale old value succeeded")
+	}
+	if err := s.UpdateReference("refs/heads/main", b, &a, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateReference("refs/heads/bad..name", b, nil, ""); err == nil {
+		t.Fatal("update of an invalid ref name succeeded")
+	}
+
+	list, err := s.References()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != "refs/heads/main" || list[0].Hash != b {
+		t.Fatalf("References = %+v", list)
+	}
+	if err := s.RemoveReference("HEAD", &a); err == nil {
+		t.Fatal("remove with a stale old value succeeded")
+	}
+	if err := s.RemoveReference("HEAD", &b); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Reference("refs/heads/main"); !errors.Is(err, refs.ErrNotFound) {
+		t.Fatalf("Reference after remove = %v, want refs.ErrNotFound", err)
+	}
+}
+
+func TestMemoryIndex(t *testing.T) {
+	s := NewMemory()
+	idx, err := s.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Entries) != 0 {
+		t.Fatalf("new index has %d entries", len(idx.Entries))
+	}
+
+	a, _ := s.WriteObject(hash.BlobObject, []byte("a\n"))
+	b, _ := s.WriteObject(hash.BlobObject, []byte("b\n"))
+	idx.Add(index.Entry{Path: "src/b.txt", Mode: 0100644, Hash: b})
+	idx.Add(index.Entry{Path: "a.txt", Mode: 0100755, Hash: a})
+	if err := s.SetIndex(idx); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Entries) != 2 || got.Entries[0].Path != "a.txt" || got.Entries[1].Hash != b {
+		t.Fatalf("Index = %+v", got.Entries)
+	}
+
+	// 与 git write-tree 相同：子目录写成单独的 tree
+	root, err := WriteIndexTree(s, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt, err := ReadTree(s, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.Entries) != 2 || rt.Entries[0].Name != "a.txt" || rt.Entries[0].Mode != "100755" ||
+		rt.Entries[1].Name != "src" || rt.Entries[1].Mode != "40000" {
+		t.Fatalf("root tree = %+v", rt.Entries)
+	}
+	sub, err := ReadTree(s, rt.Entries[1].Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sub.Entries) != 1 || sub.Entries[0].Name != "b.txt" {
+		t.Fatalf("src tree = %+v", sub.Entries)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	src := NewMemory()
+	empty, err := WriteTree(src, []tree.TreeEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := commit.Signature{Name: "A", Email: "a@example.com", When: time.Unix(1700000000, 0).UTC()}
+	c, err := WriteCommit(src, &commit.Commit{Tree: empty, Author: sig, Committer: sig, Message: "init\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.UpdateReference("refs/heads/main", c, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SetSymbolicReference("HEAD", "refs/heads/main", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := NewMemory()
+	if err := Copy(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	head, err := dst.Reference("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Target != "refs/heads/main" || head.Hash != c {
+		t.Fatalf("HEAD = %+v", head)
+	}
+	got, err := ReadCommit(dst, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Tree != empty || got.Message != "init\n" {
+		t.Fatalf("commit = %+v", got)
+	}
+}
@@ -0,0 +1,199 @@
+package submodule
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+	"geegit/beginner/day6-create-commit/remote"
+	"geegit/beginner/day6-create-commit/status"
+)
+
+// Submodule 是父仓库中的一个子模块
+// 父仓库的 tree 和索引中只记录子模块路径上的 gitlink（指向子模块中某个 commit），
+// 名字、地址等信息记录在工作区根目录的 .gitmodules 中
+type Submodule struct {
+	Name   string    // .gitmodules 中 [submodule "<name>"] 的名字
+	Path   string    // 在父仓库中的路径（使用 "/" 分隔）
+	URL    string    // .gitmodules 中的地址，可以是相对父仓库远程地址的 "./" 或 "../" 路径
+	Branch string    // submodule.<name>.branch
+	Commit hash.Hash // 索引中记录的 commit
+	Stage  int       // 索引中的 stage，大于 0 表示有合并冲突
+}
+
+// Parse 解析 .gitmodules 文件的内容，按出现的顺序返回其中声明的子模块
+// .gitmodules 的格式与配置文件相同，每个子模块一个 [submodule "<name>"] 节
+func Parse(data []byte) ([]*Submodule, error) {
+	entries, err := config.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	var list []*Submodule
+	byName := make(map[string]*Submodule)
+	for _, e := range entries {
+		if e.Section != "submodule" || e.Subsection == "" {
+			continue
+		}
+		s, ok := byName[e.Subsection]
+		if !ok {
+			s = &Submodule{Name: e.Subsection}
+			byName[e.Subsection] = s
+			list = append(list, s)
+		}
+		switch e.Key {
+		case "path":
+			s.Path = strings.Trim(e.Value, "/")
+		case "url":
+			s.URL = e.Value
+		case "branch":
+			s.Branch = e.Value
+		}
+	}
+	return list, nil
+}
+
+// List 返回索引中的所有 gitlink，并用 .gitmodules 中的信息补全名字和地址，按路径排序
+// .gitmodules 中没有记录的 gitlink 以路径作为名字
+func List(gitDir, workDir string) ([]*Submodule, error) {
+	declared, err := load(workDir)
+	if err != nil {
+		return nil, err
+	}
+	byPath := make(map[string]*Submodule)
+	for _, s := range declared {
+		if s.Path != "" {
+			byPath[s.Path] = s
+		}
+	}
+
+	idx, err := index.Read(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	var list []*Submodule
+	seen := make(map[string]bool)
+	for _, e := range idx.Entries {
+		if e.Mode != 0160000 || seen[e.Path] {
+			continue
+		}
+		seen[e.Path] = true
+		s := &Submodule{Name: e.Path, Path: e.Path}
+		if d, ok := byPath[e.Path]; ok {
+			copied := *d
+			s = &copied
+		}
+		s.Commit, s.Stage = e.Hash, int(e.Stage)
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list, nil
+}
+
+// load 读取工作区中的 .gitmodules，文件不存在时返回空列表
+func load(workDir string) ([]*Submodule, error) {
+	data, err := os.ReadFile(filepath.Join(workDir, ".gitmodules"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	list, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("bad config in .gitmodules: %v", err)
+	}
+	return list, nil
+}
+
+// Match 按命令行上的路径筛选子模块，paths 为空时返回全部
+// 与 git 一致，路径可以是子模块本身或包含子模块的目录；没有匹配任何子模块的路径返回错误
+func Match(list []*Submodule, paths []string) ([]*Submodule, error) {
+	if len(paths) == 0 {
+		return list, nil
+	}
+	var result []*Submodule
+	for _, p := range paths {
+		p = strings.Trim(path.Clean(filepath.ToSlash(p)), "/")
+		found := false
+		for _, s := range list {
+			if p == "." || s.Path == p || strings.HasPrefix(s.Path, p+"/") {
+				found = true
+				if !contains(result, s) {
+					result = append(result, s)
+				}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("pathspec '%s' did not match any file(s) known to git", p)
+		}
+	}
+	return result, nil
+}
+
+func contains(list []*Submodule, s *Submodule) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// GitDir 返回子模块的 git 目录：父仓库的 .git/modules/<name>
+func GitDir(gitDir, name string) string {
+	return gitdir.Path(gitDir, "modules/"+name)
+}
+
+// IsActive 判断子模块是否已经初始化（submodule init 写入了 submodule.<name>.url）
+func IsActive(cfg *config.Config, s *Submodule) bool {
+	_, ok := cfg.Get("submodule." + s.Name + ".url")
+	return ok
+}
+
+// IsPopulated 判断子模块是否已经检出到工作区（目录中有 .git）
+func IsPopulated(workDir string, s *Submodule) bool {
+	return status.SubmoduleGitDir(filepath.Join(workDir, filepath.FromSlash(s.Path))) != ""
+}
+
+// ResolveURL 把 .gitmodules 中的相对地址（"./" 或 "../" 开头）转换为绝对地址
+// 相对地址以父仓库默认远程（通常是 origin）的地址为基准，没有远程时以父仓库的工作区目录为基准
+func ResolveURL(cfg *config.Config, workDir, url string) string {
+	if !strings.HasPrefix(url, "./") && !strings.HasPrefix(url, "../") {
+		return url
+	}
+	base := filepath.ToSlash(workDir)
+	if r, err := remote.Get(cfg, "origin"); err == nil && r.FetchURL() != "" {
+		base = r.FetchURL()
+	}
+
+	// 远程地址可能是 scheme://host/path 或 host:path 的形式，只处理路径部分
+	prefix := ""
+	if i := strings.Index(base, "://"); i >= 0 {
+		if j := strings.IndexByte(base[i+3:], '/'); j >= 0 {
+			prefix, base = base[:i+3+j], base[i+3+j:]
+		}
+	} else if i := strings.IndexByte(base, ':'); i > 0 && !strings.ContainsRune(base[:i], '/') {
+		prefix, base = base[:i+1], base[i+1:]
+	}
+	base = strings.TrimSuffix(strings.TrimSuffix(base, "/"), "/.git")
+	for {
+		switch {
+		case strings.HasPrefix(url, "./"):
+			url = url[2:]
+			continue
+		case strings.HasPrefix(url, "../"):
+			url = url[3:]
+			base = path.Dir(base)
+			continue
+		}
+		break
+	}
+	return prefix + path.Join(base, url)
+}
@@ -0,0 +1,189 @@
+package submodule
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/checkout"
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/config"
+	"geegit/beginner/day6-create-commit/fetch"
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/object"
+	"geegit/beginner/day6-create-commit/refs"
+)
+
+// Init 把子模块的地址从 .gitmodules 复制到父仓库的配置中（git submodule init）
+// 写入 submodule.<name>.active 和解析后的 submodule.<name>.url，返回写入的地址；已经初始化过时返回空字符串
+func Init(gitDir, workDir string, s *Submodule) (string, error) {
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return "", err
+	}
+	if IsActive(cfg, s) {
+		return "", nil
+	}
+	if s.URL == "" {
+		return "", fmt.Errorf("No url found for submodule path '%s' in .gitmodules", s.Path)
+	}
+	url := ResolveURL(cfg, workDir, s.URL)
+	file := gitdir.Path(gitDir, "config")
+	if err := config.SetValue(file, "submodule."+s.Name+".active", "true"); err != nil {
+		return "", err
+	}
+	if err := config.SetValue(file, "submodule."+s.Name+".url", url); err != nil {
+		return "", err
+	}
+	return url, nil
+}
+
+// Update 把子模块检出到父仓库中记录的 commit（git submodule update）
+//  1. 子模块的仓库还不存在时，从 submodule.<name>.url 克隆到 .git/modules/<name>，
+//     并在子模块目录中写入指向它的 .git 文件
+//  2. 记录的 commit 不在子模块的对象库中时，先从远程获取
+//  3. 检出记录的 commit，子模块的 HEAD 处于分离状态
+//
+// 没有初始化的子模块会被跳过。目前只支持本地路径（或 file://）形式的地址；
+// progress 不为 nil 时在克隆前后输出 "Cloning into '<path>'..." 和 "done."；
+// 返回子模块的 HEAD 是否切换到了记录的 commit
+func Update(gitDir, workDir string, s *Submodule, progress io.Writer) (bool, error) {
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return false, err
+	}
+	url, ok := cfg.Get("submodule." + s.Name + ".url")
+	if !ok {
+		return false, nil
+	}
+	if s.Stage > 0 {
+		return false, fmt.Errorf("Skipping unmerged submodule %s", s.Path)
+	}
+	subGitDir := GitDir(gitDir, s.Name)
+	subWorkDir := filepath.Join(workDir, filepath.FromSlash(s.Path))
+
+	// 1. 克隆，或者重新建立工作区与 .git/modules/<name> 的链接
+	populated := IsPopulated(workDir, s)
+	if _, err := os.Stat(filepath.Join(subGitDir, "HEAD")); err != nil {
+		if progress != nil {
+			fmt.Fprintf(progress, "Cloning into '%s'...\n", subWorkDir)
+		}
+		if _, err := fetch.Clone(url, subGitDir, fetch.Options{}); err != nil {
+			return false, fmt.Errorf("clone of '%s' into submodule path '%s' failed: %v", url, subWorkDir, err)
+		}
+		if progress != nil {
+			fmt.Fprintln(progress, "done.")
+		}
+	}
+	if !populated {
+		if err := link(subGitDir, subWorkDir); err != nil {
+			return false, err
+		}
+	}
+
+	// 2. 已经在记录的 commit 上时不需要做任何事
+	head, _ := refs.Resolve(subGitDir, "HEAD")
+	if populated && head == s.Commit {
+		return false, nil
+	}
+	if !object.Exists(subGitDir, s.Commit) {
+		if _, err := fetch.Fetch(subGitDir, "origin", fetch.Options{}, "fetch"); err != nil {
+			return false, err
+		}
+		if !object.Exists(subGitDir, s.Commit) {
+			return false, fmt.Errorf("Fetched in submodule path '%s', but it did not contain %s. Direct fetching of that commit failed.",
+				s.Path, s.Commit)
+		}
+	}
+
+	// 3. 检出；刚克隆的子模块还没有索引，直接从空 tree 强制检出
+	target, err := commit.ReadCommit(subGitDir, s.Commit)
+	if err != nil {
+		return false, err
+	}
+	var from hash.Hash
+	opts := checkout.Options{Force: true}
+	if populated && !head.IsZero() {
+		c, err := commit.ReadCommit(subGitDir, head)
+		if err != nil {
+			return false, err
+		}
+		from, opts.Force = c.Tree, false
+	}
+	if err := checkout.Trees(subGitDir, subWorkDir, from, target.Tree, opts); err != nil {
+		var conflict *checkout.ConflictError
+		if errors.As(err, &conflict) {
+			return false, fmt.Errorf("%v\nAborting\nfatal: Unable to checkout '%s' in submodule path '%s'", err, s.Commit, s.Path)
+		}
+		return false, err
+	}
+	movingFrom := head.String()
+	if name, err := refs.ResolveName(subGitDir, "HEAD"); err == nil && strings.HasPrefix(name, "refs/heads/") {
+		movingFrom = strings.TrimPrefix(name, "refs/heads/")
+	}
+	if err := refs.Detach(subGitDir, s.Commit, "checkout: moving from "+movingFrom+" to "+s.Commit.String()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// link 在子模块目录中写入指向 .git/modules/<name> 的 .git 文件，并在子模块的配置中记录 core.worktree
+// 两边都使用相对路径，父仓库整体移动后仍然有效
+func link(subGitDir, subWorkDir string) error {
+	if err := os.MkdirAll(subWorkDir, 0755); err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(subWorkDir, subGitDir)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(subWorkDir, ".git"), []byte("gitdir: "+filepath.ToSlash(rel)+"\n"), 0644); err != nil {
+		return err
+	}
+	back, err := filepath.Rel(subGitDir, subWorkDir)
+	if err != nil {
+		return err
+	}
+	return config.SetValue(filepath.Join(subGitDir, "config"), "core.worktree", filepath.ToSlash(back))
+}
+
+// Describe 返回 `submodule status` 中显示在 commit 后面的名称
+// 优先使用指向该 commit 的标签，其次是其他引用（去掉 "refs/"，例如 "heads/main"），都没有时使用短哈希
+func Describe(gitDir string, h hash.Hash) string {
+	list, err := refs.List(gitDir)
+	if err != nil {
+		return h.String()[:7]
+	}
+	other := ""
+	for _, r := range list {
+		if r.Hash != h && !peelsTo(gitDir, r, h) {
+			continue
+		}
+		if name, ok := strings.CutPrefix(r.Name, "refs/tags/"); ok {
+			return name
+		}
+		if other == "" {
+			other = strings.TrimPrefix(r.Name, "refs/")
+		}
+	}
+	if other != "" {
+		return other
+	}
+	return h.String()[:7]
+}
+
+// peelsTo 判断引用是否是指向 h 的附注标签
+func peelsTo(gitDir string, r refs.Ref, h hash.Hash) bool {
+	if !strings.HasPrefix(r.Name, "refs/tags/") {
+		return false
+	}
+	obj, err := object.Read(gitDir, r.Hash)
+	if err != nil || obj.Type != hash.TagObject {
+		return false
+	}
+	return strings.HasPrefix(string(obj.Content), "object "+h.String()+"\n")
+}
@@ -0,0 +1,77 @@
+package tag
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/object"
+)
+
+// ReadTag 读取一个 tag 对象
+func ReadTag(gitDir string, h hash.Hash) (*Tag, error) {
+	obj, err := object.Read(gitDir, h)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Type != hash.TagObject {
+		return nil, fmt.Errorf("expected tag, got %s", obj.Type)
+	}
+
+	t, err := ParseTag(obj.Content)
+	if err != nil {
+		return nil, err
+	}
+	t.Hash = h
+	return t, nil
+}
+
+// ParseTag 解析 tag 对象的文本内容
+// 格式: object、type、tag、tagger 四个头部行，一个空行，然后是标签说明
+func ParseTag(content []byte) (*Tag, error) {
+	t := &Tag{}
+
+	headers := content
+	if idx := bytes.Index(content, []byte("\n\n")); idx >= 0 {
+		headers = content[:idx]
+		t.Message = string(content[idx+2:])
+	}
+
+	hasObject := false
+	for _, line := range strings.Split(string(headers), "\n") {
+		if line == "" || line[0] == ' ' {
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "object":
+			h, err := hash.ParseHash(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid object line: %v", err)
+			}
+			t.Object = h
+			hasObject = true
+		case "type":
+			objType, err := hash.ParseObjectType(value)
+			if err != nil {
+				return nil, err
+			}
+			t.Type = objType
+		case "tag":
+			t.Name = value
+		case "tagger":
+			sig, err := commit.ParseSignature(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tagger line: %v", err)
+			}
+			t.Tagger = sig
+		}
+	}
+
+	if !hasObject {
+		return nil, fmt.Errorf("tag object has no object line")
+	}
+	return t, nil
+}
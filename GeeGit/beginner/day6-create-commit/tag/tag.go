@@ -0,0 +1,17 @@
+package tag
+
+import (
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+)
+
+// Tag 表示一个附注标签（annotated tag）对象
+// 轻量标签只是 refs/tags/ 下直接指向 commit 的引用，没有对应的对象
+type Tag struct {
+	Hash    hash.Hash
+	Object  hash.Hash       // 被标记的对象
+	Type    hash.ObjectType // 被标记对象的类型
+	Name    string
+	Tagger  commit.Signature
+	Message string
+}
@@ -0,0 +1,27 @@
+package tag
+
+import (
+	"bytes"
+	"fmt"
+
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/object"
+)
+
+// WriteTag 将 tag 对象写入对象库
+func WriteTag(gitDir string, t *Tag) (hash.Hash, error) {
+	return object.Write(gitDir, hash.TagObject, Encode(t))
+}
+
+// Encode 构建 tag 对象的文本内容
+func Encode(t *Tag) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "object %s\n", t.Object.String())
+	fmt.Fprintf(&buf, "type %s\n", t.Type.String())
+	fmt.Fprintf(&buf, "tag %s\n", t.Name)
+	fmt.Fprintf(&buf, "tagger %s\n", commit.FormatSignature(t.Tagger))
+	buf.WriteString("\n")
+	buf.WriteString(t.Message)
+	return buf.Bytes()
+}
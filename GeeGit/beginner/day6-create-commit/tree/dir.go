@@ -0,0 +1,96 @@
+package tree
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+
+	"geegit/beginner/day6-create-commit/blob"
+	"geegit/beginner/day6-create-commit/hash"
+)
+
+// SkipFunc 决定是否跳过工作区中的某个路径
+// rel 是相对于工作区根目录的路径（使用 "/" 分隔）
+type SkipFunc func(rel string, isDir bool) bool
+
+// WriteDir 将工作区目录递归写入对象库，返回根 tree 的哈希
+// 和 Git 一样，.git 目录和空目录不会出现在 tree 中
+func WriteDir(gitDir, dir string, skip SkipFunc) (hash.Hash, error) {
+	h, _, err := writeDir(gitDir, dir, "", skip)
+	return h, err
+}
+
+// writeDir 返回 tree 哈希，以及该目录是否为空（空目录不写入父 tree）
+func writeDir(gitDir, root, rel string, skip SkipFunc) (hash.Hash, bool, error) {
+	items, err := os.ReadDir(filepath.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		return hash.Hash{}, false, err
+	}
+
+	var entries []TreeEntry
+	for _, item := range items {
+		name := item.Name()
+		if name == ".git" {
+			continue
+		}
+		childRel := path.Join(rel, name)
+		childPath := filepath.Join(root, filepath.FromSlash(childRel))
+
+		info, err := os.Lstat(childPath)
+		if err != nil {
+			return hash.Hash{}, false, err
+		}
+		if skip != nil && skip(childRel, info.IsDir()) {
+			continue
+		}
+
+		switch {
+		case info.IsDir():
+			h, empty, err := writeDir(gitDir, root, childRel, skip)
+			if err != nil {
+				return hash.Hash{}, false, err
+			}
+			if !empty {
+				entries = append(entries, TreeEntry{Mode: "40000", Name: name, Hash: h})
+			}
+		case info.Mode()&os.ModeSymlink != 0:
+			// 符号链接存储的是链接目标本身
+			target, err := os.Readlink(childPath)
+			if err != nil {
+				return hash.Hash{}, false, err
+			}
+			h, err := blob.WriteBlob(gitDir, []byte(filepath.ToSlash(target)))
+			if err != nil {
+				return hash.Hash{}, false, err
+			}
+			entries = append(entries, TreeEntry{Mode: "120000", Name: name, Hash: h})
+		case info.Mode().IsRegular():
+			content, err := os.ReadFile(childPath)
+			if err != nil {
+				return hash.Hash{}, false, err
+			}
+			h, err := blob.WriteBlob(gitDir, content)
+			if err != nil {
+				return hash.Hash{}, false, err
+			}
+			entries = append(entries, TreeEntry{Mode: FileMode(info), Name: name, Hash: h})
+		}
+	}
+
+	h, err := WriteTree(gitDir, entries)
+	return h, len(entries) == 0, err
+}
+
+// FileMode 根据文件信息返回 Git 使用的模式字符串
+func FileMode(info os.FileInfo) string {
+	switch {
+	case info.IsDir():
+		return "40000"
+	case info.Mode()&os.ModeSymlink != 0:
+		return "120000"
+	case info.Mode()&0111 != 0:
+		return "100755"
+	default:
+		return "100644"
+	}
+}
@@ -0,0 +1,58 @@
+package tree
+
+import (
+	"fmt"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/index"
+)
+
+// WriteIndex 把索引中的条目写成 tree 对象（与 `git write-tree` 相同），返回根 tree 的哈希
+// 索引中有冲突条目时返回错误；git add -N 添加的条目不会写入 tree
+func WriteIndex(gitDir string, idx *index.Index) (hash.Hash, error) {
+	return FromIndex(idx, func(entries []TreeEntry) (hash.Hash, error) {
+		return WriteTree(gitDir, entries)
+	})
+}
+
+// FromIndex 与 WriteIndex 相同，但每个 tree 交给 write 写入，用于保存在其他存储后端中的仓库
+func FromIndex(idx *index.Index, write func([]TreeEntry) (hash.Hash, error)) (hash.Hash, error) {
+	entries := make([]index.Entry, 0, len(idx.Entries))
+	for _, e := range idx.Entries {
+		if e.Stage != 0 {
+			return hash.Hash{}, fmt.Errorf("%s: unmerged", e.Path)
+		}
+		if !e.IntentToAdd {
+			entries = append(entries, e)
+		}
+	}
+	return writeIndexDir(write, entries, "")
+}
+
+// writeIndexDir 写出目录 prefix 对应的 tree，entries 是该目录下按路径排序的全部条目
+// 同一子目录下的条目在排序后一定是连续的
+func writeIndexDir(write func([]TreeEntry) (hash.Hash, error), entries []index.Entry, prefix string) (hash.Hash, error) {
+	var treeEntries []TreeEntry
+	for i := 0; i < len(entries); {
+		rel := entries[i].Path[len(prefix):]
+		name, _, isDir := strings.Cut(rel, "/")
+		if !isDir {
+			treeEntries = append(treeEntries, TreeEntry{Mode: entries[i].ModeString(), Name: name, Hash: entries[i].Hash})
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(entries) && strings.HasPrefix(entries[j].Path, prefix+name+"/") {
+			j++
+		}
+		h, err := writeIndexDir(write, entries[i:j], prefix+name+"/")
+		if err != nil {
+			return hash.Hash{}, err
+		}
+		treeEntries = append(treeEntries, TreeEntry{Mode: "40000", Name: name, Hash: h})
+		i = j
+	}
+	return write(treeEntries)
+}
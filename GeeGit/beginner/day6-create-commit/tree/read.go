@@ -2,83 +2,46 @@ package tree
 
 import (
 	"bytes"
-	"compress/zlib"
 	"fmt"
-	"io"
-	"os"
-	"path/filepath"
-	"strings"
 
 	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/object"
 )
 
-// ReadTree 从 .git/objects 读取一个 tree 对象
-func ReadTree(gitDir string, hash hash.Hash) (*Tree, error) {
-	hashStr := hash.String()
-	if len(hashStr) < 2 {
-		return nil, fmt.Errorf("invalid hash")
-	}
-
-	objPath := filepath.Join(gitDir, "objects", hashStr[:2], hashStr[2:])
-
-	file, err := os.Open(objPath)
-	if err != nil {
-		return nil, fmt.Errorf("object not found: %v", err)
-	}
-	defer file.Close()
-
-	zr, err := zlib.NewReader(file)
-	if err != nil {
-		return nil, fmt.Errorf("zlib decompress failed: %v", err)
-	}
-	defer zr.Close()
-
-	data, err := io.ReadAll(zr)
+// ReadTree 读取一个 tree 对象（松散对象或 packfile 中的对象）
+func ReadTree(gitDir string, h hash.Hash) (*Tree, error) {
+	obj, err := object.Read(gitDir, h)
 	if err != nil {
-		return nil, fmt.Errorf("read failed: %v", err)
-	}
-
-	nullIdx := bytes.IndexByte(data, 0)
-	if nullIdx < 0 {
-		return nil, fmt.Errorf("invalid object format")
-	}
-
-	header := string(data[:nullIdx])
-	content := data[nullIdx+1:]
-
-	parts := strings.Split(header, " ")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid object header: %s", header)
+		return nil, err
 	}
 
-	objTypeStr := parts[0]
-	if objTypeStr != "tree" {
-		return nil, fmt.Errorf("expected tree, got %s", objTypeStr)
+	if obj.Type != hash.TreeObject {
+		return nil, fmt.Errorf("expected tree, got %s", obj.Type)
 	}
 
 	// 解析 tree 内容
-	entries, err := parseTreeEntries(content)
+	entries, err := ParseEntries(obj.Content)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse tree entries: %v", err)
 	}
 
 	return &Tree{
-		Hash:    hash,
+		Hash:    h,
 		Entries: entries,
 	}, nil
 }
 
-// parseTreeEntries 解析 tree 对象的二进制内容
+// ParseEntries 解析 tree 对象的二进制内容
 // 格式: <mode> <name>\0<20-byte-hash> ...
-func parseTreeEntries(data []byte) ([]TreeEntry, error) {
+func ParseEntries(data []byte) ([]TreeEntry, error) {
 	var entries []TreeEntry
 	offset := 0
 
 	for offset < len(data) {
 		// 1. 读取模式和名称（以空格分隔）
 		spaceIdx := bytes.IndexByte(data[offset:], ' ')
-		if spaceIdx < 0 {
-			break
+		if spaceIdx <= 0 {
+			return nil, fmt.Errorf("invalid tree format: missing mode at offset %d", offset)
 		}
 		mode := string(data[offset : offset+spaceIdx])
 		offset += spaceIdx + 1
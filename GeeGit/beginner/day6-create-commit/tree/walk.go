@@ -0,0 +1,89 @@
+package tree
+
+import (
+	"errors"
+	"path"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/hash"
+)
+
+// ErrNotFound 表示路径在 tree 中不存在
+var ErrNotFound = errors.New("path not found in tree")
+
+// IsDir 判断条目是否指向子目录（tree 对象）
+// Git 写入的模式是 "40000"，本项目写入的是 "040000"，两者都要识别
+func (e TreeEntry) IsDir() bool {
+	return e.Mode == "40000" || e.Mode == "040000"
+}
+
+// IsSubmodule 判断条目是否是子模块（gitlink，模式 "160000"）
+// gitlink 指向子模块仓库中的 commit，这个 commit 不在本仓库的对象库中
+func (e TreeEntry) IsSubmodule() bool {
+	return e.Mode == "160000"
+}
+
+// WalkFunc 是 Walk 遍历每个文件条目时调用的函数
+// p 是相对于根 tree 的完整路径（使用 "/" 分隔）
+type WalkFunc func(p string, entry TreeEntry) error
+
+// Walk 递归遍历 tree，对每个非目录条目调用 fn
+func Walk(gitDir string, root hash.Hash, fn WalkFunc) error {
+	return walk(gitDir, root, "", fn)
+}
+
+func walk(gitDir string, h hash.Hash, prefix string, fn WalkFunc) error {
+	t, err := ReadTree(gitDir, h)
+	if err != nil {
+		return err
+	}
+	for _, entry := range t.Entries {
+		p := path.Join(prefix, entry.Name)
+		if entry.IsDir() {
+			if err := walk(gitDir, entry.Hash, p, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(p, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindEntry 在根 tree 中按路径查找条目，例如 "src/main.go"
+// 找不到时返回 ErrNotFound
+func FindEntry(gitDir string, root hash.Hash, p string) (*TreeEntry, error) {
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	current := root
+
+	for i, name := range parts {
+		t, err := ReadTree(gitDir, current)
+		if err != nil {
+			return nil, err
+		}
+
+		var found *TreeEntry
+		for j := range t.Entries {
+			if t.Entries[j].Name == name {
+				found = &t.Entries[j]
+				break
+			}
+		}
+		if found == nil {
+			return nil, ErrNotFound
+		}
+
+		// 最后一段就是目标条目
+		if i == len(parts)-1 {
+			return found, nil
+		}
+		if !found.IsDir() {
+			return nil, ErrNotFound
+		}
+		current = found.Hash
+	}
+
+	return nil, ErrNotFound
+}
@@ -0,0 +1,251 @@
+package wildmatch
+
+import "strings"
+
+// wildmatch 的内部结果，与 git 的 dowild 一致
+// abortAll 和 abortToStarStar 用于在不可能匹配时尽早结束回溯
+type wildResult int
+
+const (
+	wildMatch wildResult = iota
+	wildNoMatch
+	wildAbortAll
+	wildAbortToStarStar
+)
+
+// Flags 控制 Match 的行为
+type Flags int
+
+const (
+	// PathName 表示按路径匹配："?"、"*" 和字符类不匹配 "/"，只有 "**" 能跨越目录
+	PathName Flags = 1 << iota
+	// CaseFold 表示不区分大小写（core.ignoreCase）
+	CaseFold
+)
+
+// Match 按 git 的 wildmatch 规则匹配 text
+//   - "?" 匹配任意一个字符，"*" 匹配任意字符串
+//   - "**" 只在作为完整的路径段时（"**/"、"/**/"、"/**"）才在 PathName 模式下跨越目录
+//   - "[a-z]"、"[!a]"、"[^a]" 和 "[[:alpha:]]" 等字符类
+//   - "\" 转义下一个字符
+//
+// .gitignore 等路径规则使用 PathName；`git branch --list` 等名称匹配不使用
+func Match(pattern, text string, flags Flags) bool {
+	if flags&CaseFold != 0 {
+		pattern, text = strings.ToLower(pattern), strings.ToLower(text)
+	}
+	return dowild(pattern, text, flags&PathName != 0) == wildMatch
+}
+
+func dowild(p, text string, pathname bool) wildResult {
+	pi, ti := 0, 0
+	for ; pi < len(p); pi, ti = pi+1, ti+1 {
+		pc := p[pi]
+		if ti >= len(text) && pc != '*' {
+			return wildAbortAll
+		}
+		var tc byte
+		if ti < len(text) {
+			tc = text[ti]
+		}
+
+		switch pc {
+		case '\\':
+			// 转义：下一个字符按字面匹配
+			pi++
+			if pi >= len(p) || tc != p[pi] {
+				return wildNoMatch
+			}
+
+		case '?':
+			if pathname && tc == '/' {
+				return wildNoMatch
+			}
+
+		case '*':
+			matchSlash := !pathname
+			pi++
+			if pi < len(p) && p[pi] == '*' {
+				// 连续的 "*"，只有作为完整路径段时才是能跨越目录的 "**"
+				prev := pi - 2
+				for pi < len(p) && p[pi] == '*' {
+					pi++
+				}
+				if (prev < 0 || p[prev] == '/') &&
+					(pi == len(p) || p[pi] == '/' || (p[pi] == '\\' && pi+1 < len(p) && p[pi+1] == '/')) {
+					// "**/" 也可以匹配零个目录
+					if pi < len(p) && p[pi] == '/' && dowild(p[pi+1:], text[ti:], pathname) == wildMatch {
+						return wildMatch
+					}
+					matchSlash = true
+				}
+			}
+
+			if pi == len(p) {
+				// 末尾的 "*" 匹配剩余的全部内容（不跨越 "/" 时要求剩余内容中没有 "/"）
+				if !matchSlash && strings.IndexByte(text[ti:], '/') >= 0 {
+					return wildNoMatch
+				}
+				return wildMatch
+			}
+			if !matchSlash && p[pi] == '/' {
+				// "*/" 直接跳到下一个 "/"
+				slash := strings.IndexByte(text[ti:], '/')
+				if slash < 0 {
+					return wildNoMatch
+				}
+				ti += slash
+				continue
+			}
+
+			for ; ti < len(text); ti++ {
+				r := dowild(p[pi:], text[ti:], pathname)
+				if r != wildNoMatch {
+					if !matchSlash || r != wildAbortToStarStar {
+						return r
+					}
+				} else if !matchSlash && text[ti] == '/' {
+					return wildAbortToStarStar
+				}
+			}
+			return wildAbortAll
+
+		case '[':
+			next, matched, ok := matchClass(p, pi, tc)
+			if !ok {
+				return wildAbortAll
+			}
+			if !matched || (pathname && tc == '/') {
+				return wildNoMatch
+			}
+			pi = next
+
+		default:
+			if tc != pc {
+				return wildNoMatch
+			}
+		}
+	}
+
+	if ti < len(text) {
+		return wildNoMatch
+	}
+	return wildMatch
+}
+
+// matchClass 匹配从 p[start]（"["）开始的字符类
+// 返回字符类结尾 "]" 的下标、是否匹配，以及字符类是否完整
+func matchClass(p string, start int, tc byte) (int, bool, bool) {
+	pi := start + 1
+	if pi >= len(p) {
+		return 0, false, false
+	}
+	negated := false
+	if p[pi] == '!' || p[pi] == '^' {
+		negated = true
+		pi++
+	}
+
+	matched := false
+	var prev byte
+	// 第一个字符即使是 "]" 也作为普通字符处理
+	for first := true; first || (pi < len(p) && p[pi] != ']'); first = false {
+		if pi >= len(p) {
+			return 0, false, false
+		}
+		pc := p[pi]
+
+		switch {
+		case pc == '\\':
+			pi++
+			if pi >= len(p) {
+				return 0, false, false
+			}
+			pc = p[pi]
+			if tc == pc {
+				matched = true
+			}
+
+		case pc == '-' && prev != 0 && pi+1 < len(p) && p[pi+1] != ']':
+			pi++
+			hi := p[pi]
+			if hi == '\\' {
+				pi++
+				if pi >= len(p) {
+					return 0, false, false
+				}
+				hi = p[pi]
+			}
+			if tc >= prev && tc <= hi {
+				matched = true
+			}
+			pc = 0 // 范围之后不能再接 "-"
+
+		case pc == '[' && pi+1 < len(p) && p[pi+1] == ':':
+			end := strings.Index(p[pi+2:], ":]")
+			if end < 0 {
+				// 不是 "[:class:]"，按普通字符处理
+				if tc == pc {
+					matched = true
+				}
+				break
+			}
+			class := p[pi+2 : pi+2+end]
+			in, ok := inClass(class, tc)
+			if !ok {
+				return 0, false, false
+			}
+			if in {
+				matched = true
+			}
+			pi += 2 + end + 1
+			pc = 0
+
+		default:
+			if tc == pc {
+				matched = true
+			}
+		}
+
+		prev = pc
+		pi++
+	}
+	if pi >= len(p) {
+		return 0, false, false
+	}
+	return pi, matched != negated, true
+}
+
+// inClass 判断字符是否属于 POSIX 字符类，第二个返回值表示类名是否有效
+func inClass(class string, c byte) (bool, bool) {
+	isUpper := c >= 'A' && c <= 'Z'
+	isLower := c >= 'a' && c <= 'z'
+	isDigit := c >= '0' && c <= '9'
+	switch class {
+	case "alnum":
+		return isUpper || isLower || isDigit, true
+	case "alpha":
+		return isUpper || isLower, true
+	case "blank":
+		return c == ' ' || c == '\t', true
+	case "cntrl":
+		return c < 0x20 || c == 0x7f, true
+	case "digit":
+		return isDigit, true
+	case "graph":
+		return c > 0x20 && c < 0x7f, true
+	case "lower":
+		return isLower, true
+	case "print":
+		return c >= 0x20 && c < 0x7f, true
+	case "punct":
+		return c > 0x20 && c < 0x7f && !isUpper && !isLower && !isDigit, true
+	case "space":
+		return c == ' ' || (c >= '\t' && c <= '\r'), true
+	case "upper":
+		return isUpper, true
+	case "xdigit":
+		return isDigit || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F'), true
+	}
+	return false, false
+}
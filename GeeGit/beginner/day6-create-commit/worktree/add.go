@@ -0,0 +1,214 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/checkout"
+	"geegit/beginner/day6-create-commit/commit"
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/refs"
+	"geegit/beginner/day6-create-commit/status"
+)
+
+var (
+	// ErrMainWorktree 表示不能删除主工作区
+	ErrMainWorktree = errors.New("is a main working tree")
+	// ErrDirty 表示工作区中有修改或未跟踪的文件
+	ErrDirty = errors.New("contains modified or untracked files, use --force to delete it")
+)
+
+// AddOptions 控制 Add 检出的内容
+type AddOptions struct {
+	Branch string    // 检出的分支（完整引用名），为空时分离 HEAD
+	Commit hash.Hash // 检出的 commit
+	Force  bool      // 允许检出已经在其他工作区中检出的分支
+}
+
+// Add 在 path 创建一个新的链接工作区（git worktree add）
+//  1. 在 .git/worktrees/<name> 中创建工作区的 git 目录：HEAD、commondir、gitdir
+//  2. 在 path 中写入指向该目录的 .git 文件
+//  3. 检出 commit 的内容并写入工作区自己的索引
+//
+// 创建过程中 git 目录带有 locked 文件，避免被并发的 prune 清理；失败时删除已创建的内容
+func Add(gitDir, path string, opts AddOptions) (err error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	// 1. 目标路径必须不存在或是空目录，分支不能已在其他工作区中检出
+	if entries, err := os.ReadDir(abs); err == nil && len(entries) > 0 {
+		return fmt.Errorf("'%s' already exists", path)
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("'%s' already exists", path)
+	}
+	if opts.Branch != "" && !opts.Force {
+		if w := checkedOut(gitDir, opts.Branch, ""); w != nil {
+			return fmt.Errorf("'%s' is already checked out at '%s'", refs.ShortName(opts.Branch), w.Path)
+		}
+	}
+	c, err := commit.ReadCommit(gitDir, opts.Commit)
+	if err != nil {
+		return err
+	}
+
+	// 2. 创建工作区的 git 目录
+	common := gitdir.CommonDir(gitDir)
+	id := uniqueID(common, filepath.Base(abs))
+	wtGitDir := filepath.Join(common, "worktrees", id)
+	if err := os.MkdirAll(wtGitDir, 0755); err != nil {
+		return err
+	}
+	_, statErr := os.Stat(abs)
+	createdPath := errors.Is(statErr, os.ErrNotExist)
+	defer func() {
+		if err != nil {
+			os.RemoveAll(wtGitDir)
+			removeEmptyWorktreesDir(common)
+			if createdPath {
+				os.RemoveAll(abs)
+			} else {
+				os.Remove(filepath.Join(abs, ".git"))
+			}
+		}
+	}()
+	if err := os.MkdirAll(abs, 0755); err != nil {
+		return err
+	}
+	files := []struct{ name, content string }{
+		{"locked", "initializing\n"},
+		{"gitdir", filepath.Join(abs, ".git") + "\n"},
+		{"commondir", "../..\n"},
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(wtGitDir, f.name), []byte(f.content), 0644); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(abs, ".git"), []byte("gitdir: "+wtGitDir+"\n"), 0644); err != nil {
+		return err
+	}
+
+	// 3. HEAD 指向分支或直接指向 commit
+	head := opts.Commit.String() + "\n"
+	if opts.Branch != "" {
+		head = "ref: " + opts.Branch + "\n"
+	}
+	if err := os.WriteFile(filepath.Join(wtGitDir, "HEAD"), []byte(head), 0644); err != nil {
+		return err
+	}
+	committer, err := commit.DefaultSignature(gitDir, "committer")
+	if err != nil {
+		return err
+	}
+	if err := refs.AppendLog(wtGitDir, "HEAD", refs.LogEntry{New: opts.Commit, Committer: committer}); err != nil {
+		return err
+	}
+
+	// 4. 检出文件（git reset --hard），与 git 一致同时记录 ORIG_HEAD；
+	// 引用的值没有变化，只有指向分支的 HEAD 会在 reflog 中多一条记录
+	if err := checkout.Trees(wtGitDir, abs, hash.Hash{}, c.Tree, checkout.Options{Force: true}); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(wtGitDir, "ORIG_HEAD"), []byte(opts.Commit.String()+"\n"), 0644); err != nil {
+		return err
+	}
+	if opts.Branch != "" {
+		entry := refs.LogEntry{Old: opts.Commit, New: opts.Commit, Committer: committer, Message: "reset: moving to HEAD"}
+		if err := refs.AppendLog(wtGitDir, "HEAD", entry); err != nil {
+			return err
+		}
+	}
+	return os.Remove(filepath.Join(wtGitDir, "locked"))
+}
+
+// uniqueID 返回工作区在 .git/worktrees 下的名字：路径的最后一段，已被占用时加上数字后缀
+func uniqueID(common, base string) string {
+	base = strings.Map(func(r rune) rune {
+		if r <= ' ' || strings.ContainsRune(`~^:?*[\`, r) {
+			return '-'
+		}
+		return r
+	}, strings.TrimPrefix(base, "."))
+	if base == "" {
+		base = "worktree"
+	}
+	id := base
+	for n := 1; ; n++ {
+		if _, err := os.Lstat(filepath.Join(common, "worktrees", id)); errors.Is(err, os.ErrNotExist) {
+			return id
+		}
+		id = base + strconv.Itoa(n)
+	}
+}
+
+// Remove 删除链接工作区的目录和它在 .git/worktrees 下的 git 目录（git worktree remove）
+// 工作区有修改或未跟踪的文件时，只有 force 才会删除；加锁的工作区不能删除
+func Remove(w *Worktree, force bool) error {
+	if w.IsMain() {
+		return ErrMainWorktree
+	}
+	if w.IsLocked && !force {
+		if w.Locked != "" {
+			return fmt.Errorf("cannot remove a locked working tree, lock reason: %s", w.Locked)
+		}
+		return errors.New("cannot remove a locked working tree")
+	}
+	if !force && w.Prunable == "" {
+		st, err := status.Compute(w.GitDir, w.Path, status.Options{AllUntracked: true})
+		if err != nil {
+			return err
+		}
+		if len(st.Files) > 0 {
+			return ErrDirty
+		}
+	}
+
+	if err := os.RemoveAll(w.Path); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(w.GitDir); err != nil {
+		return err
+	}
+	removeEmptyWorktreesDir(filepath.Dir(filepath.Dir(w.GitDir)))
+	return nil
+}
+
+// Prune 清理工作区目录已经不存在的 .git/worktrees/<name>（git worktree prune）
+// 每个要清理的工作区调用一次 report（例如输出 "Removing worktrees/<name>: <原因>"）；
+// dryRun 为 true 时只报告不删除；加锁的工作区不会被清理
+func Prune(gitDir string, dryRun bool, report func(w *Worktree)) error {
+	list, err := List(gitDir)
+	if err != nil {
+		return err
+	}
+	for _, w := range list {
+		if w.IsMain() || w.Prunable == "" || w.IsLocked {
+			continue
+		}
+		if report != nil {
+			report(w)
+		}
+		if dryRun {
+			continue
+		}
+		if err := os.RemoveAll(w.GitDir); err != nil {
+			return err
+		}
+	}
+	if !dryRun {
+		removeEmptyWorktreesDir(gitdir.CommonDir(gitDir))
+	}
+	return nil
+}
+
+// removeEmptyWorktreesDir 在最后一个链接工作区被删除后删除空的 .git/worktrees 目录
+func removeEmptyWorktreesDir(common string) {
+	os.Remove(filepath.Join(common, "worktrees"))
+}
@@ -0,0 +1,157 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"geegit/beginner/day6-create-commit/gitdir"
+	"geegit/beginner/day6-create-commit/hash"
+	"geegit/beginner/day6-create-commit/refs"
+)
+
+// Worktree 是仓库的一个工作区
+// 主工作区的 git 目录是 .git 本身；链接工作区的 git 目录是 .git/worktrees/<ID>，
+// 其中保存各自的 HEAD、索引和 reflog，对象库和引用通过 commondir 与主工作区共享
+type Worktree struct {
+	Path   string    // 工作区根目录
+	GitDir string    // 工作区的 git 目录
+	ID     string    // 链接工作区在 .git/worktrees 下的名字，主工作区为空
+	Head   hash.Hash // HEAD 指向的 commit，还没有 commit 时为零值
+	Branch string    // HEAD 指向的分支（完整引用名），分离 HEAD 时为空
+
+	// IsLocked 表示工作区已加锁（git worktree lock），Locked 是加锁的原因，可以为空
+	Locked   string
+	IsLocked bool
+
+	// Prunable 是可以被 prune 清理的原因，为空表示工作区有效
+	Prunable string
+}
+
+// IsMain 判断是否是主工作区
+func (w *Worktree) IsMain() bool {
+	return w.ID == ""
+}
+
+// List 列出仓库的所有工作区，主工作区在最前面，其余按名字排序
+func List(gitDir string) ([]*Worktree, error) {
+	common := gitdir.CommonDir(gitDir)
+	main := &Worktree{Path: filepath.Dir(common), GitDir: common}
+	readHead(main)
+	list := []*Worktree{main}
+
+	entries, err := os.ReadDir(filepath.Join(common, "worktrees"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			list = append(list, read(common, e.Name()))
+		}
+	}
+	sort.SliceStable(list[1:], func(i, j int) bool { return list[1+i].ID < list[1+j].ID })
+	return list, nil
+}
+
+// read 读取 .git/worktrees/<id> 中记录的链接工作区
+func read(common, id string) *Worktree {
+	w := &Worktree{ID: id, GitDir: filepath.Join(common, "worktrees", id)}
+	if data, err := os.ReadFile(filepath.Join(w.GitDir, "locked")); err == nil {
+		w.Locked, w.IsLocked = strings.TrimSpace(string(data)), true
+	}
+
+	// gitdir 文件记录工作区中 .git 文件的绝对路径
+	data, err := os.ReadFile(filepath.Join(w.GitDir, "gitdir"))
+	switch {
+	case err != nil:
+		w.Prunable = "gitdir file does not exist"
+	case strings.TrimSpace(string(data)) == "":
+		w.Prunable = "invalid gitdir file"
+	default:
+		gitFile := strings.TrimSpace(string(data))
+		w.Path = filepath.Dir(gitFile)
+		if _, err := os.Stat(gitFile); err != nil {
+			w.Prunable = "gitdir file points to non-existent location"
+		}
+	}
+	readHead(w)
+	return w
+}
+
+// readHead 读取工作区的 HEAD
+func readHead(w *Worktree) {
+	ref, err := refs.Read(w.GitDir, "HEAD")
+	if err != nil {
+		return
+	}
+	w.Branch = ref.Target
+	w.Head = ref.Hash
+	if ref.Target != "" {
+		w.Head, _ = refs.Resolve(w.GitDir, ref.Target)
+	}
+}
+
+// Current 返回 gitDir 对应的工作区
+func Current(gitDir string) (*Worktree, error) {
+	list, err := List(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range list {
+		if filepath.Clean(w.GitDir) == filepath.Clean(gitDir) {
+			return w, nil
+		}
+	}
+	return nil, fmt.Errorf("'%s' is not a working tree", gitDir)
+}
+
+// CheckedOut 返回除 gitDir 自身以外已经检出分支 ref（完整引用名）的工作区，没有时返回 nil
+// 同一个分支不能同时在两个工作区中检出
+func CheckedOut(gitDir, ref string) *Worktree {
+	return checkedOut(gitDir, ref, gitDir)
+}
+
+// checkedOut 返回检出了分支 ref 的工作区，git 目录为 skip 的工作区除外
+func checkedOut(gitDir, ref, skip string) *Worktree {
+	list, err := List(gitDir)
+	if err != nil {
+		return nil
+	}
+	for _, w := range list {
+		if w.Branch == ref && (skip == "" || filepath.Clean(w.GitDir) != filepath.Clean(skip)) {
+			return w
+		}
+	}
+	return nil
+}
+
+// Find 按路径查找工作区，arg 可以是相对当前目录的路径
+func Find(gitDir, arg string) (*Worktree, error) {
+	abs, err := filepath.Abs(arg)
+	if err != nil {
+		return nil, err
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = real
+	}
+	list, err := List(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range list {
+		if w.Path == "" {
+			continue
+		}
+		p := w.Path
+		if real, err := filepath.EvalSymlinks(p); err == nil {
+			p = real
+		}
+		if p == abs {
+			return w, nil
+		}
+	}
+	return nil, fmt.Errorf("'%s' is not a working tree", arg)
+}